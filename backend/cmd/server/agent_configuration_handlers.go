@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/opamp"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// pinAgentConfigurationHandler 指定 Agent 使用的配置
+// @Summary      指定 Agent 使用的配置
+// @Description  将 Agent 固定到指定名称的配置, 不再按标签匹配配置; 配置因此改变时立即推送。Agent 上报状态不会覆盖指定的配置
+// @Tags         agents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Agent ID"
+// @Param        request body model.PinConfigurationRequest true "配置名称"
+// @Success      200 {object} agentChange
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/{id}/configuration [put]
+func pinAgentConfigurationHandler(store store.Store, opampServer opamp.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.PinConfigurationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		config, err := store.GetConfigurationByName(c.Request.Context(), req.ConfigurationName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if config == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "configuration not found"})
+			return
+		}
+
+		respondConfigurationPin(c, store, opampServer, req.ConfigurationName)
+	}
+}
+
+// unpinAgentConfigurationHandler 取消指定 Agent 使用的配置
+// @Summary      取消指定 Agent 使用的配置
+// @Description  取消固定的配置, 恢复按标签匹配配置; 配置因此改变时立即推送
+// @Tags         agents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Agent ID"
+// @Success      200 {object} agentChange
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/{id}/configuration [delete]
+func unpinAgentConfigurationHandler(store store.Store, opampServer opamp.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		respondConfigurationPin(c, store, opampServer, "")
+	}
+}
+
+// respondConfigurationPin 设置路径中 Agent 指定的配置并写入响应
+func respondConfigurationPin(c *gin.Context, store store.Store, opampServer opamp.Server, configName string) {
+	agentID := c.Param("id")
+
+	before, err := store.GetAgent(c.Request.Context(), agentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	change, err := pinAgentConfiguration(c.Request.Context(), store, opampServer, agentID, configName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if change == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+	if before != nil {
+		audit.SetChange(c, gin.H{"configuration_name": before.ConfigurationName}, gin.H{"configuration_name": configName})
+	}
+
+	c.JSON(http.StatusOK, change)
+}
+
+// pinAgentConfiguration 设置 Agent 指定的配置, 为空表示取消指定; 生效的配置因此改变时推送新配置
+//
+// Agent 不存在时返回 nil。
+func pinAgentConfiguration(ctx context.Context, store store.Store, opampServer opamp.Server, agentID, configName string) (*agentChange, error) {
+	previous, err := store.GetConfiguration(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := store.UpdateAgentConfigurationName(ctx, agentID, configName)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	agent, err := store.GetAgent(ctx, agentID)
+	if err != nil || agent == nil {
+		return nil, err
+	}
+
+	change := &agentChange{Agent: agent}
+	change.ConfigurationPushed, err = reconcileAgentConfig(ctx, store, opampServer, agent, previous)
+	if err != nil {
+		change.PushError = err.Error()
+	}
+	return change, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+func TestAgentConfigurationPinHandlers(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	server := &fakeOpAMPServer{sent: make(map[string]string)}
+
+	agent := &model.Agent{
+		ID:     uuid.New().String(),
+		Name:   "pinned-agent",
+		Status: model.StatusOnline,
+		Labels: model.Labels{"env": "dev"},
+	}
+	require.NoError(t, store.UpsertAgent(ctx, agent))
+
+	matchedName := "test-matched-" + uuid.New().String()[:8]
+	pinnedName := "test-pinned-" + uuid.New().String()[:8]
+	require.NoError(t, store.CreateConfiguration(ctx, &model.Configuration{
+		Name:      matchedName,
+		RawConfig: "receivers: {}",
+		Selector:  map[string]string{"env": "dev"},
+	}))
+	require.NoError(t, store.CreateConfiguration(ctx, &model.Configuration{
+		Name:      pinnedName,
+		RawConfig: "exporters: {}",
+	}))
+	t.Cleanup(func() {
+		_ = store.DeleteConfiguration(ctx, matchedName)
+		_ = store.DeleteConfiguration(ctx, pinnedName)
+	})
+
+	router := setupTestRouter()
+	router.PUT("/agents/:id/configuration", pinAgentConfigurationHandler(store, server))
+	router.DELETE("/agents/:id/configuration", unpinAgentConfigurationHandler(store, server))
+
+	// 指定配置后立即推送
+	body, _ := json.Marshal(model.PinConfigurationRequest{ConfigurationName: pinnedName})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/agents/"+agent.ID+"/configuration", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var change agentChange
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &change))
+	assert.Equal(t, pinnedName, change.Agent.ConfigurationName)
+	assert.Equal(t, pinnedName, change.ConfigurationPushed)
+	assert.Equal(t, pinnedName, server.sent[agent.ID])
+
+	// 取消指定后恢复按标签匹配的配置
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/agents/"+agent.ID+"/configuration", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	change = agentChange{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &change))
+	assert.Empty(t, change.Agent.ConfigurationName)
+	assert.Equal(t, matchedName, server.sent[agent.ID])
+
+	// 配置不存在
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/agents/"+agent.ID+"/configuration", bytes.NewReader([]byte(`{"configuration_name":"missing"}`))))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Agent 不存在
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/agents/missing/configuration", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
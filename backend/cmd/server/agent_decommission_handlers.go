@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/opamp"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// decommissionAgentHandler 退役 Agent
+// @Summary      退役 Agent
+// @Description  将 Agent 标记为退役并关闭其 OpAMP 连接, 之后该 Agent 的连接都会被拒绝。
+// @Description  final_config 为 true 时先向已连接的 Agent 推送停用所有数据管道的配置; purge_history 为 true 时删除其连接历史和配置应用历史, 否则保留。
+// @Description  删除已退役的 Agent 后它可以重新注册
+// @Tags         agents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Agent ID"
+// @Param        request body model.DecommissionRequest false "退役选项"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/{id}/decommission [post]
+func decommissionAgentHandler(store store.Store, opampServer opamp.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		agentID := c.Param("id")
+
+		var req model.DecommissionRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		agent, err := store.GetAgent(ctx, agentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if agent == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+			return
+		}
+		if agent.IsDecommissioned() {
+			c.JSON(http.StatusConflict, gin.H{"error": "agent is already decommissioned"})
+			return
+		}
+
+		// 先推送最终配置, 连接关闭后无法再推送
+		finalConfigSent := false
+		var pushError string
+		if req.FinalConfig && opampServer.Connected(agentID) {
+			update := &model.AgentUpdate{Configuration: model.DecommissionConfiguration()}
+			if err := opampServer.SendUpdate(ctx, agentID, update); err != nil {
+				pushError = err.Error()
+			} else {
+				finalConfigSent = true
+			}
+		}
+
+		found, err := store.DecommissionAgent(ctx, agentID, req.PurgeHistory)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+			return
+		}
+
+		// 标记退役后再断开, 避免 Agent 在两者之间重新连接
+		disconnectError := ""
+		if err := opampServer.Disconnect(agentID); err != nil {
+			disconnectError = err.Error()
+		}
+		audit.SetChange(c, nil, req)
+
+		agent, err = store.GetAgent(ctx, agentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		response := gin.H{
+			"agent":             agent,
+			"final_config_sent": finalConfigSent,
+			"history_purged":    req.PurgeHistory,
+		}
+		if pushError != "" {
+			response["push_error"] = pushError
+		}
+		if disconnectError != "" {
+			response["disconnect_error"] = disconnectError
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
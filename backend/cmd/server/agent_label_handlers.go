@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/opamp"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// errLabelsOutOfScope 修改后的标签会使 Agent 移出当前角色的范围
+var errLabelsOutOfScope = errors.New("labels would move the agent outside of the role scope")
+
+// agentChange 单个 Agent 修改标签或指定配置的结果
+type agentChange struct {
+	Agent *model.Agent `json:"agent"`
+	// ConfigurationPushed 修改后生效的配置改变并已推送时为该配置的名称
+	ConfigurationPushed string `json:"configuration_pushed,omitempty"`
+	// PushError 推送新配置失败的原因
+	PushError string `json:"push_error,omitempty"`
+}
+
+// setAgentLabelsHandler 整体替换 Agent 的服务端标签
+// @Summary      设置 Agent 标签
+// @Description  整体替换 Agent 的服务端标签。服务端标签与 Agent 上报的标签合并, 同名时服务端标签优先; 生效的配置因此改变时立即推送新配置
+// @Tags         agents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Agent ID"
+// @Param        labels body model.AgentLabelsRequest true "服务端标签"
+// @Success      200 {object} agentChange
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/{id}/labels [put]
+func setAgentLabelsHandler(store store.Store, opampServer opamp.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.AgentLabelsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := model.ValidateLabels(req.Labels); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		respondLabelChange(c, store, opampServer, func(model.Labels) model.Labels {
+			return req.Labels.Merge(nil)
+		})
+	}
+}
+
+// patchAgentLabelsHandler 修改 Agent 的部分服务端标签
+// @Summary      修改 Agent 标签
+// @Description  设置 set 中的服务端标签并删除 remove 中的服务端标签, 其余标签保持不变; 生效的配置因此改变时立即推送新配置
+// @Tags         agents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path string true "Agent ID"
+// @Param        labels body model.AgentLabelsPatch true "标签修改"
+// @Success      200 {object} agentChange
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/{id}/labels [patch]
+func patchAgentLabelsHandler(store store.Store, opampServer opamp.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.AgentLabelsPatch
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := model.ValidateLabels(req.Set); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		respondLabelChange(c, store, opampServer, func(labels model.Labels) model.Labels {
+			return labels.Apply(req.Set, req.Remove)
+		})
+	}
+}
+
+// bulkAgentLabelsHandler 按选择器批量修改 Agent 的服务端标签
+// @Summary      批量修改 Agent 标签
+// @Description  对所有匹配选择器 (且在当前角色范围内) 的 Agent 设置 set 中的服务端标签并删除 remove 中的服务端标签; 生效的配置因此改变时立即推送新配置
+// @Tags         agents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body model.BulkAgentLabelsRequest true "选择器和标签修改"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/labels [post]
+func bulkAgentLabelsHandler(store store.Store, opampServer opamp.Server) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.BulkAgentLabelsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(req.Selector) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "selector must not be empty"})
+			return
+		}
+		if err := model.ValidateLabels(req.Set); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		selector, ok := auth.MergeScope(c, req.Selector)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "selector is outside of the role scope"})
+			return
+		}
+		agents, err := store.ListAgentsBySelector(c.Request.Context(), selector)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		changes := make([]*agentChange, 0, len(agents))
+		var skipped []string
+		for _, agent := range agents {
+			change, err := changeAgentLabels(c.Request.Context(), store, opampServer, auth.ScopeSelector(c), agent.ID, func(labels model.Labels) model.Labels {
+				return labels.Apply(req.Set, req.Remove)
+			})
+			if errors.Is(err, errLabelsOutOfScope) {
+				skipped = append(skipped, agent.ID)
+				continue
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if change != nil {
+				changes = append(changes, change)
+			}
+		}
+		audit.SetChange(c, nil, gin.H{"selector": req.Selector, "set": req.Set, "remove": req.Remove})
+
+		c.JSON(http.StatusOK, gin.H{
+			"updated": changes,
+			"total":   len(changes),
+			"skipped": skipped,
+		})
+	}
+}
+
+// respondLabelChange 修改路径中 Agent 的服务端标签并写入响应
+func respondLabelChange(c *gin.Context, store store.Store, opampServer opamp.Server, update func(model.Labels) model.Labels) {
+	agentID := c.Param("id")
+
+	before, err := store.GetAgent(c.Request.Context(), agentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	change, err := changeAgentLabels(c.Request.Context(), store, opampServer, auth.ScopeSelector(c), agentID, update)
+	if errors.Is(err, errLabelsOutOfScope) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if change == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+	if before != nil {
+		audit.SetChange(c, before.ServerLabels, change.Agent.ServerLabels)
+	}
+
+	c.JSON(http.StatusOK, change)
+}
+
+// changeAgentLabels 修改 Agent 的服务端标签, 生效的配置因此改变时推送新配置
+//
+// 修改后的标签必须仍在角色范围 scope 内。Agent 不存在时返回 nil。
+func changeAgentLabels(ctx context.Context, store store.Store, opampServer opamp.Server, scope map[string]string, agentID string, update func(model.Labels) model.Labels) (*agentChange, error) {
+	previous, err := store.GetConfiguration(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+
+	agent, err := store.UpdateAgentServerLabels(ctx, agentID, func(agent *model.Agent) error {
+		agent.ServerLabels = update(agent.ServerLabels)
+		agent.RefreshLabels()
+		if !agent.Labels.Matches(scope) {
+			return errLabelsOutOfScope
+		}
+		return nil
+	})
+	if err != nil || agent == nil {
+		return nil, err
+	}
+
+	change := &agentChange{Agent: agent}
+	change.ConfigurationPushed, err = reconcileAgentConfig(ctx, store, opampServer, agent, previous)
+	if err != nil {
+		change.PushError = err.Error()
+	}
+	return change, nil
+}
+
+// reconcileAgentConfig 重新计算 Agent 应使用的配置, 与 previous 不同时推送给已连接的 Agent
+//
+// 返回已推送的配置名称, 未推送时为空。
+func reconcileAgentConfig(ctx context.Context, store store.Store, opampServer opamp.Server, agent *model.Agent, previous *model.Configuration) (string, error) {
+	config, err := store.GetConfiguration(ctx, agent.ID)
+	if err != nil {
+		return "", err
+	}
+	if config == nil {
+		return "", nil
+	}
+	if previous != nil && previous.Name == config.Name && previous.ConfigHash == config.ConfigHash {
+		return "", nil
+	}
+
+	// 未连接的 Agent 在下次连接时获取配置, 抖动中的 Agent 待其稳定后获取
+	if !opampServer.Connected(agent.ID) || opampServer.HoldsConfig(agent) {
+		return "", nil
+	}
+
+	if err := pushConfigToAgent(ctx, store, opampServer, agent.ID, config); err != nil {
+		return "", err
+	}
+	return config.Name, nil
+}
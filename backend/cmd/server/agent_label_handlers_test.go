@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/opamp"
+)
+
+// fakeOpAMPServer 记录推送的配置, 所有 Agent 都视为已连接
+type fakeOpAMPServer struct {
+	opamp.Server
+	sent map[string]string
+}
+
+func (f *fakeOpAMPServer) Connected(agentID string) bool { return true }
+
+func (f *fakeOpAMPServer) HoldsConfig(agent *model.Agent) bool { return false }
+
+func (f *fakeOpAMPServer) SendUpdate(ctx context.Context, agentID string, update *model.AgentUpdate) error {
+	f.sent[agentID] = update.Configuration.Name
+	return nil
+}
+
+func TestAgentLabelHandlers(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := context.Background()
+	server := &fakeOpAMPServer{sent: make(map[string]string)}
+
+	agent := &model.Agent{
+		ID:             uuid.New().String(),
+		Name:           "labels-agent",
+		Status:         model.StatusOnline,
+		ReportedLabels: model.Labels{"env": "dev"},
+	}
+	agent.RefreshLabels()
+	require.NoError(t, store.UpsertAgent(ctx, agent))
+
+	configName := "test-labels-" + uuid.New().String()[:8]
+	require.NoError(t, store.CreateConfiguration(ctx, &model.Configuration{
+		Name:      configName,
+		RawConfig: "receivers: {}",
+		Selector:  map[string]string{"tier": "gold"},
+	}))
+	t.Cleanup(func() { _ = store.DeleteConfiguration(ctx, configName) })
+
+	router := setupTestRouter()
+	router.PUT("/agents/:id/labels", setAgentLabelsHandler(store, server))
+	router.PATCH("/agents/:id/labels", patchAgentLabelsHandler(store, server))
+
+	// 设置服务端标签后匹配到新配置并立即推送
+	body, _ := json.Marshal(model.AgentLabelsRequest{Labels: model.Labels{"tier": "gold", "env": "prod"}})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/agents/"+agent.ID+"/labels", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var change agentChange
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &change))
+	assert.Equal(t, "prod", change.Agent.Labels["env"])
+	assert.Equal(t, "dev", change.Agent.ReportedLabels["env"])
+	assert.Equal(t, configName, change.ConfigurationPushed)
+	assert.Equal(t, configName, server.sent[agent.ID])
+
+	// 删除服务端标签后恢复上报的值
+	body, _ = json.Marshal(model.AgentLabelsPatch{Remove: []string{"env"}})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPatch, "/agents/"+agent.ID+"/labels", bytes.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	change = agentChange{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &change))
+	assert.Equal(t, "dev", change.Agent.Labels["env"])
+	assert.Empty(t, change.ConfigurationPushed)
+
+	// 非法标签
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/agents/"+agent.ID+"/labels", bytes.NewReader([]byte(`{"labels":{"a=b":"c"}}`))))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Agent 不存在
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/agents/missing/labels", bytes.NewReader([]byte(`{"labels":{}}`))))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
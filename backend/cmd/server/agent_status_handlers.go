@@ -4,8 +4,9 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/cc1024201/opamp-platform/internal/auth"
 	"github.com/cc1024201/opamp-platform/internal/model"
-	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+	"github.com/cc1024201/opamp-platform/internal/store"
 	"github.com/gin-gonic/gin"
 )
 
@@ -23,7 +24,7 @@ import (
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /agents/{id}/connection-history [get]
-func getAgentConnectionHistoryHandler(store *postgres.Store) gin.HandlerFunc {
+func getAgentConnectionHistoryHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		agentID := c.Param("id")
 		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -57,7 +58,7 @@ func getAgentConnectionHistoryHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      404 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /agents/{id}/active-connection [get]
-func getAgentActiveConnectionHandler(store *postgres.Store) gin.HandlerFunc {
+func getAgentActiveConnectionHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		agentID := c.Param("id")
 
@@ -87,7 +88,7 @@ func getAgentActiveConnectionHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /agents/online [get]
-func listOnlineAgentsHandler(store *postgres.Store) gin.HandlerFunc {
+func listOnlineAgentsHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		agents, err := store.ListOnlineAgents(c.Request.Context())
 		if err != nil {
@@ -95,6 +96,17 @@ func listOnlineAgentsHandler(store *postgres.Store) gin.HandlerFunc {
 			return
 		}
 
+		// 限定范围的角色只能看到范围内的 Agent
+		if auth.ScopeSelector(c) != nil {
+			scoped := make([]*model.Agent, 0, len(agents))
+			for _, agent := range agents {
+				if auth.InScope(c, agent.Labels) {
+					scoped = append(scoped, agent)
+				}
+			}
+			agents = scoped
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"agents": agents,
 			"total":  len(agents),
@@ -115,11 +127,29 @@ func listOnlineAgentsHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /agents/offline [get]
-func listOfflineAgentsHandler(store *postgres.Store) gin.HandlerFunc {
+func listOfflineAgentsHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
+		// 限定范围的角色只能看到范围内的 Agent
+		if auth.ScopeSelector(c) != nil {
+			agents, err := listScopedAgents(c, store, func(agent *model.Agent) bool {
+				return agent.Status == model.StatusOffline
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"agents": paginateAgents(agents, limit, offset),
+				"total":  len(agents),
+				"limit":  limit,
+				"offset": offset,
+			})
+			return
+		}
+
 		agents, total, err := store.ListOfflineAgents(c.Request.Context(), limit, offset)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -146,41 +176,28 @@ func listOfflineAgentsHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /agents/status/summary [get]
-func getAgentStatusSummaryHandler(store *postgres.Store) gin.HandlerFunc {
+func getAgentStatusSummaryHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx := c.Request.Context()
-
-		// 获取在线 Agent
-		onlineAgents, err := store.ListOnlineAgents(ctx)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		// 获取离线 Agent 总数
-		_, offlineTotal, err := store.ListOfflineAgents(ctx, 0, 0)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		// 获取所有 Agent 总数
-		agents, total, err := store.ListAgents(ctx, 0, 0)
+		// 限定范围的角色只统计范围内的 Agent
+		selector, _ := auth.MergeScope(c, nil)
+		buckets, err := store.CountAgentsBy(c.Request.Context(), model.AgentFilter{Selector: selector}, model.FleetDimensionStatus)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
 		// 按状态分组统计
-		statusCounts := make(map[model.AgentStatus]int)
-		for _, agent := range agents {
-			statusCounts[agent.Status]++
+		var total int64
+		statusCounts := make(map[model.AgentStatus]int64)
+		for _, bucket := range buckets {
+			statusCounts[model.AgentStatus(bucket.Value)] = bucket.Count
+			total += bucket.Count
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"total":         total,
-			"online":        len(onlineAgents),
-			"offline":       offlineTotal,
+			"online":        statusCounts[model.StatusOnline],
+			"offline":       statusCounts[model.StatusOffline],
 			"status_counts": statusCounts,
 		})
 	}
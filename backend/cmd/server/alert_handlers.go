@@ -0,0 +1,411 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// listAlertsHandler 列出告警
+// @Summary      列出告警
+// @Description  获取告警列表, 可按状态过滤
+// @Tags         alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        state query string false "告警状态 (pending/firing/resolved)"
+// @Param        limit query int false "每页数量" default(50)
+// @Param        offset query int false "偏移量" default(0)
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /alerts [get]
+func listAlertsHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state := model.AlertState(c.Query("state"))
+		switch state {
+		case "", model.AlertStatePending, model.AlertStateFiring, model.AlertStateResolved:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid alert state"})
+			return
+		}
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+		alerts, total, err := store.ListAlerts(c.Request.Context(), state, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"alerts": alerts,
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		})
+	}
+}
+
+// listAlertRulesHandler 列出告警规则
+// @Summary      列出告警规则
+// @Description  获取所有告警规则, 限定范围的角色只能看到选择器在范围内的规则
+// @Tags         alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /alerts/rules [get]
+func listAlertRulesHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		all, err := store.ListAlertRules(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		rules := make([]*model.AlertRule, 0, len(all))
+		for _, rule := range all {
+			if auth.SelectorInScope(c, rule.Selector) {
+				rules = append(rules, rule)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"rules": rules,
+			"total": len(rules),
+		})
+	}
+}
+
+// getAlertRuleHandler 获取单个告警规则
+// @Summary      获取告警规则详情
+// @Description  根据 ID 获取告警规则
+// @Tags         alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "规则 ID"
+// @Success      200 {object} model.AlertRule
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /alerts/rules/{id} [get]
+func getAlertRuleHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := loadAlertRule(c, store)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+// createAlertRuleHandler 创建告警规则
+// @Summary      创建告警规则
+// @Description  创建告警规则, 支持 offline_ratio、flapping 和 config_failed 三种类型
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        rule body model.AlertRuleRequest true "规则信息"
+// @Success      201 {object} model.AlertRule
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /alerts/rules [post]
+func createAlertRuleHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.AlertRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 限定范围的角色只能评估范围内的 Agent
+		selector, ok := auth.MergeScope(c, req.Selector)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "selector is outside of the role scope"})
+			return
+		}
+		req.Selector = selector
+
+		rule := &model.AlertRule{Enabled: true}
+		applyAlertRuleRequest(rule, &req)
+		if err := rule.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := store.CreateAlertRule(c.Request.Context(), rule); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetTarget(c, "alerts.rules", strconv.FormatUint(uint64(rule.ID), 10))
+		audit.SetChange(c, nil, rule)
+
+		c.JSON(http.StatusCreated, rule)
+	}
+}
+
+// updateAlertRuleHandler 更新告警规则
+// @Summary      更新告警规则
+// @Description  更新告警规则, 下一次评估时生效
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "规则 ID"
+// @Param        rule body model.AlertRuleRequest true "规则信息"
+// @Success      200 {object} model.AlertRule
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /alerts/rules/{id} [put]
+func updateAlertRuleHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule, ok := loadAlertRule(c, store)
+		if !ok {
+			return
+		}
+
+		var req model.AlertRuleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 限定范围的角色只能评估范围内的 Agent
+		selector, ok := auth.MergeScope(c, req.Selector)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "selector is outside of the role scope"})
+			return
+		}
+		req.Selector = selector
+
+		before := *rule
+		applyAlertRuleRequest(rule, &req)
+		if err := rule.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := store.UpdateAlertRule(c.Request.Context(), rule); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetChange(c, &before, rule)
+
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+// deleteAlertRuleHandler 删除告警规则
+// @Summary      删除告警规则
+// @Description  删除告警规则及其告警和静默
+// @Tags         alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "规则 ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /alerts/rules/{id} [delete]
+func deleteAlertRuleHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+			return
+		}
+
+		existing, err := store.GetAlertRule(c.Request.Context(), uint(id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil && !auth.SelectorInScope(c, existing.Selector) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+			return
+		}
+
+		if err := store.DeleteAlertRule(c.Request.Context(), uint(id)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil {
+			audit.SetChange(c, existing, nil)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "alert rule deleted"})
+	}
+}
+
+// listSilencesHandler 列出静默
+// @Summary      列出静默
+// @Description  获取告警静默列表, 默认只返回未过期的静默
+// @Tags         alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        include_expired query bool false "是否包含已过期的静默"
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /alerts/silences [get]
+func listSilencesHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		includeExpired := c.Query("include_expired") == "true"
+
+		silences, err := store.ListSilences(c.Request.Context(), includeExpired)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"silences": silences,
+			"total":    len(silences),
+		})
+	}
+}
+
+// createSilenceHandler 创建静默
+// @Summary      创建静默
+// @Description  创建告警静默, 生效期间匹配的告警不发送通知
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        silence body model.SilenceRequest true "静默信息"
+// @Success      201 {object} model.Silence
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /alerts/silences [post]
+func createSilenceHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.SilenceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		startsAt := time.Now()
+		if req.StartsAt != nil {
+			startsAt = *req.StartsAt
+		}
+		if !req.EndsAt.After(startsAt) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ends_at must be after starts_at"})
+			return
+		}
+		if req.RuleID == nil && len(req.Matchers) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "rule_id or matchers is required"})
+			return
+		}
+
+		silence := &model.Silence{
+			RuleID:   req.RuleID,
+			Matchers: req.Matchers,
+			Comment:  req.Comment,
+			StartsAt: startsAt,
+			EndsAt:   req.EndsAt,
+		}
+		if claims, exists := auth.GetCurrentUser(c); exists {
+			silence.CreatedBy = claims.Username
+		}
+
+		if err := store.CreateSilence(c.Request.Context(), silence); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetTarget(c, "alerts.silences", strconv.FormatUint(uint64(silence.ID), 10))
+		audit.SetChange(c, nil, silence)
+
+		c.JSON(http.StatusCreated, silence)
+	}
+}
+
+// deleteSilenceHandler 删除静默
+// @Summary      删除静默
+// @Description  删除告警静默
+// @Tags         alerts
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "静默 ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /alerts/silences/{id} [delete]
+func deleteSilenceHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid silence id"})
+			return
+		}
+
+		if err := store.DeleteSilence(c.Request.Context(), uint(id)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "silence deleted"})
+	}
+}
+
+// loadAlertRule 根据路径参数加载告警规则, 失败时写入错误响应
+//
+// 选择器不在当前角色范围内的规则按不存在处理。
+func loadAlertRule(c *gin.Context, store store.Store) (*model.AlertRule, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule id"})
+		return nil, false
+	}
+
+	rule, err := store.GetAlertRule(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if rule == nil || !auth.SelectorInScope(c, rule.Selector) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "alert rule not found"})
+		return nil, false
+	}
+
+	return rule, true
+}
+
+// applyAlertRuleRequest 将请求内容写入规则
+func applyAlertRuleRequest(rule *model.AlertRule, req *model.AlertRuleRequest) {
+	rule.Name = req.Name
+	rule.Description = req.Description
+	rule.Type = req.Type
+	rule.Selector = req.Selector
+	rule.AgentID = req.AgentID
+	rule.ConfigurationName = req.ConfigurationName
+	rule.Threshold = req.Threshold
+	rule.WindowSeconds = req.WindowSeconds
+	rule.ForSeconds = req.ForSeconds
+	rule.Severity = req.Severity
+	if rule.Severity == "" {
+		rule.Severity = model.SeverityWarning
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+}
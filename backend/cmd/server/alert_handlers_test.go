@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+func TestAlertRuleHandlers_RoleScope(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := t.Context()
+	suffix := time.Now().UnixNano()
+
+	prod := &model.AlertRule{Name: fmt.Sprintf("test-rule-prod-%d", suffix), Type: model.AlertRuleOfflineRatio, Threshold: 0.5, Severity: model.SeverityWarning, Selector: map[string]string{"env": "prod"}, Enabled: true}
+	require.NoError(t, store.CreateAlertRule(ctx, prod))
+
+	// 范围限定为 env=dev 的角色
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.RolePayloadKey, &model.Role{Name: "dev-operator", Permissions: []string{"alerts:*"}, Selector: map[string]string{"env": "dev"}})
+	})
+	router.GET("/alerts/rules", listAlertRulesHandler(store))
+	router.GET("/alerts/rules/:id", getAlertRuleHandler(store))
+	router.POST("/alerts/rules", createAlertRuleHandler(store))
+	router.PUT("/alerts/rules/:id", updateAlertRuleHandler(store))
+	router.DELETE("/alerts/rules/:id", deleteAlertRuleHandler(store))
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	prodPath := fmt.Sprintf("/alerts/rules/%d", prod.ID)
+	request := func(selector map[string]string) model.AlertRuleRequest {
+		return model.AlertRuleRequest{Name: fmt.Sprintf("test-rule-dev-%d", suffix), Type: model.AlertRuleOfflineRatio, Threshold: 0.5, Selector: selector}
+	}
+
+	// 没有选择器时合并角色范围, 只评估范围内的 Agent
+	w := send(http.MethodPost, "/alerts/rules", request(nil))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created model.AlertRule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, map[string]string{"env": "dev"}, created.Selector)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+	}{
+		{"创建范围外的规则", http.MethodPost, "/alerts/rules", request(map[string]string{"env": "prod"}), http.StatusForbidden},
+		{"把规则改到范围外", http.MethodPut, fmt.Sprintf("/alerts/rules/%d", created.ID), request(map[string]string{"env": "prod"}), http.StatusForbidden},
+		{"查看范围外的规则", http.MethodGet, prodPath, nil, http.StatusNotFound},
+		{"修改范围外的规则", http.MethodPut, prodPath, request(map[string]string{"env": "dev"}), http.StatusNotFound},
+		{"删除范围外的规则", http.MethodDelete, prodPath, nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+
+	w = send(http.MethodGet, "/alerts/rules", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Rules []model.AlertRule `json:"rules"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	for _, rule := range list.Rules {
+		assert.Equal(t, "dev", rule.Selector["env"])
+	}
+
+	stored, err := store.GetAlertRule(ctx, prod.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored)
+
+	// 清理
+	execTestSQL(store, "DELETE FROM alert_rules WHERE name LIKE ?", fmt.Sprintf("test-rule-%%-%d", suffix))
+}
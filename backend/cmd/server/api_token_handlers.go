@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// listMyAPITokensHandler 列出当前用户的 API 令牌
+// @Summary      列出我的 API 令牌
+// @Description  获取当前用户的所有 API 令牌 (包括已撤销和已过期的), 不包含令牌明文
+// @Tags         tokens
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /tokens [get]
+func listMyAPITokensHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := auth.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			return
+		}
+		listAPITokens(c, store, claims.UserID)
+	}
+}
+
+// createMyAPITokenHandler 为当前用户创建 API 令牌
+// @Summary      创建我的 API 令牌
+// @Description  创建长期有效的 API 令牌, 令牌明文只在响应中返回一次。使用 API 令牌认证的请求不能再创建令牌
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        token body model.APITokenRequest true "令牌信息"
+// @Success      201 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /tokens [post]
+func createMyAPITokenHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := auth.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			return
+		}
+		if claims.APITokenID != 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API tokens cannot be used to create API tokens"})
+			return
+		}
+		issueAPIToken(c, store, claims.UserID)
+	}
+}
+
+// revokeMyAPITokenHandler 撤销当前用户的 API 令牌
+// @Summary      撤销我的 API 令牌
+// @Description  撤销后令牌立即失效
+// @Tags         tokens
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "令牌 ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /tokens/{id} [delete]
+func revokeMyAPITokenHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := auth.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			return
+		}
+		revokeAPIToken(c, store, claims.UserID, c.Param("id"))
+	}
+}
+
+// listUserAPITokensHandler 列出指定用户的 API 令牌
+// @Summary      列出用户的 API 令牌
+// @Description  管理员查看指定用户或服务账号的 API 令牌
+// @Tags         tokens
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id}/tokens [get]
+func listUserAPITokensHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadUser(c, store)
+		if !ok {
+			return
+		}
+		listAPITokens(c, store, user.ID)
+	}
+}
+
+// createUserAPITokenHandler 为指定用户创建 API 令牌
+// @Summary      为用户创建 API 令牌
+// @Description  管理员为指定用户或服务账号创建 API 令牌, 令牌明文只在响应中返回一次
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Param        token body model.APITokenRequest true "令牌信息"
+// @Success      201 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id}/tokens [post]
+func createUserAPITokenHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadManagedUser(c, store)
+		if !ok {
+			return
+		}
+		issueAPIToken(c, store, user.ID)
+	}
+}
+
+// revokeUserAPITokenHandler 撤销指定用户的 API 令牌
+// @Summary      撤销用户的 API 令牌
+// @Description  管理员撤销指定用户或服务账号的 API 令牌
+// @Tags         tokens
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Param        token_id path int true "令牌 ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id}/tokens/{token_id} [delete]
+func revokeUserAPITokenHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadManagedUser(c, store)
+		if !ok {
+			return
+		}
+		revokeAPIToken(c, store, user.ID, c.Param("token_id"))
+	}
+}
+
+// createServiceAccountHandler 创建服务账号
+// @Summary      创建服务账号
+// @Description  创建只能通过 API 令牌认证的服务账号, 用于 CI 等自动化场景; 创建后通过 /users/{id}/tokens 签发令牌
+// @Tags         tokens
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        account body model.CreateServiceAccountRequest true "服务账号信息"
+// @Success      201 {object} model.User
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /service-accounts [post]
+func createServiceAccountHandler(store store.Store, authorizer *auth.Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CreateServiceAccountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		roleName, ok := resolveRoleName(c, authorizer, req.Role)
+		if !ok {
+			return
+		}
+
+		// 服务账号没有真实邮箱, 也不能密码登录, 使用随机密码占位
+		email := fmt.Sprintf("%s@service-account.local", req.Name)
+		if !ensureUserAvailable(c, store, req.Name, email) {
+			return
+		}
+		password, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate password"})
+			return
+		}
+
+		user := &model.User{
+			Username:         req.Name,
+			Email:            email,
+			Password:         password,
+			Role:             roleName,
+			IsActive:         true,
+			IsServiceAccount: true,
+		}
+		if err := store.CreateUser(c.Request.Context(), user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create service account"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, user)
+	}
+}
+
+// listAPITokens 输出用户的 API 令牌列表
+func listAPITokens(c *gin.Context, store store.Store, userID uint) {
+	tokens, err := store.ListAPITokens(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tokens": tokens,
+		"total":  len(tokens),
+	})
+}
+
+// issueAPIToken 根据请求为用户创建 API 令牌
+func issueAPIToken(c *gin.Context, store store.Store, userID uint) {
+	var req model.APITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one scope is required"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !auth.ValidatePermission(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown scope: " + scope})
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn != "" {
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_in"})
+			return
+		}
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	plaintext, prefix, err := auth.GenerateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	token := &model.APIToken{
+		Name:      req.Name,
+		Prefix:    prefix,
+		TokenHash: auth.HashToken(plaintext),
+		UserID:    userID,
+		Scopes:    req.Scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := store.CreateAPIToken(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":     token,
+		"plaintext": plaintext,
+	})
+}
+
+// revokeAPIToken 撤销用户的 API 令牌
+func revokeAPIToken(c *gin.Context, store store.Store, userID uint, idParam string) {
+	id, err := strconv.ParseUint(idParam, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token id"})
+		return
+	}
+
+	token, err := store.GetAPIToken(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if token == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+
+	if err := store.RevokeAPIToken(c.Request.Context(), token.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "token revoked"})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// listAuditLogsHandler 查询审计日志
+// @Summary      查询审计日志
+// @Description  按操作者、操作、目标、请求 ID 和时间范围查询审计日志, 按时间倒序分页
+// @Tags         audit
+// @Produce      json
+// @Security     BearerAuth
+// @Param        actor_type  query string false "操作者类型 (user/api_token/system/anonymous)"
+// @Param        actor       query string false "操作者用户名"
+// @Param        action      query string false "操作名称, 以 .* 结尾时按前缀匹配 (如 users.*)"
+// @Param        target_type query string false "目标类型"
+// @Param        target_id   query string false "目标 ID"
+// @Param        request_id  query string false "请求 ID"
+// @Param        from        query string false "开始时间 (RFC3339)"
+// @Param        to          query string false "结束时间 (RFC3339)"
+// @Param        limit       query int    false "每页数量" default(50)
+// @Param        offset      query int    false "偏移量" default(0)
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /audit [get]
+func listAuditLogsHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseAuditFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+		logs, total, err := store.ListAuditLogs(c.Request.Context(), filter, limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"entries": logs,
+			"total":   total,
+			"limit":   limit,
+			"offset":  offset,
+		})
+	}
+}
+
+// exportAuditLogsHandler 导出审计日志
+// @Summary      导出审计日志
+// @Description  以 JSON Lines 格式导出符合条件的全部审计日志, 按时间正序, 过滤参数与查询接口相同
+// @Tags         audit
+// @Produce      application/x-ndjson
+// @Security     BearerAuth
+// @Param        actor_type  query string false "操作者类型 (user/api_token/system/anonymous)"
+// @Param        actor       query string false "操作者用户名"
+// @Param        action      query string false "操作名称, 以 .* 结尾时按前缀匹配 (如 users.*)"
+// @Param        target_type query string false "目标类型"
+// @Param        target_id   query string false "目标 ID"
+// @Param        request_id  query string false "请求 ID"
+// @Param        from        query string false "开始时间 (RFC3339)"
+// @Param        to          query string false "结束时间 (RFC3339)"
+// @Success      200 {string} string "每行一条审计日志"
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Router       /audit/export [get]
+func exportAuditLogsHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseAuditFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		c.Status(http.StatusOK)
+
+		// 响应头已经发出, 中途出错时只能截断输出
+		encoder := json.NewEncoder(c.Writer)
+		_ = store.ExportAuditLogs(c.Request.Context(), filter, func(entry *model.AuditLog) error {
+			return encoder.Encode(entry)
+		})
+		c.Writer.Flush()
+	}
+}
+
+// parseAuditFilter 解析审计日志的过滤参数
+func parseAuditFilter(c *gin.Context) (model.AuditFilter, error) {
+	filter := model.AuditFilter{
+		ActorType:  c.Query("actor_type"),
+		Actor:      c.Query("actor"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		RequestID:  c.Query("request_id"),
+	}
+
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from: %w", err)
+		}
+		filter.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to: %w", err)
+		}
+		filter.To = &t
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return filter, fmt.Errorf("from must be before to")
+	}
+
+	return filter, nil
+}
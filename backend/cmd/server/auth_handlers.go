@@ -1,17 +1,23 @@
 package main
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/cc1024201/opamp-platform/internal/audit"
 	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/events"
 	"github.com/cc1024201/opamp-platform/internal/model"
-	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+	"github.com/cc1024201/opamp-platform/internal/store"
 )
 
 // loginHandler 登录处理器
 // @Summary      用户登录
-// @Description  使用用户名和密码登录系统，返回 JWT token
+// @Description  使用用户名和密码登录系统，返回短期有效的 JWT 访问令牌和用于续期的刷新令牌; 需要 MFA 时返回 MFA 令牌, 通过 /auth/mfa/verify 完成登录; 密码已过期时返回修改密码令牌, 通过 /auth/password/renew 设置新密码。连续失败会触发渐进等待和临时锁定
 // @Tags         auth
 // @Accept       json
 // @Produce      json
@@ -19,15 +25,23 @@ import (
 // @Success      200 {object} model.LoginResponse
 // @Failure      400 {object} map[string]string
 // @Failure      401 {object} map[string]string
+// @Failure      429 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /auth/login [post]
-func loginHandler(store *postgres.Store, jwtManager *auth.JWTManager) gin.HandlerFunc {
+func loginHandler(store store.Store, jwtManager *auth.JWTManager, guard *auth.LoginGuard, policy *auth.PasswordPolicy, bus *events.Bus) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req model.LoginRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		// 登录请求没有认证信息, 审计日志记录尝试登录的用户名
+		audit.SetActor(c, 0, req.Username)
+
+		// 检查账号和 IP 是否处于等待或锁定状态
+		if !checkLoginAllowed(c, guard, req.Username) {
+			return
+		}
 
 		// 查找用户
 		user, err := store.GetUserByUsername(c.Request.Context(), req.Username)
@@ -37,9 +51,11 @@ func loginHandler(store *postgres.Store, jwtManager *auth.JWTManager) gin.Handle
 		}
 
 		if user == nil {
+			recordLoginFailure(c, guard, bus, req.Username)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
 			return
 		}
+		audit.SetActor(c, user.ID, user.Username)
 
 		// 检查用户是否激活
 		if !user.IsActive {
@@ -49,64 +65,66 @@ func loginHandler(store *postgres.Store, jwtManager *auth.JWTManager) gin.Handle
 
 		// 验证密码
 		if !user.CheckPassword(req.Password) {
+			recordLoginFailure(c, guard, bus, req.Username)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
 			return
 		}
+		guard.RecordSuccess(req.Username)
 
-		// 生成 JWT token
-		token, err := jwtManager.GenerateToken(user.ID, user.Username, user.Role)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		// 服务账号只能使用 API 令牌
+		if user.IsServiceAccount {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": auth.ErrServiceAccountLogin.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, model.LoginResponse{
-			Token: token,
-			User:  user,
-		})
+		// 外部身份提供方的用户只能使用单点登录
+		if user.IsExternal() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user must sign in with single sign-on"})
+			return
+		}
+
+		// 需要 MFA 时返回挑战令牌, 否则签发访问令牌和刷新令牌
+		completeLogin(c, store, jwtManager, policy, user, http.StatusOK)
 	}
 }
 
 // registerHandler 注册处理器
 // @Summary      用户注册
-// @Description  注册新用户账号，返回 JWT token
+// @Description  注册新用户账号，返回 JWT token。仅在注册模式为 open 或系统中还没有用户时可用, 第一个用户成为管理员
 // @Tags         auth
 // @Accept       json
 // @Produce      json
 // @Param        request body model.RegisterRequest true "注册信息"
 // @Success      201 {object} model.LoginResponse
 // @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
 // @Failure      409 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /auth/register [post]
-func registerHandler(store *postgres.Store, jwtManager *auth.JWTManager) gin.HandlerFunc {
+func registerHandler(store store.Store, jwtManager *auth.JWTManager, policy *auth.PasswordPolicy) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req model.RegisterRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		// 检查用户名是否已存在
-		existingUser, err := store.GetUserByUsername(c.Request.Context(), req.Username)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		if !validatePassword(c, policy, req.Password, req.Username) {
 			return
 		}
-		if existingUser != nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
+
+		if !ensureUserAvailable(c, store, req.Username, req.Email) {
 			return
 		}
 
-		// 检查邮箱是否已存在
-		existingEmail, err := store.GetUserByEmail(c.Request.Context(), req.Email)
+		// 第一个注册的用户成为管理员
+		userCount, err := store.CountUsers(c.Request.Context())
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
 			return
 		}
-		if existingEmail != nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
-			return
+		role := auth.DefaultRole
+		if userCount == 0 {
+			role = auth.RoleAdmin
 		}
 
 		// 创建新用户
@@ -114,7 +132,7 @@ func registerHandler(store *postgres.Store, jwtManager *auth.JWTManager) gin.Han
 			Username: req.Username,
 			Email:    req.Email,
 			Password: req.Password, // BeforeCreate hook 会自动哈希密码
-			Role:     "user",       // 默认角色
+			Role:     role,
 			IsActive: true,
 		}
 
@@ -123,17 +141,36 @@ func registerHandler(store *postgres.Store, jwtManager *auth.JWTManager) gin.Han
 			return
 		}
 
-		// 生成 JWT token
-		token, err := jwtManager.GenerateToken(user.ID, user.Username, user.Role)
+		// 角色要求 MFA 时先绑定 MFA, 否则签发访问令牌和刷新令牌
+		completeLogin(c, store, jwtManager, policy, user, http.StatusCreated)
+	}
+}
+
+// registrationGate 根据注册模式限制自助注册
+//
+// 系统中还没有任何用户时总是允许注册, 以便创建第一个管理员。
+func registrationGate(store store.Store, mode auth.RegistrationMode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mode == auth.RegistrationOpen {
+			c.Next()
+			return
+		}
+
+		userCount, err := store.CountUsers(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if userCount == 0 {
+			c.Next()
 			return
 		}
 
-		c.JSON(http.StatusCreated, model.LoginResponse{
-			Token: token,
-			User:  user,
-		})
+		message := "self-registration is disabled"
+		if mode == auth.RegistrationInvite {
+			message = "registration requires an invitation"
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": message})
 	}
 }
 
@@ -149,7 +186,7 @@ func registerHandler(store *postgres.Store, jwtManager *auth.JWTManager) gin.Han
 // @Failure      404 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /me [get]
-func meHandler(store *postgres.Store) gin.HandlerFunc {
+func meHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claims, exists := auth.GetCurrentUser(c)
 		if !exists {
@@ -172,3 +209,383 @@ func meHandler(store *postgres.Store) gin.HandlerFunc {
 		c.JSON(http.StatusOK, user)
 	}
 }
+
+// refreshTokenHandler 刷新访问令牌
+// @Summary      刷新访问令牌
+// @Description  使用刷新令牌换取新的访问令牌和刷新令牌, 旧的刷新令牌随即失效。已失效的刷新令牌被再次使用时, 该会话的所有刷新令牌都会被撤销
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body model.RefreshRequest true "刷新令牌"
+// @Success      200 {object} model.LoginResponse
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /auth/refresh [post]
+func refreshTokenHandler(store store.Store, jwtManager *auth.JWTManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.RefreshRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx := c.Request.Context()
+		refreshToken, err := store.GetRefreshTokenByHash(ctx, auth.HashToken(req.RefreshToken))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if refreshToken == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+			return
+		}
+
+		// 已轮换的令牌被再次使用, 说明令牌可能已泄露, 撤销整个会话
+		if refreshToken.RevokedAt != nil {
+			_ = store.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has been revoked"})
+			return
+		}
+		if !refreshToken.IsUsable(time.Now()) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has expired"})
+			return
+		}
+
+		user, err := store.GetUserByID(ctx, refreshToken.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if user == nil || !user.IsActive || user.TokenVersion != refreshToken.TokenVersion {
+			_ = store.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has been revoked"})
+			return
+		}
+
+		// 角色要求 MFA 但用户尚未绑定时, 不能继续刷新, 需要重新登录并绑定
+		if !user.MFAEnabled {
+			required, err := store.IsMFARequiredForRole(ctx, user.Role)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			if required {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "mfa enrollment required, please sign in again"})
+				return
+			}
+		}
+
+		// 并发刷新时只有一个请求能使旧令牌失效
+		revoked, err := store.RevokeRefreshToken(ctx, refreshToken.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !revoked {
+			_ = store.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token has been revoked"})
+			return
+		}
+
+		response, ok := issueTokens(c, store, jwtManager, user, refreshToken.FamilyID)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// logoutHandler 退出登录
+// @Summary      退出登录
+// @Description  撤销当前访问令牌和请求中的刷新令牌所属的会话; all=true 时撤销该用户的所有会话
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body model.LogoutRequest false "退出选项"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /auth/logout [post]
+func logoutHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := auth.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			return
+		}
+		if claims.APITokenID != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API tokens must be revoked via /tokens"})
+			return
+		}
+
+		var req model.LogoutRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		ctx := c.Request.Context()
+		if req.All {
+			user, err := store.GetUserByID(ctx, claims.UserID)
+			if err != nil || user == nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			if !invalidateUserSessions(c, store, user) {
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "all sessions logged out"})
+			return
+		}
+
+		if claims.ID != "" && claims.ExpiresAt != nil {
+			if err := store.RevokeAccessToken(ctx, &model.RevokedAccessToken{
+				JTI:       claims.ID,
+				UserID:    claims.UserID,
+				ExpiresAt: claims.ExpiresAt.Time,
+			}); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+		}
+
+		if req.RefreshToken != "" {
+			refreshToken, err := store.GetRefreshTokenByHash(ctx, auth.HashToken(req.RefreshToken))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+				return
+			}
+			if refreshToken != nil && refreshToken.UserID == claims.UserID {
+				if err := store.RevokeRefreshTokenFamily(ctx, refreshToken.FamilyID); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+					return
+				}
+			}
+		}
+
+		// 顺便清理已过期的令牌记录
+		_ = store.DeleteExpiredTokens(ctx, time.Now())
+
+		c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+	}
+}
+
+// changePasswordHandler 修改当前用户的密码
+// @Summary      修改密码
+// @Description  修改当前用户的密码, 新密码必须满足密码策略且不能与当前密码相同; 该用户已签发的所有令牌立即失效, 响应中返回新的令牌
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body model.ChangePasswordRequest true "密码"
+// @Success      200 {object} model.LoginResponse
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /me/password [post]
+func changePasswordHandler(store store.Store, jwtManager *auth.JWTManager, policy *auth.PasswordPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := auth.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			return
+		}
+		if claims.APITokenID != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API tokens cannot be used to change passwords"})
+			return
+		}
+
+		var req model.ChangePasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, err := store.GetUserByID(c.Request.Context(), claims.UserID)
+		if err != nil || user == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if user.IsExternal() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "password is managed by the identity provider"})
+			return
+		}
+		if !user.CheckPassword(req.CurrentPassword) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+			return
+		}
+		if !setNewPassword(c, store, policy, user, req.NewPassword) {
+			return
+		}
+
+		response, ok := issueTokens(c, store, jwtManager, user, "")
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// renewPasswordHandler 设置新密码替换已过期的密码
+// @Summary      更新过期密码
+// @Description  登录时密码已过期会返回修改密码令牌, 使用该令牌设置满足密码策略的新密码后完成登录
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body model.RenewPasswordRequest true "修改密码令牌和新密码"
+// @Success      200 {object} model.LoginResponse
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /auth/password/renew [post]
+func renewPasswordHandler(store store.Store, jwtManager *auth.JWTManager, policy *auth.PasswordPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.RenewPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := loadMFAChallengeUser(c, store, jwtManager, req.PasswordToken, auth.PurposePasswordChange)
+		if !ok {
+			return
+		}
+		if !setNewPassword(c, store, policy, user, req.NewPassword) {
+			return
+		}
+
+		response, ok := issueTokens(c, store, jwtManager, user, "")
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// checkLoginAllowed 检查账号和客户端 IP 是否允许尝试登录, 不允许时写入 429 响应和 Retry-After
+func checkLoginAllowed(c *gin.Context, guard *auth.LoginGuard, username string) bool {
+	wait, err := guard.Check(username, c.ClientIP())
+	if err == nil {
+		return true
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	return false
+}
+
+// recordLoginFailure 记录失败的登录尝试, 触发锁定时发布 auth.lockout 事件
+//
+// 锁定按用户名和 IP 计数, 与组织无关, 事件发布到默认组织由平台管理员处理。
+func recordLoginFailure(c *gin.Context, guard *auth.LoginGuard, bus *events.Bus, username string) {
+	for _, lockout := range guard.RecordFailure(username, c.ClientIP()) {
+		bus.Publish(events.Event{
+			Type:           events.TypeAuthLockout,
+			OrganizationID: model.DefaultOrganizationID,
+			Data: map[string]interface{}{
+				"kind":     lockout.Kind,
+				"key":      lockout.Key,
+				"failures": lockout.Failures,
+				"until":    lockout.Until,
+			},
+		})
+	}
+}
+
+// validatePassword 按密码策略校验密码, 不满足时写入 400 响应并列出不满足的规则
+func validatePassword(c *gin.Context, policy *auth.PasswordPolicy, password, username string) bool {
+	err := policy.Validate(password, username)
+	if err == nil {
+		return true
+	}
+
+	var policyErr *auth.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password does not meet the policy", "violations": policyErr.Violations})
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	return false
+}
+
+// setNewPassword 校验并设置用户的新密码, 新密码不能与当前密码相同; 已签发的令牌随之失效
+func setNewPassword(c *gin.Context, store store.Store, policy *auth.PasswordPolicy, user *model.User, password string) bool {
+	if !validatePassword(c, policy, password, user.Username) {
+		return false
+	}
+	if user.CheckPassword(password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "new password must be different from the current password"})
+		return false
+	}
+
+	if err := user.SetPassword(password); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set password"})
+		return false
+	}
+	return invalidateUserSessions(c, store, user)
+}
+
+// issueTokens 为用户签发访问令牌和刷新令牌, familyID 为空时开始新的会话
+func issueTokens(c *gin.Context, store store.Store, jwtManager *auth.JWTManager, user *model.User, familyID string) (*model.LoginResponse, bool) {
+	// 登录类请求未经过认证中间件, 审计日志需记入用户所属的组织
+	auth.BindOrganization(c, user.OrganizationID)
+
+	accessToken, err := jwtManager.GenerateAccessToken(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return nil, false
+	}
+
+	plaintext, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return nil, false
+	}
+	if familyID == "" {
+		family, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+			return nil, false
+		}
+		familyID = family[:32]
+	}
+
+	refreshToken := &model.RefreshToken{
+		TokenHash:    auth.HashToken(plaintext),
+		FamilyID:     familyID,
+		UserID:       user.ID,
+		TokenVersion: user.TokenVersion,
+		ExpiresAt:    time.Now().Add(jwtManager.RefreshTokenDuration()),
+	}
+	if err := store.CreateRefreshToken(c.Request.Context(), refreshToken); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return nil, false
+	}
+
+	return &model.LoginResponse{
+		Token:        accessToken,
+		RefreshToken: plaintext,
+		ExpiresIn:    int64(jwtManager.TokenDuration() / time.Second),
+		User:         user,
+	}, true
+}
+
+// invalidateUserSessions 递增用户的令牌版本并撤销其刷新令牌, 同时保存对用户的其他修改
+func invalidateUserSessions(c *gin.Context, store store.Store, user *model.User) bool {
+	user.TokenVersion++
+	if err := store.UpdateUser(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := store.RevokeUserRefreshTokens(c.Request.Context(), user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -12,10 +14,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 
 	"github.com/cc1024201/opamp-platform/internal/auth"
 	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/store/memory"
 	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+	"github.com/cc1024201/opamp-platform/internal/store/sqlite"
 )
 
 func setupTestRouter() *gin.Engine {
@@ -24,18 +30,42 @@ func setupTestRouter() *gin.Engine {
 	return router
 }
 
-func setupTestStore(t *testing.T) *postgres.Store {
+// testStore 测试使用的存储
+type testStore = store.Store
+
+// execTestSQL 在基于 SQL 数据库的存储上执行清理语句, 内存存储每个测试都是新的, 不需要清理
+func execTestSQL(s testStore, query string, args ...interface{}) {
+	if db, ok := s.(interface{ GetDB() *gorm.DB }); ok {
+		db.GetDB().Exec(query, args...)
+	}
+}
+
+// setupTestStore 创建测试存储
+//
+// TEST_DB_DRIVER=sqlite 时使用临时的 SQLite 数据库, TEST_DB_DRIVER=memory 时使用内存存储, 都不需要 PostgreSQL。
+func setupTestStore(t *testing.T) testStore {
 	// 创建测试用的 logger
 	logger, err := zap.NewDevelopment()
 	require.NoError(t, err)
 
+	switch os.Getenv("TEST_DB_DRIVER") {
+	case "memory":
+		return memory.NewStore()
+	case "sqlite":
+		store, err := sqlite.NewStore(sqlite.Config{Path: filepath.Join(t.TempDir(), "test.db")}, logger)
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		return store
+	}
+
 	config := postgres.Config{
-		Host:     getEnv("TEST_DB_HOST", "localhost"),
-		Port:     getEnvInt("TEST_DB_PORT", 5432),
-		User:     getEnv("TEST_DB_USER", "opamp"),
-		Password: getEnv("TEST_DB_PASSWORD", "opamp123"),
-		DBName:   getEnv("TEST_DB_NAME", "opamp_platform"),
-		SSLMode:  "disable",
+		Host:           getEnv("TEST_DB_HOST", "localhost"),
+		Port:           getEnvInt("TEST_DB_PORT", 5432),
+		User:           getEnv("TEST_DB_USER", "opamp"),
+		Password:       getEnv("TEST_DB_PASSWORD", "opamp123"),
+		DBName:         getEnv("TEST_DB_NAME", "opamp_platform"),
+		SSLMode:        "disable",
+		MigrateOnStart: true,
 	}
 
 	store, err := postgres.NewStore(config, logger)
@@ -50,9 +80,8 @@ func setupTestStore(t *testing.T) *postgres.Store {
 	return store
 }
 
-func cleanupTestData(store *postgres.Store) {
-	db := store.GetDB()
-	db.Exec("DELETE FROM users WHERE username LIKE 'test%'")
+func cleanupTestData(store testStore) {
+	execTestSQL(store, "DELETE FROM users WHERE username LIKE 'test%'")
 }
 
 func getEnv(key, defaultValue string) string {
@@ -147,7 +176,7 @@ func TestLoginHandler(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			router := setupTestRouter()
-			router.POST("/login", loginHandler(store, jwtManager))
+			router.POST("/login", loginHandler(store, jwtManager, auth.NewLoginGuard(auth.LockoutConfig{}), auth.DefaultPasswordPolicy(), nil))
 
 			body, _ := json.Marshal(tt.requestBody)
 			req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
@@ -164,11 +193,44 @@ func TestLoginHandler(t *testing.T) {
 	}
 }
 
+func TestLoginHandler_IgnoresUntrustedForwardedFor(t *testing.T) {
+	store := setupTestStore(t)
+	jwtManager := auth.NewJWTManager("test-secret-key", 24*time.Hour)
+	guard := auth.NewLoginGuard(auth.LockoutConfig{MaxAttempts: 100, IPMaxAttempts: 2, BaseDelay: -1})
+
+	login := func(router *gin.Engine, username, forwardedFor string) int {
+		body, _ := json.Marshal(model.LoginRequest{Username: username, Password: "wrongpassword"})
+		req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = "192.0.2.10:12345"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 默认不信任任何代理, 伪造的 X-Forwarded-For 不能绕过 IP 锁定
+	router := setupTestRouter()
+	require.NoError(t, router.SetTrustedProxies(nil))
+	router.POST("/login", loginHandler(store, jwtManager, guard, auth.DefaultPasswordPolicy(), nil))
+
+	assert.Equal(t, http.StatusUnauthorized, login(router, "nobody-1", "203.0.113.1"))
+	assert.Equal(t, http.StatusUnauthorized, login(router, "nobody-2", "203.0.113.2"))
+	assert.Equal(t, http.StatusTooManyRequests, login(router, "nobody-3", "203.0.113.3"))
+
+	// 来自可信代理的请求按 X-Forwarded-For 中的客户端 IP 计数
+	trusted := setupTestRouter()
+	require.NoError(t, trusted.SetTrustedProxies([]string{"192.0.2.0/24"}))
+	trusted.POST("/login", loginHandler(store, jwtManager, guard, auth.DefaultPasswordPolicy(), nil))
+
+	assert.Equal(t, http.StatusUnauthorized, login(trusted, "nobody-4", "203.0.113.4"))
+}
+
 func TestRegisterHandler(t *testing.T) {
 	store := setupTestStore(t)
 	jwtManager := auth.NewJWTManager("test-secret-key", 24*time.Hour)
 	router := setupTestRouter()
-	router.POST("/register", registerHandler(store, jwtManager))
+	router.POST("/register", registerHandler(store, jwtManager, auth.DefaultPasswordPolicy()))
 
 	// 测试成功注册 - 必须先执行以创建基准用户
 	t.Run("成功注册", func(t *testing.T) {
@@ -262,7 +324,7 @@ func TestMeHandler(t *testing.T) {
 	require.NoError(t, err)
 
 	// 生成有效的 token
-	validToken, err := jwtManager.GenerateToken(testUser.ID, testUser.Username, testUser.Role)
+	validToken, err := jwtManager.GenerateAccessToken(testUser)
 	require.NoError(t, err)
 
 	tests := []struct {
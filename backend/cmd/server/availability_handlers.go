@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+const (
+	// defaultAvailabilityRange 未指定 from 时的默认统计范围
+	defaultAvailabilityRange = 7 * 24 * time.Hour
+	// maxAvailabilityRange 单次查询允许的最大统计范围
+	maxAvailabilityRange = 366 * 24 * time.Hour
+	// ungroupedLabel 分组报告中缺少分组标签的 Agent 所属的分组
+	ungroupedLabel = "(none)"
+	// availabilityBatchSize 群组报告每次查询连接历史的 Agent 数量
+	availabilityBatchSize = 500
+)
+
+// getAgentAvailabilityHandler 获取 Agent 可用性报告
+// @Summary      获取 Agent 可用性报告
+// @Description  根据连接历史计算指定时间范围内的在线率、故障次数、MTTR 和最长故障, 按天 (UTC) 分桶
+// @Tags         agents
+// @Produce      json
+// @Produce      text/csv
+// @Security     BearerAuth
+// @Param        id path string true "Agent ID"
+// @Param        from query string false "开始时间 (RFC3339), 默认 7 天前"
+// @Param        to query string false "结束时间 (RFC3339), 默认当前时间, 晚于当前时间时按当前时间计算"
+// @Param        format query string false "输出格式 (json/csv)" default(json)
+// @Success      200 {object} model.AvailabilityReport
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/{id}/availability [get]
+func getAgentAvailabilityHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		agentID := c.Param("id")
+		from, to, err := parseAvailabilityRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		agent, err := store.GetAgent(c.Request.Context(), agentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if agent == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+			return
+		}
+
+		histories, err := store.ListConnectionHistoryInRange(c.Request.Context(), []string{agentID}, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		report := computeAgentAvailability(agent, histories, from, to)
+
+		if wantsCSV(c) {
+			writeAvailabilityCSV(c, "availability-"+agentID+".csv", []*model.AvailabilityReport{report})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// getFleetAvailabilityHandler 获取按标签分组的可用性报告
+// @Summary      获取 Agent 群组可用性报告
+// @Description  计算所有 (或匹配选择器的) Agent 的可用性, 并按指定标签的取值分组汇总
+// @Tags         agents
+// @Produce      json
+// @Produce      text/csv
+// @Security     BearerAuth
+// @Param        from query string false "开始时间 (RFC3339), 默认 7 天前"
+// @Param        to query string false "结束时间 (RFC3339), 默认当前时间, 晚于当前时间时按当前时间计算"
+// @Param        group_by query string false "分组标签 key, 为空则汇总为一个分组"
+// @Param        selector query string false "标签选择器 (key1=value1,key2=value2)"
+// @Param        format query string false "输出格式 (json/csv)" default(json)
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/availability [get]
+func getFleetAvailabilityHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to, err := parseAvailabilityRange(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		groupBy := c.Query("group_by")
+		selector, err := model.ParseLabelSelector(c.Query("selector"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// 限定范围的角色只统计范围内的 Agent
+		selector, ok := auth.MergeScope(c, selector)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "selector is outside of the role scope"})
+			return
+		}
+
+		agents, err := store.ListAgentsBySelector(c.Request.Context(), selector)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 按标签取值分组, 分批加载连接历史, 每批计算完成后即可释放
+		grouped := make(map[string][]*model.AvailabilityReport)
+		for start := 0; start < len(agents); start += availabilityBatchSize {
+			batch := agents[start:min(start+availabilityBatchSize, len(agents))]
+			agentIDs := make([]string, len(batch))
+			for i, agent := range batch {
+				agentIDs[i] = agent.ID
+			}
+
+			histories, err := store.ListConnectionHistoryInRange(c.Request.Context(), agentIDs, from, to)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			byAgent := make(map[string][]*model.AgentConnectionHistory)
+			for _, h := range histories {
+				byAgent[h.AgentID] = append(byAgent[h.AgentID], h)
+			}
+
+			for _, agent := range batch {
+				group := "all"
+				if groupBy != "" {
+					group = agent.Labels[groupBy]
+					if group == "" {
+						group = ungroupedLabel
+					}
+				}
+				grouped[group] = append(grouped[group], computeAgentAvailability(agent, byAgent[agent.ID], from, to))
+			}
+		}
+
+		groups := make([]string, 0, len(grouped))
+		for group := range grouped {
+			groups = append(groups, group)
+		}
+		sort.Strings(groups)
+
+		reports := make([]*model.AvailabilityReport, 0, len(groups))
+		for _, group := range groups {
+			reports = append(reports, model.MergeAvailability(group, from, to, grouped[group]))
+		}
+
+		if wantsCSV(c) {
+			writeAvailabilityCSV(c, "availability.csv", reports)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"from":     from,
+			"to":       to,
+			"group_by": groupBy,
+			"groups":   reports,
+			"total":    len(reports),
+		})
+	}
+}
+
+// computeAgentAvailability 计算单个 Agent 的可用性, Agent 注册之前的时段不计入统计
+func computeAgentAvailability(agent *model.Agent, histories []*model.AgentConnectionHistory, from, to time.Time) *model.AvailabilityReport {
+	effectiveFrom := from
+	if agent.CreatedAt.After(effectiveFrom) {
+		effectiveFrom = agent.CreatedAt
+	}
+	if effectiveFrom.After(to) {
+		effectiveFrom = to
+	}
+
+	report := model.ComputeAvailability(agent.ID, histories, effectiveFrom, to, time.Now())
+	report.From = from
+	return report
+}
+
+// parseAvailabilityRange 解析 from/to 查询参数, to 不晚于当前时间
+func parseAvailabilityRange(c *gin.Context) (time.Time, time.Time, error) {
+	now := time.Now()
+	to := now
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to: %w", err)
+		}
+		// 尚未到来的时段没有连接记录, 计入统计会被当作故障时间
+		if t.Before(now) {
+			to = t
+		}
+	}
+
+	from := to.Add(-defaultAvailabilityRange)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from: %w", err)
+		}
+		from = t
+	}
+
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > maxAvailabilityRange {
+		return time.Time{}, time.Time{}, fmt.Errorf("time range must not exceed %d days", int(maxAvailabilityRange.Hours()/24))
+	}
+
+	return from, to, nil
+}
+
+// wantsCSV 检查客户端是否请求 CSV 格式
+func wantsCSV(c *gin.Context) bool {
+	if format := c.Query("format"); format != "" {
+		return strings.EqualFold(format, "csv")
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/csv")
+}
+
+// writeAvailabilityCSV 以 CSV 输出可用性报告, 每个报告输出按天的明细行和一行 total 汇总
+func writeAvailabilityCSV(c *gin.Context, filename string, reports []*model.AvailabilityReport) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+strconv.Quote(filename))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{
+		"group", "agent_id", "date", "agents", "observed_seconds", "uptime_seconds",
+		"uptime_percent", "outages", "mttr_seconds", "longest_outage_seconds",
+	})
+
+	for _, r := range reports {
+		for _, d := range r.Daily {
+			_ = w.Write([]string{
+				r.Group, r.AgentID, d.Date, strconv.Itoa(r.Agents),
+				strconv.FormatInt(d.ObservedSeconds, 10),
+				strconv.FormatInt(d.UptimeSeconds, 10),
+				strconv.FormatFloat(d.UptimePercent, 'f', 2, 64),
+				strconv.Itoa(d.Outages), "", "",
+			})
+		}
+		_ = w.Write([]string{
+			r.Group, r.AgentID, "total", strconv.Itoa(r.Agents),
+			strconv.FormatInt(r.ObservedSeconds, 10),
+			strconv.FormatInt(r.UptimeSeconds, 10),
+			strconv.FormatFloat(r.UptimePercent, 'f', 2, 64),
+			strconv.Itoa(r.Outages),
+			strconv.FormatFloat(r.MTTRSeconds, 'f', 0, 64),
+			strconv.FormatInt(r.LongestOutageSeconds, 10),
+		})
+	}
+
+	w.Flush()
+}
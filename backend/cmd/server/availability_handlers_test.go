@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAvailabilityRange(t *testing.T) {
+	parse := func(query string) (time.Time, time.Time, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/agents/availability"+query, nil)
+		return parseAvailabilityRange(c)
+	}
+
+	// 未来的结束时间被限制为当前时间
+	before := time.Now()
+	from, to, err := parse("?from=" + before.Add(-time.Hour).UTC().Format(time.RFC3339) + "&to=" + before.Add(48*time.Hour).UTC().Format(time.RFC3339))
+	require.NoError(t, err)
+	assert.False(t, to.Before(before))
+	assert.False(t, to.After(time.Now()))
+	assert.Equal(t, before.Add(-time.Hour).Unix(), from.Unix())
+
+	// 默认统计最近 7 天
+	from, to, err = parse("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultAvailabilityRange, to.Sub(from))
+
+	_, _, err = parse("?from=" + before.Add(time.Hour).UTC().Format(time.RFC3339))
+	assert.Error(t, err)
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/opamp"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// bulkProgressInterval 批量任务每处理多少个 Agent 保存一次进度
+const bulkProgressInterval = 100
+
+// bulkAgentsHandler 批量操作 Agent
+// @Summary      批量操作 Agent
+// @Description  对匹配选择器或显式指定的 Agent (且在当前角色范围内) 执行批量操作: delete、relabel、assign_configuration、restart 或 resync。
+// @Description  dry_run 为 true 时只返回匹配的 Agent; 否则创建后台任务并立即返回, 通过 GET /agents/bulk/jobs/{id} 查看进度和每个 Agent 的结果。delete 操作需要 agents:delete 权限, assign_configuration 操作需要 configurations:push 权限
+// @Tags         agents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body model.BulkAgentRequest true "目标 Agent 和操作"
+// @Success      200 {object} map[string]interface{} "dry_run 的匹配结果"
+// @Success      202 {object} model.BulkJob
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/bulk [post]
+func bulkAgentsHandler(store store.Store, opampServer opamp.Server, authorizer *auth.Authorizer, logger *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var req model.BulkAgentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := req.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Action == model.BulkActionDelete && !authorizer.Check(c, auth.PermAgentsDelete) {
+			return
+		}
+		if req.Action == model.BulkActionAssignConfiguration && !authorizer.Check(c, auth.PermConfigurationsPush) {
+			return
+		}
+
+		if req.Action == model.BulkActionAssignConfiguration && *req.ConfigurationName != "" {
+			config, err := store.GetConfigurationByName(ctx, *req.ConfigurationName)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if config == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "configuration not found"})
+				return
+			}
+		}
+
+		// 限定范围的角色只能操作范围内的 Agent
+		selector, ok := auth.MergeScope(c, req.Selector)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "selector is outside of the role scope"})
+			return
+		}
+		agents, err := store.ListAgentsByFilter(ctx, model.AgentFilter{IDs: req.AgentIDs, Selector: selector})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		missing := missingAgentIDs(req.AgentIDs, agents)
+
+		if req.DryRun {
+			c.JSON(http.StatusOK, gin.H{
+				"dry_run": true,
+				"action":  req.Action,
+				"agents":  agents,
+				"total":   len(agents),
+				"missing": missing,
+			})
+			return
+		}
+
+		job := &model.BulkJob{
+			Action:    req.Action,
+			Request:   req,
+			Status:    model.BulkJobRunning,
+			Total:     len(agents) + len(missing),
+			CreatedBy: currentUsername(c),
+		}
+		for _, id := range missing {
+			job.AddResult(model.BulkAgentResult{
+				AgentID: id,
+				Status:  model.BulkResultSkipped,
+				Error:   "agent not found or outside of the role scope",
+			})
+		}
+		if err := store.CreateBulkJob(ctx, job); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetTarget(c, "agents.bulk_jobs", strconv.FormatUint(uint64(job.ID), 10))
+		audit.SetChange(c, nil, req)
+
+		// 先写入响应再启动任务, 任务会修改 job
+		c.JSON(http.StatusAccepted, job)
+
+		// 任务在请求结束后继续执行, 保留 context 中的组织
+		go runBulkJob(context.WithoutCancel(ctx), store, opampServer, logger, auth.ScopeSelector(c), job, agents)
+	}
+}
+
+// listBulkJobsHandler 列出批量操作任务
+// @Summary      列出批量操作任务
+// @Description  按创建时间倒序列出批量操作任务, 不包含每个 Agent 的结果。限定范围的角色只能看到自己创建的任务
+// @Tags         agents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        limit query int false "每页数量" default(20)
+// @Param        offset query int false "偏移量" default(0)
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/bulk/jobs [get]
+func listBulkJobsHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+		jobs, total, err := store.ListBulkJobs(c.Request.Context(), bulkJobOwner(c), limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"jobs":   jobs,
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		})
+	}
+}
+
+// getBulkJobHandler 获取批量操作任务
+// @Summary      获取批量操作任务
+// @Description  获取批量操作任务的状态、计数和每个 Agent 的结果。限定范围的角色只能查看自己创建的任务
+// @Tags         agents
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "任务 ID"
+// @Success      200 {object} model.BulkJob
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/bulk/jobs/{id} [get]
+func getBulkJobHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+			return
+		}
+
+		job, err := store.GetBulkJob(c.Request.Context(), uint(id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if job == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+		if owner := bulkJobOwner(c); owner != "" && job.CreatedBy != owner {
+			c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// bulkJobOwner 限定范围的角色只能看到自己创建的任务, 返回其用户名; 不限定范围时返回空字符串
+//
+// 任务结果中包含范围外的 Agent ID, 因此不按范围而按创建者过滤。
+func bulkJobOwner(c *gin.Context) string {
+	if len(auth.ScopeSelector(c)) == 0 {
+		return ""
+	}
+	return currentUsername(c)
+}
+
+// missingAgentIDs 返回 ids 中没有出现在 agents 里的 ID
+func missingAgentIDs(ids []string, agents []*model.Agent) []string {
+	found := make(map[string]bool, len(agents))
+	for _, agent := range agents {
+		found[agent.ID] = true
+	}
+
+	missing := make([]string, 0)
+	for _, id := range ids {
+		if !found[id] {
+			found[id] = true
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// runBulkJob 逐个对 Agent 执行批量操作, 定期保存进度
+//
+// 保存失败时只记录日志并继续执行, 下一次保存会写入完整的进度。
+func runBulkJob(ctx context.Context, store store.Store, opampServer opamp.Server, logger *zap.Logger, scope map[string]string, job *model.BulkJob, agents []*model.Agent) {
+	save := func() {
+		if err := store.UpdateBulkJob(ctx, job); err != nil {
+			logger.Error("Failed to save bulk job",
+				zap.Uint("job_id", job.ID),
+				zap.String("status", string(job.Status)),
+				zap.Error(err))
+		}
+	}
+
+	for i, agent := range agents {
+		job.AddResult(runBulkAction(ctx, store, opampServer, scope, &job.Request, agent))
+		if (i+1)%bulkProgressInterval == 0 {
+			save()
+		}
+	}
+
+	now := time.Now()
+	job.Status = model.BulkJobCompleted
+	job.FinishedAt = &now
+	save()
+}
+
+// runBulkAction 对单个 Agent 执行批量操作
+func runBulkAction(ctx context.Context, store store.Store, opampServer opamp.Server, scope map[string]string, req *model.BulkAgentRequest, agent *model.Agent) model.BulkAgentResult {
+	result := model.BulkAgentResult{AgentID: agent.ID, Status: model.BulkResultSucceeded}
+	skip := func(reason string) model.BulkAgentResult {
+		result.Status = model.BulkResultSkipped
+		result.Error = reason
+		return result
+	}
+	fail := func(err error) model.BulkAgentResult {
+		result.Status = model.BulkResultFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	switch req.Action {
+	case model.BulkActionDelete:
+		if err := store.DeleteAgent(ctx, agent.ID); err != nil {
+			return fail(err)
+		}
+
+	case model.BulkActionRelabel:
+		change, err := changeAgentLabels(ctx, store, opampServer, scope, agent.ID, func(labels model.Labels) model.Labels {
+			return labels.Apply(req.Labels.Set, req.Labels.Remove)
+		})
+		if errors.Is(err, errLabelsOutOfScope) {
+			return skip(err.Error())
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if change == nil {
+			return skip("agent not found")
+		}
+		result.ConfigurationPushed = change.ConfigurationPushed
+		if change.PushError != "" {
+			return fail(errors.New("labels updated but configuration push failed: " + change.PushError))
+		}
+
+	case model.BulkActionAssignConfiguration:
+		change, err := pinAgentConfiguration(ctx, store, opampServer, agent.ID, *req.ConfigurationName)
+		if err != nil {
+			return fail(err)
+		}
+		if change == nil {
+			return skip("agent not found")
+		}
+		result.ConfigurationPushed = change.ConfigurationPushed
+		if change.PushError != "" {
+			return fail(errors.New("configuration assigned but push failed: " + change.PushError))
+		}
+
+	case model.BulkActionRestart:
+		if !opampServer.Connected(agent.ID) {
+			return skip("agent not connected")
+		}
+		if !opamp.AcceptsRestart(agent) {
+			return skip("agent does not accept restart commands")
+		}
+		if err := opampServer.SendUpdate(ctx, agent.ID, &model.AgentUpdate{Restart: true}); err != nil {
+			return fail(err)
+		}
+
+	case model.BulkActionResync:
+		// Agent 上报完整状态后, 服务端按上报的配置哈希重新核对并下发配置
+		if !opampServer.Connected(agent.ID) {
+			return skip("agent not connected")
+		}
+		if err := opampServer.SendUpdate(ctx, agent.ID, &model.AgentUpdate{ReportFullState: true}); err != nil {
+			return fail(err)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/opamp"
+)
+
+// commandOpAMPServer 记录发送给 Agent 的更新, 只有 connected 中的 Agent 视为已连接
+type commandOpAMPServer struct {
+	opamp.Server
+	connected map[string]bool
+	updates   map[string]*model.AgentUpdate
+}
+
+func (f *commandOpAMPServer) Connected(agentID string) bool { return f.connected[agentID] }
+
+func (f *commandOpAMPServer) SendUpdate(ctx context.Context, agentID string, update *model.AgentUpdate) error {
+	f.updates[agentID] = update
+	return nil
+}
+
+func TestMissingAgentIDs(t *testing.T) {
+	agents := []*model.Agent{{ID: "a1"}, {ID: "a3"}}
+
+	assert.Equal(t, []string{"a2"}, missingAgentIDs([]string{"a1", "a2", "a2", "a3"}, agents))
+	assert.Empty(t, missingAgentIDs(nil, agents))
+}
+
+func TestRunBulkAction_Commands(t *testing.T) {
+	ctx := context.Background()
+	server := &commandOpAMPServer{
+		connected: map[string]bool{"restartable": true, "legacy": true},
+		updates:   make(map[string]*model.AgentUpdate),
+	}
+	restartable := &model.Agent{
+		ID:           "restartable",
+		Capabilities: uint64(protobufs.AgentCapabilities_AgentCapabilities_AcceptsRestartCommand),
+	}
+	legacy := &model.Agent{ID: "legacy"}
+	offline := &model.Agent{ID: "offline"}
+
+	restart := &model.BulkAgentRequest{Action: model.BulkActionRestart}
+	result := runBulkAction(ctx, nil, server, nil, restart, restartable)
+	assert.Equal(t, model.BulkResultSucceeded, result.Status)
+	assert.True(t, server.updates["restartable"].Restart)
+
+	// 不支持重启命令和未连接的 Agent 被跳过
+	result = runBulkAction(ctx, nil, server, nil, restart, legacy)
+	assert.Equal(t, model.BulkResultSkipped, result.Status)
+	result = runBulkAction(ctx, nil, server, nil, restart, offline)
+	assert.Equal(t, model.BulkResultSkipped, result.Status)
+	assert.NotContains(t, server.updates, "legacy")
+
+	resync := &model.BulkAgentRequest{Action: model.BulkActionResync}
+	result = runBulkAction(ctx, nil, server, nil, resync, legacy)
+	assert.Equal(t, model.BulkResultSucceeded, result.Status)
+	assert.True(t, server.updates["legacy"].ReportFullState)
+}
+
+func TestBulkJobHandlers_RoleScope(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := t.Context()
+	suffix := time.Now().UnixNano()
+	operator := fmt.Sprintf("test-operator-%d", suffix)
+
+	own := &model.BulkJob{Action: model.BulkActionResync, Status: model.BulkJobCompleted, CreatedBy: operator}
+	other := &model.BulkJob{Action: model.BulkActionDelete, Status: model.BulkJobCompleted, CreatedBy: fmt.Sprintf("test-admin-%d", suffix)}
+	require.NoError(t, store.CreateBulkJob(ctx, own))
+	require.NoError(t, store.CreateBulkJob(ctx, other))
+
+	newRouter := func(role *model.Role) *gin.Engine {
+		router := setupTestRouter()
+		router.Use(func(c *gin.Context) {
+			c.Set(auth.AuthorizationPayloadKey, &auth.Claims{Username: operator, Role: role.Name})
+			c.Set(auth.RolePayloadKey, role)
+		})
+		router.GET("/bulk/jobs", listBulkJobsHandler(store))
+		router.GET("/bulk/jobs/:id", getBulkJobHandler(store))
+		return router
+	}
+	get := func(router *gin.Engine, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	listedIDs := func(w *httptest.ResponseRecorder) []uint {
+		var response struct {
+			Jobs []model.BulkJob `json:"jobs"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		var ids []uint
+		for _, job := range response.Jobs {
+			ids = append(ids, job.ID)
+		}
+		return ids
+	}
+
+	// 限定范围的角色只能看到自己创建的任务
+	scoped := newRouter(&model.Role{Name: "dev-operator", Permissions: []string{"agents:*"}, Selector: map[string]string{"env": "dev"}})
+	w := get(scoped, "/bulk/jobs?limit=1000")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	ids := listedIDs(w)
+	assert.Contains(t, ids, own.ID)
+	assert.NotContains(t, ids, other.ID)
+	assert.Equal(t, http.StatusOK, get(scoped, fmt.Sprintf("/bulk/jobs/%d", own.ID)).Code)
+	assert.Equal(t, http.StatusNotFound, get(scoped, fmt.Sprintf("/bulk/jobs/%d", other.ID)).Code)
+
+	// 不限定范围的角色可以看到所有任务
+	unscoped := newRouter(&model.Role{Name: "operator", Permissions: []string{"agents:*"}})
+	w = get(unscoped, "/bulk/jobs?limit=1000")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	ids = listedIDs(w)
+	assert.Contains(t, ids, own.ID)
+	assert.Contains(t, ids, other.ID)
+	assert.Equal(t, http.StatusOK, get(unscoped, fmt.Sprintf("/bulk/jobs/%d", other.ID)).Code)
+
+	// 清理
+	execTestSQL(store, "DELETE FROM bulk_jobs WHERE id IN (?, ?)", own.ID, other.ID)
+}
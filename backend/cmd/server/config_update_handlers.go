@@ -7,9 +7,12 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/events"
 	"github.com/cc1024201/opamp-platform/internal/model"
 	"github.com/cc1024201/opamp-platform/internal/opamp"
-	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+	"github.com/cc1024201/opamp-platform/internal/store"
 )
 
 // pushConfigurationHandler 手动推送配置到 Agent
@@ -24,10 +27,11 @@ import (
 // @Success      200 {object} map[string]interface{}
 // @Failure      400 {object} map[string]string
 // @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
 // @Failure      404 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations/{name}/push [post]
-func pushConfigurationHandler(store *postgres.Store, opampServer opamp.Server) gin.HandlerFunc {
+func pushConfigurationHandler(store store.Store, opampServer opamp.Server, bus *events.Bus) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		configName := c.Param("name")
 		agentID := c.Query("agent_id")
@@ -42,17 +46,27 @@ func pushConfigurationHandler(store *postgres.Store, opampServer opamp.Server) g
 			c.JSON(http.StatusNotFound, gin.H{"error": "configuration not found"})
 			return
 		}
+		if !checkConfigurationScope(c, config.Selector) {
+			return
+		}
 
-		var affectedAgents []string
-		var failedAgents []string
+		var targetAgents []*model.Agent
 
 		if agentID != "" {
 			// 推送到指定 Agent
-			if err := pushConfigToAgent(c.Request.Context(), store, opampServer, agentID, config); err != nil {
-				failedAgents = append(failedAgents, agentID)
-			} else {
-				affectedAgents = append(affectedAgents, agentID)
+			agent, err := store.GetAgent(c.Request.Context(), agentID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if agent == nil {
+				agent = &model.Agent{ID: agentID}
 			}
+			if !auth.InScope(c, agent.Labels) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+				return
+			}
+			targetAgents = append(targetAgents, agent)
 		} else {
 			// 推送到所有匹配的 Agent
 			agents, _, err := store.ListAgents(c.Request.Context(), 1000, 0)
@@ -67,39 +81,88 @@ func pushConfigurationHandler(store *postgres.Store, opampServer opamp.Server) g
 					continue
 				}
 
+				// 限定范围的角色只推送到范围内的 Agent
+				if !auth.InScope(c, agent.Labels) {
+					continue
+				}
+
 				// 只推送到已连接的 Agent
 				if !opampServer.Connected(agent.ID) {
 					continue
 				}
 
-				if err := pushConfigToAgent(c.Request.Context(), store, opampServer, agent.ID, config); err != nil {
-					failedAgents = append(failedAgents, agent.ID)
-				} else {
-					affectedAgents = append(affectedAgents, agent.ID)
-				}
+				targetAgents = append(targetAgents, agent)
+			}
+		}
+
+		var affectedAgents []string
+		var failedAgents []string
+		var heldAgents []string
+
+		for _, agent := range targetAgents {
+			// 抖动中的 Agent 暂缓推送, 待其稳定后通过下一次心跳获取配置
+			if opampServer.HoldsConfig(agent) {
+				heldAgents = append(heldAgents, agent.ID)
+				continue
+			}
+
+			pushErr := pushConfigToAgent(c.Request.Context(), store, opampServer, agent.ID, config)
+			if pushErr != nil {
+				failedAgents = append(failedAgents, agent.ID)
+			} else {
+				affectedAgents = append(affectedAgents, agent.ID)
+			}
+
+			// 发布推送进度事件
+			data := map[string]interface{}{
+				"configuration": config.Name,
+				"config_hash":   config.ConfigHash,
+				"succeeded":     len(affectedAgents),
+				"failed":        len(failedAgents),
+				"total":         len(targetAgents),
+			}
+			if pushErr != nil {
+				data["error"] = pushErr.Error()
 			}
+			bus.Publish(events.Event{
+				Type:           events.TypeRolloutProgress,
+				OrganizationID: config.OrganizationID,
+				AgentID:        agent.ID,
+				Labels:         agent.Labels,
+				Data:           data,
+			})
 		}
 
 		// 更新配置的最后应用时间
 		now := time.Now()
 		config.LastAppliedAt = &now
-		if err := store.UpdateConfiguration(c.Request.Context(), config); err != nil {
+		// 推送期间配置可能已被修改, 只在版本号未变时更新, 避免覆盖新的内容
+		if err := store.UpdateConfigurationIfVersion(c.Request.Context(), config, config.Version); err != nil {
 			// 记录错误但不影响响应
 			c.Header("X-Warning", "Failed to update last_applied_at: "+err.Error())
 		}
 
+		audit.SetChange(c, nil, gin.H{
+			"config_hash":     config.ConfigHash,
+			"affected_agents": affectedAgents,
+			"failed_agents":   failedAgents,
+			"held_agents":     heldAgents,
+		})
+
 		c.JSON(http.StatusOK, gin.H{
 			"message":         "configuration push initiated",
 			"affected_agents": affectedAgents,
 			"failed_agents":   failedAgents,
+			"held_agents":     heldAgents,
 			"total":           len(affectedAgents),
 			"failed":          len(failedAgents),
+			"held":            len(heldAgents),
 		})
 	}
 }
 
 // pushConfigToAgent 推送配置到单个 Agent
-func pushConfigToAgent(ctx context.Context, store *postgres.Store, opampServer opamp.Server, agentID string, config *model.Configuration) error {
+func pushConfigToAgent(ctx context.Context, store store.Store, opampServer opamp.Server, agentID string, config *model.Configuration) error {
 	// 创建应用历史记录
 	applyHistory := &model.ConfigurationApplyHistory{
 		AgentID:           agentID,
@@ -143,7 +206,7 @@ func pushConfigToAgent(ctx context.Context, store *postgres.Store, opampServer o
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations/{name}/history [get]
-func listConfigurationHistoryHandler(store *postgres.Store) gin.HandlerFunc {
+func listConfigurationHistoryHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		configName := c.Param("name")
 		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -178,7 +241,7 @@ func listConfigurationHistoryHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      404 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations/{name}/history/{version} [get]
-func getConfigurationHistoryHandler(store *postgres.Store) gin.HandlerFunc {
+func getConfigurationHistoryHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		configName := c.Param("name")
 		version, err := strconv.Atoi(c.Param("version"))
@@ -203,20 +266,24 @@ func getConfigurationHistoryHandler(store *postgres.Store) gin.HandlerFunc {
 
 // rollbackConfigurationHandler 回滚配置到指定版本
 // @Summary      回滚配置
-// @Description  将配置回滚到指定的历史版本
+// @Description  将配置回滚到指定的历史版本, If-Match 必须与配置当前的 ETag 一致
 // @Tags         configurations
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        name path string true "配置名称"
 // @Param        version path int true "目标版本号"
+// @Param        If-Match header string true "获取配置时返回的 ETag"
 // @Success      200 {object} model.Configuration
 // @Failure      400 {object} map[string]string
 // @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
 // @Failure      404 {object} map[string]string
+// @Failure      412 {object} map[string]string
+// @Failure      428 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations/{name}/rollback/{version} [post]
-func rollbackConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
+func rollbackConfigurationHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		configName := c.Param("name")
 		targetVersion, err := strconv.Atoi(c.Param("version"))
@@ -246,19 +313,29 @@ func rollbackConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "configuration not found"})
 			return
 		}
+		if !checkConfigurationScope(c, currentConfig.Selector, history.Selector) {
+			return
+		}
+		if !checkConfigurationIfMatch(c, currentConfig) {
+			return
+		}
 
 		// 使用历史版本的内容更新当前配置
+		before := *currentConfig
+		currentConfig.UpdatedBy = currentUsername(c)
 		currentConfig.ContentType = history.ContentType
 		currentConfig.RawConfig = history.RawConfig
 		currentConfig.Selector = history.Selector
 		currentConfig.Platform = history.Platform
-		// UpdateConfiguration 会自动处理版本号递增和历史记录
+		// UpdateConfigurationIfVersion 会自动处理版本号递增和历史记录
 
-		if err := store.UpdateConfiguration(c.Request.Context(), currentConfig); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if err := store.UpdateConfigurationIfVersion(c.Request.Context(), currentConfig, before.Version); err != nil {
+			writeConfigurationUpdateError(c, err)
 			return
 		}
+		audit.SetChange(c, &before, currentConfig)
 
+		c.Header("ETag", configurationETag(currentConfig))
 		c.JSON(http.StatusOK, currentConfig)
 	}
 }
@@ -277,7 +354,7 @@ func rollbackConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations/{name}/apply-history [get]
-func listApplyHistoryHandler(store *postgres.Store) gin.HandlerFunc {
+func listApplyHistoryHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		configName := c.Param("name")
 		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -312,7 +389,7 @@ func listApplyHistoryHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /agents/{id}/apply-history [get]
-func getAgentApplyHistoryHandler(store *postgres.Store) gin.HandlerFunc {
+func getAgentApplyHistoryHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		agentID := c.Param("id")
 		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
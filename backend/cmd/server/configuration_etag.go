@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// configurationETag 返回配置的强 ETag, 由版本号和内容哈希组成
+func configurationETag(config *model.Configuration) string {
+	return fmt.Sprintf(`"%d-%s"`, config.Version, config.ConfigHash)
+}
+
+// checkConfigurationIfMatch 检查 If-Match 请求头是否与配置的当前 ETag 一致
+//
+// 缺少 If-Match 时返回 428, 不一致时返回 412 并在 ETag 响应头中带上当前值, 两种情况都返回 false。
+func checkConfigurationIfMatch(c *gin.Context, config *model.Configuration) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return false
+	}
+
+	etag := configurationETag(config)
+	if !etagMatches(ifMatch, etag) {
+		c.Header("ETag", etag)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "configuration has been modified"})
+		return false
+	}
+	return true
+}
+
+// etagMatches 按强比较检查 If-Match 中的 ETag 列表是否包含 etag, 弱 ETag 永远不匹配
+func etagMatches(ifMatch, etag string) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConfigurationUpdateError 写入配置更新或删除失败的响应, 版本冲突返回 412
+func writeConfigurationUpdateError(c *gin.Context, err error) {
+	if errors.Is(err, store.ErrVersionConflict) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "configuration has been modified"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
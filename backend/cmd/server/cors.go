@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// allowedOrigins 允许跨域访问 API 和事件流的来源列表, "*" 表示允许所有来源
+type allowedOrigins []string
+
+// allows 检查浏览器请求的 Origin 是否在允许列表中
+func (o allowedOrigins) allows(origin string) bool {
+	for _, allowed := range o {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcard 检查是否允许所有来源
+func (o allowedOrigins) wildcard() bool {
+	for _, allowed := range o {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebSocketOrigin 检查 WebSocket 握手的来源, 防止跨站 WebSocket 劫持
+//
+// 没有 Origin 的非浏览器客户端和同源请求总是允许, 其他来源必须在允许列表中。
+func (o allowedOrigins) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return o.allows(origin)
+}
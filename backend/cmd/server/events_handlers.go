@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// sseKeepAliveInterval SSE 保活注释的发送间隔
+const sseKeepAliveInterval = 15 * time.Second
+
+// streamEventsHandler 以 Server-Sent Events 推送平台事件
+// @Summary      订阅平台事件 (SSE)
+// @Description  以 text/event-stream 推送 Agent 连接、状态变更、配置应用等事件, 支持通过 Last-Event-ID 断点续传
+// @Tags         events
+// @Produce      text/event-stream
+// @Security     BearerAuth
+// @Param        types         query  string false "事件类型过滤, 逗号分隔 (例如 agent.connected,config.failed)"
+// @Param        selector      query  string false "标签选择器 (例如 env=prod,region=us-east)"
+// @Param        last_event_id query  int    false "从该事件 ID 之后开始推送"
+// @Param        Last-Event-ID header int    false "从该事件 ID 之后开始推送"
+// @Success      200 {string} string "event stream"
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Router       /events [get]
+func streamEventsHandler(bus *events.Bus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, lastEventID, err := parseEventStreamParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sub, missed := bus.Subscribe(filter, lastEventID)
+		defer bus.Unsubscribe(sub)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+
+		for _, e := range missed {
+			if err := writeSSEEvent(c.Writer, e); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+
+		ticker := time.NewTicker(sseKeepAliveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if err := writeSSEEvent(c.Writer, e); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			case <-ticker.C:
+				if _, err := io.WriteString(c.Writer, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// streamEventsWebSocketHandler 以 WebSocket 推送平台事件
+// @Summary      订阅平台事件 (WebSocket)
+// @Description  升级为 WebSocket 连接后以 JSON 消息推送平台事件, 过滤参数与 SSE 接口相同。
+// @Description  浏览器无法设置 Authorization header, 可以通过子协议传递令牌: new WebSocket(url, ["bearer", token])
+// @Tags         events
+// @Security     BearerAuth
+// @Param        types         query string false "事件类型过滤, 逗号分隔"
+// @Param        selector      query string false "标签选择器"
+// @Param        last_event_id query int    false "从该事件 ID 之后开始推送"
+// @Success      101 {string} string "switching protocols"
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Router       /events/ws [get]
+func streamEventsWebSocketHandler(bus *events.Bus, origins allowedOrigins) gin.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     origins.checkWebSocketOrigin,
+		// 只回应 bearer 子协议, 令牌不会出现在响应中
+		Subprotocols: []string{auth.WebSocketProtocolBearer},
+	}
+
+	return func(c *gin.Context) {
+		filter, lastEventID, err := parseEventStreamParams(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		sub, missed := bus.Subscribe(filter, lastEventID)
+		defer bus.Unsubscribe(sub)
+
+		// 读取客户端消息以感知连接关闭
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for _, e := range missed {
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-closed:
+				return
+			case e, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if err := conn.WriteJSON(e); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// parseEventStreamParams 解析事件流的过滤参数和断点续传位置
+func parseEventStreamParams(c *gin.Context) (events.Filter, uint64, error) {
+	var filter events.Filter
+
+	if typesParam := c.Query("types"); typesParam != "" {
+		for _, t := range strings.Split(typesParam, ",") {
+			eventType := events.Type(strings.TrimSpace(t))
+			if !eventType.IsValid() {
+				return filter, 0, fmt.Errorf("unknown event type: %s", eventType)
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	selector, err := model.ParseLabelSelector(c.Query("selector"))
+	if err != nil {
+		return filter, 0, err
+	}
+	// 限定范围的角色只能订阅范围内 Agent 的事件
+	selector, ok := auth.MergeScope(c, selector)
+	if !ok {
+		return filter, 0, fmt.Errorf("selector is outside of the role scope")
+	}
+	filter.Selector = selector
+
+	// 只能订阅本组织的事件
+	if claims, ok := auth.GetCurrentUser(c); ok {
+		filter.OrganizationID = claims.OrganizationID
+	}
+
+	lastEventIDParam := c.GetHeader("Last-Event-ID")
+	if lastEventIDParam == "" {
+		lastEventIDParam = c.Query("last_event_id")
+	}
+
+	var lastEventID uint64
+	if lastEventIDParam != "" {
+		lastEventID, err = strconv.ParseUint(lastEventIDParam, 10, 64)
+		if err != nil {
+			return filter, 0, fmt.Errorf("invalid last event id: %s", lastEventIDParam)
+		}
+	}
+
+	return filter, lastEventID, nil
+}
+
+// writeSSEEvent 按 SSE 格式写出一条事件
+func writeSSEEvent(w io.Writer, e *events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// readSSEEventIDs 从 SSE 流中读取指定数量的事件 ID
+func readSSEEventIDs(t *testing.T, reader *bufio.Reader, count int) []string {
+	t.Helper()
+
+	var ids []string
+	for len(ids) < count {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimSpace(strings.TrimPrefix(line, "id: ")))
+		}
+	}
+	return ids
+}
+
+func TestStreamEventsHandler(t *testing.T) {
+	bus := events.NewBus(100)
+	router := setupTestRouter()
+	router.GET("/events", streamEventsHandler(bus))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// 订阅前已经发布的事件
+	first := bus.Publish(events.Event{Type: events.TypeAgentConnected, AgentID: "a1", Labels: model.Labels{"env": "prod"}})
+	bus.Publish(events.Event{Type: events.TypeAgentConnected, AgentID: "a2", Labels: model.Labels{"env": "dev"}})
+	third := bus.Publish(events.Event{Type: events.TypeAgentDisconnected, AgentID: "a3", Labels: model.Labels{"env": "prod"}})
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/events?selector=env=prod", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(first.ID, 10))
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+
+	// 补发第一个事件之后匹配 env=prod 的事件
+	assert.Equal(t, []string{strconv.FormatUint(third.ID, 10)}, readSSEEventIDs(t, reader, 1))
+
+	// 实时事件
+	require.Eventually(t, func() bool { return bus.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+	live := bus.Publish(events.Event{Type: events.TypeConfigFailed, AgentID: "a1", Labels: model.Labels{"env": "prod"}})
+	assert.Equal(t, []string{strconv.FormatUint(live.ID, 10)}, readSSEEventIDs(t, reader, 1))
+}
+
+func TestStreamEventsHandler_InvalidParams(t *testing.T) {
+	bus := events.NewBus(100)
+	router := setupTestRouter()
+	router.GET("/events", streamEventsHandler(bus))
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "未知事件类型", query: "?types=agent.unknown"},
+		{name: "非法标签选择器", query: "?selector=env"},
+		{name: "非法 last_event_id", query: "?last_event_id=abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/events"+tt.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestStreamEventsWebSocketHandler_Origin(t *testing.T) {
+	bus := events.NewBus(100)
+	router := setupTestRouter()
+	router.GET("/events/ws", streamEventsWebSocketHandler(bus, allowedOrigins{"http://localhost:3000"}))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/events/ws"
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{name: "非浏览器客户端", origin: "", allowed: true},
+		{name: "同源", origin: server.URL, allowed: true},
+		{name: "允许列表中的来源", origin: "http://localhost:3000", allowed: true},
+		{name: "其他站点", origin: "https://evil.example.com", allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+			conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+			if tt.allowed {
+				require.NoError(t, err)
+				conn.Close()
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+		})
+	}
+}
+
+func TestStreamEventsWebSocketHandler_SubprotocolAuth(t *testing.T) {
+	bus := events.NewBus(100)
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	router := setupTestRouter()
+	router.GET("/events/ws", auth.AuthMiddleware(jwtManager), streamEventsWebSocketHandler(bus, allowedOrigins{}))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/events/ws"
+
+	token, err := jwtManager.GenerateAccessToken(&model.User{ID: 1, Username: "viewer", Role: auth.RoleViewer})
+	require.NoError(t, err)
+
+	// 浏览器只能通过子协议传递令牌, 服务端回应 bearer 而不是令牌
+	dialer := websocket.Dialer{Subprotocols: []string{auth.WebSocketProtocolBearer, token}}
+	conn, resp, err := dialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, auth.WebSocketProtocolBearer, resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	require.Eventually(t, func() bool { return bus.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+	published := bus.Publish(events.Event{Type: events.TypeAgentConnected, OrganizationID: model.DefaultOrganizationID, AgentID: "a1"})
+
+	var received events.Event
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	require.NoError(t, conn.ReadJSON(&received))
+	assert.Equal(t, published.ID, received.ID)
+
+	// 没有令牌时拒绝升级
+	_, resp, err = websocket.DefaultDialer.Dial(wsURL, nil)
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	router := setupTestRouter()
+	router.Use(corsMiddleware(allowedOrigins{"http://localhost:3000"}))
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+
+	req = httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// defaultFleetDimensions 未指定 by 时的分组维度
+var defaultFleetDimensions = []model.FleetDimension{
+	model.FleetDimensionStatus,
+	model.FleetDimensionVersion,
+	model.FleetDimensionOS,
+	model.FleetDimensionArch,
+	model.FleetDimensionConfiguration,
+}
+
+// getFleetStatsHandler 获取 Agent 分组统计
+// @Summary      获取 Agent 分组统计
+// @Description  按状态、版本、操作系统、架构、配置或任意标签分组统计 Agent 数量, 过滤参数与 Agent 列表相同。
+// @Description  series=true 时同时返回各维度在 [from, to) 内的定期采样序列; 采样针对整个组织, 不受过滤条件影响, 标签维度只有配置了采样的标签才有数据
+// @Tags         agents
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        by               query string false "分组维度, 多个用逗号分隔 (status/version/os/arch/configuration/label:<key>), 默认除标签外的全部维度"
+// @Param        status           query string false "状态, 多个用逗号分隔 (online/offline/error)"
+// @Param        selector         query string false "标签选择器 (key1=value1,key2=value2)"
+// @Param        version          query string false "Agent 版本"
+// @Param        search           query string false "名称或主机名包含的字符串 (不区分大小写)"
+// @Param        os               query string false "操作系统类型"
+// @Param        arch             query string false "CPU 架构"
+// @Param        configuration    query string false "指定的配置名称"
+// @Param        last_seen_after  query string false "最后心跳时间不早于 (RFC3339)"
+// @Param        last_seen_before query string false "最后心跳时间早于 (RFC3339)"
+// @Param        capability       query string false "必须具备的能力, 多个用逗号分隔"
+// @Param        series           query bool   false "是否返回采样序列" default(false)
+// @Param        from             query string false "序列开始时间 (RFC3339), 默认 7 天前"
+// @Param        to               query string false "序列结束时间 (RFC3339), 默认当前时间"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /agents/stats [get]
+func getFleetStatsHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		dimensions, err := model.ParseFleetDimensions(c.Query("by"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(dimensions) == 0 {
+			dimensions = defaultFleetDimensions
+		}
+		filter, err := parseAgentFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		withSeries, _ := strconv.ParseBool(c.DefaultQuery("series", "false"))
+
+		// 采样覆盖整个组织, 限定范围的角色不能查看
+		if withSeries && auth.ScopeSelector(c) != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "fleet stats series are not available to scoped roles"})
+			return
+		}
+
+		// 限定范围的角色只统计范围内的 Agent
+		selector, ok := auth.MergeScope(c, filter.Selector)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "selector is outside of the role scope"})
+			return
+		}
+		filter.Selector = selector
+
+		var total int64
+		counts := make(map[model.FleetDimension][]model.FleetBucket, len(dimensions))
+		for i, dimension := range dimensions {
+			buckets, err := store.CountAgentsBy(ctx, filter, dimension)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			counts[dimension] = buckets
+
+			// 每个维度都覆盖全部 Agent, 用第一个维度计算总数
+			if i == 0 {
+				for _, bucket := range buckets {
+					total += bucket.Count
+				}
+			}
+		}
+
+		response := gin.H{
+			"total":      total,
+			"dimensions": counts,
+		}
+
+		if withSeries {
+			from, to, err := parseAvailabilityRange(c)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			series := make(map[model.FleetDimension][]model.FleetSeriesPoint, len(dimensions))
+			for _, dimension := range dimensions {
+				samples, err := store.ListFleetStatsSamples(ctx, dimension, from, to)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				series[dimension] = model.BuildFleetSeries(samples)
+			}
+			response["from"] = from
+			response["to"] = to
+			response["series"] = series
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -1,50 +1,203 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/auth"
 	"github.com/cc1024201/opamp-platform/internal/model"
-	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+	"github.com/cc1024201/opamp-platform/internal/opamp"
+	"github.com/cc1024201/opamp-platform/internal/store"
 )
 
 // Agent handlers
 
 // listAgentsHandler 列出所有 Agent
 // @Summary      列出所有 Agent
-// @Description  获取所有 Agent 列表，支持分页
+// @Description  按状态、标签、版本、名称、系统、配置、最后心跳时间和能力筛选 Agent, 支持排序、偏移分页和游标分页。返回 next_cursor 时可用它获取下一页
 // @Tags         agents
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
-// @Param        limit  query int false "每页数量" default(20)
-// @Param        offset query int false "偏移量" default(0)
+// @Param        status           query string false "状态, 多个用逗号分隔 (online/offline/error)"
+// @Param        selector         query string false "标签选择器 (key1=value1,key2=value2)"
+// @Param        version          query string false "Agent 版本"
+// @Param        search           query string false "名称或主机名包含的字符串 (不区分大小写)"
+// @Param        os               query string false "操作系统类型"
+// @Param        arch             query string false "CPU 架构"
+// @Param        configuration    query string false "指定的配置名称"
+// @Param        last_seen_after  query string false "最后心跳时间不早于 (RFC3339)"
+// @Param        last_seen_before query string false "最后心跳时间早于 (RFC3339)"
+// @Param        capability       query string false "必须具备的能力, 多个用逗号分隔 (如 accepts_remote_config)"
+// @Param        decommissioned   query bool   false "true 只列出已退役的 Agent, false 只列出未退役的 Agent, 默认全部"
+// @Param        sort             query string false "排序字段, 前缀 - 表示倒序 (updated_at/created_at/last_seen_at/name/hostname/version)" default(-updated_at)
+// @Param        limit            query int    false "每页数量 (最大 1000)" default(20)
+// @Param        offset           query int    false "偏移量, 指定 cursor 时忽略" default(0)
+// @Param        cursor           query string false "上一页返回的 next_cursor"
 // @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /agents [get]
-func listAgentsHandler(store *postgres.Store) gin.HandlerFunc {
+func listAgentsHandler(s store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 获取分页参数
-		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		filter, err := parseAgentFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		opts, err := parseAgentListOptions(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 限定范围的角色只能看到范围内的 Agent
+		selector, ok := auth.MergeScope(c, filter.Selector)
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{
+				"agents": []*model.Agent{},
+				"total":  0,
+				"limit":  opts.Limit,
+				"offset": opts.Offset,
+			})
+			return
+		}
+		filter.Selector = selector
 
-		agents, total, err := store.ListAgents(c.Request.Context(), limit, offset)
+		agents, total, next, err := s.SearchAgents(c.Request.Context(), filter, opts)
+		if errors.Is(err, store.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
+		response := gin.H{
 			"agents": agents,
 			"total":  total,
-			"limit":  limit,
-			"offset": offset,
-		})
+			"limit":  opts.Limit,
+			"offset": opts.Offset,
+		}
+		if next != "" {
+			response["next_cursor"] = next
+		}
+		c.JSON(http.StatusOK, response)
 	}
 }
 
+// maxAgentPageSize Agent 列表每页的最大数量
+const maxAgentPageSize = 1000
+
+// parseAgentFilter 解析 Agent 列表的过滤参数
+func parseAgentFilter(c *gin.Context) (model.AgentFilter, error) {
+	filter := model.AgentFilter{
+		Version:           c.Query("version"),
+		Search:            strings.TrimSpace(c.Query("search")),
+		OS:                c.Query("os"),
+		Arch:              c.Query("arch"),
+		ConfigurationName: c.Query("configuration"),
+	}
+
+	for _, s := range splitQueryList(c.Query("status")) {
+		status := model.AgentStatus(s)
+		if !status.IsValid() {
+			return filter, fmt.Errorf("invalid status: %s", s)
+		}
+		filter.Statuses = append(filter.Statuses, status)
+	}
+
+	selector, err := model.ParseLabelSelector(c.Query("selector"))
+	if err != nil {
+		return filter, err
+	}
+	filter.Selector = selector
+
+	if v := c.Query("last_seen_after"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid last_seen_after: %w", err)
+		}
+		filter.LastSeenAfter = &t
+	}
+	if v := c.Query("last_seen_before"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid last_seen_before: %w", err)
+		}
+		filter.LastSeenBefore = &t
+	}
+
+	if v := c.Query("decommissioned"); v != "" {
+		decommissioned, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid decommissioned: %s", v)
+		}
+		filter.Decommissioned = &decommissioned
+	}
+
+	for _, name := range splitQueryList(c.Query("capability")) {
+		capability, err := opamp.ParseCapability(name)
+		if err != nil {
+			return filter, err
+		}
+		filter.Capabilities |= capability
+	}
+
+	return filter, nil
+}
+
+// parseAgentListOptions 解析 Agent 列表的排序和分页参数
+func parseAgentListOptions(c *gin.Context) (model.AgentListOptions, error) {
+	opts := model.AgentListOptions{
+		Sort:       model.AgentSortUpdatedAt,
+		Descending: true,
+		Cursor:     c.Query("cursor"),
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		field, descending := strings.CutPrefix(sort, "-")
+		opts.Sort = model.AgentSort(field)
+		opts.Descending = descending
+		if !opts.Sort.IsValid() {
+			return opts, fmt.Errorf("invalid sort field: %s", field)
+		}
+	}
+
+	opts.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if opts.Limit <= 0 {
+		opts.Limit = 20
+	}
+	if opts.Limit > maxAgentPageSize {
+		opts.Limit = maxAgentPageSize
+	}
+	opts.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+
+	return opts, nil
+}
+
+// splitQueryList 拆分逗号分隔的查询参数, 忽略空项
+func splitQueryList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // getAgentHandler 获取单个 Agent
 // @Summary      获取 Agent 详情
 // @Description  根据 ID 获取单个 Agent 的详细信息
@@ -58,7 +211,7 @@ func listAgentsHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      404 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /agents/{id} [get]
-func getAgentHandler(store *postgres.Store) gin.HandlerFunc {
+func getAgentHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		agentID := c.Param("id")
 
@@ -79,7 +232,7 @@ func getAgentHandler(store *postgres.Store) gin.HandlerFunc {
 
 // deleteAgentHandler 删除 Agent
 // @Summary      删除 Agent
-// @Description  根据 ID 删除指定的 Agent
+// @Description  根据 ID 删除指定的 Agent 及其连接历史和配置应用历史。仍在运行的采集器再次连接时会重新注册, 要永久移除请使用 POST /agents/{id}/decommission
 // @Tags         agents
 // @Accept       json
 // @Produce      json
@@ -89,7 +242,7 @@ func getAgentHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /agents/{id} [delete]
-func deleteAgentHandler(store *postgres.Store) gin.HandlerFunc {
+func deleteAgentHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		agentID := c.Param("id")
 
@@ -115,7 +268,7 @@ func deleteAgentHandler(store *postgres.Store) gin.HandlerFunc {
 // @Failure      401 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations [get]
-func listConfigurationsHandler(store *postgres.Store) gin.HandlerFunc {
+func listConfigurationsHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		configs, err := store.ListConfigurations(c.Request.Context())
 		if err != nil {
@@ -132,18 +285,19 @@ func listConfigurationsHandler(store *postgres.Store) gin.HandlerFunc {
 
 // getConfigurationHandler 获取单个配置
 // @Summary      获取配置详情
-// @Description  根据名称获取单个配置的详细信息
+// @Description  根据名称获取单个配置的详细信息, ETag 响应头用于后续修改时的 If-Match
 // @Tags         configurations
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        name path string true "配置名称"
 // @Success      200 {object} model.Configuration
+// @Header       200 {string} ETag "配置版本标识"
 // @Failure      401 {object} map[string]string
 // @Failure      404 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations/{name} [get]
-func getConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
+func getConfigurationHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		name := c.Param("name")
 
@@ -158,6 +312,7 @@ func getConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
 			return
 		}
 
+		c.Header("ETag", configurationETag(config))
 		c.JSON(http.StatusOK, config)
 	}
 }
@@ -173,9 +328,10 @@ func getConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
 // @Success      201 {object} model.Configuration
 // @Failure      400 {object} map[string]string
 // @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations [post]
-func createConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
+func createConfigurationHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var config model.Configuration
 		if err := c.ShouldBindJSON(&config); err != nil {
@@ -183,10 +339,17 @@ func createConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
 			return
 		}
 
+		if !checkConfigurationScope(c, config.Selector) {
+			return
+		}
+
+		config.UpdatedBy = currentUsername(c)
 		if err := store.CreateConfiguration(c.Request.Context(), &config); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		audit.SetTarget(c, "configurations", config.Name)
+		audit.SetChange(c, nil, &config)
 
 		c.JSON(http.StatusCreated, config)
 	}
@@ -194,19 +357,24 @@ func createConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
 
 // updateConfigurationHandler 更新配置
 // @Summary      更新配置
-// @Description  更新指定名称的配置
+// @Description  更新指定名称的配置, If-Match 必须与配置当前的 ETag 一致
 // @Tags         configurations
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        name path string true "配置名称"
+// @Param        If-Match header string true "获取配置时返回的 ETag"
 // @Param        configuration body model.Configuration true "配置信息"
 // @Success      200 {object} model.Configuration
 // @Failure      400 {object} map[string]string
 // @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      412 {object} map[string]string
+// @Failure      428 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations/{name} [put]
-func updateConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
+func updateConfigurationHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		name := c.Param("name")
 
@@ -218,36 +386,92 @@ func updateConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
 
 		config.Name = name
 
-		if err := store.UpdateConfiguration(c.Request.Context(), &config); err != nil {
+		existing, err := store.GetConfigurationByName(c.Request.Context(), name)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if existing == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "configuration not found"})
+			return
+		}
 
+		if !checkConfigurationScope(c, existing.Selector, config.Selector) {
+			return
+		}
+		if !checkConfigurationIfMatch(c, existing) {
+			return
+		}
+
+		// 版本号在存储的事务中再次检查, 防止检查之后被并发修改
+		config.UpdatedBy = currentUsername(c)
+		if err := store.UpdateConfigurationIfVersion(c.Request.Context(), &config, existing.Version); err != nil {
+			writeConfigurationUpdateError(c, err)
+			return
+		}
+		audit.SetChange(c, existing, &config)
+
+		c.Header("ETag", configurationETag(&config))
 		c.JSON(http.StatusOK, config)
 	}
 }
 
 // deleteConfigurationHandler 删除配置
 // @Summary      删除配置
-// @Description  根据名称删除指定的配置
+// @Description  根据名称删除指定的配置, If-Match 必须与配置当前的 ETag 一致
 // @Tags         configurations
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
 // @Param        name path string true "配置名称"
+// @Param        If-Match header string true "获取配置时返回的 ETag"
 // @Success      200 {object} map[string]string
 // @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      412 {object} map[string]string
+// @Failure      428 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Router       /configurations/{name} [delete]
-func deleteConfigurationHandler(store *postgres.Store) gin.HandlerFunc {
+func deleteConfigurationHandler(store store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		name := c.Param("name")
 
-		if err := store.DeleteConfiguration(c.Request.Context(), name); err != nil {
+		existing, err := store.GetConfigurationByName(c.Request.Context(), name)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		if existing == nil {
+			// DELETE 操作幂等, 配置不存在时无需检查 If-Match
+			c.JSON(http.StatusOK, gin.H{"message": "configuration deleted"})
+			return
+		}
+		if !checkConfigurationScope(c, existing.Selector) {
+			return
+		}
+		if !checkConfigurationIfMatch(c, existing) {
+			return
+		}
+
+		if err := store.DeleteConfigurationIfVersion(c.Request.Context(), name, existing.Version); err != nil {
+			writeConfigurationUpdateError(c, err)
+			return
+		}
+		audit.SetChange(c, existing, nil)
 
 		c.JSON(http.StatusOK, gin.H{"message": "configuration deleted"})
 	}
 }
+
+// checkConfigurationScope 检查配置的选择器是否在当前角色的范围内, 不在范围内时返回 403
+//
+// 限定范围的角色只能管理选择器包含其范围的配置, 否则配置会下发到范围外的 Agent。
+func checkConfigurationScope(c *gin.Context, selectors ...map[string]string) bool {
+	for _, selector := range selectors {
+		if !auth.SelectorInScope(c, selector) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "configuration selector is outside of the role scope"})
+			return false
+		}
+	}
+	return true
+}
@@ -9,10 +9,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/cc1024201/opamp-platform/internal/auth"
 	"github.com/cc1024201/opamp-platform/internal/model"
 )
 
@@ -91,7 +93,7 @@ func TestListAgentsHandler(t *testing.T) {
 	}
 
 	// 清理
-	store.GetDB().Exec("DELETE FROM agents WHERE name LIKE 'test-agent-%'")
+	execTestSQL(store, "DELETE FROM agents WHERE name LIKE 'test-agent-%'")
 }
 
 func TestGetAgentHandler(t *testing.T) {
@@ -154,7 +156,7 @@ func TestGetAgentHandler(t *testing.T) {
 	}
 
 	// 清理
-	store.GetDB().Exec("DELETE FROM agents WHERE name = 'test-agent-get'")
+	execTestSQL(store, "DELETE FROM agents WHERE name = 'test-agent-get'")
 }
 
 func TestDeleteAgentHandler(t *testing.T) {
@@ -277,7 +279,7 @@ func TestListConfigurationsHandler(t *testing.T) {
 	}
 
 	// 清理
-	store.GetDB().Exec("DELETE FROM configurations WHERE name LIKE 'test-config-%'")
+	execTestSQL(store, "DELETE FROM configurations WHERE name LIKE 'test-config-%'")
 }
 
 func TestGetConfigurationHandler(t *testing.T) {
@@ -310,6 +312,7 @@ func TestGetConfigurationHandler(t *testing.T) {
 				require.NoError(t, err)
 				assert.Equal(t, "test-config-get", response.Name)
 				assert.Equal(t, "Test Config Get", response.DisplayName)
+				assert.Equal(t, fmt.Sprintf(`"1-%s"`, response.ConfigHash), w.Header().Get("ETag"))
 			},
 		},
 		{
@@ -339,7 +342,7 @@ func TestGetConfigurationHandler(t *testing.T) {
 	}
 
 	// 清理
-	store.GetDB().Exec("DELETE FROM configurations WHERE name = 'test-config-get'")
+	execTestSQL(store, "DELETE FROM configurations WHERE name = 'test-config-get'")
 }
 
 func TestCreateConfigurationHandler(t *testing.T) {
@@ -399,7 +402,7 @@ func TestCreateConfigurationHandler(t *testing.T) {
 	}
 
 	// 清理
-	store.GetDB().Exec("DELETE FROM configurations WHERE name LIKE 'test-config-create-%'")
+	execTestSQL(store, "DELETE FROM configurations WHERE name LIKE 'test-config-create-%'")
 }
 
 func TestUpdateConfigurationHandler(t *testing.T) {
@@ -417,33 +420,64 @@ func TestUpdateConfigurationHandler(t *testing.T) {
 	err := store.CreateConfiguration(nil, config)
 	require.NoError(t, err)
 
+	etag := configurationETag(config)
+	update := model.Configuration{
+		DisplayName: "Updated Config",
+		ContentType: "yaml",
+		RawConfig:   "test: updated",
+		Selector:    map[string]string{"env": "prod"},
+	}
+
 	tests := []struct {
 		name           string
 		configName     string
+		ifMatch        string
 		requestBody    interface{}
 		expectedStatus int
 		checkResponse  func(*testing.T, *httptest.ResponseRecorder)
 	}{
 		{
-			name:       "成功更新 configuration",
-			configName: configName,
-			requestBody: model.Configuration{
-				DisplayName: "Updated Config",
-				ContentType: "yaml",
-				RawConfig:   "test: updated",
-				Selector:    map[string]string{"env": "prod"},
+			name:           "缺少 If-Match",
+			configName:     configName,
+			requestBody:    update,
+			expectedStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:           "If-Match 与当前版本不一致",
+			configName:     configName,
+			ifMatch:        `"0-stale"`,
+			requestBody:    update,
+			expectedStatus: http.StatusPreconditionFailed,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, etag, w.Header().Get("ETag"))
 			},
+		},
+		{
+			name:           "成功更新 configuration",
+			configName:     configName,
+			ifMatch:        etag,
+			requestBody:    update,
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
 				var response model.Configuration
 				err := json.Unmarshal(w.Body.Bytes(), &response)
 				require.NoError(t, err)
 				assert.Equal(t, "Updated Config", response.DisplayName)
+				assert.Equal(t, 2, response.Version)
+				assert.Equal(t, configurationETag(&response), w.Header().Get("ETag"))
 			},
 		},
+		{
+			name:           "使用已过期的 ETag 再次更新",
+			configName:     configName,
+			ifMatch:        etag,
+			requestBody:    update,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
 		{
 			name:           "无效的请求体",
 			configName:     configName,
+			ifMatch:        "*",
 			requestBody:    "invalid json{",
 			expectedStatus: http.StatusBadRequest,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
@@ -460,6 +494,9 @@ func TestUpdateConfigurationHandler(t *testing.T) {
 			body, _ := json.Marshal(tt.requestBody)
 			req := httptest.NewRequest(http.MethodPut, "/configurations/"+tt.configName, bytes.NewBuffer(body))
 			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
 
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
@@ -472,7 +509,101 @@ func TestUpdateConfigurationHandler(t *testing.T) {
 	}
 
 	// 清理
-	store.GetDB().Exec("DELETE FROM configurations WHERE name LIKE 'test-config-update-%'")
+	execTestSQL(store, "DELETE FROM configurations WHERE name LIKE 'test-config-update-%'")
+}
+
+func TestUpdateConfigurationHandler_SelectorOnlyConflict(t *testing.T) {
+	store := setupTestStore(t)
+
+	configName := fmt.Sprintf("test-config-selector-%d", time.Now().Unix())
+	config := &model.Configuration{Name: configName, ContentType: "yaml", RawConfig: "test: config", Selector: map[string]string{"env": "test"}}
+	require.NoError(t, store.CreateConfiguration(nil, config))
+	etag := configurationETag(config)
+
+	router := setupTestRouter()
+	router.PUT("/configurations/:name", updateConfigurationHandler(store))
+	update := func(env string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(model.Configuration{ContentType: "yaml", RawConfig: "test: config", Selector: map[string]string{"env": env}})
+		req := httptest.NewRequest(http.MethodPut, "/configurations/"+configName, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", etag)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 两个客户端基于同一 ETag 只修改选择器, 第二次修改不能覆盖第一次
+	w := update("prod")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	assert.Equal(t, http.StatusPreconditionFailed, update("staging").Code)
+
+	stored, err := store.GetConfigurationByName(nil, configName)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod"}, stored.Selector)
+
+	// 清理
+	execTestSQL(store, "DELETE FROM configurations WHERE name = ?", configName)
+}
+
+func TestConfigurationHandlers_RoleScope(t *testing.T) {
+	store := setupTestStore(t)
+
+	prodName := fmt.Sprintf("test-config-scope-prod-%d", time.Now().Unix())
+	prod := &model.Configuration{Name: prodName, ContentType: "yaml", RawConfig: "test: prod", Selector: map[string]string{"env": "prod"}}
+	require.NoError(t, store.CreateConfiguration(nil, prod))
+
+	// 范围限定为 env=dev 的角色
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.RolePayloadKey, &model.Role{Name: "dev-operator", Permissions: []string{"configurations:*"}, Selector: map[string]string{"env": "dev"}})
+	})
+	router.POST("/configurations", createConfigurationHandler(store))
+	router.PUT("/configurations/:name", updateConfigurationHandler(store))
+	router.DELETE("/configurations/:name", deleteConfigurationHandler(store))
+
+	send := func(method, path string, body interface{}, ifMatch string) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	devName := fmt.Sprintf("test-config-scope-dev-%d", time.Now().Unix())
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		ifMatch        string
+		expectedStatus int
+	}{
+		{"创建范围外的配置", http.MethodPost, "/configurations", model.Configuration{Name: devName, RawConfig: "a: 1", Selector: map[string]string{"env": "prod"}}, "", http.StatusForbidden},
+		{"创建没有选择器的配置", http.MethodPost, "/configurations", model.Configuration{Name: devName, RawConfig: "a: 1"}, "", http.StatusForbidden},
+		{"创建范围内的配置", http.MethodPost, "/configurations", model.Configuration{Name: devName, RawConfig: "a: 1", Selector: map[string]string{"env": "dev", "app": "web"}}, "", http.StatusCreated},
+		{"把范围外的配置改到范围内", http.MethodPut, "/configurations/" + prodName, model.Configuration{RawConfig: "a: 2", Selector: map[string]string{"env": "dev"}}, configurationETag(prod), http.StatusForbidden},
+		{"删除范围外的配置", http.MethodDelete, "/configurations/" + prodName, nil, configurationETag(prod), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.method, tt.path, tt.body, tt.ifMatch)
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+
+	stored, err := store.GetConfigurationByName(nil, prodName)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, "test: prod", stored.RawConfig)
+
+	// 清理
+	execTestSQL(store, "DELETE FROM configurations WHERE name LIKE 'test-config-scope-%'")
 }
 
 func TestDeleteConfigurationHandler(t *testing.T) {
@@ -493,12 +624,29 @@ func TestDeleteConfigurationHandler(t *testing.T) {
 	tests := []struct {
 		name           string
 		configName     string
+		ifMatch        string
 		expectedStatus int
 		checkResponse  func(*testing.T, *httptest.ResponseRecorder)
 	}{
+		{
+			name:           "缺少 If-Match",
+			configName:     configName,
+			expectedStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:           "If-Match 与当前版本不一致",
+			configName:     configName,
+			ifMatch:        `W/` + configurationETag(config),
+			expectedStatus: http.StatusPreconditionFailed,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				existing, _ := store.GetConfigurationByName(nil, configName)
+				assert.NotNil(t, existing)
+			},
+		},
 		{
 			name:           "成功删除 configuration",
 			configName:     configName,
+			ifMatch:        `"0-stale", ` + configurationETag(config),
 			expectedStatus: http.StatusOK,
 			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
 				assert.Contains(t, w.Body.String(), "deleted")
@@ -523,6 +671,9 @@ func TestDeleteConfigurationHandler(t *testing.T) {
 			router.DELETE("/configurations/:name", deleteConfigurationHandler(store))
 
 			req := httptest.NewRequest(http.MethodDelete, "/configurations/"+tt.configName, nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
 			w := httptest.NewRecorder()
 			router.ServeHTTP(w, req)
 
@@ -533,3 +684,39 @@ func TestDeleteConfigurationHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRollbackConfigurationHandler_IfMatch(t *testing.T) {
+	store := setupTestStore(t)
+
+	configName := fmt.Sprintf("test-config-rollback-%d", time.Now().Unix())
+	config := &model.Configuration{Name: configName, ContentType: "yaml", RawConfig: "test: v1"}
+	require.NoError(t, store.CreateConfiguration(nil, config))
+	config.RawConfig = "test: v2"
+	require.NoError(t, store.UpdateConfiguration(nil, config))
+
+	router := setupTestRouter()
+	router.POST("/configurations/:name/rollback/:version", rollbackConfigurationHandler(store))
+	rollback := func(ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/configurations/"+configName+"/rollback/1", nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusPreconditionRequired, rollback("").Code)
+	assert.Equal(t, http.StatusPreconditionFailed, rollback(`"1-stale"`).Code)
+
+	w := rollback(configurationETag(config))
+	require.Equal(t, http.StatusOK, w.Code)
+	var response model.Configuration
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "test: v1", response.RawConfig)
+	assert.Equal(t, 3, response.Version)
+	assert.Equal(t, configurationETag(&response), w.Header().Get("ETag"))
+
+	// 清理
+	execTestSQL(store, "DELETE FROM configurations WHERE name = ?", configName)
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/store"
 )
 
 // HealthStatus 健康检查状态
@@ -34,7 +36,7 @@ type HealthResponse struct {
 }
 
 // healthCheckHandler 详细的健康检查
-func healthCheckHandler(db *gorm.DB) gin.HandlerFunc {
+func healthCheckHandler(db store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
 		defer cancel()
@@ -74,20 +76,17 @@ func healthCheckHandler(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// dbStatsProvider 使用连接池的存储
+type dbStatsProvider interface {
+	Stats() sql.DBStats
+}
+
 // checkDatabase 检查数据库连接
-func checkDatabase(ctx context.Context, db *gorm.DB) ComponentHealth {
+func checkDatabase(ctx context.Context, db store.Store) ComponentHealth {
 	start := time.Now()
 
-	sqlDB, err := db.DB()
-	if err != nil {
-		return ComponentHealth{
-			Status:  HealthStatusUnhealthy,
-			Message: "failed to get database connection: " + err.Error(),
-		}
-	}
-
 	// Ping 数据库
-	if err := sqlDB.PingContext(ctx); err != nil {
+	if err := db.Ping(ctx); err != nil {
 		return ComponentHealth{
 			Status:  HealthStatusUnhealthy,
 			Message: "database ping failed: " + err.Error(),
@@ -97,12 +96,14 @@ func checkDatabase(ctx context.Context, db *gorm.DB) ComponentHealth {
 	latency := time.Since(start)
 
 	// 检查连接池状态
-	stats := sqlDB.Stats()
-	if stats.OpenConnections >= stats.MaxOpenConnections {
-		return ComponentHealth{
-			Status:  HealthStatusDegraded,
-			Message: "database connection pool exhausted",
-			Latency: latency.String(),
+	if provider, ok := db.(dbStatsProvider); ok {
+		stats := provider.Stats()
+		if stats.MaxOpenConnections > 0 && stats.OpenConnections >= stats.MaxOpenConnections {
+			return ComponentHealth{
+				Status:  HealthStatusDegraded,
+				Message: "database connection pool exhausted",
+				Latency: latency.String(),
+			}
 		}
 	}
 
@@ -114,14 +115,13 @@ func checkDatabase(ctx context.Context, db *gorm.DB) ComponentHealth {
 }
 
 // readinessHandler Kubernetes 就绪探针
-func readinessHandler(db *gorm.DB) gin.HandlerFunc {
+func readinessHandler(db store.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
 		defer cancel()
 
 		// 检查数据库
-		sqlDB, err := db.DB()
-		if err != nil || sqlDB.PingContext(ctx) != nil {
+		if err := db.Ping(ctx); err != nil {
 			c.JSON(http.StatusServiceUnavailable, gin.H{
 				"ready": false,
 			})
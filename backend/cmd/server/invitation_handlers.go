@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// maxInvitationTTL 邀请允许的最长有效期
+const maxInvitationTTL = 30 * 24 * time.Hour
+
+// createInvitationHandler 创建邀请
+// @Summary      创建邀请
+// @Description  创建一个带有效期的邀请, 被邀请人使用令牌注册并获得指定角色。令牌只在创建时返回一次
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        invitation body model.InvitationRequest true "邀请信息"
+// @Success      201 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /invitations [post]
+func createInvitationHandler(store store.Store, authorizer *auth.Authorizer, mode auth.RegistrationMode, defaultTTL time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mode == auth.RegistrationDisabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invitations are not available when registration is disabled"})
+			return
+		}
+
+		var req model.InvitationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ttl := defaultTTL
+		if req.ExpiresIn != "" {
+			parsed, err := time.ParseDuration(req.ExpiresIn)
+			if err != nil || parsed <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expires_in"})
+				return
+			}
+			ttl = parsed
+		}
+		if ttl > maxInvitationTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must not exceed 720h"})
+			return
+		}
+
+		roleName, ok := resolveRoleName(c, authorizer, req.Role)
+		if !ok {
+			return
+		}
+
+		token, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+			return
+		}
+
+		invitation := &model.Invitation{
+			TokenHash: auth.HashToken(token),
+			Email:     req.Email,
+			Role:      roleName,
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		if claims, exists := auth.GetCurrentUser(c); exists {
+			invitation.CreatedBy = claims.Username
+		}
+
+		if err := store.CreateInvitation(c.Request.Context(), invitation); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"invitation": invitation,
+			"token":      token,
+		})
+	}
+}
+
+// listInvitationsHandler 列出邀请
+// @Summary      列出邀请
+// @Description  默认只列出仍可使用的邀请, all=true 时包括已接受和已过期的邀请
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        all query bool false "包括已接受和已过期的邀请"
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /invitations [get]
+func listInvitationsHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		includeUsed := c.Query("all") == "true"
+
+		invitations, err := store.ListInvitations(c.Request.Context(), includeUsed)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"invitations": invitations,
+			"total":       len(invitations),
+		})
+	}
+}
+
+// deleteInvitationHandler 撤销邀请
+// @Summary      撤销邀请
+// @Description  删除邀请, 令牌随即失效
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "邀请 ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /invitations/{id} [delete]
+func deleteInvitationHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid invitation id"})
+			return
+		}
+
+		deleted, err := store.DeleteInvitation(c.Request.Context(), uint(id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !deleted {
+			c.JSON(http.StatusNotFound, gin.H{"error": "invitation not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "invitation revoked"})
+	}
+}
+
+// acceptInvitationHandler 接受邀请并注册
+// @Summary      接受邀请
+// @Description  使用邀请令牌注册新用户, 返回 JWT token。邀请指定了邮箱时必须使用该邮箱
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body model.AcceptInvitationRequest true "注册信息"
+// @Success      201 {object} model.LoginResponse
+// @Failure      400 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      410 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /auth/invitations/accept [post]
+func acceptInvitationHandler(s store.Store, jwtManager *auth.JWTManager, mode auth.RegistrationMode, policy *auth.PasswordPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mode == auth.RegistrationDisabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "self-registration is disabled"})
+			return
+		}
+
+		var req model.AcceptInvitationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		invitation, err := s.GetInvitationByTokenHash(c.Request.Context(), auth.HashToken(req.Token))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if invitation == nil || !invitation.IsUsable(time.Now()) {
+			c.JSON(http.StatusGone, gin.H{"error": store.ErrInvitationUnavailable.Error()})
+			return
+		}
+
+		email := req.Email
+		if invitation.Email != "" {
+			if email != "" && email != invitation.Email {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "email does not match the invitation"})
+				return
+			}
+			email = invitation.Email
+		}
+		if email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+			return
+		}
+
+		// 新用户加入邀请所属的组织, 后续操作和审计都记入该组织
+		auth.BindOrganization(c, invitation.OrganizationID)
+
+		if !validatePassword(c, policy, req.Password, req.Username) {
+			return
+		}
+		if !ensureUserAvailable(c, s, req.Username, email) {
+			return
+		}
+
+		user := &model.User{
+			Username: req.Username,
+			Email:    email,
+			Password: req.Password, // BeforeCreate hook 会自动哈希密码
+			Role:     invitation.Role,
+			IsActive: true,
+
+			OrganizationID: invitation.OrganizationID,
+		}
+		if err := s.AcceptInvitation(c.Request.Context(), invitation, user); err != nil {
+			if errors.Is(err, store.ErrInvitationUnavailable) {
+				c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+			return
+		}
+
+		completeLogin(c, s, jwtManager, policy, user, http.StatusCreated)
+	}
+}
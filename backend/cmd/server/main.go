@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -16,12 +18,21 @@ import (
 	swaggerFiles "github.com/swaggo/files"
 	"go.uber.org/zap"
 
+	"github.com/cc1024201/opamp-platform/internal/agentgc"
+	"github.com/cc1024201/opamp-platform/internal/alerting"
+	"github.com/cc1024201/opamp-platform/internal/audit"
 	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/fleetstats"
 	"github.com/cc1024201/opamp-platform/internal/metrics"
+	"github.com/cc1024201/opamp-platform/internal/middleware"
+	"github.com/cc1024201/opamp-platform/internal/model"
 	"github.com/cc1024201/opamp-platform/internal/opamp"
 	"github.com/cc1024201/opamp-platform/internal/packagemgr"
+	"github.com/cc1024201/opamp-platform/internal/retention"
 	"github.com/cc1024201/opamp-platform/internal/storage"
 	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+	"github.com/cc1024201/opamp-platform/internal/webhook"
 	_ "github.com/cc1024201/opamp-platform/docs" // Swagger 文档
 )
 
@@ -46,6 +57,10 @@ import (
 // @description Type "Bearer" followed by a space and JWT token.
 
 func main() {
+	// --dev 使用内存存储和内存文件存储启动, 不需要 PostgreSQL 和 MinIO
+	devMode := flag.Bool("dev", false, "run with in-memory store and file storage, data is lost on exit")
+	flag.Parse()
+
 	// 初始化日志
 	logger, err := zap.NewDevelopment()
 	if err != nil {
@@ -62,6 +77,10 @@ func main() {
 	if err := viper.ReadInConfig(); err != nil {
 		logger.Fatal("Failed to read config", zap.Error(err))
 	}
+	if *devMode {
+		viper.Set("database.driver", storeDriverMemory)
+		logger.Warn("Development mode enabled, using in-memory store and file storage")
+	}
 
 	// 初始化数据库
 	dbConfig := postgres.Config{
@@ -71,18 +90,58 @@ func main() {
 		Password: viper.GetString("database.password"),
 		DBName:   viper.GetString("database.dbname"),
 		SSLMode:  "disable",
+
+		MigrateOnStart: viper.GetBool("database.migrate_on_start"),
 	}
 
-	store, err := postgres.NewStore(dbConfig, logger)
+	// server migrate ... 只执行数据库迁移
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if storeDriver() != storeDriverPostgres {
+			logger.Fatal("The migrate command only supports the postgres driver, the sqlite schema is created on start")
+		}
+		if err := runMigrateCommand(dbConfig, logger, args[1:]); err != nil {
+			logger.Fatal("Migration failed", zap.Error(err))
+		}
+		return
+	}
+
+	store, err := openStore(dbConfig, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize store", zap.Error(err))
 	}
 	defer store.Close()
 
+	// 上次退出时仍在执行的批量任务不会继续执行
+	if n, err := store.InterruptRunningBulkJobs(context.Background()); err != nil {
+		logger.Error("Failed to mark interrupted bulk jobs", zap.Error(err))
+	} else if n > 0 {
+		logger.Warn("Marked unfinished bulk jobs as interrupted", zap.Int64("count", n))
+	}
+
+	// 创建事件总线
+	eventBus := events.NewBus(viper.GetInt("events.buffer_size"))
+
+	// 初始化 Metrics
+	appMetrics := metrics.NewMetrics("opamp_platform")
+
+	// 审计日志
+	auditRecorder := audit.NewRecorder(store, logger)
+
 	// 创建 OpAMP 服务器
 	opampConfig := opamp.Config{
 		Endpoint:  viper.GetString("opamp.endpoint"),
 		SecretKey: viper.GetString("opamp.secret_key"),
+		EventBus:  eventBus,
+		Metrics:   appMetrics,
+		Auditor:   auditRecorder,
+		Flapping: opamp.FlappingConfig{
+			Enabled:            viper.GetBool("opamp.flapping.enabled"),
+			CheckInterval:      viper.GetDuration("opamp.flapping.check_interval"),
+			Window:             viper.GetDuration("opamp.flapping.window"),
+			MaxConnections:     viper.GetInt("opamp.flapping.max_connections"),
+			MinSessionDuration: viper.GetDuration("opamp.flapping.min_session_duration"),
+			HoldConfig:         viper.GetBool("opamp.flapping.hold_config"),
+		},
 	}
 
 	opampServer, err := opamp.NewServer(opampConfig, store, logger)
@@ -104,40 +163,180 @@ func main() {
 	}
 	jwtDuration := viper.GetDuration("jwt.duration")
 	if jwtDuration == 0 {
-		jwtDuration = 24 * time.Hour // 默认 24 小时
+		jwtDuration = 15 * time.Minute // 默认 15 分钟, 通过刷新令牌续期
 	}
 	jwtManager := auth.NewJWTManager(jwtSecretKey, jwtDuration)
+	jwtManager.SetRefreshTokenDuration(viper.GetDuration("jwt.refresh_duration"))
+	jwtManager.SetRevocationChecker(auth.NewRevocationChecker(store))
+	authorizer := auth.NewAuthorizer(store)
+	apiTokenVerifier := auth.NewAPITokenVerifier(store)
 
-	// 初始化 MinIO 客户端
-	minioConfig := storage.Config{
-		Endpoint:  viper.GetString("minio.endpoint"),
-		AccessKey: viper.GetString("minio.access_key"),
-		SecretKey: viper.GetString("minio.secret_key"),
-		Bucket:    viper.GetString("minio.bucket"),
-		UseSSL:    viper.GetBool("minio.use_ssl"),
+	// 注册模式和邀请有效期
+	registrationMode, err := auth.ParseRegistrationMode(viper.GetString("auth.registration"))
+	if err != nil {
+		logger.Fatal("Invalid registration mode", zap.Error(err))
 	}
-	minioClient, err := storage.NewMinIOClient(minioConfig, logger)
+	invitationTTL := viper.GetDuration("auth.invitation_ttl")
+	if invitationTTL == 0 {
+		invitationTTL = 72 * time.Hour // 默认 3 天
+	}
+
+	// 密码策略
+	passwordPolicy, err := auth.NewPasswordPolicy(auth.PasswordPolicyConfig{
+		MinLength:             viper.GetInt("auth.password.min_length"),
+		RequireUpper:          viper.GetBool("auth.password.require_upper"),
+		RequireLower:          viper.GetBool("auth.password.require_lower"),
+		RequireDigit:          viper.GetBool("auth.password.require_digit"),
+		RequireSymbol:         viper.GetBool("auth.password.require_symbol"),
+		MaxAge:                viper.GetDuration("auth.password.max_age"),
+		BreachedPasswordsFile: viper.GetString("auth.password.breached_list"),
+	})
 	if err != nil {
-		logger.Fatal("Failed to initialize MinIO client", zap.Error(err))
+		logger.Fatal("Failed to load password policy", zap.Error(err))
+	}
+	if passwordPolicy.BreachedCount() > 0 {
+		logger.Info("Breached password list loaded", zap.Int("count", passwordPolicy.BreachedCount()))
 	}
 
-	// 初始化 Package Manager
-	packageManager := packagemgr.NewManager(store, minioClient, logger)
+	// 登录失败限制
+	loginGuard := auth.NewLoginGuard(auth.LockoutConfig{
+		MaxAttempts:     viper.GetInt("auth.lockout.max_attempts"),
+		IPMaxAttempts:   viper.GetInt("auth.lockout.ip_max_attempts"),
+		Window:          viper.GetDuration("auth.lockout.window"),
+		LockoutDuration: viper.GetDuration("auth.lockout.duration"),
+		BaseDelay:       viper.GetDuration("auth.lockout.base_delay"),
+		MaxDelay:        viper.GetDuration("auth.lockout.max_delay"),
+	})
+
+	// TOTP 认证器应用中显示的发行方名称
+	mfaIssuer := viper.GetString("auth.mfa.issuer")
+	if mfaIssuer == "" {
+		mfaIssuer = "OpAMP Platform"
+	}
 
-	// 初始化 Metrics
-	appMetrics := metrics.NewMetrics("opamp_platform")
+	// OIDC 单点登录, 本地密码登录默认保留作为后备
+	localLogin := !viper.IsSet("auth.local_login") || viper.GetBool("auth.local_login")
+	var oidcProvider *auth.OIDCProvider
+	if viper.GetBool("auth.oidc.enabled") {
+		var roleMapping []auth.OIDCRoleMapping
+		if err := viper.UnmarshalKey("auth.oidc.role_mapping", &roleMapping); err != nil {
+			logger.Fatal("Invalid OIDC role mapping", zap.Error(err))
+		}
+		oidcProvider, err = auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+			Issuer:       viper.GetString("auth.oidc.issuer"),
+			ClientID:     viper.GetString("auth.oidc.client_id"),
+			ClientSecret: viper.GetString("auth.oidc.client_secret"),
+			RedirectURL:  viper.GetString("auth.oidc.redirect_url"),
+			Scopes:       viper.GetStringSlice("auth.oidc.scopes"),
+			GroupsClaim:  viper.GetString("auth.oidc.groups_claim"),
+			RoleMapping:  roleMapping,
+			DefaultRole:  viper.GetString("auth.oidc.default_role"),
+			StateSecret:  jwtSecretKey,
+		})
+		if err != nil {
+			logger.Fatal("Failed to initialize OIDC provider", zap.Error(err))
+		}
+		logger.Info("OIDC single sign-on enabled", zap.String("issuer", viper.GetString("auth.oidc.issuer")))
+	} else if !localLogin {
+		logger.Fatal("auth.local_login is disabled but OIDC is not enabled, nobody could sign in")
+	}
+
+	// 初始化 MinIO 客户端, 开发模式下软件包保存在内存中
+	var fileStorage packagemgr.FileStorage
+	if *devMode {
+		fileStorage = storage.NewMemoryStorage()
+	} else {
+		minioConfig := storage.Config{
+			Endpoint:  viper.GetString("minio.endpoint"),
+			AccessKey: viper.GetString("minio.access_key"),
+			SecretKey: viper.GetString("minio.secret_key"),
+			Bucket:    viper.GetString("minio.bucket"),
+			UseSSL:    viper.GetBool("minio.use_ssl"),
+		}
+		minioClient, err := storage.NewMinIOClient(minioConfig, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize MinIO client", zap.Error(err))
+		}
+		fileStorage = minioClient
+	}
+
+	// 初始化 Package Manager
+	packageManager := packagemgr.NewManager(store, fileStorage, logger)
+	packageManager.SetQuotaStore(store)
+
+	// 启动 Webhook 投递器
+	webhookDispatcher := webhook.NewDispatcher(store, eventBus, logger, webhook.Config{
+		Workers:        viper.GetInt("webhooks.workers"),
+		MaxAttempts:    viper.GetInt("webhooks.max_attempts"),
+		InitialBackoff: viper.GetDuration("webhooks.initial_backoff"),
+		MaxBackoff:     viper.GetDuration("webhooks.max_backoff"),
+		Timeout:        viper.GetDuration("webhooks.timeout"),
+	})
+	webhookDispatcher.Start(ctx)
+
+	// 启动告警规则引擎
+	alertEngine := alerting.NewEngine(store, logger,
+		viper.GetDuration("alerting.evaluation_interval"),
+		alerting.NewLogNotifier(logger),
+		alerting.NewEventNotifier(eventBus),
+	)
+	alertEngine.Start(ctx)
+
+	// 启动 Agent 分组统计采样
+	fleetSampler := fleetstats.NewSampler(store, logger, fleetstats.Config{
+		Interval:  viper.GetDuration("fleet_stats.sample_interval"),
+		Retention: viper.GetDuration("fleet_stats.retention"),
+		LabelKeys: viper.GetStringSlice("fleet_stats.label_keys"),
+	})
+	fleetSampler.Start(ctx)
+
+	// 启动离线 Agent 垃圾回收
+	agentCollector := agentgc.NewCollector(store, logger, auditRecorder, agentgc.Config{
+		Interval:     viper.GetDuration("opamp.gc.check_interval"),
+		OfflineAfter: viper.GetDuration("opamp.gc.offline_after"),
+	})
+	agentCollector.Start(ctx)
+
+	// 启动历史记录清理
+	var retentionPolicies []model.RetentionPolicy
+	for _, table := range model.RetentionTables {
+		key := "retention." + string(table)
+		policy := model.RetentionPolicy{
+			Table:         table,
+			MaxAgeSeconds: int64(viper.GetDuration(key+".max_age").Seconds()),
+			MaxRows:       viper.GetInt(key + ".max_rows"),
+			KeepLast:      viper.GetInt(key + ".keep_last"),
+		}
+		if err := policy.Validate(); err != nil {
+			logger.Fatal("Invalid retention policy", zap.String("table", string(table)), zap.Error(err))
+		}
+		retentionPolicies = append(retentionPolicies, policy)
+	}
+	historyPruner := retention.NewPruner(store, logger, appMetrics, retention.Config{
+		Interval:   viper.GetDuration("retention.interval"),
+		BatchSize:  viper.GetInt("retention.batch_size"),
+		BatchPause: viper.GetDuration("retention.batch_pause"),
+		Policies:   retentionPolicies,
+	})
+	historyPruner.Start(ctx)
 
 	// 创建 HTTP 服务器
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	// 只有来自可信代理的 X-Forwarded-For 才用于识别客户端 IP, 默认不信任任何代理, 防止伪造 IP 绕过登录锁定
+	if err := router.SetTrustedProxies(viper.GetStringSlice("server.trusted_proxies")); err != nil {
+		logger.Fatal("Invalid server.trusted_proxies", zap.Error(err))
+	}
 	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
+	router.Use(middleware.RequestID())
+	corsOrigins := allowedOrigins(viper.GetStringSlice("server.cors_origins"))
+	router.Use(corsMiddleware(corsOrigins))
 	router.Use(loggingMiddleware(logger))
 	router.Use(metrics.PrometheusMiddleware(appMetrics))
 
 	// 健康检查端点
-	router.GET("/health", healthCheckHandler(store.GetDB()))
-	router.GET("/health/ready", readinessHandler(store.GetDB()))
+	router.GET("/health", healthCheckHandler(store))
+	router.GET("/health/ready", readinessHandler(store))
 	router.GET("/health/live", livenessHandler())
 
 	// Prometheus metrics 端点
@@ -148,65 +347,234 @@ func main() {
 
 	// API 路由组
 	api := router.Group("/api/v1")
+	api.Use(auditRecorder.Middleware())
 	{
 		// 公开的认证相关 API（不需要 token）
 		authGroup := api.Group("/auth")
 		{
-			authGroup.POST("/login", loginHandler(store, jwtManager))
-			authGroup.POST("/register", registerHandler(store, jwtManager))
+			passwordLogin := localLoginGate(localLogin)
+			authGroup.POST("/login", passwordLogin, loginHandler(store, jwtManager, loginGuard, passwordPolicy, eventBus))
+			authGroup.POST("/password/renew", renewPasswordHandler(store, jwtManager, passwordPolicy))
+			authGroup.POST("/refresh", refreshTokenHandler(store, jwtManager))
+			authGroup.POST("/register", passwordLogin, registrationGate(store, registrationMode), registerHandler(store, jwtManager, passwordPolicy))
+			authGroup.POST("/invitations/accept", passwordLogin, acceptInvitationHandler(store, jwtManager, registrationMode, passwordPolicy))
+
+			// 两步登录
+			authGroup.POST("/mfa/verify", mfaVerifyHandler(store, jwtManager, passwordPolicy, loginGuard, eventBus))
+			authGroup.POST("/mfa/enroll", mfaLoginEnrollHandler(store, jwtManager, mfaIssuer))
+			authGroup.POST("/mfa/confirm", mfaLoginConfirmHandler(store, jwtManager, passwordPolicy))
+
+			if oidcProvider != nil {
+				secureCookie := strings.HasPrefix(viper.GetString("auth.oidc.redirect_url"), "https://")
+				postLoginRedirect := viper.GetString("auth.oidc.post_login_redirect")
+				authGroup.GET("/oidc/login", oidcLoginHandler(oidcProvider, secureCookie))
+				authGroup.GET("/oidc/callback", oidcCallbackHandler(store, jwtManager, authorizer, oidcProvider, passwordPolicy, secureCookie, postLoginRedirect))
+			}
 		}
 
 		// 需要认证的 API
 		authenticated := api.Group("")
-		authenticated.Use(auth.AuthMiddleware(jwtManager))
+		authenticated.Use(auth.AuthMiddleware(jwtManager, apiTokenVerifier))
 		{
 			// 用户信息
 			authenticated.GET("/me", meHandler(store))
+			authenticated.POST("/me/password", changePasswordHandler(store, jwtManager, passwordPolicy))
+			authenticated.POST("/auth/logout", logoutHandler(store))
+
+			// 当前用户的 MFA
+			authenticated.GET("/me/mfa", getMyMFAHandler(store))
+			authenticated.POST("/me/mfa/enroll", enrollMyMFAHandler(store, mfaIssuer))
+			authenticated.POST("/me/mfa/confirm", confirmMyMFAHandler(store))
+			authenticated.POST("/me/mfa/recovery-codes", regenerateRecoveryCodesHandler(store))
+			authenticated.POST("/me/mfa/disable", disableMyMFAHandler(store))
+
+			// 当前用户所属的组织
+			authenticated.GET("/organization", getMyOrganizationHandler(store))
+
+			// 当前用户的 API 令牌
+			authenticated.GET("/tokens", listMyAPITokensHandler(store))
+			authenticated.POST("/tokens", createMyAPITokenHandler(store))
+			authenticated.DELETE("/tokens/:id", revokeMyAPITokenHandler(store))
+
+			// 事件流
+			authenticated.GET("/events", authorizer.Require(auth.PermEventsRead), streamEventsHandler(eventBus))
+			authenticated.GET("/events/ws", authorizer.Require(auth.PermEventsRead), streamEventsWebSocketHandler(eventBus, corsOrigins))
 
 			// Agent 相关 API
 			agents := authenticated.Group("/agents")
+			agents.Use(authorizer.Require(auth.PermAgentsRead))
 			{
 				// 状态统计和分组查询(放在前面,避免被 :id 路由捕获)
 				agents.GET("/online", listOnlineAgentsHandler(store))
 				agents.GET("/offline", listOfflineAgentsHandler(store))
 				agents.GET("/status/summary", getAgentStatusSummaryHandler(store))
+				agents.GET("/availability", getFleetAvailabilityHandler(store))
+				agents.GET("/stats", getFleetStatsHandler(store))
+
+				// 按选择器批量修改服务端标签
+				agents.POST("/labels", authorizer.Require(auth.PermAgentsWrite), bulkAgentLabelsHandler(store, opampServer))
 
-				// Agent CRUD
+				// 批量操作 (delete 和 assign_configuration 操作在处理器中额外检查权限)
+				agents.POST("/bulk", authorizer.Require(auth.PermAgentsWrite), bulkAgentsHandler(store, opampServer, authorizer, logger))
+				agents.GET("/bulk/jobs", listBulkJobsHandler(store))
+				agents.GET("/bulk/jobs/:id", getBulkJobHandler(store))
+
+				// Agent 列表
 				agents.GET("", listAgentsHandler(store))
-				agents.GET("/:id", getAgentHandler(store))
-				agents.DELETE("/:id", deleteAgentHandler(store))
 
-				// Agent 状态和历史
-				agents.GET("/:id/apply-history", getAgentApplyHistoryHandler(store))
-				agents.GET("/:id/connection-history", getAgentConnectionHistoryHandler(store))
-				agents.GET("/:id/active-connection", getAgentActiveConnectionHandler(store))
+				// 单个 Agent 的路由需检查角色范围
+				agent := agents.Group("/:id")
+				agent.Use(requireAgentScope(store))
+				{
+					agent.GET("", getAgentHandler(store))
+					agent.DELETE("", authorizer.Require(auth.PermAgentsDelete), deleteAgentHandler(store))
+					agent.POST("/decommission", authorizer.Require(auth.PermAgentsDelete), decommissionAgentHandler(store, opampServer))
+
+					// 服务端标签
+					agent.PUT("/labels", authorizer.Require(auth.PermAgentsWrite), setAgentLabelsHandler(store, opampServer))
+					agent.PATCH("/labels", authorizer.Require(auth.PermAgentsWrite), patchAgentLabelsHandler(store, opampServer))
+
+					// 指定使用的配置
+					agent.PUT("/configuration", authorizer.Require(auth.PermAgentsWrite, auth.PermConfigurationsPush), pinAgentConfigurationHandler(store, opampServer))
+					agent.DELETE("/configuration", authorizer.Require(auth.PermAgentsWrite, auth.PermConfigurationsPush), unpinAgentConfigurationHandler(store, opampServer))
+
+					// Agent 状态和历史
+					agent.GET("/apply-history", getAgentApplyHistoryHandler(store))
+					agent.GET("/connection-history", getAgentConnectionHistoryHandler(store))
+					agent.GET("/active-connection", getAgentActiveConnectionHandler(store))
+					agent.GET("/availability", getAgentAvailabilityHandler(store))
+				}
 			}
 
 			// Configuration 相关 API
 			configs := authenticated.Group("/configurations")
+			configs.Use(authorizer.Require(auth.PermConfigurationsRead))
 			{
+				configWrite := authorizer.Require(auth.PermConfigurationsWrite)
+
 				configs.GET("", listConfigurationsHandler(store))
 				configs.GET("/:name", getConfigurationHandler(store))
-				configs.POST("", createConfigurationHandler(store))
-				configs.PUT("/:name", updateConfigurationHandler(store))
-				configs.DELETE("/:name", deleteConfigurationHandler(store))
+				configs.POST("", configWrite, createConfigurationHandler(store))
+				configs.PUT("/:name", configWrite, updateConfigurationHandler(store))
+				configs.DELETE("/:name", configWrite, deleteConfigurationHandler(store))
 
 				// 配置热更新相关
-				configs.POST("/:name/push", pushConfigurationHandler(store, opampServer))
+				configs.POST("/:name/push", authorizer.Require(auth.PermConfigurationsPush), pushConfigurationHandler(store, opampServer, eventBus))
 				configs.GET("/:name/history", listConfigurationHistoryHandler(store))
 				configs.GET("/:name/history/:version", getConfigurationHistoryHandler(store))
-				configs.POST("/:name/rollback/:version", rollbackConfigurationHandler(store))
+				configs.POST("/:name/rollback/:version", configWrite, rollbackConfigurationHandler(store))
 				configs.GET("/:name/apply-history", listApplyHistoryHandler(store))
 			}
 
+			// Webhook 相关 API
+			webhooks := authenticated.Group("/webhooks")
+			webhooks.Use(authorizer.Require(auth.PermWebhooksRead))
+			{
+				webhookWrite := authorizer.Require(auth.PermWebhooksWrite)
+
+				webhooks.GET("", listWebhooksHandler(store))
+				webhooks.POST("", webhookWrite, createWebhookHandler(store))
+				webhooks.GET("/:id", getWebhookHandler(store))
+				webhooks.PUT("/:id", webhookWrite, updateWebhookHandler(store))
+				webhooks.DELETE("/:id", webhookWrite, deleteWebhookHandler(store))
+				webhooks.POST("/:id/test", webhookWrite, testWebhookHandler(store, webhookDispatcher))
+				webhooks.GET("/:id/deliveries", listWebhookDeliveriesHandler(store))
+			}
+
+			// 告警相关 API
+			alerts := authenticated.Group("/alerts")
+			alerts.Use(authorizer.Require(auth.PermAlertsRead))
+			{
+				alertWrite := authorizer.Require(auth.PermAlertsWrite)
+
+				alerts.GET("", listAlertsHandler(store))
+				alerts.GET("/rules", listAlertRulesHandler(store))
+				alerts.POST("/rules", alertWrite, audit.Action("alerts.rules.create"), createAlertRuleHandler(store))
+				alerts.GET("/rules/:id", getAlertRuleHandler(store))
+				alerts.PUT("/rules/:id", alertWrite, updateAlertRuleHandler(store))
+				alerts.DELETE("/rules/:id", alertWrite, deleteAlertRuleHandler(store))
+				alerts.GET("/silences", listSilencesHandler(store))
+				alerts.POST("/silences", alertWrite, audit.Action("alerts.silences.create"), createSilenceHandler(store))
+				alerts.DELETE("/silences/:id", alertWrite, deleteSilenceHandler(store))
+			}
+
+			// 角色、用户和邀请管理 API
+			admin := authenticated.Group("")
+			admin.Use(authorizer.Require(auth.PermUsersAdmin))
+			{
+				admin.GET("/permissions", listPermissionsHandler())
+				admin.GET("/roles", listRolesHandler(store))
+				admin.POST("/roles", createRoleHandler(store))
+				admin.PUT("/roles/:name", updateRoleHandler(store))
+				admin.DELETE("/roles/:name", deleteRoleHandler(store))
+
+				// 用户管理
+				admin.GET("/users", listUsersHandler(store))
+				admin.POST("/users", createUserHandler(store, authorizer, passwordPolicy))
+				admin.GET("/users/:id", getUserHandler(store))
+				admin.DELETE("/users/:id", deleteUserHandler(store))
+				admin.PUT("/users/:id/role", updateUserRoleHandler(store, authorizer))
+				admin.POST("/users/:id/disable", disableUserHandler(store))
+				admin.POST("/users/:id/enable", enableUserHandler(store))
+				admin.POST("/users/:id/reset-password", resetUserPasswordHandler(store, passwordPolicy))
+				admin.POST("/users/:id/unlock", unlockUserHandler(store, loginGuard))
+				admin.POST("/users/:id/mfa/reset", resetUserMFAHandler(store))
+
+				// MFA 策略
+				admin.GET("/mfa/policy", getMFAPolicyHandler(store))
+				admin.PUT("/mfa/policy", updateMFAPolicyHandler(store, authorizer))
+
+				// 服务账号和 API 令牌
+				admin.POST("/service-accounts", createServiceAccountHandler(store, authorizer))
+				admin.GET("/users/:id/tokens", listUserAPITokensHandler(store))
+				admin.POST("/users/:id/tokens", audit.Action("users.tokens.create"), createUserAPITokenHandler(store))
+				admin.DELETE("/users/:id/tokens/:token_id", revokeUserAPITokenHandler(store))
+
+				// 邀请
+				admin.GET("/invitations", listInvitationsHandler(store))
+				admin.POST("/invitations", createInvitationHandler(store, authorizer, registrationMode, invitationTTL))
+				admin.DELETE("/invitations/:id", deleteInvitationHandler(store))
+			}
+
+			// 组织管理 API, 仅默认组织的管理员可用
+			organizations := authenticated.Group("/organizations")
+			organizations.Use(authorizer.Require(auth.PermOrganizationsAdmin), requirePlatformAdmin())
+			{
+				organizations.GET("", listOrganizationsHandler(store))
+				organizations.POST("", createOrganizationHandler(store, invitationTTL))
+				organizations.GET("/:id", getOrganizationHandler(store))
+				organizations.PUT("/:id", updateOrganizationHandler(store))
+				organizations.DELETE("/:id", deleteOrganizationHandler(store))
+				organizations.POST("/:id/secret-key", rotateOrganizationSecretKeyHandler(store))
+			}
+
+			// 历史记录保留策略, 作用于所有组织, 仅默认组织的管理员可用
+			retentionGroup := authenticated.Group("/retention")
+			retentionGroup.Use(authorizer.Require(auth.PermOrganizationsAdmin), requirePlatformAdmin())
+			{
+				retentionGroup.GET("/policies", getRetentionPoliciesHandler(historyPruner))
+				retentionGroup.POST("/dry-run", dryRunRetentionHandler(historyPruner))
+			}
+
+			// 审计日志
+			auditLogs := authenticated.Group("/audit")
+			auditLogs.Use(authorizer.Require(auth.PermAuditRead))
+			{
+				auditLogs.GET("", listAuditLogsHandler(store))
+				auditLogs.GET("/export", exportAuditLogsHandler(store))
+			}
+
 			// Package 相关 API
 			packages := authenticated.Group("/packages")
+			packages.Use(authorizer.Require(auth.PermPackagesRead))
 			{
+				packageWrite := authorizer.Require(auth.PermPackagesWrite)
+
 				packages.GET("", listPackagesHandler(packageManager))
-				packages.POST("", uploadPackageHandler(packageManager))
+				packages.POST("", packageWrite, uploadPackageHandler(packageManager))
 				packages.GET("/:id", getPackageHandler(packageManager))
 				packages.GET("/:id/download", downloadPackageHandler(packageManager))
-				packages.DELETE("/:id", deletePackageHandler(packageManager))
+				packages.DELETE("/:id", packageWrite, deletePackageHandler(packageManager))
 			}
 		}
 	}
@@ -240,6 +608,12 @@ func main() {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	alertEngine.Stop()
+	fleetSampler.Stop()
+	agentCollector.Stop()
+	historyPruner.Stop()
+	webhookDispatcher.Stop()
+
 	if err := opampServer.Stop(shutdownCtx); err != nil {
 		logger.Error("OpAMP server shutdown error", zap.Error(err))
 	}
@@ -251,12 +625,20 @@ func main() {
 	logger.Info("Server stopped")
 }
 
-// CORS 中间件
-func corsMiddleware() gin.HandlerFunc {
+// CORS 中间件, 只有 origins 中的来源可以跨域访问
+func corsMiddleware(origins allowedOrigins) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		if origin := c.GetHeader("Origin"); origin != "" && origins.allows(origin) {
+			if origins.wildcard() {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+				c.Writer.Header().Add("Vary", "Origin")
+			}
+		}
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Last-Event-ID, X-Request-ID, If-Match")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -289,6 +671,7 @@ func loggingMiddleware(logger *zap.Logger) gin.HandlerFunc {
 			zap.Int("status", statusCode),
 			zap.Duration("latency", latency),
 			zap.String("client_ip", c.ClientIP()),
+			zap.String("request_id", middleware.GetRequestID(c)),
 		)
 	}
 }
@@ -0,0 +1,685 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// mfaVerifyHandler 两步登录第二步: 校验验证码或恢复码
+// @Summary      校验 MFA
+// @Description  使用登录返回的 MFA 令牌和 TOTP 验证码 (或一次性恢复码) 完成登录, 返回访问令牌和刷新令牌。验证失败与密码错误一样计入登录失败次数
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body model.MFAVerifyRequest true "MFA 令牌和验证码"
+// @Success      200 {object} model.LoginResponse
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      429 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /auth/mfa/verify [post]
+func mfaVerifyHandler(store store.Store, jwtManager *auth.JWTManager, policy *auth.PasswordPolicy, guard *auth.LoginGuard, bus *events.Bus) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.MFAVerifyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := loadMFAChallengeUser(c, store, jwtManager, req.MFAToken, auth.PurposeMFAVerify)
+		if !ok {
+			return
+		}
+		if !checkLoginAllowed(c, guard, user.Username) {
+			return
+		}
+		if req.Code == "" && req.RecoveryCode == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+			return
+		}
+
+		valid, err := checkMFACode(c.Request.Context(), store, user, req.Code, req.RecoveryCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return
+		}
+		if !valid {
+			recordLoginFailure(c, guard, bus, user.Username)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid verification code"})
+			return
+		}
+		guard.RecordSuccess(user.Username)
+
+		result, ok := finishLogin(c, store, jwtManager, policy, user)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// mfaLoginEnrollHandler 登录过程中开始绑定 MFA
+// @Summary      登录时绑定 MFA
+// @Description  用户的角色要求 MFA 但尚未绑定时, 使用登录返回的 MFA 令牌生成 TOTP 密钥
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body model.MFAEnrollRequest true "MFA 令牌"
+// @Success      200 {object} model.MFAEnrollmentResponse
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /auth/mfa/enroll [post]
+func mfaLoginEnrollHandler(store store.Store, jwtManager *auth.JWTManager, issuer string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.MFAEnrollRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := loadMFAChallengeUser(c, store, jwtManager, req.MFAToken, auth.PurposeMFAEnroll)
+		if !ok {
+			return
+		}
+		startMFAEnrollment(c, store, user, issuer)
+	}
+}
+
+// mfaLoginConfirmHandler 登录过程中确认绑定 MFA 并完成登录
+// @Summary      登录时确认绑定 MFA
+// @Description  提交认证器应用中的验证码确认绑定, 返回一次性恢复码以及访问令牌和刷新令牌。恢复码只返回一次
+// @Tags         auth
+// @Accept       json
+// @Produce      json
+// @Param        request body model.MFAConfirmRequest true "MFA 令牌和验证码"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /auth/mfa/confirm [post]
+func mfaLoginConfirmHandler(store store.Store, jwtManager *auth.JWTManager, policy *auth.PasswordPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.MFAConfirmRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := loadMFAChallengeUser(c, store, jwtManager, req.MFAToken, auth.PurposeMFAEnroll)
+		if !ok {
+			return
+		}
+		codes, ok := confirmMFAEnrollment(c, store, user, req.Code)
+		if !ok {
+			return
+		}
+
+		result, ok := finishLogin(c, store, jwtManager, policy, user)
+		if !ok {
+			return
+		}
+
+		response := gin.H{"recovery_codes": codes}
+		switch r := result.(type) {
+		case *model.LoginResponse:
+			response["token"] = r.Token
+			response["refresh_token"] = r.RefreshToken
+			response["expires_in"] = r.ExpiresIn
+			response["user"] = r.User
+		case *model.PasswordExpiredResponse:
+			response["password_expired"] = r.PasswordExpired
+			response["password_token"] = r.PasswordToken
+			response["expires_in"] = r.ExpiresIn
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// getMyMFAHandler 获取当前用户的 MFA 状态
+// @Summary      获取我的 MFA 状态
+// @Description  返回是否已启用 MFA, 角色是否要求 MFA 以及剩余恢复码数量
+// @Tags         mfa
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} model.MFAStatus
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /me/mfa [get]
+func getMyMFAHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadCurrentUser(c, store)
+		if !ok {
+			return
+		}
+
+		ctx := c.Request.Context()
+		required, err := store.IsMFARequiredForRole(ctx, user.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		status := model.MFAStatus{Enabled: user.MFAEnabled, Required: required}
+		if user.MFAEnabled {
+			status.RecoveryCodesRemaining, err = store.CountUnusedRecoveryCodes(ctx, user.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+// enrollMyMFAHandler 当前用户开始绑定 MFA
+// @Summary      绑定 MFA
+// @Description  生成 TOTP 密钥和 otpauth:// URI (可生成二维码), 使用验证码确认后生效。重复调用会生成新的密钥
+// @Tags         mfa
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} model.MFAEnrollmentResponse
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /me/mfa/enroll [post]
+func enrollMyMFAHandler(store store.Store, issuer string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadCurrentUser(c, store)
+		if !ok {
+			return
+		}
+		startMFAEnrollment(c, store, user, issuer)
+	}
+}
+
+// confirmMyMFAHandler 当前用户确认绑定 MFA
+// @Summary      确认绑定 MFA
+// @Description  提交认证器应用中的验证码确认绑定, 返回一次性恢复码。恢复码只返回一次
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body model.MFACodeRequest true "验证码"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /me/mfa/confirm [post]
+func confirmMyMFAHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.MFACodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := loadCurrentUser(c, store)
+		if !ok {
+			return
+		}
+		codes, ok := confirmMFAEnrollment(c, store, user, req.Code)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "mfa enabled", "recovery_codes": codes})
+	}
+}
+
+// regenerateRecoveryCodesHandler 重新生成恢复码
+// @Summary      重新生成恢复码
+// @Description  需要当前验证码, 旧的恢复码全部失效。新的恢复码只返回一次
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body model.MFACodeRequest true "验证码"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /me/mfa/recovery-codes [post]
+func regenerateRecoveryCodesHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.MFACodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := loadCurrentUser(c, store)
+		if !ok {
+			return
+		}
+		if !user.MFAEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is not enabled"})
+			return
+		}
+		if !verifyMFACode(c, store, user, req.Code, "") {
+			return
+		}
+
+		codes, ok := replaceRecoveryCodes(c, store, user)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// disableMyMFAHandler 当前用户关闭 MFA
+// @Summary      关闭 MFA
+// @Description  需要当前验证码; 角色要求 MFA 时不能关闭
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request body model.MFACodeRequest true "验证码"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /me/mfa/disable [post]
+func disableMyMFAHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.MFACodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		user, ok := loadCurrentUser(c, store)
+		if !ok {
+			return
+		}
+		if !user.MFAEnabled {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is not enabled"})
+			return
+		}
+
+		required, err := store.IsMFARequiredForRole(c.Request.Context(), user.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if required {
+			c.JSON(http.StatusForbidden, gin.H{"error": "mfa is required for your role"})
+			return
+		}
+		if !verifyMFACode(c, store, user, req.Code, "") {
+			return
+		}
+
+		if !clearMFA(c, store, user) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "mfa disabled"})
+	}
+}
+
+// resetUserMFAHandler 管理员重置用户的 MFA
+// @Summary      重置用户 MFA
+// @Description  用户丢失认证器时由管理员清除其 MFA 绑定和恢复码, 并使其已签发的令牌失效; 角色要求 MFA 时用户下次登录需要重新绑定
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Success      200 {object} model.User
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id}/mfa/reset [post]
+func resetUserMFAHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadManagedUser(c, store)
+		if !ok {
+			return
+		}
+
+		user.MFAEnabled = false
+		user.MFASecret = ""
+		if err := store.ReplaceRecoveryCodes(c.Request.Context(), user.ID, nil); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !invalidateUserSessions(c, store, user) {
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// getMFAPolicyHandler 获取 MFA 策略
+// @Summary      获取 MFA 策略
+// @Description  列出要求用户启用 MFA 的角色
+// @Tags         mfa
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /mfa/policy [get]
+func getMFAPolicyHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, err := store.ListMFARequiredRoles(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"roles": roles})
+	}
+}
+
+// updateMFAPolicyHandler 设置 MFA 策略
+// @Summary      设置 MFA 策略
+// @Description  设置要求用户启用 MFA 的角色。这些角色的用户下次登录时必须先绑定 MFA, 未绑定的用户刷新令牌会失败
+// @Tags         mfa
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        policy body model.MFAPolicyRequest true "要求 MFA 的角色"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /mfa/policy [put]
+func updateMFAPolicyHandler(store store.Store, authorizer *auth.Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.MFAPolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		seen := make(map[string]bool, len(req.Roles))
+		roles := make([]string, 0, len(req.Roles))
+		for _, name := range req.Roles {
+			role, err := authorizer.ResolveRole(c.Request.Context(), name)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if role == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role: " + name})
+				return
+			}
+			if !seen[name] {
+				seen[name] = true
+				roles = append(roles, name)
+			}
+		}
+
+		if err := store.SetMFARequiredRoles(c.Request.Context(), roles); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"roles": roles})
+	}
+}
+
+// completeLogin 完成密码校验后的登录
+//
+// 用户已启用 MFA, 或角色要求 MFA 但尚未绑定时, 返回短期 MFA 挑战令牌而不是访问令牌。
+func completeLogin(c *gin.Context, store store.Store, jwtManager *auth.JWTManager, policy *auth.PasswordPolicy, user *model.User, status int) {
+	challenge, ok := loginChallenge(c, store, jwtManager, user)
+	if !ok {
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	result, ok := finishLogin(c, store, jwtManager, policy, user)
+	if !ok {
+		return
+	}
+	if _, expired := result.(*model.PasswordExpiredResponse); expired {
+		status = http.StatusOK
+	}
+	c.JSON(status, result)
+}
+
+// loginChallenge 检查登录是否需要第二步验证, 需要时返回 MFA 挑战, 不需要时返回 nil
+//
+// 本地密码登录和 OIDC 单点登录都要经过这一步, IdP 的认证不能替代平台的 MFA 策略。
+func loginChallenge(c *gin.Context, store store.Store, jwtManager *auth.JWTManager, user *model.User) (*model.MFAChallengeResponse, bool) {
+	// MFA 策略按组织配置
+	auth.BindOrganization(c, user.OrganizationID)
+
+	purpose := ""
+	if user.MFAEnabled {
+		purpose = auth.PurposeMFAVerify
+	} else {
+		required, err := store.IsMFARequiredForRole(c.Request.Context(), user.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+			return nil, false
+		}
+		if required {
+			purpose = auth.PurposeMFAEnroll
+		}
+	}
+	if purpose == "" {
+		return nil, true
+	}
+
+	token, err := jwtManager.GenerateMFAChallenge(user, purpose)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return nil, false
+	}
+	return &model.MFAChallengeResponse{
+		MFARequired:        true,
+		EnrollmentRequired: purpose == auth.PurposeMFAEnroll,
+		MFAToken:           token,
+		ExpiresIn:          int64(auth.MFAChallengeDuration / time.Second),
+	}, true
+}
+
+// finishLogin 在密码和 MFA 校验都通过后签发令牌
+//
+// 本地用户的密码已过期时不签发访问令牌, 而是返回修改密码令牌。
+func finishLogin(c *gin.Context, store store.Store, jwtManager *auth.JWTManager, policy *auth.PasswordPolicy, user *model.User) (interface{}, bool) {
+	if !user.IsExternal() && policy.IsExpired(user.PasswordSetAt(), time.Now()) {
+		token, err := jwtManager.GenerateMFAChallenge(user, auth.PurposePasswordChange)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+			return nil, false
+		}
+		return &model.PasswordExpiredResponse{
+			PasswordExpired: true,
+			PasswordToken:   token,
+			ExpiresIn:       int64(auth.MFAChallengeDuration / time.Second),
+		}, true
+	}
+
+	response, ok := issueTokens(c, store, jwtManager, user, "")
+	if !ok {
+		return nil, false
+	}
+	return response, true
+}
+
+// loadMFAChallengeUser 校验 MFA 挑战令牌并加载对应用户, 失败时写入错误响应
+func loadMFAChallengeUser(c *gin.Context, store store.Store, jwtManager *auth.JWTManager, token, purpose string) (*model.User, bool) {
+	claims, err := jwtManager.VerifyMFAChallenge(token, purpose)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token"})
+		return nil, false
+	}
+
+	user, err := store.GetUserByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	// 签发挑战令牌后用户被禁用或重置了密码, 挑战令牌随之失效
+	if user == nil || !user.IsActive || user.TokenVersion != claims.TokenVersion {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token"})
+		return nil, false
+	}
+	return user, true
+}
+
+// loadCurrentUser 加载当前登录的用户, 使用 API 令牌认证时拒绝请求
+func loadCurrentUser(c *gin.Context, store store.Store) (*model.User, bool) {
+	claims, exists := auth.GetCurrentUser(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return nil, false
+	}
+	if claims.APITokenID != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API tokens cannot be used to manage mfa"})
+		return nil, false
+	}
+
+	user, err := store.GetUserByID(c.Request.Context(), claims.UserID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	return user, true
+}
+
+// verifyMFACode 校验 TOTP 验证码或恢复码, 失败时写入错误响应
+func verifyMFACode(c *gin.Context, store store.Store, user *model.User, code, recoveryCode string) bool {
+	if code == "" && recoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code or recovery_code is required"})
+		return false
+	}
+
+	ok, err := checkMFACode(c.Request.Context(), store, user, code, recoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid verification code"})
+		return false
+	}
+	return true
+}
+
+// checkMFACode 校验 TOTP 验证码, 未提供验证码时校验恢复码; 验证码或恢复码无效时返回 false
+func checkMFACode(ctx context.Context, store store.Store, user *model.User, code, recoveryCode string) (bool, error) {
+	if code == "" {
+		return store.UseRecoveryCode(ctx, user.ID, auth.HashRecoveryCode(recoveryCode))
+	}
+
+	step, ok := auth.ValidateTOTP(user.MFASecret, code, time.Now(), user.MFALastStep)
+	if !ok {
+		return false, nil
+	}
+	// 并发请求使用同一个验证码时只有一个能成功
+	advanced, err := store.AdvanceMFAStep(ctx, user.ID, step)
+	if err != nil || !advanced {
+		return false, err
+	}
+	user.MFALastStep = step
+	return true, nil
+}
+
+// startMFAEnrollment 为用户生成新的 TOTP 密钥, 确认前不会启用
+func startMFAEnrollment(c *gin.Context, store store.Store, user *model.User, issuer string) {
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa is already enabled"})
+		return
+	}
+	if user.IsServiceAccount || user.IsExternal() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is not available for this account"})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+		return
+	}
+	user.MFASecret = secret
+	if err := store.UpdateUser(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.MFAEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(issuer, user.Username, secret),
+	})
+}
+
+// confirmMFAEnrollment 校验验证码后启用 MFA 并生成恢复码
+func confirmMFAEnrollment(c *gin.Context, store store.Store, user *model.User, code string) ([]string, bool) {
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa is already enabled"})
+		return nil, false
+	}
+	if user.MFASecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa enrollment has not been started"})
+		return nil, false
+	}
+	if !verifyMFACode(c, store, user, code, "") {
+		return nil, false
+	}
+
+	codes, ok := replaceRecoveryCodes(c, store, user)
+	if !ok {
+		return nil, false
+	}
+	user.MFAEnabled = true
+	if err := store.UpdateUser(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return codes, true
+}
+
+// replaceRecoveryCodes 生成新的恢复码替换旧的恢复码, 返回明文
+func replaceRecoveryCodes(c *gin.Context, store store.Store, user *model.User) ([]string, bool) {
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate recovery codes"})
+		return nil, false
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+	if err := store.ReplaceRecoveryCodes(c.Request.Context(), user.ID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return codes, true
+}
+
+// clearMFA 关闭用户的 MFA 并删除恢复码
+func clearMFA(c *gin.Context, store store.Store, user *model.User) bool {
+	if err := store.ReplaceRecoveryCodes(c.Request.Context(), user.ID, nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	user.MFAEnabled = false
+	user.MFASecret = ""
+	if err := store.UpdateUser(c.Request.Context(), user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
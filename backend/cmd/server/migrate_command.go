@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/migrate"
+	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+)
+
+// migrateUsage migrate 子命令的用法
+const migrateUsage = "usage: server migrate <up|down [steps]|status|force <version>>"
+
+// runMigrateCommand 执行 migrate 子命令
+//
+//	server migrate up               执行所有未执行的迁移, 由 AutoMigrate 创建的数据库先标记版本
+//	server migrate down [steps]     回滚最近 steps 个迁移, 默认 1
+//	server migrate status           显示当前版本和未执行的迁移
+//	server migrate force <version>  将数据库标记为指定版本, 不执行迁移
+func runMigrateCommand(dbConfig postgres.Config, logger *zap.Logger, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	store, err := postgres.Connect(dbConfig, logger)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	migrator, err := store.Migrator()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		if err := store.Baseline(ctx, migrator); err != nil {
+			return err
+		}
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no change")
+			return nil
+		}
+		fmt.Printf("applied %d migration(s), now at version %d\n", len(applied), applied[len(applied)-1].Version)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				return fmt.Errorf("invalid steps: %s", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if errors.Is(err, migrate.ErrNoChange) {
+			fmt.Println("no change")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("reverted %d migration(s)\n", len(reverted))
+
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("current: %d\nlatest:  %d\ndirty:   %t\n", status.Current, status.Latest, status.Dirty)
+		for _, m := range status.Pending {
+			fmt.Printf("pending: %06d_%s\n", m.Version, m.Name)
+		}
+
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseUint(args[1], 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", args[1])
+		}
+		if err := migrator.Force(ctx, uint(version)); err != nil {
+			return err
+		}
+		fmt.Printf("forced version %d\n", version)
+
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+const (
+	// oidcFlowCookie 保存 OIDC 登录流程状态的 cookie
+	oidcFlowCookie = "oidc_flow"
+	// oidcCookiePath cookie 只在 OIDC 端点上发送
+	oidcCookiePath = "/api/v1/auth/oidc"
+	// oidcCookieMaxAge 与登录流程有效期一致 (秒)
+	oidcCookieMaxAge = 600
+)
+
+// oidcLoginHandler 发起 OIDC 单点登录
+// @Summary      OIDC 单点登录
+// @Description  跳转到 IdP 进行授权码 + PKCE 登录, 登录流程状态保存在签名 cookie 中
+// @Tags         auth
+// @Success      302
+// @Failure      500 {object} map[string]string
+// @Router       /auth/oidc/login [get]
+func oidcLoginHandler(provider *auth.OIDCProvider, secureCookie bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		flow, err := provider.NewFlow()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+			return
+		}
+		sealed, err := provider.SealFlow(flow)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+			return
+		}
+
+		// IdP 回调是跨站跳转, 需要 Lax 才能带上 cookie
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oidcFlowCookie, sealed, oidcCookieMaxAge, oidcCookiePath, "", secureCookie, true)
+		c.Redirect(http.StatusFound, provider.AuthCodeURL(flow))
+	}
+}
+
+// oidcCallbackHandler 处理 IdP 回调
+// @Summary      OIDC 登录回调
+// @Description  校验 state 并使用授权码换取 ID token, 首次登录时自动创建用户, 并按 IdP 的组映射平台角色。用户启用了 MFA 或角色要求 MFA 时返回 MFA 挑战令牌, 需要继续调用 /auth/mfa/verify 或 /auth/mfa/enroll。配置了 post_login_redirect 时跳转到前端并在 URL fragment 中携带令牌, 否则返回 JSON
+// @Tags         auth
+// @Produce      json
+// @Param        code  query string true "授权码"
+// @Param        state query string true "state"
+// @Success      200 {object} model.LoginResponse
+// @Success      302
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /auth/oidc/callback [get]
+func oidcCallbackHandler(store store.Store, jwtManager *auth.JWTManager, authorizer *auth.Authorizer, provider *auth.OIDCProvider, policy *auth.PasswordPolicy, secureCookie bool, postLoginRedirect string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sealed, _ := c.Cookie(oidcFlowCookie)
+		// 登录流程状态只能使用一次
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(oidcFlowCookie, "", -1, oidcCookiePath, "", secureCookie, true)
+
+		if idpError := c.Query("error"); idpError != "" {
+			message := idpError
+			if description := c.Query("error_description"); description != "" {
+				message = fmt.Sprintf("%s: %s", idpError, description)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+			return
+		}
+
+		flow, err := provider.OpenFlow(sealed, c.Query("state"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		code := c.Query("code")
+		if code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "missing authorization code"})
+			return
+		}
+
+		identity, err := provider.Exchange(c.Request.Context(), code, flow)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "single sign-on failed"})
+			return
+		}
+
+		user, ok := provisionOIDCUser(c, store, authorizer, provider, identity)
+		if !ok {
+			return
+		}
+		if !user.IsActive {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user account is disabled"})
+			return
+		}
+
+		completeOIDCLogin(c, store, jwtManager, policy, user, postLoginRedirect)
+	}
+}
+
+// completeOIDCLogin 完成 IdP 认证后的登录, 与密码登录一样经过 MFA 检查
+//
+// 配置了 postLoginRedirect 时跳转到前端, 访问令牌或 MFA 挑战令牌放在 fragment 中, 不会发送到服务器或出现在访问日志里。
+func completeOIDCLogin(c *gin.Context, store store.Store, jwtManager *auth.JWTManager, policy *auth.PasswordPolicy, user *model.User, postLoginRedirect string) {
+	challenge, ok := loginChallenge(c, store, jwtManager, user)
+	if !ok {
+		return
+	}
+
+	var result interface{} = challenge
+	if challenge == nil {
+		result, ok = finishLogin(c, store, jwtManager, policy, user)
+		if !ok {
+			return
+		}
+	}
+
+	if postLoginRedirect == "" {
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	var fragment url.Values
+	switch response := result.(type) {
+	case *model.MFAChallengeResponse:
+		fragment = url.Values{
+			"mfa_required":        {"true"},
+			"enrollment_required": {strconv.FormatBool(response.EnrollmentRequired)},
+			"mfa_token":           {response.MFAToken},
+			"expires_in":          {strconv.FormatInt(response.ExpiresIn, 10)},
+		}
+	case *model.LoginResponse:
+		fragment = url.Values{
+			"token":         {response.Token},
+			"refresh_token": {response.RefreshToken},
+			"expires_in":    {strconv.FormatInt(response.ExpiresIn, 10)},
+		}
+	default:
+		c.JSON(http.StatusOK, result)
+		return
+	}
+	c.Redirect(http.StatusFound, postLoginRedirect+"#"+fragment.Encode())
+}
+
+// provisionOIDCUser 查找 OIDC 身份对应的用户, 首次登录时创建用户; 配置了角色映射时同步用户角色
+func provisionOIDCUser(c *gin.Context, store store.Store, authorizer *auth.Authorizer, provider *auth.OIDCProvider, identity *auth.OIDCIdentity) (*model.User, bool) {
+	ctx := c.Request.Context()
+
+	roleName, err := provider.MapRole(identity.Groups)
+	if err != nil {
+		if errors.Is(err, auth.ErrOIDCNoRole) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	role, err := authorizer.ResolveRole(ctx, roleName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if role == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "oidc role mapping refers to unknown role: " + roleName})
+		return nil, false
+	}
+
+	externalID := identity.ExternalID()
+	user, err := store.GetUserByExternalID(ctx, externalID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+
+	if user != nil {
+		// IdP 是组成员关系的唯一来源, 每次登录时同步角色
+		if provider.HasRoleMapping() && user.Role != role.Name {
+			user.Role = role.Name
+			if err := store.UpdateUser(ctx, user); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return nil, false
+			}
+		}
+		return user, true
+	}
+
+	// 首次登录: 即时创建用户。不会自动关联同名的本地用户, 避免 IdP 中的同名账号接管本地账号
+	username := identity.Username
+	if username == "" && identity.Email != "" {
+		username = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	if username == "" {
+		username = identity.Subject
+	}
+	email := identity.Email
+	if email == "" {
+		email = fmt.Sprintf("%s@oidc.local", username)
+	}
+	if !ensureUserAvailable(c, store, username, email) {
+		return nil, false
+	}
+
+	// 与自助注册一致, 系统中的第一个用户成为管理员
+	userCount, err := store.CountUsers(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return nil, false
+	}
+	if userCount == 0 {
+		roleName = auth.RoleAdmin
+	} else {
+		roleName = role.Name
+	}
+
+	// OIDC 用户不能密码登录, 使用随机密码占位
+	password, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate password"})
+		return nil, false
+	}
+
+	user = &model.User{
+		Username:     username,
+		Email:        email,
+		Password:     password,
+		Role:         roleName,
+		IsActive:     true,
+		AuthProvider: model.AuthProviderOIDC,
+		ExternalID:   &externalID,
+	}
+	if err := store.CreateUser(ctx, user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+		return nil, false
+	}
+	return user, true
+}
+
+// localLoginGate 关闭本地密码登录时拒绝密码登录, 注册和接受邀请
+func localLoginGate(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "local password login is disabled, use single sign-on"})
+			return
+		}
+		c.Next()
+	}
+}
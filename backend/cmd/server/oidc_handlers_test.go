@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+func TestCompleteOIDCLogin_MFA(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := t.Context()
+	jwtManager := auth.NewJWTManager("test-secret-key", time.Hour)
+	suffix := time.Now().UnixNano()
+
+	// 要求 MFA 的角色只在本测试中使用
+	mfaRole := fmt.Sprintf("test-mfa-%d", suffix)
+	previous, err := store.ListMFARequiredRoles(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.SetMFARequiredRoles(ctx, append(append([]string{}, previous...), mfaRole)))
+	t.Cleanup(func() { store.SetMFARequiredRoles(ctx, previous) })
+
+	newUser := func(name, role string, mfaEnabled bool) *model.User {
+		externalID := fmt.Sprintf("oidc|%s-%d", name, suffix)
+		user := &model.User{
+			Username:     fmt.Sprintf("%s-%d", name, suffix),
+			Email:        fmt.Sprintf("%s-%d@example.com", name, suffix),
+			Role:         role,
+			IsActive:     true,
+			AuthProvider: model.AuthProviderOIDC,
+			ExternalID:   &externalID,
+			MFAEnabled:   mfaEnabled,
+		}
+		require.NoError(t, store.CreateUser(ctx, user))
+		return user
+	}
+	plain := newUser("oidc-plain", auth.RoleViewer, false)
+	enrolled := newUser("oidc-enrolled", auth.RoleViewer, true)
+	required := newUser("oidc-required", mfaRole, false)
+
+	login := func(user *model.User, postLoginRedirect string) *httptest.ResponseRecorder {
+		router := setupTestRouter()
+		router.GET("/callback", func(c *gin.Context) {
+			completeOIDCLogin(c, store, jwtManager, auth.DefaultPasswordPolicy(), user, postLoginRedirect)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/callback", nil))
+		return w
+	}
+
+	t.Run("未启用 MFA 直接签发令牌", func(t *testing.T) {
+		w := login(plain, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var response model.LoginResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.NotEmpty(t, response.Token)
+		assert.NotEmpty(t, response.RefreshToken)
+	})
+
+	t.Run("已启用 MFA 返回挑战令牌", func(t *testing.T) {
+		w := login(enrolled, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, true, response["mfa_required"])
+		assert.NotEmpty(t, response["mfa_token"])
+		assert.NotContains(t, response, "token")
+		assert.NotContains(t, response, "refresh_token")
+	})
+
+	t.Run("角色要求 MFA 时返回绑定挑战", func(t *testing.T) {
+		w := login(required, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var response model.MFAChallengeResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.MFARequired)
+		assert.True(t, response.EnrollmentRequired)
+		_, err := jwtManager.VerifyMFAChallenge(response.MFAToken, auth.PurposeMFAEnroll)
+		assert.NoError(t, err)
+	})
+
+	t.Run("跳转时 fragment 中只有挑战令牌", func(t *testing.T) {
+		w := login(enrolled, "https://app.example.com/login")
+		require.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		fragment, err := url.ParseQuery(location.Fragment)
+		require.NoError(t, err)
+		assert.Equal(t, "true", fragment.Get("mfa_required"))
+		assert.Empty(t, fragment.Get("token"))
+		_, err = jwtManager.VerifyMFAChallenge(fragment.Get("mfa_token"), auth.PurposeMFAVerify)
+		assert.NoError(t, err)
+	})
+
+	t.Run("跳转时 fragment 中携带访问令牌", func(t *testing.T) {
+		w := login(plain, "https://app.example.com/login")
+		require.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		fragment, err := url.ParseQuery(location.Fragment)
+		require.NoError(t, err)
+		assert.NotEmpty(t, fragment.Get("token"))
+		assert.NotEmpty(t, fragment.Get("refresh_token"))
+	})
+
+	// 清理
+	execTestSQL(store, "DELETE FROM refresh_tokens WHERE user_id IN (?, ?, ?)", plain.ID, enrolled.ID, required.ID)
+	execTestSQL(store, "DELETE FROM users WHERE id IN (?, ?, ?)", plain.ID, enrolled.ID, required.ID)
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// requirePlatformAdmin 要求当前用户属于默认组织, 必须在 Authorizer.Require 之后使用
+//
+// 每个组织都有自己的管理员, 只有默认组织的管理员可以管理所有组织。
+func requirePlatformAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := auth.GetCurrentUser(c)
+		if !exists || claims.OrganizationID != model.DefaultOrganizationID {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "organization management is restricted to platform administrators"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// getMyOrganizationHandler 获取当前用户所属的组织
+// @Summary      获取当前组织
+// @Description  获取当前用户所属的组织及其配额使用情况
+// @Tags         organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /organization [get]
+func getMyOrganizationHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, exists := auth.GetCurrentUser(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+			return
+		}
+
+		org, err := store.GetOrganization(c.Request.Context(), claims.OrganizationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if org == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+			return
+		}
+
+		respondOrganization(c, store, org)
+	}
+}
+
+// listOrganizationsHandler 列出所有组织
+// @Summary      列出组织
+// @Description  获取所有组织, 仅平台管理员可用
+// @Tags         organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /organizations [get]
+func listOrganizationsHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		orgs, err := store.ListOrganizations(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"organizations": orgs,
+			"total":         len(orgs),
+		})
+	}
+}
+
+// getOrganizationHandler 获取单个组织
+// @Summary      获取组织详情
+// @Description  根据 ID 获取组织及其配额使用情况, 仅平台管理员可用
+// @Tags         organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "组织 ID"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /organizations/{id} [get]
+func getOrganizationHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		org, ok := loadOrganization(c, store)
+		if !ok {
+			return
+		}
+
+		respondOrganization(c, store, org)
+	}
+}
+
+// createOrganizationHandler 创建组织
+// @Summary      创建组织
+// @Description  创建组织并生成 Agent 连接使用的 Secret Key; 指定 admin_email 时同时生成该组织的管理员邀请。Secret Key 和邀请令牌只在创建时返回一次
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        organization body model.OrganizationRequest true "组织信息"
+// @Success      201 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /organizations [post]
+func createOrganizationHandler(store store.Store, invitationTTL time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.OrganizationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		existing, err := store.GetOrganizationByName(c.Request.Context(), req.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "organization name already exists"})
+			return
+		}
+
+		secretKey, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret key"})
+			return
+		}
+
+		org := &model.Organization{
+			Name:            req.Name,
+			DisplayName:     req.DisplayName,
+			MaxAgents:       req.MaxAgents,
+			MaxPackageBytes: req.MaxPackageBytes,
+		}
+		org.SetSecretKey(secretKey)
+
+		if err := store.CreateOrganization(c.Request.Context(), org); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetTarget(c, "organizations", strconv.FormatUint(uint64(org.ID), 10))
+		audit.SetChange(c, nil, org)
+
+		response := gin.H{
+			"organization": org,
+			"secret_key":   secretKey,
+		}
+
+		if req.AdminEmail != "" {
+			token, err := auth.GenerateOpaqueToken()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+				return
+			}
+
+			invitation := &model.Invitation{
+				TokenHash: auth.HashToken(token),
+				Email:     req.AdminEmail,
+				Role:      auth.RoleAdmin,
+				ExpiresAt: time.Now().Add(invitationTTL),
+			}
+			if claims, exists := auth.GetCurrentUser(c); exists {
+				invitation.CreatedBy = claims.Username
+			}
+
+			// 邀请属于新组织
+			ctx := tenant.WithOrganization(c.Request.Context(), org.ID)
+			if err := store.CreateInvitation(ctx, invitation); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			response["invitation"] = invitation
+			response["invitation_token"] = token
+		}
+
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// updateOrganizationHandler 更新组织
+// @Summary      更新组织
+// @Description  更新组织的名称和配额, 仅平台管理员可用。降低配额不影响已接入的 Agent 和已上传的软件包
+// @Tags         organizations
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "组织 ID"
+// @Param        organization body model.OrganizationRequest true "组织信息"
+// @Success      200 {object} model.Organization
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /organizations/{id} [put]
+func updateOrganizationHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		org, ok := loadOrganization(c, store)
+		if !ok {
+			return
+		}
+
+		var req model.OrganizationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if req.Name != org.Name {
+			existing, err := store.GetOrganizationByName(c.Request.Context(), req.Name)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if existing != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": "organization name already exists"})
+				return
+			}
+		}
+
+		before := *org
+		org.Name = req.Name
+		org.DisplayName = req.DisplayName
+		org.MaxAgents = req.MaxAgents
+		org.MaxPackageBytes = req.MaxPackageBytes
+
+		if err := store.UpdateOrganization(c.Request.Context(), org); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetChange(c, &before, org)
+
+		c.JSON(http.StatusOK, org)
+	}
+}
+
+// deleteOrganizationHandler 删除组织
+// @Summary      删除组织
+// @Description  删除组织, 仅平台管理员可用。默认组织不能删除, 组织中还有用户或 Agent 时不能删除
+// @Tags         organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "组织 ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /organizations/{id} [delete]
+func deleteOrganizationHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		org, ok := loadOrganization(c, store)
+		if !ok {
+			return
+		}
+		if org.ID == model.DefaultOrganizationID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the default organization cannot be deleted"})
+			return
+		}
+
+		usage, err := store.GetOrganizationUsage(c.Request.Context(), org.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if usage.Users > 0 || usage.Agents > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "organization still has users or agents"})
+			return
+		}
+
+		if err := store.DeleteOrganization(c.Request.Context(), org.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetChange(c, org, nil)
+
+		c.JSON(http.StatusOK, gin.H{"message": "organization deleted"})
+	}
+}
+
+// rotateOrganizationSecretKeyHandler 重新生成组织的 Secret Key
+// @Summary      重新生成 Secret Key
+// @Description  为组织生成新的 Agent 连接 Secret Key, 旧的 Secret Key 立即失效。新 Secret Key 只返回一次
+// @Tags         organizations
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "组织 ID"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /organizations/{id}/secret-key [post]
+func rotateOrganizationSecretKeyHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		org, ok := loadOrganization(c, store)
+		if !ok {
+			return
+		}
+
+		secretKey, err := auth.GenerateOpaqueToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret key"})
+			return
+		}
+		org.SetSecretKey(secretKey)
+
+		if err := store.UpdateOrganization(c.Request.Context(), org); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"organization": org,
+			"secret_key":   secretKey,
+		})
+	}
+}
+
+// loadOrganization 根据路由参数加载组织, 失败时写入错误响应
+func loadOrganization(c *gin.Context, store store.Store) (*model.Organization, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid organization id"})
+		return nil, false
+	}
+
+	org, err := store.GetOrganization(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if org == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "organization not found"})
+		return nil, false
+	}
+	return org, true
+}
+
+// respondOrganization 返回组织及其配额使用情况
+func respondOrganization(c *gin.Context, store store.Store, org *model.Organization) {
+	usage, err := store.GetOrganizationUsage(c.Request.Context(), org.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"organization": org,
+		"usage":        usage,
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -26,6 +27,7 @@ import (
 // @Param        description formData string false "描述"
 // @Success      200 {object} model.Package
 // @Failure      400 {object} map[string]string
+// @Failure      413 {object} map[string]string
 // @Failure      500 {object} map[string]string
 // @Security     BearerAuth
 // @Router       /packages [post]
@@ -57,6 +59,10 @@ func uploadPackageHandler(pm *packagemgr.Manager) gin.HandlerFunc {
 
 		// 上传包
 		if err := pm.UploadPackage(c.Request.Context(), pkg, file); err != nil {
+			if errors.Is(err, packagemgr.ErrStorageQuotaExceeded) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/retention"
+)
+
+// getRetentionPoliciesHandler 获取历史记录的保留策略
+// @Summary      获取保留策略
+// @Description  获取启用的历史表保留策略, 保留策略作用于所有组织
+// @Tags         retention
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Router       /retention/policies [get]
+func getRetentionPoliciesHandler(pruner *retention.Pruner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"policies": pruner.Policies()})
+	}
+}
+
+// dryRunRetentionHandler 试运行历史记录清理
+// @Summary      试运行历史记录清理
+// @Description  按当前的保留策略统计每张历史表将要删除的记录数, 不删除任何记录
+// @Tags         retention
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Router       /retention/dry-run [post]
+func dryRunRetentionHandler(pruner *retention.Pruner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"results": pruner.DryRun(c.Request.Context())})
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// listPermissionsHandler 列出所有权限
+// @Summary      列出所有权限
+// @Description  获取系统支持的所有权限, 可用于定义自定义角色
+// @Tags         roles
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Router       /permissions [get]
+func listPermissionsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"permissions": auth.AllPermissions(),
+		})
+	}
+}
+
+// listRolesHandler 列出所有角色
+// @Summary      列出所有角色
+// @Description  获取内置角色和自定义角色
+// @Tags         roles
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /roles [get]
+func listRolesHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		custom, err := store.ListRoles(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		roles := append(auth.BuiltinRoles(), custom...)
+		c.JSON(http.StatusOK, gin.H{
+			"roles": roles,
+			"total": len(roles),
+		})
+	}
+}
+
+// createRoleHandler 创建自定义角色
+// @Summary      创建自定义角色
+// @Description  创建一个自定义角色, selector 不为空时角色只能访问标签匹配的 Agent。只能授予自己拥有的权限
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        role body model.RoleRequest true "角色信息"
+// @Success      201 {object} model.Role
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /roles [post]
+func createRoleHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.RoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := validateRoleRequest(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if auth.IsBuiltinRole(req.Name) {
+			c.JSON(http.StatusConflict, gin.H{"error": "role name is reserved for a built-in role"})
+			return
+		}
+		existing, err := store.GetRoleByName(c.Request.Context(), req.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "role already exists"})
+			return
+		}
+
+		role := &model.Role{
+			Name:        req.Name,
+			Description: req.Description,
+			Permissions: req.Permissions,
+			Selector:    req.Selector,
+		}
+		if !auth.CanGrant(c, role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot grant permissions you do not hold"})
+			return
+		}
+		if err := store.CreateRole(c.Request.Context(), role); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetTarget(c, "roles", role.Name)
+		audit.SetChange(c, nil, role)
+
+		c.JSON(http.StatusCreated, role)
+	}
+}
+
+// updateRoleHandler 更新自定义角色
+// @Summary      更新自定义角色
+// @Description  更新自定义角色的描述、权限和范围, 不能修改内置角色和角色名
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        name path string true "角色名"
+// @Param        role body model.RoleRequest true "角色信息"
+// @Success      200 {object} model.Role
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /roles/{name} [put]
+func updateRoleHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if auth.IsBuiltinRole(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "built-in roles cannot be modified"})
+			return
+		}
+
+		var req model.RoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Name != name {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role name cannot be changed"})
+			return
+		}
+		if err := validateRoleRequest(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		role, err := store.GetRoleByName(c.Request.Context(), name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if role == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+			return
+		}
+
+		// 修改前后的角色都不能超出当前用户的权限
+		before := *role
+		role.Description = req.Description
+		role.Permissions = req.Permissions
+		role.Selector = req.Selector
+		if !auth.CanGrant(c, &before) || !auth.CanGrant(c, role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot grant permissions you do not hold"})
+			return
+		}
+		if err := store.UpdateRole(c.Request.Context(), role); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetChange(c, &before, role)
+
+		c.JSON(http.StatusOK, role)
+	}
+}
+
+// deleteRoleHandler 删除自定义角色
+// @Summary      删除自定义角色
+// @Description  删除自定义角色, 仍有用户使用该角色时拒绝删除
+// @Tags         roles
+// @Produce      json
+// @Security     BearerAuth
+// @Param        name path string true "角色名"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /roles/{name} [delete]
+func deleteRoleHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if auth.IsBuiltinRole(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "built-in roles cannot be deleted"})
+			return
+		}
+
+		role, err := store.GetRoleByName(c.Request.Context(), name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if role == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+			return
+		}
+
+		users, err := store.CountUsersWithRole(c.Request.Context(), name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if users > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("role is assigned to %d user(s)", users)})
+			return
+		}
+
+		if err := store.DeleteRole(c.Request.Context(), name); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetChange(c, role, nil)
+
+		c.JSON(http.StatusOK, gin.H{"message": "role deleted"})
+	}
+}
+
+// updateUserRoleHandler 修改用户角色
+// @Summary      修改用户角色
+// @Description  为用户分配内置角色或自定义角色, 新角色立即生效。只能分配自己拥有的权限, 不能修改自己的角色
+// @Tags         roles
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Param        role body model.UpdateUserRoleRequest true "角色"
+// @Success      200 {object} model.User
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id}/role [put]
+func updateUserRoleHandler(store store.Store, authorizer *auth.Authorizer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.UpdateUserRoleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		roleName, ok := resolveRoleName(c, authorizer, req.Role)
+		if !ok {
+			return
+		}
+
+		user, ok := loadManagedUser(c, store)
+		if !ok {
+			return
+		}
+		if claims, exists := auth.GetCurrentUser(c); exists && claims.UserID == user.ID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "cannot change your own role"})
+			return
+		}
+		if roleName != auth.RoleAdmin && !ensureNotLastAdmin(c, store, user) {
+			return
+		}
+
+		before := *user
+		user.Role = roleName
+		if err := store.UpdateUser(c.Request.Context(), user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetChange(c, &before, user)
+
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// validateRoleRequest 校验自定义角色的权限列表
+func validateRoleRequest(req *model.RoleRequest) error {
+	if len(req.Permissions) == 0 {
+		return fmt.Errorf("at least one permission is required")
+	}
+	for _, p := range req.Permissions {
+		if !auth.ValidatePermission(p) {
+			return fmt.Errorf("unknown permission: %s", p)
+		}
+	}
+	return nil
+}
+
+// requireAgentScope 检查路径中的 Agent 是否在当前角色的范围内
+//
+// 范围外的 Agent 按不存在处理, 避免泄露其存在性。不限范围的角色不做额外查询。
+func requireAgentScope(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth.ScopeSelector(c) == nil {
+			c.Next()
+			return
+		}
+
+		agent, err := store.GetAgent(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if agent == nil || !auth.InScope(c, agent.Labels) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// listScopedAgents 列出当前角色范围内满足条件的 Agent
+func listScopedAgents(c *gin.Context, store store.Store, keep func(*model.Agent) bool) ([]*model.Agent, error) {
+	agents, err := store.ListAgentsBySelector(c.Request.Context(), auth.ScopeSelector(c))
+	if err != nil {
+		return nil, err
+	}
+	if keep == nil {
+		return agents, nil
+	}
+
+	filtered := make([]*model.Agent, 0, len(agents))
+	for _, agent := range agents {
+		if keep(agent) {
+			filtered = append(filtered, agent)
+		}
+	}
+	return filtered, nil
+}
+
+// paginateAgents 在内存中分页, limit <= 0 表示不限
+func paginateAgents(agents []*model.Agent, limit, offset int) []*model.Agent {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(agents) {
+		return []*model.Agent{}
+	}
+	agents = agents[offset:]
+	if limit > 0 && limit < len(agents) {
+		agents = agents[:limit]
+	}
+	return agents
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+func TestRoleHandlers_PrivilegeEscalation(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := t.Context()
+	suffix := time.Now().UnixNano()
+
+	// 只拥有用户管理和 Agent 只读权限的自定义角色
+	roleName := fmt.Sprintf("test-user-admin-%d", suffix)
+	require.NoError(t, store.CreateRole(ctx, &model.Role{
+		Name:        roleName,
+		Permissions: []string{string(auth.PermUsersAdmin), string(auth.PermAgentsRead)},
+	}))
+
+	newUser := func(name, role string) *model.User {
+		user := &model.User{Username: fmt.Sprintf("%s-%d", name, suffix), Email: fmt.Sprintf("%s-%d@example.com", name, suffix), Role: role, IsActive: true}
+		require.NoError(t, user.SetPassword("Password123!"))
+		require.NoError(t, store.CreateUser(ctx, user))
+		return user
+	}
+	manager := newUser("manager", roleName)
+	viewer := newUser("viewer", auth.RoleViewer)
+	admin := newUser("admin", auth.RoleAdmin)
+
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	token, err := jwtManager.GenerateAccessToken(manager)
+	require.NoError(t, err)
+
+	authorizer := auth.NewAuthorizer(store)
+	router := setupTestRouter()
+	router.Use(auth.AuthMiddleware(jwtManager), authorizer.Require(auth.PermUsersAdmin))
+	router.POST("/roles", createRoleHandler(store))
+	router.PUT("/users/:id/role", updateUserRoleHandler(store, authorizer))
+	router.POST("/users/:id/reset-password", resetUserPasswordHandler(store, auth.DefaultPasswordPolicy()))
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+	}{
+		{"创建包含通配符的角色", http.MethodPost, "/roles", model.RoleRequest{Name: fmt.Sprintf("test-all-%d", suffix), Permissions: []string{"*"}}, http.StatusForbidden},
+		{"创建包含未拥有权限的角色", http.MethodPost, "/roles", model.RoleRequest{Name: fmt.Sprintf("test-write-%d", suffix), Permissions: []string{string(auth.PermAgentsWrite)}}, http.StatusForbidden},
+		{"创建只包含已拥有权限的角色", http.MethodPost, "/roles", model.RoleRequest{Name: fmt.Sprintf("test-read-%d", suffix), Permissions: []string{string(auth.PermAgentsRead)}}, http.StatusCreated},
+		{"把自己提升为 admin", http.MethodPut, fmt.Sprintf("/users/%d/role", manager.ID), model.UpdateUserRoleRequest{Role: auth.RoleAdmin}, http.StatusForbidden},
+		{"修改自己的角色", http.MethodPut, fmt.Sprintf("/users/%d/role", manager.ID), model.UpdateUserRoleRequest{Role: fmt.Sprintf("test-read-%d", suffix)}, http.StatusForbidden},
+		{"为他人分配 admin", http.MethodPut, fmt.Sprintf("/users/%d/role", viewer.ID), model.UpdateUserRoleRequest{Role: auth.RoleAdmin}, http.StatusForbidden},
+		{"重置 admin 的密码", http.MethodPost, fmt.Sprintf("/users/%d/reset-password", admin.ID), gin.H{"password": "NewPassword123!"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+
+	stored, err := store.GetUserByID(ctx, viewer.ID)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RoleViewer, stored.Role)
+
+	// 清理
+	execTestSQL(store, "DELETE FROM users WHERE username LIKE ?", fmt.Sprintf("%%-%d", suffix))
+	execTestSQL(store, "DELETE FROM roles WHERE name LIKE ?", fmt.Sprintf("test-%%-%d", suffix))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/store/memory"
+	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+	"github.com/cc1024201/opamp-platform/internal/store/sqlite"
+)
+
+// 存储后端, 由 database.driver 选择
+const (
+	storeDriverPostgres = "postgres"
+	storeDriverSQLite   = "sqlite"
+	// storeDriverMemory 内存存储, 进程退出后数据丢失, 只用于开发和演示 (--dev)
+	storeDriverMemory = "memory"
+)
+
+// storeDriver 返回配置的存储后端, 默认为 PostgreSQL
+func storeDriver() string {
+	if driver := viper.GetString("database.driver"); driver != "" {
+		return driver
+	}
+	return storeDriverPostgres
+}
+
+// openStore 按 database.driver 创建存储
+func openStore(pgConfig postgres.Config, logger *zap.Logger) (store.Store, error) {
+	switch driver := storeDriver(); driver {
+	case storeDriverPostgres:
+		s, err := postgres.NewStore(pgConfig, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case storeDriverSQLite:
+		s, err := sqlite.NewStore(sqlite.Config{
+			Path:          viper.GetString("database.sqlite.path"),
+			BusyTimeoutMs: viper.GetInt("database.sqlite.busy_timeout_ms"),
+		}, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case storeDriverMemory:
+		logger.Warn("Using the in-memory store, all data is lost when the server stops")
+		return memory.NewStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// listUsersHandler 列出所有用户
+// @Summary      列出所有用户
+// @Description  获取系统中的所有用户
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users [get]
+func listUsersHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		users, err := store.ListUsers(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"users": users,
+			"total": len(users),
+		})
+	}
+}
+
+// getUserHandler 获取用户详情
+// @Summary      获取用户详情
+// @Description  根据 ID 获取用户
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Success      200 {object} model.User
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id} [get]
+func getUserHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadUser(c, store)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// createUserHandler 管理员创建用户
+// @Summary      创建用户
+// @Description  管理员直接创建用户, 不受注册模式限制; 未指定角色时使用默认角色, 密码必须满足密码策略
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        user body model.CreateUserRequest true "用户信息"
+// @Success      201 {object} model.User
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users [post]
+func createUserHandler(store store.Store, authorizer *auth.Authorizer, policy *auth.PasswordPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.CreateUserRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !validatePassword(c, policy, req.Password, req.Username) {
+			return
+		}
+
+		roleName, ok := resolveRoleName(c, authorizer, req.Role)
+		if !ok {
+			return
+		}
+		if !ensureUserAvailable(c, store, req.Username, req.Email) {
+			return
+		}
+
+		user := &model.User{
+			Username: req.Username,
+			Email:    req.Email,
+			Password: req.Password, // BeforeCreate hook 会自动哈希密码
+			Role:     roleName,
+			IsActive: true,
+		}
+		if err := store.CreateUser(c.Request.Context(), user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+			return
+		}
+		audit.SetTarget(c, "users", strconv.FormatUint(uint64(user.ID), 10))
+		audit.SetChange(c, nil, user)
+
+		c.JSON(http.StatusCreated, user)
+	}
+}
+
+// disableUserHandler 禁用用户
+// @Summary      禁用用户
+// @Description  禁用用户后其现有 token 立即失效且无法再登录; 不能禁用最后一个管理员
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Success      200 {object} model.User
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id}/disable [post]
+func disableUserHandler(store store.Store) gin.HandlerFunc {
+	return setUserActiveHandler(store, false)
+}
+
+// enableUserHandler 启用用户
+// @Summary      启用用户
+// @Description  重新启用被禁用的用户
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Success      200 {object} model.User
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id}/enable [post]
+func enableUserHandler(store store.Store) gin.HandlerFunc {
+	return setUserActiveHandler(store, true)
+}
+
+// setUserActiveHandler 修改用户的激活状态
+func setUserActiveHandler(store store.Store, active bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadManagedUser(c, store)
+		if !ok {
+			return
+		}
+		if user.IsActive == active {
+			c.JSON(http.StatusOK, user)
+			return
+		}
+		if !active && !ensureNotLastAdmin(c, store, user) {
+			return
+		}
+
+		before := *user
+		user.IsActive = active
+		if !active {
+			// 禁用时使已签发的令牌立即失效
+			if !invalidateUserSessions(c, store, user) {
+				return
+			}
+		} else if err := store.UpdateUser(c.Request.Context(), user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetChange(c, &before, user)
+
+		c.JSON(http.StatusOK, user)
+	}
+}
+
+// resetUserPasswordHandler 重置用户密码
+// @Summary      重置用户密码
+// @Description  管理员重置用户密码并使其已签发的令牌失效; 指定的密码必须满足密码策略, 未指定密码时生成满足策略的临时密码, 临时密码只在响应中返回一次
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Param        request body model.ResetPasswordRequest false "新密码"
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id}/reset-password [post]
+func resetUserPasswordHandler(store store.Store, policy *auth.PasswordPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.ResetPasswordRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		user, ok := loadManagedUser(c, store)
+		if !ok {
+			return
+		}
+
+		password := req.Password
+		generated := password == ""
+		if generated {
+			var err error
+			password, err = policy.GeneratePassword()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate password"})
+				return
+			}
+		} else if !validatePassword(c, policy, password, user.Username) {
+			return
+		}
+
+		if err := user.SetPassword(password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set password"})
+			return
+		}
+		// 重置密码后用户已签发的令牌全部失效
+		if !invalidateUserSessions(c, store, user) {
+			return
+		}
+
+		response := gin.H{"message": "password reset", "user": user}
+		if generated {
+			response["password"] = password
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// unlockUserHandler 解除账号的登录锁定
+// @Summary      解除登录锁定
+// @Description  清除用户因连续登录失败产生的锁定和失败计数
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id}/unlock [post]
+func unlockUserHandler(store store.Store, guard *auth.LoginGuard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadUser(c, store)
+		if !ok {
+			return
+		}
+
+		guard.Unlock(user.Username)
+		c.JSON(http.StatusOK, gin.H{"message": "user unlocked"})
+	}
+}
+
+// deleteUserHandler 删除用户
+// @Summary      删除用户
+// @Description  删除用户; 不能删除最后一个管理员
+// @Tags         users
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "用户 ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      409 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /users/{id} [delete]
+func deleteUserHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadManagedUser(c, store)
+		if !ok {
+			return
+		}
+		if !ensureNotLastAdmin(c, store, user) {
+			return
+		}
+
+		if err := store.DeleteUser(c.Request.Context(), user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetChange(c, user, nil)
+
+		c.JSON(http.StatusOK, gin.H{"message": "user deleted"})
+	}
+}
+
+// loadUser 根据路径参数加载用户, 失败时写入错误响应
+func loadUser(c *gin.Context, store store.Store) (*model.User, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return nil, false
+	}
+
+	user, err := store.GetUserByID(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return nil, false
+	}
+	return user, true
+}
+
+// loadManagedUser 加载路径中的用户, 并检查当前用户能否管理该用户
+//
+// 用户的角色包含当前用户没有的权限时返回 403, 避免通过重置密码、签发令牌等操作接管权限更高的账号。
+func loadManagedUser(c *gin.Context, store store.Store) (*model.User, bool) {
+	user, ok := loadUser(c, store)
+	if !ok {
+		return nil, false
+	}
+
+	role, err := auth.NewAuthorizer(store).ResolveRole(c.Request.Context(), user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if role != nil && !auth.CanGrant(c, role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot manage a user with permissions you do not hold"})
+		return nil, false
+	}
+	return user, true
+}
+
+// ensureNotLastAdmin 检查对用户的降级、禁用或删除是否会导致系统没有可用的管理员
+func ensureNotLastAdmin(c *gin.Context, store store.Store, user *model.User) bool {
+	if user.Role != auth.RoleAdmin || !user.IsActive {
+		return true
+	}
+
+	admins, err := store.CountActiveUsersWithRole(c.Request.Context(), auth.RoleAdmin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if admins <= 1 {
+		c.JSON(http.StatusConflict, gin.H{"error": "cannot remove the last active admin"})
+		return false
+	}
+	return true
+}
+
+// ensureUserAvailable 检查用户名和邮箱是否已被使用
+func ensureUserAvailable(c *gin.Context, store store.Store, username, email string) bool {
+	existingUser, err := store.GetUserByUsername(c.Request.Context(), username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+	if existingUser != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "username already exists"})
+		return false
+	}
+
+	existingEmail, err := store.GetUserByEmail(c.Request.Context(), email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return false
+	}
+	if existingEmail != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "email already exists"})
+		return false
+	}
+	return true
+}
+
+// resolveRoleName 校验角色名, 为空时返回默认角色
+func resolveRoleName(c *gin.Context, authorizer *auth.Authorizer, name string) (string, bool) {
+	if name == "" {
+		return auth.DefaultRole, true
+	}
+
+	role, err := authorizer.ResolveRole(c.Request.Context(), name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if role == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role"})
+		return "", false
+	}
+	if !auth.CanGrant(c, role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "cannot grant a role with permissions you do not hold"})
+		return "", false
+	}
+	return role.Name, true
+}
+
+// currentUsername 返回当前认证用户的用户名, 用于记录修改人
+func currentUsername(c *gin.Context) string {
+	if claims, exists := auth.GetCurrentUser(c); exists {
+		return claims.Username
+	}
+	return ""
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/audit"
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/webhook"
+)
+
+// listWebhooksHandler 列出所有 Webhook
+// @Summary      列出 Webhook
+// @Description  获取所有 Webhook 订阅, 限定范围的角色只能看到选择器在范围内的订阅
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200 {object} map[string]interface{}
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /webhooks [get]
+func listWebhooksHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		all, err := store.ListWebhooks(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		webhooks := make([]*model.Webhook, 0, len(all))
+		for _, hook := range all {
+			if auth.SelectorInScope(c, hook.Selector) {
+				webhooks = append(webhooks, hook)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"webhooks": webhooks,
+			"total":    len(webhooks),
+		})
+	}
+}
+
+// getWebhookHandler 获取单个 Webhook
+// @Summary      获取 Webhook 详情
+// @Description  根据 ID 获取 Webhook 订阅
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "Webhook ID"
+// @Success      200 {object} model.Webhook
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /webhooks/{id} [get]
+func getWebhookHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := loadWebhook(c, store)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, hook)
+	}
+}
+
+// createWebhookHandler 创建 Webhook
+// @Summary      创建 Webhook
+// @Description  创建 Webhook 订阅; 未指定 secret 时自动生成, 签名密钥只在创建时返回一次
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        webhook body model.WebhookRequest true "Webhook 信息"
+// @Success      201 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /webhooks [post]
+func createWebhookHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req model.WebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validateWebhookEventTypes(req.EventTypes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 限定范围的角色只能订阅范围内 Agent 的事件
+		selector, ok := auth.MergeScope(c, req.Selector)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "selector is outside of the role scope"})
+			return
+		}
+		req.Selector = selector
+
+		secret := req.Secret
+		if secret == "" {
+			generated, err := webhook.GenerateSecret()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+				return
+			}
+			secret = generated
+		}
+
+		hook := &model.Webhook{
+			Name:        req.Name,
+			URL:         req.URL,
+			Description: req.Description,
+			EventTypes:  req.EventTypes,
+			Selector:    req.Selector,
+			Secret:      secret,
+			Enabled:     req.Enabled == nil || *req.Enabled,
+		}
+
+		if err := store.CreateWebhook(c.Request.Context(), hook); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetTarget(c, "webhooks", strconv.FormatUint(uint64(hook.ID), 10))
+		audit.SetChange(c, nil, hook)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"webhook": hook,
+			"secret":  secret,
+		})
+	}
+}
+
+// updateWebhookHandler 更新 Webhook
+// @Summary      更新 Webhook
+// @Description  更新 Webhook 订阅; secret 为空时保留原密钥
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "Webhook ID"
+// @Param        webhook body model.WebhookRequest true "Webhook 信息"
+// @Success      200 {object} model.Webhook
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      403 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /webhooks/{id} [put]
+func updateWebhookHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := loadWebhook(c, store)
+		if !ok {
+			return
+		}
+
+		var req model.WebhookRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validateWebhookEventTypes(req.EventTypes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 限定范围的角色只能订阅范围内 Agent 的事件
+		selector, ok := auth.MergeScope(c, req.Selector)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "selector is outside of the role scope"})
+			return
+		}
+		req.Selector = selector
+
+		before := *hook
+		hook.Name = req.Name
+		hook.URL = req.URL
+		hook.Description = req.Description
+		hook.EventTypes = req.EventTypes
+		hook.Selector = req.Selector
+		if req.Secret != "" {
+			hook.Secret = req.Secret
+		}
+		if req.Enabled != nil {
+			hook.Enabled = *req.Enabled
+		}
+
+		if err := store.UpdateWebhook(c.Request.Context(), hook); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		audit.SetChange(c, &before, hook)
+
+		c.JSON(http.StatusOK, hook)
+	}
+}
+
+// deleteWebhookHandler 删除 Webhook
+// @Summary      删除 Webhook
+// @Description  删除 Webhook 订阅及其投递记录
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "Webhook ID"
+// @Success      200 {object} map[string]string
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /webhooks/{id} [delete]
+func deleteWebhookHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+			return
+		}
+
+		existing, err := store.GetWebhook(c.Request.Context(), uint(id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil && !auth.SelectorInScope(c, existing.Selector) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return
+		}
+
+		if err := store.DeleteWebhook(c.Request.Context(), uint(id)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if existing != nil {
+			audit.SetChange(c, existing, nil)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "webhook deleted"})
+	}
+}
+
+// testWebhookHandler 发送测试事件
+// @Summary      发送测试事件
+// @Description  向 Webhook 同步发送一条 webhook.test 事件, 返回投递记录
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "Webhook ID"
+// @Success      200 {object} model.WebhookDelivery
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      404 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /webhooks/{id}/test [post]
+func testWebhookHandler(store store.Store, dispatcher *webhook.Dispatcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		hook, ok := loadWebhook(c, store)
+		if !ok {
+			return
+		}
+
+		delivery, err := dispatcher.SendTest(c.Request.Context(), hook)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, delivery)
+	}
+}
+
+// listWebhookDeliveriesHandler 列出 Webhook 投递记录
+// @Summary      列出 Webhook 投递记录
+// @Description  获取 Webhook 的投递日志, 包括重试状态
+// @Tags         webhooks
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id path int true "Webhook ID"
+// @Param        limit query int false "每页数量" default(20)
+// @Param        offset query int false "偏移量" default(0)
+// @Success      200 {object} map[string]interface{}
+// @Failure      400 {object} map[string]string
+// @Failure      401 {object} map[string]string
+// @Failure      500 {object} map[string]string
+// @Router       /webhooks/{id}/deliveries [get]
+func listWebhookDeliveriesHandler(store store.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+			return
+		}
+		limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+		hook, err := store.GetWebhook(c.Request.Context(), uint(id))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if hook != nil && !auth.SelectorInScope(c, hook.Selector) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return
+		}
+
+		deliveries, total, err := store.ListWebhookDeliveries(c.Request.Context(), uint(id), limit, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"deliveries": deliveries,
+			"total":      total,
+			"limit":      limit,
+			"offset":     offset,
+		})
+	}
+}
+
+// loadWebhook 根据路径参数加载 Webhook, 失败时写入错误响应
+//
+// 选择器不在当前角色范围内的 Webhook 按不存在处理。
+func loadWebhook(c *gin.Context, store store.Store) (*model.Webhook, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+		return nil, false
+	}
+
+	hook, err := store.GetWebhook(c.Request.Context(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if hook == nil || !auth.SelectorInScope(c, hook.Selector) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return nil, false
+	}
+
+	return hook, true
+}
+
+// validateWebhookEventTypes 校验订阅的事件类型
+func validateWebhookEventTypes(eventTypes []string) error {
+	for _, t := range eventTypes {
+		if !events.Type(t).IsValid() {
+			return fmt.Errorf("unknown event type: %s", t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+func TestWebhookHandlers_RoleScope(t *testing.T) {
+	store := setupTestStore(t)
+	ctx := t.Context()
+	suffix := time.Now().UnixNano()
+
+	prod := &model.Webhook{Name: fmt.Sprintf("test-hook-prod-%d", suffix), URL: "https://example.com/prod", Selector: map[string]string{"env": "prod"}, Secret: "secret", Enabled: true}
+	require.NoError(t, store.CreateWebhook(ctx, prod))
+
+	// 范围限定为 env=dev 的角色
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set(auth.RolePayloadKey, &model.Role{Name: "dev-operator", Permissions: []string{"webhooks:*"}, Selector: map[string]string{"env": "dev"}})
+	})
+	router.GET("/webhooks", listWebhooksHandler(store))
+	router.GET("/webhooks/:id", getWebhookHandler(store))
+	router.POST("/webhooks", createWebhookHandler(store))
+	router.PUT("/webhooks/:id", updateWebhookHandler(store))
+	router.DELETE("/webhooks/:id", deleteWebhookHandler(store))
+
+	send := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(data))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	prodPath := fmt.Sprintf("/webhooks/%d", prod.ID)
+	request := func(selector map[string]string) model.WebhookRequest {
+		return model.WebhookRequest{Name: fmt.Sprintf("test-hook-dev-%d", suffix), URL: "https://example.com/dev", Selector: selector}
+	}
+
+	// 没有选择器时合并角色范围, 只订阅范围内的 Agent
+	w := send(http.MethodPost, "/webhooks", request(nil))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Webhook model.Webhook `json:"webhook"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, map[string]string{"env": "dev"}, created.Webhook.Selector)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+	}{
+		{"创建范围外的 Webhook", http.MethodPost, "/webhooks", request(map[string]string{"env": "prod"}), http.StatusForbidden},
+		{"把 Webhook 改到范围外", http.MethodPut, fmt.Sprintf("/webhooks/%d", created.Webhook.ID), request(map[string]string{"env": "prod"}), http.StatusForbidden},
+		{"查看范围外的 Webhook", http.MethodGet, prodPath, nil, http.StatusNotFound},
+		{"修改范围外的 Webhook", http.MethodPut, prodPath, request(map[string]string{"env": "dev"}), http.StatusNotFound},
+		{"删除范围外的 Webhook", http.MethodDelete, prodPath, nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.method, tt.path, tt.body)
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+
+	w = send(http.MethodGet, "/webhooks", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Webhooks []model.Webhook `json:"webhooks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	for _, hook := range list.Webhooks {
+		assert.Equal(t, "dev", hook.Selector["env"])
+	}
+
+	stored, err := store.GetWebhook(ctx, prod.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, stored)
+
+	// 清理
+	execTestSQL(store, "DELETE FROM webhooks WHERE name LIKE ?", fmt.Sprintf("test-hook-%%-%d", suffix))
+}
@@ -0,0 +1,162 @@
+// Package agentgc 定期删除长时间离线的 Agent
+package agentgc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// Store 定义垃圾回收所需的存储接口
+type Store interface {
+	ListOrganizations(ctx context.Context) ([]*model.Organization, error)
+	DeleteAgentsOfflineBefore(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// Auditor 记录垃圾回收删除的 Agent
+type Auditor interface {
+	Record(ctx context.Context, entry *model.AuditLog)
+}
+
+// Config 垃圾回收配置
+type Config struct {
+	// Interval 检查间隔
+	Interval time.Duration
+	// OfflineAfter 离线超过该时长的 Agent 被删除, 0 表示不启用
+	OfflineAfter time.Duration
+}
+
+// Collector 定期删除离线超过 OfflineAfter 的 Agent 及其历史记录
+//
+// 已退役的 Agent 不会被删除, 以免其再次连接时重新注册。
+type Collector struct {
+	store   Store
+	logger  *zap.Logger
+	auditor Auditor
+	config  Config
+	now     func() time.Time
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewCollector 创建新的垃圾回收器, auditor 为空时不记录审计日志
+func NewCollector(store Store, logger *zap.Logger, auditor Auditor, config Config) *Collector {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	// 默认值
+	if config.Interval == 0 {
+		config.Interval = time.Hour
+	}
+
+	return &Collector{
+		store:   store,
+		logger:  logger,
+		auditor: auditor,
+		config:  config,
+		now:     time.Now,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Enabled 检查是否启用了垃圾回收
+func (c *Collector) Enabled() bool {
+	return c.config.OfflineAfter > 0
+}
+
+// Start 启动定期回收, 未启用时不做任何操作
+func (c *Collector) Start(ctx context.Context) {
+	if !c.Enabled() {
+		return
+	}
+
+	c.logger.Info("starting agent garbage collector",
+		zap.Duration("interval", c.config.Interval),
+		zap.Duration("offline_after", c.config.OfflineAfter))
+
+	c.wg.Add(1)
+	go c.run(ctx)
+}
+
+// Stop 停止回收
+func (c *Collector) Stop() {
+	if !c.Enabled() {
+		return
+	}
+
+	c.logger.Info("stopping agent garbage collector")
+	close(c.stopCh)
+	c.wg.Wait()
+}
+
+// run 执行回收循环
+func (c *Collector) run(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.collect(ctx)
+		case <-c.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// collect 删除所有组织中离线过久的 Agent
+func (c *Collector) collect(ctx context.Context) {
+	before := c.now().Add(-c.config.OfflineAfter)
+
+	orgs, err := c.store.ListOrganizations(tenant.WithoutOrganization(ctx))
+	if err != nil {
+		c.logger.Error("failed to list organizations", zap.Error(err))
+		return
+	}
+
+	for _, org := range orgs {
+		orgCtx := tenant.WithOrganization(ctx, org.ID)
+
+		ids, err := c.store.DeleteAgentsOfflineBefore(orgCtx, before)
+		if err != nil {
+			c.logger.Error("failed to delete offline agents",
+				zap.Uint("organization_id", org.ID),
+				zap.Error(err))
+			continue
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		c.logger.Info("deleted offline agents",
+			zap.Uint("organization_id", org.ID),
+			zap.Strings("agent_ids", ids),
+			zap.Time("offline_before", before))
+
+		if c.auditor == nil {
+			continue
+		}
+		for _, id := range ids {
+			c.auditor.Record(orgCtx, &model.AuditLog{
+				OrganizationID: org.ID,
+				Actor:          "agent-gc",
+				Action:         "agents.gc",
+				TargetType:     "agents",
+				TargetID:       id,
+				Details: map[string]interface{}{
+					"offline_before": before,
+				},
+			})
+		}
+	}
+}
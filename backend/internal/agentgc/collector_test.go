@@ -0,0 +1,81 @@
+package agentgc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// mockStore 内存实现的 Store
+type mockStore struct {
+	orgs          []*model.Organization
+	deleted       map[uint][]string
+	deleteErr     map[uint]error
+	deletedBefore time.Time
+}
+
+func (s *mockStore) ListOrganizations(ctx context.Context) ([]*model.Organization, error) {
+	return s.orgs, nil
+}
+
+func (s *mockStore) DeleteAgentsOfflineBefore(ctx context.Context, before time.Time) ([]string, error) {
+	s.deletedBefore = before
+	orgID, _ := tenant.OrganizationID(ctx)
+	if err := s.deleteErr[orgID]; err != nil {
+		return nil, err
+	}
+	return s.deleted[orgID], nil
+}
+
+// mockAuditor 记录写入的审计日志
+type mockAuditor struct {
+	entries []*model.AuditLog
+}
+
+func (a *mockAuditor) Record(ctx context.Context, entry *model.AuditLog) {
+	a.entries = append(a.entries, entry)
+}
+
+func TestCollector_Collect(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := &mockStore{
+		orgs: []*model.Organization{{ID: 1}, {ID: 2}, {ID: 3}},
+		deleted: map[uint][]string{
+			1: {"agent-1", "agent-2"},
+			3: {"agent-3"},
+		},
+		deleteErr: map[uint]error{2: errors.New("boom")},
+	}
+	auditor := &mockAuditor{}
+
+	collector := NewCollector(store, nil, auditor, Config{OfflineAfter: 24 * time.Hour})
+	collector.now = func() time.Time { return now }
+	collector.collect(context.Background())
+
+	assert.Equal(t, now.Add(-24*time.Hour), store.deletedBefore)
+	// 一个组织失败不影响其他组织
+	if assert.Len(t, auditor.entries, 3) {
+		assert.Equal(t, uint(1), auditor.entries[0].OrganizationID)
+		assert.Equal(t, "agent-1", auditor.entries[0].TargetID)
+		assert.Equal(t, "agents.gc", auditor.entries[0].Action)
+		assert.Equal(t, uint(3), auditor.entries[2].OrganizationID)
+		assert.Equal(t, "agent-3", auditor.entries[2].TargetID)
+	}
+}
+
+func TestCollector_Disabled(t *testing.T) {
+	collector := NewCollector(&mockStore{}, nil, nil, Config{})
+
+	assert.False(t, collector.Enabled())
+	assert.Equal(t, time.Hour, collector.config.Interval)
+
+	// 未启用时 Start 和 Stop 不做任何操作
+	collector.Start(context.Background())
+	collector.Stop()
+}
@@ -0,0 +1,255 @@
+package alerting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// Store 定义告警评估所需的存储接口
+type Store interface {
+	ListAlertRules(ctx context.Context) ([]*model.AlertRule, error)
+	ListActiveAlerts(ctx context.Context) ([]*model.Alert, error)
+	CreateAlert(ctx context.Context, alert *model.Alert) error
+	UpdateAlert(ctx context.Context, alert *model.Alert) error
+	ListActiveSilences(ctx context.Context, now time.Time) ([]*model.Silence, error)
+	ListAgentsBySelector(ctx context.Context, selector map[string]string) ([]*model.Agent, error)
+	CountConnectionsSince(ctx context.Context, since time.Time) (map[string]int64, error)
+	ListFailedApplyHistorySince(ctx context.Context, configName string, since time.Time) ([]*model.ConfigurationApplyHistory, error)
+}
+
+// Engine 告警规则引擎
+//
+// 定期评估所有启用的规则, 维护告警的 pending -> firing -> resolved 状态,
+// 并在状态变为 firing 或从 firing 恢复时调用通知器。
+type Engine struct {
+	store              Store
+	logger             *zap.Logger
+	notifiers          []Notifier
+	evaluationInterval time.Duration
+	now                func() time.Time
+	stopCh             chan struct{}
+	wg                 sync.WaitGroup
+}
+
+// NewEngine 创建新的告警规则引擎
+func NewEngine(store Store, logger *zap.Logger, evaluationInterval time.Duration, notifiers ...Notifier) *Engine {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	// 默认值
+	if evaluationInterval == 0 {
+		evaluationInterval = 30 * time.Second // 每 30 秒评估一次
+	}
+
+	return &Engine{
+		store:              store,
+		logger:             logger,
+		notifiers:          notifiers,
+		evaluationInterval: evaluationInterval,
+		now:                time.Now,
+		stopCh:             make(chan struct{}),
+	}
+}
+
+// Start 启动告警评估
+func (e *Engine) Start(ctx context.Context) {
+	e.logger.Info("starting alert engine",
+		zap.Duration("evaluation_interval", e.evaluationInterval),
+		zap.Int("notifiers", len(e.notifiers)))
+
+	e.wg.Add(1)
+	go e.run(ctx)
+}
+
+// Stop 停止告警评估
+func (e *Engine) Stop() {
+	e.logger.Info("stopping alert engine")
+	close(e.stopCh)
+	e.wg.Wait()
+}
+
+// run 执行告警评估循环
+func (e *Engine) run(ctx context.Context) {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.evaluationInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			e.evaluate(ctx)
+		case <-e.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// evaluate 评估所有规则并更新告警状态
+//
+// 规则、告警和静默跨组织读取, 每条规则只在其所属组织的范围内评估。
+func (e *Engine) evaluate(ctx context.Context) {
+	now := e.now()
+
+	rules, err := e.store.ListAlertRules(ctx)
+	if err != nil {
+		e.logger.Error("failed to list alert rules", zap.Error(err))
+		return
+	}
+
+	activeAlerts, err := e.store.ListActiveAlerts(ctx)
+	if err != nil {
+		e.logger.Error("failed to list active alerts", zap.Error(err))
+		return
+	}
+
+	silences, err := e.store.ListActiveSilences(ctx, now)
+	if err != nil {
+		e.logger.Error("failed to list silences", zap.Error(err))
+		return
+	}
+
+	active := make(map[string]*model.Alert, len(activeAlerts))
+	for _, alert := range activeAlerts {
+		active[alert.Fingerprint] = alert
+	}
+
+	// 评估失败的规则保持现有告警不变
+	skipped := make(map[uint]bool)
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		ruleCtx := tenant.WithOrganization(ctx, rule.OrganizationID)
+		results, err := e.evaluateRule(ruleCtx, rule, now)
+		if err != nil {
+			e.logger.Error("failed to evaluate alert rule",
+				zap.String("rule", rule.Name),
+				zap.Error(err))
+			skipped[rule.ID] = true
+			continue
+		}
+
+		for _, r := range results {
+			alert := active[r.fingerprint]
+			delete(active, r.fingerprint)
+			e.applyResult(ruleCtx, rule, alert, r, silences, now)
+		}
+	}
+
+	// 剩余的活跃告警条件已不成立 (或规则已禁用/删除), 标记为恢复
+	for _, alert := range active {
+		if skipped[alert.RuleID] {
+			continue
+		}
+		e.resolve(tenant.WithOrganization(ctx, alert.OrganizationID), alert, silences, now)
+	}
+}
+
+// applyResult 根据条件成立的评估结果创建或推进告警
+func (e *Engine) applyResult(ctx context.Context, rule *model.AlertRule, alert *model.Alert, r result, silences []*model.Silence, now time.Time) {
+	isNew := alert == nil
+	if isNew {
+		alert = &model.Alert{
+			OrganizationID: rule.OrganizationID,
+			RuleID:         rule.ID,
+			RuleName:       rule.Name,
+			Fingerprint:    r.fingerprint,
+			State:          model.AlertStatePending,
+			StartedAt:      now,
+		}
+	}
+
+	alert.Severity = rule.Severity
+	alert.AgentID = r.agentID
+	alert.Labels = r.labels
+	alert.Value = r.value
+	alert.Summary = r.summary
+	alert.LastEvaluatedAt = now
+	alert.Silenced = isSilenced(alert, silences)
+
+	fired := false
+	if alert.State == model.AlertStatePending && now.Sub(alert.StartedAt) >= rule.For() {
+		alert.State = model.AlertStateFiring
+		alert.FiredAt = &now
+		fired = true
+	}
+
+	var err error
+	if isNew {
+		err = e.store.CreateAlert(ctx, alert)
+	} else {
+		err = e.store.UpdateAlert(ctx, alert)
+	}
+	if err != nil {
+		e.logger.Error("failed to save alert",
+			zap.String("rule", rule.Name),
+			zap.String("fingerprint", alert.Fingerprint),
+			zap.Error(err))
+		return
+	}
+
+	if fired {
+		e.notify(ctx, alert)
+	}
+}
+
+// resolve 将告警标记为恢复, 仅对已触发的告警发送恢复通知
+func (e *Engine) resolve(ctx context.Context, alert *model.Alert, silences []*model.Silence, now time.Time) {
+	wasFiring := alert.State == model.AlertStateFiring
+
+	alert.State = model.AlertStateResolved
+	alert.ResolvedAt = &now
+	alert.LastEvaluatedAt = now
+	alert.Silenced = isSilenced(alert, silences)
+
+	if err := e.store.UpdateAlert(ctx, alert); err != nil {
+		e.logger.Error("failed to resolve alert",
+			zap.String("fingerprint", alert.Fingerprint),
+			zap.Error(err))
+		return
+	}
+
+	if wasFiring {
+		e.notify(ctx, alert)
+	}
+}
+
+// notify 调用所有通知器, 被静默的告警跳过
+func (e *Engine) notify(ctx context.Context, alert *model.Alert) {
+	if alert.Silenced {
+		e.logger.Debug("alert notification silenced",
+			zap.String("rule", alert.RuleName),
+			zap.String("fingerprint", alert.Fingerprint))
+		return
+	}
+
+	for _, n := range e.notifiers {
+		if err := n.Notify(ctx, alert); err != nil {
+			e.logger.Error("failed to send alert notification",
+				zap.String("rule", alert.RuleName),
+				zap.String("fingerprint", alert.Fingerprint),
+				zap.Error(err))
+		}
+	}
+}
+
+// isSilenced 检查告警是否被任一静默覆盖
+func isSilenced(alert *model.Alert, silences []*model.Silence) bool {
+	for _, s := range silences {
+		if s.Matches(alert) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,375 @@
+package alerting
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// mockStore 内存实现的 Store
+type mockStore struct {
+	mu          sync.Mutex
+	rules       []*model.AlertRule
+	alerts      map[uint]*model.Alert
+	silences    []*model.Silence
+	agents      []*model.Agent
+	connections map[string]int64
+	failures    []*model.ConfigurationApplyHistory
+	agentsErr   error
+	nextID      uint
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{
+		alerts:      make(map[uint]*model.Alert),
+		connections: make(map[string]int64),
+	}
+}
+
+func (s *mockStore) ListAlertRules(ctx context.Context) ([]*model.AlertRule, error) {
+	return s.rules, nil
+}
+
+func (s *mockStore) ListActiveAlerts(ctx context.Context) ([]*model.Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*model.Alert
+	for _, a := range s.alerts {
+		if a.State != model.AlertStateResolved {
+			copied := *a
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (s *mockStore) CreateAlert(ctx context.Context, alert *model.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	alert.ID = s.nextID
+	copied := *alert
+	s.alerts[alert.ID] = &copied
+	return nil
+}
+
+func (s *mockStore) UpdateAlert(ctx context.Context, alert *model.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *alert
+	s.alerts[alert.ID] = &copied
+	return nil
+}
+
+func (s *mockStore) ListActiveSilences(ctx context.Context, now time.Time) ([]*model.Silence, error) {
+	var result []*model.Silence
+	for _, silence := range s.silences {
+		if silence.IsActive(now) {
+			result = append(result, silence)
+		}
+	}
+	return result, nil
+}
+
+func (s *mockStore) ListAgentsBySelector(ctx context.Context, selector map[string]string) ([]*model.Agent, error) {
+	if s.agentsErr != nil {
+		return nil, s.agentsErr
+	}
+	orgID, scoped := tenant.OrganizationID(ctx)
+	var result []*model.Agent
+	for _, agent := range s.agents {
+		if scoped && agent.OrganizationID != orgID {
+			continue
+		}
+		if agent.Labels.Matches(selector) {
+			result = append(result, agent)
+		}
+	}
+	return result, nil
+}
+
+func (s *mockStore) CountConnectionsSince(ctx context.Context, since time.Time) (map[string]int64, error) {
+	return s.connections, nil
+}
+
+func (s *mockStore) ListFailedApplyHistorySince(ctx context.Context, configName string, since time.Time) ([]*model.ConfigurationApplyHistory, error) {
+	var result []*model.ConfigurationApplyHistory
+	for _, h := range s.failures {
+		if configName == "" || h.ConfigurationName == configName {
+			result = append(result, h)
+		}
+	}
+	return result, nil
+}
+
+func (s *mockStore) alertsByState(state model.AlertState) []*model.Alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*model.Alert
+	for _, a := range s.alerts {
+		if a.State == state {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// recorder 记录通知的 Notifier
+type recorder struct {
+	alerts []model.Alert
+}
+
+func (r *recorder) Notify(ctx context.Context, alert *model.Alert) error {
+	r.alerts = append(r.alerts, *alert)
+	return nil
+}
+
+// newTestEngine 创建使用可控时钟的引擎
+func newTestEngine(store Store, n Notifier) (*Engine, *time.Time) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	engine := NewEngine(store, nil, time.Minute, n)
+	engine.now = func() time.Time { return now }
+	return engine, &now
+}
+
+func agentWithStatus(id string, status model.AgentStatus, labels model.Labels) *model.Agent {
+	return &model.Agent{ID: id, Status: status, Labels: labels}
+}
+
+func TestEngine_OfflineRatioLifecycle(t *testing.T) {
+	store := newMockStore()
+	store.rules = []*model.AlertRule{{
+		ID:         1,
+		Name:       "prod-offline",
+		Type:       model.AlertRuleOfflineRatio,
+		Selector:   map[string]string{"env": "prod"},
+		Threshold:  5,
+		ForSeconds: 600,
+		Severity:   model.SeverityCritical,
+		Enabled:    true,
+	}}
+	store.agents = []*model.Agent{
+		agentWithStatus("a1", model.StatusOffline, model.Labels{"env": "prod"}),
+		agentWithStatus("a2", model.StatusOnline, model.Labels{"env": "prod"}),
+		agentWithStatus("a3", model.StatusOffline, model.Labels{"env": "dev"}),
+	}
+
+	notifier := &recorder{}
+	engine, now := newTestEngine(store, notifier)
+	ctx := context.Background()
+
+	// 条件成立但未达到持续时间: pending
+	engine.evaluate(ctx)
+	pending := store.alertsByState(model.AlertStatePending)
+	require.Len(t, pending, 1)
+	assert.Equal(t, 50.0, pending[0].Value)
+	assert.Equal(t, "prod-offline", pending[0].Labels["alertname"])
+	assert.Empty(t, notifier.alerts)
+
+	// 持续 10 分钟后: firing 并通知
+	*now = now.Add(10 * time.Minute)
+	engine.evaluate(ctx)
+	firing := store.alertsByState(model.AlertStateFiring)
+	require.Len(t, firing, 1)
+	assert.NotNil(t, firing[0].FiredAt)
+	require.Len(t, notifier.alerts, 1)
+	assert.Equal(t, model.AlertStateFiring, notifier.alerts[0].State)
+
+	// 再次评估不重复通知
+	*now = now.Add(time.Minute)
+	engine.evaluate(ctx)
+	assert.Len(t, notifier.alerts, 1)
+
+	// Agent 恢复在线: resolved 并通知
+	store.agents[0].Status = model.StatusOnline
+	*now = now.Add(time.Minute)
+	engine.evaluate(ctx)
+	resolved := store.alertsByState(model.AlertStateResolved)
+	require.Len(t, resolved, 1)
+	assert.NotNil(t, resolved[0].ResolvedAt)
+	require.Len(t, notifier.alerts, 2)
+	assert.Equal(t, model.AlertStateResolved, notifier.alerts[1].State)
+}
+
+func TestEngine_PendingResolvesWithoutNotification(t *testing.T) {
+	store := newMockStore()
+	store.rules = []*model.AlertRule{{
+		ID: 1, Name: "offline", Type: model.AlertRuleOfflineRatio, ForSeconds: 600, Enabled: true,
+	}}
+	store.agents = []*model.Agent{agentWithStatus("a1", model.StatusOffline, nil)}
+
+	notifier := &recorder{}
+	engine, _ := newTestEngine(store, notifier)
+
+	engine.evaluate(context.Background())
+	require.Len(t, store.alertsByState(model.AlertStatePending), 1)
+
+	store.agents[0].Status = model.StatusOnline
+	engine.evaluate(context.Background())
+	assert.Len(t, store.alertsByState(model.AlertStateResolved), 1)
+	assert.Empty(t, notifier.alerts)
+}
+
+func TestEngine_FlappingPerAgent(t *testing.T) {
+	store := newMockStore()
+	store.rules = []*model.AlertRule{{
+		ID: 1, Name: "flapping", Type: model.AlertRuleFlapping, Threshold: 3, WindowSeconds: 900, Enabled: true,
+	}}
+	store.agents = []*model.Agent{
+		agentWithStatus("a1", model.StatusOnline, nil),
+		agentWithStatus("a2", model.StatusOnline, nil),
+		agentWithStatus("a3", model.StatusOnline, nil),
+	}
+	store.connections = map[string]int64{"a1": 5, "a2": 3, "a3": 4}
+
+	notifier := &recorder{}
+	engine, _ := newTestEngine(store, notifier)
+	engine.evaluate(context.Background())
+
+	// ForSeconds 为 0 时立即触发
+	firing := store.alertsByState(model.AlertStateFiring)
+	require.Len(t, firing, 2)
+	ids := []string{firing[0].AgentID, firing[1].AgentID}
+	assert.ElementsMatch(t, []string{"a1", "a3"}, ids)
+	assert.Len(t, notifier.alerts, 2)
+}
+
+func TestEngine_ConfigFailed(t *testing.T) {
+	store := newMockStore()
+	store.rules = []*model.AlertRule{{
+		ID: 1, Name: "cfg-failed", Type: model.AlertRuleConfigFailed,
+		ConfigurationName: "prod-config", WindowSeconds: 3600, Enabled: true,
+	}}
+	store.failures = []*model.ConfigurationApplyHistory{
+		{AgentID: "a1", ConfigurationName: "prod-config", Status: model.ApplyStatusFailed},
+		{AgentID: "a1", ConfigurationName: "prod-config", Status: model.ApplyStatusFailed},
+		{AgentID: "a2", ConfigurationName: "other", Status: model.ApplyStatusFailed},
+	}
+
+	engine, _ := newTestEngine(store, &recorder{})
+	engine.evaluate(context.Background())
+
+	firing := store.alertsByState(model.AlertStateFiring)
+	require.Len(t, firing, 1)
+	assert.Equal(t, 1.0, firing[0].Value)
+	assert.Contains(t, firing[0].Summary, "prod-config")
+	assert.Contains(t, firing[0].Summary, "a1")
+}
+
+func TestEngine_SilenceSuppressesNotification(t *testing.T) {
+	store := newMockStore()
+	ruleID := uint(1)
+	store.rules = []*model.AlertRule{{
+		ID: ruleID, Name: "offline", Type: model.AlertRuleOfflineRatio, Enabled: true,
+	}}
+	store.agents = []*model.Agent{agentWithStatus("a1", model.StatusOffline, nil)}
+
+	notifier := &recorder{}
+	engine, now := newTestEngine(store, notifier)
+	store.silences = []*model.Silence{{
+		RuleID:   &ruleID,
+		StartsAt: now.Add(-time.Minute),
+		EndsAt:   now.Add(time.Hour),
+	}}
+
+	engine.evaluate(context.Background())
+	firing := store.alertsByState(model.AlertStateFiring)
+	require.Len(t, firing, 1)
+	assert.True(t, firing[0].Silenced)
+	assert.Empty(t, notifier.alerts)
+}
+
+func TestEngine_RulesEvaluatedPerOrganization(t *testing.T) {
+	store := newMockStore()
+	store.rules = []*model.AlertRule{
+		{ID: 1, OrganizationID: 1, Name: "offline", Type: model.AlertRuleOfflineRatio, Enabled: true},
+		{ID: 2, OrganizationID: 2, Name: "offline", Type: model.AlertRuleOfflineRatio, Enabled: true},
+	}
+	store.agents = []*model.Agent{
+		{ID: "a1", OrganizationID: 1, Status: model.StatusOnline},
+		{ID: "a2", OrganizationID: 2, Status: model.StatusOffline},
+	}
+
+	notifier := &recorder{}
+	engine, now := newTestEngine(store, notifier)
+	// 其他组织的静默不影响本组织的告警
+	store.silences = []*model.Silence{{
+		OrganizationID: 1,
+		StartsAt:       now.Add(-time.Minute),
+		EndsAt:         now.Add(time.Hour),
+	}}
+
+	engine.evaluate(context.Background())
+	firing := store.alertsByState(model.AlertStateFiring)
+	require.Len(t, firing, 1, "只有组织 2 的 Agent 离线")
+	assert.Equal(t, uint(2), firing[0].RuleID)
+	assert.Equal(t, uint(2), firing[0].OrganizationID)
+	assert.False(t, firing[0].Silenced)
+	require.Len(t, notifier.alerts, 1)
+}
+
+func TestEngine_OfflineRatioIgnoresErrorStatus(t *testing.T) {
+	store := newMockStore()
+	store.rules = []*model.AlertRule{{ID: 1, Name: "offline", Type: model.AlertRuleOfflineRatio, Threshold: 40, Enabled: true}}
+	store.agents = []*model.Agent{
+		agentWithStatus("a1", model.StatusOffline, nil),
+		agentWithStatus("a2", model.StatusError, nil),
+		agentWithStatus("a3", model.StatusOnline, nil),
+	}
+
+	engine, _ := newTestEngine(store, &recorder{})
+	engine.evaluate(context.Background())
+
+	// 只有 a1 离线, 比例 33.3% 未超过阈值
+	assert.Empty(t, store.alertsByState(model.AlertStatePending))
+	assert.Empty(t, store.alertsByState(model.AlertStateFiring))
+}
+
+func TestEngine_DisabledRuleResolvesAlerts(t *testing.T) {
+	store := newMockStore()
+	rule := &model.AlertRule{ID: 1, Name: "offline", Type: model.AlertRuleOfflineRatio, Enabled: true}
+	store.rules = []*model.AlertRule{rule}
+	store.agents = []*model.Agent{agentWithStatus("a1", model.StatusOffline, nil)}
+
+	notifier := &recorder{}
+	engine, _ := newTestEngine(store, notifier)
+	engine.evaluate(context.Background())
+	require.Len(t, store.alertsByState(model.AlertStateFiring), 1)
+
+	rule.Enabled = false
+	engine.evaluate(context.Background())
+	assert.Len(t, store.alertsByState(model.AlertStateResolved), 1)
+	assert.Len(t, notifier.alerts, 2)
+}
+
+func TestEngine_EvaluationErrorKeepsAlerts(t *testing.T) {
+	store := newMockStore()
+	store.rules = []*model.AlertRule{{ID: 1, Name: "offline", Type: model.AlertRuleOfflineRatio, Enabled: true}}
+	store.agents = []*model.Agent{agentWithStatus("a1", model.StatusOffline, nil)}
+
+	engine, _ := newTestEngine(store, &recorder{})
+	engine.evaluate(context.Background())
+	require.Len(t, store.alertsByState(model.AlertStateFiring), 1)
+
+	store.agentsErr = errors.New("database unavailable")
+	engine.evaluate(context.Background())
+	assert.Len(t, store.alertsByState(model.AlertStateFiring), 1)
+	assert.Empty(t, store.alertsByState(model.AlertStateResolved))
+}
+
+func TestEngine_StartStop(t *testing.T) {
+	store := newMockStore()
+	engine := NewEngine(store, nil, 10*time.Millisecond)
+
+	engine.Start(context.Background())
+	time.Sleep(30 * time.Millisecond)
+	engine.Stop()
+}
@@ -0,0 +1,190 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// result 表示一次规则评估中条件成立的目标
+type result struct {
+	fingerprint string
+	agentID     string
+	labels      model.Labels
+	value       float64
+	summary     string
+}
+
+// evaluateRule 根据规则类型评估规则, 返回条件成立的目标
+func (e *Engine) evaluateRule(ctx context.Context, rule *model.AlertRule, now time.Time) ([]result, error) {
+	switch rule.Type {
+	case model.AlertRuleOfflineRatio:
+		return e.evaluateOfflineRatio(ctx, rule)
+	case model.AlertRuleFlapping:
+		return e.evaluateFlapping(ctx, rule, now)
+	case model.AlertRuleConfigFailed:
+		return e.evaluateConfigFailed(ctx, rule, now)
+	default:
+		return nil, fmt.Errorf("unsupported rule type: %s", rule.Type)
+	}
+}
+
+// evaluateOfflineRatio 离线比例 (百分比) 超过阈值时触发, 整条规则只产生一个告警
+func (e *Engine) evaluateOfflineRatio(ctx context.Context, rule *model.AlertRule) ([]result, error) {
+	agents, err := e.store.ListAgentsBySelector(ctx, rule.Selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(agents) == 0 {
+		return nil, nil
+	}
+
+	offline := 0
+	for _, agent := range agents {
+		// error 等状态的 Agent 仍然连接着, 不计入离线
+		if agent.Status == model.StatusOffline {
+			offline++
+		}
+	}
+
+	ratio := float64(offline) / float64(len(agents)) * 100
+	if ratio <= rule.Threshold {
+		return nil, nil
+	}
+
+	return []result{{
+		fingerprint: ruleFingerprint(rule, ""),
+		labels:      ruleLabels(rule, nil),
+		value:       ratio,
+		summary: fmt.Sprintf("%d of %d agents (%.1f%%) are offline, threshold %.1f%%",
+			offline, len(agents), ratio, rule.Threshold),
+	}}, nil
+}
+
+// evaluateFlapping 窗口内连接次数超过阈值的 Agent 各产生一个告警
+func (e *Engine) evaluateFlapping(ctx context.Context, rule *model.AlertRule, now time.Time) ([]result, error) {
+	counts, err := e.store.CountConnectionsSince(ctx, now.Add(-rule.Window()))
+	if err != nil {
+		return nil, err
+	}
+
+	agents, err := e.store.ListAgentsBySelector(ctx, rule.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []result
+	for _, agent := range agents {
+		if rule.AgentID != "" && agent.ID != rule.AgentID {
+			continue
+		}
+
+		count := counts[agent.ID]
+		if float64(count) <= rule.Threshold {
+			continue
+		}
+
+		results = append(results, result{
+			fingerprint: ruleFingerprint(rule, agent.ID),
+			agentID:     agent.ID,
+			labels:      ruleLabels(rule, agent),
+			value:       float64(count),
+			summary: fmt.Sprintf("agent %s reconnected %d times in %s, threshold %.0f",
+				agent.ID, count, rule.Window(), rule.Threshold),
+		})
+	}
+	return results, nil
+}
+
+// evaluateConfigFailed 窗口内应用失败的 Agent 数超过阈值时触发, 整条规则只产生一个告警
+func (e *Engine) evaluateConfigFailed(ctx context.Context, rule *model.AlertRule, now time.Time) ([]result, error) {
+	histories, err := e.store.ListFailedApplyHistorySince(ctx, rule.ConfigurationName, now.Add(-rule.Window()))
+	if err != nil {
+		return nil, err
+	}
+	if len(histories) == 0 {
+		return nil, nil
+	}
+
+	// 有选择器时只统计匹配的 Agent
+	var allowed map[string]bool
+	if len(rule.Selector) > 0 {
+		agents, err := e.store.ListAgentsBySelector(ctx, rule.Selector)
+		if err != nil {
+			return nil, err
+		}
+		allowed = make(map[string]bool, len(agents))
+		for _, agent := range agents {
+			allowed[agent.ID] = true
+		}
+	}
+
+	failed := make(map[string]bool)
+	for _, h := range histories {
+		if allowed != nil && !allowed[h.AgentID] {
+			continue
+		}
+		failed[h.AgentID] = true
+	}
+
+	if float64(len(failed)) <= rule.Threshold {
+		return nil, nil
+	}
+
+	agentIDs := make([]string, 0, len(failed))
+	for id := range failed {
+		agentIDs = append(agentIDs, id)
+	}
+	sort.Strings(agentIDs)
+
+	target := "configurations"
+	if rule.ConfigurationName != "" {
+		target = "configuration " + rule.ConfigurationName
+	}
+
+	return []result{{
+		fingerprint: ruleFingerprint(rule, ""),
+		labels:      ruleLabels(rule, nil),
+		value:       float64(len(agentIDs)),
+		summary: fmt.Sprintf("%s failed on %d agents in %s: %s",
+			target, len(agentIDs), rule.Window(), truncateList(agentIDs, 10)),
+	}}, nil
+}
+
+// ruleFingerprint 生成告警指纹, 同一规则的不同目标使用不同指纹
+func ruleFingerprint(rule *model.AlertRule, agentID string) string {
+	if agentID == "" {
+		return fmt.Sprintf("rule:%d", rule.ID)
+	}
+	return fmt.Sprintf("rule:%d:agent:%s", rule.ID, agentID)
+}
+
+// ruleLabels 生成告警标签: Agent 标签 + 规则选择器 + 规则元数据
+func ruleLabels(rule *model.AlertRule, agent *model.Agent) model.Labels {
+	labels := model.Labels{}
+	if agent != nil {
+		labels = agent.Labels.Merge(nil)
+	}
+	labels = labels.Merge(rule.Selector)
+	labels["alertname"] = rule.Name
+	labels["severity"] = string(rule.Severity)
+	if agent != nil {
+		labels["agent_id"] = agent.ID
+	}
+	if rule.ConfigurationName != "" {
+		labels["configuration"] = rule.ConfigurationName
+	}
+	return labels
+}
+
+// truncateList 将列表格式化为逗号分隔的字符串, 超过 max 个时截断
+func truncateList(items []string, max int) string {
+	if len(items) <= max {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(items[:max], ", "), len(items)-max)
+}
@@ -0,0 +1,85 @@
+package alerting
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// Notifier 告警通知接口
+//
+// 告警转为 firing 或从 firing 恢复为 resolved 时调用, 被静默的告警不会通知。
+type Notifier interface {
+	Notify(ctx context.Context, alert *model.Alert) error
+}
+
+// NotifierFunc 将函数适配为 Notifier
+type NotifierFunc func(ctx context.Context, alert *model.Alert) error
+
+// Notify 实现 Notifier
+func (f NotifierFunc) Notify(ctx context.Context, alert *model.Alert) error {
+	return f(ctx, alert)
+}
+
+// LogNotifier 将告警写入日志
+type LogNotifier struct {
+	logger *zap.Logger
+}
+
+// NewLogNotifier 创建日志通知器
+func NewLogNotifier(logger *zap.Logger) *LogNotifier {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &LogNotifier{logger: logger}
+}
+
+// Notify 实现 Notifier
+func (n *LogNotifier) Notify(ctx context.Context, alert *model.Alert) error {
+	n.logger.Warn("alert "+string(alert.State),
+		zap.String("rule", alert.RuleName),
+		zap.String("severity", string(alert.Severity)),
+		zap.String("fingerprint", alert.Fingerprint),
+		zap.Float64("value", alert.Value),
+		zap.String("summary", alert.Summary))
+	return nil
+}
+
+// EventNotifier 将告警发布到事件总线, 由 SSE 和 Webhook 转发
+type EventNotifier struct {
+	bus *events.Bus
+}
+
+// NewEventNotifier 创建事件总线通知器
+func NewEventNotifier(bus *events.Bus) *EventNotifier {
+	return &EventNotifier{bus: bus}
+}
+
+// Notify 实现 Notifier
+func (n *EventNotifier) Notify(ctx context.Context, alert *model.Alert) error {
+	eventType := events.TypeAlertFiring
+	if alert.State == model.AlertStateResolved {
+		eventType = events.TypeAlertResolved
+	}
+
+	n.bus.Publish(events.Event{
+		Type:           eventType,
+		OrganizationID: alert.OrganizationID,
+		AgentID:        alert.AgentID,
+		Labels:         alert.Labels,
+		Data: map[string]interface{}{
+			"alert_id":    alert.ID,
+			"rule_id":     alert.RuleID,
+			"rule":        alert.RuleName,
+			"severity":    alert.Severity,
+			"summary":     alert.Summary,
+			"value":       alert.Value,
+			"started_at":  alert.StartedAt,
+			"resolved_at": alert.ResolvedAt,
+		},
+	})
+	return nil
+}
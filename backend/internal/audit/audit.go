@@ -0,0 +1,237 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/middleware"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// context keys, 处理器通过这些值补充审计信息
+const (
+	actionKey = "audit_action"
+	targetKey = "audit_target"
+	changeKey = "audit_change"
+	actorKey  = "audit_actor"
+)
+
+// target 处理器设置的操作目标
+type target struct {
+	kind string
+	id   string
+}
+
+// change 处理器设置的修改前后的对象
+type change struct {
+	before interface{}
+	after  interface{}
+}
+
+// Store 审计日志存储接口
+type Store interface {
+	// CreateAuditLog 追加一条审计日志
+	CreateAuditLog(ctx context.Context, entry *model.AuditLog) error
+}
+
+// Recorder 写入审计日志
+//
+// 写入失败只记录错误日志, 不影响业务请求。nil Recorder 不记录任何内容。
+type Recorder struct {
+	store  Store
+	logger *zap.Logger
+}
+
+// NewRecorder 创建审计日志记录器
+func NewRecorder(store Store, logger *zap.Logger) *Recorder {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Recorder{store: store, logger: logger}
+}
+
+// Record 追加一条审计日志, 未设置操作者时视为系统操作
+func (r *Recorder) Record(ctx context.Context, entry *model.AuditLog) {
+	if r == nil || entry == nil {
+		return
+	}
+	if entry.ActorType == "" {
+		entry.ActorType = model.AuditActorSystem
+	}
+
+	// 请求结束或客户端断开后仍然要写入审计日志
+	if err := r.store.CreateAuditLog(context.WithoutCancel(ctx), entry); err != nil {
+		r.logger.Error("Failed to write audit log",
+			zap.String("action", entry.Action),
+			zap.String("actor", entry.Actor),
+			zap.String("target_id", entry.TargetID),
+			zap.Error(err),
+		)
+	}
+}
+
+// Middleware 为所有修改类请求 (非 GET/HEAD/OPTIONS) 记录审计日志
+//
+// 操作名称和目标默认由路由推导, 处理器可以通过 SetAction, SetTarget, SetChange
+// 和 SetActor 补充更准确的信息。
+func (r *Recorder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if r == nil || !isMutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		// 未匹配任何路由的请求不记录
+		route := c.FullPath()
+		if route == "" {
+			return
+		}
+
+		entry := &model.AuditLog{
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Status:    c.Writer.Status(),
+			SourceIP:  c.ClientIP(),
+			RequestID: middleware.GetRequestID(c),
+		}
+		setActor(c, entry)
+
+		entry.Action = c.GetString(actionKey)
+		if entry.Action == "" {
+			entry.Action = ActionFromRoute(c.Request.Method, route)
+		}
+		if t, ok := c.Get(targetKey); ok {
+			entry.TargetType, entry.TargetID = t.(target).kind, t.(target).id
+		} else {
+			entry.TargetType, entry.TargetID = targetFromRoute(c, route)
+		}
+		if ch, ok := c.Get(changeKey); ok {
+			entry.Changes = Diff(ch.(change).before, ch.(change).after)
+		}
+
+		r.Record(c.Request.Context(), entry)
+	}
+}
+
+// Action 返回在路由上覆盖默认操作名称的中间件
+func Action(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		SetAction(c, action)
+		c.Next()
+	}
+}
+
+// SetAction 设置本次请求的操作名称
+func SetAction(c *gin.Context, action string) {
+	c.Set(actionKey, action)
+}
+
+// SetTarget 设置本次请求的操作目标, 用于目标不在路由参数中的请求 (如创建)
+func SetTarget(c *gin.Context, targetType, targetID string) {
+	c.Set(targetKey, target{kind: targetType, id: targetID})
+}
+
+// SetChange 记录修改前后的对象, 写入审计日志时计算差异; 创建时 before 为 nil, 删除时 after 为 nil
+func SetChange(c *gin.Context, before, after interface{}) {
+	c.Set(changeKey, change{before: before, after: after})
+}
+
+// SetActor 为未认证的请求 (如登录) 设置操作者
+func SetActor(c *gin.Context, userID uint, username string) {
+	c.Set(actorKey, &model.AuditLog{ActorType: model.AuditActorUser, ActorID: optionalID(userID), Actor: username})
+}
+
+// setActor 从认证信息中填充操作者
+func setActor(c *gin.Context, entry *model.AuditLog) {
+	if claims, ok := auth.GetCurrentUser(c); ok {
+		entry.ActorType = model.AuditActorUser
+		entry.ActorID = optionalID(claims.UserID)
+		entry.Actor = claims.Username
+		if claims.APITokenID != 0 {
+			entry.ActorType = model.AuditActorAPIToken
+			entry.APITokenID = optionalID(claims.APITokenID)
+		}
+		return
+	}
+	if actor, ok := c.Get(actorKey); ok {
+		a := actor.(*model.AuditLog)
+		entry.ActorType, entry.ActorID, entry.Actor = a.ActorType, a.ActorID, a.Actor
+		return
+	}
+	entry.ActorType = model.AuditActorAnonymous
+}
+
+// versionSegment 匹配路由中的 API 版本段 (如 v1)
+var versionSegment = regexp.MustCompile(`^v[0-9]+$`)
+
+// ActionFromRoute 根据请求方法和路由推导操作名称
+//
+// 路由中的静态段用 "." 连接, PUT/PATCH/DELETE 追加 update/delete,
+// 对集合的 POST 追加 create; 其他 POST 视为动作 (如 configurations.push)。
+func ActionFromRoute(method, route string) string {
+	var statics []string
+	for _, segment := range strings.Split(route, "/") {
+		if segment == "" || segment == "api" || versionSegment.MatchString(segment) ||
+			strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			continue
+		}
+		statics = append(statics, segment)
+	}
+	action := strings.Join(statics, ".")
+
+	switch method {
+	case http.MethodPut, http.MethodPatch:
+		return action + ".update"
+	case http.MethodDelete:
+		return action + ".delete"
+	case http.MethodPost:
+		if len(statics) == 1 {
+			return action + ".create"
+		}
+	}
+	return action
+}
+
+// targetFromRoute 默认以第一个路由参数为目标 ID, 参数之前的静态段为目标类型 (如 alerts.rules);
+// 没有路由参数时以第一个静态段为目标类型
+func targetFromRoute(c *gin.Context, route string) (string, string) {
+	var statics []string
+	for _, segment := range strings.Split(route, "/") {
+		if segment == "" || segment == "api" || versionSegment.MatchString(segment) {
+			continue
+		}
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			return strings.Join(statics, "."), c.Param(segment[1:])
+		}
+		statics = append(statics, segment)
+	}
+	if len(statics) == 0 {
+		return "", ""
+	}
+	return statics[0], ""
+}
+
+// isMutating 检查请求方法是否会修改数据
+func isMutating(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// optionalID 0 表示没有 ID
+func optionalID(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,205 @@
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/auth"
+	"github.com/cc1024201/opamp-platform/internal/middleware"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// memoryStore 在内存中保存审计日志
+type memoryStore struct {
+	mu      sync.Mutex
+	entries []*model.AuditLog
+}
+
+func (s *memoryStore) CreateAuditLog(ctx context.Context, entry *model.AuditLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func setupAuditRouter(store *memoryStore, claims *auth.Claims) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	router.Use(NewRecorder(store, nil).Middleware())
+	router.Use(func(c *gin.Context) {
+		if claims != nil {
+			c.Set(auth.AuthorizationPayloadKey, claims)
+		}
+		c.Next()
+	})
+	return router
+}
+
+func TestActionFromRoute(t *testing.T) {
+	tests := []struct {
+		method string
+		route  string
+		want   string
+	}{
+		{http.MethodPost, "/api/v1/configurations", "configurations.create"},
+		{http.MethodPut, "/api/v1/configurations/:name", "configurations.update"},
+		{http.MethodDelete, "/api/v1/configurations/:name", "configurations.delete"},
+		{http.MethodPost, "/api/v1/configurations/:name/push", "configurations.push"},
+		{http.MethodPost, "/api/v1/configurations/:name/rollback/:version", "configurations.rollback"},
+		{http.MethodPut, "/api/v1/users/:id/role", "users.role.update"},
+		{http.MethodDelete, "/api/v1/users/:id/tokens/:token_id", "users.tokens.delete"},
+		{http.MethodPost, "/api/v1/auth/login", "auth.login"},
+		{http.MethodPost, "/api/v1/me/mfa/enroll", "me.mfa.enroll"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.route, func(t *testing.T) {
+			assert.Equal(t, tt.want, ActionFromRoute(tt.method, tt.route))
+		})
+	}
+}
+
+func TestMiddleware_RecordsMutatingRequests(t *testing.T) {
+	store := &memoryStore{}
+	router := setupAuditRouter(store, &auth.Claims{UserID: 7, Username: "alice", Role: "admin"})
+
+	type config struct {
+		Name      string `json:"name"`
+		RawConfig string `json:"raw_config"`
+		Secret    string `json:"-"`
+	}
+	router.PUT("/api/v1/configurations/:name", func(c *gin.Context) {
+		SetChange(c,
+			&config{Name: "web", RawConfig: "a: 1", Secret: "old"},
+			&config{Name: "web", RawConfig: "a: 2", Secret: "new"},
+		)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	router.GET("/api/v1/configurations/:name", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+
+	// 查询请求不记录
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/configurations/web", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, store.entries)
+
+	req = httptest.NewRequest(http.MethodPut, "/api/v1/configurations/web", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "req-123", w.Header().Get(middleware.RequestIDHeader))
+
+	require.Len(t, store.entries, 1)
+	entry := store.entries[0]
+	assert.Equal(t, model.AuditActorUser, entry.ActorType)
+	require.NotNil(t, entry.ActorID)
+	assert.Equal(t, uint(7), *entry.ActorID)
+	assert.Equal(t, "alice", entry.Actor)
+	assert.Equal(t, "configurations.update", entry.Action)
+	assert.Equal(t, "configurations", entry.TargetType)
+	assert.Equal(t, "web", entry.TargetID)
+	assert.Equal(t, http.StatusOK, entry.Status)
+	assert.Equal(t, "req-123", entry.RequestID)
+	assert.Equal(t, map[string]model.AuditChange{
+		"raw_config": {Before: "a: 1", After: "a: 2"},
+	}, entry.Changes, "只记录变化的字段, 不包含 json:\"-\" 字段")
+}
+
+func TestMiddleware_ActorAndOverrides(t *testing.T) {
+	t.Run("api token", func(t *testing.T) {
+		store := &memoryStore{}
+		router := setupAuditRouter(store, &auth.Claims{UserID: 3, Username: "ci", APITokenID: 9})
+		router.POST("/api/v1/alerts/rules", Action("alerts.rules.create"), func(c *gin.Context) {
+			SetTarget(c, "alerts.rules", "42")
+			c.JSON(http.StatusCreated, gin.H{})
+		})
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/alerts/rules", nil))
+
+		require.Len(t, store.entries, 1)
+		entry := store.entries[0]
+		assert.Equal(t, model.AuditActorAPIToken, entry.ActorType)
+		require.NotNil(t, entry.APITokenID)
+		assert.Equal(t, uint(9), *entry.APITokenID)
+		assert.Equal(t, "alerts.rules.create", entry.Action)
+		assert.Equal(t, "alerts.rules", entry.TargetType)
+		assert.Equal(t, "42", entry.TargetID)
+		assert.NotEmpty(t, entry.RequestID, "未传入请求 ID 时自动生成")
+	})
+
+	t.Run("anonymous and failed requests", func(t *testing.T) {
+		store := &memoryStore{}
+		router := setupAuditRouter(store, nil)
+		router.POST("/api/v1/auth/login", func(c *gin.Context) {
+			SetActor(c, 0, "mallory")
+			c.JSON(http.StatusUnauthorized, gin.H{})
+		})
+		router.POST("/api/v1/auth/register", func(c *gin.Context) {
+			c.JSON(http.StatusForbidden, gin.H{})
+		})
+
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", nil))
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/unknown", nil))
+
+		require.Len(t, store.entries, 2, "未匹配路由的请求不记录")
+		assert.Equal(t, model.AuditActorUser, store.entries[0].ActorType)
+		assert.Nil(t, store.entries[0].ActorID)
+		assert.Equal(t, "mallory", store.entries[0].Actor)
+		assert.Equal(t, http.StatusUnauthorized, store.entries[0].Status)
+		assert.Equal(t, model.AuditActorAnonymous, store.entries[1].ActorType)
+		assert.Equal(t, "auth.register", store.entries[1].Action)
+	})
+}
+
+func TestRecorder_Record(t *testing.T) {
+	store := &memoryStore{}
+	recorder := NewRecorder(store, nil)
+
+	recorder.Record(context.Background(), &model.AuditLog{Action: "agents.config.send", TargetID: "agent-1"})
+	require.Len(t, store.entries, 1)
+	assert.Equal(t, model.AuditActorSystem, store.entries[0].ActorType)
+
+	// nil Recorder 不记录
+	var disabled *Recorder
+	disabled.Record(context.Background(), &model.AuditLog{Action: "ignored"})
+	assert.Len(t, store.entries, 1)
+}
+
+func TestDiff(t *testing.T) {
+	type role struct {
+		Name        string            `json:"name"`
+		Permissions []string          `json:"permissions"`
+		Selector    map[string]string `json:"selector,omitempty"`
+	}
+
+	before := &role{Name: "dev", Permissions: []string{"agents:read"}}
+	after := &role{Name: "dev", Permissions: []string{"agents:read", "agents:write"}, Selector: map[string]string{"env": "dev"}}
+
+	changes := Diff(before, after)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, []interface{}{"agents:read"}, changes["permissions"].Before)
+	assert.Equal(t, []interface{}{"agents:read", "agents:write"}, changes["permissions"].After)
+	assert.Nil(t, changes["selector"].Before)
+	assert.Equal(t, map[string]interface{}{"env": "dev"}, changes["selector"].After)
+
+	// 创建和删除
+	created := Diff(nil, after)
+	assert.Equal(t, "dev", created["name"].After)
+	assert.Nil(t, created["name"].Before)
+	deleted := Diff(before, (*role)(nil))
+	assert.Equal(t, "dev", deleted["name"].Before)
+	assert.Nil(t, deleted["name"].After)
+
+	// 没有变化
+	assert.Nil(t, Diff(before, before))
+}
@@ -0,0 +1,72 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// ignoredFields 每次修改都会变化的字段, 不计入差异
+var ignoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// Diff 比较两个对象的 JSON 表示, 返回发生变化的顶层字段
+//
+// 对象按 JSON 序列化比较, 标记为 json:"-" 的敏感字段 (如密码哈希) 不会出现在结果中。
+// before 为 nil 表示创建, after 为 nil 表示删除。
+func Diff(before, after interface{}) map[string]model.AuditChange {
+	beforeFields := toFields(before)
+	afterFields := toFields(after)
+
+	changes := make(map[string]model.AuditChange)
+	for key, oldValue := range beforeFields {
+		if ignoredFields[key] {
+			continue
+		}
+		newValue, ok := afterFields[key]
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			changes[key] = model.AuditChange{Before: oldValue, After: newValue}
+		}
+	}
+	for key, newValue := range afterFields {
+		if ignoredFields[key] {
+			continue
+		}
+		if _, ok := beforeFields[key]; !ok {
+			changes[key] = model.AuditChange{Before: nil, After: newValue}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+// toFields 将对象转换为字段表, 非对象的值放在 "value" 字段中
+func toFields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err != nil {
+			return nil
+		}
+		return map[string]interface{}{"value": value}
+	}
+	return fields
+}
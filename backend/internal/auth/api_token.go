@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// APITokenPrefix API 令牌的固定前缀, 用于区分 API 令牌和 JWT
+const APITokenPrefix = "opat_"
+
+// apiTokenTouchInterval 最后使用时间的最小更新间隔, 避免每个请求都写数据库
+const apiTokenTouchInterval = time.Minute
+
+var (
+	ErrRevokedToken        = errors.New("token has been revoked")
+	ErrServiceAccountLogin = errors.New("service accounts cannot log in with a password")
+)
+
+// TokenVerifier 校验 JWT 以外的 Bearer 令牌
+type TokenVerifier interface {
+	// Supports 判断令牌是否由该校验器处理
+	Supports(token string) bool
+	// Verify 校验令牌并返回对应的 Claims
+	Verify(ctx context.Context, token string) (*Claims, error)
+}
+
+// APITokenStore 定义 API 令牌校验所需的存储接口
+type APITokenStore interface {
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, error)
+	TouchAPIToken(ctx context.Context, id uint, usedAt time.Time) error
+	GetUserByID(ctx context.Context, id uint) (*model.User, error)
+}
+
+// APITokenVerifier 校验 API 令牌
+type APITokenVerifier struct {
+	store APITokenStore
+	now   func() time.Time
+}
+
+// NewAPITokenVerifier 创建 API 令牌校验器
+func NewAPITokenVerifier(store APITokenStore) *APITokenVerifier {
+	return &APITokenVerifier{store: store, now: time.Now}
+}
+
+// GenerateAPIToken 生成新的 API 令牌, 返回明文令牌和用于展示的前缀
+func GenerateAPIToken() (token, prefix string, err error) {
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	token = APITokenPrefix + secret
+	return token, token[:len(APITokenPrefix)+8], nil
+}
+
+// Supports 判断是否为 API 令牌
+func (v *APITokenVerifier) Supports(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// Verify 校验 API 令牌, 并按需更新最后使用时间
+func (v *APITokenVerifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	apiToken, err := v.store.GetAPITokenByHash(ctx, HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if apiToken == nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := v.now()
+	if apiToken.RevokedAt != nil {
+		return nil, ErrRevokedToken
+	}
+	if !apiToken.IsUsable(now) {
+		return nil, ErrExpiredToken
+	}
+
+	user, err := v.store.GetUserByID(ctx, apiToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive {
+		return nil, ErrInvalidToken
+	}
+
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) >= apiTokenTouchInterval {
+		// 最后使用时间只用于展示, 更新失败不影响认证
+		_ = v.store.TouchAPIToken(ctx, apiToken.ID, now)
+	}
+
+	return &Claims{
+		UserID:         user.ID,
+		Username:       user.Username,
+		Role:           user.Role,
+		OrganizationID: user.OrganizationID,
+		APITokenID:     apiToken.ID,
+		Scopes:         apiToken.Scopes,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+type mockAPITokenStore struct {
+	tokens  map[string]*model.APIToken
+	users   map[uint]*model.User
+	touched []uint
+}
+
+func (m *mockAPITokenStore) GetAPITokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, error) {
+	return m.tokens[tokenHash], nil
+}
+
+func (m *mockAPITokenStore) TouchAPIToken(ctx context.Context, id uint, usedAt time.Time) error {
+	m.touched = append(m.touched, id)
+	return nil
+}
+
+func (m *mockAPITokenStore) GetUserByID(ctx context.Context, id uint) (*model.User, error) {
+	return m.users[id], nil
+}
+
+func (m *mockAPITokenStore) GetRoleByName(ctx context.Context, name string) (*model.Role, error) {
+	return nil, nil
+}
+
+func (m *mockAPITokenStore) addToken(t *testing.T, token *model.APIToken) string {
+	plaintext, prefix, err := GenerateAPIToken()
+	require.NoError(t, err)
+	token.Prefix = prefix
+	token.TokenHash = HashToken(plaintext)
+	m.tokens[token.TokenHash] = token
+	return plaintext
+}
+
+func TestAPITokenVerifier(t *testing.T) {
+	store := &mockAPITokenStore{
+		tokens: make(map[string]*model.APIToken),
+		users: map[uint]*model.User{
+			1: {ID: 1, Username: "ci", Role: RoleOperator, IsActive: true, IsServiceAccount: true},
+			2: {ID: 2, Username: "gone", Role: RoleAdmin, IsActive: false},
+		},
+	}
+	verifier := NewAPITokenVerifier(store)
+	ctx := context.Background()
+
+	valid := store.addToken(t, &model.APIToken{ID: 1, UserID: 1, Scopes: []string{"configurations:push"}})
+	assert.True(t, verifier.Supports(valid))
+
+	claims, err := verifier.Verify(ctx, valid)
+	require.NoError(t, err)
+	assert.Equal(t, "ci", claims.Username)
+	assert.Equal(t, RoleOperator, claims.Role)
+	assert.Equal(t, uint(1), claims.APITokenID)
+	assert.Equal(t, []uint{1}, store.touched)
+
+	// 最近使用过的令牌不会重复更新使用时间
+	now := time.Now()
+	store.tokens[HashToken(valid)].LastUsedAt = &now
+	_, err = verifier.Verify(ctx, valid)
+	require.NoError(t, err)
+	assert.Len(t, store.touched, 1)
+
+	past := time.Now().Add(-time.Hour)
+	expired := store.addToken(t, &model.APIToken{ID: 2, UserID: 1, ExpiresAt: &past})
+	_, err = verifier.Verify(ctx, expired)
+	assert.ErrorIs(t, err, ErrExpiredToken)
+
+	revoked := store.addToken(t, &model.APIToken{ID: 3, UserID: 1, RevokedAt: &past})
+	_, err = verifier.Verify(ctx, revoked)
+	assert.ErrorIs(t, err, ErrRevokedToken)
+
+	disabledUser := store.addToken(t, &model.APIToken{ID: 4, UserID: 2})
+	_, err = verifier.Verify(ctx, disabledUser)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = verifier.Verify(ctx, APITokenPrefix+"unknown")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestAuthMiddleware_APIToken(t *testing.T) {
+	manager := NewJWTManager("test-secret", 24*time.Hour)
+	store := &mockAPITokenStore{
+		tokens: make(map[string]*model.APIToken),
+		users: map[uint]*model.User{
+			1: {ID: 1, Username: "ci", Role: RoleOperator, IsActive: true},
+		},
+	}
+	authorizer := NewAuthorizer(store)
+	token := store.addToken(t, &model.APIToken{ID: 1, UserID: 1, Scopes: []string{"configurations:*"}})
+
+	router := setupTestRouter()
+	router.Use(AuthMiddleware(manager, NewAPITokenVerifier(store)))
+	router.POST("/push", authorizer.Require(PermConfigurationsPush), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.DELETE("/agents", authorizer.Require(PermAgentsDelete), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		want   int
+	}{
+		{"scope allows push", token, http.MethodPost, "/push", http.StatusNoContent},
+		{"role allows but scope denies", token, http.MethodDelete, "/agents", http.StatusForbidden},
+		{"unknown api token", APITokenPrefix + "nope", http.MethodPost, "/push", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tt.token))
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	// JWT 仍然可用
+	jwtToken, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "ci", Role: RoleOperator})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/agents", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", jwtToken))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
@@ -1,10 +1,29 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// DefaultRefreshTokenDuration 刷新令牌的默认有效期
+const DefaultRefreshTokenDuration = 30 * 24 * time.Hour
+
+// MFAChallengeDuration 两步登录中 MFA 挑战令牌 (以及修改过期密码令牌) 的有效期
+const MFAChallengeDuration = 5 * time.Minute
+
+// MFA 挑战令牌的用途
+const (
+	// PurposeMFAVerify 用户已启用 MFA, 需要提交验证码或恢复码
+	PurposeMFAVerify = "mfa"
+	// PurposeMFAEnroll 用户的角色要求 MFA 但尚未绑定, 需要先完成绑定
+	PurposeMFAEnroll = "mfa_enroll"
+	// PurposePasswordChange 用户的密码已过期, 需要先设置新密码
+	PurposePasswordChange = "password_change"
 )
 
 var (
@@ -17,42 +36,146 @@ type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// OrganizationID 用户所属组织, 旧版本签发的令牌中没有该字段, 视为默认组织
+	OrganizationID uint `json:"org,omitempty"`
+	// TokenVersion 签发时用户的令牌版本, 与用户当前版本不一致时令牌失效
+	TokenVersion int `json:"ver,omitempty"`
+	// Purpose 非空表示这是 MFA 挑战令牌, 不能作为访问令牌使用
+	Purpose string `json:"purpose,omitempty"`
+
+	// 以下字段只在使用 API 令牌认证时设置, 不会出现在 JWT 中
+	APITokenID uint     `json:"-"`
+	Scopes     []string `json:"-"`
+
 	jwt.RegisteredClaims
 }
 
+// RevocationChecker 检查已签发的访问令牌是否已被撤销
+type RevocationChecker interface {
+	// Check 令牌已被撤销时返回 ErrRevokedToken
+	Check(ctx context.Context, claims *Claims) error
+}
+
 // JWTManager JWT 管理器
 type JWTManager struct {
-	secretKey     string
-	tokenDuration time.Duration
+	secretKey            string
+	tokenDuration        time.Duration
+	refreshTokenDuration time.Duration
+	revocation           RevocationChecker
 }
 
 // NewJWTManager 创建 JWT 管理器
 func NewJWTManager(secretKey string, tokenDuration time.Duration) *JWTManager {
 	return &JWTManager{
-		secretKey:     secretKey,
-		tokenDuration: tokenDuration,
+		secretKey:            secretKey,
+		tokenDuration:        tokenDuration,
+		refreshTokenDuration: DefaultRefreshTokenDuration,
 	}
 }
 
-// GenerateToken 生成 JWT token
-func (m *JWTManager) GenerateToken(userID uint, username, role string) (string, error) {
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.tokenDuration)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+// SetRefreshTokenDuration 设置刷新令牌有效期
+func (m *JWTManager) SetRefreshTokenDuration(d time.Duration) {
+	if d > 0 {
+		m.refreshTokenDuration = d
+	}
+}
+
+// SetRevocationChecker 设置撤销检查, 设置后 AuthMiddleware 会拒绝已撤销的访问令牌
+func (m *JWTManager) SetRevocationChecker(checker RevocationChecker) {
+	m.revocation = checker
+}
+
+// TokenDuration 返回访问令牌有效期
+func (m *JWTManager) TokenDuration() time.Duration {
+	return m.tokenDuration
+}
+
+// RefreshTokenDuration 返回刷新令牌有效期
+func (m *JWTManager) RefreshTokenDuration() time.Duration {
+	return m.refreshTokenDuration
+}
+
+// GenerateAccessToken 为用户生成访问令牌, 令牌绑定用户当前的令牌版本
+func (m *JWTManager) GenerateAccessToken(user *model.User) (string, error) {
+	return m.generate(Claims{
+		UserID:         user.ID,
+		Username:       user.Username,
+		Role:           user.Role,
+		OrganizationID: user.OrganizationID,
+		TokenVersion:   user.TokenVersion,
+	})
+}
+
+// GenerateMFAChallenge 为已通过密码校验的用户生成短期 MFA 挑战令牌
+func (m *JWTManager) GenerateMFAChallenge(user *model.User, purpose string) (string, error) {
+	return m.generateWithDuration(Claims{
+		UserID:         user.ID,
+		Username:       user.Username,
+		Role:           user.Role,
+		OrganizationID: user.OrganizationID,
+		TokenVersion:   user.TokenVersion,
+		Purpose:        purpose,
+	}, MFAChallengeDuration)
+}
+
+// VerifyMFAChallenge 验证 MFA 挑战令牌, 令牌用途必须与 purpose 一致
+func (m *JWTManager) VerifyMFAChallenge(tokenString, purpose string) (*Claims, error) {
+	claims, err := m.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// generate 补全有效期和令牌 ID 并签名
+func (m *JWTManager) generate(claims Claims) (string, error) {
+	return m.generateWithDuration(claims, m.tokenDuration)
+}
+
+// generateWithDuration 使用指定有效期生成令牌
+func (m *JWTManager) generateWithDuration(claims Claims, duration time.Duration) (string, error) {
+	jti, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        jti[:32],
+		ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(m.secretKey))
 }
 
-// VerifyToken 验证 JWT token
+// checkRevocation 检查访问令牌是否已被撤销, 未设置撤销检查时总是通过
+func (m *JWTManager) checkRevocation(ctx context.Context, claims *Claims) error {
+	if m.revocation == nil {
+		return nil
+	}
+	return m.revocation.Check(ctx, claims)
+}
+
+// VerifyToken 验证 JWT token, MFA 挑战令牌不能通过验证
 func (m *JWTManager) VerifyToken(tokenString string) (*Claims, error) {
+	claims, err := m.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// parse 校验签名和有效期并解析 claims
+func (m *JWTManager) parse(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&Claims{},
@@ -7,6 +7,8 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
 )
 
 func TestNewJWTManager(t *testing.T) {
@@ -20,48 +22,42 @@ func TestNewJWTManager(t *testing.T) {
 	assert.Equal(t, duration, manager.tokenDuration)
 }
 
-func TestJWTManager_GenerateToken(t *testing.T) {
+func TestJWTManager_GenerateAccessToken(t *testing.T) {
 	manager := NewJWTManager("test-secret", 24*time.Hour)
 
 	tests := []struct {
-		name     string
-		userID   uint
-		username string
-		role     string
+		name string
+		user *model.User
 	}{
 		{
-			name:     "admin user",
-			userID:   1,
-			username: "admin",
-			role:     "admin",
+			name: "admin user",
+			user: &model.User{ID: 1, Username: "admin", Role: "admin", OrganizationID: 1},
 		},
 		{
-			name:     "regular user",
-			userID:   2,
-			username: "user123",
-			role:     "user",
+			name: "regular user",
+			user: &model.User{ID: 2, Username: "user123", Role: "user", OrganizationID: 2, TokenVersion: 3},
 		},
 		{
-			name:     "user with special characters",
-			userID:   3,
-			username: "user@example.com",
-			role:     "user",
+			name: "user with special characters",
+			user: &model.User{ID: 3, Username: "user@example.com", Role: "user", OrganizationID: 1},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			token, err := manager.GenerateToken(tt.userID, tt.username, tt.role)
+			token, err := manager.GenerateAccessToken(tt.user)
 
 			require.NoError(t, err)
 			require.NotEmpty(t, token)
 
-			// 验证生成的 token 可以被解析
+			// 验证生成的 token 可以被解析, 并带有吊销和租户所需的声明
 			claims, err := manager.VerifyToken(token)
 			require.NoError(t, err)
-			assert.Equal(t, tt.userID, claims.UserID)
-			assert.Equal(t, tt.username, claims.Username)
-			assert.Equal(t, tt.role, claims.Role)
+			assert.Equal(t, tt.user.ID, claims.UserID)
+			assert.Equal(t, tt.user.Username, claims.Username)
+			assert.Equal(t, tt.user.Role, claims.Role)
+			assert.Equal(t, tt.user.OrganizationID, claims.OrganizationID)
+			assert.Equal(t, tt.user.TokenVersion, claims.TokenVersion)
 		})
 	}
 }
@@ -75,7 +71,7 @@ func TestJWTManager_VerifyToken(t *testing.T) {
 		username := "testuser"
 		role := "admin"
 
-		token, err := manager.GenerateToken(userID, username, role)
+		token, err := manager.GenerateAccessToken(&model.User{ID: userID, Username: username, Role: role})
 		require.NoError(t, err)
 
 		claims, err := manager.VerifyToken(token)
@@ -93,7 +89,7 @@ func TestJWTManager_VerifyToken(t *testing.T) {
 	t.Run("expired token", func(t *testing.T) {
 		// 创建一个已过期的 token (duration 设置为负数)
 		expiredManager := NewJWTManager(secretKey, -time.Hour)
-		token, err := expiredManager.GenerateToken(1, "testuser", "user")
+		token, err := expiredManager.GenerateAccessToken(&model.User{ID: 1, Username: "testuser", Role: "user"})
 		require.NoError(t, err)
 
 		// 使用正常的 manager 验证过期 token
@@ -113,7 +109,7 @@ func TestJWTManager_VerifyToken(t *testing.T) {
 	t.Run("token with wrong secret", func(t *testing.T) {
 		// 使用不同的 secret 生成 token
 		otherManager := NewJWTManager("different-secret", 24*time.Hour)
-		token, err := otherManager.GenerateToken(1, "testuser", "user")
+		token, err := otherManager.GenerateAccessToken(&model.User{ID: 1, Username: "testuser", Role: "user"})
 		require.NoError(t, err)
 
 		// 使用原始 manager 验证 (secret 不匹配)
@@ -155,7 +151,7 @@ func TestJWTManager_TokenExpiry(t *testing.T) {
 	t.Run("token expires after duration", func(t *testing.T) {
 		// 创建一个 1 秒过期的 token
 		manager := NewJWTManager("test-secret", 1*time.Second)
-		token, err := manager.GenerateToken(1, "testuser", "user")
+		token, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "testuser", Role: "user"})
 		require.NoError(t, err)
 
 		// 立即验证应该成功
@@ -173,13 +169,39 @@ func TestJWTManager_TokenExpiry(t *testing.T) {
 	})
 }
 
+func TestJWTManager_MFAChallenge(t *testing.T) {
+	manager := NewJWTManager("test-secret", time.Hour)
+	user := &model.User{ID: 7, Username: "alice", Role: RoleAdmin, TokenVersion: 2}
+
+	token, err := manager.GenerateMFAChallenge(user, PurposeMFAVerify)
+	require.NoError(t, err)
+
+	claims, err := manager.VerifyMFAChallenge(token, PurposeMFAVerify)
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), claims.UserID)
+	assert.Equal(t, 2, claims.TokenVersion)
+	assert.WithinDuration(t, time.Now().Add(MFAChallengeDuration), claims.ExpiresAt.Time, 5*time.Second)
+
+	// 挑战令牌不能作为访问令牌, 也不能用于其他用途
+	_, err = manager.VerifyToken(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	_, err = manager.VerifyMFAChallenge(token, PurposeMFAEnroll)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// 访问令牌不能作为挑战令牌
+	accessToken, err := manager.GenerateAccessToken(user)
+	require.NoError(t, err)
+	_, err = manager.VerifyMFAChallenge(accessToken, PurposeMFAVerify)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
 func TestJWTManager_ClaimsFields(t *testing.T) {
 	manager := NewJWTManager("test-secret", 24*time.Hour)
 	userID := uint(100)
 	username := "testuser"
 	role := "admin"
 
-	token, err := manager.GenerateToken(userID, username, role)
+	token, err := manager.GenerateAccessToken(&model.User{ID: userID, Username: username, Role: role})
 	require.NoError(t, err)
 
 	claims, err := manager.VerifyToken(token)
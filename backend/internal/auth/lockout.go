@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAccountLocked   = errors.New("account is temporarily locked due to too many failed login attempts")
+	ErrTooManyAttempts = errors.New("too many failed login attempts, please retry later")
+)
+
+// LockoutConfig 登录失败限制配置
+type LockoutConfig struct {
+	// MaxAttempts 同一账号在 Window 内连续失败多少次后锁定
+	MaxAttempts int
+	// IPMaxAttempts 同一 IP 在 Window 内失败多少次后锁定该 IP
+	IPMaxAttempts int
+	// Window 失败次数的统计窗口, 超过窗口没有失败时重新计数
+	Window time.Duration
+	// LockoutDuration 锁定时长
+	LockoutDuration time.Duration
+	// BaseDelay 账号第一次失败后需要等待的时间, 之后每次失败翻倍; 负值表示不启用
+	BaseDelay time.Duration
+	// MaxDelay 渐进等待时间的上限
+	MaxDelay time.Duration
+}
+
+// DefaultLockoutConfig 返回默认的登录失败限制配置
+func DefaultLockoutConfig() LockoutConfig {
+	return LockoutConfig{
+		MaxAttempts:     5,
+		IPMaxAttempts:   50,
+		Window:          15 * time.Minute,
+		LockoutDuration: 15 * time.Minute,
+		BaseDelay:       time.Second,
+		MaxDelay:        30 * time.Second,
+	}
+}
+
+// LockoutEvent 描述一次新发生的锁定
+type LockoutEvent struct {
+	// Kind 为 account 或 ip
+	Kind     string
+	Key      string
+	Failures int
+	Until    time.Time
+}
+
+// attemptRecord 一个账号或 IP 的失败记录
+type attemptRecord struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LoginGuard 按账号和 IP 统计登录失败次数, 实现渐进等待和临时锁定
+//
+// 记录保存在进程内存中, 多实例部署时每个实例独立计数。
+type LoginGuard struct {
+	config LockoutConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	accounts  map[string]*attemptRecord
+	ips       map[string]*attemptRecord
+	lastPrune time.Time
+}
+
+// NewLoginGuard 创建登录失败限制器, 未设置的配置项使用默认值
+func NewLoginGuard(config LockoutConfig) *LoginGuard {
+	defaults := DefaultLockoutConfig()
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaults.MaxAttempts
+	}
+	if config.IPMaxAttempts <= 0 {
+		config.IPMaxAttempts = defaults.IPMaxAttempts
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.LockoutDuration <= 0 {
+		config.LockoutDuration = defaults.LockoutDuration
+	}
+	if config.BaseDelay == 0 {
+		config.BaseDelay = defaults.BaseDelay
+	} else if config.BaseDelay < 0 {
+		config.BaseDelay = 0 // 负值表示不启用渐进等待
+	}
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = defaults.MaxDelay
+	}
+
+	return &LoginGuard{
+		config:   config,
+		now:      time.Now,
+		accounts: make(map[string]*attemptRecord),
+		ips:      make(map[string]*attemptRecord),
+	}
+}
+
+// Check 检查账号和 IP 当前是否允许尝试登录, 不允许时返回需要等待的时间
+func (g *LoginGuard) Check(username, ip string) (time.Duration, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	// IP 只在达到失败上限后锁定, 不做渐进等待, 避免同一出口 IP 下的其他用户被拖慢
+	if record := g.ips[ip]; record != nil && now.Before(record.lockedUntil) {
+		return record.lockedUntil.Sub(now), ErrTooManyAttempts
+	}
+	if wait, locked := g.blocked(g.accounts[normalizeUsername(username)], now); wait > 0 {
+		if locked {
+			return wait, ErrAccountLocked
+		}
+		return wait, ErrTooManyAttempts
+	}
+	return 0, nil
+}
+
+// RecordFailure 记录一次失败的登录, 返回因此新发生的锁定
+func (g *LoginGuard) RecordFailure(username, ip string) []LockoutEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	g.prune(now)
+
+	var lockouts []LockoutEvent
+	account := normalizeUsername(username)
+	if event := g.fail(g.accounts, account, g.config.MaxAttempts, now); event != nil {
+		event.Kind = "account"
+		lockouts = append(lockouts, *event)
+	}
+	if ip != "" {
+		if event := g.fail(g.ips, ip, g.config.IPMaxAttempts, now); event != nil {
+			event.Kind = "ip"
+			lockouts = append(lockouts, *event)
+		}
+	}
+	return lockouts
+}
+
+// RecordSuccess 登录成功后清除账号的失败记录
+//
+// IP 的失败记录不会清除, 避免攻击者用自己的账号重置 IP 计数。
+func (g *LoginGuard) RecordSuccess(username string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.accounts, normalizeUsername(username))
+}
+
+// Unlock 管理员手动解除账号锁定
+func (g *LoginGuard) Unlock(username string) {
+	g.RecordSuccess(username)
+}
+
+// IsLocked 检查账号是否处于锁定状态
+func (g *LoginGuard) IsLocked(username string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	record := g.accounts[normalizeUsername(username)]
+	return record != nil && g.now().Before(record.lockedUntil)
+}
+
+// blocked 返回记录需要等待的时间, 以及是否处于锁定状态
+func (g *LoginGuard) blocked(record *attemptRecord, now time.Time) (time.Duration, bool) {
+	if record == nil {
+		return 0, false
+	}
+	if now.Before(record.lockedUntil) {
+		return record.lockedUntil.Sub(now), true
+	}
+	if next := record.lastFailure.Add(g.delay(record.failures)); now.Before(next) {
+		return next.Sub(now), false
+	}
+	return 0, false
+}
+
+// fail 增加失败次数, 达到上限时锁定并返回锁定事件
+func (g *LoginGuard) fail(records map[string]*attemptRecord, key string, limit int, now time.Time) *LockoutEvent {
+	record := records[key]
+	if record == nil || now.Sub(record.lastFailure) > g.config.Window || (!record.lockedUntil.IsZero() && !now.Before(record.lockedUntil)) {
+		record = &attemptRecord{}
+		records[key] = record
+	}
+
+	record.failures++
+	record.lastFailure = now
+	if record.failures >= limit && !now.Before(record.lockedUntil) {
+		record.lockedUntil = now.Add(g.config.LockoutDuration)
+		return &LockoutEvent{Key: key, Failures: record.failures, Until: record.lockedUntil}
+	}
+	return nil
+}
+
+// delay 计算第 failures 次失败后的渐进等待时间
+func (g *LoginGuard) delay(failures int) time.Duration {
+	if failures <= 0 || g.config.BaseDelay == 0 {
+		return 0
+	}
+
+	d := g.config.BaseDelay
+	for i := 1; i < failures && d < g.config.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > g.config.MaxDelay {
+		d = g.config.MaxDelay
+	}
+	return d
+}
+
+// prune 清理已过期的记录, 防止内存无限增长; 每分钟最多清理一次
+func (g *LoginGuard) prune(now time.Time) {
+	if now.Sub(g.lastPrune) < time.Minute {
+		return
+	}
+	g.lastPrune = now
+
+	for _, records := range []map[string]*attemptRecord{g.accounts, g.ips} {
+		for key, record := range records {
+			if now.Sub(record.lastFailure) > g.config.Window && !now.Before(record.lockedUntil) {
+				delete(records, key)
+			}
+		}
+	}
+}
+
+// normalizeUsername 用户名不区分大小写计数
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
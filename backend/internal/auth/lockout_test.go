@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLoginGuard 创建使用可控时钟的登录失败限制器
+func newTestLoginGuard(config LockoutConfig) (*LoginGuard, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	guard := NewLoginGuard(config)
+	guard.now = func() time.Time { return now }
+	return guard, &now
+}
+
+func TestLoginGuard_ProgressiveDelayAndLockout(t *testing.T) {
+	guard, now := newTestLoginGuard(LockoutConfig{MaxAttempts: 3, BaseDelay: time.Second, LockoutDuration: 10 * time.Minute})
+
+	_, err := guard.Check("alice", "10.0.0.1")
+	require.NoError(t, err)
+
+	assert.Empty(t, guard.RecordFailure("alice", "10.0.0.1"))
+	wait, err := guard.Check("Alice", "10.0.0.2")
+	assert.ErrorIs(t, err, ErrTooManyAttempts, "用户名不区分大小写")
+	assert.Equal(t, time.Second, wait)
+
+	// 第二次失败后等待时间翻倍
+	*now = now.Add(time.Second)
+	_, err = guard.Check("alice", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Empty(t, guard.RecordFailure("alice", "10.0.0.1"))
+	wait, _ = guard.Check("alice", "10.0.0.1")
+	assert.Equal(t, 2*time.Second, wait)
+
+	// 达到上限后锁定
+	*now = now.Add(2 * time.Second)
+	lockouts := guard.RecordFailure("alice", "10.0.0.1")
+	require.Len(t, lockouts, 1)
+	assert.Equal(t, "account", lockouts[0].Kind)
+	assert.Equal(t, "alice", lockouts[0].Key)
+	assert.Equal(t, 3, lockouts[0].Failures)
+
+	wait, err = guard.Check("alice", "10.0.0.9")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+	assert.Equal(t, 10*time.Minute, wait)
+	assert.True(t, guard.IsLocked("alice"))
+
+	// 其他账号不受影响
+	_, err = guard.Check("bob", "10.0.0.9")
+	assert.NoError(t, err)
+
+	// 锁定到期后重新计数
+	*now = now.Add(10 * time.Minute)
+	_, err = guard.Check("alice", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Empty(t, guard.RecordFailure("alice", "10.0.0.1"))
+}
+
+func TestLoginGuard_IPLockout(t *testing.T) {
+	guard, _ := newTestLoginGuard(LockoutConfig{MaxAttempts: 100, IPMaxAttempts: 3, BaseDelay: -1})
+
+	// 同一 IP 尝试不同账号
+	assert.Empty(t, guard.RecordFailure("a", "10.0.0.1"))
+	_, err := guard.Check("b", "10.0.0.1")
+	assert.NoError(t, err, "IP 没有渐进等待")
+	assert.Empty(t, guard.RecordFailure("b", "10.0.0.1"))
+
+	lockouts := guard.RecordFailure("c", "10.0.0.1")
+	require.Len(t, lockouts, 1)
+	assert.Equal(t, "ip", lockouts[0].Kind)
+
+	_, err = guard.Check("d", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	_, err = guard.Check("d", "10.0.0.2")
+	assert.NoError(t, err)
+}
+
+func TestLoginGuard_SuccessAndUnlock(t *testing.T) {
+	guard, now := newTestLoginGuard(LockoutConfig{MaxAttempts: 2, IPMaxAttempts: 2})
+
+	guard.RecordFailure("alice", "10.0.0.1")
+	guard.RecordSuccess("alice")
+	_, err := guard.Check("alice", "10.0.0.2")
+	assert.NoError(t, err)
+
+	guard.RecordFailure("alice", "10.0.0.2")
+	*now = now.Add(time.Minute)
+	guard.RecordFailure("alice", "10.0.0.3")
+	assert.True(t, guard.IsLocked("alice"))
+
+	guard.Unlock("alice")
+	assert.False(t, guard.IsLocked("alice"))
+
+	// 登录成功不会清除 IP 的失败记录
+	guard.RecordFailure("mallory", "10.0.0.1")
+	guard.RecordSuccess("mallory")
+	_, err = guard.Check("anyone", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+}
@@ -5,6 +5,9 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
 )
 
 const (
@@ -14,16 +17,26 @@ const (
 	AuthorizationTypeBearer = "bearer"
 	// AuthorizationPayloadKey context key for authorization payload
 	AuthorizationPayloadKey = "authorization_payload"
+	// WebSocketProtocolBearer WebSocket 子协议, 浏览器无法设置 Authorization header,
+	// 以 Sec-WebSocket-Protocol: bearer, <token> 传递令牌, 服务端只回应 bearer
+	WebSocketProtocolBearer = "bearer"
 )
 
 // AuthMiddleware 创建认证中间件
-func AuthMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
+//
+// 默认只接受 JWT; verifiers 中的校验器可以处理其他类型的 Bearer 令牌 (如 API 令牌)。
+// WebSocket 升级请求没有 Authorization header 时从 bearer 子协议中读取令牌。
+func AuthMiddleware(jwtManager *JWTManager, verifiers ...TokenVerifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authorizationHeader := c.GetHeader(AuthorizationHeader)
 
 		if len(authorizationHeader) == 0 {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header is not provided"})
-			return
+			token, ok := webSocketProtocolToken(c.Request)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header is not provided"})
+				return
+			}
+			authorizationHeader = AuthorizationTypeBearer + " " + token
 		}
 
 		fields := strings.Fields(authorizationHeader)
@@ -39,18 +52,73 @@ func AuthMiddleware(jwtManager *JWTManager) gin.HandlerFunc {
 		}
 
 		accessToken := fields[1]
-		claims, err := jwtManager.VerifyToken(accessToken)
+		claims, err := verifyAccessToken(c, jwtManager, verifiers, accessToken)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			return
 		}
 
-		// 将 claims 存储到 context 中
+		// 旧版本签发的令牌没有组织, 属于默认组织
+		claims.OrganizationID = organizationOrDefault(claims.OrganizationID)
+
+		// 将 claims 存储到 context 中, 之后的存储操作只作用于用户所属的组织
 		c.Set(AuthorizationPayloadKey, claims)
+		BindOrganization(c, claims.OrganizationID)
 		c.Next()
 	}
 }
 
+// webSocketProtocolToken 从 WebSocket 升级请求的子协议 "bearer, <token>" 中读取令牌
+func webSocketProtocolToken(r *http.Request) (string, bool) {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return "", false
+	}
+
+	var protocols []string
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == WebSocketProtocolBearer && protocols[i+1] != "" {
+			return protocols[i+1], true
+		}
+	}
+	return "", false
+}
+
+// organizationOrDefault 未设置组织 (0) 视为默认组织
+func organizationOrDefault(id uint) uint {
+	if id == 0 {
+		return model.DefaultOrganizationID
+	}
+	return id
+}
+
+// BindOrganization 将请求绑定到指定组织, 之后通过请求 context 进行的存储操作只作用于该组织
+func BindOrganization(c *gin.Context, orgID uint) {
+	c.Request = c.Request.WithContext(tenant.WithOrganization(c.Request.Context(), orgID))
+}
+
+// verifyAccessToken 使用支持该令牌的校验器校验, 都不支持时按 JWT 校验
+func verifyAccessToken(c *gin.Context, jwtManager *JWTManager, verifiers []TokenVerifier, token string) (*Claims, error) {
+	for _, v := range verifiers {
+		if v.Supports(token) {
+			return v.Verify(c.Request.Context(), token)
+		}
+	}
+
+	claims, err := jwtManager.VerifyToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := jwtManager.checkRevocation(c.Request.Context(), claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
 // GetCurrentUser 从 context 中获取当前用户信息
 func GetCurrentUser(c *gin.Context) (*Claims, bool) {
 	payload, exists := c.Get(AuthorizationPayloadKey)
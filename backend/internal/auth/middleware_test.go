@@ -10,6 +10,9 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
 )
 
 func setupTestRouter() *gin.Engine {
@@ -38,7 +41,7 @@ func TestAuthMiddleware(t *testing.T) {
 		})
 
 		// 生成有效 token
-		token, err := manager.GenerateToken(1, "testuser", "admin")
+		token, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "testuser", Role: "admin"})
 		require.NoError(t, err)
 
 		// 创建请求
@@ -145,7 +148,7 @@ func TestAuthMiddleware(t *testing.T) {
 
 		// 生成已过期的 token
 		expiredManager := NewJWTManager("test-secret", -time.Hour)
-		token, err := expiredManager.GenerateToken(1, "testuser", "user")
+		token, err := expiredManager.GenerateAccessToken(&model.User{ID: 1, Username: "testuser", Role: "user"})
 		require.NoError(t, err)
 
 		w := httptest.NewRecorder()
@@ -164,7 +167,7 @@ func TestAuthMiddleware(t *testing.T) {
 			c.JSON(http.StatusOK, gin.H{"message": "success"})
 		})
 
-		token, err := manager.GenerateToken(1, "testuser", "user")
+		token, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "testuser", Role: "user"})
 		require.NoError(t, err)
 
 		testCases := []string{
@@ -186,6 +189,45 @@ func TestAuthMiddleware(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("websocket subprotocol token", func(t *testing.T) {
+		router := setupTestRouter()
+		router.Use(AuthMiddleware(manager))
+		router.GET("/protected", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"message": "success"})
+		})
+
+		token, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "testuser", Role: "user"})
+		require.NoError(t, err)
+
+		testCases := []struct {
+			name           string
+			upgrade        string
+			protocol       string
+			expectedStatus int
+		}{
+			{name: "bearer subprotocol", upgrade: "websocket", protocol: "bearer, " + token, expectedStatus: http.StatusOK},
+			{name: "missing token", upgrade: "websocket", protocol: "bearer", expectedStatus: http.StatusUnauthorized},
+			{name: "other subprotocol", upgrade: "websocket", protocol: "chat, " + token, expectedStatus: http.StatusUnauthorized},
+			{name: "not a websocket upgrade", upgrade: "", protocol: "bearer, " + token, expectedStatus: http.StatusUnauthorized},
+			{name: "invalid token", upgrade: "websocket", protocol: "bearer, invalid-token", expectedStatus: http.StatusUnauthorized},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/protected", nil)
+				if tc.upgrade != "" {
+					req.Header.Set("Upgrade", tc.upgrade)
+				}
+				req.Header.Set("Sec-WebSocket-Protocol", tc.protocol)
+
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, tc.expectedStatus, w.Code)
+			})
+		}
+	})
 }
 
 func TestGetCurrentUser(t *testing.T) {
@@ -203,7 +245,7 @@ func TestGetCurrentUser(t *testing.T) {
 			c.JSON(http.StatusOK, gin.H{"message": "success"})
 		})
 
-		token, err := manager.GenerateToken(1, "testuser", "user")
+		token, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "testuser", Role: "user"})
 		require.NoError(t, err)
 
 		w := httptest.NewRecorder()
@@ -243,7 +285,7 @@ func TestRequireRole(t *testing.T) {
 			c.JSON(http.StatusOK, gin.H{"message": "admin access granted"})
 		})
 
-		token, err := manager.GenerateToken(1, "admin", "admin")
+		token, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "admin", Role: "admin"})
 		require.NoError(t, err)
 
 		w := httptest.NewRecorder()
@@ -262,7 +304,7 @@ func TestRequireRole(t *testing.T) {
 			c.JSON(http.StatusOK, gin.H{"message": "admin access granted"})
 		})
 
-		token, err := manager.GenerateToken(1, "user", "user")
+		token, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "user", Role: "user"})
 		require.NoError(t, err)
 
 		w := httptest.NewRecorder()
@@ -306,7 +348,7 @@ func TestRequireRole(t *testing.T) {
 
 		for _, tc := range testCases {
 			t.Run(tc.name, func(t *testing.T) {
-				token, err := manager.GenerateToken(1, "testuser", tc.role)
+				token, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "testuser", Role: tc.role})
 				require.NoError(t, err)
 
 				w := httptest.NewRecorder()
@@ -378,7 +420,7 @@ func TestAuthMiddleware_Integration(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 
 		// 测试普通用户访问 profile
-		userToken, _ := manager.GenerateToken(1, "user", "user")
+		userToken, _ := manager.GenerateAccessToken(&model.User{ID: 1, Username: "user", Role: "user"})
 		w = httptest.NewRecorder()
 		req, _ = http.NewRequest("GET", "/api/profile", nil)
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", userToken))
@@ -393,7 +435,7 @@ func TestAuthMiddleware_Integration(t *testing.T) {
 		assert.Equal(t, http.StatusForbidden, w.Code)
 
 		// 测试管理员访问 admin 路由
-		adminToken, _ := manager.GenerateToken(2, "admin", "admin")
+		adminToken, _ := manager.GenerateAccessToken(&model.User{ID: 2, Username: "admin", Role: "admin"})
 		w = httptest.NewRecorder()
 		req, _ = http.NewRequest("GET", "/api/admin", nil)
 		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", adminToken))
@@ -401,3 +443,31 @@ func TestAuthMiddleware_Integration(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 	})
 }
+
+func TestAuthMiddleware_BindsOrganization(t *testing.T) {
+	manager := NewJWTManager("test-secret", 15*time.Minute)
+	router := setupTestRouter()
+	router.Use(AuthMiddleware(manager))
+	router.GET("/org", func(c *gin.Context) {
+		orgID, _ := tenant.OrganizationID(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"org": orgID})
+	})
+
+	request := func(token string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/org", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w.Body.String()
+	}
+
+	token, err := manager.GenerateAccessToken(&model.User{ID: 1, Username: "alice", Role: RoleViewer, OrganizationID: 3})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"org":3}`, request(token))
+
+	// 旧令牌没有组织, 属于默认组织
+	legacy, err := manager.generate(Claims{UserID: 1, Username: "alice", Role: RoleViewer})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"org":1}`, request(legacy))
+}
@@ -0,0 +1,422 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcFlowTTL 一次 OIDC 登录流程 (从跳转到 IdP 到回调) 允许的最长时间
+const oidcFlowTTL = 10 * time.Minute
+
+var (
+	ErrOIDCStateMismatch = errors.New("oidc state mismatch")
+	ErrOIDCFlowExpired   = errors.New("oidc login flow is invalid or expired")
+	ErrOIDCNoRole        = errors.New("no platform role is mapped to the user's groups")
+)
+
+// OIDCRoleMapping 将 IdP 组映射为平台角色
+type OIDCRoleMapping struct {
+	Group string `mapstructure:"group"`
+	Role  string `mapstructure:"role"`
+}
+
+// OIDCConfig OIDC 单点登录配置
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// GroupsClaim ID token 中保存用户组的 claim, 默认 groups
+	GroupsClaim string
+	// RoleMapping 按顺序匹配, 第一个命中的组决定用户角色
+	RoleMapping []OIDCRoleMapping
+	// DefaultRole 没有组命中时使用的角色, 为空时拒绝登录
+	DefaultRole string
+	// StateSecret 用于签名登录流程状态
+	StateSecret string
+	HTTPClient  *http.Client
+}
+
+// OIDCIdentity 从 ID token 中解析出的用户身份
+type OIDCIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string
+	Name          string
+	Groups        []string
+}
+
+// ExternalID 返回用户在 IdP 中的唯一标识
+func (i *OIDCIdentity) ExternalID() string {
+	return i.Issuer + "#" + i.Subject
+}
+
+// OIDCFlow 一次授权码登录流程的状态, 在跳转前保存, 回调时校验
+type OIDCFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// oidcFlowClaims 登录流程状态的签名载体
+type oidcFlowClaims struct {
+	OIDCFlow
+	jwt.RegisteredClaims
+}
+
+// oidcDiscovery OpenID Provider 元数据
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// OIDCProvider 实现授权码 + PKCE 流程和 ID token 校验
+type OIDCProvider struct {
+	config    OIDCConfig
+	client    *http.Client
+	discovery oidcDiscovery
+
+	mu   sync.RWMutex
+	keys map[string]*rsa.PublicKey
+}
+
+// NewOIDCProvider 通过 discovery 文档初始化 OIDC Provider
+func NewOIDCProvider(ctx context.Context, config OIDCConfig) (*OIDCProvider, error) {
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("oidc issuer, client_id and redirect_url are required")
+	}
+	if config.StateSecret == "" {
+		return nil, errors.New("oidc state secret is required")
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	p := &OIDCProvider{
+		config: config,
+		client: client,
+		keys:   make(map[string]*rsa.PublicKey),
+	}
+
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &p.discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if p.discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch, expected %q got %q", config.Issuer, p.discovery.Issuer)
+	}
+	if p.discovery.AuthorizationEndpoint == "" || p.discovery.TokenEndpoint == "" || p.discovery.JWKSURI == "" {
+		return nil, errors.New("oidc discovery: incomplete provider metadata")
+	}
+
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// NewFlow 生成新的登录流程状态 (state, nonce 和 PKCE verifier)
+func (p *OIDCProvider) NewFlow() (*OIDCFlow, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return &OIDCFlow{State: values[0], Nonce: values[1], Verifier: values[2]}, nil
+}
+
+// SealFlow 将登录流程状态签名后编码, 用于保存在 cookie 中
+func (p *OIDCProvider) SealFlow(flow *OIDCFlow) (string, error) {
+	claims := oidcFlowClaims{
+		OIDCFlow: *flow,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcFlowTTL)),
+			Audience:  jwt.ClaimStrings{"oidc-flow"},
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(p.config.StateSecret))
+}
+
+// OpenFlow 校验并解码 SealFlow 生成的登录流程状态, state 必须与回调参数一致
+func (p *OIDCProvider) OpenFlow(sealed, state string) (*OIDCFlow, error) {
+	claims := &oidcFlowClaims{}
+	_, err := jwt.ParseWithClaims(sealed, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(p.config.StateSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience("oidc-flow"), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrOIDCFlowExpired
+	}
+	if state == "" || claims.State != state {
+		return nil, ErrOIDCStateMismatch
+	}
+	return &claims.OIDCFlow, nil
+}
+
+// AuthCodeURL 返回 IdP 授权端点地址
+func (p *OIDCProvider) AuthCodeURL(flow *OIDCFlow) string {
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {flow.State},
+		"nonce":                 {flow.Nonce},
+		"code_challenge":        {PKCEChallenge(flow.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(p.discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.discovery.AuthorizationEndpoint + sep + params.Encode()
+}
+
+// Exchange 使用授权码和 PKCE verifier 换取 ID token 并完成校验
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, flow *OIDCFlow) (*OIDCIdentity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {flow.Verifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc token exchange: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("oidc token exchange: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc token exchange: unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResponse struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("oidc token exchange: %w", err)
+	}
+	if tokenResponse.IDToken == "" {
+		return nil, errors.New("oidc token exchange: response has no id_token")
+	}
+
+	return p.VerifyIDToken(ctx, tokenResponse.IDToken, flow.Nonce)
+}
+
+// VerifyIDToken 校验 ID token 的签名, issuer, audience, 有效期和 nonce
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc id token: %w", err)
+	}
+
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, errors.New("oidc id token: nonce mismatch")
+	}
+
+	identity := &OIDCIdentity{Issuer: p.config.Issuer}
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Subject == "" {
+		return nil, errors.New("oidc id token: missing subject")
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.EmailVerified, _ = claims["email_verified"].(bool)
+	identity.Name, _ = claims["name"].(string)
+	identity.Username, _ = claims["preferred_username"].(string)
+	identity.Groups = stringsClaim(claims[p.config.GroupsClaim])
+	return identity, nil
+}
+
+// MapRole 根据用户组映射平台角色, 没有组命中且未配置默认角色时返回 ErrOIDCNoRole
+func (p *OIDCProvider) MapRole(groups []string) (string, error) {
+	member := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		member[g] = true
+	}
+	for _, m := range p.config.RoleMapping {
+		if member[m.Group] {
+			return m.Role, nil
+		}
+	}
+	if p.config.DefaultRole != "" {
+		return p.config.DefaultRole, nil
+	}
+	return "", ErrOIDCNoRole
+}
+
+// HasRoleMapping 是否配置了组到角色的映射; 配置后每次登录都会按 IdP 的组同步用户角色
+func (p *OIDCProvider) HasRoleMapping() bool {
+	return len(p.config.RoleMapping) > 0
+}
+
+// PKCEChallenge 计算 PKCE S256 code challenge
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// key 返回指定 kid 的签名公钥, 找不到时重新拉取 JWKS 以支持密钥轮换
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	if key := p.lookupKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookupKey 在已缓存的密钥中查找, kid 为空且只有一个密钥时直接使用该密钥
+func (p *OIDCProvider) lookupKey(kid string) *rsa.PublicKey {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+// refreshKeys 拉取 JWKS 并替换缓存的 RSA 公钥
+func (p *OIDCProvider) refreshKeys(ctx context.Context) error {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &jwks); err != nil {
+		return fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("oidc jwks: no usable RSA signing keys")
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	return nil
+}
+
+// getJSON 发送 GET 请求并解析 JSON 响应
+func (p *OIDCProvider) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, endpoint)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// stringsClaim 将字符串或字符串数组形式的 claim 转换为字符串切片
+func stringsClaim(v interface{}) []string {
+	switch value := v.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
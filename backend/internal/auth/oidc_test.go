@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockOIDCProvider 进程内的 OIDC IdP, 提供 discovery, JWKS 和 token 端点
+type mockOIDCProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+}
+
+// mockAuthorization 一次授权请求签发的授权码信息
+type mockAuthorization struct {
+	challenge string
+	nonce     string
+	claims    jwt.MapClaims
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	m := &mockOIDCProvider{key: key, codes: make(map[string]mockAuthorization)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", m.handleToken)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize 模拟用户在 IdP 完成登录, 返回回调收到的授权码
+func (m *mockOIDCProvider) authorize(t *testing.T, authURL string, claims jwt.MapClaims) string {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	q := u.Query()
+	require.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	code := "code-" + q.Get("state")
+	m.mu.Lock()
+	m.codes[code] = mockAuthorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), claims: claims}
+	m.mu.Unlock()
+	return code
+}
+
+func (m *mockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	authz, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || PKCEChallenge(r.PostForm.Get("code_verifier")) != authz.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":   m.server.URL,
+		"aud":   "platform",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": authz.nonce,
+	}
+	for k, v := range authz.claims {
+		claims[k] = v
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"id_token": m.sign(claims), "token_type": "Bearer"})
+}
+
+func (m *mockOIDCProvider) sign(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, _ := token.SignedString(m.key)
+	return signed
+}
+
+func newTestOIDCProvider(t *testing.T, m *mockOIDCProvider) *OIDCProvider {
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		Issuer:       m.server.URL,
+		ClientID:     "platform",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:8080/api/v1/auth/oidc/callback",
+		RoleMapping: []OIDCRoleMapping{
+			{Group: "platform-admins", Role: RoleAdmin},
+			{Group: "sre", Role: RoleOperator},
+		},
+		StateSecret: "test-secret",
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+func TestOIDCProvider_AuthorizationCodeFlow(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := newTestOIDCProvider(t, mock)
+	ctx := context.Background()
+
+	flow, err := provider.NewFlow()
+	require.NoError(t, err)
+	sealed, err := provider.SealFlow(flow)
+	require.NoError(t, err)
+
+	code := mock.authorize(t, provider.AuthCodeURL(flow), jwt.MapClaims{
+		"sub":                "user-1",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"groups":             []string{"dev", "sre"},
+	})
+
+	opened, err := provider.OpenFlow(sealed, flow.State)
+	require.NoError(t, err)
+
+	identity, err := provider.Exchange(ctx, code, opened)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", identity.Subject)
+	assert.Equal(t, "alice", identity.Username)
+	assert.Equal(t, "alice@example.com", identity.Email)
+	assert.True(t, identity.EmailVerified)
+	assert.Equal(t, []string{"dev", "sre"}, identity.Groups)
+	assert.Equal(t, mock.server.URL+"#user-1", identity.ExternalID())
+
+	role, err := provider.MapRole(identity.Groups)
+	require.NoError(t, err)
+	assert.Equal(t, RoleOperator, role)
+
+	// 授权码只能使用一次
+	_, err = provider.Exchange(ctx, code, opened)
+	assert.Error(t, err)
+}
+
+func TestOIDCProvider_RejectsWrongVerifier(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := newTestOIDCProvider(t, mock)
+
+	flow, err := provider.NewFlow()
+	require.NoError(t, err)
+	code := mock.authorize(t, provider.AuthCodeURL(flow), jwt.MapClaims{"sub": "user-1"})
+
+	other, err := provider.NewFlow()
+	require.NoError(t, err)
+	_, err = provider.Exchange(context.Background(), code, &OIDCFlow{Nonce: flow.Nonce, Verifier: other.Verifier})
+	assert.Error(t, err)
+}
+
+func TestOIDCProvider_VerifyIDToken(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := newTestOIDCProvider(t, mock)
+	ctx := context.Background()
+
+	valid := jwt.MapClaims{
+		"iss":   mock.server.URL,
+		"aud":   "platform",
+		"sub":   "user-1",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": "n",
+	}
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := jwt.MapClaims{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+
+	_, err := provider.VerifyIDToken(ctx, mock.sign(valid), "n")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		nonce  string
+	}{
+		{"nonce mismatch", valid, "other"},
+		{"wrong issuer", with("iss", "https://evil.example.com"), "n"},
+		{"wrong audience", with("aud", "other-client"), "n"},
+		{"expired", with("exp", time.Now().Add(-time.Hour).Unix()), "n"},
+		{"missing subject", with("sub", ""), "n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(ctx, mock.sign(tt.claims), tt.nonce)
+			assert.Error(t, err)
+		})
+	}
+
+	// 使用其他密钥签名的 token
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, valid)
+	forged.Header["kid"] = "test-key"
+	signed, err := forged.SignedString(otherKey)
+	require.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, signed, "n")
+	assert.Error(t, err)
+}
+
+func TestOIDCProvider_Flow(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := newTestOIDCProvider(t, mock)
+
+	flow, err := provider.NewFlow()
+	require.NoError(t, err)
+	sealed, err := provider.SealFlow(flow)
+	require.NoError(t, err)
+
+	_, err = provider.OpenFlow(sealed, "other-state")
+	assert.ErrorIs(t, err, ErrOIDCStateMismatch)
+
+	_, err = provider.OpenFlow(sealed+"x", flow.State)
+	assert.ErrorIs(t, err, ErrOIDCFlowExpired)
+
+	u, err := url.Parse(provider.AuthCodeURL(flow))
+	require.NoError(t, err)
+	assert.Equal(t, PKCEChallenge(flow.Verifier), u.Query().Get("code_challenge"))
+	assert.NotContains(t, u.RawQuery, flow.Verifier)
+}
+
+func TestOIDCProvider_MapRole(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := newTestOIDCProvider(t, mock)
+
+	role, err := provider.MapRole([]string{"sre", "platform-admins"})
+	require.NoError(t, err)
+	assert.Equal(t, RoleAdmin, role, "按配置顺序第一个命中的映射生效")
+
+	_, err = provider.MapRole([]string{"marketing"})
+	assert.ErrorIs(t, err, ErrOIDCNoRole)
+
+	provider.config.DefaultRole = RoleViewer
+	role, err = provider.MapRole(nil)
+	require.NoError(t, err)
+	assert.Equal(t, RoleViewer, role)
+}
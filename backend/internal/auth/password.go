@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PasswordPolicyConfig 密码策略配置
+type PasswordPolicyConfig struct {
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// MaxAge 密码有效期, 0 表示密码不过期
+	MaxAge time.Duration
+	// BreachedPasswordsFile 泄露密码列表文件, 每行一个明文密码或 SHA-1 哈希 (兼容 "HASH:count" 格式)
+	BreachedPasswordsFile string
+}
+
+// DefaultPasswordMinLength 未配置时的最小密码长度
+const DefaultPasswordMinLength = 8
+
+// PasswordPolicyError 密码不满足策略, Violations 列出所有不满足的规则
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+// Error 实现 error 接口
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the policy: " + strings.Join(e.Violations, "; ")
+}
+
+// PasswordPolicy 校验密码强度和有效期
+type PasswordPolicy struct {
+	config   PasswordPolicyConfig
+	breached map[string]struct{} // 泄露密码的 SHA-1 (大写十六进制)
+}
+
+// NewPasswordPolicy 创建密码策略, 配置了泄露密码列表时从本地文件加载
+func NewPasswordPolicy(config PasswordPolicyConfig) (*PasswordPolicy, error) {
+	if config.MinLength <= 0 {
+		config.MinLength = DefaultPasswordMinLength
+	}
+
+	p := &PasswordPolicy{config: config, breached: make(map[string]struct{})}
+	if config.BreachedPasswordsFile != "" {
+		if err := p.loadBreachedPasswords(config.BreachedPasswordsFile); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// DefaultPasswordPolicy 返回只限制最小长度的默认密码策略
+func DefaultPasswordPolicy() *PasswordPolicy {
+	p, _ := NewPasswordPolicy(PasswordPolicyConfig{})
+	return p
+}
+
+// BreachedCount 返回已加载的泄露密码数量
+func (p *PasswordPolicy) BreachedCount() int {
+	return len(p.breached)
+}
+
+// Validate 校验密码是否满足策略, username 不为空时密码不能包含用户名
+func (p *PasswordPolicy) Validate(password, username string) error {
+	var violations []string
+
+	if len([]rune(password)) < p.config.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.config.MinLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.config.RequireUpper && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.config.RequireLower && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.config.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.config.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if username != "" && len(username) >= 3 && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violations = append(violations, "must not contain the username")
+	}
+	if _, found := p.breached[sha1Hex(password)]; found {
+		violations = append(violations, "appears in a list of breached passwords")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// MaxAge 返回密码有效期, 0 表示不过期
+func (p *PasswordPolicy) MaxAge() time.Duration {
+	return p.config.MaxAge
+}
+
+// IsExpired 检查在 changedAt 设置的密码到 now 是否已过期
+func (p *PasswordPolicy) IsExpired(changedAt, now time.Time) bool {
+	return p.config.MaxAge > 0 && !changedAt.IsZero() && now.Sub(changedAt) > p.config.MaxAge
+}
+
+// GeneratePassword 生成满足策略的随机密码, 用于临时密码
+func (p *PasswordPolicy) GeneratePassword() (string, error) {
+	const (
+		uppers  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+		lowers  = "abcdefghijkmnopqrstuvwxyz"
+		digits  = "23456789"
+		symbols = "!@#$%^&*-_=+"
+	)
+	all := uppers + lowers + digits + symbols
+
+	length := p.config.MinLength
+	if length < 20 {
+		length = 20
+	}
+
+	// 每类字符至少一个, 其余随机
+	classes := []string{uppers, lowers, digits, symbols}
+	password := make([]byte, 0, length)
+	for _, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+	for len(password) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// 打乱顺序, 避免固定位置的字符类别
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+// loadBreachedPasswords 从本地文件加载泄露密码列表
+func (p *PasswordPolicy) loadBreachedPasswords(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if hash, ok := parseSHA1Line(line); ok {
+			p.breached[hash] = struct{}{}
+			continue
+		}
+		p.breached[sha1Hex(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return nil
+}
+
+// parseSHA1Line 解析 "HASH" 或 "HASH:count" 格式的行
+func parseSHA1Line(line string) (string, bool) {
+	if i := strings.IndexByte(line, ':'); i == 40 {
+		line = line[:i]
+	}
+	if len(line) != 40 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(line); err != nil {
+		return "", false
+	}
+	return strings.ToUpper(line), true
+}
+
+// sha1Hex 计算大写十六进制 SHA-1
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// randomChar 从字符集中随机选取一个字符
+func randomChar(charset string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, err
+	}
+	return charset[n.Int64()], nil
+}
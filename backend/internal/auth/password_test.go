@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	policy, err := NewPasswordPolicy(PasswordPolicyConfig{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, policy.Validate("Correct-Horse-9", "alice"))
+
+	err = policy.Validate("short", "alice")
+	var policyErr *PasswordPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Contains(t, policyErr.Violations, "must be at least 10 characters")
+	assert.Contains(t, policyErr.Violations, "must contain an uppercase letter")
+	assert.Contains(t, policyErr.Violations, "must contain a digit")
+	assert.Contains(t, policyErr.Violations, "must contain a symbol")
+	assert.NotContains(t, policyErr.Violations, "must contain a lowercase letter")
+
+	err = policy.Validate("Alice-Password-1", "alice")
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, []string{"must not contain the username"}, policyErr.Violations)
+}
+
+func TestPasswordPolicy_Default(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	assert.NoError(t, policy.Validate("password123", ""))
+	assert.Error(t, policy.Validate("pass123", ""))
+}
+
+func TestPasswordPolicy_BreachedList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# 常见密码\n" +
+		"letmein123\n" +
+		// "correcthorse" 的 SHA-1, HIBP 格式
+		"0e4cecb0f76c0600f8fc5995fa087260ba91640b:42\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	policy, err := NewPasswordPolicy(PasswordPolicyConfig{BreachedPasswordsFile: path})
+	require.NoError(t, err)
+	assert.Equal(t, 2, policy.BreachedCount())
+
+	assert.Error(t, policy.Validate("letmein123", ""))
+	assert.Error(t, policy.Validate("correcthorse", ""))
+	assert.NoError(t, policy.Validate("uncommon-passphrase", ""))
+
+	_, err = NewPasswordPolicy(PasswordPolicyConfig{BreachedPasswordsFile: filepath.Join(t.TempDir(), "missing.txt")})
+	assert.Error(t, err)
+}
+
+func TestPasswordPolicy_Expiry(t *testing.T) {
+	now := time.Now()
+	assert.False(t, DefaultPasswordPolicy().IsExpired(now.Add(-10*365*24*time.Hour), now))
+
+	policy, err := NewPasswordPolicy(PasswordPolicyConfig{MaxAge: 90 * 24 * time.Hour})
+	require.NoError(t, err)
+	assert.False(t, policy.IsExpired(now.Add(-89*24*time.Hour), now))
+	assert.True(t, policy.IsExpired(now.Add(-91*24*time.Hour), now))
+}
+
+func TestPasswordPolicy_GeneratePassword(t *testing.T) {
+	policy, err := NewPasswordPolicy(PasswordPolicyConfig{
+		MinLength:     24,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		password, err := policy.GeneratePassword()
+		require.NoError(t, err)
+		assert.Len(t, password, 24)
+		assert.NoError(t, policy.Validate(password, ""))
+	}
+}
@@ -0,0 +1,340 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// RolePayloadKey context key for the resolved role
+const RolePayloadKey = "authorization_role"
+
+// Permission 权限, 格式为 "resource:action"
+type Permission string
+
+const (
+	PermAgentsRead          Permission = "agents:read"          // 查看 Agent
+	PermAgentsWrite         Permission = "agents:write"         // 修改 Agent (标签等)
+	PermAgentsDelete        Permission = "agents:delete"        // 删除 Agent
+	PermConfigurationsRead  Permission = "configurations:read"  // 查看配置
+	PermConfigurationsWrite Permission = "configurations:write" // 创建、修改、删除、回滚配置
+	PermConfigurationsPush  Permission = "configurations:push"  // 推送配置到 Agent
+	PermPackagesRead        Permission = "packages:read"        // 查看和下载软件包
+	PermPackagesWrite       Permission = "packages:write"       // 上传和删除软件包
+	PermEventsRead          Permission = "events:read"          // 订阅事件流
+	PermWebhooksRead        Permission = "webhooks:read"        // 查看 Webhook
+	PermWebhooksWrite       Permission = "webhooks:write"       // 管理 Webhook
+	PermAlertsRead          Permission = "alerts:read"          // 查看告警
+	PermAlertsWrite         Permission = "alerts:write"         // 管理告警规则和静默
+	PermUsersAdmin          Permission = "users:admin"          // 管理用户和角色
+	PermAuditRead           Permission = "audit:read"           // 查看和导出审计日志
+	// PermOrganizationsAdmin 管理所有组织, 只对默认组织中的用户生效 (平台管理员)
+	PermOrganizationsAdmin Permission = "organizations:admin"
+)
+
+// 内置角色
+const (
+	RoleViewer   = "viewer"   // 只读
+	RoleOperator = "operator" // 日常运维
+	RoleAdmin    = "admin"    // 所有权限
+	// RoleUser 旧版本注册用户的默认角色, 按 viewer 处理
+	RoleUser = "user"
+)
+
+// DefaultRole 新注册用户的默认角色
+const DefaultRole = RoleViewer
+
+// AllPermissions 返回所有已知权限
+func AllPermissions() []Permission {
+	return []Permission{
+		PermAgentsRead,
+		PermAgentsWrite,
+		PermAgentsDelete,
+		PermConfigurationsRead,
+		PermConfigurationsWrite,
+		PermConfigurationsPush,
+		PermPackagesRead,
+		PermPackagesWrite,
+		PermEventsRead,
+		PermWebhooksRead,
+		PermWebhooksWrite,
+		PermAlertsRead,
+		PermAlertsWrite,
+		PermUsersAdmin,
+		PermAuditRead,
+		PermOrganizationsAdmin,
+	}
+}
+
+// ValidatePermission 检查权限字符串是否有效 (包括通配符)
+func ValidatePermission(p string) bool {
+	if p == "*" {
+		return true
+	}
+	for _, known := range AllPermissions() {
+		if p == string(known) {
+			return true
+		}
+		if resource, _, ok := strings.Cut(string(known), ":"); ok && p == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// BuiltinRoles 返回内置角色
+func BuiltinRoles() []*model.Role {
+	viewer := []string{
+		string(PermAgentsRead),
+		string(PermConfigurationsRead),
+		string(PermPackagesRead),
+		string(PermEventsRead),
+		string(PermWebhooksRead),
+		string(PermAlertsRead),
+	}
+	operator := append(append([]string{}, viewer...),
+		string(PermAgentsWrite),
+		string(PermAgentsDelete),
+		string(PermConfigurationsWrite),
+		string(PermConfigurationsPush),
+		string(PermPackagesWrite),
+		string(PermWebhooksWrite),
+		string(PermAlertsWrite),
+	)
+
+	return []*model.Role{
+		{Name: RoleViewer, Description: "Read-only access", Permissions: viewer, BuiltIn: true},
+		{Name: RoleOperator, Description: "Manage agents, configurations and packages", Permissions: operator, BuiltIn: true},
+		{Name: RoleAdmin, Description: "Full access including user management", Permissions: []string{"*"}, BuiltIn: true},
+	}
+}
+
+// IsBuiltinRole 检查角色名是否为内置角色
+func IsBuiltinRole(name string) bool {
+	return builtinRole(name) != nil
+}
+
+// builtinRole 查找内置角色
+func builtinRole(name string) *model.Role {
+	if name == RoleUser {
+		name = RoleViewer
+	}
+	for _, role := range BuiltinRoles() {
+		if role.Name == name {
+			return role
+		}
+	}
+	return nil
+}
+
+// RoleStore 定义权限检查所需的存储接口
+type RoleStore interface {
+	GetRoleByName(ctx context.Context, name string) (*model.Role, error)
+	GetUserByID(ctx context.Context, id uint) (*model.User, error)
+}
+
+// Authorizer 基于角色的权限检查
+type Authorizer struct {
+	store RoleStore
+}
+
+// NewAuthorizer 创建权限检查器
+//
+// store 不为空时每次请求都从数据库读取用户, 因此禁用用户和修改角色立即生效;
+// store 为空时只支持内置角色, 并直接使用 token 中的角色。
+func NewAuthorizer(store RoleStore) *Authorizer {
+	return &Authorizer{store: store}
+}
+
+// ResolveRole 根据角色名获取角色, 角色不存在时返回 nil
+func (a *Authorizer) ResolveRole(ctx context.Context, name string) (*model.Role, error) {
+	if role := builtinRole(name); role != nil {
+		return role, nil
+	}
+	if a.store == nil {
+		return nil, nil
+	}
+	return a.store.GetRoleByName(ctx, name)
+}
+
+// Require 要求当前用户拥有所有指定权限, 必须在 AuthMiddleware 之后使用
+func (a *Authorizer) Require(permissions ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.Check(c, permissions...) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// Check 检查当前用户是否拥有所有指定权限, 没有时写入错误响应并返回 false
+//
+// 用于所需权限取决于请求内容的处理器, 其余情况使用 Require。
+func (a *Authorizer) Check(c *gin.Context, permissions ...Permission) bool {
+	role, ok := a.currentRole(c)
+	if !ok {
+		return false
+	}
+
+	// API 令牌的权限还受令牌 scopes 限制
+	var scopes *model.Role
+	if claims, exists := GetCurrentUser(c); exists && claims.APITokenID != 0 {
+		scopes = &model.Role{Permissions: claims.Scopes}
+	}
+
+	for _, p := range permissions {
+		if !role.HasPermission(string(p)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":      "insufficient permissions",
+				"permission": p,
+			})
+			return false
+		}
+		if scopes != nil && !scopes.HasPermission(string(p)) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":      "token scope does not include permission",
+				"permission": p,
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// currentRole 解析当前用户的角色并缓存在 context 中, 失败时写入错误响应
+func (a *Authorizer) currentRole(c *gin.Context) (*model.Role, bool) {
+	if role, exists := GetCurrentRole(c); exists {
+		return role, true
+	}
+
+	claims, exists := GetCurrentUser(c)
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not authenticated"})
+		return nil, false
+	}
+
+	roleName := claims.Role
+	if a.store != nil {
+		user, err := a.store.GetUserByID(c.Request.Context(), claims.UserID)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+			return nil, false
+		}
+		if user == nil || !user.IsActive {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user account is disabled"})
+			return nil, false
+		}
+		roleName = user.Role
+	}
+
+	role, err := a.ResolveRole(c.Request.Context(), roleName)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve role"})
+		return nil, false
+	}
+	if role == nil {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "unknown role"})
+		return nil, false
+	}
+
+	c.Set(RolePayloadKey, role)
+	return role, true
+}
+
+// GetCurrentRole 从 context 中获取当前用户的角色
+func GetCurrentRole(c *gin.Context) (*model.Role, bool) {
+	payload, exists := c.Get(RolePayloadKey)
+	if !exists {
+		return nil, false
+	}
+
+	role, ok := payload.(*model.Role)
+	return role, ok
+}
+
+// CanGrant 检查当前用户能否授予角色
+//
+// 角色的每项权限 (通配符按其覆盖的所有权限计算) 当前用户都必须拥有, 使用 API 令牌时还受令牌 scopes 限制,
+// 且角色的范围不能超出当前用户的范围。用于创建、修改和分配角色, 防止拥有 users:admin 的用户提升权限。
+func CanGrant(c *gin.Context, role *model.Role) bool {
+	holder, exists := GetCurrentRole(c)
+	if !exists {
+		return false
+	}
+	var scopes *model.Role
+	if claims, exists := GetCurrentUser(c); exists && claims.APITokenID != 0 {
+		scopes = &model.Role{Permissions: claims.Scopes}
+	}
+
+	for _, p := range role.Permissions {
+		for _, covered := range coveredPermissions(p) {
+			if !holder.HasPermission(covered) || (scopes != nil && !scopes.HasPermission(covered)) {
+				return false
+			}
+		}
+	}
+	return SelectorInScope(c, role.Selector)
+}
+
+// coveredPermissions 返回权限 (包括通配符) 覆盖的所有已知权限
+func coveredPermissions(p string) []string {
+	resource, action, _ := strings.Cut(p, ":")
+	if p != "*" && action != "*" {
+		return []string{p}
+	}
+
+	var covered []string
+	for _, known := range AllPermissions() {
+		if p == "*" || strings.HasPrefix(string(known), resource+":") {
+			covered = append(covered, string(known))
+		}
+	}
+	return covered
+}
+
+// ScopeSelector 返回当前角色的 Agent 范围选择器, 不限范围时返回 nil
+func ScopeSelector(c *gin.Context) map[string]string {
+	role, exists := GetCurrentRole(c)
+	if !exists || !role.IsScoped() {
+		return nil
+	}
+	return role.Selector
+}
+
+// InScope 检查 Agent 标签是否在当前角色的范围内
+func InScope(c *gin.Context, labels model.Labels) bool {
+	return labels.Matches(ScopeSelector(c))
+}
+
+// SelectorInScope 检查选择器是否包含当前角色范围的所有条件
+//
+// 满足时选择器匹配的 Agent 必然都在范围内, 用于校验配置等带选择器的资源。不限范围时总是返回 true。
+func SelectorInScope(c *gin.Context, selector map[string]string) bool {
+	return InScope(c, model.Labels(selector))
+}
+
+// MergeScope 将当前角色的范围合并到请求的选择器中
+//
+// 两者对同一个 key 要求不同的值时结果必然为空, 此时返回 false。
+func MergeScope(c *gin.Context, selector map[string]string) (map[string]string, bool) {
+	scope := ScopeSelector(c)
+	if len(scope) == 0 {
+		return selector, true
+	}
+
+	merged := make(map[string]string, len(selector)+len(scope))
+	for k, v := range selector {
+		merged[k] = v
+	}
+	for k, v := range scope {
+		if existing, ok := merged[k]; ok && existing != v {
+			return nil, false
+		}
+		merged[k] = v
+	}
+	return merged, true
+}
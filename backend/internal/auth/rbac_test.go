@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+type mockRoleStore struct {
+	roles map[string]*model.Role
+	users map[uint]*model.User
+}
+
+func (m *mockRoleStore) GetRoleByName(ctx context.Context, name string) (*model.Role, error) {
+	return m.roles[name], nil
+}
+
+func (m *mockRoleStore) GetUserByID(ctx context.Context, id uint) (*model.User, error) {
+	return m.users[id], nil
+}
+
+func TestBuiltinRoles(t *testing.T) {
+	authorizer := NewAuthorizer(nil)
+	ctx := context.Background()
+
+	viewer, err := authorizer.ResolveRole(ctx, RoleViewer)
+	require.NoError(t, err)
+	assert.True(t, viewer.HasPermission(string(PermAgentsRead)))
+	assert.False(t, viewer.HasPermission(string(PermAgentsDelete)))
+
+	// 旧版本的 user 角色按 viewer 处理
+	legacy, err := authorizer.ResolveRole(ctx, RoleUser)
+	require.NoError(t, err)
+	assert.Equal(t, RoleViewer, legacy.Name)
+
+	operator, err := authorizer.ResolveRole(ctx, RoleOperator)
+	require.NoError(t, err)
+	assert.True(t, operator.HasPermission(string(PermConfigurationsPush)))
+	assert.False(t, operator.HasPermission(string(PermUsersAdmin)))
+
+	admin, err := authorizer.ResolveRole(ctx, RoleAdmin)
+	require.NoError(t, err)
+	for _, p := range AllPermissions() {
+		assert.True(t, admin.HasPermission(string(p)), p)
+	}
+
+	unknown, err := authorizer.ResolveRole(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, unknown)
+}
+
+func TestRoleHasPermission_Wildcard(t *testing.T) {
+	role := &model.Role{Permissions: []string{"agents:*"}}
+	assert.True(t, role.HasPermission(string(PermAgentsDelete)))
+	assert.False(t, role.HasPermission(string(PermConfigurationsWrite)))
+}
+
+func TestValidatePermission(t *testing.T) {
+	assert.True(t, ValidatePermission("*"))
+	assert.True(t, ValidatePermission("agents:read"))
+	assert.True(t, ValidatePermission("configurations:*"))
+	assert.False(t, ValidatePermission("agents:fly"))
+	assert.False(t, ValidatePermission("robots:*"))
+}
+
+func TestAuthorizerRequire(t *testing.T) {
+	manager := NewJWTManager("test-secret", 24*time.Hour)
+	store := &mockRoleStore{
+		roles: map[string]*model.Role{
+			"team-a": {
+				Name:        "team-a",
+				Permissions: []string{string(PermAgentsRead)},
+				Selector:    map[string]string{"team": "a"},
+			},
+		},
+		users: make(map[uint]*model.User),
+	}
+	authorizer := NewAuthorizer(store)
+
+	newRouter := func() *gin.Engine {
+		router := setupTestRouter()
+		router.Use(AuthMiddleware(manager))
+		router.DELETE("/agents", authorizer.Require(PermAgentsDelete), func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+		router.GET("/agents", authorizer.Require(PermAgentsRead), func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"scope": ScopeSelector(c)})
+		})
+		return router
+	}
+
+	tests := []struct {
+		name     string
+		role     string
+		disabled bool
+		method   string
+		want     int
+	}{
+		{"viewer can read", RoleViewer, false, http.MethodGet, http.StatusOK},
+		{"viewer cannot delete", RoleViewer, false, http.MethodDelete, http.StatusForbidden},
+		{"operator can delete", RoleOperator, false, http.MethodDelete, http.StatusNoContent},
+		{"custom role can read", "team-a", false, http.MethodGet, http.StatusOK},
+		{"custom role cannot delete", "team-a", false, http.MethodDelete, http.StatusForbidden},
+		{"unknown role", "ghost", false, http.MethodGet, http.StatusForbidden},
+		{"disabled user", RoleAdmin, true, http.MethodGet, http.StatusUnauthorized},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uint(i + 1)
+			store.users[userID] = &model.User{ID: userID, Role: tt.role, IsActive: !tt.disabled}
+
+			// token 中的角色以数据库为准
+			token, err := manager.GenerateAccessToken(&model.User{ID: userID, Username: "testuser", Role: RoleAdmin})
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, "/agents", nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			newRouter().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
+
+func TestMergeScope(t *testing.T) {
+	newContext := func(role *model.Role) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if role != nil {
+			c.Set(RolePayloadKey, role)
+		}
+		return c
+	}
+
+	// 不限范围时原样返回
+	merged, ok := MergeScope(newContext(nil), map[string]string{"env": "prod"})
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"env": "prod"}, merged)
+
+	scoped := newContext(&model.Role{Selector: map[string]string{"team": "a"}})
+	merged, ok = MergeScope(scoped, map[string]string{"env": "prod"})
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"env": "prod", "team": "a"}, merged)
+
+	_, ok = MergeScope(scoped, map[string]string{"team": "b"})
+	assert.False(t, ok)
+
+	assert.True(t, InScope(scoped, model.Labels{"team": "a", "env": "dev"}))
+	assert.False(t, InScope(scoped, model.Labels{"team": "b"}))
+
+	assert.True(t, SelectorInScope(scoped, map[string]string{"team": "a", "env": "dev"}))
+	assert.False(t, SelectorInScope(scoped, map[string]string{"env": "dev"}))
+	assert.False(t, SelectorInScope(scoped, nil))
+	assert.True(t, SelectorInScope(newContext(nil), nil))
+}
+
+func TestCanGrant(t *testing.T) {
+	newContext := func(role *model.Role) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(RolePayloadKey, role)
+		return c
+	}
+	usersAdmin := newContext(&model.Role{Permissions: []string{string(PermUsersAdmin), string(PermAgentsRead), "configurations:*"}})
+
+	tests := []struct {
+		name string
+		c    *gin.Context
+		role *model.Role
+		want bool
+	}{
+		{"admin grants admin", newContext(builtinRole(RoleAdmin)), builtinRole(RoleAdmin), true},
+		{"held permissions", usersAdmin, &model.Role{Permissions: []string{string(PermAgentsRead), string(PermConfigurationsPush)}}, true},
+		{"held resource wildcard", usersAdmin, &model.Role{Permissions: []string{"configurations:*"}}, true},
+		{"wildcard not held", usersAdmin, &model.Role{Permissions: []string{"*"}}, false},
+		{"resource wildcard not held", usersAdmin, &model.Role{Permissions: []string{"agents:*"}}, false},
+		{"admin role not held", usersAdmin, builtinRole(RoleAdmin), false},
+		{"scope narrowed", newContext(&model.Role{Permissions: []string{"*"}, Selector: map[string]string{"env": "dev"}}),
+			&model.Role{Permissions: []string{string(PermAgentsRead)}, Selector: map[string]string{"env": "dev", "team": "a"}}, true},
+		{"scope widened", newContext(&model.Role{Permissions: []string{"*"}, Selector: map[string]string{"env": "dev"}}),
+			&model.Role{Permissions: []string{string(PermAgentsRead)}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CanGrant(tt.c, tt.role))
+		})
+	}
+}
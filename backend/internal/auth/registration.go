@@ -0,0 +1,24 @@
+package auth
+
+import "fmt"
+
+// RegistrationMode 自助注册模式
+type RegistrationMode string
+
+const (
+	RegistrationDisabled RegistrationMode = "disabled" // 禁止自助注册, 只能由管理员创建用户
+	RegistrationInvite   RegistrationMode = "invite"   // 只能通过邀请注册
+	RegistrationOpen     RegistrationMode = "open"     // 任何人都可以注册
+)
+
+// ParseRegistrationMode 解析注册模式, 空字符串按 invite 处理
+func ParseRegistrationMode(s string) (RegistrationMode, error) {
+	switch mode := RegistrationMode(s); mode {
+	case "":
+		return RegistrationInvite, nil
+	case RegistrationDisabled, RegistrationInvite, RegistrationOpen:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown registration mode: %s", s)
+	}
+}
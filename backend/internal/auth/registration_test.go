@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRegistrationMode(t *testing.T) {
+	mode, err := ParseRegistrationMode("")
+	require.NoError(t, err)
+	assert.Equal(t, RegistrationInvite, mode)
+
+	mode, err = ParseRegistrationMode("open")
+	require.NoError(t, err)
+	assert.Equal(t, RegistrationOpen, mode)
+
+	_, err = ParseRegistrationMode("public")
+	assert.Error(t, err)
+}
+
+func TestOpaqueToken(t *testing.T) {
+	a, err := GenerateOpaqueToken()
+	require.NoError(t, err)
+	b, err := GenerateOpaqueToken()
+	require.NoError(t, err)
+
+	assert.Len(t, a, 64)
+	assert.NotEqual(t, a, b)
+	assert.Equal(t, HashToken(a), HashToken(a))
+	assert.NotEqual(t, a, HashToken(a))
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// RevocationStore 定义撤销检查所需的存储接口
+type RevocationStore interface {
+	GetUserByID(ctx context.Context, id uint) (*model.User, error)
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// StoreRevocationChecker 基于数据库的撤销检查
+//
+// 以下情况访问令牌视为已撤销: 用户不存在或已禁用, 用户的令牌版本已变化
+// (修改密码、禁用、退出所有会话), 用户已不属于令牌中的组织, 或令牌 ID 被单独撤销 (退出登录)。
+type StoreRevocationChecker struct {
+	store RevocationStore
+}
+
+// NewRevocationChecker 创建撤销检查
+func NewRevocationChecker(store RevocationStore) *StoreRevocationChecker {
+	return &StoreRevocationChecker{store: store}
+}
+
+// Check 检查访问令牌是否已被撤销
+func (r *StoreRevocationChecker) Check(ctx context.Context, claims *Claims) error {
+	user, err := r.store.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsActive || user.TokenVersion != claims.TokenVersion {
+		return ErrRevokedToken
+	}
+	if organizationOrDefault(user.OrganizationID) != organizationOrDefault(claims.OrganizationID) {
+		return ErrRevokedToken
+	}
+
+	if claims.ID != "" {
+		revoked, err := r.store.IsAccessTokenRevoked(ctx, claims.ID)
+		if err != nil {
+			return err
+		}
+		if revoked {
+			return ErrRevokedToken
+		}
+	}
+
+	return nil
+}
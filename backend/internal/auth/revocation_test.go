@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+type mockRevocationStore struct {
+	users   map[uint]*model.User
+	revoked map[string]bool
+}
+
+func (m *mockRevocationStore) GetUserByID(ctx context.Context, id uint) (*model.User, error) {
+	return m.users[id], nil
+}
+
+func (m *mockRevocationStore) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	return m.revoked[jti], nil
+}
+
+func TestGenerateAccessToken(t *testing.T) {
+	manager := NewJWTManager("test-secret", 15*time.Minute)
+	user := &model.User{ID: 7, Username: "alice", Role: RoleOperator, TokenVersion: 3, OrganizationID: 4}
+
+	token, err := manager.GenerateAccessToken(user)
+	require.NoError(t, err)
+
+	claims, err := manager.VerifyToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), claims.UserID)
+	assert.Equal(t, 3, claims.TokenVersion)
+	assert.Equal(t, uint(4), claims.OrganizationID)
+	assert.NotEmpty(t, claims.ID)
+
+	// 每个令牌的 ID 都不同
+	other, err := manager.GenerateAccessToken(user)
+	require.NoError(t, err)
+	otherClaims, err := manager.VerifyToken(other)
+	require.NoError(t, err)
+	assert.NotEqual(t, claims.ID, otherClaims.ID)
+}
+
+func TestAuthMiddleware_Revocation(t *testing.T) {
+	manager := NewJWTManager("test-secret", 15*time.Minute)
+	user := &model.User{ID: 1, Username: "alice", Role: RoleViewer, IsActive: true}
+	store := &mockRevocationStore{
+		users:   map[uint]*model.User{1: user},
+		revoked: make(map[string]bool),
+	}
+	manager.SetRevocationChecker(NewRevocationChecker(store))
+
+	router := setupTestRouter()
+	router.Use(AuthMiddleware(manager))
+	router.GET("/protected", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	request := func(token string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/protected", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	token, err := manager.GenerateAccessToken(user)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, request(token))
+
+	// 单独撤销 (退出登录)
+	claims, err := manager.VerifyToken(token)
+	require.NoError(t, err)
+	store.revoked[claims.ID] = true
+	assert.Equal(t, http.StatusUnauthorized, request(token))
+
+	// 令牌版本变化 (修改密码)
+	token, err = manager.GenerateAccessToken(user)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, request(token))
+	user.TokenVersion++
+	assert.Equal(t, http.StatusUnauthorized, request(token))
+
+	// 用户被移到其他组织
+	token, err = manager.GenerateAccessToken(user)
+	require.NoError(t, err)
+	user.OrganizationID = 2
+	assert.Equal(t, http.StatusUnauthorized, request(token))
+
+	// 用户被禁用
+	token, err = manager.GenerateAccessToken(user)
+	require.NoError(t, err)
+	user.IsActive = false
+	assert.Equal(t, http.StatusUnauthorized, request(token))
+}
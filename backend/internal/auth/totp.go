@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPDigits 验证码位数
+	TOTPDigits = 6
+	// TOTPPeriod 验证码时间步长
+	TOTPPeriod = 30 * time.Second
+	// RecoveryCodeCount 每次生成的恢复码数量
+	RecoveryCodeCount = 10
+
+	// totpSkew 允许前后各偏差的时间步数, 用于容忍客户端时钟误差
+	totpSkew = 1
+)
+
+// totpEncoding TOTP 密钥使用不带填充的 base32 编码 (RFC 4648)
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret 生成 160 位随机 TOTP 密钥
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPProvisioningURI 生成 otpauth:// URI, 供认证器应用扫描二维码
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	params := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprintf("%d", TOTPDigits)},
+		"period":    {fmt.Sprintf("%d", int(TOTPPeriod/time.Second))},
+	}
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// TOTPCode 计算指定时间的验证码 (RFC 6238)
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, totpStep(t)), nil
+}
+
+// ValidateTOTP 校验验证码, 成功时返回验证码对应的时间步
+//
+// 只接受大于 lastStep 的时间步, 防止同一个验证码被重复使用。
+func ValidateTOTP(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return 0, false
+	}
+
+	current := totpStep(t)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if hmac.Equal([]byte(hotp(key, step)), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes 生成一组一次性恢复码, 格式为 xxxxx-xxxxx
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := hex.EncodeToString(b)
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode 计算恢复码的哈希, 忽略大小写, 空格和连字符
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return HashToken(normalized)
+}
+
+// totpStep 返回时间对应的时间步
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// decodeTOTPSecret 解码 base32 密钥, 兼容小写和带填充的写法
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.TrimRight(strings.TrimSpace(secret), "="))
+	return totpEncoding.DecodeString(secret)
+}
+
+// hotp 计算 HOTP 验证码 (RFC 4226)
+func hotp(key []byte, counter int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod)
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret RFC 6238 附录 B 中 SHA1 测试向量使用的密钥 "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// RFC 6238 给出的是 8 位验证码, 6 位验证码为其后 6 位
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	now := time.Now()
+
+	code, err := TOTPCode(secret, now)
+	require.NoError(t, err)
+	step, ok := ValidateTOTP(secret, code, now, 0)
+	require.True(t, ok)
+
+	// 同一个验证码不能重复使用
+	_, ok = ValidateTOTP(secret, code, now, step)
+	assert.False(t, ok)
+
+	// 容忍一个时间步的时钟误差
+	previous, err := TOTPCode(secret, now.Add(-TOTPPeriod))
+	require.NoError(t, err)
+	_, ok = ValidateTOTP(secret, previous, now, 0)
+	assert.True(t, ok)
+
+	stale, err := TOTPCode(secret, now.Add(-3*TOTPPeriod))
+	require.NoError(t, err)
+	_, ok = ValidateTOTP(secret, stale, now, 0)
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(secret, "12345", now, 0)
+	assert.False(t, ok)
+	_, ok = ValidateTOTP("not base32!", code, now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("OpAMP Platform", "alice", "SECRET")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/OpAMP%20Platform:alice?"))
+
+	u, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "SECRET", u.Query().Get("secret"))
+	assert.Equal(t, "OpAMP Platform", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		assert.Len(t, code, 11)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+
+	// 哈希忽略大小写, 空格和连字符
+	assert.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))))
+}
@@ -0,0 +1,230 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// defaultSubscriptionBuffer 订阅通道的默认缓冲大小
+const defaultSubscriptionBuffer = 64
+
+// Type 事件类型
+type Type string
+
+const (
+	TypeAgentConnected        Type = "agent.connected"         // Agent 上线
+	TypeAgentDisconnected     Type = "agent.disconnected"      // Agent 断开连接
+	TypeAgentHeartbeatTimeout Type = "agent.heartbeat_timeout" // Agent 心跳超时
+	TypeAgentStatusChanged    Type = "agent.status_changed"    // Agent 状态变更
+	TypeAgentUnhealthy        Type = "agent.unhealthy"         // Agent 上报组件不健康
+	TypeAgentFlapping         Type = "agent.flapping"          // Agent 抖动状态变更
+	TypeConfigApplied         Type = "config.applied"          // 配置应用成功
+	TypeConfigFailed          Type = "config.failed"           // 配置应用失败
+	TypePackageInstalled      Type = "package.installed"       // 软件包安装完成
+	TypeRolloutProgress       Type = "rollout.progress"        // 配置推送进度
+	TypeAlertFiring           Type = "alert.firing"            // 告警触发
+	TypeAlertResolved         Type = "alert.resolved"          // 告警恢复
+	TypeAuthLockout           Type = "auth.lockout"            // 登录失败次数过多, 账号或 IP 被临时锁定
+)
+
+// AllTypes 返回所有已知的事件类型
+func AllTypes() []Type {
+	return []Type{
+		TypeAgentConnected,
+		TypeAgentDisconnected,
+		TypeAgentHeartbeatTimeout,
+		TypeAgentStatusChanged,
+		TypeAgentUnhealthy,
+		TypeAgentFlapping,
+		TypeConfigApplied,
+		TypeConfigFailed,
+		TypePackageInstalled,
+		TypeRolloutProgress,
+		TypeAlertFiring,
+		TypeAlertResolved,
+		TypeAuthLockout,
+	}
+}
+
+// IsValid 检查事件类型是否有效
+func (t Type) IsValid() bool {
+	for _, known := range AllTypes() {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event 表示一条平台事件
+type Event struct {
+	ID   uint64 `json:"id"`
+	Type Type   `json:"type"`
+	// OrganizationID 事件所属组织, 只投递给该组织的订阅者
+	OrganizationID uint                   `json:"organization_id,omitempty"`
+	AgentID        string                 `json:"agent_id,omitempty"`
+	Labels         model.Labels           `json:"labels,omitempty"`
+	Data           map[string]interface{} `json:"data,omitempty"`
+	Timestamp      time.Time              `json:"timestamp"`
+}
+
+// Filter 事件过滤条件
+type Filter struct {
+	Types          []Type            // 为空则接收所有类型
+	Selector       map[string]string // 标签选择器, 为空则不过滤
+	OrganizationID uint              // 只接收该组织的事件, 为 0 则不过滤
+}
+
+// Matches 检查事件是否满足过滤条件
+func (f Filter) Matches(e *Event) bool {
+	if f.OrganizationID != 0 && e.OrganizationID != f.OrganizationID {
+		return false
+	}
+
+	if len(f.Types) > 0 {
+		matched := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return e.Labels.Matches(f.Selector)
+}
+
+// Subscription 事件订阅
+type Subscription struct {
+	C      <-chan *Event
+	ch     chan *Event
+	filter Filter
+}
+
+// Bus 进程内事件总线
+//
+// 发布的事件会保存在一个固定大小的环形缓冲区中, 订阅者可以通过
+// Last-Event-ID 从缓冲区中补齐断线期间错过的事件。
+//
+// 事件 ID 从创建总线时的微秒时间戳开始递增, 服务重启后新事件的 ID 仍然大于重启前的 ID,
+// 客户端携带重启前的 Last-Event-ID 重连时不会漏掉事件。微秒时间戳小于 2^53,
+// 在 JavaScript 中也能精确表示。
+type Bus struct {
+	mu          sync.RWMutex
+	nextID      uint64
+	buffer      []*Event
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus 创建新的事件总线
+func NewBus(bufferSize int) *Bus {
+	if bufferSize <= 0 {
+		bufferSize = 1000 // 默认保留最近 1000 条事件
+	}
+
+	return &Bus{
+		nextID:      uint64(time.Now().UnixMicro()),
+		bufferSize:  bufferSize,
+		buffer:      make([]*Event, 0, bufferSize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish 发布事件, 自动分配 ID 和时间戳
+//
+// 对 nil Bus 调用是安全的, 便于在未启用事件总线时直接调用。
+func (b *Bus) Publish(e Event) *Event {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	event := &e
+
+	if len(b.buffer) >= b.bufferSize {
+		b.buffer = b.buffer[1:]
+	}
+	b.buffer = append(b.buffer, event)
+
+	for sub := range b.subscribers {
+		if !sub.filter.Matches(event) {
+			continue
+		}
+		// 订阅者处理过慢时丢弃事件, 避免阻塞发布方
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+
+	return event
+}
+
+// Subscribe 订阅事件
+//
+// lastEventID 大于 0 时, 返回缓冲区中该 ID 之后且满足过滤条件的事件,
+// 调用方应先处理这些补发的事件再读取 Subscription.C。
+func (b *Bus) Subscribe(filter Filter, lastEventID uint64) (*Subscription, []*Event) {
+	return b.SubscribeWithBuffer(filter, lastEventID, defaultSubscriptionBuffer)
+}
+
+// SubscribeWithBuffer 订阅事件并指定订阅通道的缓冲大小
+//
+// 后台消费者 (例如 Webhook 投递) 可以使用更大的缓冲区, 以免突发事件被丢弃。
+func (b *Bus) SubscribeWithBuffer(filter Filter, lastEventID uint64, bufferSize int) (*Subscription, []*Event) {
+	if bufferSize <= 0 {
+		bufferSize = defaultSubscriptionBuffer
+	}
+	ch := make(chan *Event, bufferSize)
+	sub := &Subscription{
+		C:      ch,
+		ch:     ch,
+		filter: filter,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []*Event
+	if lastEventID > 0 {
+		for _, event := range b.buffer {
+			if event.ID > lastEventID && filter.Matches(event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	b.subscribers[sub] = struct{}{}
+	return sub, missed
+}
+
+// Unsubscribe 取消订阅
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// SubscriberCount 返回当前订阅者数量
+func (b *Bus) SubscriberCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
@@ -0,0 +1,153 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+func receive(t *testing.T, sub *Subscription) *Event {
+	t.Helper()
+	select {
+	case e := <-sub.C:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+		return nil
+	}
+}
+
+func TestBus_PublishAssignsIDAndTimestamp(t *testing.T) {
+	bus := NewBus(10)
+
+	e1 := bus.Publish(Event{Type: TypeAgentConnected, AgentID: "agent-1"})
+	e2 := bus.Publish(Event{Type: TypeAgentDisconnected, AgentID: "agent-1"})
+
+	assert.Equal(t, e1.ID+1, e2.ID)
+	assert.False(t, e1.Timestamp.IsZero())
+}
+
+func TestBus_IDsIncreaseAcrossRestarts(t *testing.T) {
+	before := NewBus(10).Publish(Event{Type: TypeAgentConnected})
+	time.Sleep(time.Millisecond)
+
+	// 新的总线 (服务重启) 分配的 ID 大于之前的 ID
+	bus := NewBus(10)
+	after := bus.Publish(Event{Type: TypeAgentConnected})
+	assert.Greater(t, after.ID, before.ID)
+
+	// 携带重启前的 ID 订阅时补发重启后的所有事件
+	sub, missed := bus.Subscribe(Filter{}, before.ID)
+	defer bus.Unsubscribe(sub)
+	require.Len(t, missed, 1)
+	assert.Equal(t, after.ID, missed[0].ID)
+}
+
+func TestBus_NilBusIsSafe(t *testing.T) {
+	var bus *Bus
+	assert.Nil(t, bus.Publish(Event{Type: TypeAgentConnected}))
+}
+
+func TestBus_SubscribeWithFilter(t *testing.T) {
+	bus := NewBus(10)
+
+	sub, missed := bus.Subscribe(Filter{
+		Types:    []Type{TypeAgentDisconnected},
+		Selector: map[string]string{"env": "prod"},
+	}, 0)
+	defer bus.Unsubscribe(sub)
+	assert.Empty(t, missed)
+
+	bus.Publish(Event{Type: TypeAgentConnected, Labels: model.Labels{"env": "prod"}})
+	bus.Publish(Event{Type: TypeAgentDisconnected, Labels: model.Labels{"env": "dev"}})
+	bus.Publish(Event{Type: TypeAgentDisconnected, AgentID: "agent-prod", Labels: model.Labels{"env": "prod"}})
+
+	e := receive(t, sub)
+	assert.Equal(t, "agent-prod", e.AgentID)
+	assert.Equal(t, TypeAgentDisconnected, e.Type)
+
+	select {
+	case extra := <-sub.C:
+		t.Fatalf("unexpected event: %+v", extra)
+	default:
+	}
+}
+
+func TestBus_SubscribeFiltersOrganization(t *testing.T) {
+	bus := NewBus(10)
+	first := bus.Publish(Event{Type: TypeAgentConnected, OrganizationID: 2, AgentID: "agent-0"})
+	bus.Publish(Event{Type: TypeAgentConnected, OrganizationID: 1, AgentID: "agent-1"})
+	bus.Publish(Event{Type: TypeAgentConnected, OrganizationID: 2, AgentID: "agent-2"})
+
+	// 补齐的历史事件同样按组织过滤
+	sub, missed := bus.Subscribe(Filter{OrganizationID: 2}, first.ID)
+	defer bus.Unsubscribe(sub)
+	require.Len(t, missed, 1)
+	assert.Equal(t, "agent-2", missed[0].AgentID)
+
+	bus.Publish(Event{Type: TypeAgentDisconnected, OrganizationID: 1, AgentID: "agent-1"})
+	bus.Publish(Event{Type: TypeAgentDisconnected, OrganizationID: 2, AgentID: "agent-2"})
+	e := receive(t, sub)
+	assert.Equal(t, uint(2), e.OrganizationID)
+	assert.Equal(t, TypeAgentDisconnected, e.Type)
+}
+
+func TestBus_ResumeFromLastEventID(t *testing.T) {
+	bus := NewBus(10)
+
+	var published []*Event
+	for i := 0; i < 5; i++ {
+		published = append(published, bus.Publish(Event{Type: TypeAgentConnected}))
+	}
+
+	sub, missed := bus.Subscribe(Filter{}, published[2].ID)
+	defer bus.Unsubscribe(sub)
+
+	require.Len(t, missed, 2)
+	assert.Equal(t, published[3].ID, missed[0].ID)
+	assert.Equal(t, published[4].ID, missed[1].ID)
+
+	e := bus.Publish(Event{Type: TypeAgentConnected})
+	assert.Equal(t, e.ID, receive(t, sub).ID)
+}
+
+func TestBus_BufferIsBounded(t *testing.T) {
+	bus := NewBus(3)
+
+	var published []*Event
+	for i := 0; i < 10; i++ {
+		published = append(published, bus.Publish(Event{Type: TypeAgentConnected}))
+	}
+
+	sub, missed := bus.Subscribe(Filter{}, published[0].ID)
+	defer bus.Unsubscribe(sub)
+
+	require.Len(t, missed, 3)
+	assert.Equal(t, published[7].ID, missed[0].ID)
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	bus := NewBus(10)
+
+	sub, _ := bus.Subscribe(Filter{}, 0)
+	assert.Equal(t, 1, bus.SubscriberCount())
+
+	bus.Unsubscribe(sub)
+	assert.Equal(t, 0, bus.SubscriberCount())
+
+	_, ok := <-sub.C
+	assert.False(t, ok, "channel should be closed after unsubscribe")
+
+	// 重复取消订阅不应 panic
+	bus.Unsubscribe(sub)
+}
+
+func TestType_IsValid(t *testing.T) {
+	assert.True(t, TypeAgentConnected.IsValid())
+	assert.True(t, TypeRolloutProgress.IsValid())
+	assert.False(t, Type("unknown").IsValid())
+}
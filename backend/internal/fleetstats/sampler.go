@@ -0,0 +1,173 @@
+// Package fleetstats 定期采样 Agent 的分组统计, 用于展示版本分布等指标随时间的变化
+package fleetstats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// Store 定义采样所需的存储接口
+type Store interface {
+	ListOrganizations(ctx context.Context) ([]*model.Organization, error)
+	CountAgentsBy(ctx context.Context, filter model.AgentFilter, dimension model.FleetDimension) ([]model.FleetBucket, error)
+	CreateFleetStatsSamples(ctx context.Context, samples []*model.FleetStatsSample) error
+	DeleteFleetStatsSamplesBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// DefaultDimensions 总是采样的维度
+var DefaultDimensions = []model.FleetDimension{
+	model.FleetDimensionStatus,
+	model.FleetDimensionVersion,
+	model.FleetDimensionOS,
+	model.FleetDimensionArch,
+	model.FleetDimensionConfiguration,
+}
+
+// Config 采样配置
+type Config struct {
+	// Interval 采样间隔
+	Interval time.Duration
+	// Retention 采样保留时长, 0 表示永久保留
+	Retention time.Duration
+	// LabelKeys 额外按这些标签 key 采样
+	LabelKeys []string
+}
+
+// Sampler 定期按维度统计每个组织的 Agent 并保存采样
+type Sampler struct {
+	store      Store
+	logger     *zap.Logger
+	config     Config
+	dimensions []model.FleetDimension
+	now        func() time.Time
+	stopCh     chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewSampler 创建新的采样器
+func NewSampler(store Store, logger *zap.Logger, config Config) *Sampler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	// 默认值
+	if config.Interval == 0 {
+		config.Interval = 5 * time.Minute
+	}
+
+	dimensions := append([]model.FleetDimension{}, DefaultDimensions...)
+	for _, key := range config.LabelKeys {
+		if key != "" {
+			dimensions = append(dimensions, model.LabelDimension(key))
+		}
+	}
+
+	return &Sampler{
+		store:      store,
+		logger:     logger,
+		config:     config,
+		dimensions: dimensions,
+		now:        time.Now,
+		stopCh:     make(chan struct{}),
+	}
+}
+
+// Dimensions 返回采样的维度
+func (s *Sampler) Dimensions() []model.FleetDimension {
+	return s.dimensions
+}
+
+// Start 启动定期采样
+func (s *Sampler) Start(ctx context.Context) {
+	s.logger.Info("starting fleet stats sampler",
+		zap.Duration("interval", s.config.Interval),
+		zap.Int("dimensions", len(s.dimensions)))
+
+	s.wg.Add(1)
+	go s.run(ctx)
+}
+
+// Stop 停止采样
+func (s *Sampler) Stop() {
+	s.logger.Info("stopping fleet stats sampler")
+	close(s.stopCh)
+	s.wg.Wait()
+}
+
+// run 执行采样循环
+func (s *Sampler) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sample(ctx)
+		case <-s.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sample 对所有组织采样一次, 并清理过期的采样
+//
+// 同一次采样的所有记录使用相同的采样时间, 便于按时间点组合为序列。
+func (s *Sampler) sample(ctx context.Context) {
+	now := s.now().UTC()
+
+	orgs, err := s.store.ListOrganizations(tenant.WithoutOrganization(ctx))
+	if err != nil {
+		s.logger.Error("failed to list organizations", zap.Error(err))
+		return
+	}
+
+	for _, org := range orgs {
+		orgCtx := tenant.WithOrganization(ctx, org.ID)
+
+		var samples []*model.FleetStatsSample
+		for _, dimension := range s.dimensions {
+			buckets, err := s.store.CountAgentsBy(orgCtx, model.AgentFilter{}, dimension)
+			if err != nil {
+				s.logger.Error("failed to count agents",
+					zap.Uint("organization_id", org.ID),
+					zap.String("dimension", string(dimension)),
+					zap.Error(err))
+				continue
+			}
+			for _, bucket := range buckets {
+				samples = append(samples, &model.FleetStatsSample{
+					OrganizationID: org.ID,
+					Dimension:      string(dimension),
+					SampledAt:      now,
+					Value:          bucket.Value,
+					Count:          bucket.Count,
+				})
+			}
+		}
+
+		if err := s.store.CreateFleetStatsSamples(orgCtx, samples); err != nil {
+			s.logger.Error("failed to save fleet stats samples",
+				zap.Uint("organization_id", org.ID),
+				zap.Error(err))
+		}
+	}
+
+	if s.config.Retention > 0 {
+		deleted, err := s.store.DeleteFleetStatsSamplesBefore(tenant.WithoutOrganization(ctx), now.Add(-s.config.Retention))
+		if err != nil {
+			s.logger.Error("failed to delete expired fleet stats samples", zap.Error(err))
+		} else if deleted > 0 {
+			s.logger.Debug("deleted expired fleet stats samples", zap.Int64("count", deleted))
+		}
+	}
+}
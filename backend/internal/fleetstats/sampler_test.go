@@ -0,0 +1,86 @@
+package fleetstats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// mockStore 内存实现的 Store
+type mockStore struct {
+	orgs          []*model.Organization
+	counts        map[uint]map[model.FleetDimension][]model.FleetBucket
+	countErr      map[model.FleetDimension]error
+	samples       []*model.FleetStatsSample
+	deletedBefore time.Time
+}
+
+func (s *mockStore) ListOrganizations(ctx context.Context) ([]*model.Organization, error) {
+	return s.orgs, nil
+}
+
+func (s *mockStore) CountAgentsBy(ctx context.Context, filter model.AgentFilter, dimension model.FleetDimension) ([]model.FleetBucket, error) {
+	if err := s.countErr[dimension]; err != nil {
+		return nil, err
+	}
+	orgID, _ := tenant.OrganizationID(ctx)
+	return s.counts[orgID][dimension], nil
+}
+
+func (s *mockStore) CreateFleetStatsSamples(ctx context.Context, samples []*model.FleetStatsSample) error {
+	s.samples = append(s.samples, samples...)
+	return nil
+}
+
+func (s *mockStore) DeleteFleetStatsSamplesBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.deletedBefore = before
+	return 0, nil
+}
+
+func TestSampler_Sample(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := &mockStore{
+		orgs: []*model.Organization{{ID: 1}, {ID: 2}},
+		counts: map[uint]map[model.FleetDimension][]model.FleetBucket{
+			1: {
+				model.FleetDimensionVersion: {{Value: "1.0", Count: 3}, {Value: "1.1", Count: 1}},
+				model.LabelDimension("env"): {{Value: "prod", Count: 4}},
+			},
+			2: {
+				model.FleetDimensionVersion: {{Value: "1.1", Count: 2}},
+			},
+		},
+		countErr: map[model.FleetDimension]error{
+			model.FleetDimensionOS: errors.New("boom"),
+		},
+	}
+
+	sampler := NewSampler(store, nil, Config{Retention: 24 * time.Hour, LabelKeys: []string{"env"}})
+	sampler.now = func() time.Time { return now }
+	assert.Contains(t, sampler.Dimensions(), model.LabelDimension("env"))
+
+	sampler.sample(context.Background())
+
+	// 统计失败的维度被跳过, 其余维度照常保存
+	require.Len(t, store.samples, 4)
+	for _, sample := range store.samples {
+		assert.Equal(t, now, sample.SampledAt)
+		assert.NotEqual(t, string(model.FleetDimensionOS), sample.Dimension)
+	}
+	assert.Equal(t, &model.FleetStatsSample{
+		OrganizationID: 2,
+		Dimension:      string(model.FleetDimensionVersion),
+		SampledAt:      now,
+		Value:          "1.1",
+		Count:          2,
+	}, store.samples[3])
+
+	assert.Equal(t, now.Add(-24*time.Hour), store.deletedBefore)
+}
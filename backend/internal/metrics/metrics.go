@@ -27,14 +27,21 @@ type Metrics struct {
 	AgentStaleCount      prometheus.Gauge
 	AgentLastSeenSeconds *prometheus.GaugeVec
 
-	// Agent 连接时长指标
-	AgentConnectionDuration *prometheus.HistogramVec
+	// Agent 抖动指标
+	AgentsFlapping           prometheus.Gauge
+	AgentFlappingTransitions *prometheus.CounterVec
+
+	// Agent 连接时长指标, 不按 Agent 区分以避免标签基数随 Agent 数量增长
+	AgentConnectionDuration prometheus.Histogram
 
 	// Configuration 指标
 	ConfigurationsTotal      prometheus.Gauge
 	ConfigurationChangesTotal prometheus.Counter
 	ConfigurationPushTotal    *prometheus.CounterVec
 
+	// 历史记录清理指标
+	HistoryRowsPruned *prometheus.CounterVec
+
 	// 数据库指标
 	DBConnectionsOpen prometheus.Gauge
 	DBConnectionsIdle prometheus.Gauge
@@ -150,6 +157,21 @@ func NewMetrics(namespace string) *Metrics {
 				Help:      "Number of agents with stale heartbeats",
 			},
 		),
+		AgentsFlapping: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "agents_flapping",
+				Help:      "Number of agents currently detected as flapping",
+			},
+		),
+		AgentFlappingTransitions: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "agent_flapping_transitions_total",
+				Help:      "Total number of agents entering or leaving the flapping state",
+			},
+			[]string{"state"},
+		),
 		AgentLastSeenSeconds: promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Namespace: namespace,
@@ -158,14 +180,13 @@ func NewMetrics(namespace string) *Metrics {
 			},
 			[]string{"agent_id"},
 		),
-		AgentConnectionDuration: promauto.NewHistogramVec(
+		AgentConnectionDuration: promauto.NewHistogram(
 			prometheus.HistogramOpts{
 				Namespace: namespace,
 				Name:      "agent_connection_duration_seconds",
 				Help:      "Agent connection duration in seconds",
 				Buckets:   []float64{60, 300, 600, 1800, 3600, 7200, 14400, 28800, 86400}, // 1m, 5m, 10m, 30m, 1h, 2h, 4h, 8h, 24h
 			},
-			[]string{"agent_id"},
 		),
 
 		// Configuration 指标
@@ -192,6 +213,16 @@ func NewMetrics(namespace string) *Metrics {
 			[]string{"status"},
 		),
 
+		// 历史记录清理指标
+		HistoryRowsPruned: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "history_rows_pruned_total",
+				Help:      "Total number of history rows deleted by retention policies",
+			},
+			[]string{"table"},
+		),
+
 		// 数据库指标
 		DBConnectionsOpen: promauto.NewGauge(
 			prometheus.GaugeOpts{
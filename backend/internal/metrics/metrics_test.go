@@ -47,6 +47,7 @@ func TestNewMetrics(t *testing.T) {
 			assert.NotNil(t, m.ConfigurationsTotal)
 			assert.NotNil(t, m.ConfigurationChangesTotal)
 			assert.NotNil(t, m.ConfigurationPushTotal)
+			assert.NotNil(t, m.HistoryRowsPruned)
 
 			assert.NotNil(t, m.DBConnectionsOpen)
 			assert.NotNil(t, m.DBConnectionsIdle)
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIDHeader 请求 ID 的 HTTP 头
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey context key for the request ID
+	RequestIDKey = "request_id"
+)
+
+// validRequestID 只接受由字母、数字和常见分隔符组成的外部请求 ID, 避免日志注入
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID 为每个请求分配请求 ID, 沿用调用方传入的合法 X-Request-ID, 并在响应头中返回
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		c.Set(RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID 从 context 中获取请求 ID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// newRequestID 生成随机请求 ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,400 @@
+// Package migrate 按版本执行 PostgreSQL 的 SQL 迁移文件
+//
+// 已执行的版本保存在 schema_migrations 表中, 格式与 golang-migrate 相同, 因此
+// 两者可以交替使用。多个副本同时启动时通过 advisory lock 保证只有一个执行迁移。
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// lockID 执行迁移时持有的 advisory lock
+const lockID int64 = 7_305_412_853
+
+// fileNamePattern 迁移文件名: {version}_{description}.{up|down}.sql
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+var (
+	// ErrDirty 上次迁移执行失败, 需要修复后使用 Force 设置版本
+	ErrDirty = errors.New("database schema is dirty, fix it manually and force a version")
+	// ErrNoChange 没有可以执行的迁移
+	ErrNoChange = errors.New("no change")
+)
+
+// Migration 一个版本的迁移
+type Migration struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	up      string
+	down    string
+}
+
+// Status 数据库的迁移状态
+type Status struct {
+	// Current 已执行的最新版本, 0 表示还没有执行任何迁移
+	Current uint `json:"current"`
+	// Latest 迁移文件中的最新版本
+	Latest uint `json:"latest"`
+	// Dirty 上次迁移执行失败
+	Dirty bool `json:"dirty"`
+	// Pending 还没有执行的迁移
+	Pending []Migration `json:"pending"`
+}
+
+// UpToDate 检查数据库是否已执行所有迁移
+func (s *Status) UpToDate() bool {
+	return !s.Dirty && len(s.Pending) == 0
+}
+
+// Migrator 执行迁移文件
+type Migrator struct {
+	db         *sql.DB
+	logger     *zap.Logger
+	migrations []Migration
+}
+
+// New 从 fsys 加载迁移文件并创建迁移器
+func New(db *sql.DB, fsys fs.FS, logger *zap.Logger) (*Migrator, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		logger:     logger,
+		migrations: migrations,
+	}, nil
+}
+
+// Load 加载 fsys 根目录中的迁移文件, 按版本排序
+//
+// 每个版本必须同时有 up 和 down 文件。
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[uint]*Migration)
+	hasDown := make(map[uint]bool)
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version: %s", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: match[2]}
+			byVersion[uint(version)] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+			hasDown[m.Version] = true
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		if !hasDown[m.Version] {
+			return nil, fmt.Errorf("migration %d_%s has no down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Latest 返回迁移文件中的最新版本
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status 返回数据库的迁移状态
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	var status *Status
+	err := m.withConn(ctx, false, func(conn *sql.Conn) error {
+		current, dirty, err := readVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		status = m.status(current, dirty)
+		return nil
+	})
+	return status, err
+}
+
+// status 根据已执行的版本计算迁移状态
+func (m *Migrator) status(current uint, dirty bool) *Status {
+	status := &Status{
+		Current: current,
+		Latest:  m.Latest(),
+		Dirty:   dirty,
+		Pending: []Migration{},
+	}
+	for _, migration := range m.migrations {
+		if migration.Version > current {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+	return status
+}
+
+// Up 执行所有未执行的迁移, 返回执行的迁移
+//
+// 每个迁移在单独的事务中执行, 失败时回滚该迁移并停止, 之前的迁移保持已执行。
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withConn(ctx, true, func(conn *sql.Conn) error {
+		current, dirty, err := readVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirty
+		}
+
+		for _, migration := range m.status(current, dirty).Pending {
+			if err := m.apply(ctx, conn, migration.up, migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.Info("Applied migration",
+				zap.Uint("version", migration.Version),
+				zap.String("name", migration.Name))
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down 回滚最近 steps 个已执行的迁移, 返回回滚的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withConn(ctx, true, func(conn *sql.Conn) error {
+		current, dirty, err := readVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return ErrDirty
+		}
+		if current == 0 {
+			return ErrNoChange
+		}
+		if !m.hasVersion(current) {
+			return fmt.Errorf("database is at version %d which has no migration file", current)
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			migration := m.migrations[i]
+			if migration.Version > current {
+				continue
+			}
+			var previous uint
+			if i > 0 {
+				previous = m.migrations[i-1].Version
+			}
+			if err := m.apply(ctx, conn, migration.down, previous); err != nil {
+				return fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			m.logger.Info("Reverted migration",
+				zap.Uint("version", migration.Version),
+				zap.String("name", migration.Name))
+			reverted = append(reverted, migration)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Force 将数据库标记为已执行到 version 且不执行任何迁移, 0 表示清除版本
+//
+// 用于修复执行失败的迁移, 以及将由 AutoMigrate 创建的数据库纳入版本管理。
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	if version != 0 && !m.hasVersion(version) {
+		return fmt.Errorf("unknown migration version: %d", version)
+	}
+	return m.withConn(ctx, true, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := writeVersion(ctx, tx, version); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+}
+
+// Baseline 数据库没有版本记录但 table 已存在时, 将其标记为已执行到 version, 返回是否已标记
+//
+// 用于在执行迁移前接管由 AutoMigrate 创建的数据库, 这类数据库有完整的表结构但没有
+// schema_migrations 记录, 直接执行迁移会因为表和索引已存在而失败。
+func (m *Migrator) Baseline(ctx context.Context, version uint, table string) (bool, error) {
+	if !m.hasVersion(version) {
+		return false, fmt.Errorf("unknown migration version: %d", version)
+	}
+
+	var marked bool
+	err := m.withConn(ctx, true, func(conn *sql.Conn) error {
+		current, dirty, err := readVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current != 0 || dirty {
+			return nil
+		}
+
+		var exists bool
+		if err := conn.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return nil
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if err := writeVersion(ctx, tx, version); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		m.logger.Info("Marked existing database as migrated",
+			zap.Uint("version", version),
+			zap.String("table", table))
+		marked = true
+		return nil
+	})
+	return marked, err
+}
+
+// hasVersion 检查迁移文件中是否有该版本
+func (m *Migrator) hasVersion(version uint) bool {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// apply 在事务中执行迁移 SQL 并记录新的版本
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, query string, version uint) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	if err := writeVersion(ctx, tx, version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// withConn 在单个连接上执行 fn, lock 为 true 时持有 advisory lock 并确保 schema_migrations 存在
+//
+// advisory lock 属于连接, 因此加锁、迁移和解锁必须使用同一个连接。
+func (m *Migrator) withConn(ctx context.Context, lock bool, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !lock {
+		return fn(conn)
+	}
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			m.logger.Error("Failed to release migration lock", zap.Error(err))
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx,
+		"CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)"); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return fn(conn)
+}
+
+// readVersion 读取已执行的版本, schema_migrations 不存在时视为没有执行任何迁移
+func readVersion(ctx context.Context, conn *sql.Conn) (uint, bool, error) {
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil || !exists {
+		return 0, false, err
+	}
+
+	var version int64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
+
+// writeVersion 记录已执行的版本, 0 表示没有执行任何迁移
+func writeVersion(ctx context.Context, tx *sql.Tx, version uint) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+	_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, FALSE)", int64(version))
+	return err
+}
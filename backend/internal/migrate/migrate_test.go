@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000002_add_users.up.sql":     {Data: []byte("CREATE TABLE users (id INT);")},
+		"000002_add_users.down.sql":   {Data: []byte("DROP TABLE users;")},
+		"000001_initial.up.sql":       {Data: []byte("CREATE TABLE agents (id INT);")},
+		"000001_initial.down.sql":     {Data: []byte("DROP TABLE agents;")},
+		"000010_add_indexes.up.sql":   {Data: []byte("CREATE INDEX idx ON users(id);")},
+		"000010_add_indexes.down.sql": {Data: []byte("DROP INDEX idx;")},
+		"README.md":                   {Data: []byte("# 迁移")},
+	}
+
+	loaded, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, loaded, 3)
+	assert.Equal(t, uint(1), loaded[0].Version)
+	assert.Equal(t, "initial", loaded[0].Name)
+	assert.Equal(t, "CREATE TABLE agents (id INT);", loaded[0].up)
+	assert.Equal(t, "DROP TABLE agents;", loaded[0].down)
+	assert.Equal(t, uint(2), loaded[1].Version)
+	assert.Equal(t, uint(10), loaded[2].Version)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "missing down file",
+			fsys: fstest.MapFS{
+				"000001_initial.up.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "missing up file",
+			fsys: fstest.MapFS{
+				"000001_initial.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"000001_initial.up.sql":     {Data: []byte("SELECT 1;")},
+				"000001_initial.down.sql":   {Data: []byte("SELECT 1;")},
+				"000001_add_users.up.sql":   {Data: []byte("SELECT 1;")},
+				"000001_add_users.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "zero version",
+			fsys: fstest.MapFS{
+				"000000_initial.up.sql":   {Data: []byte("SELECT 1;")},
+				"000000_initial.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMigrator_Status(t *testing.T) {
+	m, err := New(nil, fstest.MapFS{
+		"000001_initial.up.sql":       {Data: []byte("SELECT 1;")},
+		"000001_initial.down.sql":     {Data: []byte("SELECT 1;")},
+		"000002_add_users.up.sql":     {Data: []byte("SELECT 1;")},
+		"000002_add_users.down.sql":   {Data: []byte("SELECT 1;")},
+		"000003_add_indexes.up.sql":   {Data: []byte("SELECT 1;")},
+		"000003_add_indexes.down.sql": {Data: []byte("SELECT 1;")},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint(3), m.Latest())
+
+	status := m.status(0, false)
+	assert.Len(t, status.Pending, 3)
+	assert.False(t, status.UpToDate())
+
+	status = m.status(2, false)
+	require.Len(t, status.Pending, 1)
+	assert.Equal(t, uint(3), status.Pending[0].Version)
+
+	assert.True(t, m.status(3, false).UpToDate())
+	assert.False(t, m.status(3, true).UpToDate())
+
+	// 数据库版本比迁移文件新时 (新版本服务已执行迁移) 没有待执行的迁移
+	assert.True(t, m.status(4, false).UpToDate())
+}
+
+func TestMigrator_BaselineUnknownVersion(t *testing.T) {
+	m, err := New(nil, fstest.MapFS{
+		"000001_initial.up.sql":   {Data: []byte("SELECT 1;")},
+		"000001_initial.down.sql": {Data: []byte("SELECT 1;")},
+	}, nil)
+	require.NoError(t, err)
+
+	// 未知版本在连接数据库前返回错误
+	_, err = m.Baseline(t.Context(), 2, "agents")
+	assert.Error(t, err)
+}
+
+// TestEmbeddedMigrations 检查嵌入的迁移文件都能加载
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+
+	for i, m := range loaded {
+		assert.Equal(t, uint(i+1), m.Version, "migration versions should be consecutive")
+	}
+}
@@ -1,6 +1,9 @@
 package model
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -38,6 +41,9 @@ type Agent struct {
 	Hostname     string    `json:"hostname" gorm:"index"`
 	Version      string    `json:"version"` // Agent 版本
 
+	// 所属组织, 由 Agent 连接时使用的 Secret Key 决定
+	OrganizationID uint `json:"organization_id" gorm:"not null;default:1;index"`
+
 	// 连接状态
 	Status              AgentStatus `json:"status" gorm:"type:varchar(20);default:offline;index"`
 	LastSeenAt          *time.Time  `json:"last_seen_at,omitempty" gorm:"index"`
@@ -45,21 +51,36 @@ type Agent struct {
 	LastDisconnectedAt  *time.Time  `json:"last_disconnected_at,omitempty"`
 	DisconnectReason    string      `json:"disconnect_reason,omitempty"`
 
+	// 抖动检测 (频繁重连或会话过短)
+	Flapping      bool       `json:"flapping" gorm:"default:false;index"`
+	FlappingSince *time.Time `json:"flapping_since,omitempty"`
+
 	// 兼容性字段 (将来可以移除)
 	ConnectedAt    *time.Time  `json:"connected_at,omitempty" gorm:"-"`
 	DisconnectedAt *time.Time  `json:"disconnected_at,omitempty" gorm:"-"`
 
-	// 标签 (用于配置匹配)
+	// 标签 (用于配置匹配), 由上报标签和服务端标签合并而成, 同名时服务端标签优先
 	Labels Labels `json:"labels" gorm:"serializer:json"`
 
+	// 上报标签来自 Agent 的 NonIdentifyingAttributes, 每次上报时整体替换;
+	// 服务端标签通过 API 设置, 不受 Agent 上报影响
+	ReportedLabels Labels `json:"reported_labels" gorm:"serializer:json"`
+	ServerLabels   Labels `json:"server_labels" gorm:"serializer:json"`
+
 	// 当前配置
-	ConfigurationName string `json:"configuration_name,omitempty"` // 关联的配置名称
+	ConfigurationName string `json:"configuration_name,omitempty"` // 指定使用的配置, 为空时按标签匹配; 只通过 API 修改
 
 	// OpAMP 协议相关
 	Protocol       string `json:"protocol"`        // 使用的协议: opamp
 	OpAMPState     []byte `json:"-" gorm:"type:bytea"` // OpAMP 状态 (序列化的 protobuf)
 	SequenceNumber uint64 `json:"sequence_number"` // OpAMP 消息序列号
 
+	// Agent 上报的 OpAMP 能力位 (AgentCapabilities)
+	Capabilities uint64 `json:"capabilities" gorm:"not null;default:0"`
+
+	// 退役时间, 退役的 Agent 保留记录但不再允许连接
+	DecommissionedAt *time.Time `json:"decommissioned_at,omitempty" gorm:"index"`
+
 	// 元数据
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
@@ -70,6 +91,16 @@ func (Agent) TableName() string {
 	return "agents"
 }
 
+// IsDecommissioned 检查 Agent 是否已退役
+func (a *Agent) IsDecommissioned() bool {
+	return a.DecommissionedAt != nil
+}
+
+// RefreshLabels 根据上报标签和服务端标签重新计算生效的标签
+func (a *Agent) RefreshLabels() {
+	a.Labels = a.ReportedLabels.Merge(a.ServerLabels)
+}
+
 // Labels 表示 Agent 的标签集合
 type Labels map[string]string
 
@@ -89,7 +120,7 @@ func (l Labels) Matches(selector map[string]string) bool {
 	return true
 }
 
-// Merge 合并标签
+// Merge 合并标签, 同名时 other 中的值优先
 func (l Labels) Merge(other Labels) Labels {
 	result := make(Labels, len(l)+len(other))
 	for k, v := range l {
@@ -101,8 +132,182 @@ func (l Labels) Merge(other Labels) Labels {
 	return result
 }
 
+// Apply 返回设置 set 中的标签并删除 remove 中的标签后的新标签集合, 不修改原集合
+func (l Labels) Apply(set Labels, remove []string) Labels {
+	result := l.Merge(set)
+	for _, key := range remove {
+		delete(result, key)
+	}
+	return result
+}
+
+// ValidateLabels 检查标签能否用于标签选择器: key 不能为空, key 和 value 不能包含 "," 或 "="
+func ValidateLabels(labels Labels) error {
+	for k, v := range labels {
+		if strings.TrimSpace(k) == "" {
+			return fmt.Errorf("label key must not be empty")
+		}
+		if strings.ContainsAny(k, ",=") || strings.ContainsAny(v, ",=") {
+			return fmt.Errorf("label %q must not contain ',' or '='", k)
+		}
+	}
+	return nil
+}
+
+// ParseLabelSelector 解析 "key1=value1,key2=value2" 格式的标签选择器
+func ParseLabelSelector(s string) (map[string]string, error) {
+	selector := make(map[string]string)
+	if strings.TrimSpace(s) == "" {
+		return selector, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid label selector: %q", part)
+		}
+		selector[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return selector, nil
+}
+
+// FormatLabelSelector 将标签选择器格式化为 "key1=value1,key2=value2" (按 key 排序)
+func FormatLabelSelector(selector map[string]string) string {
+	keys := make([]string, 0, len(selector))
+	for k := range selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+selector[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// AgentLabelsRequest 整体替换 Agent 服务端标签的请求
+type AgentLabelsRequest struct {
+	Labels Labels `json:"labels"`
+}
+
+// AgentLabelsPatch 修改 Agent 服务端标签的请求, 先设置 set 中的标签再删除 remove 中的标签
+type AgentLabelsPatch struct {
+	Set    Labels   `json:"set"`
+	Remove []string `json:"remove"`
+}
+
+// PinConfigurationRequest 指定 Agent 使用的配置的请求
+type PinConfigurationRequest struct {
+	ConfigurationName string `json:"configuration_name" binding:"required"`
+}
+
+// DecommissionRequest 退役 Agent 的请求
+type DecommissionRequest struct {
+	// FinalConfig 断开连接前向 Agent 推送停用所有数据管道的配置
+	FinalConfig bool `json:"final_config"`
+	// PurgeHistory 删除 Agent 的连接历史和配置应用历史, 否则保留用于审计
+	PurgeHistory bool `json:"purge_history"`
+}
+
+// decommissionConfig 退役时推送的配置, 只保留一条 nop 管道使采集器可以启动但不处理任何数据
+const decommissionConfig = `receivers:
+  nop:
+exporters:
+  nop:
+service:
+  pipelines:
+    traces:
+      receivers: [nop]
+      exporters: [nop]
+`
+
+// DecommissionConfiguration 返回退役时推送给 Agent 的配置
+func DecommissionConfiguration() *Configuration {
+	config := &Configuration{
+		Name:      "decommissioned",
+		RawConfig: decommissionConfig,
+	}
+	config.UpdateHash()
+	return config
+}
+
+// BulkAgentLabelsRequest 按选择器批量修改 Agent 服务端标签的请求
+type BulkAgentLabelsRequest struct {
+	Selector map[string]string `json:"selector" binding:"required"`
+	AgentLabelsPatch
+}
+
 // AgentUpdate 表示 Agent 需要接收的更新
+//
+// 标签保存在服务端 (见 Agent.ServerLabels), 不下发给 Agent。
 type AgentUpdate struct {
-	Labels        *Labels        `json:"labels,omitempty"`
 	Configuration *Configuration `json:"configuration,omitempty"`
+	// Restart 要求 Agent 重启, Agent 需具备 AcceptsRestartCommand 能力
+	Restart bool `json:"restart,omitempty"`
+	// ReportFullState 要求 Agent 重新上报完整状态, 服务端据此重新核对配置
+	ReportFullState bool `json:"report_full_state,omitempty"`
+}
+
+// AgentFilter Agent 查询条件, 空值表示不过滤
+type AgentFilter struct {
+	// IDs 限定在这些 Agent 中查询
+	IDs      []string
+	Statuses []AgentStatus
+	Selector map[string]string
+	Version  string
+	// Search 按名称或主机名的子串匹配, 不区分大小写
+	Search            string
+	OS                string
+	Arch              string
+	ConfigurationName string
+	LastSeenAfter     *time.Time
+	LastSeenBefore    *time.Time
+	// Capabilities Agent 必须具备的全部能力位
+	Capabilities uint64
+	// Decommissioned 为 true 时只查询已退役的 Agent, 为 false 时只查询未退役的 Agent
+	Decommissioned *bool
+}
+
+// WithoutDecommissioned 返回默认排除已退役 Agent 的查询条件, 已经指定 Decommissioned 时保持不变
+func (f AgentFilter) WithoutDecommissioned() AgentFilter {
+	if f.Decommissioned == nil {
+		decommissioned := false
+		f.Decommissioned = &decommissioned
+	}
+	return f
+}
+
+// AgentSort Agent 列表的排序字段
+type AgentSort string
+
+const (
+	AgentSortUpdatedAt  AgentSort = "updated_at"
+	AgentSortCreatedAt  AgentSort = "created_at"
+	AgentSortLastSeenAt AgentSort = "last_seen_at"
+	AgentSortName       AgentSort = "name"
+	AgentSortHostname   AgentSort = "hostname"
+	AgentSortVersion    AgentSort = "version"
+)
+
+// IsValid 检查排序字段是否有效
+func (s AgentSort) IsValid() bool {
+	switch s {
+	case AgentSortUpdatedAt, AgentSortCreatedAt, AgentSortLastSeenAt, AgentSortName, AgentSortHostname, AgentSortVersion:
+		return true
+	default:
+		return false
+	}
+}
+
+// AgentListOptions Agent 列表的排序和分页
+//
+// 指定 Cursor 时按游标 (keyset) 分页并忽略 Offset; 同一排序值的 Agent 按 ID 排序。
+type AgentListOptions struct {
+	Sort       AgentSort
+	Descending bool
+	Limit      int
+	Offset     int
+	Cursor     string
 }
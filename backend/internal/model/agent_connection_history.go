@@ -9,6 +9,9 @@ type AgentConnectionHistory struct {
 	ID        uint   `json:"id" gorm:"primaryKey"`
 	AgentID   string `json:"agent_id" gorm:"type:varchar(255);not null;index"`
 
+	// 所属组织
+	OrganizationID uint `json:"organization_id" gorm:"not null;default:1;index"`
+
 	// 连接信息
 	ConnectedAt     time.Time  `json:"connected_at" gorm:"not null;index"`
 	DisconnectedAt  *time.Time `json:"disconnected_at,omitempty" gorm:"index"`
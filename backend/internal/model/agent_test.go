@@ -236,3 +236,149 @@ func TestAgent_ConfigurationName(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "empty string",
+			input: "",
+			want:  map[string]string{},
+		},
+		{
+			name:  "single label",
+			input: "env=prod",
+			want:  map[string]string{"env": "prod"},
+		},
+		{
+			name:  "multiple labels with spaces",
+			input: "env=prod, region = us-east",
+			want:  map[string]string{"env": "prod", "region": "us-east"},
+		},
+		{
+			name:  "empty value",
+			input: "env=",
+			want:  map[string]string{"env": ""},
+		},
+		{
+			name:    "missing equals",
+			input:   "env",
+			wantErr: true,
+		},
+		{
+			name:    "missing key",
+			input:   "=prod",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLabelSelector(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLabelSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseLabelSelector() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("ParseLabelSelector()[%s] = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatLabelSelector(t *testing.T) {
+	got := FormatLabelSelector(map[string]string{"region": "us-east", "env": "prod"})
+	if got != "env=prod,region=us-east" {
+		t.Errorf("FormatLabelSelector() = %v, want env=prod,region=us-east", got)
+	}
+
+	if FormatLabelSelector(nil) != "" {
+		t.Error("FormatLabelSelector(nil) should be empty")
+	}
+}
+
+func TestAgent_RefreshLabels(t *testing.T) {
+	agent := &Agent{
+		ReportedLabels: Labels{"env": "dev", "os.type": "linux"},
+		ServerLabels:   Labels{"env": "prod", "team": "payments"},
+	}
+	agent.RefreshLabels()
+
+	want := Labels{"env": "prod", "os.type": "linux", "team": "payments"}
+	if len(agent.Labels) != len(want) {
+		t.Fatalf("Labels = %v, want %v", agent.Labels, want)
+	}
+	for k, v := range want {
+		if agent.Labels[k] != v {
+			t.Errorf("Labels[%s] = %v, want %v", k, agent.Labels[k], v)
+		}
+	}
+
+	// 删除服务端标签后恢复为上报的值
+	agent.ServerLabels = agent.ServerLabels.Apply(nil, []string{"env"})
+	agent.RefreshLabels()
+	if agent.Labels["env"] != "dev" {
+		t.Errorf("Labels[env] = %v, want dev", agent.Labels["env"])
+	}
+}
+
+func TestLabels_Apply(t *testing.T) {
+	original := Labels{"env": "dev", "team": "payments"}
+	got := original.Apply(Labels{"env": "prod", "region": "eu"}, []string{"team", "missing"})
+
+	if len(got) != 2 || got["env"] != "prod" || got["region"] != "eu" {
+		t.Errorf("Apply() = %v, want env=prod,region=eu", got)
+	}
+	if original["env"] != "dev" || original["team"] != "payments" {
+		t.Errorf("Apply() modified the original labels: %v", original)
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	if err := ValidateLabels(Labels{"env": "prod", "team": ""}); err != nil {
+		t.Errorf("ValidateLabels() unexpected error: %v", err)
+	}
+	for _, labels := range []Labels{{"": "x"}, {"a=b": "x"}, {"env": "a,b"}} {
+		if err := ValidateLabels(labels); err == nil {
+			t.Errorf("ValidateLabels(%v) should fail", labels)
+		}
+	}
+}
+
+func TestAgent_IsDecommissioned(t *testing.T) {
+	agent := &Agent{ID: "test-agent"}
+	if agent.IsDecommissioned() {
+		t.Error("new agent should not be decommissioned")
+	}
+
+	now := time.Now()
+	agent.DecommissionedAt = &now
+	if !agent.IsDecommissioned() {
+		t.Error("agent with DecommissionedAt should be decommissioned")
+	}
+}
+
+func TestDecommissionConfiguration(t *testing.T) {
+	config := DecommissionConfiguration()
+
+	if config.Name != "decommissioned" {
+		t.Errorf("Name = %q, want %q", config.Name, "decommissioned")
+	}
+	if config.ConfigHash == "" {
+		t.Error("ConfigHash should be set")
+	}
+	if DecommissionConfiguration().ConfigHash != config.ConfigHash {
+		t.Error("ConfigHash should be stable")
+	}
+}
@@ -0,0 +1,207 @@
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// AlertRuleType 告警规则类型
+type AlertRuleType string
+
+const (
+	// AlertRuleOfflineRatio 匹配选择器的 Agent 中离线比例超过阈值 (百分比)
+	AlertRuleOfflineRatio AlertRuleType = "offline_ratio"
+	// AlertRuleFlapping 单个 Agent 在窗口内的重连次数超过阈值
+	AlertRuleFlapping AlertRuleType = "flapping"
+	// AlertRuleConfigFailed 配置在窗口内应用失败的 Agent 数超过阈值
+	AlertRuleConfigFailed AlertRuleType = "config_failed"
+)
+
+// IsValid 检查规则类型是否有效
+func (t AlertRuleType) IsValid() bool {
+	switch t {
+	case AlertRuleOfflineRatio, AlertRuleFlapping, AlertRuleConfigFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// AlertSeverity 告警级别
+type AlertSeverity string
+
+const (
+	SeverityInfo     AlertSeverity = "info"
+	SeverityWarning  AlertSeverity = "warning"
+	SeverityCritical AlertSeverity = "critical"
+)
+
+// IsValid 检查告警级别是否有效
+func (s AlertSeverity) IsValid() bool {
+	switch s {
+	case SeverityInfo, SeverityWarning, SeverityCritical:
+		return true
+	default:
+		return false
+	}
+}
+
+// AlertRule 表示一条告警规则
+type AlertRule struct {
+	ID                uint              `json:"id" gorm:"primaryKey"`
+	OrganizationID    uint              `json:"organization_id" gorm:"uniqueIndex:idx_alert_rules_org_name;not null;default:1"`
+	Name              string            `json:"name" gorm:"uniqueIndex:idx_alert_rules_org_name;not null"` // 组织内唯一
+	Description       string            `json:"description" gorm:"type:text"`
+	Type              AlertRuleType     `json:"type" gorm:"type:varchar(50);not null"`
+	Selector          map[string]string `json:"selector" gorm:"serializer:json"` // 标签选择器, 限定参与评估的 Agent
+	AgentID           string            `json:"agent_id,omitempty"`              // 仅评估指定 Agent (flapping)
+	ConfigurationName string            `json:"configuration_name,omitempty"`    // 仅评估指定配置 (config_failed)
+	Threshold         float64           `json:"threshold"`                       // 触发阈值, 含义取决于规则类型
+	WindowSeconds     int               `json:"window_seconds"`                  // 统计窗口
+	ForSeconds        int               `json:"for_seconds"`                     // 条件持续多久后由 pending 转为 firing
+	Severity          AlertSeverity     `json:"severity" gorm:"type:varchar(20);default:warning"`
+	Enabled           bool              `json:"enabled"`
+	CreatedAt         time.Time         `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time         `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (AlertRule) TableName() string {
+	return "alert_rules"
+}
+
+// Window 返回统计窗口
+func (r *AlertRule) Window() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+// For 返回条件需要持续的时间
+func (r *AlertRule) For() time.Duration {
+	return time.Duration(r.ForSeconds) * time.Second
+}
+
+// Validate 校验规则参数
+func (r *AlertRule) Validate() error {
+	if !r.Type.IsValid() {
+		return fmt.Errorf("invalid rule type: %s", r.Type)
+	}
+	if r.Severity != "" && !r.Severity.IsValid() {
+		return fmt.Errorf("invalid severity: %s", r.Severity)
+	}
+	if r.Threshold < 0 {
+		return fmt.Errorf("threshold must not be negative")
+	}
+	if r.WindowSeconds < 0 || r.ForSeconds < 0 {
+		return fmt.Errorf("window_seconds and for_seconds must not be negative")
+	}
+
+	switch r.Type {
+	case AlertRuleOfflineRatio:
+		if r.Threshold > 100 {
+			return fmt.Errorf("offline_ratio threshold is a percentage and must be <= 100")
+		}
+	case AlertRuleFlapping, AlertRuleConfigFailed:
+		if r.WindowSeconds == 0 {
+			return fmt.Errorf("%s rules require window_seconds", r.Type)
+		}
+	}
+	return nil
+}
+
+// AlertRuleRequest 创建或更新告警规则的请求
+type AlertRuleRequest struct {
+	Name              string            `json:"name" binding:"required,max=255"`
+	Description       string            `json:"description"`
+	Type              AlertRuleType     `json:"type" binding:"required"`
+	Selector          map[string]string `json:"selector"`
+	AgentID           string            `json:"agent_id"`
+	ConfigurationName string            `json:"configuration_name"`
+	Threshold         float64           `json:"threshold"`
+	WindowSeconds     int               `json:"window_seconds"`
+	ForSeconds        int               `json:"for_seconds"`
+	Severity          AlertSeverity     `json:"severity"`
+	Enabled           *bool             `json:"enabled"` // 为空时默认启用
+}
+
+// AlertState 告警状态
+type AlertState string
+
+const (
+	AlertStatePending  AlertState = "pending"  // 条件成立, 等待持续时间
+	AlertStateFiring   AlertState = "firing"   // 已触发
+	AlertStateResolved AlertState = "resolved" // 已恢复
+)
+
+// Alert 表示一条告警实例
+//
+// 同一规则针对不同目标 (例如不同 Agent) 会产生不同的告警, 用 Fingerprint 区分。
+type Alert struct {
+	ID              uint          `json:"id" gorm:"primaryKey"`
+	OrganizationID  uint          `json:"organization_id" gorm:"not null;default:1;index"`
+	RuleID          uint          `json:"rule_id" gorm:"index;not null"`
+	RuleName        string        `json:"rule_name"`
+	Fingerprint     string        `json:"fingerprint" gorm:"index;not null"`
+	State           AlertState    `json:"state" gorm:"type:varchar(20);index;not null"`
+	Severity        AlertSeverity `json:"severity" gorm:"type:varchar(20)"`
+	Summary         string        `json:"summary" gorm:"type:text"`
+	AgentID         string        `json:"agent_id,omitempty" gorm:"index"`
+	Labels          Labels        `json:"labels" gorm:"serializer:json"`
+	Value           float64       `json:"value"`
+	Silenced        bool          `json:"silenced"`
+	StartedAt       time.Time     `json:"started_at"`
+	FiredAt         *time.Time    `json:"fired_at,omitempty"`
+	ResolvedAt      *time.Time    `json:"resolved_at,omitempty"`
+	LastEvaluatedAt time.Time     `json:"last_evaluated_at"`
+	CreatedAt       time.Time     `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time     `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (Alert) TableName() string {
+	return "alerts"
+}
+
+// Silence 表示一条告警静默
+//
+// 在 [StartsAt, EndsAt) 期间, 匹配的告警仍会正常评估状态, 但不会发送通知。
+type Silence struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	OrganizationID uint              `json:"organization_id" gorm:"not null;default:1;index"`
+	RuleID         *uint             `json:"rule_id,omitempty" gorm:"index"`  // 为空则不限规则
+	Matchers       map[string]string `json:"matchers" gorm:"serializer:json"` // 匹配告警标签
+	Comment        string            `json:"comment" gorm:"type:text"`
+	CreatedBy      string            `json:"created_by"`
+	StartsAt       time.Time         `json:"starts_at" gorm:"not null"`
+	EndsAt         time.Time         `json:"ends_at" gorm:"not null;index"`
+	CreatedAt      time.Time         `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (Silence) TableName() string {
+	return "alert_silences"
+}
+
+// IsActive 检查静默在指定时间是否生效
+func (s *Silence) IsActive(now time.Time) bool {
+	return !now.Before(s.StartsAt) && now.Before(s.EndsAt)
+}
+
+// Matches 检查静默是否覆盖指定告警
+func (s *Silence) Matches(alert *Alert) bool {
+	if s.OrganizationID != alert.OrganizationID {
+		return false
+	}
+	if s.RuleID != nil && *s.RuleID != alert.RuleID {
+		return false
+	}
+	return alert.Labels.Matches(s.Matchers)
+}
+
+// SilenceRequest 创建静默的请求
+type SilenceRequest struct {
+	RuleID   *uint             `json:"rule_id"`
+	Matchers map[string]string `json:"matchers"`
+	Comment  string            `json:"comment"`
+	StartsAt *time.Time        `json:"starts_at"` // 为空时立即生效
+	EndsAt   time.Time         `json:"ends_at" binding:"required"`
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlertRule_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    AlertRule
+		wantErr bool
+	}{
+		{"valid offline ratio", AlertRule{Type: AlertRuleOfflineRatio, Threshold: 5}, false},
+		{"offline ratio above 100", AlertRule{Type: AlertRuleOfflineRatio, Threshold: 120}, true},
+		{"valid flapping", AlertRule{Type: AlertRuleFlapping, Threshold: 3, WindowSeconds: 900}, false},
+		{"flapping without window", AlertRule{Type: AlertRuleFlapping, Threshold: 3}, true},
+		{"config failed without window", AlertRule{Type: AlertRuleConfigFailed}, true},
+		{"unknown type", AlertRule{Type: "cpu"}, true},
+		{"invalid severity", AlertRule{Type: AlertRuleOfflineRatio, Severity: "page"}, true},
+		{"negative threshold", AlertRule{Type: AlertRuleOfflineRatio, Threshold: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSilence_IsActiveAndMatches(t *testing.T) {
+	now := time.Now()
+	ruleID := uint(1)
+	otherRule := uint(2)
+
+	silence := Silence{
+		RuleID:   &ruleID,
+		Matchers: map[string]string{"env": "prod"},
+		StartsAt: now.Add(-time.Minute),
+		EndsAt:   now.Add(time.Hour),
+	}
+
+	assert.True(t, silence.IsActive(now))
+	assert.False(t, silence.IsActive(now.Add(-2*time.Minute)))
+	assert.False(t, silence.IsActive(now.Add(time.Hour)))
+
+	assert.True(t, silence.Matches(&Alert{RuleID: 1, Labels: Labels{"env": "prod", "region": "eu"}}))
+	assert.False(t, silence.Matches(&Alert{RuleID: 1, Labels: Labels{"env": "dev"}}))
+	assert.False(t, silence.Matches(&Alert{RuleID: otherRule, Labels: Labels{"env": "prod"}}))
+}
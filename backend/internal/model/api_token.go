@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// APIToken 表示一个长期有效的 API 令牌
+//
+// 令牌明文只在创建时返回一次, 数据库中只保存其哈希和用于识别的前缀。
+// Scopes 限制令牌可使用的权限, 实际权限为令牌 Scopes 与所属用户角色权限的交集。
+type APIToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix" gorm:"not null"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Scopes     []string   `json:"scopes" gorm:"serializer:json"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// IsUsable 检查令牌是否仍可使用 (未撤销且未过期)
+func (t *APIToken) IsUsable(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
+
+// APITokenRequest 创建 API 令牌的请求
+type APITokenRequest struct {
+	Name      string   `json:"name" binding:"required,min=1,max=64"`
+	Scopes    []string `json:"scopes" binding:"required"`
+	ExpiresIn string   `json:"expires_in"` // Go duration, 例如 "2160h", 为空表示永不过期
+}
@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// 审计日志的操作者类型
+const (
+	AuditActorUser      = "user"      // 通过 JWT 登录的用户
+	AuditActorAPIToken  = "api_token" // 通过 API 令牌调用
+	AuditActorSystem    = "system"    // 服务端自身 (如 OpAMP 服务器)
+	AuditActorAnonymous = "anonymous" // 未认证的请求 (如登录、注册)
+)
+
+// AuditLog 表示一条审计日志, 只追加不修改
+type AuditLog struct {
+	ID             uint   `json:"id" gorm:"primaryKey"`
+	OrganizationID uint   `json:"organization_id" gorm:"not null;default:1;index"`
+	ActorType      string `json:"actor_type" gorm:"not null;index"`
+	ActorID        *uint  `json:"actor_id,omitempty" gorm:"index"`
+	Actor          string `json:"actor" gorm:"index"`
+	APITokenID     *uint  `json:"api_token_id,omitempty"`
+	// Action 操作名称, 如 configurations.update, users.mfa.reset
+	Action     string `json:"action" gorm:"not null;index"`
+	TargetType string `json:"target_type,omitempty" gorm:"index"`
+	TargetID   string `json:"target_id,omitempty" gorm:"index"`
+	// Method 和 Path 只在 API 调用产生的日志中设置
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Status HTTP 响应状态码, 失败的调用同样会记录
+	Status    int                    `json:"status,omitempty"`
+	Changes   map[string]AuditChange `json:"changes,omitempty" gorm:"serializer:json"`
+	Details   map[string]interface{} `json:"details,omitempty" gorm:"serializer:json"`
+	SourceIP  string                 `json:"source_ip,omitempty"`
+	RequestID string                 `json:"request_id,omitempty" gorm:"index"`
+	CreatedAt time.Time              `json:"created_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// AuditChange 表示一个字段修改前后的值
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditFilter 审计日志查询条件, 空值表示不过滤
+type AuditFilter struct {
+	ActorType string
+	Actor     string
+	// Action 精确匹配, 以 ".*" 结尾时按前缀匹配 (如 users.*)
+	Action     string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+}
@@ -0,0 +1,262 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+// AvailabilityReport 表示一段时间内的可用性统计
+//
+// 单个 Agent 的报告与按标签分组的汇总报告共用该结构。
+type AvailabilityReport struct {
+	AgentID              string              `json:"agent_id,omitempty"`
+	Group                string              `json:"group,omitempty"`
+	Agents               int                 `json:"agents"`
+	From                 time.Time           `json:"from"`
+	To                   time.Time           `json:"to"`
+	ObservedSeconds      int64               `json:"observed_seconds"`
+	UptimeSeconds        int64               `json:"uptime_seconds"`
+	DowntimeSeconds      int64               `json:"downtime_seconds"`
+	UptimePercent        float64             `json:"uptime_percent"`
+	Outages              int                 `json:"outages"`
+	RecoveredOutages     int                 `json:"recovered_outages"`
+	MTTRSeconds          float64             `json:"mttr_seconds"`
+	LongestOutageSeconds int64               `json:"longest_outage_seconds"`
+	Daily                []DailyAvailability `json:"daily"`
+
+	recoverySeconds int64 // 已恢复故障的总时长, 用于汇总时重新计算 MTTR
+}
+
+// DailyAvailability 表示按天 (UTC) 划分的可用性
+type DailyAvailability struct {
+	Date            string  `json:"date"`
+	ObservedSeconds int64   `json:"observed_seconds"`
+	UptimeSeconds   int64   `json:"uptime_seconds"`
+	UptimePercent   float64 `json:"uptime_percent"`
+	Outages         int     `json:"outages"`
+}
+
+// interval 表示一个时间区间 [start, end)
+type interval struct {
+	start time.Time
+	end   time.Time
+}
+
+// ComputeAvailability 根据连接历史计算 [from, to) 内的可用性
+//
+// 会话结束时间优先使用 DisconnectedAt, 其次使用 ConnectedAt + DurationSeconds,
+// 两者都没有时视为仍在线的会话, 结束于 now。窗口内不在线的时段均计为故障,
+// 仍未恢复的故障计入故障次数和最长故障, 但不计入 MTTR。
+func ComputeAvailability(agentID string, histories []*AgentConnectionHistory, from, to, now time.Time) *AvailabilityReport {
+	report := &AvailabilityReport{
+		AgentID: agentID,
+		Agents:  1,
+		From:    from,
+		To:      to,
+	}
+	if !to.After(from) {
+		return report
+	}
+
+	// 计算在线区间并裁剪到窗口内
+	var up []interval
+	for _, h := range histories {
+		end := sessionEnd(h, now)
+		start := h.ConnectedAt
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			up = append(up, interval{start: start, end: end})
+		}
+	}
+	up = mergeIntervals(up)
+
+	// 在线区间之间的空隙即为故障
+	var outages []interval
+	cursor := from
+	for _, iv := range up {
+		if iv.start.After(cursor) {
+			outages = append(outages, interval{start: cursor, end: iv.start})
+		}
+		cursor = iv.end
+	}
+	if to.After(cursor) {
+		outages = append(outages, interval{start: cursor, end: to})
+	}
+
+	report.ObservedSeconds = seconds(to.Sub(from))
+	for _, iv := range up {
+		report.UptimeSeconds += seconds(iv.end.Sub(iv.start))
+	}
+	report.DowntimeSeconds = report.ObservedSeconds - report.UptimeSeconds
+
+	report.Outages = len(outages)
+	for _, o := range outages {
+		duration := seconds(o.end.Sub(o.start))
+		if duration > report.LongestOutageSeconds {
+			report.LongestOutageSeconds = duration
+		}
+		// 在窗口结束前恢复的故障
+		if o.end.Before(to) {
+			report.RecoveredOutages++
+			report.recoverySeconds += duration
+		}
+	}
+
+	report.Daily = dailyBuckets(up, outages, from, to)
+	report.finalize()
+	return report
+}
+
+// MergeAvailability 将多个 Agent 的报告汇总为一个分组报告
+func MergeAvailability(group string, from, to time.Time, reports []*AvailabilityReport) *AvailabilityReport {
+	merged := &AvailabilityReport{
+		Group:  group,
+		Agents: len(reports),
+		From:   from,
+		To:     to,
+	}
+
+	daily := make(map[string]*DailyAvailability)
+	for _, r := range reports {
+		merged.ObservedSeconds += r.ObservedSeconds
+		merged.UptimeSeconds += r.UptimeSeconds
+		merged.DowntimeSeconds += r.DowntimeSeconds
+		merged.Outages += r.Outages
+		merged.RecoveredOutages += r.RecoveredOutages
+		merged.recoverySeconds += r.recoverySeconds
+		if r.LongestOutageSeconds > merged.LongestOutageSeconds {
+			merged.LongestOutageSeconds = r.LongestOutageSeconds
+		}
+
+		for _, d := range r.Daily {
+			bucket, ok := daily[d.Date]
+			if !ok {
+				bucket = &DailyAvailability{Date: d.Date}
+				daily[d.Date] = bucket
+			}
+			bucket.ObservedSeconds += d.ObservedSeconds
+			bucket.UptimeSeconds += d.UptimeSeconds
+			bucket.Outages += d.Outages
+		}
+	}
+
+	merged.Daily = make([]DailyAvailability, 0, len(daily))
+	for _, d := range daily {
+		d.UptimePercent = percent(d.UptimeSeconds, d.ObservedSeconds)
+		merged.Daily = append(merged.Daily, *d)
+	}
+	sort.Slice(merged.Daily, func(i, j int) bool {
+		return merged.Daily[i].Date < merged.Daily[j].Date
+	})
+
+	merged.finalize()
+	return merged
+}
+
+// finalize 计算百分比和 MTTR
+func (r *AvailabilityReport) finalize() {
+	r.UptimePercent = percent(r.UptimeSeconds, r.ObservedSeconds)
+	if r.RecoveredOutages > 0 {
+		r.MTTRSeconds = float64(r.recoverySeconds) / float64(r.RecoveredOutages)
+	}
+}
+
+// sessionEnd 返回会话的结束时间
+func sessionEnd(h *AgentConnectionHistory, now time.Time) time.Time {
+	if h.DisconnectedAt != nil {
+		return *h.DisconnectedAt
+	}
+	if h.DurationSeconds != nil {
+		return h.ConnectedAt.Add(time.Duration(*h.DurationSeconds) * time.Second)
+	}
+	return now
+}
+
+// mergeIntervals 合并重叠的区间 (例如重连时旧会话尚未关闭)
+func mergeIntervals(intervals []interval) []interval {
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].start.Before(intervals[j].start)
+	})
+
+	merged := []interval{intervals[0]}
+	for _, iv := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if !iv.start.After(last.end) {
+			if iv.end.After(last.end) {
+				last.end = iv.end
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// dailyBuckets 按 UTC 日期划分在线时长和故障次数 (故障计入其开始的那一天)
+func dailyBuckets(up, outages []interval, from, to time.Time) []DailyAvailability {
+	var buckets []DailyAvailability
+
+	fromUTC := from.UTC()
+	day := time.Date(fromUTC.Year(), fromUTC.Month(), fromUTC.Day(), 0, 0, 0, 0, time.UTC)
+	for day.Before(to) {
+		next := day.AddDate(0, 0, 1)
+
+		start, end := day, next
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+
+		bucket := DailyAvailability{
+			Date:            day.Format("2006-01-02"),
+			ObservedSeconds: seconds(end.Sub(start)),
+		}
+		for _, iv := range up {
+			s, e := iv.start, iv.end
+			if s.Before(start) {
+				s = start
+			}
+			if e.After(end) {
+				e = end
+			}
+			if e.After(s) {
+				bucket.UptimeSeconds += seconds(e.Sub(s))
+			}
+		}
+		for _, o := range outages {
+			if !o.start.Before(start) && o.start.Before(end) {
+				bucket.Outages++
+			}
+		}
+		bucket.UptimePercent = percent(bucket.UptimeSeconds, bucket.ObservedSeconds)
+
+		buckets = append(buckets, bucket)
+		day = next
+	}
+
+	return buckets
+}
+
+// seconds 将时长转换为整秒
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+// percent 计算百分比, 保留两位小数
+func percent(part, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(int64(float64(part)/float64(total)*10000+0.5)) / 100
+}
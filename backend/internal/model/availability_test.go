@@ -0,0 +1,107 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func closedSession(connectedAt time.Time, duration time.Duration) *AgentConnectionHistory {
+	disconnectedAt := connectedAt.Add(duration)
+	return &AgentConnectionHistory{ConnectedAt: connectedAt, DisconnectedAt: &disconnectedAt}
+}
+
+func TestComputeAvailability(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(48 * time.Hour)
+
+	duration := int(2 * time.Hour / time.Second)
+	histories := []*AgentConnectionHistory{
+		// 窗口开始前建立的会话, 裁剪到窗口内: 00:00 - 10:00
+		closedSession(from.Add(-2*time.Hour), 12*time.Hour),
+		// 故障 1: 10:00 - 11:00 (1h)
+		closedSession(from.Add(11*time.Hour), 13*time.Hour),
+		// 故障 2: 24:00 - 26:00 (2h), 只有 DurationSeconds 的会话: 26:00 - 28:00
+		{ConnectedAt: from.Add(26 * time.Hour), DurationSeconds: &duration},
+		// 故障 3: 28:00 - 30:00 (2h), 仍在线的会话, 结束于 now
+		{ConnectedAt: from.Add(30 * time.Hour)},
+	}
+	now := from.Add(40 * time.Hour)
+	// 故障 4: 40:00 - 48:00 (8h), 窗口结束时仍未恢复
+
+	report := ComputeAvailability("agent-1", histories, from, to, now)
+
+	assert.Equal(t, "agent-1", report.AgentID)
+	assert.Equal(t, int64(48*3600), report.ObservedSeconds)
+	assert.Equal(t, int64((10+13+2+10)*3600), report.UptimeSeconds)
+	assert.Equal(t, int64(13*3600), report.DowntimeSeconds)
+	assert.Equal(t, 72.92, report.UptimePercent)
+	assert.Equal(t, 4, report.Outages)
+	assert.Equal(t, 3, report.RecoveredOutages)
+	assert.InDelta(t, float64(5*3600)/3, report.MTTRSeconds, 0.01)
+	assert.Equal(t, int64(8*3600), report.LongestOutageSeconds)
+
+	require.Len(t, report.Daily, 2)
+	assert.Equal(t, "2024-01-01", report.Daily[0].Date)
+	assert.Equal(t, int64(23*3600), report.Daily[0].UptimeSeconds)
+	assert.Equal(t, 1, report.Daily[0].Outages)
+	assert.Equal(t, "2024-01-02", report.Daily[1].Date)
+	assert.Equal(t, int64(12*3600), report.Daily[1].UptimeSeconds)
+	assert.Equal(t, 3, report.Daily[1].Outages)
+}
+
+func TestComputeAvailability_OverlappingSessions(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+
+	// 重连时旧会话尚未关闭, 重叠部分只计一次
+	histories := []*AgentConnectionHistory{
+		closedSession(from, 6*time.Hour),
+		closedSession(from.Add(5*time.Hour), 5*time.Hour),
+	}
+
+	report := ComputeAvailability("agent-1", histories, from, to, to)
+	assert.Equal(t, int64(10*3600), report.UptimeSeconds)
+	assert.Equal(t, 100.0, report.UptimePercent)
+	assert.Equal(t, 0, report.Outages)
+	assert.Zero(t, report.MTTRSeconds)
+}
+
+func TestComputeAvailability_NoHistory(t *testing.T) {
+	from := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	report := ComputeAvailability("agent-1", nil, from, to, to)
+	assert.Equal(t, 0.0, report.UptimePercent)
+	assert.Equal(t, 1, report.Outages)
+	assert.Equal(t, 0, report.RecoveredOutages)
+	assert.Equal(t, int64(24*3600), report.LongestOutageSeconds)
+
+	// 窗口跨越两个自然日, 每天按实际观测时长计算
+	require.Len(t, report.Daily, 2)
+	assert.Equal(t, int64(12*3600), report.Daily[0].ObservedSeconds)
+	assert.Equal(t, int64(12*3600), report.Daily[1].ObservedSeconds)
+}
+
+func TestMergeAvailability(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	// a1: 全天在线; a2: 前 6 小时故障后恢复
+	a1 := ComputeAvailability("a1", []*AgentConnectionHistory{{ConnectedAt: from}}, from, to, to)
+	a2 := ComputeAvailability("a2", []*AgentConnectionHistory{{ConnectedAt: from.Add(6 * time.Hour)}}, from, to, to)
+
+	merged := MergeAvailability("prod", from, to, []*AvailabilityReport{a1, a2})
+	assert.Equal(t, "prod", merged.Group)
+	assert.Equal(t, 2, merged.Agents)
+	assert.Equal(t, int64(48*3600), merged.ObservedSeconds)
+	assert.Equal(t, int64(42*3600), merged.UptimeSeconds)
+	assert.Equal(t, 87.5, merged.UptimePercent)
+	assert.Equal(t, 1, merged.Outages)
+	assert.Equal(t, float64(6*3600), merged.MTTRSeconds)
+
+	require.Len(t, merged.Daily, 1)
+	assert.Equal(t, 87.5, merged.Daily[0].UptimePercent)
+}
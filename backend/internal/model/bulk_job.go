@@ -0,0 +1,143 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BulkAction 批量操作类型
+type BulkAction string
+
+const (
+	// BulkActionDelete 删除 Agent
+	BulkActionDelete BulkAction = "delete"
+	// BulkActionRelabel 修改服务端标签
+	BulkActionRelabel BulkAction = "relabel"
+	// BulkActionAssignConfiguration 指定 Agent 使用的配置 (设置 ConfigurationName)
+	BulkActionAssignConfiguration BulkAction = "assign_configuration"
+	// BulkActionRestart 要求已连接的 Agent 重启
+	BulkActionRestart BulkAction = "restart"
+	// BulkActionResync 要求已连接的 Agent 重新上报完整状态并核对配置
+	BulkActionResync BulkAction = "resync"
+)
+
+// IsValid 检查批量操作类型是否有效
+func (a BulkAction) IsValid() bool {
+	switch a {
+	case BulkActionDelete, BulkActionRelabel, BulkActionAssignConfiguration, BulkActionRestart, BulkActionResync:
+		return true
+	default:
+		return false
+	}
+}
+
+// maxBulkAgentIDs 单次请求允许显式指定的 Agent 数量
+const maxBulkAgentIDs = 10000
+
+// BulkAgentRequest 批量操作 Agent 的请求
+//
+// Selector 和 AgentIDs 只能指定其中一个。
+type BulkAgentRequest struct {
+	Selector map[string]string `json:"selector,omitempty"`
+	AgentIDs []string          `json:"agent_ids,omitempty"`
+	Action   BulkAction        `json:"action" binding:"required"`
+	// Labels relabel 操作的标签修改
+	Labels *AgentLabelsPatch `json:"labels,omitempty"`
+	// ConfigurationName assign_configuration 操作指定的配置, 为空表示取消指定, 改为按标签匹配
+	ConfigurationName *string `json:"configuration_name,omitempty"`
+	// DryRun 只返回匹配的 Agent, 不执行操作
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// Validate 检查请求是否有效
+func (r *BulkAgentRequest) Validate() error {
+	if !r.Action.IsValid() {
+		return fmt.Errorf("invalid action: %q", r.Action)
+	}
+	if (len(r.Selector) == 0) == (len(r.AgentIDs) == 0) {
+		return errors.New("exactly one of selector and agent_ids is required")
+	}
+	if len(r.AgentIDs) > maxBulkAgentIDs {
+		return fmt.Errorf("agent_ids must not contain more than %d agents", maxBulkAgentIDs)
+	}
+
+	switch r.Action {
+	case BulkActionRelabel:
+		if r.Labels == nil || (len(r.Labels.Set) == 0 && len(r.Labels.Remove) == 0) {
+			return errors.New("labels is required for relabel")
+		}
+		return ValidateLabels(r.Labels.Set)
+	case BulkActionAssignConfiguration:
+		if r.ConfigurationName == nil {
+			return errors.New("configuration_name is required for assign_configuration")
+		}
+	}
+	return nil
+}
+
+// BulkJobStatus 批量操作任务状态
+type BulkJobStatus string
+
+const (
+	BulkJobRunning   BulkJobStatus = "running"
+	BulkJobCompleted BulkJobStatus = "completed"
+	// BulkJobInterrupted 服务重启时仍在执行的任务, 未处理的 Agent 没有结果
+	BulkJobInterrupted BulkJobStatus = "interrupted"
+)
+
+// BulkResultStatus 单个 Agent 的执行结果
+type BulkResultStatus string
+
+const (
+	BulkResultSucceeded BulkResultStatus = "succeeded"
+	BulkResultFailed    BulkResultStatus = "failed"
+	// BulkResultSkipped Agent 已不存在、不在角色范围内或不支持该操作
+	BulkResultSkipped BulkResultStatus = "skipped"
+)
+
+// BulkAgentResult 单个 Agent 的执行结果
+type BulkAgentResult struct {
+	AgentID string           `json:"agent_id"`
+	Status  BulkResultStatus `json:"status"`
+	Error   string           `json:"error,omitempty"`
+	// ConfigurationPushed 操作后匹配到新的配置并已推送时为该配置的名称
+	ConfigurationPushed string `json:"configuration_pushed,omitempty"`
+}
+
+// BulkJob 表示一次批量操作任务
+//
+// 匹配的 Agent 在创建任务时确定, 任务在后台逐个执行并记录每个 Agent 的结果。
+type BulkJob struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	OrganizationID uint              `json:"organization_id" gorm:"not null;default:1;index"`
+	Action         BulkAction        `json:"action" gorm:"type:varchar(50);not null"`
+	Request        BulkAgentRequest  `json:"request" gorm:"serializer:json"`
+	Status         BulkJobStatus     `json:"status" gorm:"type:varchar(20);not null;index"`
+	Total          int               `json:"total"`
+	Succeeded      int               `json:"succeeded"`
+	Failed         int               `json:"failed"`
+	Skipped        int               `json:"skipped"`
+	Results        []BulkAgentResult `json:"results,omitempty" gorm:"serializer:json"`
+	CreatedBy      string            `json:"created_by"`
+	CreatedAt      time.Time         `json:"created_at" gorm:"index"`
+	FinishedAt     *time.Time        `json:"finished_at,omitempty"`
+}
+
+// TableName 指定表名
+func (BulkJob) TableName() string {
+	return "bulk_jobs"
+}
+
+// AddResult 记录单个 Agent 的结果并更新计数
+func (j *BulkJob) AddResult(result BulkAgentResult) {
+	j.Results = append(j.Results, result)
+	switch result.Status {
+	case BulkResultSucceeded:
+		j.Succeeded++
+	case BulkResultFailed:
+		j.Failed++
+	case BulkResultSkipped:
+		j.Skipped++
+	}
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkAgentRequest_Validate(t *testing.T) {
+	empty := ""
+	tests := []struct {
+		name    string
+		req     BulkAgentRequest
+		wantErr bool
+	}{
+		{"delete by selector", BulkAgentRequest{Action: BulkActionDelete, Selector: map[string]string{"env": "dev"}}, false},
+		{"restart by ids", BulkAgentRequest{Action: BulkActionRestart, AgentIDs: []string{"a1"}}, false},
+		{"unknown action", BulkAgentRequest{Action: "upgrade", AgentIDs: []string{"a1"}}, true},
+		{"no target", BulkAgentRequest{Action: BulkActionResync}, true},
+		{"both targets", BulkAgentRequest{Action: BulkActionResync, AgentIDs: []string{"a1"}, Selector: map[string]string{"env": "dev"}}, true},
+		{"relabel without labels", BulkAgentRequest{Action: BulkActionRelabel, AgentIDs: []string{"a1"}}, true},
+		{"relabel with invalid label", BulkAgentRequest{Action: BulkActionRelabel, AgentIDs: []string{"a1"}, Labels: &AgentLabelsPatch{Set: Labels{"a=b": "c"}}}, true},
+		{"relabel", BulkAgentRequest{Action: BulkActionRelabel, AgentIDs: []string{"a1"}, Labels: &AgentLabelsPatch{Remove: []string{"team"}}}, false},
+		{"assign without configuration", BulkAgentRequest{Action: BulkActionAssignConfiguration, AgentIDs: []string{"a1"}}, true},
+		{"unassign configuration", BulkAgentRequest{Action: BulkActionAssignConfiguration, AgentIDs: []string{"a1"}, ConfigurationName: &empty}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestBulkJob_AddResult(t *testing.T) {
+	job := &BulkJob{}
+	job.AddResult(BulkAgentResult{AgentID: "a1", Status: BulkResultSucceeded})
+	job.AddResult(BulkAgentResult{AgentID: "a2", Status: BulkResultFailed, Error: "boom"})
+	job.AddResult(BulkAgentResult{AgentID: "a3", Status: BulkResultSkipped})
+	job.AddResult(BulkAgentResult{AgentID: "a4", Status: BulkResultSucceeded})
+
+	assert.Equal(t, 2, job.Succeeded)
+	assert.Equal(t, 1, job.Failed)
+	assert.Equal(t, 1, job.Skipped)
+	assert.Len(t, job.Results, 4)
+}
@@ -3,6 +3,8 @@ package model
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"maps"
 	"time"
 )
 
@@ -13,6 +15,9 @@ type Configuration struct {
 	DisplayName string `json:"display_name"`
 	Description string `json:"description"`
 
+	// 所属组织, 配置名称在组织内唯一
+	OrganizationID uint `json:"organization_id" gorm:"primaryKey;autoIncrement:false;default:1"`
+
 	// 配置内容
 	ContentType string `json:"content_type"` // yaml, json
 	RawConfig   string `json:"raw_config" gorm:"type:text"`    // 原始配置内容 (YAML/JSON)
@@ -29,6 +34,8 @@ type Configuration struct {
 	Platform *PlatformConfig `json:"platform,omitempty" gorm:"serializer:json"`
 
 	// 元数据
+	// UpdatedBy 最后修改当前版本的用户, 生成历史版本时记录为该版本的 CreatedBy
+	UpdatedBy string    `json:"updated_by"`
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
@@ -58,6 +65,24 @@ func (c *Configuration) UpdateHash() {
 	c.ConfigHash = hex.EncodeToString(hash[:])
 }
 
+// ChangedFrom 检查配置的可编辑字段是否与 existing 不同
+//
+// 内容、选择器、平台配置和描述信息的任何变化都会产生新版本, ETag 依赖版本号识别并发修改。
+// 调用前需要先调用 UpdateHash。
+func (c *Configuration) ChangedFrom(existing *Configuration) bool {
+	if c.ConfigHash != existing.ConfigHash ||
+		c.ContentType != existing.ContentType ||
+		c.DisplayName != existing.DisplayName ||
+		c.Description != existing.Description ||
+		!maps.Equal(c.Selector, existing.Selector) {
+		return true
+	}
+	// 平台配置按序列化结果比较, 避免数字参数在 JSON 往返后类型不同
+	platform, _ := json.Marshal(c.Platform)
+	existingPlatform, _ := json.Marshal(existing.Platform)
+	return string(platform) != string(existingPlatform)
+}
+
 // MatchesAgent 检查配置是否匹配 Agent
 func (c *Configuration) MatchesAgent(agent *Agent) bool {
 	if len(c.Selector) == 0 {
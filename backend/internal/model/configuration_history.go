@@ -5,6 +5,7 @@ import "time"
 // ConfigurationHistory 表示配置的历史版本
 type ConfigurationHistory struct {
 	ID                uint              `json:"id" gorm:"primaryKey"`
+	OrganizationID    uint              `json:"organization_id" gorm:"not null;default:1;index"`
 	ConfigurationName string            `json:"configuration_name" gorm:"index;not null"`
 	Version           int               `json:"version" gorm:"not null"`
 	ContentType       string            `json:"content_type" gorm:"default:yaml"`
@@ -35,6 +36,7 @@ const (
 // ConfigurationApplyHistory 表示配置应用到 Agent 的历史记录
 type ConfigurationApplyHistory struct {
 	ID                uint        `json:"id" gorm:"primaryKey"`
+	OrganizationID    uint        `json:"organization_id" gorm:"not null;default:1;index"`
 	AgentID           string      `json:"agent_id" gorm:"index;not null"`
 	ConfigurationName string      `json:"configuration_name" gorm:"index;not null"`
 	ConfigHash        string      `json:"config_hash" gorm:"not null"`
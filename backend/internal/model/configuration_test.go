@@ -40,6 +40,45 @@ func TestConfiguration_UpdateHash(t *testing.T) {
 	}
 }
 
+func TestConfiguration_ChangedFrom(t *testing.T) {
+	existing := &Configuration{
+		Name:        "test-config",
+		ContentType: "yaml",
+		RawConfig:   "receivers: {}",
+		Selector:    map[string]string{"env": "dev"},
+		Platform:    &PlatformConfig{Sources: []ResourceReference{{Name: "otlp", Parameters: map[string]interface{}{"port": 4317}}}},
+	}
+	existing.UpdateHash()
+
+	tests := []struct {
+		name   string
+		modify func(c *Configuration)
+		want   bool
+	}{
+		{"no change", func(c *Configuration) {}, false},
+		{"selector", func(c *Configuration) { c.Selector = map[string]string{"env": "prod"} }, true},
+		{"content type", func(c *Configuration) { c.ContentType = "json" }, true},
+		{"description", func(c *Configuration) { c.Description = "changed" }, true},
+		{"raw config", func(c *Configuration) { c.RawConfig = "receivers: {otlp: {}}" }, true},
+		{"platform", func(c *Configuration) { c.Platform = nil }, true},
+		{"platform number type", func(c *Configuration) {
+			c.Platform = &PlatformConfig{Sources: []ResourceReference{{Name: "otlp", Parameters: map[string]interface{}{"port": float64(4317)}}}}
+		}, false},
+		{"selector removed", func(c *Configuration) { c.Selector = nil }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := *existing
+			tt.modify(&config)
+			config.UpdateHash()
+			if got := config.ChangedFrom(existing); got != tt.want {
+				t.Errorf("ChangedFrom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestConfiguration_MatchesAgent(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FleetDimension Agent 统计的分组维度
+type FleetDimension string
+
+const (
+	FleetDimensionStatus        FleetDimension = "status"
+	FleetDimensionVersion       FleetDimension = "version"
+	FleetDimensionOS            FleetDimension = "os"
+	FleetDimensionArch          FleetDimension = "arch"
+	FleetDimensionConfiguration FleetDimension = "configuration"
+)
+
+// fleetLabelDimensionPrefix 按标签分组的维度前缀, 如 "label:env"
+const fleetLabelDimensionPrefix = "label:"
+
+// LabelDimension 返回按指定标签 key 分组的维度
+func LabelDimension(key string) FleetDimension {
+	return FleetDimension(fleetLabelDimensionPrefix + key)
+}
+
+// LabelKey 返回标签维度的标签 key, 不是标签维度时返回 false
+func (d FleetDimension) LabelKey() (string, bool) {
+	key, ok := strings.CutPrefix(string(d), fleetLabelDimensionPrefix)
+	if !ok || key == "" {
+		return "", false
+	}
+	return key, true
+}
+
+// IsValid 检查分组维度是否有效
+func (d FleetDimension) IsValid() bool {
+	switch d {
+	case FleetDimensionStatus, FleetDimensionVersion, FleetDimensionOS, FleetDimensionArch, FleetDimensionConfiguration:
+		return true
+	}
+	_, ok := d.LabelKey()
+	return ok
+}
+
+// ParseFleetDimensions 解析逗号分隔的分组维度列表, 忽略重复项
+func ParseFleetDimensions(s string) ([]FleetDimension, error) {
+	var dimensions []FleetDimension
+	seen := make(map[FleetDimension]bool)
+	for _, part := range strings.Split(s, ",") {
+		d := FleetDimension(strings.TrimSpace(part))
+		if d == "" || seen[d] {
+			continue
+		}
+		if !d.IsValid() {
+			return nil, fmt.Errorf("invalid dimension: %q", part)
+		}
+		seen[d] = true
+		dimensions = append(dimensions, d)
+	}
+	return dimensions, nil
+}
+
+// FleetBucket 一个分组取值及其 Agent 数量
+//
+// 缺少该字段或标签的 Agent 归入取值为空的分组。
+type FleetBucket struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// FleetStatsSample 定期采样的分组统计, 每个分组取值一行
+type FleetStatsSample struct {
+	ID             uint      `json:"-" gorm:"primaryKey"`
+	OrganizationID uint      `json:"-" gorm:"index:idx_fleet_stats_lookup,priority:1;not null;default:1"`
+	Dimension      string    `json:"dimension" gorm:"index:idx_fleet_stats_lookup,priority:2;type:varchar(255);not null"`
+	SampledAt      time.Time `json:"sampled_at" gorm:"index:idx_fleet_stats_lookup,priority:3;index;not null"`
+	Value          string    `json:"value" gorm:"not null;default:''"`
+	Count          int64     `json:"count" gorm:"not null;default:0"`
+}
+
+// TableName 指定表名
+func (FleetStatsSample) TableName() string {
+	return "fleet_stats_samples"
+}
+
+// FleetSeriesPoint 时间序列中一次采样的分组统计
+type FleetSeriesPoint struct {
+	SampledAt time.Time     `json:"sampled_at"`
+	Buckets   []FleetBucket `json:"buckets"`
+}
+
+// BuildFleetSeries 将按采样时间排序的采样记录整理为时间序列
+func BuildFleetSeries(samples []*FleetStatsSample) []FleetSeriesPoint {
+	series := make([]FleetSeriesPoint, 0)
+	for _, sample := range samples {
+		if n := len(series); n == 0 || !series[n-1].SampledAt.Equal(sample.SampledAt) {
+			series = append(series, FleetSeriesPoint{SampledAt: sample.SampledAt})
+		}
+		point := &series[len(series)-1]
+		point.Buckets = append(point.Buckets, FleetBucket{Value: sample.Value, Count: sample.Count})
+	}
+	return series
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFleetDimensions(t *testing.T) {
+	dimensions, err := ParseFleetDimensions("status, version,label:env,status")
+	require.NoError(t, err)
+	assert.Equal(t, []FleetDimension{FleetDimensionStatus, FleetDimensionVersion, LabelDimension("env")}, dimensions)
+
+	key, ok := dimensions[2].LabelKey()
+	assert.True(t, ok)
+	assert.Equal(t, "env", key)
+
+	_, err = ParseFleetDimensions("label:")
+	assert.Error(t, err)
+	_, err = ParseFleetDimensions("cpu")
+	assert.Error(t, err)
+}
+
+func TestBuildFleetSeries(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(5 * time.Minute)
+
+	series := BuildFleetSeries([]*FleetStatsSample{
+		{SampledAt: t1, Value: "1.0", Count: 3},
+		{SampledAt: t1, Value: "1.1", Count: 1},
+		{SampledAt: t2, Value: "1.1", Count: 4},
+	})
+
+	require.Len(t, series, 2)
+	assert.Equal(t, t1, series[0].SampledAt)
+	assert.Equal(t, []FleetBucket{{Value: "1.0", Count: 3}, {Value: "1.1", Count: 1}}, series[0].Buckets)
+	assert.Equal(t, []FleetBucket{{Value: "1.1", Count: 4}}, series[1].Buckets)
+
+	assert.Empty(t, BuildFleetSeries(nil))
+}
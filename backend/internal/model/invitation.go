@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// Invitation 表示一个用户邀请
+//
+// 邀请令牌只在创建时返回一次, 数据库中只保存其哈希。
+type Invitation struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	OrganizationID uint       `json:"organization_id" gorm:"not null;default:1;index"` // 接受邀请的用户加入该组织
+	TokenHash      string     `json:"-" gorm:"uniqueIndex;not null"`
+	Email          string     `json:"email,omitempty"`
+	Role           string     `json:"role" gorm:"not null"`
+	CreatedBy      string     `json:"created_by"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null;index"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	AcceptedBy     *uint      `json:"accepted_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// IsUsable 检查邀请是否仍可使用 (未接受且未过期)
+func (i *Invitation) IsUsable(now time.Time) bool {
+	return i.AcceptedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// InvitationRequest 创建邀请的请求
+type InvitationRequest struct {
+	Email     string `json:"email" binding:"omitempty,email"`
+	Role      string `json:"role"`
+	ExpiresIn string `json:"expires_in"` // Go duration, 例如 "72h", 为空使用默认有效期
+}
+
+// AcceptInvitationRequest 接受邀请的请求
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Username string `json:"username" binding:"required,min=3,max=32"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	Password string `json:"password" binding:"required"`
+}
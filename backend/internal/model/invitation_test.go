@@ -0,0 +1,22 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvitationIsUsable(t *testing.T) {
+	now := time.Now()
+
+	invitation := &Invitation{ExpiresAt: now.Add(time.Hour)}
+	assert.True(t, invitation.IsUsable(now))
+
+	// 已过期
+	assert.False(t, invitation.IsUsable(now.Add(2*time.Hour)))
+
+	// 已接受
+	invitation.AcceptedAt = &now
+	assert.False(t, invitation.IsUsable(now))
+}
@@ -0,0 +1,78 @@
+package model
+
+import "time"
+
+// RecoveryCode 表示 MFA 恢复码, 只保存哈希, 每个恢复码只能使用一次
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (RecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// MFARequiredRole 表示要求用户启用 MFA 的角色
+type MFARequiredRole struct {
+	OrganizationID uint      `json:"organization_id" gorm:"primaryKey;autoIncrement:false;default:1"`
+	Role           string    `json:"role" gorm:"primaryKey"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (MFARequiredRole) TableName() string {
+	return "mfa_required_roles"
+}
+
+// MFAChallengeResponse 两步登录第一步的响应, 使用 MFA 令牌完成第二步
+type MFAChallengeResponse struct {
+	MFARequired        bool   `json:"mfa_required"`
+	EnrollmentRequired bool   `json:"enrollment_required,omitempty"` // 角色要求 MFA 但用户尚未绑定
+	MFAToken           string `json:"mfa_token"`
+	ExpiresIn          int64  `json:"expires_in"` // MFA 令牌有效期 (秒)
+}
+
+// MFAVerifyRequest 两步登录第二步的请求, 验证码和恢复码二选一
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFAEnrollRequest 登录过程中开始绑定 MFA 的请求
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFAConfirmRequest 确认绑定 MFA 的请求, 登录过程中需要携带 MFA 令牌
+type MFAConfirmRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// MFACodeRequest 需要当前验证码的操作 (关闭 MFA, 重新生成恢复码)
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFAEnrollmentResponse 绑定 MFA 时返回的密钥和二维码 URI
+type MFAEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFAStatus 用户的 MFA 状态
+type MFAStatus struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"` // 用户的角色要求 MFA
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// MFAPolicyRequest 设置要求 MFA 的角色
+type MFAPolicyRequest struct {
+	Roles []string `json:"roles"`
+}
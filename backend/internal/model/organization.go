@@ -0,0 +1,74 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// DefaultOrganizationID 默认组织的 ID
+//
+// 启用多租户之前的数据全部属于默认组织, 使用全局 OpAMP Secret Key 连接的 Agent
+// 以及自助注册的用户也归入默认组织。默认组织的管理员同时是平台管理员。
+const DefaultOrganizationID uint = 1
+
+// Organization 表示一个组织 (租户)
+//
+// Agent、配置、软件包、用户等数据都属于某个组织, 不同组织之间的数据互相隔离。
+type Organization struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name" gorm:"uniqueIndex;not null"`
+	DisplayName string `json:"display_name"`
+	// SecretKeyHash Agent 连接时使用的 Secret Key 的哈希, 明文只在生成时返回一次
+	SecretKeyHash *string `json:"-" gorm:"uniqueIndex"`
+	// MaxAgents Agent 数量上限, 0 表示不限制
+	MaxAgents int `json:"max_agents" gorm:"not null;default:0"`
+	// MaxPackageBytes 软件包存储空间上限 (字节), 0 表示不限制
+	MaxPackageBytes int64     `json:"max_package_bytes" gorm:"not null;default:0"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// SetSecretKey 设置 Agent 连接使用的 Secret Key, 只保存其哈希
+func (o *Organization) SetSecretKey(secretKey string) {
+	hash := HashSecretKey(secretKey)
+	o.SecretKeyHash = &hash
+}
+
+// HashSecretKey 计算 Secret Key 的哈希, 用于按 Secret Key 查找组织
+func HashSecretKey(secretKey string) string {
+	hash := sha256.Sum256([]byte(secretKey))
+	return hex.EncodeToString(hash[:])
+}
+
+// AllowsAgents 检查在已有 current 个 Agent 时是否还能再接入 n 个
+func (o *Organization) AllowsAgents(current int64, n int) bool {
+	return o.MaxAgents <= 0 || current+int64(n) <= int64(o.MaxAgents)
+}
+
+// AllowsPackageBytes 检查在已使用 used 字节时是否还能再存储 size 字节
+func (o *Organization) AllowsPackageBytes(used, size int64) bool {
+	return o.MaxPackageBytes <= 0 || used+size <= o.MaxPackageBytes
+}
+
+// OrganizationUsage 组织当前的资源使用量
+type OrganizationUsage struct {
+	Agents       int64 `json:"agents"`
+	PackageBytes int64 `json:"package_bytes"`
+	Users        int64 `json:"users"`
+}
+
+// OrganizationRequest 创建或更新组织的请求
+type OrganizationRequest struct {
+	Name            string `json:"name" binding:"required,min=2,max=64"`
+	DisplayName     string `json:"display_name"`
+	MaxAgents       int    `json:"max_agents" binding:"min=0"`
+	MaxPackageBytes int64  `json:"max_package_bytes" binding:"min=0"`
+	// AdminEmail 创建组织时为该邮箱生成一个管理员邀请, 为空则不生成
+	AdminEmail string `json:"admin_email" binding:"omitempty,email"`
+}
@@ -18,6 +18,9 @@ type Package struct {
 	IsActive    bool      `gorm:"default:true" json:"is_active"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// 所属组织, 同一组织内名称、版本、平台和架构唯一
+	OrganizationID uint `gorm:"uniqueIndex:idx_package_unique;not null;default:1" json:"organization_id"`
 }
 
 // TableName 指定表名
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// RefreshToken 表示服务端保存的刷新令牌
+//
+// 每次刷新都会签发新令牌并使旧令牌失效 (轮换)。同一登录会话的令牌共享 FamilyID,
+// 已失效的令牌被再次使用时视为泄露, 整个会话的令牌都会被撤销。
+type RefreshToken struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	TokenHash    string     `json:"-" gorm:"uniqueIndex;not null"`
+	FamilyID     string     `json:"family_id" gorm:"not null;index"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	TokenVersion int        `json:"-" gorm:"not null;default:0"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// IsUsable 检查刷新令牌是否仍可使用
+func (t *RefreshToken) IsUsable(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// RevokedAccessToken 表示被单独撤销的访问令牌 (退出登录), 过期后可以清理
+type RevokedAccessToken struct {
+	JTI       string    `json:"jti" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (RevokedAccessToken) TableName() string {
+	return "revoked_access_tokens"
+}
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshTokenIsUsable(t *testing.T) {
+	now := time.Now()
+
+	token := &RefreshToken{ExpiresAt: now.Add(time.Hour)}
+	assert.True(t, token.IsUsable(now))
+	assert.False(t, token.IsUsable(now.Add(2*time.Hour)))
+
+	token.RevokedAt = &now
+	assert.False(t, token.IsUsable(now))
+}
@@ -0,0 +1,79 @@
+package model
+
+import (
+	"errors"
+	"time"
+)
+
+// RetentionTable 可以按保留策略清理的历史表
+type RetentionTable string
+
+const (
+	RetentionTableConnectionHistory    RetentionTable = "agent_connection_history"
+	RetentionTableApplyHistory         RetentionTable = "configuration_apply_history"
+	RetentionTableConfigurationHistory RetentionTable = "configuration_history"
+)
+
+// RetentionTables 所有可以清理的历史表
+var RetentionTables = []RetentionTable{
+	RetentionTableConnectionHistory,
+	RetentionTableApplyHistory,
+	RetentionTableConfigurationHistory,
+}
+
+// IsValid 检查历史表是否可以清理
+func (t RetentionTable) IsValid() bool {
+	for _, table := range RetentionTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// RetentionPolicy 一张历史表的保留策略
+//
+// 连接历史和配置应用历史按 Agent 分组, 配置历史按配置分组。超过 MaxAgeSeconds 或者在
+// 分组中排在 MaxRows 之后的记录被删除, 但每个分组最新的 KeepLast 条总是保留。
+// 进行中的连接和未结束的配置应用不会被删除。
+type RetentionPolicy struct {
+	Table RetentionTable `json:"table"`
+	// MaxAgeSeconds 记录的最长保留时长, 0 表示不按时长清理
+	MaxAgeSeconds int64 `json:"max_age_seconds"`
+	// MaxRows 每个分组最多保留的记录数, 0 表示不限制
+	MaxRows int `json:"max_rows"`
+	// KeepLast 每个分组总是保留的最新记录数
+	KeepLast int `json:"keep_last"`
+}
+
+// MaxAge 返回记录的最长保留时长
+func (p RetentionPolicy) MaxAge() time.Duration {
+	return time.Duration(p.MaxAgeSeconds) * time.Second
+}
+
+// Enabled 检查策略是否会清理记录
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxAgeSeconds > 0 || p.MaxRows > 0
+}
+
+// Validate 检查保留策略是否有效
+func (p RetentionPolicy) Validate() error {
+	if !p.Table.IsValid() {
+		return errors.New("unknown retention table: " + string(p.Table))
+	}
+	if p.MaxAgeSeconds < 0 || p.MaxRows < 0 || p.KeepLast < 0 {
+		return errors.New("retention limits must not be negative")
+	}
+	if p.MaxRows > 0 && p.KeepLast > p.MaxRows {
+		return errors.New("keep_last must not exceed max_rows")
+	}
+	return nil
+}
+
+// PruneResult 一张历史表的清理结果
+type PruneResult struct {
+	Table RetentionTable `json:"table"`
+	// Rows 已删除 (试运行时为将要删除) 的记录数
+	Rows  int64  `json:"rows"`
+	Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetentionPolicy
+		wantErr bool
+	}{
+		{"disabled policy", RetentionPolicy{Table: RetentionTableConnectionHistory}, false},
+		{"max age", RetentionPolicy{Table: RetentionTableApplyHistory, MaxAgeSeconds: 3600}, false},
+		{"keep last within max rows", RetentionPolicy{Table: RetentionTableConfigurationHistory, MaxRows: 20, KeepLast: 10}, false},
+		{"keep last exceeds max rows", RetentionPolicy{Table: RetentionTableConfigurationHistory, MaxRows: 5, KeepLast: 10}, true},
+		{"negative limit", RetentionPolicy{Table: RetentionTableConnectionHistory, MaxRows: -1}, true},
+		{"unknown table", RetentionPolicy{Table: "audit_logs", MaxAgeSeconds: 3600}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicy_Enabled(t *testing.T) {
+	assert.False(t, RetentionPolicy{Table: RetentionTableConnectionHistory, KeepLast: 5}.Enabled())
+	assert.True(t, RetentionPolicy{Table: RetentionTableConnectionHistory, MaxRows: 100}.Enabled())
+
+	policy := RetentionPolicy{Table: RetentionTableConnectionHistory, MaxAgeSeconds: 86400}
+	assert.True(t, policy.Enabled())
+	assert.Equal(t, 24*time.Hour, policy.MaxAge())
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Role 表示一个角色及其权限
+//
+// 内置角色 (viewer/operator/admin) 定义在代码中, 自定义角色保存在数据库。
+// Selector 不为空时, 角色只能访问标签匹配该选择器的 Agent。
+type Role struct {
+	ID             uint              `json:"id,omitempty" gorm:"primaryKey"`
+	OrganizationID uint              `json:"organization_id" gorm:"uniqueIndex:idx_roles_org_name;not null;default:1"`
+	Name           string            `json:"name" gorm:"uniqueIndex:idx_roles_org_name;not null"` // 组织内唯一
+	Description    string            `json:"description" gorm:"type:text"`
+	Permissions    []string          `json:"permissions" gorm:"serializer:json"`
+	Selector       map[string]string `json:"selector,omitempty" gorm:"serializer:json"`
+	BuiltIn        bool              `json:"built_in" gorm:"-"`
+	CreatedAt      time.Time         `json:"created_at,omitempty" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time         `json:"updated_at,omitempty" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (Role) TableName() string {
+	return "roles"
+}
+
+// HasPermission 检查角色是否拥有指定权限
+//
+// 支持 "*" (所有权限) 和 "resource:*" (某类资源的所有权限) 通配符。
+func (r *Role) HasPermission(permission string) bool {
+	resource := permission
+	if i := strings.Index(permission, ":"); i >= 0 {
+		resource = permission[:i]
+	}
+
+	for _, p := range r.Permissions {
+		if p == "*" || p == permission || p == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsScoped 检查角色是否限定了 Agent 范围
+func (r *Role) IsScoped() bool {
+	return len(r.Selector) > 0
+}
+
+// RoleRequest 创建或更新自定义角色的请求
+type RoleRequest struct {
+	Name        string            `json:"name" binding:"required,min=2,max=64"`
+	Description string            `json:"description"`
+	Permissions []string          `json:"permissions" binding:"required"`
+	Selector    map[string]string `json:"selector"`
+}
+
+// UpdateUserRoleRequest 修改用户角色的请求
+type UpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
@@ -9,14 +9,23 @@ import (
 
 // User 表示系统用户
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Username  string    `json:"username" gorm:"uniqueIndex;not null"`
-	Email     string    `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string    `json:"-" gorm:"not null"` // 密码不会在 JSON 中暴露
-	Role      string    `json:"role" gorm:"default:'user'"`
-	IsActive  bool      `json:"is_active" gorm:"default:true"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	OrganizationID    uint       `json:"organization_id" gorm:"not null;default:1;index"` // 所属组织, 用户名和邮箱全局唯一
+	Username          string     `json:"username" gorm:"uniqueIndex;not null"`
+	Email             string     `json:"email" gorm:"uniqueIndex;not null"`
+	Password          string     `json:"-" gorm:"not null"` // 密码不会在 JSON 中暴露
+	Role              string     `json:"role" gorm:"default:'user'"`
+	IsActive          bool       `json:"is_active" gorm:"default:true"`
+	IsServiceAccount  bool       `json:"is_service_account" gorm:"default:false"`       // 服务账号只能使用 API 令牌, 不能密码登录
+	TokenVersion      int        `json:"-" gorm:"not null;default:0"`                   // 递增后已签发的访问令牌全部失效
+	AuthProvider      string     `json:"auth_provider" gorm:"not null;default:'local'"` // local 或 oidc, oidc 用户不能密码登录
+	ExternalID        *string    `json:"-" gorm:"uniqueIndex"`                          // 外部身份提供方中的唯一标识
+	MFAEnabled        bool       `json:"mfa_enabled" gorm:"not null;default:false"`
+	MFASecret         string     `json:"-"`                             // TOTP 密钥, 绑定确认前 MFAEnabled 为 false
+	MFALastStep       int64      `json:"-" gorm:"not null;default:0"`   // 最近一次使用的验证码时间步, 防止重放
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"` // 用于密码过期策略
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
 }
 
 // TableName 指定表名
@@ -32,6 +41,10 @@ func (u *User) BeforeCreate(tx *gorm.DB) error {
 			return err
 		}
 		u.Password = string(hashedPassword)
+		if u.PasswordChangedAt == nil {
+			now := time.Now()
+			u.PasswordChangedAt = &now
+		}
 	}
 	return nil
 }
@@ -49,9 +62,30 @@ func (u *User) SetPassword(password string) error {
 		return err
 	}
 	u.Password = string(hashedPassword)
+	now := time.Now()
+	u.PasswordChangedAt = &now
 	return nil
 }
 
+// PasswordSetAt 返回密码的设置时间, 旧数据没有记录时使用创建时间
+func (u *User) PasswordSetAt() time.Time {
+	if u.PasswordChangedAt != nil {
+		return *u.PasswordChangedAt
+	}
+	return u.CreatedAt
+}
+
+// 用户的认证来源
+const (
+	AuthProviderLocal = "local"
+	AuthProviderOIDC  = "oidc"
+)
+
+// IsExternal 用户是否由外部身份提供方管理
+func (u *User) IsExternal() bool {
+	return u.AuthProvider != "" && u.AuthProvider != AuthProviderLocal
+}
+
 // LoginRequest 登录请求
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
@@ -60,13 +94,64 @@ type LoginRequest struct {
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"` // 访问令牌有效期 (秒)
+	User         *User  `json:"user"`
+}
+
+// RefreshRequest 刷新令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 退出登录请求
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"` // 退出该用户的所有会话
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// PasswordExpiredResponse 密码已过期时的登录响应, 使用 PasswordToken 设置新密码后完成登录
+type PasswordExpiredResponse struct {
+	PasswordExpired bool   `json:"password_expired"`
+	PasswordToken   string `json:"password_token"`
+	ExpiresIn       int64  `json:"expires_in"` // 修改密码令牌有效期 (秒)
+}
+
+// RenewPasswordRequest 使用修改密码令牌设置新密码的请求
+type RenewPasswordRequest struct {
+	PasswordToken string `json:"password_token" binding:"required"`
+	NewPassword   string `json:"new_password" binding:"required"`
 }
 
 // RegisterRequest 注册请求
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=32"`
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
+}
+
+// CreateUserRequest 管理员创建用户的请求
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=32"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role"`
+}
+
+// ResetPasswordRequest 管理员重置密码的请求, 密码为空时生成临时密码; 密码规则由密码策略校验
+type ResetPasswordRequest struct {
+	Password string `json:"password"`
+}
+
+// CreateServiceAccountRequest 创建服务账号的请求
+type CreateServiceAccountRequest struct {
+	Name string `json:"name" binding:"required,min=3,max=32"`
+	Role string `json:"role"`
 }
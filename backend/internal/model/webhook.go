@@ -0,0 +1,87 @@
+package model
+
+import "time"
+
+// Webhook 表示一个出站 Webhook 订阅
+type Webhook struct {
+	ID             uint              `json:"id" gorm:"primaryKey"`
+	OrganizationID uint              `json:"organization_id" gorm:"uniqueIndex:idx_webhooks_org_name;not null;default:1"`
+	Name           string            `json:"name" gorm:"uniqueIndex:idx_webhooks_org_name;not null"` // 组织内唯一
+	URL            string            `json:"url" gorm:"type:text;not null"`
+	Description    string            `json:"description" gorm:"type:text"`
+	EventTypes     []string          `json:"event_types" gorm:"serializer:json"` // 为空则订阅所有事件
+	Selector       map[string]string `json:"selector" gorm:"serializer:json"`    // 标签选择器
+	Secret         string            `json:"-" gorm:"not null"`                  // HMAC 签名密钥, 不在 JSON 中暴露
+	Enabled        bool              `json:"enabled"`
+	CreatedAt      time.Time         `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time         `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Matches 检查 Webhook 是否订阅了指定事件
+func (w *Webhook) Matches(eventType string, labels Labels) bool {
+	if !w.Enabled {
+		return false
+	}
+
+	if len(w.EventTypes) > 0 {
+		subscribed := false
+		for _, t := range w.EventTypes {
+			if t == eventType {
+				subscribed = true
+				break
+			}
+		}
+		if !subscribed {
+			return false
+		}
+	}
+
+	return labels.Matches(w.Selector)
+}
+
+// WebhookRequest 创建或更新 Webhook 的请求
+type WebhookRequest struct {
+	Name        string            `json:"name" binding:"required,max=255"`
+	URL         string            `json:"url" binding:"required,url"`
+	Description string            `json:"description"`
+	EventTypes  []string          `json:"event_types"`
+	Selector    map[string]string `json:"selector"`
+	Secret      string            `json:"secret"`  // 为空时自动生成 (仅创建时)
+	Enabled     *bool             `json:"enabled"` // 为空时默认启用
+}
+
+// DeliveryStatus 表示 Webhook 投递状态
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "pending"   // 等待投递或重试
+	DeliveryStatusSucceeded DeliveryStatus = "succeeded" // 投递成功
+	DeliveryStatusFailed    DeliveryStatus = "failed"    // 重试次数耗尽
+)
+
+// WebhookDelivery 记录一次 Webhook 投递
+type WebhookDelivery struct {
+	ID            uint           `json:"id" gorm:"primaryKey"`
+	WebhookID     uint           `json:"webhook_id" gorm:"index;not null"`
+	EventID       uint64         `json:"event_id"`
+	EventType     string         `json:"event_type" gorm:"index"`
+	Payload       string         `json:"payload" gorm:"type:text;not null"`
+	Status        DeliveryStatus `json:"status" gorm:"type:varchar(20);index;default:pending"`
+	Attempts      int            `json:"attempts"`
+	NextAttemptAt *time.Time     `json:"next_attempt_at,omitempty" gorm:"index"`
+	ResponseCode  int            `json:"response_code,omitempty"`
+	LastError     string         `json:"last_error,omitempty" gorm:"type:text"`
+	DeliveredAt   *time.Time     `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
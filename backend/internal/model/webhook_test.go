@@ -0,0 +1,60 @@
+package model
+
+import "testing"
+
+func TestWebhook_Matches(t *testing.T) {
+	tests := []struct {
+		name      string
+		webhook   Webhook
+		eventType string
+		labels    Labels
+		want      bool
+	}{
+		{
+			name:      "no filters matches everything",
+			webhook:   Webhook{Enabled: true},
+			eventType: "agent.connected",
+			want:      true,
+		},
+		{
+			name:      "disabled webhook never matches",
+			webhook:   Webhook{Enabled: false},
+			eventType: "agent.connected",
+			want:      false,
+		},
+		{
+			name:      "subscribed event type",
+			webhook:   Webhook{Enabled: true, EventTypes: []string{"agent.disconnected", "config.failed"}},
+			eventType: "config.failed",
+			want:      true,
+		},
+		{
+			name:      "unsubscribed event type",
+			webhook:   Webhook{Enabled: true, EventTypes: []string{"agent.disconnected"}},
+			eventType: "config.failed",
+			want:      false,
+		},
+		{
+			name:      "selector matches labels",
+			webhook:   Webhook{Enabled: true, Selector: map[string]string{"env": "prod"}},
+			eventType: "agent.disconnected",
+			labels:    Labels{"env": "prod", "region": "us"},
+			want:      true,
+		},
+		{
+			name:      "selector does not match labels",
+			webhook:   Webhook{Enabled: true, Selector: map[string]string{"env": "prod"}},
+			eventType: "agent.disconnected",
+			labels:    Labels{"env": "dev"},
+			want:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.webhook.Matches(tt.eventType, tt.labels); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
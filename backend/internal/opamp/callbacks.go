@@ -2,6 +2,7 @@ package opamp
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -11,7 +12,9 @@ import (
 	"github.com/open-telemetry/opamp-go/server/types"
 	"go.uber.org/zap"
 
+	"github.com/cc1024201/opamp-platform/internal/events"
 	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
 )
 
 const (
@@ -19,46 +22,79 @@ const (
 	headerSecretKey     = "Secret-Key"
 )
 
+var (
+	errInvalidSecretKey = errors.New("invalid secret key")
+	// errAgentQuotaExceeded 组织的 Agent 数量已达上限, 新 Agent 不能接入
+	errAgentQuotaExceeded = errors.New("agent quota exceeded")
+	// errAgentDecommissioned Agent 已退役, 不再允许连接
+	errAgentDecommissioned = errors.New("agent has been decommissioned")
+)
+
 // onConnecting 在新连接建立前调用，用于验证和授权
+//
+// 此时还不知道 Agent ID (instance UID 在第一条消息中), 已退役的 Agent 在 onMessage 中拒绝。
 func (s *opampServer) onConnecting(request *http.Request) types.ConnectionResponse {
 	s.logger.Debug("Agent connecting",
 		zap.String("remote_addr", request.RemoteAddr),
 	)
 
-	// 验证 Secret Key (如果配置了)
-	if s.config.SecretKey != "" {
-		secretKey := request.Header.Get(headerSecretKey)
-		if secretKey == "" {
-			// 尝试从 Authorization header 获取
-			auth := request.Header.Get(headerAuthorization)
-			if strings.HasPrefix(auth, "Bearer ") {
-				secretKey = strings.TrimPrefix(auth, "Bearer ")
-			}
-		}
-
-		if secretKey != s.config.SecretKey {
-			s.logger.Warn("Invalid secret key",
-				zap.String("remote_addr", request.RemoteAddr),
-			)
-			return types.ConnectionResponse{
-				Accept:         false,
-				HTTPStatusCode: http.StatusUnauthorized,
-			}
+	// 根据 Secret Key 确定 Agent 所属的组织
+	orgID, err := s.resolveOrganization(request)
+	if err != nil {
+		s.logger.Warn("Invalid secret key",
+			zap.String("remote_addr", request.RemoteAddr),
+			zap.Error(err),
+		)
+		return types.ConnectionResponse{
+			Accept:         false,
+			HTTPStatusCode: http.StatusUnauthorized,
 		}
 	}
 
-	// 返回连接回调
+	// 返回连接回调, 该连接上的所有存储操作都只作用于 Agent 所属的组织
 	return types.ConnectionResponse{
 		Accept:         true,
 		HTTPStatusCode: http.StatusOK,
 		ConnectionCallbacks: types.ConnectionCallbacks{
-			OnConnected:       s.onConnected,
-			OnMessage:         s.onMessage,
+			OnConnected: s.onConnected,
+			OnMessage: func(ctx context.Context, conn types.Connection, message *protobufs.AgentToServer) *protobufs.ServerToAgent {
+				return s.onMessage(tenant.WithOrganization(ctx, orgID), conn, message)
+			},
 			OnConnectionClose: s.onConnectionClose,
 		},
 	}
 }
 
+// resolveOrganization 根据连接使用的 Secret Key 确定组织
+//
+// 组织自己的 Secret Key 优先; 全局 Secret Key (或未配置全局 Secret Key 时不带密钥的连接)
+// 属于默认组织。
+func (s *opampServer) resolveOrganization(request *http.Request) (uint, error) {
+	secretKey := request.Header.Get(headerSecretKey)
+	if secretKey == "" {
+		// 尝试从 Authorization header 获取
+		auth := request.Header.Get(headerAuthorization)
+		if strings.HasPrefix(auth, "Bearer ") {
+			secretKey = strings.TrimPrefix(auth, "Bearer ")
+		}
+	}
+
+	if secretKey != "" {
+		org, err := s.store.GetOrganizationBySecretKey(request.Context(), secretKey)
+		if err != nil {
+			return 0, err
+		}
+		if org != nil {
+			return org.ID, nil
+		}
+	}
+
+	if s.config.SecretKey == "" || secretKey == s.config.SecretKey {
+		return model.DefaultOrganizationID, nil
+	}
+	return 0, errInvalidSecretKey
+}
+
 // onConnected 在连接成功建立后调用
 func (s *opampServer) onConnected(ctx context.Context, conn types.Connection) {
 	remoteAddr := "unknown"
@@ -88,7 +124,25 @@ func (s *opampServer) onMessage(ctx context.Context, conn types.Connection, mess
 	)
 
 	// 更新 Agent 状态
-	if err := s.updateAgentState(ctx, conn, agentIDStr, message); err != nil {
+	err := s.updateAgentState(ctx, conn, agentIDStr, message)
+	if errors.Is(err, errAgentDecommissioned) {
+		s.logger.Info("Rejecting decommissioned agent",
+			zap.String("agent_id", agentIDStr),
+		)
+		return s.rejectDecommissioned(ctx, conn, message)
+	}
+	if errors.Is(err, errAgentQuotaExceeded) {
+		s.logger.Warn("Rejecting agent, organization agent quota exceeded",
+			zap.String("agent_id", agentIDStr),
+		)
+		return &protobufs.ServerToAgent{
+			InstanceUid: message.InstanceUid,
+			ErrorResponse: &protobufs.ServerErrorResponse{
+				Type:         protobufs.ServerErrorResponseType_ServerErrorResponseType_BadRequest,
+				ErrorMessage: err.Error(),
+			},
+		}
+	} else if err != nil {
 		s.logger.Error("Failed to update agent state",
 			zap.String("agent_id", agentIDStr),
 			zap.Error(err),
@@ -129,6 +183,16 @@ func (s *opampServer) onConnectionClose(conn types.Connection) {
 		return
 	}
 
+	// 发布断开事件
+	agent, err := s.store.GetAgent(ctx, agentID)
+	if err != nil || agent == nil {
+		agent = &model.Agent{ID: agentID}
+	}
+	s.events.Publish(newAgentEvent(events.TypeAgentDisconnected, agent, map[string]interface{}{
+		"reason": "connection closed",
+	}))
+	s.events.Publish(newStatusChangedEvent(agent, model.StatusOnline, model.StatusOffline))
+
 	// 设置断开原因
 	if err := s.store.SetAgentDisconnectReason(ctx, agentID, "connection closed"); err != nil {
 		s.logger.Error("Failed to set disconnect reason",
@@ -168,19 +232,31 @@ func (s *opampServer) updateAgentState(ctx context.Context, conn types.Connectio
 		return err
 	}
 
+	if agent != nil && agent.IsDecommissioned() {
+		return errAgentDecommissioned
+	}
+
 	isNewAgent := (agent == nil)
 	wasOffline := false
+	previousStatus := model.StatusOffline
+	var pendingEvents []events.Event
 
 	if agent == nil {
-		// Agent 不存在,创建新的
+		// Agent 不存在,检查组织的 Agent 数量上限后创建新的
+		if err := s.checkAgentQuota(ctx); err != nil {
+			return err
+		}
+		orgID, _ := tenant.OrganizationID(ctx)
 		agent = &model.Agent{
-			ID:       agentID,
-			Protocol: "opamp",
-			Status:   model.StatusOffline, // 初始为离线,后面会更新
-			Labels:   make(model.Labels),
+			ID:             agentID,
+			OrganizationID: orgID,
+			Protocol:       "opamp",
+			Status:         model.StatusOffline, // 初始为离线,后面会更新
+			Labels:         make(model.Labels),
 		}
 	} else {
 		wasOffline = (agent.Status == model.StatusOffline)
+		previousStatus = agent.Status
 	}
 
 	// 更新基本信息
@@ -203,10 +279,16 @@ func (s *opampServer) updateAgentState(ctx context.Context, conn types.Connectio
 			}
 		}
 
-		// 提取非标识属性作为标签
+		// 非标识属性作为上报标签, 整体替换上次上报的标签, 服务端标签不受影响
+		reported := make(model.Labels, len(desc.NonIdentifyingAttributes))
 		for _, attr := range desc.NonIdentifyingAttributes {
-			agent.Labels[attr.Key] = attr.Value.GetStringValue()
+			reported[attr.Key] = attr.Value.GetStringValue()
 		}
+		agent.ReportedLabels = reported
+	}
+	// 每条消息都重新合并, 使并发修改的服务端标签在下一条消息时生效
+	if agent.ReportedLabels != nil {
+		agent.RefreshLabels()
 	}
 
 	// 更新连接状态
@@ -239,6 +321,10 @@ func (s *opampServer) updateAgentState(ctx context.Context, conn types.Connectio
 				zap.Error(err),
 			)
 		}
+
+		pendingEvents = append(pendingEvents, newAgentEvent(events.TypeAgentConnected, agent, map[string]interface{}{
+			"remote_addr": remoteAddr,
+		}))
 	} else if agent.Status == model.StatusOnline {
 		// 已经在线,只更新最后心跳时间
 		agent.LastSeenAt = &now
@@ -254,15 +340,39 @@ func (s *opampServer) updateAgentState(ctx context.Context, conn types.Connectio
 			agent.Status = model.StatusError
 			// 更新应用历史记录为失败状态
 			s.updateApplyHistoryStatus(ctx, agentID, configHash, model.ApplyStatusFailed, status.ErrorMessage)
+			pendingEvents = append(pendingEvents, newAgentEvent(events.TypeConfigFailed, agent, map[string]interface{}{
+				"config_hash":   configHash,
+				"error_message": status.ErrorMessage,
+			}))
 		} else if status.Status == protobufs.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED {
 			// 配置应用成功
 			s.updateApplyHistoryStatus(ctx, agentID, configHash, model.ApplyStatusApplied, "")
+			pendingEvents = append(pendingEvents, newAgentEvent(events.TypeConfigApplied, agent, map[string]interface{}{
+				"config_hash": configHash,
+			}))
+		}
+	}
+
+	// 检查健康状态
+	if message.Health != nil {
+		if e := unhealthyEvent(agent, message.Health); e != nil {
+			pendingEvents = append(pendingEvents, *e)
 		}
 	}
 
+	// 检查软件包状态
+	if message.PackageStatuses != nil {
+		pendingEvents = append(pendingEvents, packageInstalledEvents(agent, message.PackageStatuses)...)
+	}
+
 	// 更新序列号
 	agent.SequenceNumber = message.SequenceNum
 
+	// 更新能力位, 未上报时保留原值
+	if message.Capabilities != 0 {
+		agent.Capabilities = message.Capabilities
+	}
+
 	// 保存 Agent
 	if err := s.store.UpsertAgent(ctx, agent); err != nil {
 		return err
@@ -271,6 +381,53 @@ func (s *opampServer) updateAgentState(ctx context.Context, conn types.Connectio
 	// 注册连接
 	s.connections.addConnection(agentID, conn)
 
+	// Agent 保存成功后再发布事件
+	if previousStatus != agent.Status {
+		pendingEvents = append(pendingEvents, newStatusChangedEvent(agent, previousStatus, agent.Status))
+	}
+	for _, e := range pendingEvents {
+		s.events.Publish(e)
+	}
+
+	return nil
+}
+
+// rejectDecommissioned 拒绝已退役的 Agent
+//
+// WebSocket 连接发送错误响应后立即关闭; 普通 HTTP 连接无法主动关闭, 只返回错误响应。
+func (s *opampServer) rejectDecommissioned(ctx context.Context, conn types.Connection, message *protobufs.AgentToServer) *protobufs.ServerToAgent {
+	response := &protobufs.ServerToAgent{
+		InstanceUid: message.InstanceUid,
+		ErrorResponse: &protobufs.ServerErrorResponse{
+			Type:         protobufs.ServerErrorResponseType_ServerErrorResponseType_BadRequest,
+			ErrorMessage: errAgentDecommissioned.Error(),
+		},
+	}
+	if err := conn.Send(ctx, response); err != nil {
+		return response
+	}
+	_ = conn.Disconnect()
+	return nil
+}
+
+// checkAgentQuota 检查 context 所属组织是否还能接入新的 Agent
+func (s *opampServer) checkAgentQuota(ctx context.Context) error {
+	orgID, ok := tenant.OrganizationID(ctx)
+	if !ok {
+		return nil
+	}
+	org, err := s.store.GetOrganization(ctx, orgID)
+	if err != nil || org == nil || org.MaxAgents <= 0 {
+		return err
+	}
+
+	count, err := s.store.CountAgents(ctx)
+	if err != nil {
+		return err
+	}
+	if !org.AllowsAgents(count, 1) {
+		return errAgentQuotaExceeded
+	}
 	return nil
 }
 
@@ -338,11 +495,40 @@ func (s *opampServer) checkAndSendConfig(ctx context.Context, agentID string, me
 		return nil
 	}
 
+	// 抖动中的 Agent 暂缓推送, 等待其稳定后再发送
+	if s.config.Flapping.HoldConfig {
+		agent, err := s.store.GetAgent(ctx, agentID)
+		if err == nil && s.HoldsConfig(agent) {
+			s.logger.Info("Holding back configuration for flapping agent",
+				zap.String("agent_id", agentID),
+				zap.String("config_name", config.Name),
+			)
+			if s.config.Metrics != nil {
+				s.config.Metrics.ConfigurationPushTotal.WithLabelValues("held").Inc()
+			}
+			return nil
+		}
+	}
+
 	s.logger.Info("Sending new configuration to agent",
 		zap.String("agent_id", agentID),
 		zap.String("config_name", config.Name),
 		zap.String("config_hash", config.ConfigHash),
 	)
+	s.audit(ctx, &model.AuditLog{
+		OrganizationID: config.OrganizationID,
+		Actor:          "opamp",
+		Action:         "agents.config.send",
+		TargetType:     "agents",
+		TargetID:       agentID,
+		Changes: map[string]model.AuditChange{
+			"config_hash": {Before: currentHash, After: config.ConfigHash},
+		},
+		Details: map[string]interface{}{
+			"configuration": config.Name,
+			"version":       config.Version,
+		},
+	})
 
 	// 构建配置消息
 	response := &protobufs.ServerToAgent{
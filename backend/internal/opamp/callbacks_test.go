@@ -8,6 +8,7 @@ import (
 	"github.com/open-telemetry/opamp-go/protobufs"
 	"go.uber.org/zap"
 
+	"github.com/cc1024201/opamp-platform/internal/events"
 	"github.com/cc1024201/opamp-platform/internal/model"
 )
 
@@ -28,8 +29,9 @@ func TestUpdateAgentState_NewAgent(t *testing.T) {
 
 	agentUUID := uuid.MustParse(agentID)
 	message := &protobufs.AgentToServer{
-		InstanceUid: agentUUID[:],
-		SequenceNum: 1,
+		InstanceUid:  agentUUID[:],
+		SequenceNum:  1,
+		Capabilities: uint64(protobufs.AgentCapabilities_AgentCapabilities_AcceptsRemoteConfig),
 		AgentDescription: &protobufs.AgentDescription{
 			IdentifyingAttributes: []*protobufs.KeyValue{
 				{
@@ -125,6 +127,9 @@ func TestUpdateAgentState_NewAgent(t *testing.T) {
 	if agent.Type != "linux" {
 		t.Errorf("Type = %v, want linux", agent.Type)
 	}
+	if agent.Capabilities != uint64(protobufs.AgentCapabilities_AgentCapabilities_AcceptsRemoteConfig) {
+		t.Errorf("Capabilities = %v, want AcceptsRemoteConfig", agent.Capabilities)
+	}
 
 	// Verify non-identifying attributes (labels)
 	if agent.Labels["env"] != "production" {
@@ -172,7 +177,10 @@ func TestUpdateAgentState_ExistingAgent(t *testing.T) {
 		Version:  "0.5.0",
 		Status:   model.StatusOffline,
 		Protocol: "opamp",
-		Labels:   model.Labels{"old-label": "old-value"},
+		Labels:   model.Labels{"old-label": "old-value", "team": "payments"},
+
+		ReportedLabels: model.Labels{"old-label": "old-value"},
+		ServerLabels:   model.Labels{"team": "payments"},
 	}
 	store.UpsertAgent(ctx, existingAgent)
 
@@ -241,6 +249,14 @@ func TestUpdateAgentState_ExistingAgent(t *testing.T) {
 	if agent.Labels["new-label"] != "new-value" {
 		t.Errorf("Label new-label = %v, want new-value", agent.Labels["new-label"])
 	}
+	if _, ok := agent.Labels["old-label"]; ok {
+		t.Errorf("Label old-label should be removed, labels = %v", agent.Labels)
+	}
+
+	// Server-assigned labels survive agent reports
+	if agent.Labels["team"] != "payments" {
+		t.Errorf("Label team = %v, want payments", agent.Labels["team"])
+	}
 }
 
 func TestUpdateAgentState_ConfigFailure(t *testing.T) {
@@ -480,3 +496,64 @@ func TestOnConnectionClose_NonExistentAgent(t *testing.T) {
 	// Connection not in manager - should not panic
 	opampSrv.onConnectionClose(conn)
 }
+
+func TestUpdateAgentState_PublishesEvents(t *testing.T) {
+	logger := zap.NewNop()
+	store := newMockAgentStore()
+	bus := events.NewBus(100)
+	config := Config{Endpoint: "/v1/opamp", EventBus: bus}
+
+	server, err := NewServer(config, store, logger)
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+
+	sub, _ := bus.Subscribe(events.Filter{}, 0)
+	defer bus.Unsubscribe(sub)
+
+	opampSrv := server.(*opampServer)
+	ctx := context.Background()
+	agentID := uuid.New().String()
+	conn := newMockConnection("conn-1")
+
+	agentUUID := uuid.MustParse(agentID)
+	message := &protobufs.AgentToServer{
+		InstanceUid: agentUUID[:],
+		SequenceNum: 1,
+		RemoteConfigStatus: &protobufs.RemoteConfigStatus{
+			LastRemoteConfigHash: []byte("hash-1"),
+			Status:               protobufs.RemoteConfigStatuses_RemoteConfigStatuses_APPLIED,
+		},
+	}
+
+	if err := opampSrv.updateAgentState(ctx, conn, agentID, message); err != nil {
+		t.Fatalf("updateAgentState() failed: %v", err)
+	}
+
+	var got []events.Type
+	for len(sub.C) > 0 {
+		e := <-sub.C
+		if e.AgentID != agentID {
+			t.Errorf("AgentID = %v, want %v", e.AgentID, agentID)
+		}
+		got = append(got, e.Type)
+	}
+
+	want := []events.Type{events.TypeAgentConnected, events.TypeConfigApplied, events.TypeAgentStatusChanged}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("events[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// 断开连接后应发布断开事件
+	opampSrv.onConnectionClose(conn)
+
+	e := <-sub.C
+	if e.Type != events.TypeAgentDisconnected {
+		t.Errorf("Type = %v, want %v", e.Type, events.TypeAgentDisconnected)
+	}
+}
@@ -0,0 +1,39 @@
+package opamp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/open-telemetry/opamp-go/protobufs"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// capabilityPrefix AgentCapabilities 枚举名称的公共前缀
+const capabilityPrefix = "AgentCapabilities_"
+
+// ParseCapability 将能力名称解析为 AgentCapabilities 能力位
+//
+// 名称不区分大小写, 可以省略前缀并使用下划线分隔, 例如 AcceptsRemoteConfig、
+// accepts_remote_config 和 AgentCapabilities_AcceptsRemoteConfig 等价。
+func ParseCapability(name string) (uint64, error) {
+	normalized := normalizeCapability(name)
+	for enumName, value := range protobufs.AgentCapabilities_value {
+		if value != 0 && normalizeCapability(enumName) == normalized {
+			return uint64(value), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown capability: %s", name)
+}
+
+// normalizeCapability 去掉前缀和下划线并转为小写
+func normalizeCapability(name string) string {
+	name = strings.TrimPrefix(name, capabilityPrefix)
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// AcceptsRestart 检查 Agent 是否上报了接受重启命令的能力
+func AcceptsRestart(agent *model.Agent) bool {
+	capability := uint64(protobufs.AgentCapabilities_AgentCapabilities_AcceptsRestartCommand)
+	return agent.Capabilities&capability != 0
+}
@@ -0,0 +1,41 @@
+package opamp
+
+import (
+	"testing"
+
+	"github.com/open-telemetry/opamp-go/protobufs"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+func TestParseCapability(t *testing.T) {
+	want := uint64(protobufs.AgentCapabilities_AgentCapabilities_AcceptsRemoteConfig)
+	for _, name := range []string{"AcceptsRemoteConfig", "accepts_remote_config", "AgentCapabilities_AcceptsRemoteConfig"} {
+		got, err := ParseCapability(name)
+		if err != nil {
+			t.Errorf("ParseCapability(%q) error = %v", name, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseCapability(%q) = %d, want %d", name, got, want)
+		}
+	}
+
+	for _, name := range []string{"", "unspecified", "flies"} {
+		if _, err := ParseCapability(name); err == nil {
+			t.Errorf("ParseCapability(%q) should fail", name)
+		}
+	}
+}
+
+func TestAcceptsRestart(t *testing.T) {
+	agent := &model.Agent{Capabilities: uint64(protobufs.AgentCapabilities_AgentCapabilities_ReportsStatus)}
+	if AcceptsRestart(agent) {
+		t.Error("AcceptsRestart() should be false without AcceptsRestartCommand")
+	}
+
+	agent.Capabilities |= uint64(protobufs.AgentCapabilities_AgentCapabilities_AcceptsRestartCommand)
+	if !AcceptsRestart(agent) {
+		t.Error("AcceptsRestart() should be true with AcceptsRestartCommand")
+	}
+}
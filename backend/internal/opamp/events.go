@@ -0,0 +1,66 @@
+package opamp
+
+import (
+	"sort"
+
+	"github.com/open-telemetry/opamp-go/protobufs"
+
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// newAgentEvent 构建与 Agent 相关的事件
+func newAgentEvent(eventType events.Type, agent *model.Agent, data map[string]interface{}) events.Event {
+	return events.Event{
+		Type:           eventType,
+		OrganizationID: agent.OrganizationID,
+		AgentID:        agent.ID,
+		Labels:         agent.Labels.Merge(nil), // 复制一份, 避免后续修改影响已发布的事件
+		Data:           data,
+	}
+}
+
+// newStatusChangedEvent 构建 Agent 状态变更事件
+func newStatusChangedEvent(agent *model.Agent, from, to model.AgentStatus) events.Event {
+	return newAgentEvent(events.TypeAgentStatusChanged, agent, map[string]interface{}{
+		"from": from,
+		"to":   to,
+	})
+}
+
+// packageInstalledEvents 从 Agent 上报的包状态中提取已安装的包事件
+func packageInstalledEvents(agent *model.Agent, statuses *protobufs.PackageStatuses) []events.Event {
+	var result []events.Event
+	for name, status := range statuses.GetPackages() {
+		if status.GetStatus() != protobufs.PackageStatusEnum_PackageStatusEnum_Installed {
+			continue
+		}
+		result = append(result, newAgentEvent(events.TypePackageInstalled, agent, map[string]interface{}{
+			"package": name,
+			"version": status.GetAgentHasVersion(),
+		}))
+	}
+	return result
+}
+
+// unhealthyEvent 根据 Agent 上报的健康状态构建不健康事件, 健康时返回 nil
+func unhealthyEvent(agent *model.Agent, health *protobufs.ComponentHealth) *events.Event {
+	if health.GetHealthy() {
+		return nil
+	}
+
+	var components []string
+	for name, component := range health.GetComponentHealthMap() {
+		if !component.GetHealthy() {
+			components = append(components, name)
+		}
+	}
+	sort.Strings(components)
+
+	e := newAgentEvent(events.TypeAgentUnhealthy, agent, map[string]interface{}{
+		"status":               health.GetStatus(),
+		"last_error":           health.GetLastError(),
+		"unhealthy_components": components,
+	})
+	return &e
+}
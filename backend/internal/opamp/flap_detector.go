@@ -0,0 +1,234 @@
+package opamp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/metrics"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// FlappingConfig 抖动检测配置
+type FlappingConfig struct {
+	Enabled            bool          // 是否启用抖动检测
+	CheckInterval      time.Duration // 检测间隔
+	Window             time.Duration // 统计窗口
+	MaxConnections     int           // 窗口内连接次数超过该值视为抖动
+	MinSessionDuration time.Duration // 窗口内已结束会话的平均时长低于该值视为抖动
+	HoldConfig         bool          // 是否暂缓向抖动中的 Agent 推送新配置
+}
+
+// FlapDetector 根据连接历史检测频繁重连的 Agent
+//
+// 检测窗口本身提供了滞后效果: Agent 只有在整个窗口内都保持稳定后才会被清除抖动标记。
+type FlapDetector struct {
+	store   AgentStore
+	logger  *zap.Logger
+	metrics *metrics.Metrics
+	events  *events.Bus
+	config  FlappingConfig
+	auditor Auditor
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewFlapDetector 创建新的抖动检测器
+func NewFlapDetector(store AgentStore, logger *zap.Logger, m *metrics.Metrics, bus *events.Bus, config FlappingConfig) *FlapDetector {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	// 默认值
+	if config.CheckInterval == 0 {
+		config.CheckInterval = 1 * time.Minute
+	}
+	if config.Window == 0 {
+		config.Window = 15 * time.Minute
+	}
+	if config.MaxConnections == 0 {
+		config.MaxConnections = 5
+	}
+	if config.MinSessionDuration == 0 {
+		config.MinSessionDuration = 1 * time.Minute
+	}
+
+	return &FlapDetector{
+		store:   store,
+		logger:  logger,
+		metrics: m,
+		events:  bus,
+		config:  config,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Start 启动抖动检测
+func (d *FlapDetector) Start(ctx context.Context) {
+	d.logger.Info("starting flap detector",
+		zap.Duration("check_interval", d.config.CheckInterval),
+		zap.Duration("window", d.config.Window),
+		zap.Int("max_connections", d.config.MaxConnections),
+		zap.Duration("min_session_duration", d.config.MinSessionDuration))
+
+	d.wg.Add(1)
+	go d.run(ctx)
+}
+
+// Stop 停止抖动检测
+func (d *FlapDetector) Stop() {
+	d.logger.Info("stopping flap detector")
+	close(d.stopCh)
+	d.wg.Wait()
+}
+
+// run 执行抖动检测循环
+func (d *FlapDetector) run(ctx context.Context) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.config.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.detect(ctx)
+		case <-d.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// detect 分析窗口内的连接历史并更新 Agent 的抖动标记
+func (d *FlapDetector) detect(ctx context.Context) {
+	now := time.Now()
+
+	histories, err := d.store.ListConnectionHistorySince(ctx, now.Add(-d.config.Window))
+	if err != nil {
+		d.logger.Error("failed to list connection history", zap.Error(err))
+		return
+	}
+
+	flagged, err := d.store.ListFlappingAgents(ctx)
+	if err != nil {
+		d.logger.Error("failed to list flapping agents", zap.Error(err))
+		return
+	}
+
+	// 按 Agent 分组
+	byAgent := make(map[string][]*model.AgentConnectionHistory)
+	for _, h := range histories {
+		byAgent[h.AgentID] = append(byAgent[h.AgentID], h)
+	}
+
+	flapping := make(map[string]string) // agentID -> reason
+	for agentID, agentHistories := range byAgent {
+		if reason, ok := d.evaluate(agentHistories); ok {
+			flapping[agentID] = reason
+		}
+	}
+
+	wasFlapping := make(map[string]bool, len(flagged))
+	for _, agent := range flagged {
+		wasFlapping[agent.ID] = true
+		if _, still := flapping[agent.ID]; !still {
+			d.transition(ctx, agent.ID, false, "stable for "+d.config.Window.String())
+		}
+	}
+
+	for agentID, reason := range flapping {
+		if !wasFlapping[agentID] {
+			d.transition(ctx, agentID, true, reason)
+		}
+	}
+
+	// 更新 metrics: 抖动中的 Agent 数量
+	if d.metrics != nil {
+		d.metrics.AgentsFlapping.Set(float64(len(flapping)))
+	}
+}
+
+// evaluate 判断单个 Agent 窗口内的连接记录是否构成抖动, 返回原因
+func (d *FlapDetector) evaluate(histories []*model.AgentConnectionHistory) (string, bool) {
+	if len(histories) > d.config.MaxConnections {
+		return fmt.Sprintf("%d connections in %s", len(histories), d.config.Window), true
+	}
+
+	// 只统计已结束的会话, 当前仍在线的会话时长尚未确定
+	var closed int
+	var total time.Duration
+	for _, h := range histories {
+		if h.DisconnectedAt == nil {
+			continue
+		}
+		closed++
+		total += h.DisconnectedAt.Sub(h.ConnectedAt)
+	}
+
+	if closed >= 2 {
+		average := total / time.Duration(closed)
+		if average < d.config.MinSessionDuration {
+			return fmt.Sprintf("average session duration %s over %d sessions", average.Round(time.Second), closed), true
+		}
+	}
+
+	return "", false
+}
+
+// transition 更新 Agent 的抖动标记并发布事件
+func (d *FlapDetector) transition(ctx context.Context, agentID string, flapping bool, reason string) {
+	if err := d.store.SetAgentFlapping(ctx, agentID, flapping); err != nil {
+		d.logger.Error("failed to set agent flapping",
+			zap.String("agent_id", agentID),
+			zap.Error(err))
+		return
+	}
+
+	if flapping {
+		d.logger.Warn("agent is flapping",
+			zap.String("agent_id", agentID),
+			zap.String("reason", reason))
+	} else {
+		d.logger.Info("agent stopped flapping", zap.String("agent_id", agentID))
+	}
+
+	// 更新 metrics: 状态转换
+	if d.metrics != nil {
+		state := "stable"
+		if flapping {
+			state = "flapping"
+		}
+		d.metrics.AgentFlappingTransitions.WithLabelValues(state).Inc()
+	}
+
+	// 发布事件
+	agent, err := d.store.GetAgent(ctx, agentID)
+	if err != nil || agent == nil {
+		agent = &model.Agent{ID: agentID}
+	}
+	d.events.Publish(newAgentEvent(events.TypeAgentFlapping, agent, map[string]interface{}{
+		"flapping": flapping,
+		"reason":   reason,
+	}))
+
+	// 记录审计日志
+	if d.auditor != nil {
+		d.auditor.Record(ctx, &model.AuditLog{
+			OrganizationID: agent.OrganizationID,
+			Actor:          "opamp",
+			Action:         "agents.flapping.update",
+			TargetType:     "agents",
+			TargetID:       agentID,
+			Changes: map[string]model.AuditChange{
+				"flapping": {Before: !flapping, After: flapping},
+			},
+			Details: map[string]interface{}{"reason": reason},
+		})
+	}
+}
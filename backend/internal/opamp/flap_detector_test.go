@@ -0,0 +1,160 @@
+package opamp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/open-telemetry/opamp-go/protobufs"
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// session 构造一条连接记录, duration 为 0 表示仍在线
+func session(agentID string, connectedAt time.Time, duration time.Duration) *model.AgentConnectionHistory {
+	h := &model.AgentConnectionHistory{AgentID: agentID, ConnectedAt: connectedAt}
+	if duration > 0 {
+		disconnectedAt := connectedAt.Add(duration)
+		h.DisconnectedAt = &disconnectedAt
+	}
+	return h
+}
+
+func TestFlapDetector_Evaluate(t *testing.T) {
+	detector := NewFlapDetector(newMockAgentStore(), zap.NewNop(), nil, nil, FlappingConfig{
+		Window:             15 * time.Minute,
+		MaxConnections:     3,
+		MinSessionDuration: time.Minute,
+	})
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		histories []*model.AgentConnectionHistory
+		want      bool
+	}{
+		{
+			name:      "single long session",
+			histories: []*model.AgentConnectionHistory{session("a", now.Add(-10*time.Minute), 0)},
+			want:      false,
+		},
+		{
+			name: "too many connections",
+			histories: []*model.AgentConnectionHistory{
+				session("a", now.Add(-14*time.Minute), 3*time.Minute),
+				session("a", now.Add(-10*time.Minute), 3*time.Minute),
+				session("a", now.Add(-6*time.Minute), 3*time.Minute),
+				session("a", now.Add(-2*time.Minute), 0),
+			},
+			want: true,
+		},
+		{
+			name: "short sessions",
+			histories: []*model.AgentConnectionHistory{
+				session("a", now.Add(-5*time.Minute), 10*time.Second),
+				session("a", now.Add(-3*time.Minute), 20*time.Second),
+				session("a", now.Add(-1*time.Minute), 0),
+			},
+			want: true,
+		},
+		{
+			name: "one short session is not enough",
+			histories: []*model.AgentConnectionHistory{
+				session("a", now.Add(-5*time.Minute), 10*time.Second),
+				session("a", now.Add(-1*time.Minute), 0),
+			},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, got := detector.evaluate(tt.histories)
+			if got != tt.want {
+				t.Errorf("evaluate() = %v (%q), want %v", got, reason, tt.want)
+			}
+			if got && reason == "" {
+				t.Error("expected a reason for flapping agent")
+			}
+		})
+	}
+}
+
+func TestFlapDetector_Detect(t *testing.T) {
+	store := newMockAgentStore()
+	bus := events.NewBus(100)
+	now := time.Now()
+
+	store.agents["flappy"] = &model.Agent{ID: "flappy"}
+	store.agents["recovered"] = &model.Agent{ID: "recovered", Flapping: true}
+	store.agents["stable"] = &model.Agent{ID: "stable"}
+	store.histories = []*model.AgentConnectionHistory{
+		session("flappy", now.Add(-4*time.Minute), 5*time.Second),
+		session("flappy", now.Add(-3*time.Minute), 5*time.Second),
+		session("flappy", now.Add(-2*time.Minute), 5*time.Second),
+		session("stable", now.Add(-10*time.Minute), 0),
+	}
+
+	sub, _ := bus.Subscribe(events.Filter{Types: []events.Type{events.TypeAgentFlapping}}, 0)
+	defer bus.Unsubscribe(sub)
+
+	detector := NewFlapDetector(store, zap.NewNop(), nil, bus, FlappingConfig{})
+	detector.detect(context.Background())
+
+	if !store.agents["flappy"].Flapping {
+		t.Error("flappy agent should be marked as flapping")
+	}
+	if store.agents["recovered"].Flapping {
+		t.Error("recovered agent should no longer be flapping")
+	}
+	if store.agents["stable"].Flapping {
+		t.Error("stable agent should not be flapping")
+	}
+
+	if len(sub.C) != 2 {
+		t.Fatalf("expected 2 flapping events, got %d", len(sub.C))
+	}
+	for len(sub.C) > 0 {
+		e := <-sub.C
+		flapping := e.Data["flapping"].(bool)
+		if flapping != (e.AgentID == "flappy") {
+			t.Errorf("event for %s has flapping = %v", e.AgentID, flapping)
+		}
+	}
+}
+
+func TestCheckAndSendConfig_HoldsConfigForFlappingAgent(t *testing.T) {
+	store := newMockAgentStore()
+	config := Config{Endpoint: "/v1/opamp", Flapping: FlappingConfig{HoldConfig: true}}
+
+	server, err := NewServer(config, store, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+
+	opampSrv := server.(*opampServer)
+	agentID := uuid.New().String()
+	store.agents[agentID] = &model.Agent{ID: agentID, Flapping: true}
+	store.configurations[agentID] = &model.Configuration{Name: "test-config", ConfigHash: "new-hash"}
+
+	agentUUID := uuid.MustParse(agentID)
+	message := &protobufs.AgentToServer{
+		InstanceUid: agentUUID[:],
+		RemoteConfigStatus: &protobufs.RemoteConfigStatus{
+			LastRemoteConfigHash: []byte("old-hash"),
+		},
+	}
+
+	if response := opampSrv.checkAndSendConfig(context.Background(), agentID, message); response != nil {
+		t.Error("expected configuration to be held back for flapping agent")
+	}
+
+	// Agent 稳定后恢复推送
+	store.agents[agentID].Flapping = false
+	if response := opampSrv.checkAndSendConfig(context.Background(), agentID, message); response == nil {
+		t.Error("expected configuration to be sent once agent is stable")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/cc1024201/opamp-platform/internal/events"
 	"github.com/cc1024201/opamp-platform/internal/metrics"
 	"github.com/cc1024201/opamp-platform/internal/model"
 )
@@ -16,6 +17,7 @@ type HeartbeatMonitor struct {
 	store         AgentStore
 	logger        *zap.Logger
 	metrics       *metrics.Metrics
+	events        *events.Bus
 	checkInterval time.Duration
 	timeout       time.Duration
 	stopCh        chan struct{}
@@ -23,7 +25,7 @@ type HeartbeatMonitor struct {
 }
 
 // NewHeartbeatMonitor 创建新的心跳监控器
-func NewHeartbeatMonitor(store AgentStore, logger *zap.Logger, m *metrics.Metrics, checkInterval, timeout time.Duration) *HeartbeatMonitor {
+func NewHeartbeatMonitor(store AgentStore, logger *zap.Logger, m *metrics.Metrics, bus *events.Bus, checkInterval, timeout time.Duration) *HeartbeatMonitor {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
@@ -40,6 +42,7 @@ func NewHeartbeatMonitor(store AgentStore, logger *zap.Logger, m *metrics.Metric
 		store:         store,
 		logger:        logger,
 		metrics:       m,
+		events:        bus,
 		checkInterval: checkInterval,
 		timeout:       timeout,
 		stopCh:        make(chan struct{}),
@@ -131,6 +134,12 @@ func (m *HeartbeatMonitor) handleHeartbeatTimeout(ctx context.Context, agent *mo
 		m.metrics.AgentsByStatus.WithLabelValues(string(model.StatusOnline)).Dec()
 	}
 
+	// 发布事件
+	m.events.Publish(newAgentEvent(events.TypeAgentHeartbeatTimeout, agent, map[string]interface{}{
+		"last_seen_at": agent.LastSeenAt,
+	}))
+	m.events.Publish(newStatusChangedEvent(agent, model.StatusOnline, model.StatusOffline))
+
 	// 设置断开原因
 	reason := "heartbeat timeout"
 	if err := m.store.SetAgentDisconnectReason(ctx, agent.ID, reason); err != nil {
@@ -161,7 +170,7 @@ func (m *HeartbeatMonitor) handleHeartbeatTimeout(ctx context.Context, agent *mo
 		// 更新 metrics: 记录连接时长
 		if m.metrics != nil && !activeHistory.ConnectedAt.IsZero() {
 			duration := now.Sub(activeHistory.ConnectedAt).Seconds()
-			m.metrics.AgentConnectionDuration.WithLabelValues(agent.ID).Observe(duration)
+			m.metrics.AgentConnectionDuration.Observe(duration)
 		}
 	}
 
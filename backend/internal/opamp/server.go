@@ -12,6 +12,8 @@ import (
 	"github.com/open-telemetry/opamp-go/server/types"
 	"go.uber.org/zap"
 
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/metrics"
 	"github.com/cc1024201/opamp-platform/internal/model"
 )
 
@@ -27,12 +29,25 @@ type Server interface {
 	Connected(agentID string) bool
 	// SendUpdate 向 Agent 发送更新
 	SendUpdate(ctx context.Context, agentID string, update *model.AgentUpdate) error
+	// Disconnect 关闭 Agent 的连接, Agent 未连接时不做任何操作
+	Disconnect(agentID string) error
+	// HoldsConfig 检查是否应暂缓向 Agent 推送新配置 (例如 Agent 正在抖动)
+	HoldsConfig(agent *model.Agent) bool
 }
 
 // Config OpAMP 服务器配置
 type Config struct {
-	Endpoint  string // OpAMP 端点路径
-	SecretKey string // Secret Key (为空则不验证)
+	Endpoint  string           // OpAMP 端点路径
+	SecretKey string           // 默认组织的全局 Secret Key (为空则不验证), 组织也可以有自己的 Secret Key
+	EventBus  *events.Bus      // 事件总线 (为空则不发布事件)
+	Metrics   *metrics.Metrics // 监控指标 (为空则不记录)
+	Flapping  FlappingConfig   // 抖动检测配置
+	Auditor   Auditor          // 审计日志 (为空则不记录)
+}
+
+// Auditor 记录 OpAMP 服务器自身执行的管理操作 (下发配置、标记抖动等)
+type Auditor interface {
+	Record(ctx context.Context, entry *model.AuditLog)
 }
 
 // AgentStore 定义 Agent 存储接口
@@ -58,6 +73,16 @@ type AgentStore interface {
 	CreateConnectionHistory(ctx context.Context, history *model.AgentConnectionHistory) error
 	UpdateConnectionHistory(ctx context.Context, history *model.AgentConnectionHistory) error
 	GetActiveConnectionHistory(ctx context.Context, agentID string) (*model.AgentConnectionHistory, error)
+
+	// 抖动检测
+	ListConnectionHistorySince(ctx context.Context, since time.Time) ([]*model.AgentConnectionHistory, error)
+	ListFlappingAgents(ctx context.Context) ([]*model.Agent, error)
+	SetAgentFlapping(ctx context.Context, agentID string, flapping bool) error
+
+	// 多租户
+	GetOrganization(ctx context.Context, id uint) (*model.Organization, error)
+	GetOrganizationBySecretKey(ctx context.Context, secretKey string) (*model.Organization, error)
+	CountAgents(ctx context.Context) (int64, error)
 }
 
 type opampServer struct {
@@ -66,8 +91,10 @@ type opampServer struct {
 	server           server.OpAMPServer
 	handler          server.HTTPHandlerFunc
 	store            AgentStore
+	events           *events.Bus
 	connections      *connectionManager
 	heartbeatMonitor *HeartbeatMonitor
+	flapDetector     *FlapDetector
 }
 
 // NewServer 创建新的 OpAMP 服务器
@@ -80,6 +107,7 @@ func NewServer(config Config, store AgentStore, logger *zap.Logger) (Server, err
 		config:      config,
 		logger:      logger,
 		store:       store,
+		events:      config.EventBus,
 		connections: newConnectionManager(),
 	}
 
@@ -87,11 +115,18 @@ func NewServer(config Config, store AgentStore, logger *zap.Logger) (Server, err
 	s.heartbeatMonitor = NewHeartbeatMonitor(
 		store,
 		logger,
-		nil, // metrics (暂时为 nil,后续可以添加)
+		config.Metrics,
+		config.EventBus,
 		30*time.Second, // 每 30 秒检查一次
 		60*time.Second, // 60 秒超时
 	)
 
+	// 创建抖动检测器
+	if config.Flapping.Enabled {
+		s.flapDetector = NewFlapDetector(store, logger, config.Metrics, config.EventBus, config.Flapping)
+		s.flapDetector.auditor = config.Auditor
+	}
+
 	// 创建 opamp-go 服务器
 	opampServer := server.New(newLoggerAdapter(logger))
 
@@ -117,6 +152,11 @@ func (s *opampServer) Start(ctx context.Context) error {
 	// 启动心跳监控
 	s.heartbeatMonitor.Start(ctx)
 
+	// 启动抖动检测
+	if s.flapDetector != nil {
+		s.flapDetector.Start(ctx)
+	}
+
 	return nil
 }
 
@@ -126,6 +166,11 @@ func (s *opampServer) Stop(ctx context.Context) error {
 	// 停止心跳监控
 	s.heartbeatMonitor.Stop()
 
+	// 停止抖动检测
+	if s.flapDetector != nil {
+		s.flapDetector.Stop()
+	}
+
 	return s.server.Stop(ctx)
 }
 
@@ -137,6 +182,10 @@ func (s *opampServer) Connected(agentID string) bool {
 	return s.connections.isConnected(agentID)
 }
 
+func (s *opampServer) HoldsConfig(agent *model.Agent) bool {
+	return s.config.Flapping.HoldConfig && agent != nil && agent.Flapping
+}
+
 func (s *opampServer) SendUpdate(ctx context.Context, agentID string, update *model.AgentUpdate) error {
 	conn := s.connections.getConnection(agentID)
 	if conn == nil {
@@ -161,10 +210,37 @@ func (s *opampServer) SendUpdate(ctx context.Context, agentID string, update *mo
 		msg.RemoteConfig.ConfigHash = []byte(update.Configuration.ConfigHash)
 	}
 
+	// 重启命令
+	if update.Restart {
+		msg.Command = &protobufs.ServerToAgentCommand{
+			Type: protobufs.CommandType_CommandType_Restart,
+		}
+	}
+
+	// 要求 Agent 上报完整状态
+	if update.ReportFullState {
+		msg.Flags |= uint64(protobufs.ServerToAgentFlags_ServerToAgentFlags_ReportFullState)
+	}
+
 	// 发送消息
 	return conn.Send(ctx, msg)
 }
 
+func (s *opampServer) Disconnect(agentID string) error {
+	conn := s.connections.getConnection(agentID)
+	if conn == nil {
+		return nil
+	}
+	return conn.Disconnect()
+}
+
+// audit 记录服务器执行的管理操作, 未配置审计时忽略
+func (s *opampServer) audit(ctx context.Context, entry *model.AuditLog) {
+	if s.config.Auditor != nil {
+		s.config.Auditor.Record(ctx, entry)
+	}
+}
+
 // connectionManager 管理 Agent 连接
 type connectionManager struct {
 	mu          sync.RWMutex
@@ -9,10 +9,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/open-telemetry/opamp-go/protobufs"
 	"go.uber.org/zap"
 
 	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
 )
 
 // mockConn implements net.Conn for testing
@@ -72,6 +74,8 @@ type mockAgentStore struct {
 	getAgentErr   error
 	upsertErr     error
 	getConfigErr  error
+	histories     []*model.AgentConnectionHistory
+	organizations map[uint]*model.Organization
 }
 
 func newMockAgentStore() *mockAgentStore {
@@ -174,6 +178,70 @@ func (m *mockAgentStore) GetActiveConnectionHistory(ctx context.Context, agentID
 	return nil, nil
 }
 
+// 抖动检测方法
+func (m *mockAgentStore) ListConnectionHistorySince(ctx context.Context, since time.Time) ([]*model.AgentConnectionHistory, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []*model.AgentConnectionHistory
+	for _, h := range m.histories {
+		if !h.ConnectedAt.Before(since) {
+			result = append(result, h)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAgentStore) ListFlappingAgents(ctx context.Context) ([]*model.Agent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result []*model.Agent
+	for _, agent := range m.agents {
+		if agent.Flapping {
+			result = append(result, agent)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockAgentStore) SetAgentFlapping(ctx context.Context, agentID string, flapping bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if agent, exists := m.agents[agentID]; exists {
+		agent.Flapping = flapping
+	}
+	return nil
+}
+
+func (m *mockAgentStore) GetOrganization(ctx context.Context, id uint) (*model.Organization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.organizations[id], nil
+}
+
+func (m *mockAgentStore) GetOrganizationBySecretKey(ctx context.Context, secretKey string) (*model.Organization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, org := range m.organizations {
+		if org.SecretKeyHash != nil && *org.SecretKeyHash == model.HashSecretKey(secretKey) {
+			return org, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockAgentStore) CountAgents(ctx context.Context) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	orgID, _ := tenant.OrganizationID(ctx)
+	var count int64
+	for _, agent := range m.agents {
+		if agent.OrganizationID == orgID {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func TestNewServer(t *testing.T) {
 	logger := zap.NewNop()
 	store := newMockAgentStore()
@@ -542,3 +610,112 @@ func TestStartStop(t *testing.T) {
 		t.Errorf("Stop() failed: %v", err)
 	}
 }
+
+func TestResolveOrganization(t *testing.T) {
+	store := newMockAgentStore()
+	tenantOrg := &model.Organization{ID: 2, Name: "acme"}
+	tenantOrg.SetSecretKey("acme-secret")
+	store.organizations = map[uint]*model.Organization{2: tenantOrg}
+
+	server, err := NewServer(Config{Endpoint: "/v1/opamp", SecretKey: "global-secret"}, store, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	opampSrv := server.(*opampServer)
+
+	tests := []struct {
+		name      string
+		secretKey string
+		wantOrg   uint
+		wantErr   bool
+	}{
+		{name: "organization secret key", secretKey: "acme-secret", wantOrg: 2},
+		{name: "global secret key", secretKey: "global-secret", wantOrg: model.DefaultOrganizationID},
+		{name: "unknown secret key", secretKey: "other", wantErr: true},
+		{name: "missing secret key", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/v1/opamp", nil)
+			if tt.secretKey != "" {
+				req.Header.Set(headerSecretKey, tt.secretKey)
+			}
+
+			orgID, err := opampSrv.resolveOrganization(req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveOrganization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if orgID != tt.wantOrg {
+				t.Errorf("resolveOrganization() = %v, want %v", orgID, tt.wantOrg)
+			}
+		})
+	}
+}
+
+func TestOnMessage_AgentQuota(t *testing.T) {
+	store := newMockAgentStore()
+	store.organizations = map[uint]*model.Organization{2: {ID: 2, Name: "acme", MaxAgents: 1}}
+	store.agents["existing"] = &model.Agent{ID: "existing", OrganizationID: 2, Labels: model.Labels{}}
+
+	server, err := NewServer(Config{Endpoint: "/v1/opamp"}, store, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	opampSrv := server.(*opampServer)
+
+	newAgent := []byte("0123456789abcdef")
+	message := &protobufs.AgentToServer{InstanceUid: newAgent, SequenceNum: 1}
+
+	// 组织已达到 Agent 数量上限, 新 Agent 被拒绝
+	ctx := tenant.WithOrganization(context.Background(), 2)
+	resp := opampSrv.onMessage(ctx, newMockConnection("conn-1"), message)
+	if resp == nil || resp.ErrorResponse == nil {
+		t.Fatal("expected an error response when the agent quota is exceeded")
+	}
+	if len(store.agents) != 1 {
+		t.Errorf("agents = %d, want 1", len(store.agents))
+	}
+
+	// 其他组织不受影响
+	ctx = tenant.WithOrganization(context.Background(), model.DefaultOrganizationID)
+	resp = opampSrv.onMessage(ctx, newMockConnection("conn-2"), message)
+	if resp != nil && resp.ErrorResponse != nil {
+		t.Fatalf("unexpected error response: %v", resp.ErrorResponse.ErrorMessage)
+	}
+	if len(store.agents) != 2 {
+		t.Errorf("agents = %d, want 2", len(store.agents))
+	}
+	for id, agent := range store.agents {
+		if id != "existing" && agent.OrganizationID != model.DefaultOrganizationID {
+			t.Errorf("new agent organization = %d, want %d", agent.OrganizationID, model.DefaultOrganizationID)
+		}
+	}
+}
+
+func TestOnMessage_DecommissionedAgent(t *testing.T) {
+	store := newMockAgentStore()
+	agentUUID := uuid.New()
+	agentID := agentUUID[:]
+	id := agentUUID.String()
+	decommissionedAt := time.Now()
+	store.agents[id] = &model.Agent{ID: id, Status: model.StatusOffline, DecommissionedAt: &decommissionedAt, Labels: model.Labels{}}
+
+	server, err := NewServer(Config{Endpoint: "/v1/opamp"}, store, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewServer() failed: %v", err)
+	}
+	opampSrv := server.(*opampServer)
+
+	// WebSocket 连接: 错误响应已通过连接发送, 不再返回响应
+	message := &protobufs.AgentToServer{InstanceUid: agentID, SequenceNum: 1}
+	if resp := opampSrv.onMessage(context.Background(), newMockConnection("conn-1"), message); resp != nil {
+		t.Errorf("expected no response after the connection was closed, got %v", resp)
+	}
+	if store.agents[id].Status != model.StatusOffline {
+		t.Errorf("status = %v, want %v", store.agents[id].Status, model.StatusOffline)
+	}
+	if opampSrv.Connected(id) {
+		t.Error("decommissioned agent should not be registered as connected")
+	}
+}
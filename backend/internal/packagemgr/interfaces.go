@@ -16,6 +16,13 @@ type PackageStore interface {
 	GetLatestPackage(ctx context.Context, name, platform, arch string) (*model.Package, error)
 }
 
+// QuotaStore 定义检查组织存储配额所需的接口
+type QuotaStore interface {
+	GetOrganization(ctx context.Context, id uint) (*model.Organization, error)
+	// SumPackageBytes 统计 context 所属组织的软件包占用的存储空间
+	SumPackageBytes(ctx context.Context) (int64, error)
+}
+
 // FileStorage 定义文件存储接口
 type FileStorage interface {
 	UploadFile(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error
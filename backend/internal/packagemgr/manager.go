@@ -4,20 +4,26 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 
 	"github.com/cc1024201/opamp-platform/internal/model"
 	"github.com/cc1024201/opamp-platform/internal/storage"
-	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
 	"go.uber.org/zap"
 )
 
+// ErrStorageQuotaExceeded 上传后会超出组织的软件包存储空间上限
+var ErrStorageQuotaExceeded = errors.New("package storage quota exceeded")
+
 // Manager 包管理器
 type Manager struct {
 	store   PackageStore
 	storage FileStorage
 	logger  *zap.Logger
+	quota   QuotaStore
 }
 
 // NewManager 创建包管理器
@@ -29,16 +35,28 @@ func NewManager(store PackageStore, storage FileStorage, logger *zap.Logger) *Ma
 	}
 }
 
-// NewManagerWithConcreteTypes 使用具体类型创建包管理器(保持向后兼容)
-func NewManagerWithConcreteTypes(store *postgres.Store, storage *storage.MinIOClient, logger *zap.Logger) *Manager {
+// NewManagerWithConcreteTypes 使用完整的存储接口和 MinIO 创建包管理器(保持向后兼容)
+func NewManagerWithConcreteTypes(store store.Store, storage *storage.MinIOClient, logger *zap.Logger) *Manager {
 	return NewManager(store, storage, logger)
 }
 
+// SetQuotaStore 设置配额检查, 设置后上传软件包时检查 context 所属组织的存储空间上限
+func (m *Manager) SetQuotaStore(quota QuotaStore) {
+	m.quota = quota
+}
+
 // UploadPackage 上传软件包
 func (m *Manager) UploadPackage(ctx context.Context, pkg *model.Package, reader io.Reader) error {
-	// 生成存储路径
+	if err := m.checkQuota(ctx, pkg.FileSize); err != nil {
+		return err
+	}
+
+	// 生成存储路径, 默认组织以外的组织使用各自的前缀, 避免同名软件包互相覆盖
 	objectName := fmt.Sprintf("packages/%s/%s/%s-%s/%s",
 		pkg.Name, pkg.Version, pkg.Platform, pkg.Arch, pkg.Name)
+	if orgID, ok := tenant.OrganizationID(ctx); ok && orgID != model.DefaultOrganizationID {
+		objectName = fmt.Sprintf("orgs/%d/%s", orgID, objectName)
+	}
 
 	// 读取文件内容并计算哈希
 	hash := sha256.New()
@@ -70,6 +88,31 @@ func (m *Manager) UploadPackage(ctx context.Context, pkg *model.Package, reader
 	return nil
 }
 
+// checkQuota 检查 context 所属组织是否还能再存储 size 字节
+func (m *Manager) checkQuota(ctx context.Context, size int64) error {
+	orgID, ok := tenant.OrganizationID(ctx)
+	if m.quota == nil || !ok {
+		return nil
+	}
+
+	org, err := m.quota.GetOrganization(ctx, orgID)
+	if err != nil {
+		return fmt.Errorf("failed to load organization: %w", err)
+	}
+	if org == nil || org.MaxPackageBytes <= 0 {
+		return nil
+	}
+
+	used, err := m.quota.SumPackageBytes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to compute package storage usage: %w", err)
+	}
+	if !org.AllowsPackageBytes(used, size) {
+		return ErrStorageQuotaExceeded
+	}
+	return nil
+}
+
 // DownloadPackage 下载软件包
 func (m *Manager) DownloadPackage(ctx context.Context, id uint) (io.ReadCloser, *model.Package, error) {
 	pkg, err := m.store.GetPackage(ctx, id)
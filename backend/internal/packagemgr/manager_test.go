@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
@@ -75,6 +76,20 @@ func (m *MockFileStorage) DeleteFile(ctx context.Context, objectName string) err
 	return args.Error(0)
 }
 
+// fakeQuotaStore 固定返回组织和已用空间
+type fakeQuotaStore struct {
+	org  *model.Organization
+	used int64
+}
+
+func (f *fakeQuotaStore) GetOrganization(ctx context.Context, id uint) (*model.Organization, error) {
+	return f.org, nil
+}
+
+func (f *fakeQuotaStore) SumPackageBytes(ctx context.Context) (int64, error) {
+	return f.used, nil
+}
+
 func TestNewManager(t *testing.T) {
 	mockStore := new(MockPackageStore)
 	mockStorage := new(MockFileStorage)
@@ -416,3 +431,33 @@ func TestDeletePackage_PackageNotFound(t *testing.T) {
 	mockStorage.AssertNotCalled(t, "DeleteFile")
 	mockStore.AssertNotCalled(t, "DeletePackage")
 }
+
+func TestUploadPackage_StorageQuota(t *testing.T) {
+	mockStore := new(MockPackageStore)
+	mockStorage := new(MockFileStorage)
+
+	manager := NewManager(mockStore, mockStorage, zap.NewNop())
+	manager.SetQuotaStore(&fakeQuotaStore{
+		org:  &model.Organization{ID: 2, MaxPackageBytes: 2048},
+		used: 1536,
+	})
+
+	ctx := tenant.WithOrganization(context.Background(), 2)
+
+	// 超出配额时不上传文件
+	tooLarge := &model.Package{Name: "agent", Version: "1.0.0", Platform: "linux", Arch: "amd64", FileSize: 1024}
+	err := manager.UploadPackage(ctx, tooLarge, strings.NewReader("content"))
+	assert.ErrorIs(t, err, ErrStorageQuotaExceeded)
+	mockStorage.AssertNotCalled(t, "UploadFile", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	// 配额内的软件包存储在组织自己的前缀下
+	pkg := &model.Package{Name: "agent", Version: "1.0.0", Platform: "linux", Arch: "amd64", FileSize: 512}
+	mockStorage.On("UploadFile", ctx, "orgs/2/packages/agent/1.0.0/linux-amd64/agent", mock.Anything, pkg.FileSize, "application/octet-stream").Return(nil)
+	mockStore.On("CreatePackage", ctx, pkg).Return(nil)
+
+	err = manager.UploadPackage(ctx, pkg, strings.NewReader("content"))
+	assert.NoError(t, err)
+	assert.Equal(t, "orgs/2/packages/agent/1.0.0/linux-amd64/agent", pkg.StoragePath)
+	mockStorage.AssertExpectations(t)
+	mockStore.AssertExpectations(t)
+}
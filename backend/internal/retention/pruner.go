@@ -0,0 +1,211 @@
+// Package retention 按保留策略定期清理历史表
+package retention
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/metrics"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// Store 定义清理所需的存储接口
+type Store interface {
+	CountPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) (int64, error)
+	ListPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) ([]uint, error)
+	DeleteHistoryBatch(ctx context.Context, table model.RetentionTable, ids []uint) (int64, error)
+}
+
+// Config 清理配置
+type Config struct {
+	// Interval 清理间隔
+	Interval time.Duration
+	// BatchSize 每批删除的最大记录数
+	BatchSize int
+	// BatchPause 两批之间的间隔, 减轻数据库压力
+	BatchPause time.Duration
+	// Policies 各历史表的保留策略, 未启用的策略被忽略
+	Policies []model.RetentionPolicy
+}
+
+// Pruner 定期按保留策略分批删除历史记录
+type Pruner struct {
+	store   Store
+	logger  *zap.Logger
+	metrics *metrics.Metrics
+	config  Config
+	now     func() time.Time
+	sleep   func(time.Duration)
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewPruner 创建新的清理器, m 为空时不记录指标
+func NewPruner(store Store, logger *zap.Logger, m *metrics.Metrics, config Config) *Pruner {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	// 默认值
+	if config.Interval == 0 {
+		config.Interval = time.Hour
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 1000
+	}
+
+	var policies []model.RetentionPolicy
+	for _, policy := range config.Policies {
+		if policy.Enabled() {
+			policies = append(policies, policy)
+		}
+	}
+	config.Policies = policies
+
+	return &Pruner{
+		store:   store,
+		logger:  logger,
+		metrics: m,
+		config:  config,
+		now:     time.Now,
+		sleep:   time.Sleep,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Policies 返回启用的保留策略
+func (p *Pruner) Policies() []model.RetentionPolicy {
+	return p.config.Policies
+}
+
+// Start 启动定期清理, 没有启用的策略时不做任何操作
+func (p *Pruner) Start(ctx context.Context) {
+	if len(p.config.Policies) == 0 {
+		return
+	}
+
+	p.logger.Info("starting history pruner",
+		zap.Duration("interval", p.config.Interval),
+		zap.Int("batch_size", p.config.BatchSize),
+		zap.Int("policies", len(p.config.Policies)))
+
+	p.wg.Add(1)
+	go p.run(ctx)
+}
+
+// Stop 停止清理, 正在进行的清理在当前批次结束后停止
+func (p *Pruner) Stop() {
+	if len(p.config.Policies) == 0 {
+		return
+	}
+
+	p.logger.Info("stopping history pruner")
+	close(p.stopCh)
+	p.wg.Wait()
+}
+
+// run 执行清理循环
+func (p *Pruner) run(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.Prune(ctx)
+		case <-p.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// DryRun 统计每张表按策略将要删除的记录数, 不删除任何记录
+func (p *Pruner) DryRun(ctx context.Context) []model.PruneResult {
+	ctx = tenant.WithoutOrganization(ctx)
+	now := p.now()
+
+	results := make([]model.PruneResult, 0, len(p.config.Policies))
+	for _, policy := range p.config.Policies {
+		result := model.PruneResult{Table: policy.Table}
+		count, err := p.store.CountPrunableHistory(ctx, policy, now)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		result.Rows = count
+		results = append(results, result)
+	}
+	return results
+}
+
+// Prune 按策略分批删除每张表的历史记录
+func (p *Pruner) Prune(ctx context.Context) []model.PruneResult {
+	ctx = tenant.WithoutOrganization(ctx)
+	now := p.now()
+
+	results := make([]model.PruneResult, 0, len(p.config.Policies))
+	for _, policy := range p.config.Policies {
+		result := p.prune(ctx, policy, now)
+		if result.Error != "" {
+			p.logger.Error("failed to prune history",
+				zap.String("table", string(result.Table)),
+				zap.Int64("rows", result.Rows),
+				zap.String("error", result.Error))
+		} else if result.Rows > 0 {
+			p.logger.Info("pruned history",
+				zap.String("table", string(result.Table)),
+				zap.Int64("rows", result.Rows))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// prune 分批删除一张表的历史记录, 直到删完本次计算出的候选记录或清理器停止
+//
+// 候选记录在开始时计算一次, 之后每批只按 ID 删除。
+func (p *Pruner) prune(ctx context.Context, policy model.RetentionPolicy, now time.Time) model.PruneResult {
+	result := model.PruneResult{Table: policy.Table}
+	ids, err := p.store.ListPrunableHistory(ctx, policy, now)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	for len(ids) > 0 {
+		batch := ids[:min(len(ids), p.config.BatchSize)]
+		ids = ids[len(batch):]
+
+		rows, err := p.store.DeleteHistoryBatch(ctx, policy.Table, batch)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Rows += rows
+		if p.metrics != nil && rows > 0 {
+			p.metrics.HistoryRowsPruned.WithLabelValues(string(policy.Table)).Add(float64(rows))
+		}
+		if len(ids) == 0 {
+			return result
+		}
+
+		select {
+		case <-p.stopCh:
+			return result
+		case <-ctx.Done():
+			return result
+		default:
+		}
+		if p.config.BatchPause > 0 {
+			p.sleep(p.config.BatchPause)
+		}
+	}
+	return result
+}
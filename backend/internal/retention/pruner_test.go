@@ -0,0 +1,110 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// mockStore 内存实现的 Store, 每张表剩余 remaining 条可删除的记录
+type mockStore struct {
+	remaining map[model.RetentionTable]int64
+	pruneErr  map[model.RetentionTable]error
+	lists     map[model.RetentionTable]int
+	batches   map[model.RetentionTable]int
+	now       time.Time
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{
+		remaining: make(map[model.RetentionTable]int64),
+		pruneErr:  make(map[model.RetentionTable]error),
+		lists:     make(map[model.RetentionTable]int),
+		batches:   make(map[model.RetentionTable]int),
+	}
+}
+
+func (s *mockStore) CountPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) (int64, error) {
+	s.now = now
+	return s.remaining[policy.Table], nil
+}
+
+func (s *mockStore) ListPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) ([]uint, error) {
+	s.now = now
+	if err := s.pruneErr[policy.Table]; err != nil {
+		return nil, err
+	}
+	s.lists[policy.Table]++
+	ids := make([]uint, 0, s.remaining[policy.Table])
+	for id := uint(1); id <= uint(s.remaining[policy.Table]); id++ {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *mockStore) DeleteHistoryBatch(ctx context.Context, table model.RetentionTable, ids []uint) (int64, error) {
+	s.batches[table]++
+	rows := int64(len(ids))
+	s.remaining[table] -= rows
+	return rows, nil
+}
+
+func TestPruner_Prune(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	store := newMockStore()
+	store.remaining[model.RetentionTableConnectionHistory] = 25
+	store.remaining[model.RetentionTableApplyHistory] = 5
+	store.pruneErr[model.RetentionTableConfigurationHistory] = errors.New("boom")
+
+	pruner := NewPruner(store, nil, nil, Config{
+		BatchSize: 10,
+		Policies: []model.RetentionPolicy{
+			{Table: model.RetentionTableConnectionHistory, MaxAgeSeconds: 3600},
+			{Table: model.RetentionTableApplyHistory, MaxRows: 100},
+			{Table: model.RetentionTableConfigurationHistory, MaxRows: 100},
+		},
+	})
+	pruner.now = func() time.Time { return now }
+
+	// 试运行不删除记录
+	dryRun := pruner.DryRun(context.Background())
+	require.Len(t, dryRun, 3)
+	assert.Equal(t, int64(25), dryRun[0].Rows)
+	assert.Equal(t, int64(25), store.remaining[model.RetentionTableConnectionHistory])
+
+	results := pruner.Prune(context.Background())
+	require.Len(t, results, 3)
+	assert.Equal(t, now, store.now)
+
+	// 候选记录只计算一次, 然后分批删除
+	assert.Equal(t, int64(25), results[0].Rows)
+	assert.Equal(t, 1, store.lists[model.RetentionTableConnectionHistory])
+	assert.Equal(t, 3, store.batches[model.RetentionTableConnectionHistory])
+	assert.Equal(t, int64(5), results[1].Rows)
+	assert.Equal(t, 1, store.lists[model.RetentionTableApplyHistory])
+	assert.Equal(t, 1, store.batches[model.RetentionTableApplyHistory])
+
+	// 一张表失败不影响其他表
+	assert.Equal(t, "boom", results[2].Error)
+}
+
+func TestPruner_IgnoresDisabledPolicies(t *testing.T) {
+	pruner := NewPruner(newMockStore(), nil, nil, Config{
+		Policies: []model.RetentionPolicy{
+			{Table: model.RetentionTableConnectionHistory, KeepLast: 10},
+		},
+	})
+
+	assert.Empty(t, pruner.Policies())
+	assert.Equal(t, 1000, pruner.config.BatchSize)
+
+	// 没有启用的策略时 Start 和 Stop 不做任何操作
+	pruner.Start(context.Background())
+	pruner.Stop()
+}
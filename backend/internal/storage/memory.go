@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// MemoryStorage 内存文件存储, 用于开发模式和测试, 进程退出后文件丢失
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+// NewMemoryStorage 创建内存文件存储
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string][]byte)}
+}
+
+// UploadFile 上传文件
+func (m *MemoryStorage) UploadFile(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[objectName] = data
+	return nil
+}
+
+// DownloadFile 下载文件
+func (m *MemoryStorage) DownloadFile(ctx context.Context, objectName string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	data, ok := m.files[objectName]
+	if !ok {
+		return nil, fmt.Errorf("failed to stat file: object %s does not exist", objectName)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// DeleteFile 删除文件
+func (m *MemoryStorage) DeleteFile(ctx context.Context, objectName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, objectName)
+	return nil
+}
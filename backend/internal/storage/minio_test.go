@@ -414,3 +414,20 @@ func TestConcurrentUploads(t *testing.T) {
 		_ = client.DeleteFile(ctx, objectName)
 	}
 }
+
+func TestMemoryStorage(t *testing.T) {
+	s := NewMemoryStorage()
+	ctx := context.Background()
+
+	assert.NoError(t, s.UploadFile(ctx, "agent/1.0.0/agent.tar.gz", strings.NewReader("payload"), 7, "application/gzip"))
+
+	reader, err := s.DownloadFile(ctx, "agent/1.0.0/agent.tar.gz")
+	assert.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "payload", string(data))
+
+	assert.NoError(t, s.DeleteFile(ctx, "agent/1.0.0/agent.tar.gz"))
+	_, err = s.DownloadFile(ctx, "agent/1.0.0/agent.tar.gz")
+	assert.Error(t, err)
+}
@@ -0,0 +1,248 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateConnectionHistory 创建连接历史记录
+func (s *Store) CreateConnectionHistory(ctx context.Context, history *model.AgentConnectionHistory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	insertRow(ctx, s, "agent_connection_history", s.connectionHistory, history, &history.ID)
+	return nil
+}
+
+// UpdateConnectionHistory 更新连接历史记录
+func (s *Store) UpdateConnectionHistory(ctx context.Context, history *model.AgentConnectionHistory) error {
+	// 计算连接时长
+	history.CalculateDuration()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saveRow(ctx, s, "agent_connection_history", s.connectionHistory, history, &history.ID)
+	return nil
+}
+
+// GetConnectionHistory 获取指定的连接历史记录
+func (s *Store) GetConnectionHistory(ctx context.Context, id uint) (*model.AgentConnectionHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := getRow(ctx, s.connectionHistory, id)
+	if history == nil {
+		return nil, errRecordNotFound
+	}
+	return history, nil
+}
+
+// ListConnectionHistoryByAgent 列出指定 Agent 的连接历史, 按连接时间倒序
+func (s *Store) ListConnectionHistoryByAgent(ctx context.Context, agentID string, limit, offset int) ([]*model.AgentConnectionHistory, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.connectionHistory, func(h *model.AgentConnectionHistory) bool {
+		return h.AgentID == agentID
+	})
+	sortConnectionHistory(histories, true)
+
+	if limit <= 0 {
+		limit = -1
+	}
+	return paginate(histories, limit, offset), int64(len(histories)), nil
+}
+
+// GetActiveConnectionHistory 获取 Agent 当前活跃的连接历史记录
+func (s *Store) GetActiveConnectionHistory(ctx context.Context, agentID string) (*model.AgentConnectionHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.connectionHistory, func(h *model.AgentConnectionHistory) bool {
+		return h.AgentID == agentID && h.DisconnectedAt == nil
+	})
+	sortConnectionHistory(histories, true)
+	return first(histories), nil
+}
+
+// sortConnectionHistory 按连接时间排序
+func sortConnectionHistory(histories []*model.AgentConnectionHistory, descending bool) {
+	sort.SliceStable(histories, func(i, j int) bool {
+		a, b := histories[i], histories[j]
+		if !a.ConnectedAt.Equal(b.ConnectedAt) {
+			return a.ConnectedAt.Before(b.ConnectedAt) != descending
+		}
+		return (a.ID < b.ID) != descending
+	})
+}
+
+// updateAgent 修改 context 组织内的 Agent 并刷新更新时间, Agent 不存在时返回 false
+func (s *Store) updateAgent(ctx context.Context, agentID string, update func(agent *model.Agent)) bool {
+	existing := s.agent(ctx, agentID)
+	if existing == nil {
+		return false
+	}
+	agent := clone(existing)
+	update(agent)
+	touch(agent)
+	s.agents[agentID] = agent
+	return true
+}
+
+// UpdateAgentStatus 更新 Agent 状态
+func (s *Store) UpdateAgentStatus(ctx context.Context, agentID string, status model.AgentStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := now()
+	s.updateAgent(ctx, agentID, func(agent *model.Agent) {
+		agent.Status = status
+		agent.LastSeenAt = &t
+		if status == model.StatusOnline {
+			agent.LastConnectedAt = &t
+			agent.DisconnectReason = "" // 清空断开原因
+		} else if status == model.StatusOffline {
+			agent.LastDisconnectedAt = &t
+		}
+	})
+	return nil
+}
+
+// UpdateAgentLastSeen 更新 Agent 最后心跳时间
+func (s *Store) UpdateAgentLastSeen(ctx context.Context, agentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := now()
+	s.updateAgent(ctx, agentID, func(agent *model.Agent) {
+		agent.LastSeenAt = &t
+	})
+	return nil
+}
+
+// SetAgentDisconnectReason 设置 Agent 断开原因
+func (s *Store) SetAgentDisconnectReason(ctx context.Context, agentID string, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateAgent(ctx, agentID, func(agent *model.Agent) {
+		agent.DisconnectReason = reason
+	})
+	return nil
+}
+
+// ListOnlineAgents 列出所有在线的 Agent
+func (s *Store) ListOnlineAgents(ctx context.Context) ([]*model.Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := selectRows(ctx, s.agents, func(agent *model.Agent) bool {
+		return agent.Status == model.StatusOnline
+	})
+	sortAgentsByID(agents)
+	return agents, nil
+}
+
+// ListOfflineAgents 列出所有离线的 Agent, 按断开时间倒序, 没有断开时间的排在最前
+func (s *Store) ListOfflineAgents(ctx context.Context, limit, offset int) ([]*model.Agent, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := selectRows(ctx, s.agents, func(agent *model.Agent) bool {
+		return agent.Status == model.StatusOffline
+	})
+	// PostgreSQL 降序排列时 NULL 排在最前
+	sort.SliceStable(agents, func(i, j int) bool {
+		a, b := agents[i].LastDisconnectedAt, agents[j].LastDisconnectedAt
+		switch {
+		case a == nil || b == nil:
+			return a == nil && b != nil
+		case !a.Equal(*b):
+			return a.After(*b)
+		default:
+			return agents[i].ID < agents[j].ID
+		}
+	})
+
+	if limit <= 0 {
+		limit = -1
+	}
+	return paginate(agents, limit, offset), int64(len(agents)), nil
+}
+
+// ListStaleAgents 列出心跳超时的 Agent (status 为 online 但 last_seen_at 超过指定时间)
+func (s *Store) ListStaleAgents(ctx context.Context, timeout time.Duration) ([]*model.Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	threshold := time.Now().Add(-timeout)
+	agents := selectRows(ctx, s.agents, func(agent *model.Agent) bool {
+		return agent.Status == model.StatusOnline && agent.LastSeenAt != nil && agent.LastSeenAt.Before(threshold)
+	})
+	sortAgentsByID(agents)
+	return agents, nil
+}
+
+// ListConnectionHistorySince 列出自指定时间以来建立的所有连接记录
+func (s *Store) ListConnectionHistorySince(ctx context.Context, since time.Time) ([]*model.AgentConnectionHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.connectionHistory, func(h *model.AgentConnectionHistory) bool {
+		return !h.ConnectedAt.Before(since)
+	})
+	sortConnectionHistory(histories, false)
+	return histories, nil
+}
+
+// ListFlappingAgents 列出当前被标记为抖动的 Agent
+func (s *Store) ListFlappingAgents(ctx context.Context) ([]*model.Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := selectRows(ctx, s.agents, func(agent *model.Agent) bool {
+		return agent.Flapping
+	})
+	sortAgentsByID(agents)
+	return agents, nil
+}
+
+// SetAgentFlapping 设置 Agent 的抖动标记
+func (s *Store) SetAgentFlapping(ctx context.Context, agentID string, flapping bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateAgent(ctx, agentID, func(agent *model.Agent) {
+		agent.Flapping = flapping
+		agent.FlappingSince = nil
+		if flapping {
+			t := now()
+			agent.FlappingSince = &t
+		}
+	})
+	return nil
+}
+
+// ListConnectionHistoryInRange 列出指定 Agent 与 [from, to) 有交集的连接记录
+func (s *Store) ListConnectionHistoryInRange(ctx context.Context, agentIDs []string, from, to time.Time) ([]*model.AgentConnectionHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.connectionHistory, func(h *model.AgentConnectionHistory) bool {
+		if !contains(agentIDs, h.AgentID) {
+			return false
+		}
+		return h.ConnectedAt.Before(to) && (h.DisconnectedAt == nil || h.DisconnectedAt.After(from))
+	})
+	sortConnectionHistory(histories, false)
+	return histories, nil
+}
+
+// sortAgentsByID 按 ID 排序 Agent, PostgreSQL 中未指定排序的查询在内存存储中使用该顺序
+func sortAgentsByID(agents []*model.Agent) {
+	sort.Slice(agents, func(i, j int) bool { return agents[i].ID < agents[j].ID })
+}
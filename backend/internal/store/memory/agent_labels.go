@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// UpdateAgentServerLabels 修改 Agent 的服务端标签并重新计算生效的标签
+//
+// update 修改传入 Agent 的 ServerLabels, 返回错误时放弃修改。update 在锁外执行, 期间
+// Agent 被并发修改时基于新的数据重新执行, 因此并发的标签修改不会互相覆盖。
+// Agent 不存在时返回 nil。
+func (s *Store) UpdateAgentServerLabels(ctx context.Context, agentID string, update func(agent *model.Agent) error) (*model.Agent, error) {
+	for {
+		s.mu.RLock()
+		current := s.agent(ctx, agentID)
+		s.mu.RUnlock()
+		if current == nil {
+			return nil, nil
+		}
+
+		agent := clone(current)
+		// 启用服务端标签之前的 Agent 没有单独保存上报标签, 以当前标签作为上报标签
+		if agent.ReportedLabels == nil {
+			agent.ReportedLabels = agent.Labels.Merge(nil)
+		}
+		if err := update(agent); err != nil {
+			return nil, err
+		}
+		agent.RefreshLabels()
+
+		s.mu.Lock()
+		// 保存的记录每次修改都会被替换, 指针不变说明期间没有其他修改
+		if s.agents[agentID] != current {
+			s.mu.Unlock()
+			continue
+		}
+		updated := clone(current)
+		updated.Labels = agent.Labels
+		updated.ReportedLabels = agent.ReportedLabels
+		updated.ServerLabels = agent.ServerLabels
+		touch(updated)
+		s.agents[agentID] = clone(updated)
+		s.mu.Unlock()
+		return updated, nil
+	}
+}
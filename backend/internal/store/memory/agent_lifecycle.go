@@ -0,0 +1,73 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// DecommissionAgent 将 Agent 标记为退役, purgeHistory 为 true 时同时删除其历史记录
+//
+// 退役的 Agent 保留记录, 再次连接时被拒绝。Agent 不存在时返回 false。
+func (s *Store) DecommissionAgent(ctx context.Context, agentID string, purgeHistory bool) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := now()
+	found := s.updateAgent(ctx, agentID, func(agent *model.Agent) {
+		agent.DecommissionedAt = &t
+		agent.Status = model.StatusOffline
+	})
+	if found && purgeHistory {
+		s.deleteAgentHistory(ctx, []string{agentID})
+	}
+	return found, nil
+}
+
+// DeleteAgentsOfflineBefore 删除 before 之后一直没有上报的离线 Agent 及其历史记录, 返回删除的 Agent ID
+//
+// 从未上报过心跳的 Agent 按创建时间判断。已退役的 Agent 不删除, 以免其再次连接。
+func (s *Store) DeleteAgentsOfflineBefore(ctx context.Context, before time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	agents := selectRows(ctx, s.agents, func(agent *model.Agent) bool {
+		lastSeen := agent.CreatedAt
+		if agent.LastSeenAt != nil {
+			lastSeen = *agent.LastSeenAt
+		}
+		return agent.Status == model.StatusOffline && agent.DecommissionedAt == nil && lastSeen.Before(before)
+	})
+	sortAgentsByID(agents)
+
+	ids := make([]string, len(agents))
+	for i, agent := range agents {
+		ids[i] = agent.ID
+		delete(s.agents, agent.ID)
+	}
+	s.deleteAgentHistory(ctx, ids)
+	return ids, nil
+}
+
+// deleteAgentHistory 删除 context 组织内 Agent 的连接历史和配置应用历史
+func (s *Store) deleteAgentHistory(ctx context.Context, agentIDs []string) {
+	if len(agentIDs) == 0 {
+		return
+	}
+	ids := make(map[string]bool, len(agentIDs))
+	for _, id := range agentIDs {
+		ids[id] = true
+	}
+
+	for id, history := range s.connectionHistory {
+		if ids[history.AgentID] && visible(ctx, history) {
+			delete(s.connectionHistory, id)
+		}
+	}
+	for id, history := range s.applyHistory {
+		if ids[history.AgentID] && visible(ctx, history) {
+			delete(s.applyHistory, id)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package memory
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// negativeInfinity 游标中表示从未上报心跳的 Agent 的排序值, 与 PostgreSQL 存储一致
+const negativeInfinity = "-infinity"
+
+// agentCursor 游标记录上一页最后一个 Agent 的排序值和 ID, 格式与 PostgreSQL 存储相同
+type agentCursor struct {
+	Sort  model.AgentSort `json:"s"`
+	Value string          `json:"v"`
+	ID    string          `json:"id"`
+}
+
+// SearchAgents 按条件查询 Agent, 返回当前页、符合条件的总数和下一页的游标
+//
+// 同一排序值的 Agent 按 ID 排序, last_seen_at 为空的 Agent 视为最早。还有下一页时返回非空游标。
+func (s *Store) SearchAgents(ctx context.Context, filter model.AgentFilter, opts model.AgentListOptions) ([]*model.Agent, int64, string, error) {
+	if opts.Sort == "" {
+		opts.Sort = model.AgentSortUpdatedAt
+	}
+	if !opts.Sort.IsValid() {
+		return nil, 0, "", fmt.Errorf("unknown sort field: %s", opts.Sort)
+	}
+
+	s.mu.RLock()
+	agents := selectRows(ctx, s.agents, func(agent *model.Agent) bool {
+		return matchesAgentFilter(agent, filter)
+	})
+	s.mu.RUnlock()
+	total := int64(len(agents))
+
+	less := func(a, b *model.Agent) bool {
+		c := compareSortValues(agentSortValue(opts.Sort, a), agentSortValue(opts.Sort, b))
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		if opts.Descending {
+			return c > 0
+		}
+		return c < 0
+	}
+	sort.Slice(agents, func(i, j int) bool { return less(agents[i], agents[j]) })
+
+	if opts.Cursor != "" {
+		cursor, err := decodeAgentCursor(opts.Cursor)
+		if err != nil || cursor.Sort != opts.Sort {
+			return nil, 0, "", store.ErrInvalidCursor
+		}
+		value, err := cursor.sortValue()
+		if err != nil {
+			return nil, 0, "", store.ErrInvalidCursor
+		}
+
+		// 跳过排在游标之前 (含游标) 的 Agent
+		start := sort.Search(len(agents), func(i int) bool {
+			c := compareSortValues(agentSortValue(opts.Sort, agents[i]), value)
+			if c == 0 {
+				c = strings.Compare(agents[i].ID, cursor.ID)
+			}
+			if opts.Descending {
+				return c < 0
+			}
+			return c > 0
+		})
+		agents = paginate(agents[start:], opts.Limit, 0)
+	} else {
+		agents = paginate(agents, opts.Limit, opts.Offset)
+	}
+
+	var next string
+	if opts.Limit > 0 && len(agents) == opts.Limit {
+		next = encodeAgentCursor(opts.Sort, agents[len(agents)-1])
+	}
+	return agents, total, next, nil
+}
+
+// matchesAgentFilter 检查 Agent 是否符合查询条件
+func matchesAgentFilter(agent *model.Agent, filter model.AgentFilter) bool {
+	if len(filter.IDs) > 0 && !contains(filter.IDs, agent.ID) {
+		return false
+	}
+	if len(filter.Statuses) > 0 && !contains(filter.Statuses, agent.Status) {
+		return false
+	}
+	for key, value := range filter.Selector {
+		if v, ok := agent.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	if filter.Version != "" && agent.Version != filter.Version {
+		return false
+	}
+	if filter.Search != "" {
+		search := strings.ToLower(filter.Search)
+		if !strings.Contains(strings.ToLower(agent.Name), search) && !strings.Contains(strings.ToLower(agent.Hostname), search) {
+			return false
+		}
+	}
+	if filter.OS != "" && agent.Type != filter.OS {
+		return false
+	}
+	if filter.Arch != "" && agent.Architecture != filter.Arch {
+		return false
+	}
+	if filter.ConfigurationName != "" && agent.ConfigurationName != filter.ConfigurationName {
+		return false
+	}
+	if filter.LastSeenAfter != nil && (agent.LastSeenAt == nil || agent.LastSeenAt.Before(*filter.LastSeenAfter)) {
+		return false
+	}
+	if filter.LastSeenBefore != nil && (agent.LastSeenAt == nil || !agent.LastSeenAt.Before(*filter.LastSeenBefore)) {
+		return false
+	}
+	if filter.Decommissioned != nil && *filter.Decommissioned != (agent.DecommissionedAt != nil) {
+		return false
+	}
+	if filter.Capabilities != 0 && agent.Capabilities&filter.Capabilities != filter.Capabilities {
+		return false
+	}
+	return true
+}
+
+// contains 检查切片中是否包含 v
+func contains[T comparable](values []T, v T) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// agentSortValue 返回 Agent 的排序值, 时间字段返回 time.Time, 其他字段返回字符串
+func agentSortValue(sort model.AgentSort, agent *model.Agent) interface{} {
+	switch sort {
+	case model.AgentSortUpdatedAt:
+		return agent.UpdatedAt
+	case model.AgentSortCreatedAt:
+		return agent.CreatedAt
+	case model.AgentSortLastSeenAt:
+		// 零值早于任何实际时间, 相当于 -infinity
+		if agent.LastSeenAt == nil {
+			return time.Time{}
+		}
+		return *agent.LastSeenAt
+	case model.AgentSortName:
+		return agent.Name
+	case model.AgentSortHostname:
+		return agent.Hostname
+	case model.AgentSortVersion:
+		return agent.Version
+	default:
+		return ""
+	}
+}
+
+// compareSortValues 比较两个同类型的排序值
+func compareSortValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	case string:
+		return strings.Compare(a, b.(string))
+	default:
+		return 0
+	}
+}
+
+// encodeAgentCursor 根据一页中最后一个 Agent 生成下一页的游标
+func encodeAgentCursor(sort model.AgentSort, agent *model.Agent) string {
+	cursor := agentCursor{Sort: sort, ID: agent.ID}
+	switch value := agentSortValue(sort, agent).(type) {
+	case time.Time:
+		cursor.Value = negativeInfinity
+		if !value.IsZero() {
+			cursor.Value = value.Format(time.RFC3339Nano)
+		}
+	case string:
+		cursor.Value = value
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sortValue 返回游标中的排序值
+func (c *agentCursor) sortValue() (interface{}, error) {
+	switch c.Sort {
+	case model.AgentSortUpdatedAt, model.AgentSortCreatedAt, model.AgentSortLastSeenAt:
+		if c.Value == negativeInfinity {
+			return time.Time{}, nil
+		}
+		return time.Parse(time.RFC3339Nano, c.Value)
+	default:
+		return c.Value, nil
+	}
+}
+
+// decodeAgentCursor 解析游标
+func decodeAgentCursor(s string) (*agentCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cursor agentCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == "" {
+		return nil, store.ErrInvalidCursor
+	}
+	return &cursor, nil
+}
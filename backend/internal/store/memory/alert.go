@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateAlertRule 创建告警规则, 规则名称在组织内唯一
+func (s *Store) CreateAlertRule(ctx context.Context, rule *model.AlertRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prepareCreate(ctx, rule)
+	if s.alertRuleNameTaken(rule) {
+		return duplicateKeyError("idx_alert_rules_org_name")
+	}
+	insertRow(ctx, s, "alert_rules", s.alertRules, rule, &rule.ID)
+	return nil
+}
+
+// alertRuleNameTaken 检查组织内是否已有同名的其他规则
+func (s *Store) alertRuleNameTaken(rule *model.AlertRule) bool {
+	for _, other := range s.alertRules {
+		if other.ID != rule.ID && other.OrganizationID == rule.OrganizationID && other.Name == rule.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAlertRule 获取告警规则
+func (s *Store) GetAlertRule(ctx context.Context, id uint) (*model.AlertRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getRow(ctx, s.alertRules, id), nil
+}
+
+// ListAlertRules 列出所有告警规则
+func (s *Store) ListAlertRules(ctx context.Context) ([]*model.AlertRule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rules := selectRows(ctx, s.alertRules, nil)
+	sortByID(rules, func(rule *model.AlertRule) uint { return rule.ID })
+	return rules, nil
+}
+
+// UpdateAlertRule 更新告警规则
+func (s *Store) UpdateAlertRule(ctx context.Context, rule *model.AlertRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.alertRules[rule.ID]; ok {
+		renamed := clone(rule)
+		s.prepareUpdate(ctx, renamed, existing)
+		if s.alertRuleNameTaken(renamed) {
+			return duplicateKeyError("idx_alert_rules_org_name")
+		}
+	}
+	saveRow(ctx, s, "alert_rules", s.alertRules, rule, &rule.ID)
+	return nil
+}
+
+// DeleteAlertRule 删除告警规则及其告警和静默
+func (s *Store) DeleteAlertRule(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for alertID, alert := range s.alerts {
+		if alert.RuleID == id && visible(ctx, alert) {
+			delete(s.alerts, alertID)
+		}
+	}
+	for silenceID, silence := range s.silences {
+		if silence.RuleID != nil && *silence.RuleID == id && visible(ctx, silence) {
+			delete(s.silences, silenceID)
+		}
+	}
+	deleteRow(ctx, s.alertRules, id)
+	return nil
+}
+
+// CreateAlert 创建告警
+func (s *Store) CreateAlert(ctx context.Context, alert *model.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	insertRow(ctx, s, "alerts", s.alerts, alert, &alert.ID)
+	return nil
+}
+
+// UpdateAlert 更新告警
+func (s *Store) UpdateAlert(ctx context.Context, alert *model.Alert) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saveRow(ctx, s, "alerts", s.alerts, alert, &alert.ID)
+	return nil
+}
+
+// ListActiveAlerts 列出处于 pending 或 firing 状态的告警
+func (s *Store) ListActiveAlerts(ctx context.Context) ([]*model.Alert, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alerts := selectRows(ctx, s.alerts, func(alert *model.Alert) bool {
+		return alert.State == model.AlertStatePending || alert.State == model.AlertStateFiring
+	})
+	sortByID(alerts, func(alert *model.Alert) uint { return alert.ID })
+	return alerts, nil
+}
+
+// ListAlerts 列出告警, state 为空时返回所有状态
+func (s *Store) ListAlerts(ctx context.Context, state model.AlertState, limit, offset int) ([]*model.Alert, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alerts := selectRows(ctx, s.alerts, func(alert *model.Alert) bool {
+		return state == "" || alert.State == state
+	})
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].StartedAt.Equal(alerts[j].StartedAt) {
+			return alerts[i].StartedAt.After(alerts[j].StartedAt)
+		}
+		return alerts[i].ID > alerts[j].ID
+	})
+	return paginate(alerts, limit, offset), int64(len(alerts)), nil
+}
+
+// CreateSilence 创建静默
+func (s *Store) CreateSilence(ctx context.Context, silence *model.Silence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	insertRow(ctx, s, "alert_silences", s.silences, silence, &silence.ID)
+	return nil
+}
+
+// ListSilences 列出静默, includeExpired 为 false 时只返回未过期的静默
+func (s *Store) ListSilences(ctx context.Context, includeExpired bool) ([]*model.Silence, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t := time.Now()
+	silences := selectRows(ctx, s.silences, func(silence *model.Silence) bool {
+		return includeExpired || silence.EndsAt.After(t)
+	})
+	sortByID(silences, func(silence *model.Silence) uint { return silence.ID })
+	return silences, nil
+}
+
+// ListActiveSilences 列出在指定时间生效的静默
+func (s *Store) ListActiveSilences(ctx context.Context, now time.Time) ([]*model.Silence, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	silences := selectRows(ctx, s.silences, func(silence *model.Silence) bool {
+		return !silence.StartsAt.After(now) && silence.EndsAt.After(now)
+	})
+	sortByID(silences, func(silence *model.Silence) uint { return silence.ID })
+	return silences, nil
+}
+
+// DeleteSilence 删除静默
+func (s *Store) DeleteSilence(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleteRow(ctx, s.silences, id)
+	return nil
+}
+
+// ListAgentsBySelector 列出标签匹配选择器的未退役 Agent, 选择器为空时返回所有未退役的 Agent
+func (s *Store) ListAgentsBySelector(ctx context.Context, selector map[string]string) ([]*model.Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := selectRows(ctx, s.agents, func(agent *model.Agent) bool {
+		return agent.DecommissionedAt == nil && (len(selector) == 0 || agent.Labels.Matches(selector))
+	})
+	sortAgentsByID(agents)
+	return agents, nil
+}
+
+// CountConnectionsSince 统计每个 Agent 自指定时间以来的连接次数
+func (s *Store) CountConnectionsSince(ctx context.Context, since time.Time) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, history := range s.connectionHistory {
+		if visible(ctx, history) && !history.ConnectedAt.Before(since) {
+			counts[history.AgentID]++
+		}
+	}
+	return counts, nil
+}
+
+// ListFailedApplyHistorySince 列出自指定时间以来应用失败的记录, configName 为空时不限配置
+func (s *Store) ListFailedApplyHistorySince(ctx context.Context, configName string, since time.Time) ([]*model.ConfigurationApplyHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.applyHistory, func(h *model.ConfigurationApplyHistory) bool {
+		return h.Status == model.ApplyStatusFailed && !h.UpdatedAt.Before(since) &&
+			(configName == "" || h.ConfigurationName == configName)
+	})
+	sort.Slice(histories, func(i, j int) bool {
+		if !histories[i].UpdatedAt.Equal(histories[j].UpdatedAt) {
+			return histories[i].UpdatedAt.After(histories[j].UpdatedAt)
+		}
+		return histories[i].ID > histories[j].ID
+	})
+	return histories, nil
+}
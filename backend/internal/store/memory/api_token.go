@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateAPIToken 创建 API 令牌
+func (s *Store) CreateAPIToken(ctx context.Context, token *model.APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.apiTokens {
+		if other.TokenHash == token.TokenHash {
+			return duplicateKeyError("idx_api_tokens_token_hash")
+		}
+	}
+	insertRow(ctx, s, "api_tokens", s.apiTokens, token, &token.ID)
+	return nil
+}
+
+// GetAPITokenByHash 根据令牌哈希获取 API 令牌
+func (s *Store) GetAPITokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := selectRows(ctx, s.apiTokens, func(token *model.APIToken) bool {
+		return token.TokenHash == tokenHash
+	})
+	return first(tokens), nil
+}
+
+// GetAPIToken 获取用户的 API 令牌
+func (s *Store) GetAPIToken(ctx context.Context, userID, id uint) (*model.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	token := getRow(ctx, s.apiTokens, id)
+	if token == nil || token.UserID != userID {
+		return nil, nil
+	}
+	return token, nil
+}
+
+// ListAPITokens 列出用户的 API 令牌, 按创建时间倒序
+func (s *Store) ListAPITokens(ctx context.Context, userID uint) ([]*model.APIToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := selectRows(ctx, s.apiTokens, func(token *model.APIToken) bool {
+		return token.UserID == userID
+	})
+	sort.Slice(tokens, func(i, j int) bool {
+		if !tokens[i].CreatedAt.Equal(tokens[j].CreatedAt) {
+			return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+		}
+		return tokens[i].ID > tokens[j].ID
+	})
+	return tokens, nil
+}
+
+// RevokeAPIToken 撤销 API 令牌
+func (s *Store) RevokeAPIToken(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token, ok := s.apiTokens[id]; ok && token.RevokedAt == nil {
+		t := now()
+		token.RevokedAt = &t
+	}
+	return nil
+}
+
+// TouchAPIToken 更新 API 令牌的最后使用时间
+func (s *Store) TouchAPIToken(ctx context.Context, id uint, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token, ok := s.apiTokens[id]; ok {
+		token.LastUsedAt = &usedAt
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateAuditLog 追加一条审计日志
+func (s *Store) CreateAuditLog(ctx context.Context, entry *model.AuditLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	insertRow(ctx, s, "audit_logs", s.auditLogs, entry, &entry.ID)
+	return nil
+}
+
+// ListAuditLogs 按条件分页查询审计日志, 按时间倒序
+func (s *Store) ListAuditLogs(ctx context.Context, filter model.AuditFilter, limit, offset int) ([]*model.AuditLog, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	logs := selectRows(ctx, s.auditLogs, func(entry *model.AuditLog) bool {
+		return matchesAuditFilter(entry, filter)
+	})
+	sort.Slice(logs, func(i, j int) bool {
+		if !logs[i].CreatedAt.Equal(logs[j].CreatedAt) {
+			return logs[i].CreatedAt.After(logs[j].CreatedAt)
+		}
+		return logs[i].ID > logs[j].ID
+	})
+	return paginate(logs, limit, offset), int64(len(logs)), nil
+}
+
+// ExportAuditLogs 按条件读取审计日志, 按写入顺序逐条回调
+//
+// 回调在释放锁之后执行, 回调中可以继续访问存储。
+func (s *Store) ExportAuditLogs(ctx context.Context, filter model.AuditFilter, fn func(*model.AuditLog) error) error {
+	s.mu.RLock()
+	logs := selectRows(ctx, s.auditLogs, func(entry *model.AuditLog) bool {
+		return matchesAuditFilter(entry, filter)
+	})
+	s.mu.RUnlock()
+
+	sortByID(logs, func(entry *model.AuditLog) uint { return entry.ID })
+	for _, entry := range logs {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchesAuditFilter 检查审计日志是否符合查询条件
+func matchesAuditFilter(entry *model.AuditLog, filter model.AuditFilter) bool {
+	if filter.ActorType != "" && entry.ActorType != filter.ActorType {
+		return false
+	}
+	if filter.Actor != "" && entry.Actor != filter.Actor {
+		return false
+	}
+	if filter.Action != "" {
+		if prefix, ok := strings.CutSuffix(filter.Action, ".*"); ok {
+			if !strings.HasPrefix(entry.Action, prefix+".") {
+				return false
+			}
+		} else if entry.Action != filter.Action {
+			return false
+		}
+	}
+	if filter.TargetType != "" && entry.TargetType != filter.TargetType {
+		return false
+	}
+	if filter.TargetID != "" && entry.TargetID != filter.TargetID {
+		return false
+	}
+	if filter.RequestID != "" && entry.RequestID != filter.RequestID {
+		return false
+	}
+	if filter.From != nil && entry.CreatedAt.Before(*filter.From) {
+		return false
+	}
+	if filter.To != nil && !entry.CreatedAt.Before(*filter.To) {
+		return false
+	}
+	return true
+}
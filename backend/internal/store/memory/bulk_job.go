@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// ListAgentsByFilter 获取所有符合条件的 Agent, 不分页
+func (s *Store) ListAgentsByFilter(ctx context.Context, filter model.AgentFilter) ([]*model.Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agents := selectRows(ctx, s.agents, func(agent *model.Agent) bool {
+		return matchesAgentFilter(agent, filter)
+	})
+	sortAgentsByID(agents)
+	return agents, nil
+}
+
+// UpdateAgentConfigurationName 设置 Agent 指定使用的配置, 为空表示按标签匹配
+//
+// 只修改该字段, 不影响并发的状态上报。Agent 不存在时返回 false。
+func (s *Store) UpdateAgentConfigurationName(ctx context.Context, agentID, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateAgent(ctx, agentID, func(agent *model.Agent) {
+		agent.ConfigurationName = name
+	}), nil
+}
+
+// CreateBulkJob 创建批量操作任务
+func (s *Store) CreateBulkJob(ctx context.Context, job *model.BulkJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	insertRow(ctx, s, "bulk_jobs", s.bulkJobs, job, &job.ID)
+	return nil
+}
+
+// UpdateBulkJob 更新批量操作任务的进度和结果
+func (s *Store) UpdateBulkJob(ctx context.Context, job *model.BulkJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saveRow(ctx, s, "bulk_jobs", s.bulkJobs, job, &job.ID)
+	return nil
+}
+
+// InterruptRunningBulkJobs 将仍在执行的任务标记为已中断, 在服务启动时调用
+func (s *Store) InterruptRunningBulkJobs(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var interrupted int64
+	for id, job := range s.bulkJobs {
+		if !visible(ctx, job) || job.Status != model.BulkJobRunning {
+			continue
+		}
+		updated := clone(job)
+		t := now()
+		updated.Status = model.BulkJobInterrupted
+		updated.FinishedAt = &t
+		s.bulkJobs[id] = updated
+		interrupted++
+	}
+	return interrupted, nil
+}
+
+// GetBulkJob 获取批量操作任务
+func (s *Store) GetBulkJob(ctx context.Context, id uint) (*model.BulkJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return getRow(ctx, s.bulkJobs, id), nil
+}
+
+// ListBulkJobs 分页列出批量操作任务, 按创建时间倒序, 不包含每个 Agent 的结果
+//
+// createdBy 不为空时只列出该用户创建的任务。
+func (s *Store) ListBulkJobs(ctx context.Context, createdBy string, limit, offset int) ([]*model.BulkJob, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jobs := selectRows(ctx, s.bulkJobs, func(job *model.BulkJob) bool {
+		return createdBy == "" || job.CreatedBy == createdBy
+	})
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID > jobs[j].ID
+	})
+
+	page := paginate(jobs, limit, offset)
+	for _, job := range page {
+		job.Results = nil
+	}
+	return page, int64(len(jobs)), nil
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateConfigurationHistory 创建配置历史记录
+func (s *Store) CreateConfigurationHistory(ctx context.Context, history *model.ConfigurationHistory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertConfigurationHistory(ctx, history)
+	return nil
+}
+
+// insertConfigurationHistory 创建配置历史记录, 调用方持有写锁
+func (s *Store) insertConfigurationHistory(ctx context.Context, history *model.ConfigurationHistory) {
+	insertRow(ctx, s, "configuration_history", s.configurationHistory, history, &history.ID)
+}
+
+// GetConfigurationHistory 获取指定版本的配置历史
+func (s *Store) GetConfigurationHistory(ctx context.Context, configName string, version int) (*model.ConfigurationHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.configurationHistory, func(h *model.ConfigurationHistory) bool {
+		return h.ConfigurationName == configName && h.Version == version
+	})
+	sortByID(histories, func(h *model.ConfigurationHistory) uint { return h.ID })
+	if len(histories) == 0 {
+		return nil, errRecordNotFound
+	}
+	return histories[0], nil
+}
+
+// ListConfigurationHistory 列出配置的所有历史版本, 按版本号倒序
+func (s *Store) ListConfigurationHistory(ctx context.Context, configName string, limit, offset int) ([]*model.ConfigurationHistory, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.configurationHistory, func(h *model.ConfigurationHistory) bool {
+		return h.ConfigurationName == configName
+	})
+	sort.Slice(histories, func(i, j int) bool {
+		if histories[i].Version != histories[j].Version {
+			return histories[i].Version > histories[j].Version
+		}
+		return histories[i].ID > histories[j].ID
+	})
+	return paginate(histories, limit, offset), int64(len(histories)), nil
+}
+
+// GetLatestConfigurationVersion 获取配置历史中的最大版本号, 没有历史时返回 0
+func (s *Store) GetLatestConfigurationVersion(ctx context.Context, configName string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	maxVersion := 0
+	for _, history := range s.configurationHistory {
+		if visible(ctx, history) && history.ConfigurationName == configName && history.Version > maxVersion {
+			maxVersion = history.Version
+		}
+	}
+	return maxVersion, nil
+}
+
+// CreateApplyHistory 创建配置应用历史记录
+func (s *Store) CreateApplyHistory(ctx context.Context, history *model.ConfigurationApplyHistory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	insertRow(ctx, s, "configuration_apply_history", s.applyHistory, history, &history.ID)
+	return nil
+}
+
+// UpdateApplyHistory 更新配置应用历史记录
+func (s *Store) UpdateApplyHistory(ctx context.Context, history *model.ConfigurationApplyHistory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saveRow(ctx, s, "configuration_apply_history", s.applyHistory, history, &history.ID)
+	return nil
+}
+
+// GetApplyHistory 获取指定 ID 的应用历史
+func (s *Store) GetApplyHistory(ctx context.Context, id uint) (*model.ConfigurationApplyHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	history := getRow(ctx, s.applyHistory, id)
+	if history == nil {
+		return nil, errRecordNotFound
+	}
+	return history, nil
+}
+
+// GetLatestApplyHistory 获取 Agent 最新的配置应用记录
+func (s *Store) GetLatestApplyHistory(ctx context.Context, agentID, configName string) (*model.ConfigurationApplyHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.applyHistory, func(h *model.ConfigurationApplyHistory) bool {
+		return h.AgentID == agentID && h.ConfigurationName == configName
+	})
+	sortApplyHistory(histories, true)
+	if len(histories) == 0 {
+		return nil, errRecordNotFound
+	}
+	return histories[0], nil
+}
+
+// ListApplyHistoryByAgent 列出 Agent 的所有配置应用历史
+func (s *Store) ListApplyHistoryByAgent(ctx context.Context, agentID string, limit, offset int) ([]*model.ConfigurationApplyHistory, int64, error) {
+	return s.listApplyHistory(ctx, limit, offset, func(h *model.ConfigurationApplyHistory) bool {
+		return h.AgentID == agentID
+	})
+}
+
+// ListApplyHistoryByConfig 列出配置的所有应用历史
+func (s *Store) ListApplyHistoryByConfig(ctx context.Context, configName string, limit, offset int) ([]*model.ConfigurationApplyHistory, int64, error) {
+	return s.listApplyHistory(ctx, limit, offset, func(h *model.ConfigurationApplyHistory) bool {
+		return h.ConfigurationName == configName
+	})
+}
+
+// listApplyHistory 按创建时间倒序分页列出满足条件的应用历史
+func (s *Store) listApplyHistory(ctx context.Context, limit, offset int, match func(*model.ConfigurationApplyHistory) bool) ([]*model.ConfigurationApplyHistory, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.applyHistory, match)
+	sortApplyHistory(histories, true)
+	return paginate(histories, limit, offset), int64(len(histories)), nil
+}
+
+// GetPendingApplyHistories 获取所有待应用或应用中的记录
+func (s *Store) GetPendingApplyHistories(ctx context.Context) ([]*model.ConfigurationApplyHistory, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	histories := selectRows(ctx, s.applyHistory, func(h *model.ConfigurationApplyHistory) bool {
+		return h.Status == model.ApplyStatusPending || h.Status == model.ApplyStatusApplying
+	})
+	sortApplyHistory(histories, false)
+	return histories, nil
+}
+
+// sortApplyHistory 按创建时间排序
+func sortApplyHistory(histories []*model.ConfigurationApplyHistory, descending bool) {
+	sort.Slice(histories, func(i, j int) bool {
+		a, b := histories[i], histories[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt) != descending
+		}
+		return (a.ID < b.ID) != descending
+	})
+}
@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// fleetDimensionValues 分组维度对应的 Agent 字段
+var fleetDimensionValues = map[model.FleetDimension]func(agent *model.Agent) string{
+	model.FleetDimensionStatus:        func(agent *model.Agent) string { return string(agent.Status) },
+	model.FleetDimensionVersion:       func(agent *model.Agent) string { return agent.Version },
+	model.FleetDimensionOS:            func(agent *model.Agent) string { return agent.Type },
+	model.FleetDimensionArch:          func(agent *model.Agent) string { return agent.Architecture },
+	model.FleetDimensionConfiguration: func(agent *model.Agent) string { return agent.ConfigurationName },
+}
+
+// CountAgentsBy 按维度分组统计符合条件的 Agent 数量, 按数量从多到少排序
+//
+// 没有该标签的 Agent 计入值为空字符串的分组, 过滤条件没有指定 Decommissioned 时不统计已退役的 Agent。
+func (s *Store) CountAgentsBy(ctx context.Context, filter model.AgentFilter, dimension model.FleetDimension) ([]model.FleetBucket, error) {
+	filter = filter.WithoutDecommissioned()
+	value, ok := fleetDimensionValues[dimension]
+	if key, isLabel := dimension.LabelKey(); isLabel {
+		value, ok = func(agent *model.Agent) string { return agent.Labels[key] }, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown dimension: %s", dimension)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, agent := range s.agents {
+		if visible(ctx, agent) && matchesAgentFilter(agent, filter) {
+			counts[value(agent)]++
+		}
+	}
+
+	buckets := make([]model.FleetBucket, 0, len(counts))
+	for v, count := range counts {
+		buckets = append(buckets, model.FleetBucket{Value: v, Count: count})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Value < buckets[j].Value
+	})
+	return buckets, nil
+}
+
+// CreateFleetStatsSamples 保存一次采样的分组统计
+func (s *Store) CreateFleetStatsSamples(ctx context.Context, samples []*model.FleetStatsSample) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sample := range samples {
+		insertRow(ctx, s, "fleet_stats_samples", s.fleetStatsSamples, sample, &sample.ID)
+	}
+	return nil
+}
+
+// ListFleetStatsSamples 获取 [from, to) 内某个维度的采样, 按采样时间和数量排序
+func (s *Store) ListFleetStatsSamples(ctx context.Context, dimension model.FleetDimension, from, to time.Time) ([]*model.FleetStatsSample, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	samples := selectRows(ctx, s.fleetStatsSamples, func(sample *model.FleetStatsSample) bool {
+		return sample.Dimension == string(dimension) && !sample.SampledAt.Before(from) && sample.SampledAt.Before(to)
+	})
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i], samples[j]
+		switch {
+		case !a.SampledAt.Equal(b.SampledAt):
+			return a.SampledAt.Before(b.SampledAt)
+		case a.Count != b.Count:
+			return a.Count > b.Count
+		case a.Value != b.Value:
+			return a.Value < b.Value
+		default:
+			return a.ID < b.ID
+		}
+	})
+	return samples, nil
+}
+
+// DeleteFleetStatsSamplesBefore 删除 before 之前的采样, 返回删除的行数
+func (s *Store) DeleteFleetStatsSamplesBefore(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for id, sample := range s.fleetStatsSamples {
+		if visible(ctx, sample) && sample.SampledAt.Before(before) {
+			delete(s.fleetStatsSamples, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
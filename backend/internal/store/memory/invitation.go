@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// CreateInvitation 创建邀请
+func (s *Store) CreateInvitation(ctx context.Context, invitation *model.Invitation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.invitations {
+		if other.TokenHash == invitation.TokenHash {
+			return duplicateKeyError("idx_invitations_token_hash")
+		}
+	}
+	insertRow(ctx, s, "invitations", s.invitations, invitation, &invitation.ID)
+	return nil
+}
+
+// GetInvitationByTokenHash 根据令牌哈希获取邀请
+func (s *Store) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*model.Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	invitations := selectRows(ctx, s.invitations, func(invitation *model.Invitation) bool {
+		return invitation.TokenHash == tokenHash
+	})
+	return first(invitations), nil
+}
+
+// ListInvitations 列出邀请, includeUsed 为 false 时只返回未接受且未过期的邀请
+func (s *Store) ListInvitations(ctx context.Context, includeUsed bool) ([]*model.Invitation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t := time.Now()
+	invitations := selectRows(ctx, s.invitations, func(invitation *model.Invitation) bool {
+		return includeUsed || invitation.IsUsable(t)
+	})
+	sort.Slice(invitations, func(i, j int) bool {
+		if !invitations[i].CreatedAt.Equal(invitations[j].CreatedAt) {
+			return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+		}
+		return invitations[i].ID > invitations[j].ID
+	})
+	return invitations, nil
+}
+
+// DeleteInvitation 删除 (撤销) 邀请
+func (s *Store) DeleteInvitation(ctx context.Context, id uint) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return deleteRow(ctx, s.invitations, id), nil
+}
+
+// AcceptInvitation 创建用户并将邀请标记为已接受
+//
+// 邀请已被并发使用或已过期时返回 store.ErrInvitationUnavailable, 此时不会创建用户。
+func (s *Store) AcceptInvitation(ctx context.Context, invitation *model.Invitation, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 与事务中先创建用户一致, 用户违反唯一约束的错误优先返回
+	if err := s.checkUserUnique(user); err != nil {
+		return err
+	}
+	t := now()
+	existing := s.invitations[invitation.ID]
+	if existing == nil || !visible(ctx, existing) || !existing.IsUsable(t) {
+		return store.ErrInvitationUnavailable
+	}
+	if err := s.insertUser(ctx, user); err != nil {
+		return err
+	}
+
+	existing.AcceptedAt = &t
+	existing.AcceptedBy = &user.ID
+	invitation.AcceptedAt = &t
+	invitation.AcceptedBy = &user.ID
+	return nil
+}
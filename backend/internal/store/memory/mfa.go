@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// ReplaceRecoveryCodes 使用新的恢复码替换用户现有的恢复码
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, code := range s.recoveryCodes {
+		if code.UserID == userID {
+			delete(s.recoveryCodes, id)
+		}
+	}
+	for _, hash := range codeHashes {
+		code := &model.RecoveryCode{UserID: userID, CodeHash: hash}
+		insertRow(ctx, s, "mfa_recovery_codes", s.recoveryCodes, code, &code.ID)
+	}
+	return nil
+}
+
+// UseRecoveryCode 使用一个恢复码
+//
+// 返回 false 表示恢复码不存在或已被使用。
+func (s *Store) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range s.recoveryCodes {
+		if code.UserID == userID && code.CodeHash == codeHash && code.UsedAt == nil {
+			t := now()
+			code.UsedAt = &t
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CountUnusedRecoveryCodes 统计用户未使用的恢复码数量
+func (s *Store) CountUnusedRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return countRows(ctx, s.recoveryCodes, func(code *model.RecoveryCode) bool {
+		return code.UserID == userID && code.UsedAt == nil
+	}), nil
+}
+
+// ListMFARequiredRoles 列出要求 MFA 的角色
+func (s *Store) ListMFARequiredRoles(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := make([]string, 0)
+	for _, record := range selectRows(ctx, s.mfaRequiredRoles, nil) {
+		roles = append(roles, record.Role)
+	}
+	sort.Strings(roles)
+	return roles, nil
+}
+
+// SetMFARequiredRoles 替换要求 MFA 的角色列表
+func (s *Store) SetMFARequiredRoles(ctx context.Context, roles []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, record := range s.mfaRequiredRoles {
+		if visible(ctx, record) {
+			delete(s.mfaRequiredRoles, key)
+		}
+	}
+	for _, role := range roles {
+		record := &model.MFARequiredRole{Role: role}
+		s.prepareCreate(ctx, record)
+		key := mfaRoleKey{OrganizationID: record.OrganizationID, Role: role}
+		if _, ok := s.mfaRequiredRoles[key]; ok {
+			return duplicateKeyError("mfa_required_roles_pkey")
+		}
+		s.mfaRequiredRoles[key] = record
+	}
+	return nil
+}
+
+// IsMFARequiredForRole 检查角色是否要求 MFA
+func (s *Store) IsMFARequiredForRole(ctx context.Context, role string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := countRows(ctx, s.mfaRequiredRoles, func(record *model.MFARequiredRole) bool {
+		return record.Role == role
+	})
+	return count > 0, nil
+}
+
+// AdvanceMFAStep 记录已使用的验证码时间步
+//
+// 返回 false 表示该时间步 (或更新的时间步) 已被使用, 验证码不能重复使用。
+func (s *Store) AdvanceMFAStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[userID]
+	if !ok || !visible(ctx, user) || user.MFALastStep >= step {
+		return false, nil
+	}
+	updated := clone(user)
+	updated.MFALastStep = step
+	touch(updated)
+	s.users[userID] = updated
+	return true, nil
+}
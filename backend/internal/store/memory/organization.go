@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// CreateOrganization 创建组织, 组织名称和 Secret Key 全局唯一
+func (s *Store) CreateOrganization(ctx context.Context, org *model.Organization) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkOrganizationUnique(org); err != nil {
+		return err
+	}
+	if org.ID != 0 {
+		if _, ok := s.organizations[org.ID]; ok {
+			return duplicateKeyError("organizations_pkey")
+		}
+	}
+	insertRow(ctx, s, "organizations", s.organizations, org, &org.ID)
+	return nil
+}
+
+// checkOrganizationUnique 检查组织名称和 Secret Key 是否被其他组织使用
+func (s *Store) checkOrganizationUnique(org *model.Organization) error {
+	for _, other := range s.organizations {
+		if other.ID == org.ID {
+			continue
+		}
+		if other.Name == org.Name {
+			return duplicateKeyError("idx_organizations_name")
+		}
+		if other.SecretKeyHash != nil && org.SecretKeyHash != nil && *other.SecretKeyHash == *org.SecretKeyHash {
+			return duplicateKeyError("idx_organizations_secret_key_hash")
+		}
+	}
+	return nil
+}
+
+// GetOrganization 根据 ID 获取组织
+func (s *Store) GetOrganization(ctx context.Context, id uint) (*model.Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getRow(ctx, s.organizations, id), nil
+}
+
+// GetOrganizationByName 根据名称获取组织
+func (s *Store) GetOrganizationByName(ctx context.Context, name string) (*model.Organization, error) {
+	return s.findOrganization(func(org *model.Organization) bool {
+		return org.Name == name
+	}), nil
+}
+
+// GetOrganizationBySecretKey 根据 Agent 连接使用的 Secret Key 获取组织
+func (s *Store) GetOrganizationBySecretKey(ctx context.Context, secretKey string) (*model.Organization, error) {
+	hash := model.HashSecretKey(secretKey)
+	return s.findOrganization(func(org *model.Organization) bool {
+		return org.SecretKeyHash != nil && *org.SecretKeyHash == hash
+	}), nil
+}
+
+// findOrganization 返回满足条件的组织, 不存在时返回 nil
+func (s *Store) findOrganization(match func(*model.Organization) bool) *model.Organization {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orgs := selectRows(context.Background(), s.organizations, match)
+	sortByID(orgs, func(org *model.Organization) uint { return org.ID })
+	return first(orgs)
+}
+
+// ListOrganizations 列出所有组织
+func (s *Store) ListOrganizations(ctx context.Context) ([]*model.Organization, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	orgs := selectRows(ctx, s.organizations, nil)
+	sortByID(orgs, func(org *model.Organization) uint { return org.ID })
+	return orgs, nil
+}
+
+// UpdateOrganization 更新组织
+func (s *Store) UpdateOrganization(ctx context.Context, org *model.Organization) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkOrganizationUnique(org); err != nil {
+		return err
+	}
+	saveRow(ctx, s, "organizations", s.organizations, org, &org.ID)
+	return nil
+}
+
+// DeleteOrganization 删除组织, 调用方需先确认组织中已没有用户和 Agent
+func (s *Store) DeleteOrganization(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleteRow(ctx, s.organizations, id)
+	return nil
+}
+
+// CountAgents 统计 context 所属组织未退役的 Agent 数量, 已退役的 Agent 不占用配额
+func (s *Store) CountAgents(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return countRows(ctx, s.agents, func(agent *model.Agent) bool { return agent.DecommissionedAt == nil }), nil
+}
+
+// SumPackageBytes 统计 context 所属组织的软件包占用的存储空间
+func (s *Store) SumPackageBytes(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sumPackageBytes(ctx), nil
+}
+
+// sumPackageBytes 统计 context 所属组织的软件包大小之和, 调用方持有锁
+func (s *Store) sumPackageBytes(ctx context.Context) int64 {
+	var total int64
+	for _, pkg := range s.packages {
+		if visible(ctx, pkg) {
+			total += pkg.FileSize
+		}
+	}
+	return total
+}
+
+// GetOrganizationUsage 统计组织当前的资源使用量
+func (s *Store) GetOrganizationUsage(ctx context.Context, id uint) (*model.OrganizationUsage, error) {
+	ctx = tenant.WithOrganization(ctx, id)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return &model.OrganizationUsage{
+		Agents:       countRows(ctx, s.agents, nil),
+		PackageBytes: s.sumPackageBytes(ctx),
+		Users:        countRows(ctx, s.users, nil),
+	}, nil
+}
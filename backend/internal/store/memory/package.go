@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreatePackage 创建软件包, 名称、版本、平台和架构在组织内唯一
+func (s *Store) CreatePackage(ctx context.Context, pkg *model.Package) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prepareCreate(ctx, pkg)
+	if s.packageTaken(pkg) {
+		return fmt.Errorf("failed to create package: %w", duplicateKeyError("idx_package_unique"))
+	}
+	insertRow(ctx, s, "packages", s.packages, pkg, &pkg.ID)
+	return nil
+}
+
+// packageTaken 检查组织内是否已有相同版本的其他软件包
+func (s *Store) packageTaken(pkg *model.Package) bool {
+	for _, other := range s.packages {
+		if other.ID != pkg.ID && other.OrganizationID == pkg.OrganizationID &&
+			other.Name == pkg.Name && other.Version == pkg.Version &&
+			other.Platform == pkg.Platform && other.Arch == pkg.Arch {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPackage 获取软件包
+func (s *Store) GetPackage(ctx context.Context, id uint) (*model.Package, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pkg := getRow(ctx, s.packages, id)
+	if pkg == nil {
+		return nil, fmt.Errorf("failed to get package: %w", errRecordNotFound)
+	}
+	return pkg, nil
+}
+
+// GetPackageByVersion 根据版本获取软件包
+func (s *Store) GetPackageByVersion(ctx context.Context, name, version, platform, arch string) (*model.Package, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	packages := selectRows(ctx, s.packages, func(pkg *model.Package) bool {
+		return pkg.Name == name && pkg.Version == version && pkg.Platform == platform && pkg.Arch == arch
+	})
+	sortByID(packages, func(pkg *model.Package) uint { return pkg.ID })
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("failed to get package: %w", errRecordNotFound)
+	}
+	return packages[0], nil
+}
+
+// ListPackages 列出所有启用的软件包, 按创建时间倒序
+func (s *Store) ListPackages(ctx context.Context) ([]*model.Package, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	packages := selectRows(ctx, s.packages, func(pkg *model.Package) bool {
+		return pkg.IsActive
+	})
+	sort.Slice(packages, func(i, j int) bool {
+		if !packages[i].CreatedAt.Equal(packages[j].CreatedAt) {
+			return packages[i].CreatedAt.After(packages[j].CreatedAt)
+		}
+		return packages[i].ID > packages[j].ID
+	})
+	return packages, nil
+}
+
+// GetLatestPackage 获取最新版本的软件包
+//
+// 与 PostgreSQL 存储一致, 版本号按字符串比较。
+func (s *Store) GetLatestPackage(ctx context.Context, name, platform, arch string) (*model.Package, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	packages := selectRows(ctx, s.packages, func(pkg *model.Package) bool {
+		return pkg.Name == name && pkg.Platform == platform && pkg.Arch == arch && pkg.IsActive
+	})
+	sort.Slice(packages, func(i, j int) bool {
+		if packages[i].Version != packages[j].Version {
+			return packages[i].Version > packages[j].Version
+		}
+		if !packages[i].CreatedAt.Equal(packages[j].CreatedAt) {
+			return packages[i].CreatedAt.After(packages[j].CreatedAt)
+		}
+		return packages[i].ID < packages[j].ID
+	})
+	if len(packages) == 0 {
+		return nil, fmt.Errorf("failed to get latest package: %w", errRecordNotFound)
+	}
+	return packages[0], nil
+}
+
+// UpdatePackage 更新软件包
+func (s *Store) UpdatePackage(ctx context.Context, pkg *model.Package) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.packages[pkg.ID]; ok {
+		updated := clone(pkg)
+		s.prepareUpdate(ctx, updated, existing)
+		if s.packageTaken(updated) {
+			return fmt.Errorf("failed to update package: %w", duplicateKeyError("idx_package_unique"))
+		}
+	}
+	saveRow(ctx, s, "packages", s.packages, pkg, &pkg.ID)
+	return nil
+}
+
+// DeletePackage 删除软件包
+func (s *Store) DeletePackage(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleteRow(ctx, s.packages, id)
+	return nil
+}
@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateRefreshToken 创建刷新令牌
+func (s *Store) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, other := range s.refreshTokens {
+		if other.TokenHash == token.TokenHash {
+			return duplicateKeyError("idx_refresh_tokens_token_hash")
+		}
+	}
+	insertRow(ctx, s, "refresh_tokens", s.refreshTokens, token, &token.ID)
+	return nil
+}
+
+// GetRefreshTokenByHash 根据令牌哈希获取刷新令牌
+func (s *Store) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := selectRows(ctx, s.refreshTokens, func(token *model.RefreshToken) bool {
+		return token.TokenHash == tokenHash
+	})
+	return first(tokens), nil
+}
+
+// RevokeRefreshToken 撤销单个刷新令牌
+//
+// 返回 false 表示令牌已经被撤销 (例如被并发的刷新请求使用)。
+func (s *Store) RevokeRefreshToken(ctx context.Context, id uint) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[id]
+	if !ok || token.RevokedAt != nil {
+		return false, nil
+	}
+	t := now()
+	token.RevokedAt = &t
+	return true, nil
+}
+
+// RevokeRefreshTokenFamily 撤销同一登录会话的所有刷新令牌
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	s.revokeRefreshTokens(func(token *model.RefreshToken) bool {
+		return token.FamilyID == familyID
+	})
+	return nil
+}
+
+// RevokeUserRefreshTokens 撤销用户的所有刷新令牌
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID uint) error {
+	s.revokeRefreshTokens(func(token *model.RefreshToken) bool {
+		return token.UserID == userID
+	})
+	return nil
+}
+
+// revokeRefreshTokens 撤销满足条件且尚未撤销的刷新令牌
+func (s *Store) revokeRefreshTokens(match func(*model.RefreshToken) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := now()
+	for _, token := range s.refreshTokens {
+		if token.RevokedAt == nil && match(token) {
+			revokedAt := t
+			token.RevokedAt = &revokedAt
+		}
+	}
+}
+
+// RevokeAccessToken 撤销单个访问令牌, 已撤销时返回已有的记录
+func (s *Store) RevokeAccessToken(ctx context.Context, token *model.RevokedAccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.revokedAccessTokens[token.JTI]; ok {
+		*token = *clone(existing)
+		return nil
+	}
+	s.prepareCreate(ctx, token)
+	s.revokedAccessTokens[token.JTI] = clone(token)
+	return nil
+}
+
+// IsAccessTokenRevoked 检查访问令牌是否已被单独撤销
+func (s *Store) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.revokedAccessTokens[jti]
+	return ok, nil
+}
+
+// DeleteExpiredTokens 清理已过期的刷新令牌和撤销记录
+func (s *Store) DeleteExpiredTokens(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for jti, token := range s.revokedAccessTokens {
+		if token.ExpiresAt.Before(before) {
+			delete(s.revokedAccessTokens, jti)
+		}
+	}
+	for id, token := range s.refreshTokens {
+		if token.ExpiresAt.Before(before) {
+			delete(s.refreshTokens, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// retentionRow 参与保留策略计算的历史记录
+type retentionRow struct {
+	id             uint
+	organizationID uint
+	group          string
+	ts             time.Time
+	// order 组内从新到旧的排序值
+	order     int64
+	protected bool
+}
+
+// retentionRows 返回历史表中参与保留策略计算的记录和最少保留数, 调用方持有锁
+//
+// 分组、排序和保护条件与 PostgreSQL 存储的 retentionTables 一致。
+func (s *Store) retentionRows(table model.RetentionTable) ([]retentionRow, int, error) {
+	var rows []retentionRow
+	switch table {
+	case model.RetentionTableConnectionHistory:
+		for _, h := range s.connectionHistory {
+			rows = append(rows, retentionRow{
+				id: h.ID, organizationID: h.OrganizationID, group: h.AgentID,
+				ts: h.ConnectedAt, order: h.ConnectedAt.UnixNano(), protected: h.DisconnectedAt == nil,
+			})
+		}
+		return rows, 0, nil
+	case model.RetentionTableApplyHistory:
+		for _, h := range s.applyHistory {
+			rows = append(rows, retentionRow{
+				id: h.ID, organizationID: h.OrganizationID, group: h.AgentID,
+				ts: h.CreatedAt, order: h.CreatedAt.UnixNano(),
+				protected: h.Status != model.ApplyStatusApplied && h.Status != model.ApplyStatusFailed,
+			})
+		}
+		return rows, 0, nil
+	case model.RetentionTableConfigurationHistory:
+		// 至少保留每个配置的最新版本
+		for _, h := range s.configurationHistory {
+			rows = append(rows, retentionRow{
+				id: h.ID, organizationID: h.OrganizationID, group: h.ConfigurationName,
+				ts: h.CreatedAt, order: int64(h.Version),
+			})
+		}
+		return rows, 1, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown retention table: %s", table)
+	}
+}
+
+// deleteHistoryRow 删除历史表中的一条记录, 调用方持有锁
+func (s *Store) deleteHistoryRow(table model.RetentionTable, id uint) (bool, error) {
+	var found bool
+	switch table {
+	case model.RetentionTableConnectionHistory:
+		_, found = s.connectionHistory[id]
+		delete(s.connectionHistory, id)
+	case model.RetentionTableApplyHistory:
+		_, found = s.applyHistory[id]
+		delete(s.applyHistory, id)
+	case model.RetentionTableConfigurationHistory:
+		_, found = s.configurationHistory[id]
+		delete(s.configurationHistory, id)
+	default:
+		return false, fmt.Errorf("unknown retention table: %s", table)
+	}
+	return found, nil
+}
+
+// prunableHistory 返回按策略可以删除的记录 ID, 按 ID 排序, 调用方持有锁
+func (s *Store) prunableHistory(policy model.RetentionPolicy, now time.Time) ([]uint, error) {
+	rows, minKeep, err := s.retentionRows(policy.Table)
+	if err != nil || (policy.MaxAgeSeconds <= 0 && policy.MaxRows <= 0) {
+		return nil, err
+	}
+
+	type groupKey struct {
+		organizationID uint
+		group          string
+	}
+	groups := make(map[groupKey][]retentionRow)
+	for _, row := range rows {
+		key := groupKey{row.organizationID, row.group}
+		groups[key] = append(groups[key], row)
+	}
+
+	keepLast := max(policy.KeepLast, minKeep)
+	cutoff := now.Add(-policy.MaxAge())
+	var ids []uint
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool {
+			if group[i].order != group[j].order {
+				return group[i].order > group[j].order
+			}
+			return group[i].id > group[j].id
+		})
+		for i, row := range group {
+			rn := i + 1
+			expired := (policy.MaxAgeSeconds > 0 && row.ts.Before(cutoff)) || (policy.MaxRows > 0 && rn > policy.MaxRows)
+			if expired && rn > keepLast && !row.protected {
+				ids = append(ids, row.id)
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// CountPrunableHistory 统计按策略可以删除的历史记录数, 用于试运行
+//
+// 保留策略作用于所有组织。
+func (s *Store) CountPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids, err := s.prunableHistory(policy, now)
+	return int64(len(ids)), err
+}
+
+// ListPrunableHistory 返回按策略可以删除的历史记录 ID, 按 ID 从小到大排序
+func (s *Store) ListPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) ([]uint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.prunableHistory(policy, now)
+}
+
+// DeleteHistoryBatch 删除历史表中指定 ID 的记录, 返回删除的记录数
+func (s *Store) DeleteHistoryBatch(ctx context.Context, table model.RetentionTable, ids []uint) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for _, id := range ids {
+		found, err := s.deleteHistoryRow(table, id)
+		if err != nil {
+			return deleted, err
+		}
+		if found {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
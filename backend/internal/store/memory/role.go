@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateRole 创建自定义角色, 角色名称在组织内唯一
+func (s *Store) CreateRole(ctx context.Context, role *model.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prepareCreate(ctx, role)
+	if s.roleNameTaken(role) {
+		return duplicateKeyError("idx_roles_org_name")
+	}
+	insertRow(ctx, s, "roles", s.roles, role, &role.ID)
+	return nil
+}
+
+// roleNameTaken 检查组织内是否已有同名的其他角色
+func (s *Store) roleNameTaken(role *model.Role) bool {
+	for _, other := range s.roles {
+		if other.ID != role.ID && other.OrganizationID == role.OrganizationID && other.Name == role.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRoleByName 根据名称获取自定义角色
+func (s *Store) GetRoleByName(ctx context.Context, name string) (*model.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := selectRows(ctx, s.roles, func(role *model.Role) bool {
+		return role.Name == name
+	})
+	sortByID(roles, func(role *model.Role) uint { return role.ID })
+	return first(roles), nil
+}
+
+// ListRoles 列出所有自定义角色, 按名称排序
+func (s *Store) ListRoles(ctx context.Context) ([]*model.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	roles := selectRows(ctx, s.roles, nil)
+	sort.Slice(roles, func(i, j int) bool {
+		if roles[i].Name != roles[j].Name {
+			return roles[i].Name < roles[j].Name
+		}
+		return roles[i].ID < roles[j].ID
+	})
+	return roles, nil
+}
+
+// UpdateRole 更新自定义角色
+func (s *Store) UpdateRole(ctx context.Context, role *model.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.roles[role.ID]; ok {
+		renamed := clone(role)
+		s.prepareUpdate(ctx, renamed, existing)
+		if s.roleNameTaken(renamed) {
+			return duplicateKeyError("idx_roles_org_name")
+		}
+	}
+	saveRow(ctx, s, "roles", s.roles, role, &role.ID)
+	return nil
+}
+
+// DeleteRole 删除自定义角色
+func (s *Store) DeleteRole(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, role := range s.roles {
+		if role.Name == name && visible(ctx, role) {
+			delete(s.roles, id)
+		}
+	}
+	return nil
+}
+
+// CountUsersWithRole 统计使用指定角色的用户数
+func (s *Store) CountUsersWithRole(ctx context.Context, name string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return countRows(ctx, s.users, func(user *model.User) bool {
+		return user.Role == name
+	}), nil
+}
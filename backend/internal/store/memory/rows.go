@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"sort"
+)
+
+// clone 深拷贝记录, 保存和返回的记录与调用方的对象互不影响
+func clone[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+	c := new(T)
+	deepCopy(reflect.ValueOf(c).Elem(), reflect.ValueOf(v).Elem())
+	return c
+}
+
+// cloneAll 深拷贝多条记录
+func cloneAll[T any](rows []*T) []*T {
+	result := make([]*T, len(rows))
+	for i, row := range rows {
+		result[i] = clone(row)
+	}
+	return result
+}
+
+// deepCopy 把 src 复制到 dst, 指针、map、切片和接口指向的值同样复制
+//
+// 结构体的未导出字段 (如 time.Time 的内部字段) 按值复制。
+func deepCopy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		p := reflect.New(src.Type().Elem())
+		deepCopy(p.Elem(), src.Elem())
+		dst.Set(p)
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			value := reflect.New(src.Type().Elem()).Elem()
+			deepCopy(value, iter.Value())
+			m.SetMapIndex(iter.Key(), value)
+		}
+		dst.Set(m)
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		s := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			deepCopy(s.Index(i), src.Index(i))
+		}
+		dst.Set(s)
+	case reflect.Interface:
+		if src.IsNil() {
+			dst.Set(reflect.Zero(src.Type()))
+			return
+		}
+		value := reflect.New(src.Elem().Type()).Elem()
+		deepCopy(value, src.Elem())
+		dst.Set(value)
+	case reflect.Struct:
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				deepCopy(dst.Field(i), src.Field(i))
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// selectRows 返回 context 组织内满足 match 的记录副本, match 为 nil 时返回所有记录
+func selectRows[K comparable, T any](ctx context.Context, rows map[K]*T, match func(*T) bool) []*T {
+	result := make([]*T, 0)
+	for _, row := range rows {
+		if visible(ctx, row) && (match == nil || match(row)) {
+			result = append(result, clone(row))
+		}
+	}
+	return result
+}
+
+// countRows 统计 context 组织内满足 match 的记录数
+func countRows[K comparable, T any](ctx context.Context, rows map[K]*T, match func(*T) bool) int64 {
+	var count int64
+	for _, row := range rows {
+		if visible(ctx, row) && (match == nil || match(row)) {
+			count++
+		}
+	}
+	return count
+}
+
+// sortByID 按自增主键升序排序, 即按创建顺序
+func sortByID[T any](rows []*T, id func(*T) uint) {
+	sort.Slice(rows, func(i, j int) bool { return id(rows[i]) < id(rows[j]) })
+}
+
+// first 返回第一条记录, 没有记录时返回 nil
+func first[T any](rows []*T) *T {
+	if len(rows) == 0 {
+		return nil
+	}
+	return rows[0]
+}
+
+// paginate 按 LIMIT 和 OFFSET 截取记录, 与 GORM 一致 limit 为负数时不限制条数
+func paginate[T any](rows []T, limit, offset int) []T {
+	if offset > 0 {
+		if offset >= len(rows) {
+			return rows[:0]
+		}
+		rows = rows[offset:]
+	}
+	if limit >= 0 && limit < len(rows) {
+		rows = rows[:limit]
+	}
+	return rows
+}
+
+// insertRow 创建记录并分配自增主键
+func insertRow[T any](ctx context.Context, s *Store, table string, rows map[uint]*T, row *T, id *uint) {
+	s.prepareCreate(ctx, row)
+	s.assignID(table, id)
+	rows[*id] = clone(row)
+}
+
+// saveRow 整体更新记录
+//
+// 与 GORM 的 Save 一致: 主键为零或记录不存在时创建记录; 记录属于其他组织时不做修改。
+func saveRow[T any](ctx context.Context, s *Store, table string, rows map[uint]*T, row *T, id *uint) {
+	existing, ok := rows[*id]
+	if !ok || *id == 0 {
+		insertRow(ctx, s, table, rows, row, id)
+		return
+	}
+	if !visible(ctx, existing) {
+		return
+	}
+	s.prepareUpdate(ctx, row, existing)
+	rows[*id] = clone(row)
+}
+
+// getRow 返回 context 组织内指定主键的记录副本, 不存在时返回 nil
+func getRow[T any](ctx context.Context, rows map[uint]*T, id uint) *T {
+	row, ok := rows[id]
+	if !ok || !visible(ctx, row) {
+		return nil
+	}
+	return clone(row)
+}
+
+// deleteRow 删除 context 组织内指定主键的记录, 返回是否删除了记录
+func deleteRow[T any](ctx context.Context, rows map[uint]*T, id uint) bool {
+	row, ok := rows[id]
+	if !ok || !visible(ctx, row) {
+		return false
+	}
+	delete(rows, id)
+	return true
+}
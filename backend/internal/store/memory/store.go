@@ -0,0 +1,527 @@
+// Package memory 基于内存的存储实现, 用于测试和开发模式 (server --dev)
+//
+// 行为与 PostgreSQL 存储一致: 按 context 中的组织限定范围, 创建记录时为零值字段
+// 填充列的默认值, 更新配置时保存历史版本。读写都复制记录, 调用方修改传入或返回的
+// 对象不会影响已保存的数据。数据只保存在进程内, 退出后丢失。
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// errRecordNotFound 与 PostgreSQL 存储中 GORM 返回的错误信息相同
+var errRecordNotFound = errors.New("record not found")
+
+// configurationKey 配置的主键, 配置名称在组织内唯一
+type configurationKey struct {
+	OrganizationID uint
+	Name           string
+}
+
+// mfaRoleKey 要求 MFA 的角色的主键
+type mfaRoleKey struct {
+	OrganizationID uint
+	Role           string
+}
+
+// Store 内存存储实现
+//
+// 所有表共用一把读写锁, 每个方法在锁内完成, 相当于 PostgreSQL 中的一个事务。
+type Store struct {
+	mu sync.RWMutex
+
+	organizations        map[uint]*model.Organization
+	agents               map[string]*model.Agent
+	configurations       map[configurationKey]*model.Configuration
+	connectionHistory    map[uint]*model.AgentConnectionHistory
+	configurationHistory map[uint]*model.ConfigurationHistory
+	applyHistory         map[uint]*model.ConfigurationApplyHistory
+	users                map[uint]*model.User
+	packages             map[uint]*model.Package
+	roles                map[uint]*model.Role
+	apiTokens            map[uint]*model.APIToken
+	refreshTokens        map[uint]*model.RefreshToken
+	revokedAccessTokens  map[string]*model.RevokedAccessToken
+	invitations          map[uint]*model.Invitation
+	recoveryCodes        map[uint]*model.RecoveryCode
+	mfaRequiredRoles     map[mfaRoleKey]*model.MFARequiredRole
+	alertRules           map[uint]*model.AlertRule
+	alerts               map[uint]*model.Alert
+	silences             map[uint]*model.Silence
+	webhooks             map[uint]*model.Webhook
+	webhookDeliveries    map[uint]*model.WebhookDelivery
+	auditLogs            map[uint]*model.AuditLog
+	fleetStatsSamples    map[uint]*model.FleetStatsSample
+	bulkJobs             map[uint]*model.BulkJob
+
+	// sequences 每张表的自增主键
+	sequences map[string]uint
+}
+
+var _ store.Store = (*Store)(nil)
+
+// NewStore 创建只包含默认组织的内存存储
+func NewStore() *Store {
+	s := &Store{
+		organizations:        make(map[uint]*model.Organization),
+		agents:               make(map[string]*model.Agent),
+		configurations:       make(map[configurationKey]*model.Configuration),
+		connectionHistory:    make(map[uint]*model.AgentConnectionHistory),
+		configurationHistory: make(map[uint]*model.ConfigurationHistory),
+		applyHistory:         make(map[uint]*model.ConfigurationApplyHistory),
+		users:                make(map[uint]*model.User),
+		packages:             make(map[uint]*model.Package),
+		roles:                make(map[uint]*model.Role),
+		apiTokens:            make(map[uint]*model.APIToken),
+		refreshTokens:        make(map[uint]*model.RefreshToken),
+		revokedAccessTokens:  make(map[string]*model.RevokedAccessToken),
+		invitations:          make(map[uint]*model.Invitation),
+		recoveryCodes:        make(map[uint]*model.RecoveryCode),
+		mfaRequiredRoles:     make(map[mfaRoleKey]*model.MFARequiredRole),
+		alertRules:           make(map[uint]*model.AlertRule),
+		alerts:               make(map[uint]*model.Alert),
+		silences:             make(map[uint]*model.Silence),
+		webhooks:             make(map[uint]*model.Webhook),
+		webhookDeliveries:    make(map[uint]*model.WebhookDelivery),
+		auditLogs:            make(map[uint]*model.AuditLog),
+		fleetStatsSamples:    make(map[uint]*model.FleetStatsSample),
+		bulkJobs:             make(map[uint]*model.BulkJob),
+		sequences:            make(map[string]uint),
+	}
+
+	// 默认组织, 见 model.DefaultOrganizationID
+	org := &model.Organization{ID: model.DefaultOrganizationID, Name: "default", DisplayName: "Default"}
+	s.prepareCreate(context.Background(), org)
+	s.assignID("organizations", &org.ID)
+	s.organizations[org.ID] = org
+	return s
+}
+
+// Ping 内存存储始终可用
+func (s *Store) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close 内存存储没有需要释放的资源
+func (s *Store) Close() error {
+	return nil
+}
+
+// assignID 为新记录分配自增主键, 指定了主键时后续分配的主键从其之后开始
+func (s *Store) assignID(table string, id *uint) {
+	if *id == 0 {
+		s.sequences[table]++
+		*id = s.sequences[table]
+		return
+	}
+	if *id > s.sequences[table] {
+		s.sequences[table] = *id
+	}
+}
+
+// GetAgent 获取 Agent
+func (s *Store) GetAgent(ctx context.Context, agentID string) (*model.Agent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return clone(s.agent(ctx, agentID)), nil
+}
+
+// agent 返回 context 组织内的 Agent, 不存在时返回 nil
+func (s *Store) agent(ctx context.Context, agentID string) *model.Agent {
+	agent, ok := s.agents[agentID]
+	if !ok || !visible(ctx, agent) {
+		return nil
+	}
+	return agent
+}
+
+// UpsertAgent 创建或更新 Agent
+//
+// Agent 已存在时保留只通过专门的方法修改的字段 (服务端标签、指定的配置、退役时间、抖动标记和创建时间),
+// 与 PostgreSQL 存储的 agentManagedColumns 一致。
+func (s *Store) UpsertAgent(ctx context.Context, agent *model.Agent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.agents[agent.ID]; ok {
+		if !visible(ctx, existing) {
+			return duplicateKeyError("agents_pkey")
+		}
+		s.prepareUpdate(ctx, agent, existing)
+		updated := clone(agent)
+		updated.ServerLabels = existing.ServerLabels
+		updated.ConfigurationName = existing.ConfigurationName
+		updated.DecommissionedAt = existing.DecommissionedAt
+		updated.Flapping = existing.Flapping
+		updated.FlappingSince = existing.FlappingSince
+		updated.CreatedAt = existing.CreatedAt
+		s.agents[agent.ID] = updated
+		return nil
+	}
+
+	s.prepareCreate(ctx, agent)
+	s.agents[agent.ID] = clone(agent)
+	return nil
+}
+
+// ListAgents 列出所有 Agent, 按更新时间倒序分页
+func (s *Store) ListAgents(ctx context.Context, limit, offset int) ([]*model.Agent, int64, error) {
+	agents, total, _, err := s.SearchAgents(ctx, model.AgentFilter{}, model.AgentListOptions{
+		Sort:       model.AgentSortUpdatedAt,
+		Descending: true,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	return agents, total, err
+}
+
+// DeleteAgent 删除 Agent
+//
+// 同时删除 Agent 的连接历史和配置应用历史。
+func (s *Store) DeleteAgent(ctx context.Context, agentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteAgentHistory(ctx, []string{agentID})
+	if s.agent(ctx, agentID) != nil {
+		delete(s.agents, agentID)
+	}
+	return nil
+}
+
+// GetConfiguration 获取 Agent 的配置
+//
+// Agent 指定了配置时返回该配置, 否则返回第一个 (按名称排序) 匹配 Agent 标签的配置。
+func (s *Store) GetConfiguration(ctx context.Context, agentID string) (*model.Configuration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	agent := s.agent(ctx, agentID)
+	if agent == nil {
+		return nil, nil
+	}
+
+	if agent.ConfigurationName != "" {
+		return clone(s.configuration(ctx, agent.ConfigurationName)), nil
+	}
+
+	for _, config := range s.sortedConfigurations(ctx) {
+		if config.MatchesAgent(agent) {
+			return clone(config), nil
+		}
+	}
+	return nil, nil
+}
+
+// configuration 返回 context 组织内指定名称的配置, 未限定组织时返回组织 ID 最小的配置
+func (s *Store) configuration(ctx context.Context, name string) *model.Configuration {
+	for _, config := range s.sortedConfigurations(ctx) {
+		if config.Name == name {
+			return config
+		}
+	}
+	return nil
+}
+
+// sortedConfigurations 返回 context 组织内的配置, 按名称和组织排序
+func (s *Store) sortedConfigurations(ctx context.Context) []*model.Configuration {
+	configs := make([]*model.Configuration, 0, len(s.configurations))
+	for _, config := range s.configurations {
+		if visible(ctx, config) {
+			configs = append(configs, config)
+		}
+	}
+	sort.Slice(configs, func(i, j int) bool {
+		if configs[i].Name != configs[j].Name {
+			return configs[i].Name < configs[j].Name
+		}
+		return configs[i].OrganizationID < configs[j].OrganizationID
+	})
+	return configs
+}
+
+// CreateConfiguration 创建配置
+func (s *Store) CreateConfiguration(ctx context.Context, config *model.Configuration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config.UpdateHash()
+	s.prepareCreate(ctx, config)
+	key := configurationKey{OrganizationID: config.OrganizationID, Name: config.Name}
+	if _, ok := s.configurations[key]; ok {
+		return duplicateKeyError("configurations_pkey")
+	}
+	s.configurations[key] = clone(config)
+	return nil
+}
+
+// UpdateConfiguration 更新配置
+//
+// 配置的可编辑字段变化时把当前版本保存到历史记录并递增版本号。
+func (s *Store) UpdateConfiguration(ctx context.Context, config *model.Configuration) error {
+	return s.updateConfiguration(ctx, config, nil)
+}
+
+// UpdateConfigurationIfVersion 仅当配置的当前版本号为 version 时更新配置
+func (s *Store) UpdateConfigurationIfVersion(ctx context.Context, config *model.Configuration, version int) error {
+	return s.updateConfiguration(ctx, config, &version)
+}
+
+// updateConfiguration 更新配置, expectedVersion 不为空时要求当前版本号与其一致
+func (s *Store) updateConfiguration(ctx context.Context, config *model.Configuration, expectedVersion *int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.configuration(ctx, config.Name)
+	if existing == nil {
+		return errRecordNotFound
+	}
+	if expectedVersion != nil && existing.Version != *expectedVersion {
+		return store.ErrVersionConflict
+	}
+
+	// 组织是主键的一部分, 沿用已有配置的组织
+	config.OrganizationID = existing.OrganizationID
+	config.UpdateHash()
+
+	if config.ChangedFrom(existing) {
+		history := &model.ConfigurationHistory{
+			OrganizationID:    existing.OrganizationID,
+			ConfigurationName: existing.Name,
+			Version:           existing.Version,
+			ContentType:       existing.ContentType,
+			RawConfig:         existing.RawConfig,
+			ConfigHash:        existing.ConfigHash,
+			Selector:          existing.Selector,
+			Platform:          existing.Platform,
+			CreatedBy:         existing.UpdatedBy,
+			CreatedAt:         existing.UpdatedAt,
+		}
+		s.insertConfigurationHistory(ctx, history)
+		config.Version = existing.Version + 1
+	} else {
+		config.Version = existing.Version
+	}
+
+	s.prepareUpdate(ctx, config, existing)
+	s.configurations[configurationKey{OrganizationID: config.OrganizationID, Name: config.Name}] = clone(config)
+	return nil
+}
+
+// GetConfigurationByName 根据名称获取配置
+func (s *Store) GetConfigurationByName(ctx context.Context, name string) (*model.Configuration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return clone(s.configuration(ctx, name)), nil
+}
+
+// ListConfigurations 列出所有配置
+func (s *Store) ListConfigurations(ctx context.Context) ([]*model.Configuration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return cloneAll(s.sortedConfigurations(ctx)), nil
+}
+
+// DeleteConfiguration 删除配置
+func (s *Store) DeleteConfiguration(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, config := range s.configurations {
+		if key.Name == name && visible(ctx, config) {
+			delete(s.configurations, key)
+			s.deleteConfigurationHistory(key)
+		}
+	}
+	return nil
+}
+
+// DeleteConfigurationIfVersion 仅当配置的当前版本号为 version 时删除配置, 配置不存在时同样返回 store.ErrVersionConflict
+func (s *Store) DeleteConfigurationIfVersion(ctx context.Context, name string, version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.configuration(ctx, name)
+	if existing == nil || existing.Version != version {
+		return store.ErrVersionConflict
+	}
+	key := configurationKey{OrganizationID: existing.OrganizationID, Name: name}
+	delete(s.configurations, key)
+	s.deleteConfigurationHistory(key)
+	return nil
+}
+
+// deleteConfigurationHistory 删除配置的历史版本和应用历史, 与 PostgreSQL 中的级联删除一致
+func (s *Store) deleteConfigurationHistory(key configurationKey) {
+	for id, history := range s.configurationHistory {
+		if history.OrganizationID == key.OrganizationID && history.ConfigurationName == key.Name {
+			delete(s.configurationHistory, id)
+		}
+	}
+	for id, history := range s.applyHistory {
+		if history.OrganizationID == key.OrganizationID && history.ConfigurationName == key.Name {
+			delete(s.applyHistory, id)
+		}
+	}
+}
+
+// CreateUser 创建用户
+func (s *Store) CreateUser(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insertUser(ctx, user)
+}
+
+// insertUser 创建用户, 用户名、邮箱和外部身份标识全局唯一
+func (s *Store) insertUser(ctx context.Context, user *model.User) error {
+	if err := s.checkUserUnique(user); err != nil {
+		return err
+	}
+	if user.ID != 0 {
+		if _, ok := s.users[user.ID]; ok {
+			return duplicateKeyError("users_pkey")
+		}
+	}
+	if err := user.BeforeCreate(nil); err != nil {
+		return err
+	}
+
+	s.prepareCreate(ctx, user)
+	s.assignID("users", &user.ID)
+	s.users[user.ID] = clone(user)
+	return nil
+}
+
+// checkUserUnique 检查用户名、邮箱和外部身份标识是否被其他用户使用
+func (s *Store) checkUserUnique(user *model.User) error {
+	for _, other := range s.users {
+		if other.ID == user.ID {
+			continue
+		}
+		switch {
+		case other.Username == user.Username:
+			return duplicateKeyError("idx_users_username")
+		case other.Email == user.Email:
+			return duplicateKeyError("idx_users_email")
+		case other.ExternalID != nil && user.ExternalID != nil && *other.ExternalID == *user.ExternalID:
+			return duplicateKeyError("idx_users_external_id")
+		}
+	}
+	return nil
+}
+
+// GetUserByUsername 根据用户名获取用户
+//
+// 用户名、邮箱和外部身份标识全局唯一, 这几个查询不限定组织。
+func (s *Store) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	return s.findUser(tenant.WithoutOrganization(ctx), func(user *model.User) bool {
+		return user.Username == username
+	}), nil
+}
+
+// GetUserByEmail 根据邮箱获取用户
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	return s.findUser(tenant.WithoutOrganization(ctx), func(user *model.User) bool {
+		return user.Email == email
+	}), nil
+}
+
+// GetUserByExternalID 根据外部身份标识获取用户
+func (s *Store) GetUserByExternalID(ctx context.Context, externalID string) (*model.User, error) {
+	return s.findUser(tenant.WithoutOrganization(ctx), func(user *model.User) bool {
+		return user.ExternalID != nil && *user.ExternalID == externalID
+	}), nil
+}
+
+// GetUserByID 根据 ID 获取用户
+func (s *Store) GetUserByID(ctx context.Context, id uint) (*model.User, error) {
+	return s.findUser(ctx, func(user *model.User) bool {
+		return user.ID == id
+	}), nil
+}
+
+// findUser 返回第一个满足条件的用户
+func (s *Store) findUser(ctx context.Context, match func(*model.User) bool) *model.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := selectRows(ctx, s.users, match)
+	sortByID(users, func(user *model.User) uint { return user.ID })
+	return first(users)
+}
+
+// UpdateUser 更新用户
+func (s *Store) UpdateUser(ctx context.Context, user *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[user.ID]
+	if !ok || user.ID == 0 {
+		return s.insertUser(ctx, user)
+	}
+	if !visible(ctx, existing) {
+		return nil
+	}
+	if err := s.checkUserUnique(user); err != nil {
+		return err
+	}
+	s.prepareUpdate(ctx, user, existing)
+	s.users[user.ID] = clone(user)
+	return nil
+}
+
+// ListUsers 列出所有用户
+func (s *Store) ListUsers(ctx context.Context) ([]*model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := selectRows(ctx, s.users, nil)
+	sortByID(users, func(user *model.User) uint { return user.ID })
+	return users, nil
+}
+
+// DeleteUser 删除用户
+func (s *Store) DeleteUser(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[id]; ok && visible(ctx, user) {
+		delete(s.users, id)
+	}
+	return nil
+}
+
+// CountUsers 统计用户总数
+func (s *Store) CountUsers(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return countRows(ctx, s.users, nil), nil
+}
+
+// CountActiveUsersWithRole 统计指定角色的激活用户数
+func (s *Store) CountActiveUsersWithRole(ctx context.Context, role string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return countRows(ctx, s.users, func(user *model.User) bool {
+		return user.Role == role && user.IsActive
+	}), nil
+}
+
+// duplicateKeyError 违反唯一约束时返回的错误
+func duplicateKeyError(constraint string) error {
+	return fmt.Errorf("duplicate key value violates unique constraint %q", constraint)
+}
+
+// now 返回当前时间, 与 GORM 写入的时间一致使用本地时区
+func now() time.Time {
+	return time.Now().Local()
+}
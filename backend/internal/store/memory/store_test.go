@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/store/storetest"
+)
+
+func TestStore_Contract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return NewStore()
+	})
+}
+
+func TestStore_ReturnsCopies(t *testing.T) {
+	s := NewStore()
+	ctx := context.Background()
+
+	agent := &model.Agent{ID: "agent-1", Labels: model.Labels{"env": "prod"}}
+	require.NoError(t, s.UpsertAgent(ctx, agent))
+
+	// 修改传入和返回的对象不影响存储中的记录
+	agent.Labels["env"] = "dev"
+	stored, err := s.GetAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	assert.Equal(t, "prod", stored.Labels["env"])
+
+	stored.Labels["env"] = "staging"
+	stored.Name = "changed"
+	stored, err = s.GetAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	assert.Equal(t, "prod", stored.Labels["env"])
+	assert.Empty(t, stored.Name)
+}
+
+func TestStore_DeleteConfigurationCascades(t *testing.T) {
+	s := NewStore()
+	ctx := context.Background()
+
+	config := &model.Configuration{Name: "base", RawConfig: "a: 1"}
+	require.NoError(t, s.CreateConfiguration(ctx, config))
+	config.RawConfig = "a: 2"
+	require.NoError(t, s.UpdateConfiguration(ctx, config))
+	require.NoError(t, s.CreateApplyHistory(ctx, &model.ConfigurationApplyHistory{AgentID: "agent-1", ConfigurationName: "base"}))
+
+	require.NoError(t, s.DeleteConfiguration(ctx, "base"))
+
+	_, total, err := s.ListConfigurationHistory(ctx, "base", 10, 0)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+	_, total, err = s.ListApplyHistoryByConfig(ctx, "base", 10, 0)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestStore_ConcurrentWrites(t *testing.T) {
+	s := NewStore()
+	ctx := context.Background()
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "busy-agent", Protocol: "opamp"}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.UpdateAgentServerLabels(ctx, "busy-agent", func(a *model.Agent) error {
+				// 回调中可以访问存储
+				_, err := s.GetAgent(ctx, a.ID)
+				a.ServerLabels = a.ServerLabels.Apply(model.Labels{fmt.Sprintf("k%d", i): "v"}, nil)
+				return err
+			})
+			assert.NoError(t, err)
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+			assert.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: fmt.Sprintf("agent-%d", i)}))
+		}(i)
+	}
+	wg.Wait()
+
+	agent, err := s.GetAgent(ctx, "busy-agent")
+	require.NoError(t, err)
+	assert.Len(t, agent.ServerLabels, 20)
+
+	count, err := s.CountAgents(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(21), count)
+}
@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// organizationField 带有该字段的模型属于某个组织
+const organizationField = "OrganizationID"
+
+// organizationOf 返回记录所属的组织, 不属于组织的模型返回 false
+func organizationOf(v interface{}) (uint, bool) {
+	field := reflect.Indirect(reflect.ValueOf(v)).FieldByName(organizationField)
+	if !field.IsValid() {
+		return 0, false
+	}
+	return uint(field.Uint()), true
+}
+
+// setOrganizationOf 设置记录所属的组织, 不属于组织的模型不做修改
+func setOrganizationOf(v interface{}, id uint) {
+	field := reflect.Indirect(reflect.ValueOf(v)).FieldByName(organizationField)
+	if field.IsValid() {
+		field.SetUint(uint64(id))
+	}
+}
+
+// visible 检查记录是否在 context 绑定的组织内
+//
+// 与 PostgreSQL 存储的租户回调一致: context 未绑定组织时所有记录可见,
+// 不属于组织的模型总是可见。
+func visible(ctx context.Context, v interface{}) bool {
+	orgID, ok := tenant.OrganizationID(ctx)
+	if !ok {
+		return true
+	}
+	id, scoped := organizationOf(v)
+	return !scoped || id == orgID
+}
+
+// prepareCreate 为新记录设置组织、创建时间和列的默认值
+//
+// context 绑定了组织时强制写入该组织, 未绑定时未指定组织的记录归入默认组织。
+func (s *Store) prepareCreate(ctx context.Context, v interface{}) {
+	if orgID, ok := tenant.OrganizationID(ctx); ok {
+		setOrganizationOf(v, orgID)
+	} else if id, scoped := organizationOf(v); scoped && id == 0 {
+		setOrganizationOf(v, model.DefaultOrganizationID)
+	}
+
+	t := now()
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	for _, name := range []string{"CreatedAt", "UpdatedAt"} {
+		if field := rv.FieldByName(name); field.IsValid() && field.Type() == reflect.TypeOf(t) && field.IsZero() {
+			field.Set(reflect.ValueOf(t))
+		}
+	}
+
+	applyDefaults(v)
+}
+
+// prepareUpdate 为整体更新的记录设置组织和更新时间
+//
+// context 绑定了组织时强制写入该组织; 未绑定时记录中未设置的组织沿用已有记录的组织。
+func (s *Store) prepareUpdate(ctx context.Context, v, existing interface{}) {
+	if orgID, ok := tenant.OrganizationID(ctx); ok {
+		setOrganizationOf(v, orgID)
+	} else if id, scoped := organizationOf(v); scoped && id == 0 {
+		current, _ := organizationOf(existing)
+		setOrganizationOf(v, current)
+	}
+	touch(v)
+}
+
+// touch 更新记录的更新时间, 与 GORM 的 autoUpdateTime 一致
+func touch(v interface{}) {
+	if field := reflect.Indirect(reflect.ValueOf(v)).FieldByName("UpdatedAt"); field.IsValid() && field.Type() == reflect.TypeOf(time.Time{}) {
+		field.Set(reflect.ValueOf(now()))
+	}
+}
+
+// applyDefaults 为零值字段填充模型中声明的列默认值 (gorm default 标签)
+//
+// 与 GORM 一致, 零值和未设置无法区分, 例如创建 IsActive 为 false 的用户时同样使用默认值 true。
+func applyDefaults(v interface{}) {
+	switch m := v.(type) {
+	case *model.Agent:
+		if m.Status == "" {
+			m.Status = model.StatusOffline
+		}
+	case *model.Configuration:
+		if m.Version == 0 {
+			m.Version = 1
+		}
+	case *model.ConfigurationHistory:
+		if m.ContentType == "" {
+			m.ContentType = "yaml"
+		}
+	case *model.ConfigurationApplyHistory:
+		if m.Status == "" {
+			m.Status = model.ApplyStatusPending
+		}
+	case *model.User:
+		if m.Role == "" {
+			m.Role = "user"
+		}
+		if m.AuthProvider == "" {
+			m.AuthProvider = model.AuthProviderLocal
+		}
+		m.IsActive = true
+	case *model.Package:
+		m.IsActive = true
+	case *model.AlertRule:
+		if m.Severity == "" {
+			m.Severity = model.SeverityWarning
+		}
+	case *model.WebhookDelivery:
+		if m.Status == "" {
+			m.Status = model.DeliveryStatusPending
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateWebhook 创建 Webhook 订阅, 名称在组织内唯一
+func (s *Store) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prepareCreate(ctx, webhook)
+	if s.webhookNameTaken(webhook) {
+		return duplicateKeyError("idx_webhooks_org_name")
+	}
+	insertRow(ctx, s, "webhooks", s.webhooks, webhook, &webhook.ID)
+	return nil
+}
+
+// webhookNameTaken 检查组织内是否已有同名的其他 Webhook
+func (s *Store) webhookNameTaken(webhook *model.Webhook) bool {
+	for _, other := range s.webhooks {
+		if other.ID != webhook.ID && other.OrganizationID == webhook.OrganizationID && other.Name == webhook.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWebhook 获取 Webhook 订阅
+func (s *Store) GetWebhook(ctx context.Context, id uint) (*model.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getRow(ctx, s.webhooks, id), nil
+}
+
+// ListWebhooks 列出所有 Webhook 订阅
+func (s *Store) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	webhooks := selectRows(ctx, s.webhooks, nil)
+	sortByID(webhooks, func(webhook *model.Webhook) uint { return webhook.ID })
+	return webhooks, nil
+}
+
+// UpdateWebhook 更新 Webhook 订阅
+func (s *Store) UpdateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.webhooks[webhook.ID]; ok {
+		renamed := clone(webhook)
+		s.prepareUpdate(ctx, renamed, existing)
+		if s.webhookNameTaken(renamed) {
+			return duplicateKeyError("idx_webhooks_org_name")
+		}
+	}
+	saveRow(ctx, s, "webhooks", s.webhooks, webhook, &webhook.ID)
+	return nil
+}
+
+// DeleteWebhook 删除 Webhook 订阅及其投递记录
+func (s *Store) DeleteWebhook(ctx context.Context, id uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for deliveryID, delivery := range s.webhookDeliveries {
+		if delivery.WebhookID == id {
+			delete(s.webhookDeliveries, deliveryID)
+		}
+	}
+	deleteRow(ctx, s.webhooks, id)
+	return nil
+}
+
+// CreateWebhookDelivery 创建投递记录
+func (s *Store) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	insertRow(ctx, s, "webhook_deliveries", s.webhookDeliveries, delivery, &delivery.ID)
+	return nil
+}
+
+// UpdateWebhookDelivery 更新投递记录
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saveRow(ctx, s, "webhook_deliveries", s.webhookDeliveries, delivery, &delivery.ID)
+	return nil
+}
+
+// ListWebhookDeliveries 列出 Webhook 的投递记录, 按创建时间倒序
+func (s *Store) ListWebhookDeliveries(ctx context.Context, webhookID uint, limit, offset int) ([]*model.WebhookDelivery, int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deliveries := selectRows(ctx, s.webhookDeliveries, func(delivery *model.WebhookDelivery) bool {
+		return delivery.WebhookID == webhookID
+	})
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	return paginate(deliveries, limit, offset), int64(len(deliveries)), nil
+}
+
+// ListDueWebhookDeliveries 列出到达重试时间的待投递记录
+func (s *Store) ListDueWebhookDeliveries(ctx context.Context, before time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	deliveries := selectRows(ctx, s.webhookDeliveries, func(delivery *model.WebhookDelivery) bool {
+		return delivery.Status == model.DeliveryStatusPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(before)
+	})
+	sort.Slice(deliveries, func(i, j int) bool {
+		a, b := deliveries[i].NextAttemptAt, deliveries[j].NextAttemptAt
+		if !a.Equal(*b) {
+			return a.Before(*b)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	return paginate(deliveries, limit, 0), nil
+}
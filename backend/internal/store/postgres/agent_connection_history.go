@@ -165,3 +165,55 @@ func (s *Store) ListStaleAgents(ctx context.Context, timeout time.Duration) ([]*
 
 	return agents, err
 }
+
+// ListConnectionHistorySince 列出自指定时间以来建立的所有连接记录
+func (s *Store) ListConnectionHistorySince(ctx context.Context, since time.Time) ([]*model.AgentConnectionHistory, error) {
+	var histories []*model.AgentConnectionHistory
+	err := s.db.WithContext(ctx).
+		Where("connected_at >= ?", since).
+		Order("connected_at ASC").
+		Find(&histories).Error
+	return histories, err
+}
+
+// ListFlappingAgents 列出当前被标记为抖动的 Agent
+func (s *Store) ListFlappingAgents(ctx context.Context) ([]*model.Agent, error) {
+	var agents []*model.Agent
+	err := s.db.WithContext(ctx).
+		Where("flapping = ?", true).
+		Find(&agents).Error
+	return agents, err
+}
+
+// SetAgentFlapping 设置 Agent 的抖动标记
+func (s *Store) SetAgentFlapping(ctx context.Context, agentID string, flapping bool) error {
+	updates := map[string]interface{}{
+		"flapping":       flapping,
+		"flapping_since": nil,
+	}
+	if flapping {
+		updates["flapping_since"] = time.Now()
+	}
+
+	return s.db.WithContext(ctx).
+		Model(&model.Agent{}).
+		Where("id = ?", agentID).
+		Updates(updates).Error
+}
+
+// ListConnectionHistoryInRange 列出指定 Agent 与 [from, to) 有交集的连接记录
+//
+// 调用方按批次传入 Agent, 避免一次加载整个集群的连接历史。
+func (s *Store) ListConnectionHistoryInRange(ctx context.Context, agentIDs []string, from, to time.Time) ([]*model.AgentConnectionHistory, error) {
+	if len(agentIDs) == 0 {
+		return nil, nil
+	}
+	var histories []*model.AgentConnectionHistory
+	err := s.db.WithContext(ctx).
+		Where("agent_id IN ?", agentIDs).
+		Where("connected_at < ?", to).
+		Where("disconnected_at IS NULL OR disconnected_at > ?", from).
+		Order("connected_at ASC").
+		Find(&histories).Error
+	return histories, err
+}
@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// UpdateAgentServerLabels 修改 Agent 的服务端标签并重新计算生效的标签
+//
+// update 修改传入 Agent 的 ServerLabels, 返回错误时放弃修改。update 在行锁内执行,
+// 因此并发的标签修改不会互相覆盖。Agent 不存在时返回 nil。
+func (s *Store) UpdateAgentServerLabels(ctx context.Context, agentID string, update func(agent *model.Agent) error) (*model.Agent, error) {
+	var agent model.Agent
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// SQLite 不支持行锁, 写事务本身是串行的
+		query := tx
+		if !isSQLite(tx) {
+			query = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		if err := query.Where("id = ?", agentID).First(&agent).Error; err != nil {
+			return err
+		}
+
+		// 启用服务端标签之前的 Agent 没有单独保存上报标签, 以当前标签作为上报标签
+		if agent.ReportedLabels == nil {
+			agent.ReportedLabels = agent.Labels.Merge(nil)
+		}
+		if err := update(&agent); err != nil {
+			return err
+		}
+		agent.RefreshLabels()
+
+		return tx.Model(&agent).Select("labels", "reported_labels", "server_labels").Updates(&agent).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &agent, nil
+}
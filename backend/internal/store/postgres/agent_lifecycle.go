@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// DecommissionAgent 将 Agent 标记为退役, purgeHistory 为 true 时同时删除其历史记录
+//
+// 退役的 Agent 保留记录, 再次连接时被拒绝。Agent 不存在时返回 false。
+func (s *Store) DecommissionAgent(ctx context.Context, agentID string, purgeHistory bool) (bool, error) {
+	found := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&model.Agent{}).Where("id = ?", agentID).Updates(map[string]interface{}{
+			"decommissioned_at": now,
+			"status":            model.StatusOffline,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		found = true
+
+		if purgeHistory {
+			return deleteAgentHistory(tx, []string{agentID})
+		}
+		return nil
+	})
+	return found, err
+}
+
+// DeleteAgentsOfflineBefore 删除 before 之后一直没有上报的离线 Agent 及其历史记录, 返回删除的 Agent ID
+//
+// 从未上报过心跳的 Agent 按创建时间判断。已退役的 Agent 不删除, 以免其再次连接。
+func (s *Store) DeleteAgentsOfflineBefore(ctx context.Context, before time.Time) ([]string, error) {
+	var ids []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Agent{}).
+			Where("status = ? AND decommissioned_at IS NULL AND COALESCE(last_seen_at, created_at) < ?", model.StatusOffline, before).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := deleteAgentHistory(tx, ids); err != nil {
+			return err
+		}
+		return tx.Delete(&model.Agent{}, "id IN ?", ids).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// deleteAgentHistory 删除 Agent 的连接历史和配置应用历史
+//
+// 通过迁移创建的表有级联外键, 由 AutoMigrate 创建的表没有, 因此显式删除。
+func deleteAgentHistory(tx *gorm.DB, agentIDs []string) error {
+	if err := tx.Delete(&model.AgentConnectionHistory{}, "agent_id IN ?", agentIDs).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&model.ConfigurationApplyHistory{}, "agent_id IN ?", agentIDs).Error
+}
@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// ErrInvalidCursor 分页游标无效或与排序方式不匹配
+var ErrInvalidCursor = store.ErrInvalidCursor
+
+// agentSortColumns 排序字段对应的列, last_seen_at 为空的 Agent 视为最早
+var agentSortColumns = map[model.AgentSort]string{
+	model.AgentSortUpdatedAt:  "updated_at",
+	model.AgentSortCreatedAt:  "created_at",
+	model.AgentSortLastSeenAt: "COALESCE(last_seen_at, '-infinity')",
+	model.AgentSortName:       "name",
+	model.AgentSortHostname:   "hostname",
+	model.AgentSortVersion:    "version",
+}
+
+// agentCursor 游标记录上一页最后一个 Agent 的排序值和 ID
+type agentCursor struct {
+	Sort  model.AgentSort `json:"s"`
+	Value string          `json:"v"`
+	ID    string          `json:"id"`
+}
+
+// SearchAgents 按条件查询 Agent, 返回当前页、符合条件的总数和下一页的游标
+//
+// 所有条件都在 SQL 中执行: 标签选择器使用 labels 的 GIN 索引, 名称和主机名的子串
+// 匹配使用 pg_trgm 索引。还有下一页时返回非空游标。
+func (s *Store) SearchAgents(ctx context.Context, filter model.AgentFilter, opts model.AgentListOptions) ([]*model.Agent, int64, string, error) {
+	if opts.Sort == "" {
+		opts.Sort = model.AgentSortUpdatedAt
+	}
+	column, ok := agentSortColumns[opts.Sort]
+	if !ok {
+		return nil, 0, "", fmt.Errorf("unknown sort field: %s", opts.Sort)
+	}
+
+	query, err := agentQuery(s.db.WithContext(ctx), filter)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	// 统计总数和分页查询使用各自的语句
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	direction, comparison := "ASC", ">"
+	if opts.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	page := query.Order(column + " " + direction + ", id " + direction).Limit(opts.Limit)
+	if opts.Cursor != "" {
+		cursor, err := decodeAgentCursor(opts.Cursor)
+		if err != nil || cursor.Sort != opts.Sort {
+			return nil, 0, "", ErrInvalidCursor
+		}
+		value, err := cursor.sortValue()
+		if err != nil {
+			return nil, 0, "", ErrInvalidCursor
+		}
+		page = page.Where("("+column+", id) "+comparison+" (?, ?)", value, cursor.ID)
+	} else {
+		page = page.Offset(opts.Offset)
+	}
+
+	var agents []*model.Agent
+	if err := page.Find(&agents).Error; err != nil {
+		return nil, 0, "", err
+	}
+
+	var next string
+	if opts.Limit > 0 && len(agents) == opts.Limit {
+		next = encodeAgentCursor(opts.Sort, agents[len(agents)-1])
+	}
+	return agents, total, next, nil
+}
+
+// agentQuery 构造 Agent 的过滤条件
+func agentQuery(db *gorm.DB, filter model.AgentFilter) (*gorm.DB, error) {
+	query := db.Model(&model.Agent{})
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if len(filter.Selector) > 0 {
+		if isSQLite(db) {
+			for key, value := range filter.Selector {
+				query = query.Where("json_extract(labels, ?) = ?", sqliteJSONPath(key), value)
+			}
+		} else {
+			selector, err := json.Marshal(filter.Selector)
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where("labels::jsonb @> ?::jsonb", string(selector))
+		}
+	}
+	if filter.Version != "" {
+		query = query.Where("version = ?", filter.Version)
+	}
+	if filter.Search != "" {
+		pattern := "%" + escapeLike(filter.Search) + "%"
+		if isSQLite(db) {
+			// SQLite 的 LIKE 不区分 ASCII 字母大小写
+			query = query.Where(`(name LIKE ? ESCAPE '\' OR hostname LIKE ? ESCAPE '\')`, pattern, pattern)
+		} else {
+			query = query.Where("(name ILIKE ? OR hostname ILIKE ?)", pattern, pattern)
+		}
+	}
+	if filter.OS != "" {
+		query = query.Where("type = ?", filter.OS)
+	}
+	if filter.Arch != "" {
+		query = query.Where("architecture = ?", filter.Arch)
+	}
+	if filter.ConfigurationName != "" {
+		query = query.Where("configuration_name = ?", filter.ConfigurationName)
+	}
+	if filter.LastSeenAfter != nil {
+		query = query.Where("last_seen_at >= ?", *filter.LastSeenAfter)
+	}
+	if filter.LastSeenBefore != nil {
+		query = query.Where("last_seen_at < ?", *filter.LastSeenBefore)
+	}
+	if filter.Decommissioned != nil {
+		if *filter.Decommissioned {
+			query = query.Where("decommissioned_at IS NOT NULL")
+		} else {
+			query = query.Where("decommissioned_at IS NULL")
+		}
+	}
+	if filter.Capabilities != 0 {
+		query = query.Where("capabilities & ? = ?", int64(filter.Capabilities), int64(filter.Capabilities))
+	}
+	return query, nil
+}
+
+// encodeAgentCursor 根据一页中最后一个 Agent 生成下一页的游标
+func encodeAgentCursor(sort model.AgentSort, agent *model.Agent) string {
+	cursor := agentCursor{Sort: sort, ID: agent.ID}
+	switch sort {
+	case model.AgentSortUpdatedAt:
+		cursor.Value = agent.UpdatedAt.Format(time.RFC3339Nano)
+	case model.AgentSortCreatedAt:
+		cursor.Value = agent.CreatedAt.Format(time.RFC3339Nano)
+	case model.AgentSortLastSeenAt:
+		cursor.Value = "-infinity"
+		if agent.LastSeenAt != nil {
+			cursor.Value = agent.LastSeenAt.Format(time.RFC3339Nano)
+		}
+	case model.AgentSortName:
+		cursor.Value = agent.Name
+	case model.AgentSortHostname:
+		cursor.Value = agent.Hostname
+	case model.AgentSortVersion:
+		cursor.Value = agent.Version
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sortValue 返回游标中的排序值, 时间按时间类型比较 (SQLite 中时间保存为字符串, 格式与 RFC 3339 不同)
+func (c *agentCursor) sortValue() (interface{}, error) {
+	switch c.Sort {
+	case model.AgentSortUpdatedAt, model.AgentSortCreatedAt, model.AgentSortLastSeenAt:
+		if c.Value == "-infinity" {
+			return c.Value, nil
+		}
+		return time.Parse(time.RFC3339Nano, c.Value)
+	default:
+		return c.Value, nil
+	}
+}
+
+// decodeAgentCursor 解析游标
+func decodeAgentCursor(s string) (*agentCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cursor agentCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateAlertRule 创建告警规则
+func (s *Store) CreateAlertRule(ctx context.Context, rule *model.AlertRule) error {
+	return s.db.WithContext(ctx).Create(rule).Error
+}
+
+// GetAlertRule 获取告警规则
+func (s *Store) GetAlertRule(ctx context.Context, id uint) (*model.AlertRule, error) {
+	var rule model.AlertRule
+	result := s.db.WithContext(ctx).First(&rule, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &rule, nil
+}
+
+// ListAlertRules 列出所有告警规则
+func (s *Store) ListAlertRules(ctx context.Context) ([]*model.AlertRule, error) {
+	var rules []*model.AlertRule
+	err := s.db.WithContext(ctx).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+// UpdateAlertRule 更新告警规则
+func (s *Store) UpdateAlertRule(ctx context.Context, rule *model.AlertRule) error {
+	return s.db.WithContext(ctx).Save(rule).Error
+}
+
+// DeleteAlertRule 删除告警规则及其告警和静默
+func (s *Store) DeleteAlertRule(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rule_id = ?", id).Delete(&model.Alert{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("rule_id = ?", id).Delete(&model.Silence{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.AlertRule{}, id).Error
+	})
+}
+
+// CreateAlert 创建告警
+func (s *Store) CreateAlert(ctx context.Context, alert *model.Alert) error {
+	return s.db.WithContext(ctx).Create(alert).Error
+}
+
+// UpdateAlert 更新告警
+func (s *Store) UpdateAlert(ctx context.Context, alert *model.Alert) error {
+	return s.db.WithContext(ctx).Save(alert).Error
+}
+
+// ListActiveAlerts 列出处于 pending 或 firing 状态的告警
+func (s *Store) ListActiveAlerts(ctx context.Context) ([]*model.Alert, error) {
+	var alerts []*model.Alert
+	err := s.db.WithContext(ctx).
+		Where("state IN ?", []model.AlertState{model.AlertStatePending, model.AlertStateFiring}).
+		Order("id ASC").
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// ListAlerts 列出告警, state 为空时返回所有状态
+func (s *Store) ListAlerts(ctx context.Context, state model.AlertState, limit, offset int) ([]*model.Alert, int64, error) {
+	var alerts []*model.Alert
+	var total int64
+
+	query := s.db.WithContext(ctx).Model(&model.Alert{})
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+
+	// 计算总数
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 查询分页数据
+	if err := query.
+		Order("started_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&alerts).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return alerts, total, nil
+}
+
+// CreateSilence 创建静默
+func (s *Store) CreateSilence(ctx context.Context, silence *model.Silence) error {
+	return s.db.WithContext(ctx).Create(silence).Error
+}
+
+// ListSilences 列出静默, includeExpired 为 false 时只返回未过期的静默
+func (s *Store) ListSilences(ctx context.Context, includeExpired bool) ([]*model.Silence, error) {
+	var silences []*model.Silence
+	query := s.db.WithContext(ctx)
+	if !includeExpired {
+		query = query.Where("ends_at > ?", time.Now())
+	}
+	err := query.Order("id ASC").Find(&silences).Error
+	return silences, err
+}
+
+// ListActiveSilences 列出在指定时间生效的静默
+func (s *Store) ListActiveSilences(ctx context.Context, now time.Time) ([]*model.Silence, error) {
+	var silences []*model.Silence
+	err := s.db.WithContext(ctx).
+		Where("starts_at <= ? AND ends_at > ?", now, now).
+		Find(&silences).Error
+	return silences, err
+}
+
+// DeleteSilence 删除静默
+func (s *Store) DeleteSilence(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Delete(&model.Silence{}, id).Error
+}
+
+// ListAgentsBySelector 列出标签匹配选择器的未退役 Agent, 选择器为空时返回所有未退役的 Agent
+func (s *Store) ListAgentsBySelector(ctx context.Context, selector map[string]string) ([]*model.Agent, error) {
+	var agents []*model.Agent
+	if err := s.db.WithContext(ctx).Where("decommissioned_at IS NULL").Find(&agents).Error; err != nil {
+		return nil, err
+	}
+
+	if len(selector) == 0 {
+		return agents, nil
+	}
+
+	matched := make([]*model.Agent, 0, len(agents))
+	for _, agent := range agents {
+		if agent.Labels.Matches(selector) {
+			matched = append(matched, agent)
+		}
+	}
+	return matched, nil
+}
+
+// CountConnectionsSince 统计每个 Agent 自指定时间以来的连接次数
+func (s *Store) CountConnectionsSince(ctx context.Context, since time.Time) (map[string]int64, error) {
+	var rows []struct {
+		AgentID string
+		Count   int64
+	}
+	err := s.db.WithContext(ctx).
+		Model(&model.AgentConnectionHistory{}).
+		Select("agent_id, COUNT(*) AS count").
+		Where("connected_at >= ?", since).
+		Group("agent_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.AgentID] = row.Count
+	}
+	return counts, nil
+}
+
+// ListFailedApplyHistorySince 列出自指定时间以来应用失败的记录, configName 为空时不限配置
+func (s *Store) ListFailedApplyHistorySince(ctx context.Context, configName string, since time.Time) ([]*model.ConfigurationApplyHistory, error) {
+	var histories []*model.ConfigurationApplyHistory
+	query := s.db.WithContext(ctx).
+		Where("status = ? AND updated_at >= ?", model.ApplyStatusFailed, since)
+	if configName != "" {
+		query = query.Where("configuration_name = ?", configName)
+	}
+	err := query.Order("updated_at DESC").Find(&histories).Error
+	return histories, err
+}
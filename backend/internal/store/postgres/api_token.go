@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateAPIToken 创建 API 令牌
+func (s *Store) CreateAPIToken(ctx context.Context, token *model.APIToken) error {
+	return s.db.WithContext(ctx).Create(token).Error
+}
+
+// GetAPITokenByHash 根据令牌哈希获取 API 令牌
+func (s *Store) GetAPITokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, error) {
+	var token model.APIToken
+	result := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &token, nil
+}
+
+// GetAPIToken 获取用户的 API 令牌
+func (s *Store) GetAPIToken(ctx context.Context, userID, id uint) (*model.APIToken, error) {
+	var token model.APIToken
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &token, nil
+}
+
+// ListAPITokens 列出用户的 API 令牌
+func (s *Store) ListAPITokens(ctx context.Context, userID uint) ([]*model.APIToken, error) {
+	var tokens []*model.APIToken
+	err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	return tokens, err
+}
+
+// RevokeAPIToken 撤销 API 令牌
+func (s *Store) RevokeAPIToken(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Model(&model.APIToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// TouchAPIToken 更新 API 令牌的最后使用时间
+func (s *Store) TouchAPIToken(ctx context.Context, id uint, usedAt time.Time) error {
+	return s.db.WithContext(ctx).Model(&model.APIToken{}).
+		Where("id = ?", id).
+		Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// auditExportBatchSize 导出审计日志时每批读取的条数
+const auditExportBatchSize = 500
+
+// CreateAuditLog 追加一条审计日志
+func (s *Store) CreateAuditLog(ctx context.Context, entry *model.AuditLog) error {
+	return s.db.WithContext(ctx).Create(entry).Error
+}
+
+// ListAuditLogs 按条件分页查询审计日志, 按时间倒序
+func (s *Store) ListAuditLogs(ctx context.Context, filter model.AuditFilter, limit, offset int) ([]*model.AuditLog, int64, error) {
+	var logs []*model.AuditLog
+	var total int64
+
+	query := auditQuery(s.db.WithContext(ctx), filter)
+
+	// 计算总数
+	if err := query.Model(&model.AuditLog{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return logs, total, nil
+}
+
+// ExportAuditLogs 按条件分批读取审计日志, 按时间正序逐条回调
+func (s *Store) ExportAuditLogs(ctx context.Context, filter model.AuditFilter, fn func(*model.AuditLog) error) error {
+	var batch []*model.AuditLog
+	result := auditQuery(s.db.WithContext(ctx), filter).
+		FindInBatches(&batch, auditExportBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, entry := range batch {
+				if err := fn(entry); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	return result.Error
+}
+
+// auditQuery 构造审计日志的过滤条件
+func auditQuery(db *gorm.DB, filter model.AuditFilter) *gorm.DB {
+	query := db.Model(&model.AuditLog{})
+	if filter.ActorType != "" {
+		query = query.Where("actor_type = ?", filter.ActorType)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		if prefix, ok := strings.CutSuffix(filter.Action, ".*"); ok {
+			query = query.Where(`action LIKE ? ESCAPE '\'`, escapeLike(prefix)+".%")
+		} else {
+			query = query.Where("action = ?", filter.Action)
+		}
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	return query
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
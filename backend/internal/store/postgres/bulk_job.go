@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// ListAgentsByFilter 获取所有符合条件的 Agent, 不分页
+func (s *Store) ListAgentsByFilter(ctx context.Context, filter model.AgentFilter) ([]*model.Agent, error) {
+	query, err := agentQuery(s.db.WithContext(ctx), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var agents []*model.Agent
+	err = query.Order("id ASC").Find(&agents).Error
+	return agents, err
+}
+
+// UpdateAgentConfigurationName 设置 Agent 指定使用的配置, 为空表示按标签匹配
+//
+// 只更新该列, 不影响并发的状态上报。Agent 不存在时返回 false。
+func (s *Store) UpdateAgentConfigurationName(ctx context.Context, agentID, name string) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.Agent{}).Where("id = ?", agentID).Update("configuration_name", name)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CreateBulkJob 创建批量操作任务
+func (s *Store) CreateBulkJob(ctx context.Context, job *model.BulkJob) error {
+	return s.db.WithContext(ctx).Create(job).Error
+}
+
+// UpdateBulkJob 更新批量操作任务的进度和结果
+func (s *Store) UpdateBulkJob(ctx context.Context, job *model.BulkJob) error {
+	return s.db.WithContext(ctx).Save(job).Error
+}
+
+// InterruptRunningBulkJobs 将仍在执行的任务标记为已中断, 在服务启动时调用
+func (s *Store) InterruptRunningBulkJobs(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).Model(&model.BulkJob{}).
+		Where("status = ?", model.BulkJobRunning).
+		Updates(map[string]interface{}{
+			"status":      model.BulkJobInterrupted,
+			"finished_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// GetBulkJob 获取批量操作任务
+func (s *Store) GetBulkJob(ctx context.Context, id uint) (*model.BulkJob, error) {
+	var job model.BulkJob
+	if err := s.db.WithContext(ctx).First(&job, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListBulkJobs 分页列出批量操作任务, 按创建时间倒序, 不包含每个 Agent 的结果
+//
+// createdBy 不为空时只列出该用户创建的任务。
+func (s *Store) ListBulkJobs(ctx context.Context, createdBy string, limit, offset int) ([]*model.BulkJob, int64, error) {
+	var jobs []*model.BulkJob
+	var total int64
+
+	query := s.db.WithContext(ctx).Model(&model.BulkJob{})
+	if createdBy != "" {
+		query = query.Where("created_by = ?", createdBy)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := query.Omit("results").Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&jobs).Error
+	return jobs, total, err
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// fleetDimensionColumns 分组维度对应的列
+var fleetDimensionColumns = map[model.FleetDimension]string{
+	model.FleetDimensionStatus:        "status",
+	model.FleetDimensionVersion:       "version",
+	model.FleetDimensionOS:            "type",
+	model.FleetDimensionArch:          "architecture",
+	model.FleetDimensionConfiguration: "configuration_name",
+}
+
+// CountAgentsBy 按维度分组统计符合条件的 Agent 数量, 按数量从多到少排序
+//
+// 过滤条件没有指定 Decommissioned 时不统计已退役的 Agent。
+func (s *Store) CountAgentsBy(ctx context.Context, filter model.AgentFilter, dimension model.FleetDimension) ([]model.FleetBucket, error) {
+	filter = filter.WithoutDecommissioned()
+	var expr string
+	var args []interface{}
+	if key, ok := dimension.LabelKey(); ok {
+		if isSQLite(s.db) {
+			expr = "json_extract(labels, ?)"
+			args = append(args, sqliteJSONPath(key))
+		} else {
+			expr = "labels::jsonb ->> ?"
+			args = append(args, key)
+		}
+	} else if column, ok := fleetDimensionColumns[dimension]; ok {
+		expr = column
+	} else {
+		return nil, fmt.Errorf("unknown dimension: %s", dimension)
+	}
+
+	query, err := agentQuery(s.db.WithContext(ctx), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]model.FleetBucket, 0)
+	err = query.
+		Select("COALESCE("+expr+", '') AS value, COUNT(*) AS count", args...).
+		Group("value").
+		Order("count DESC, value ASC").
+		Scan(&buckets).Error
+	return buckets, err
+}
+
+// CreateFleetStatsSamples 保存一次采样的分组统计
+func (s *Store) CreateFleetStatsSamples(ctx context.Context, samples []*model.FleetStatsSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).CreateInBatches(samples, 500).Error
+}
+
+// ListFleetStatsSamples 获取 [from, to) 内某个维度的采样, 按采样时间和数量排序
+func (s *Store) ListFleetStatsSamples(ctx context.Context, dimension model.FleetDimension, from, to time.Time) ([]*model.FleetStatsSample, error) {
+	var samples []*model.FleetStatsSample
+	err := s.db.WithContext(ctx).
+		Where("dimension = ? AND sampled_at >= ? AND sampled_at < ?", string(dimension), from, to).
+		Order("sampled_at ASC, count DESC, value ASC").
+		Find(&samples).Error
+	return samples, err
+}
+
+// DeleteFleetStatsSamplesBefore 删除 before 之前的采样, 返回删除的行数
+func (s *Store) DeleteFleetStatsSamplesBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Where("sampled_at < ?", before).Delete(&model.FleetStatsSample{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+)
+
+// ErrInvitationUnavailable 邀请已被使用或已过期
+var ErrInvitationUnavailable = store.ErrInvitationUnavailable
+
+// CreateInvitation 创建邀请
+func (s *Store) CreateInvitation(ctx context.Context, invitation *model.Invitation) error {
+	return s.db.WithContext(ctx).Create(invitation).Error
+}
+
+// GetInvitationByTokenHash 根据令牌哈希获取邀请
+func (s *Store) GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*model.Invitation, error) {
+	var invitation model.Invitation
+	result := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&invitation)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &invitation, nil
+}
+
+// ListInvitations 列出邀请, includeUsed 为 false 时只返回未接受且未过期的邀请
+func (s *Store) ListInvitations(ctx context.Context, includeUsed bool) ([]*model.Invitation, error) {
+	var invitations []*model.Invitation
+	query := s.db.WithContext(ctx).Order("created_at DESC")
+	if !includeUsed {
+		query = query.Where("accepted_at IS NULL AND expires_at > ?", time.Now())
+	}
+	err := query.Find(&invitations).Error
+	return invitations, err
+}
+
+// DeleteInvitation 删除 (撤销) 邀请
+func (s *Store) DeleteInvitation(ctx context.Context, id uint) (bool, error) {
+	result := s.db.WithContext(ctx).Delete(&model.Invitation{}, id)
+	return result.RowsAffected > 0, result.Error
+}
+
+// AcceptInvitation 在同一事务中创建用户并将邀请标记为已接受
+//
+// 邀请已被并发使用或已过期时返回 ErrInvitationUnavailable。
+func (s *Store) AcceptInvitation(ctx context.Context, invitation *model.Invitation, user *model.User) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&model.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND expires_at > ?", invitation.ID, now).
+			Updates(map[string]interface{}{
+				"accepted_at": now,
+				"accepted_by": user.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvitationUnavailable
+		}
+
+		invitation.AcceptedAt = &now
+		invitation.AcceptedBy = &user.ID
+		return nil
+	})
+}
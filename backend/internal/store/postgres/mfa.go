@@ -0,0 +1,93 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// ReplaceRecoveryCodes 使用新的恢复码替换用户现有的恢复码
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codeHashes) == 0 {
+			return nil
+		}
+
+		codes := make([]model.RecoveryCode, len(codeHashes))
+		for i, hash := range codeHashes {
+			codes[i] = model.RecoveryCode{UserID: userID, CodeHash: hash}
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode 使用一个恢复码
+//
+// 返回 false 表示恢复码不存在或已被使用。
+func (s *Store) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountUnusedRecoveryCodes 统计用户未使用的恢复码数量
+func (s *Store) CountUnusedRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// ListMFARequiredRoles 列出要求 MFA 的角色
+func (s *Store) ListMFARequiredRoles(ctx context.Context) ([]string, error) {
+	var roles []string
+	err := s.db.WithContext(ctx).Model(&model.MFARequiredRole{}).
+		Order("role").
+		Pluck("role", &roles).Error
+	return roles, err
+}
+
+// SetMFARequiredRoles 替换要求 MFA 的角色列表
+func (s *Store) SetMFARequiredRoles(ctx context.Context, roles []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&model.MFARequiredRole{}).Error; err != nil {
+			return err
+		}
+		if len(roles) == 0 {
+			return nil
+		}
+
+		records := make([]model.MFARequiredRole, len(roles))
+		for i, role := range roles {
+			records[i] = model.MFARequiredRole{Role: role}
+		}
+		return tx.Create(&records).Error
+	})
+}
+
+// IsMFARequiredForRole 检查角色是否要求 MFA
+func (s *Store) IsMFARequiredForRole(ctx context.Context, role string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.MFARequiredRole{}).
+		Where("role = ?", role).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// AdvanceMFAStep 记录已使用的验证码时间步
+//
+// 返回 false 表示该时间步 (或更新的时间步) 已被使用, 验证码不能重复使用。
+func (s *Store) AdvanceMFAStep(ctx context.Context, userID uint, step int64) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND mfa_last_step < ?", userID, step).
+		Update("mfa_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,115 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// ensureDefaultOrganization 确保默认组织存在, 启用多租户之前的数据都属于默认组织
+func (s *Store) ensureDefaultOrganization() error {
+	org := model.Organization{ID: model.DefaultOrganizationID, Name: "default", DisplayName: "Default"}
+	return s.db.Where("id = ?", org.ID).FirstOrCreate(&org).Error
+}
+
+// CreateOrganization 创建组织
+func (s *Store) CreateOrganization(ctx context.Context, org *model.Organization) error {
+	return s.db.WithContext(ctx).Create(org).Error
+}
+
+// GetOrganization 根据 ID 获取组织
+func (s *Store) GetOrganization(ctx context.Context, id uint) (*model.Organization, error) {
+	var org model.Organization
+	result := s.db.WithContext(ctx).Where("id = ?", id).First(&org)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &org, nil
+}
+
+// GetOrganizationByName 根据名称获取组织
+func (s *Store) GetOrganizationByName(ctx context.Context, name string) (*model.Organization, error) {
+	var org model.Organization
+	result := s.db.WithContext(ctx).Where("name = ?", name).First(&org)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &org, nil
+}
+
+// GetOrganizationBySecretKey 根据 Agent 连接使用的 Secret Key 获取组织
+func (s *Store) GetOrganizationBySecretKey(ctx context.Context, secretKey string) (*model.Organization, error) {
+	var org model.Organization
+	result := s.db.WithContext(ctx).Where("secret_key_hash = ?", model.HashSecretKey(secretKey)).First(&org)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &org, nil
+}
+
+// ListOrganizations 列出所有组织
+func (s *Store) ListOrganizations(ctx context.Context) ([]*model.Organization, error) {
+	var orgs []*model.Organization
+	err := s.db.WithContext(ctx).Order("id ASC").Find(&orgs).Error
+	return orgs, err
+}
+
+// UpdateOrganization 更新组织
+func (s *Store) UpdateOrganization(ctx context.Context, org *model.Organization) error {
+	return s.db.WithContext(ctx).Save(org).Error
+}
+
+// DeleteOrganization 删除组织, 调用方需先确认组织中已没有用户和 Agent
+func (s *Store) DeleteOrganization(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Delete(&model.Organization{}, id).Error
+}
+
+// CountAgents 统计 context 所属组织未退役的 Agent 数量, 已退役的 Agent 不占用配额
+func (s *Store) CountAgents(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.Agent{}).Where("decommissioned_at IS NULL").Count(&count).Error
+	return count, err
+}
+
+// SumPackageBytes 统计 context 所属组织的软件包占用的存储空间
+func (s *Store) SumPackageBytes(ctx context.Context) (int64, error) {
+	var total int64
+	err := s.db.WithContext(ctx).Model(&model.Package{}).
+		Select("COALESCE(SUM(file_size), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// GetOrganizationUsage 统计组织当前的资源使用量
+func (s *Store) GetOrganizationUsage(ctx context.Context, id uint) (*model.OrganizationUsage, error) {
+	ctx = tenant.WithOrganization(ctx, id)
+
+	agents, err := s.CountAgents(ctx)
+	if err != nil {
+		return nil, err
+	}
+	packageBytes, err := s.SumPackageBytes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var users int64
+	if err := s.db.WithContext(ctx).Model(&model.User{}).Count(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return &model.OrganizationUsage{Agents: agents, PackageBytes: packageBytes, Users: users}, nil
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateRefreshToken 创建刷新令牌
+func (s *Store) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	return s.db.WithContext(ctx).Create(token).Error
+}
+
+// GetRefreshTokenByHash 根据令牌哈希获取刷新令牌
+func (s *Store) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	var token model.RefreshToken
+	result := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &token, nil
+}
+
+// RevokeRefreshToken 撤销单个刷新令牌
+//
+// 返回 false 表示令牌已经被撤销 (例如被并发的刷新请求使用)。
+func (s *Store) RevokeRefreshToken(ctx context.Context, id uint) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeRefreshTokenFamily 撤销同一登录会话的所有刷新令牌
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return s.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserRefreshTokens 撤销用户的所有刷新令牌
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Model(&model.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAccessToken 撤销单个访问令牌
+func (s *Store) RevokeAccessToken(ctx context.Context, token *model.RevokedAccessToken) error {
+	return s.db.WithContext(ctx).
+		Where(model.RevokedAccessToken{JTI: token.JTI}).
+		FirstOrCreate(token).Error
+}
+
+// IsAccessTokenRevoked 检查访问令牌是否已被单独撤销
+func (s *Store) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.RevokedAccessToken{}).
+		Where("jti = ?", jti).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteExpiredTokens 清理已过期的刷新令牌和撤销记录
+func (s *Store) DeleteExpiredTokens(ctx context.Context, before time.Time) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", before).Delete(&model.RevokedAccessToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("expires_at < ?", before).Delete(&model.RefreshToken{}).Error
+	})
+}
@@ -0,0 +1,127 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// retentionTable 历史表的清理方式
+type retentionTable struct {
+	// groupColumn 按该列分组计算每组保留的记录
+	groupColumn string
+	// timeColumn 按该列判断记录的时长
+	timeColumn string
+	// orderColumn 组内从新到旧的排序列
+	orderColumn string
+	// protected 满足该条件的记录不会被删除
+	protected string
+	// minKeep 每组至少保留的记录数
+	minKeep int
+}
+
+// retentionTables 可以清理的历史表
+//
+// 配置历史至少保留每个配置的最新版本, 否则无法查看和回滚当前版本。
+var retentionTables = map[model.RetentionTable]retentionTable{
+	model.RetentionTableConnectionHistory: {
+		groupColumn: "agent_id",
+		timeColumn:  "connected_at",
+		orderColumn: "connected_at",
+		protected:   "disconnected_at IS NULL",
+	},
+	model.RetentionTableApplyHistory: {
+		groupColumn: "agent_id",
+		timeColumn:  "created_at",
+		orderColumn: "created_at",
+		protected:   fmt.Sprintf("status NOT IN ('%s', '%s')", model.ApplyStatusApplied, model.ApplyStatusFailed),
+	},
+	model.RetentionTableConfigurationHistory: {
+		groupColumn: "configuration_name",
+		timeColumn:  "created_at",
+		orderColumn: "version",
+		protected:   "FALSE",
+		minKeep:     1,
+	},
+}
+
+// prunableHistoryQuery 返回按策略可以删除的记录 ID 查询
+func prunableHistoryQuery(policy model.RetentionPolicy, now time.Time) (string, []interface{}, error) {
+	table, ok := retentionTables[policy.Table]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown retention table: %s", policy.Table)
+	}
+
+	var limits []string
+	var args []interface{}
+	if policy.MaxAgeSeconds > 0 {
+		limits = append(limits, "ts < ?")
+		args = append(args, now.Add(-policy.MaxAge()))
+	}
+	if policy.MaxRows > 0 {
+		limits = append(limits, "rn > ?")
+		args = append(args, policy.MaxRows)
+	}
+	if len(limits) == 0 {
+		return "", nil, nil
+	}
+
+	keepLast := max(policy.KeepLast, table.minKeep)
+	args = append(args, keepLast)
+
+	query := fmt.Sprintf(`SELECT id FROM (
+	SELECT id, %[2]s AS ts, %[4]s AS protected,
+		ROW_NUMBER() OVER (PARTITION BY organization_id, %[1]s ORDER BY %[3]s DESC, id DESC) AS rn
+	FROM %[5]s
+) ranked WHERE (%[6]s) AND rn > ? AND NOT protected`,
+		table.groupColumn, table.timeColumn, table.orderColumn, table.protected, policy.Table,
+		strings.Join(limits, " OR "))
+	return query, args, nil
+}
+
+// CountPrunableHistory 统计按策略可以删除的历史记录数, 用于试运行
+//
+// 保留策略作用于所有组织。
+func (s *Store) CountPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) (int64, error) {
+	query, args, err := prunableHistoryQuery(policy, now)
+	if err != nil || query == "" {
+		return 0, err
+	}
+
+	var count int64
+	err = s.db.WithContext(ctx).Raw("SELECT COUNT(*) FROM ("+query+") prunable", args...).Scan(&count).Error
+	return count, err
+}
+
+// ListPrunableHistory 返回按策略可以删除的历史记录 ID, 按 ID 从小到大排序
+//
+// 每次清理只计算一次候选记录, 再由 DeleteHistoryBatch 分批删除, 避免每批都对整张表重新排名。
+// 候选记录在删除前不会重新变为需要保留: 组内排名只会随新记录增加, 受保护的记录也不在候选中。
+func (s *Store) ListPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) ([]uint, error) {
+	query, args, err := prunableHistoryQuery(policy, now)
+	if err != nil || query == "" {
+		return nil, err
+	}
+
+	var ids []uint
+	err = s.db.WithContext(ctx).Raw(query+" ORDER BY id", args...).Scan(&ids).Error
+	return ids, err
+}
+
+// DeleteHistoryBatch 删除历史表中指定 ID 的记录, 返回删除的记录数
+//
+// 每批单独执行, 避免长时间锁表。
+func (s *Store) DeleteHistoryBatch(ctx context.Context, table model.RetentionTable, ids []uint) (int64, error) {
+	if _, ok := retentionTables[table]; !ok {
+		return 0, fmt.Errorf("unknown retention table: %s", table)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := s.db.WithContext(ctx).Exec(fmt.Sprintf("DELETE FROM %s WHERE id IN ?", table), ids)
+	return result.RowsAffected, result.Error
+}
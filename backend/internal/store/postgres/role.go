@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateRole 创建自定义角色
+func (s *Store) CreateRole(ctx context.Context, role *model.Role) error {
+	return s.db.WithContext(ctx).Create(role).Error
+}
+
+// GetRoleByName 根据名称获取自定义角色
+func (s *Store) GetRoleByName(ctx context.Context, name string) (*model.Role, error) {
+	var role model.Role
+	result := s.db.WithContext(ctx).Where("name = ?", name).First(&role)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &role, nil
+}
+
+// ListRoles 列出所有自定义角色
+func (s *Store) ListRoles(ctx context.Context) ([]*model.Role, error) {
+	var roles []*model.Role
+	err := s.db.WithContext(ctx).Order("name ASC").Find(&roles).Error
+	return roles, err
+}
+
+// UpdateRole 更新自定义角色
+func (s *Store) UpdateRole(ctx context.Context, role *model.Role) error {
+	return s.db.WithContext(ctx).Save(role).Error
+}
+
+// DeleteRole 删除自定义角色
+func (s *Store) DeleteRole(ctx context.Context, name string) error {
+	return s.db.WithContext(ctx).Where("name = ?", name).Delete(&model.Role{}).Error
+}
+
+// CountUsersWithRole 统计使用指定角色的用户数
+func (s *Store) CountUsersWithRole(ctx context.Context, name string) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.User{}).Where("role = ?", name).Count(&count).Error
+	return count, err
+}
@@ -2,14 +2,20 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 
+	"github.com/cc1024201/opamp-platform/internal/migrate"
 	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+	"github.com/cc1024201/opamp-platform/migrations"
 )
 
 // Config PostgreSQL 配置
@@ -20,6 +26,8 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// MigrateOnStart 为 true 时创建 Store 时执行未执行的迁移, 否则只检查迁移是否已全部执行
+	MigrateOnStart bool
 }
 
 // Store PostgreSQL 存储实现
@@ -28,8 +36,32 @@ type Store struct {
 	logger *zap.Logger
 }
 
+var _ store.Store = (*Store)(nil)
+
 // NewStore 创建新的 PostgreSQL 存储
+//
+// 数据库 schema 落后于迁移文件时返回错误, 见 Config.MigrateOnStart。
 func NewStore(config Config, log *zap.Logger) (*Store, error) {
+	store, err := Connect(config, log)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.prepareSchema(config.MigrateOnStart); err != nil {
+		store.Close()
+		return nil, err
+	}
+	if err := store.ensureDefaultOrganization(); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to create default organization: %w", err)
+	}
+
+	log.Info("PostgreSQL store initialized")
+	return store, nil
+}
+
+// Connect 连接数据库但不检查 schema, 用于执行迁移命令
+func Connect(config Config, log *zap.Logger) (*Store, error) {
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		config.Host,
 		config.Port,
@@ -49,34 +81,121 @@ func NewStore(config Config, log *zap.Logger) (*Store, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	store := &Store{
+	return newStore(db, log)
+}
+
+// NewStoreFromDB 使用其他 GORM 支持的数据库创建存储, 用于 SQLite 存储
+//
+// 调用方负责创建 schema, 与 PostgreSQL 语法不同的查询按 db 的方言生成。
+func NewStoreFromDB(db *gorm.DB, log *zap.Logger) (*Store, error) {
+	store, err := newStore(db, log)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.ensureDefaultOrganization(); err != nil {
+		return nil, fmt.Errorf("failed to create default organization: %w", err)
+	}
+	return store, nil
+}
+
+// newStore 注册租户回调并创建存储
+func newStore(db *gorm.DB, log *zap.Logger) (*Store, error) {
+	// 按组织隔离数据
+	if err := registerTenantCallbacks(db); err != nil {
+		return nil, fmt.Errorf("failed to register tenant callbacks: %w", err)
+	}
+
+	return &Store{
 		db:     db,
 		logger: log,
+	}, nil
+}
+
+// isSQLite 检查数据库是否为 SQLite, 少数查询需要使用不同的语法
+func isSQLite(db *gorm.DB) bool {
+	return db.Dialector.Name() == "sqlite"
+}
+
+// sqliteJSONPath 返回 SQLite json_extract 中对象键的路径, 键中可以包含 "."
+func sqliteJSONPath(key string) string {
+	return `$."` + key + `"`
+}
+
+// Migrator 返回执行 migrations 目录中迁移文件的迁移器
+func (s *Store) Migrator() (*migrate.Migrator, error) {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return nil, err
 	}
+	return migrate.New(sqlDB, migrations.FS, s.logger)
+}
+
+// autoMigrateBaseline 由 AutoMigrate 创建的数据库对应的迁移版本
+//
+// AutoMigrate 按模型创建表结构, 与 000023_align_schema_with_models 执行后的状态一致。
+// 000002 之后的迁移没有使用 IF NOT EXISTS, 不能在这类数据库上重新执行。
+const autoMigrateBaseline uint = 23
+
+// Baseline 将由 AutoMigrate 创建且没有版本记录的数据库标记为 autoMigrateBaseline
+//
+// 以 agents 表是否存在判断数据库是否由 AutoMigrate 创建, 已有版本记录的数据库不受影响。
+func (s *Store) Baseline(ctx context.Context, migrator *migrate.Migrator) error {
+	_, err := migrator.Baseline(ctx, autoMigrateBaseline, "agents")
+	return err
+}
 
-	// 自动迁移
-	if err := store.migrate(); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+// prepareSchema 执行未执行的迁移 (apply 为 true 时), 并检查 schema 是否最新
+func (s *Store) prepareSchema(apply bool) error {
+	ctx := context.Background()
+	migrator, err := s.Migrator()
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
 	}
 
-	log.Info("PostgreSQL store initialized")
-	return store, nil
+	if apply {
+		if err := s.Baseline(ctx, migrator); err != nil {
+			return fmt.Errorf("failed to baseline database: %w", err)
+		}
+		if _, err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if status.Dirty {
+		return fmt.Errorf("database schema version %d is dirty, fix it and run `migrate force`", status.Current)
+	}
+	if !status.UpToDate() {
+		return fmt.Errorf("database schema is at version %d but version %d is required, run `migrate up` or enable database.migrate_on_start",
+			status.Current, status.Latest)
+	}
+	if status.Current > status.Latest {
+		s.logger.Warn("Database schema is newer than this server",
+			zap.Uint("schema_version", status.Current),
+			zap.Uint("latest_migration", status.Latest))
+	}
+	return nil
 }
 
-// migrate 执行数据库迁移
-func (s *Store) migrate() error {
-	return s.db.AutoMigrate(
-		&model.Agent{},
-		&model.Configuration{},
-		&model.Source{},
-		&model.Destination{},
-		&model.Processor{},
-		&model.User{},
-		&model.Package{},
-		&model.ConfigurationHistory{},
-		&model.ConfigurationApplyHistory{},
-		&model.AgentConnectionHistory{},
-	)
+// Ping 检查数据库连接
+func (s *Store) Ping(ctx context.Context) error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Stats 返回连接池状态
+func (s *Store) Stats() sql.DBStats {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return sql.DBStats{}
+	}
+	return sqlDB.Stats()
 }
 
 // Close 关闭数据库连接
@@ -101,40 +220,49 @@ func (s *Store) GetAgent(ctx context.Context, agentID string) (*model.Agent, err
 	return &agent, nil
 }
 
+// agentManagedColumns 只通过专门的方法修改的列, UpsertAgent 更新已有 Agent 时不写入
+//
+// 服务端标签由 UpdateAgentServerLabels 修改, 指定的配置由 UpdateAgentConfigurationName 修改,
+// 退役时间由 DecommissionAgent 修改, 抖动标记由 SetAgentFlapping 修改,
+// 避免 Agent 上报时用读取到的旧值覆盖并发的修改。
+var agentManagedColumns = []string{"server_labels", "configuration_name", "decommissioned_at", "flapping", "flapping_since", "created_at"}
+
 // UpsertAgent 创建或更新 Agent
+//
+// Agent 已存在时更新除 agentManagedColumns 以外的所有列, 不存在时完整插入。
 func (s *Store) UpsertAgent(ctx context.Context, agent *model.Agent) error {
-	result := s.db.WithContext(ctx).Save(agent)
-	return result.Error
-}
-
-// ListAgents 列出所有 Agent
-func (s *Store) ListAgents(ctx context.Context, limit, offset int) ([]*model.Agent, int64, error) {
-	var agents []*model.Agent
-	var total int64
-
-	// 计算总数
-	if err := s.db.WithContext(ctx).Model(&model.Agent{}).Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
-
-	// 查询分页数据
-	result := s.db.WithContext(ctx).
-		Limit(limit).
-		Offset(offset).
-		Order("updated_at DESC").
-		Find(&agents)
-
+	db := s.db.WithContext(ctx)
+	result := db.Model(agent).Select("*").Omit(agentManagedColumns...).Updates(agent)
 	if result.Error != nil {
-		return nil, 0, result.Error
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
 	}
+	return db.Create(agent).Error
+}
 
-	return agents, total, nil
+// ListAgents 列出所有 Agent, 按更新时间倒序分页
+func (s *Store) ListAgents(ctx context.Context, limit, offset int) ([]*model.Agent, int64, error) {
+	agents, total, _, err := s.SearchAgents(ctx, model.AgentFilter{}, model.AgentListOptions{
+		Sort:       model.AgentSortUpdatedAt,
+		Descending: true,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	return agents, total, err
 }
 
 // DeleteAgent 删除 Agent
+//
+// 同时删除 Agent 的连接历史和配置应用历史。
 func (s *Store) DeleteAgent(ctx context.Context, agentID string) error {
-	result := s.db.WithContext(ctx).Delete(&model.Agent{}, "id = ?", agentID)
-	return result.Error
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := deleteAgentHistory(tx, []string{agentID}); err != nil {
+			return err
+		}
+		return tx.Delete(&model.Agent{}, "id = ?", agentID).Error
+	})
 }
 
 // GetConfiguration 获取 Agent 的配置
@@ -187,21 +315,44 @@ func (s *Store) CreateConfiguration(ctx context.Context, config *model.Configura
 
 // UpdateConfiguration 更新配置
 func (s *Store) UpdateConfiguration(ctx context.Context, config *model.Configuration) error {
+	return s.updateConfiguration(ctx, config, nil)
+}
+
+// UpdateConfigurationIfVersion 仅当配置的当前版本号为 version 时更新配置
+//
+// 版本号在事务中锁定配置后检查, 不一致时返回 store.ErrVersionConflict。
+func (s *Store) UpdateConfigurationIfVersion(ctx context.Context, config *model.Configuration, version int) error {
+	return s.updateConfiguration(ctx, config, &version)
+}
+
+// updateConfiguration 更新配置, expectedVersion 不为空时要求当前版本号与其一致
+func (s *Store) updateConfiguration(ctx context.Context, config *model.Configuration, expectedVersion *int) error {
 	// 在事务中执行
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		// 获取当前配置
+		// 获取当前配置并锁定, 避免并发修改基于同一版本; SQLite 不支持行锁, 写事务本身是串行的
+		query := tx
+		if !isSQLite(tx) {
+			query = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
 		var existing model.Configuration
-		if err := tx.Where("name = ?", config.Name).First(&existing).Error; err != nil {
+		if err := query.Where("name = ?", config.Name).First(&existing).Error; err != nil {
 			return err
 		}
+		if expectedVersion != nil && existing.Version != *expectedVersion {
+			return store.ErrVersionConflict
+		}
+
+		// 组织是主键的一部分, 沿用已有配置的组织
+		config.OrganizationID = existing.OrganizationID
 
 		// 更新配置哈希
 		config.UpdateHash()
 
-		// 如果配置内容发生变化,创建历史记录并递增版本号
-		if existing.ConfigHash != config.ConfigHash {
+		// 如果配置发生变化,创建历史记录并递增版本号
+		if config.ChangedFrom(&existing) {
 			// 保存当前版本到历史记录
 			history := &model.ConfigurationHistory{
+				OrganizationID:    existing.OrganizationID,
 				ConfigurationName: existing.Name,
 				Version:           existing.Version,
 				ContentType:       existing.ContentType,
@@ -209,6 +360,7 @@ func (s *Store) UpdateConfiguration(ctx context.Context, config *model.Configura
 				ConfigHash:        existing.ConfigHash,
 				Selector:          existing.Selector,
 				Platform:          existing.Platform,
+				CreatedBy:         existing.UpdatedBy,
 				CreatedAt:         existing.UpdatedAt,
 			}
 			if err := tx.Create(history).Error; err != nil {
@@ -218,7 +370,7 @@ func (s *Store) UpdateConfiguration(ctx context.Context, config *model.Configura
 			// 递增版本号
 			config.Version = existing.Version + 1
 		} else {
-			// 配置内容未变化,保持版本号
+			// 配置未变化,保持版本号
 			config.Version = existing.Version
 		}
 
@@ -260,6 +412,20 @@ func (s *Store) DeleteConfiguration(ctx context.Context, name string) error {
 	return result.Error
 }
 
+// DeleteConfigurationIfVersion 仅当配置的当前版本号为 version 时删除配置
+//
+// 配置不存在或版本号不一致时返回 store.ErrVersionConflict。
+func (s *Store) DeleteConfigurationIfVersion(ctx context.Context, name string, version int) error {
+	result := s.db.WithContext(ctx).Delete(&model.Configuration{}, "name = ? AND version = ?", name, version)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return store.ErrVersionConflict
+	}
+	return nil
+}
+
 // CreateUser 创建用户
 func (s *Store) CreateUser(ctx context.Context, user *model.User) error {
 	result := s.db.WithContext(ctx).Create(user)
@@ -267,9 +433,11 @@ func (s *Store) CreateUser(ctx context.Context, user *model.User) error {
 }
 
 // GetUserByUsername 根据用户名获取用户
+//
+// 用户名、邮箱和外部身份标识全局唯一, 这几个查询不限定组织。
 func (s *Store) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
 	var user model.User
-	result := s.db.WithContext(ctx).Where("username = ?", username).First(&user)
+	result := s.db.WithContext(tenant.WithoutOrganization(ctx)).Where("username = ?", username).First(&user)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -282,7 +450,20 @@ func (s *Store) GetUserByUsername(ctx context.Context, username string) (*model.
 // GetUserByEmail 根据邮箱获取用户
 func (s *Store) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	var user model.User
-	result := s.db.WithContext(ctx).Where("email = ?", email).First(&user)
+	result := s.db.WithContext(tenant.WithoutOrganization(ctx)).Where("email = ?", email).First(&user)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// GetUserByExternalID 根据外部身份标识获取用户
+func (s *Store) GetUserByExternalID(ctx context.Context, externalID string) (*model.User, error) {
+	var user model.User
+	result := s.db.WithContext(tenant.WithoutOrganization(ctx)).Where("external_id = ?", externalID).First(&user)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -327,6 +508,22 @@ func (s *Store) DeleteUser(ctx context.Context, id uint) error {
 	return result.Error
 }
 
+// CountUsers 统计用户总数
+func (s *Store) CountUsers(ctx context.Context) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.User{}).Count(&count).Error
+	return count, err
+}
+
+// CountActiveUsersWithRole 统计指定角色的激活用户数
+func (s *Store) CountActiveUsersWithRole(ctx context.Context, role string) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&model.User{}).
+		Where("role = ? AND is_active = ?", role, true).
+		Count(&count).Error
+	return count, err
+}
+
 // GetDB 获取数据库连接（用于健康检查）
 func (s *Store) GetDB() *gorm.DB {
 	return s.db
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/store/storetest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 // TestStore_Close tests the Close method
@@ -16,12 +20,13 @@ func TestStore_Close(t *testing.T) {
 	// Create a temporary store for this test
 	logger, _ := zap.NewDevelopment()
 	config := Config{
-		Host:     getEnvOrDefault("TEST_DB_HOST", "localhost"),
-		Port:     getEnvOrDefaultInt("TEST_DB_PORT", 5432),
-		User:     getEnvOrDefault("TEST_DB_USER", "opamp"),
-		Password: getEnvOrDefault("TEST_DB_PASSWORD", "opamp123"),
-		DBName:   getEnvOrDefault("TEST_DB_NAME", "opamp_platform"),
-		SSLMode:  "disable",
+		Host:           getEnvOrDefault("TEST_DB_HOST", "localhost"),
+		Port:           getEnvOrDefaultInt("TEST_DB_PORT", 5432),
+		User:           getEnvOrDefault("TEST_DB_USER", "opamp"),
+		Password:       getEnvOrDefault("TEST_DB_PASSWORD", "opamp123"),
+		DBName:         getEnvOrDefault("TEST_DB_NAME", "opamp_platform"),
+		SSLMode:        "disable",
+		MigrateOnStart: true,
 	}
 
 	tempStore, err := NewStore(config, logger)
@@ -412,3 +417,361 @@ func TestStore_ConcurrentAccess(t *testing.T) {
 	assert.Equal(t, int64(numGoroutines), total)
 	assert.Len(t, agents, numGoroutines)
 }
+
+// TestStore_UpdateAgentServerLabels tests that server labels survive agent reports
+func TestStore_UpdateAgentServerLabels(t *testing.T) {
+	cleanupDatabase(t)
+	ctx := context.Background()
+
+	agent := &model.Agent{
+		ID:             "labels-agent",
+		Protocol:       "opamp",
+		ReportedLabels: model.Labels{"env": "dev", "os.type": "linux"},
+	}
+	agent.RefreshLabels()
+	require.NoError(t, testStore.UpsertAgent(ctx, agent))
+
+	updated, err := testStore.UpdateAgentServerLabels(ctx, agent.ID, func(a *model.Agent) error {
+		a.ServerLabels = a.ServerLabels.Apply(model.Labels{"env": "prod", "team": "payments"}, nil)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, updated)
+	assert.Equal(t, model.Labels{"env": "prod", "os.type": "linux", "team": "payments"}, updated.Labels)
+
+	// Agent 再次上报时不会覆盖服务端标签
+	agent.ReportedLabels = model.Labels{"env": "dev", "os.type": "windows"}
+	agent.RefreshLabels()
+	require.NoError(t, testStore.UpsertAgent(ctx, agent))
+
+	stored, err := testStore.GetAgent(ctx, agent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Labels{"env": "prod", "team": "payments"}, stored.ServerLabels)
+
+	missing, err := testStore.UpdateAgentServerLabels(ctx, "missing-agent", func(a *model.Agent) error {
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestStore_SearchAgents(t *testing.T) {
+	cleanupDatabase(t)
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		env := "dev"
+		if i%2 == 0 {
+			env = "prod"
+		}
+		agent := &model.Agent{
+			ID:           fmt.Sprintf("search-agent-%d", i),
+			Name:         fmt.Sprintf("collector-%d", i),
+			Hostname:     fmt.Sprintf("host-%d", i),
+			Version:      "1.0.0",
+			Status:       model.StatusOnline,
+			Protocol:     "opamp",
+			Capabilities: uint64(i),
+			Labels:       model.Labels{"env": env},
+		}
+		require.NoError(t, testStore.UpsertAgent(ctx, agent))
+	}
+
+	agents, total, _, err := testStore.SearchAgents(ctx, model.AgentFilter{
+		Selector: map[string]string{"env": "prod"},
+	}, model.AgentListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, agents, 2)
+
+	_, total, _, err = testStore.SearchAgents(ctx, model.AgentFilter{Search: "HOST-3"}, model.AgentListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	// 能力位 2 的 Agent: 2, 3
+	_, total, _, err = testStore.SearchAgents(ctx, model.AgentFilter{Capabilities: 2}, model.AgentListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	// 游标分页按名称依次返回所有 Agent
+	var names []string
+	opts := model.AgentListOptions{Sort: model.AgentSortName, Limit: 2}
+	for {
+		page, _, next, err := testStore.SearchAgents(ctx, model.AgentFilter{}, opts)
+		require.NoError(t, err)
+		for _, agent := range page {
+			names = append(names, agent.Name)
+		}
+		if next == "" {
+			break
+		}
+		opts.Cursor = next
+	}
+	assert.Equal(t, []string{"collector-1", "collector-2", "collector-3", "collector-4", "collector-5"}, names)
+
+	_, _, _, err = testStore.SearchAgents(ctx, model.AgentFilter{}, model.AgentListOptions{Limit: 2, Cursor: "bogus"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestStore_FleetStats(t *testing.T) {
+	cleanupDatabase(t)
+	ctx := context.Background()
+	require.NoError(t, testStore.db.Exec("DELETE FROM fleet_stats_samples").Error)
+
+	for i, version := range []string{"1.0", "1.0", "1.1"} {
+		agent := &model.Agent{
+			ID:       fmt.Sprintf("stats-agent-%d", i),
+			Version:  version,
+			Status:   model.StatusOnline,
+			Protocol: "opamp",
+			Labels:   model.Labels{"env": "prod"},
+		}
+		if i == 2 {
+			agent.Labels = nil
+		}
+		require.NoError(t, testStore.UpsertAgent(ctx, agent))
+	}
+
+	buckets, err := testStore.CountAgentsBy(ctx, model.AgentFilter{}, model.FleetDimensionVersion)
+	require.NoError(t, err)
+	assert.Equal(t, []model.FleetBucket{{Value: "1.0", Count: 2}, {Value: "1.1", Count: 1}}, buckets)
+
+	buckets, err = testStore.CountAgentsBy(ctx, model.AgentFilter{}, model.LabelDimension("env"))
+	require.NoError(t, err)
+	assert.Equal(t, []model.FleetBucket{{Value: "prod", Count: 2}, {Value: "", Count: 1}}, buckets)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, testStore.CreateFleetStatsSamples(ctx, []*model.FleetStatsSample{
+		{Dimension: "version", SampledAt: now.Add(-time.Hour), Value: "1.0", Count: 1},
+		{Dimension: "version", SampledAt: now, Value: "1.0", Count: 2},
+	}))
+
+	samples, err := testStore.ListFleetStatsSamples(ctx, model.FleetDimensionVersion, now.Add(-time.Minute), now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, samples, 1)
+	assert.Equal(t, int64(2), samples[0].Count)
+
+	deleted, err := testStore.DeleteFleetStatsSamplesBefore(ctx, now.Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestStore_UpsertAgent_KeepsConfigurationName(t *testing.T) {
+	cleanupDatabase(t)
+	ctx := context.Background()
+
+	agent := &model.Agent{ID: "pinned-agent", Protocol: "opamp", Status: model.StatusOnline}
+	require.NoError(t, testStore.UpsertAgent(ctx, agent))
+
+	found, err := testStore.UpdateAgentConfigurationName(ctx, agent.ID, "pinned-config")
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	// Agent 上报时使用的是读取到的旧值, 不会覆盖指定的配置
+	agent.Status = model.StatusOffline
+	require.NoError(t, testStore.UpsertAgent(ctx, agent))
+
+	stored, err := testStore.GetAgent(ctx, agent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pinned-config", stored.ConfigurationName)
+	assert.Equal(t, model.StatusOffline, stored.Status)
+
+	found, err = testStore.UpdateAgentConfigurationName(ctx, "missing-agent", "pinned-config")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestStore_AgentLifecycle(t *testing.T) {
+	cleanupDatabase(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	longAgo := now.Add(-48 * time.Hour)
+	agents := []*model.Agent{
+		{ID: "stale-agent", Protocol: "opamp", Status: model.StatusOffline, LastSeenAt: &longAgo},
+		{ID: "recent-agent", Protocol: "opamp", Status: model.StatusOffline, LastSeenAt: &now},
+		{ID: "online-agent", Protocol: "opamp", Status: model.StatusOnline, LastSeenAt: &longAgo},
+		{ID: "retired-agent", Protocol: "opamp", Status: model.StatusOnline, LastSeenAt: &longAgo},
+	}
+	for _, agent := range agents {
+		require.NoError(t, testStore.UpsertAgent(ctx, agent))
+		require.NoError(t, testStore.CreateConnectionHistory(ctx, &model.AgentConnectionHistory{
+			AgentID:     agent.ID,
+			ConnectedAt: longAgo,
+		}))
+	}
+
+	found, err := testStore.DecommissionAgent(ctx, "retired-agent", false)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	retired, err := testStore.GetAgent(ctx, "retired-agent")
+	require.NoError(t, err)
+	assert.True(t, retired.IsDecommissioned())
+	assert.Equal(t, model.StatusOffline, retired.Status)
+
+	// Agent 上报不会清除退役标记
+	retired.Status = model.StatusOnline
+	retired.DecommissionedAt = nil
+	require.NoError(t, testStore.UpsertAgent(ctx, retired))
+	retired, err = testStore.GetAgent(ctx, "retired-agent")
+	require.NoError(t, err)
+	assert.True(t, retired.IsDecommissioned())
+
+	found, err = testStore.DecommissionAgent(ctx, "missing-agent", false)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// 只删除离线过久且未退役的 Agent, 同时删除其连接历史
+	deleted, err := testStore.DeleteAgentsOfflineBefore(ctx, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stale-agent"}, deleted)
+
+	stale, err := testStore.GetAgent(ctx, "stale-agent")
+	require.NoError(t, err)
+	assert.Nil(t, stale)
+	_, total, err := testStore.ListConnectionHistoryByAgent(ctx, "stale-agent", 10, 0)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	_, total, err = testStore.ListConnectionHistoryByAgent(ctx, "retired-agent", 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	// 退役时可以同时删除历史记录
+	_, err = testStore.DecommissionAgent(ctx, "recent-agent", true)
+	require.NoError(t, err)
+	_, total, err = testStore.ListConnectionHistoryByAgent(ctx, "recent-agent", 10, 0)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func TestStore_PruneHistory(t *testing.T) {
+	cleanupDatabase(t)
+	testStore.db.Exec("TRUNCATE TABLE agent_connection_history, configuration_history")
+	ctx := context.Background()
+	now := time.Now()
+
+	// 5 个已结束的连接, 从新到旧相隔 1 天, 以及一个 10 天前开始仍在进行的连接
+	for i := 0; i < 5; i++ {
+		connectedAt := now.Add(-time.Duration(i) * 24 * time.Hour)
+		disconnectedAt := connectedAt.Add(time.Hour)
+		require.NoError(t, testStore.CreateConnectionHistory(ctx, &model.AgentConnectionHistory{
+			AgentID:        "retention-agent",
+			ConnectedAt:    connectedAt,
+			DisconnectedAt: &disconnectedAt,
+		}))
+	}
+	require.NoError(t, testStore.CreateConnectionHistory(ctx, &model.AgentConnectionHistory{
+		AgentID:     "retention-agent",
+		ConnectedAt: now.Add(-10 * 24 * time.Hour),
+	}))
+
+	policy := model.RetentionPolicy{
+		Table:         model.RetentionTableConnectionHistory,
+		MaxAgeSeconds: int64((36 * time.Hour).Seconds()),
+		KeepLast:      1,
+	}
+	count, err := testStore.CountPrunableHistory(ctx, policy, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// 分批删除, 进行中的连接不删除
+	ids, err := testStore.ListPrunableHistory(ctx, policy, now)
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+	rows, err := testStore.DeleteHistoryBatch(ctx, policy.Table, ids[:2])
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows)
+	rows, err = testStore.DeleteHistoryBatch(ctx, policy.Table, ids[2:])
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), rows)
+
+	_, total, err := testStore.ListConnectionHistoryByAgent(ctx, "retention-agent", 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+
+	// 配置历史至少保留最新版本
+	for version := 1; version <= 3; version++ {
+		require.NoError(t, testStore.CreateConfigurationHistory(ctx, &model.ConfigurationHistory{
+			ConfigurationName: "retention-config",
+			Version:           version,
+			RawConfig:         fmt.Sprintf("version: %d", version),
+			ConfigHash:        fmt.Sprintf("hash-%d", version),
+		}))
+	}
+	ids, err = testStore.ListPrunableHistory(ctx, model.RetentionPolicy{
+		Table:         model.RetentionTableConfigurationHistory,
+		MaxAgeSeconds: 1,
+	}, now.Add(time.Hour))
+	require.NoError(t, err)
+	rows, err = testStore.DeleteHistoryBatch(ctx, model.RetentionTableConfigurationHistory, ids)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rows)
+
+	history, _, err := testStore.ListConfigurationHistory(ctx, "retention-config", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, 3, history[0].Version)
+}
+
+// TestStore_SchemaMatchesModels 检查迁移文件创建的表包含模型的所有列
+func TestStore_SchemaMatchesModels(t *testing.T) {
+	models := []interface{}{
+		&model.Organization{}, &model.Agent{}, &model.Configuration{},
+		&model.Source{}, &model.Destination{}, &model.Processor{},
+		&model.User{}, &model.Package{},
+		&model.ConfigurationHistory{}, &model.ConfigurationApplyHistory{}, &model.AgentConnectionHistory{},
+		&model.Webhook{}, &model.WebhookDelivery{},
+		&model.AlertRule{}, &model.Alert{}, &model.Silence{},
+		&model.Role{}, &model.Invitation{}, &model.APIToken{},
+		&model.RefreshToken{}, &model.RevokedAccessToken{},
+		&model.RecoveryCode{}, &model.MFARequiredRole{},
+		&model.AuditLog{}, &model.FleetStatsSample{}, &model.BulkJob{},
+	}
+
+	migrator := testStore.db.Migrator()
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: testStore.db}
+		require.NoError(t, stmt.Parse(m))
+
+		require.True(t, migrator.HasTable(m), "table %s is missing", stmt.Schema.Table)
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName == "" {
+				continue
+			}
+			assert.True(t, migrator.HasColumn(m, field.DBName), "column %s.%s is missing", stmt.Schema.Table, field.DBName)
+		}
+	}
+
+	// 所有迁移都已执行
+	m, err := testStore.Migrator()
+	require.NoError(t, err)
+	status, err := m.Status(context.Background())
+	require.NoError(t, err)
+	assert.True(t, status.UpToDate())
+	assert.Equal(t, status.Latest, status.Current)
+}
+
+// TestStore_Contract 运行所有存储实现共用的契约测试
+func TestStore_Contract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		resetDatabase(t)
+		return testStore
+	})
+}
+
+// resetDatabase 清空所有业务表并删除默认组织以外的组织
+func resetDatabase(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		require.NoError(t, testStore.db.Exec(`TRUNCATE TABLE agents, agent_connection_history, configurations,
+			configuration_history, configuration_apply_history, users, packages, roles, api_tokens, refresh_tokens,
+			revoked_access_tokens, invitations, mfa_recovery_codes, mfa_required_roles, alert_rules, alerts,
+			alert_silences, webhooks, webhook_deliveries, audit_logs, fleet_stats_samples, bulk_jobs
+			RESTART IDENTITY CASCADE`).Error)
+		require.NoError(t, testStore.db.Exec("DELETE FROM organizations WHERE id <> ?", model.DefaultOrganizationID).Error)
+	}
+	reset()
+	t.Cleanup(reset)
+}
@@ -19,12 +19,13 @@ func TestMain(m *testing.M) {
 
 	// 使用环境变量或默认测试数据库配置
 	config := Config{
-		Host:     getEnvOrDefault("TEST_DB_HOST", "localhost"),
-		Port:     getEnvOrDefaultInt("TEST_DB_PORT", 5432),
-		User:     getEnvOrDefault("TEST_DB_USER", "opamp"),
-		Password: getEnvOrDefault("TEST_DB_PASSWORD", "opamp123"),
-		DBName:   getEnvOrDefault("TEST_DB_NAME", "opamp_platform"),
-		SSLMode:  "disable",
+		Host:           getEnvOrDefault("TEST_DB_HOST", "localhost"),
+		Port:           getEnvOrDefaultInt("TEST_DB_PORT", 5432),
+		User:           getEnvOrDefault("TEST_DB_USER", "opamp"),
+		Password:       getEnvOrDefault("TEST_DB_PASSWORD", "opamp123"),
+		DBName:         getEnvOrDefault("TEST_DB_NAME", "opamp_platform"),
+		SSLMode:        "disable",
+		MigrateOnStart: true,
 	}
 
 	var err error
@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// organizationField 带有该字段的模型属于某个组织
+const organizationField = "OrganizationID"
+
+// registerTenantCallbacks 注册按组织隔离数据的 GORM 回调
+//
+// context 绑定了组织时 (见 tenant.WithOrganization), 所有带 OrganizationID 字段的
+// 模型的查询、更新和删除都只作用于该组织的数据, 创建和更新时强制写入该组织,
+// 因此调用方无法读取或修改其他组织的数据。context 未绑定组织时 (后台任务、登录等
+// 全局操作) 不做限定, 新建的记录归入默认组织。
+func registerTenantCallbacks(db *gorm.DB) error {
+	if err := db.Callback().Query().Before("gorm:query").Register("tenant:query", scopeToOrganization); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("tenant:row", scopeToOrganization); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("tenant:delete", scopeToOrganization); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("tenant:update", assignOrganizationOnUpdate); err != nil {
+		return err
+	}
+	return db.Callback().Create().Before("gorm:create").Register("tenant:create", assignOrganizationOnCreate)
+}
+
+// tenantField 返回语句所操作模型的组织字段, 不属于组织的模型和原生 SQL 返回 nil
+func tenantField(db *gorm.DB) *schema.Field {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.SQL.Len() > 0 {
+		return nil
+	}
+	return db.Statement.Schema.LookUpField(organizationField)
+}
+
+// scopeToOrganization 为查询、更新和删除追加组织条件
+func scopeToOrganization(db *gorm.DB) {
+	field := tenantField(db)
+	if field == nil {
+		return
+	}
+	orgID, ok := tenant.OrganizationID(db.Statement.Context)
+	if !ok {
+		return
+	}
+
+	condition := clause.Eq{
+		Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+		Value:  orgID,
+	}
+
+	// 已有条件整体加括号, 避免其中的 OR 绕过组织条件
+	where := clause.Where{Exprs: []clause.Expression{condition}}
+	if c, ok := db.Statement.Clauses["WHERE"]; ok {
+		if existing, ok := c.Expression.(clause.Where); ok && len(existing.Exprs) > 0 {
+			where.Exprs = []clause.Expression{clause.And(existing.Exprs...), condition}
+		}
+		c.Expression = where
+		db.Statement.Clauses["WHERE"] = c
+		return
+	}
+	db.Statement.AddClause(where)
+}
+
+// assignOrganizationOnUpdate 更新时不允许把记录移到其他组织
+func assignOrganizationOnUpdate(db *gorm.DB) {
+	field := tenantField(db)
+	if field == nil {
+		return
+	}
+
+	if orgID, ok := tenant.OrganizationID(db.Statement.Context); ok {
+		setOrganization(db, field, orgID, true)
+		scopeToOrganization(db)
+		return
+	}
+
+	// 未绑定组织时, 对象中未设置的组织字段不覆盖数据库中的值
+	if db.Statement.ReflectValue.Kind() == reflect.Struct {
+		if _, isZero := field.ValueOf(db.Statement.Context, db.Statement.ReflectValue); isZero {
+			db.Statement.Omits = append(db.Statement.Omits, field.DBName)
+		}
+	}
+}
+
+// assignOrganizationOnCreate 创建时写入当前组织, 未绑定组织时归入默认组织
+func assignOrganizationOnCreate(db *gorm.DB) {
+	field := tenantField(db)
+	if field == nil {
+		return
+	}
+
+	orgID, ok := tenant.OrganizationID(db.Statement.Context)
+	if !ok {
+		setOrganization(db, field, model.DefaultOrganizationID, false)
+		return
+	}
+	setOrganization(db, field, orgID, true)
+
+	// Save 和 Upsert 在主键冲突时会更新已有记录, 只允许更新本组织的记录
+	if c, ok := db.Statement.Clauses["ON CONFLICT"]; ok {
+		if onConflict, ok := c.Expression.(clause.OnConflict); ok && !onConflict.DoNothing {
+			onConflict.Where.Exprs = append(onConflict.Where.Exprs, clause.Eq{
+				Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName},
+				Value:  orgID,
+			})
+			db.Statement.AddClause(onConflict)
+		}
+	}
+}
+
+// setOrganization 为语句中的所有对象设置组织, override 为 false 时只设置未指定组织的对象
+func setOrganization(db *gorm.DB, field *schema.Field, orgID uint, override bool) {
+	ctx := db.Statement.Context
+	set := func(v reflect.Value) {
+		v = reflect.Indirect(v)
+		if v.Kind() != reflect.Struct || !v.CanAddr() {
+			return
+		}
+		if _, isZero := field.ValueOf(ctx, v); !override && !isZero {
+			return
+		}
+		if err := field.Set(ctx, v, orgID); err != nil {
+			db.AddError(err)
+		}
+	}
+
+	switch rv := db.Statement.ReflectValue; rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(rv.Index(i))
+		}
+	case reflect.Struct:
+		set(rv)
+	}
+}
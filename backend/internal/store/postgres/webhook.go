@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// CreateWebhook 创建 Webhook 订阅
+func (s *Store) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	return s.db.WithContext(ctx).Create(webhook).Error
+}
+
+// GetWebhook 获取 Webhook 订阅
+func (s *Store) GetWebhook(ctx context.Context, id uint) (*model.Webhook, error) {
+	var webhook model.Webhook
+	result := s.db.WithContext(ctx).First(&webhook, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, result.Error
+	}
+	return &webhook, nil
+}
+
+// ListWebhooks 列出所有 Webhook 订阅
+func (s *Store) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	err := s.db.WithContext(ctx).Order("id ASC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+// UpdateWebhook 更新 Webhook 订阅
+func (s *Store) UpdateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	return s.db.WithContext(ctx).Save(webhook).Error
+}
+
+// DeleteWebhook 删除 Webhook 订阅及其投递记录
+func (s *Store) DeleteWebhook(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&model.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Webhook{}, id).Error
+	})
+}
+
+// CreateWebhookDelivery 创建投递记录
+func (s *Store) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return s.db.WithContext(ctx).Create(delivery).Error
+}
+
+// UpdateWebhookDelivery 更新投递记录
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return s.db.WithContext(ctx).Save(delivery).Error
+}
+
+// ListWebhookDeliveries 列出 Webhook 的投递记录
+func (s *Store) ListWebhookDeliveries(ctx context.Context, webhookID uint, limit, offset int) ([]*model.WebhookDelivery, int64, error) {
+	var deliveries []*model.WebhookDelivery
+	var total int64
+
+	query := s.db.WithContext(ctx).
+		Where("webhook_id = ?", webhookID).
+		Order("created_at DESC")
+
+	// 计算总数
+	if err := query.Model(&model.WebhookDelivery{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 分页查询
+	if err := query.Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
+
+// ListDueWebhookDeliveries 列出到达重试时间的待投递记录
+func (s *Store) ListDueWebhookDeliveries(ctx context.Context, before time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	var deliveries []*model.WebhookDelivery
+	err := s.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", model.DeliveryStatusPending, before).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
@@ -0,0 +1,115 @@
+// Package sqlite 基于嵌入式 SQLite 的存储实现, 用于小规模边缘部署和集成测试
+//
+// 查询与 PostgreSQL 存储共用, 只有少数语法不同的查询按方言生成。migrations 目录中的
+// 迁移文件使用 PostgreSQL 语法, 因此 SQLite 的 schema 由模型自动创建。
+package sqlite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/store/postgres"
+)
+
+// Config SQLite 配置
+type Config struct {
+	// Path 数据库文件路径, ":memory:" 表示使用内存数据库
+	Path string
+	// BusyTimeoutMs 等待其他连接释放写锁的最长时间 (毫秒)
+	BusyTimeoutMs int
+}
+
+// Store SQLite 存储实现
+type Store struct {
+	*postgres.Store
+}
+
+var _ store.Store = (*Store)(nil)
+
+// models 需要创建表的模型
+var models = []interface{}{
+	&model.Organization{},
+	&model.Agent{},
+	&model.Configuration{},
+	&model.Source{},
+	&model.Destination{},
+	&model.Processor{},
+	&model.User{},
+	&model.Package{},
+	&model.ConfigurationHistory{},
+	&model.ConfigurationApplyHistory{},
+	&model.AgentConnectionHistory{},
+	&model.Webhook{},
+	&model.WebhookDelivery{},
+	&model.AlertRule{},
+	&model.Alert{},
+	&model.Silence{},
+	&model.Role{},
+	&model.Invitation{},
+	&model.APIToken{},
+	&model.RefreshToken{},
+	&model.RevokedAccessToken{},
+	&model.RecoveryCode{},
+	&model.MFARequiredRole{},
+	&model.AuditLog{},
+	&model.FleetStatsSample{},
+	&model.BulkJob{},
+}
+
+// NewStore 打开 SQLite 数据库并创建或更新 schema
+func NewStore(config Config, log *zap.Logger) (*Store, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("sqlite database path is required")
+	}
+	if config.BusyTimeoutMs <= 0 {
+		config.BusyTimeoutMs = 5000
+	}
+
+	dsn := ":memory:"
+	if config.Path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(config.Path), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory: %w", err)
+		}
+		// WAL 模式下读写互不阻塞; 写事务在开始时获取写锁, 避免读事务升级为写事务时死锁
+		dsn = fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=%d&_txlock=immediate&_foreign_keys=on",
+			config.Path, config.BusyTimeoutMs)
+	}
+
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if config.Path == ":memory:" {
+		// 每个连接都有独立的内存数据库, 只能使用一个连接
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if err := db.AutoMigrate(models...); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	pgStore, err := postgres.NewStoreFromDB(db, log)
+	if err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	log.Info("SQLite store initialized", zap.String("path", config.Path))
+	return &Store{Store: pgStore}, nil
+}
@@ -0,0 +1,289 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/store/storetest"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := NewStore(Config{Path: filepath.Join(t.TempDir(), "opamp.db")}, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore_Contract(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return newTestStore(t)
+	})
+}
+
+func TestNewStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "opamp.db")
+	s, err := NewStore(Config{Path: path}, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, s.Ping(context.Background()))
+
+	org, err := s.GetOrganization(context.Background(), model.DefaultOrganizationID)
+	require.NoError(t, err)
+	require.NotNil(t, org)
+	require.NoError(t, s.Close())
+
+	// 重新打开已有的数据库
+	s, err = NewStore(Config{Path: path}, zap.NewNop())
+	require.NoError(t, err)
+	s.Close()
+
+	_, err = NewStore(Config{}, zap.NewNop())
+	assert.Error(t, err)
+}
+
+func TestNewStore_Memory(t *testing.T) {
+	s, err := NewStore(Config{Path: ":memory:"}, zap.NewNop())
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "memory-agent", Protocol: "opamp"}))
+	agent, err := s.GetAgent(ctx, "memory-agent")
+	require.NoError(t, err)
+	assert.NotNil(t, agent)
+}
+
+func TestStore_SearchAgents(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		env := "dev"
+		if i%2 == 0 {
+			env = "prod"
+		}
+		require.NoError(t, s.UpsertAgent(ctx, &model.Agent{
+			ID:       fmt.Sprintf("search-agent-%d", i),
+			Name:     fmt.Sprintf("collector-%d", i),
+			Hostname: fmt.Sprintf("host_%d", i),
+			Status:   model.StatusOnline,
+			Protocol: "opamp",
+			Labels:   model.Labels{"env": env, "k8s.cluster": "edge"},
+		}))
+	}
+
+	agents, total, _, err := s.SearchAgents(ctx, model.AgentFilter{
+		Selector: map[string]string{"env": "prod", "k8s.cluster": "edge"},
+	}, model.AgentListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, agents, 2)
+
+	// 不区分大小写, 通配符按字面匹配
+	_, total, _, err = s.SearchAgents(ctx, model.AgentFilter{Search: "HOST_3"}, model.AgentListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	_, total, _, err = s.SearchAgents(ctx, model.AgentFilter{Search: "host%"}, model.AgentListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	var names []string
+	opts := model.AgentListOptions{Sort: model.AgentSortName, Limit: 2}
+	for {
+		page, _, next, err := s.SearchAgents(ctx, model.AgentFilter{}, opts)
+		require.NoError(t, err)
+		for _, agent := range page {
+			names = append(names, agent.Name)
+		}
+		if next == "" {
+			break
+		}
+		opts.Cursor = next
+	}
+	assert.Equal(t, []string{"collector-1", "collector-2", "collector-3", "collector-4", "collector-5"}, names)
+
+	_, _, _, err = s.SearchAgents(ctx, model.AgentFilter{}, model.AgentListOptions{Limit: 2, Cursor: "bogus"})
+	assert.ErrorIs(t, err, store.ErrInvalidCursor)
+
+	buckets, err := s.CountAgentsBy(ctx, model.AgentFilter{}, model.LabelDimension("env"))
+	require.NoError(t, err)
+	assert.Equal(t, []model.FleetBucket{{Value: "dev", Count: 3}, {Value: "prod", Count: 2}}, buckets)
+}
+
+func TestStore_SearchAgents_TimeCursor(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		agent := &model.Agent{ID: fmt.Sprintf("cursor-agent-%d", i), Protocol: "opamp"}
+		if i > 0 {
+			lastSeen := base.Add(time.Duration(i) * time.Minute)
+			agent.LastSeenAt = &lastSeen
+		}
+		require.NoError(t, s.UpsertAgent(ctx, agent))
+	}
+
+	for _, sort := range []model.AgentSort{model.AgentSortLastSeenAt, model.AgentSortCreatedAt} {
+		for _, descending := range []bool{false, true} {
+			var ids []string
+			opts := model.AgentListOptions{Sort: sort, Descending: descending, Limit: 2}
+			for {
+				page, _, next, err := s.SearchAgents(ctx, model.AgentFilter{}, opts)
+				require.NoError(t, err)
+				for _, agent := range page {
+					ids = append(ids, agent.ID)
+				}
+				if next == "" {
+					break
+				}
+				opts.Cursor = next
+			}
+			assert.Len(t, ids, 5, "sort %s descending %t", sort, descending)
+			assert.ElementsMatch(t, []string{"cursor-agent-0", "cursor-agent-1", "cursor-agent-2", "cursor-agent-3", "cursor-agent-4"}, ids)
+			if sort == model.AgentSortLastSeenAt && !descending {
+				assert.Equal(t, "cursor-agent-0", ids[0], "agents that never reported sort first")
+			}
+		}
+	}
+}
+
+func TestStore_UpdateAgentServerLabels(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	agent := &model.Agent{ID: "labels-agent", Protocol: "opamp", ReportedLabels: model.Labels{"env": "dev"}}
+	agent.RefreshLabels()
+	require.NoError(t, s.UpsertAgent(ctx, agent))
+
+	updated, err := s.UpdateAgentServerLabels(ctx, agent.ID, func(a *model.Agent) error {
+		a.ServerLabels = a.ServerLabels.Apply(model.Labels{"env": "prod"}, nil)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.Labels{"env": "prod"}, updated.Labels)
+}
+
+func TestStore_UpdateConfiguration_History(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	config := &model.Configuration{Name: "edge-config", RawConfig: "receivers: {}"}
+	require.NoError(t, s.CreateConfiguration(ctx, config))
+
+	config.RawConfig = "receivers: {otlp: {}}"
+	require.NoError(t, s.UpdateConfiguration(ctx, config))
+
+	stored, err := s.GetConfigurationByName(ctx, "edge-config")
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored.Version)
+
+	history, total, err := s.ListConfigurationHistory(ctx, "edge-config", 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "receivers: {}", history[0].RawConfig)
+}
+
+func TestStore_PruneHistory(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 5; i++ {
+		connectedAt := now.Add(-time.Duration(i) * 24 * time.Hour)
+		disconnectedAt := connectedAt.Add(time.Hour)
+		require.NoError(t, s.CreateConnectionHistory(ctx, &model.AgentConnectionHistory{
+			AgentID:        "retention-agent",
+			ConnectedAt:    connectedAt,
+			DisconnectedAt: &disconnectedAt,
+		}))
+	}
+
+	policy := model.RetentionPolicy{
+		Table:         model.RetentionTableConnectionHistory,
+		MaxAgeSeconds: int64((36 * time.Hour).Seconds()),
+		KeepLast:      1,
+	}
+	count, err := s.CountPrunableHistory(ctx, policy, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), count)
+
+	ids, err := s.ListPrunableHistory(ctx, policy, now)
+	require.NoError(t, err)
+	rows, err := s.DeleteHistoryBatch(ctx, policy.Table, ids)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), rows)
+}
+
+func TestStore_AuditLogs(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	for _, action := range []string{"users.create", "users.delete", "users_x.create"} {
+		require.NoError(t, s.CreateAuditLog(ctx, &model.AuditLog{Actor: "admin", Action: action}))
+	}
+
+	_, total, err := s.ListAuditLogs(ctx, model.AuditFilter{Action: "users.*"}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+}
+
+func TestStore_TenantIsolation(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	org := &model.Organization{Name: "edge"}
+	require.NoError(t, s.CreateOrganization(ctx, org))
+
+	orgCtx := tenant.WithOrganization(ctx, org.ID)
+	require.NoError(t, s.UpsertAgent(orgCtx, &model.Agent{ID: "edge-agent", Protocol: "opamp"}))
+
+	agent, err := s.GetAgent(tenant.WithOrganization(ctx, model.DefaultOrganizationID), "edge-agent")
+	require.NoError(t, err)
+	assert.Nil(t, agent)
+
+	agent, err = s.GetAgent(orgCtx, "edge-agent")
+	require.NoError(t, err)
+	require.NotNil(t, agent)
+	assert.Equal(t, org.ID, agent.OrganizationID)
+}
+
+func TestStore_ConcurrentWrites(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "busy-agent", Protocol: "opamp"}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.UpdateAgentServerLabels(ctx, "busy-agent", func(a *model.Agent) error {
+				a.ServerLabels = a.ServerLabels.Apply(model.Labels{fmt.Sprintf("k%d", i): "v"}, nil)
+				return nil
+			})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+
+	agent, err := s.GetAgent(ctx, "busy-agent")
+	require.NoError(t, err)
+	assert.Len(t, agent.ServerLabels, 20)
+}
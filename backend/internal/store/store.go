@@ -0,0 +1,261 @@
+// Package store 定义平台的存储接口
+//
+// 处理器和后台服务只依赖这些接口, 具体的存储实现 (PostgreSQL, SQLite) 在配置文件中选择。
+// 所有方法按 context 中的组织限定范围, 见 tenant 包; Get 方法在记录不存在时返回 nil, nil。
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+var (
+	// ErrInvalidCursor 分页游标无效或与排序方式不匹配
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvitationUnavailable 邀请已被使用或已过期
+	ErrInvitationUnavailable = errors.New("invitation is no longer valid")
+	// ErrVersionConflict 配置已被修改, 当前版本号与请求的版本号不一致
+	ErrVersionConflict = errors.New("configuration version conflict")
+)
+
+// AgentStore Agent 存储
+type AgentStore interface {
+	GetAgent(ctx context.Context, agentID string) (*model.Agent, error)
+	UpsertAgent(ctx context.Context, agent *model.Agent) error
+	ListAgents(ctx context.Context, limit, offset int) ([]*model.Agent, int64, error)
+	SearchAgents(ctx context.Context, filter model.AgentFilter, opts model.AgentListOptions) ([]*model.Agent, int64, string, error)
+	ListAgentsByFilter(ctx context.Context, filter model.AgentFilter) ([]*model.Agent, error)
+	ListAgentsBySelector(ctx context.Context, selector map[string]string) ([]*model.Agent, error)
+	CountAgents(ctx context.Context) (int64, error)
+	CountAgentsBy(ctx context.Context, filter model.AgentFilter, dimension model.FleetDimension) ([]model.FleetBucket, error)
+	DeleteAgent(ctx context.Context, agentID string) error
+
+	// 只通过专门的方法修改的字段
+	UpdateAgentServerLabels(ctx context.Context, agentID string, update func(agent *model.Agent) error) (*model.Agent, error)
+	UpdateAgentConfigurationName(ctx context.Context, agentID, name string) (bool, error)
+
+	// 状态管理
+	UpdateAgentStatus(ctx context.Context, agentID string, status model.AgentStatus) error
+	UpdateAgentLastSeen(ctx context.Context, agentID string) error
+	SetAgentDisconnectReason(ctx context.Context, agentID string, reason string) error
+	ListOnlineAgents(ctx context.Context) ([]*model.Agent, error)
+	ListOfflineAgents(ctx context.Context, limit, offset int) ([]*model.Agent, int64, error)
+	ListStaleAgents(ctx context.Context, timeout time.Duration) ([]*model.Agent, error)
+	ListFlappingAgents(ctx context.Context) ([]*model.Agent, error)
+	SetAgentFlapping(ctx context.Context, agentID string, flapping bool) error
+
+	// 生命周期
+	DecommissionAgent(ctx context.Context, agentID string, purgeHistory bool) (bool, error)
+	DeleteAgentsOfflineBefore(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// ConfigurationStore 配置存储
+type ConfigurationStore interface {
+	GetConfiguration(ctx context.Context, agentID string) (*model.Configuration, error)
+	GetConfigurationByName(ctx context.Context, name string) (*model.Configuration, error)
+	ListConfigurations(ctx context.Context) ([]*model.Configuration, error)
+	CreateConfiguration(ctx context.Context, config *model.Configuration) error
+	UpdateConfiguration(ctx context.Context, config *model.Configuration) error
+	// UpdateConfigurationIfVersion 仅当配置的当前版本号为 version 时更新, 否则返回 ErrVersionConflict
+	UpdateConfigurationIfVersion(ctx context.Context, config *model.Configuration, version int) error
+	DeleteConfiguration(ctx context.Context, name string) error
+	// DeleteConfigurationIfVersion 仅当配置的当前版本号为 version 时删除, 否则返回 ErrVersionConflict
+	DeleteConfigurationIfVersion(ctx context.Context, name string, version int) error
+}
+
+// HistoryStore 连接历史、配置版本历史和配置应用历史存储
+type HistoryStore interface {
+	// 连接历史
+	CreateConnectionHistory(ctx context.Context, history *model.AgentConnectionHistory) error
+	UpdateConnectionHistory(ctx context.Context, history *model.AgentConnectionHistory) error
+	GetConnectionHistory(ctx context.Context, id uint) (*model.AgentConnectionHistory, error)
+	GetActiveConnectionHistory(ctx context.Context, agentID string) (*model.AgentConnectionHistory, error)
+	ListConnectionHistoryByAgent(ctx context.Context, agentID string, limit, offset int) ([]*model.AgentConnectionHistory, int64, error)
+	ListConnectionHistorySince(ctx context.Context, since time.Time) ([]*model.AgentConnectionHistory, error)
+	ListConnectionHistoryInRange(ctx context.Context, agentIDs []string, from, to time.Time) ([]*model.AgentConnectionHistory, error)
+	CountConnectionsSince(ctx context.Context, since time.Time) (map[string]int64, error)
+
+	// 配置版本历史
+	CreateConfigurationHistory(ctx context.Context, history *model.ConfigurationHistory) error
+	GetConfigurationHistory(ctx context.Context, configName string, version int) (*model.ConfigurationHistory, error)
+	ListConfigurationHistory(ctx context.Context, configName string, limit, offset int) ([]*model.ConfigurationHistory, int64, error)
+	GetLatestConfigurationVersion(ctx context.Context, configName string) (int, error)
+
+	// 配置应用历史
+	CreateApplyHistory(ctx context.Context, history *model.ConfigurationApplyHistory) error
+	UpdateApplyHistory(ctx context.Context, history *model.ConfigurationApplyHistory) error
+	GetApplyHistory(ctx context.Context, id uint) (*model.ConfigurationApplyHistory, error)
+	GetLatestApplyHistory(ctx context.Context, agentID, configName string) (*model.ConfigurationApplyHistory, error)
+	ListApplyHistoryByAgent(ctx context.Context, agentID string, limit, offset int) ([]*model.ConfigurationApplyHistory, int64, error)
+	ListApplyHistoryByConfig(ctx context.Context, configName string, limit, offset int) ([]*model.ConfigurationApplyHistory, int64, error)
+	GetPendingApplyHistories(ctx context.Context) ([]*model.ConfigurationApplyHistory, error)
+	ListFailedApplyHistorySince(ctx context.Context, configName string, since time.Time) ([]*model.ConfigurationApplyHistory, error)
+
+	// 保留策略
+	CountPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) (int64, error)
+	ListPrunableHistory(ctx context.Context, policy model.RetentionPolicy, now time.Time) ([]uint, error)
+	DeleteHistoryBatch(ctx context.Context, table model.RetentionTable, ids []uint) (int64, error)
+}
+
+// UserStore 用户存储
+type UserStore interface {
+	CreateUser(ctx context.Context, user *model.User) error
+	GetUserByID(ctx context.Context, id uint) (*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
+	GetUserByExternalID(ctx context.Context, externalID string) (*model.User, error)
+	ListUsers(ctx context.Context) ([]*model.User, error)
+	UpdateUser(ctx context.Context, user *model.User) error
+	DeleteUser(ctx context.Context, id uint) error
+	CountUsers(ctx context.Context) (int64, error)
+	CountActiveUsersWithRole(ctx context.Context, role string) (int64, error)
+}
+
+// PackageStore 软件包存储
+type PackageStore interface {
+	CreatePackage(ctx context.Context, pkg *model.Package) error
+	GetPackage(ctx context.Context, id uint) (*model.Package, error)
+	GetPackageByVersion(ctx context.Context, name, version, platform, arch string) (*model.Package, error)
+	GetLatestPackage(ctx context.Context, name, platform, arch string) (*model.Package, error)
+	ListPackages(ctx context.Context) ([]*model.Package, error)
+	UpdatePackage(ctx context.Context, pkg *model.Package) error
+	DeletePackage(ctx context.Context, id uint) error
+	SumPackageBytes(ctx context.Context) (int64, error)
+}
+
+// OrganizationStore 组织存储, 组织本身不按组织限定范围
+type OrganizationStore interface {
+	CreateOrganization(ctx context.Context, org *model.Organization) error
+	GetOrganization(ctx context.Context, id uint) (*model.Organization, error)
+	GetOrganizationByName(ctx context.Context, name string) (*model.Organization, error)
+	GetOrganizationBySecretKey(ctx context.Context, secretKey string) (*model.Organization, error)
+	ListOrganizations(ctx context.Context) ([]*model.Organization, error)
+	UpdateOrganization(ctx context.Context, org *model.Organization) error
+	DeleteOrganization(ctx context.Context, id uint) error
+	GetOrganizationUsage(ctx context.Context, id uint) (*model.OrganizationUsage, error)
+}
+
+// AuthStore 角色、令牌、邀请和多因素认证存储
+type AuthStore interface {
+	// 角色
+	CreateRole(ctx context.Context, role *model.Role) error
+	GetRoleByName(ctx context.Context, name string) (*model.Role, error)
+	ListRoles(ctx context.Context) ([]*model.Role, error)
+	UpdateRole(ctx context.Context, role *model.Role) error
+	DeleteRole(ctx context.Context, name string) error
+	CountUsersWithRole(ctx context.Context, name string) (int64, error)
+
+	// API 令牌
+	CreateAPIToken(ctx context.Context, token *model.APIToken) error
+	GetAPIToken(ctx context.Context, userID, id uint) (*model.APIToken, error)
+	GetAPITokenByHash(ctx context.Context, tokenHash string) (*model.APIToken, error)
+	ListAPITokens(ctx context.Context, userID uint) ([]*model.APIToken, error)
+	RevokeAPIToken(ctx context.Context, id uint) error
+	TouchAPIToken(ctx context.Context, id uint, usedAt time.Time) error
+
+	// 刷新令牌和已吊销的访问令牌
+	CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, id uint) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uint) error
+	RevokeAccessToken(ctx context.Context, token *model.RevokedAccessToken) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpiredTokens(ctx context.Context, before time.Time) error
+
+	// 邀请
+	CreateInvitation(ctx context.Context, invitation *model.Invitation) error
+	GetInvitationByTokenHash(ctx context.Context, tokenHash string) (*model.Invitation, error)
+	ListInvitations(ctx context.Context, includeUsed bool) ([]*model.Invitation, error)
+	DeleteInvitation(ctx context.Context, id uint) (bool, error)
+	AcceptInvitation(ctx context.Context, invitation *model.Invitation, user *model.User) error
+
+	// 多因素认证
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(ctx context.Context, userID uint) (int64, error)
+	ListMFARequiredRoles(ctx context.Context) ([]string, error)
+	SetMFARequiredRoles(ctx context.Context, roles []string) error
+	IsMFARequiredForRole(ctx context.Context, role string) (bool, error)
+	AdvanceMFAStep(ctx context.Context, userID uint, step int64) (bool, error)
+}
+
+// AlertStore 告警规则、告警和静默存储
+type AlertStore interface {
+	CreateAlertRule(ctx context.Context, rule *model.AlertRule) error
+	GetAlertRule(ctx context.Context, id uint) (*model.AlertRule, error)
+	ListAlertRules(ctx context.Context) ([]*model.AlertRule, error)
+	UpdateAlertRule(ctx context.Context, rule *model.AlertRule) error
+	DeleteAlertRule(ctx context.Context, id uint) error
+
+	CreateAlert(ctx context.Context, alert *model.Alert) error
+	UpdateAlert(ctx context.Context, alert *model.Alert) error
+	ListActiveAlerts(ctx context.Context) ([]*model.Alert, error)
+	ListAlerts(ctx context.Context, state model.AlertState, limit, offset int) ([]*model.Alert, int64, error)
+
+	CreateSilence(ctx context.Context, silence *model.Silence) error
+	ListSilences(ctx context.Context, includeExpired bool) ([]*model.Silence, error)
+	ListActiveSilences(ctx context.Context, now time.Time) ([]*model.Silence, error)
+	DeleteSilence(ctx context.Context, id uint) error
+}
+
+// WebhookStore Webhook 和投递记录存储
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, webhook *model.Webhook) error
+	GetWebhook(ctx context.Context, id uint) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhook *model.Webhook) error
+	DeleteWebhook(ctx context.Context, id uint) error
+
+	CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, webhookID uint, limit, offset int) ([]*model.WebhookDelivery, int64, error)
+	ListDueWebhookDeliveries(ctx context.Context, before time.Time, limit int) ([]*model.WebhookDelivery, error)
+}
+
+// AuditStore 审计日志存储, 审计日志只能追加
+type AuditStore interface {
+	CreateAuditLog(ctx context.Context, entry *model.AuditLog) error
+	ListAuditLogs(ctx context.Context, filter model.AuditFilter, limit, offset int) ([]*model.AuditLog, int64, error)
+	ExportAuditLogs(ctx context.Context, filter model.AuditFilter, fn func(*model.AuditLog) error) error
+}
+
+// FleetStatsStore 集群统计采样存储
+type FleetStatsStore interface {
+	CreateFleetStatsSamples(ctx context.Context, samples []*model.FleetStatsSample) error
+	ListFleetStatsSamples(ctx context.Context, dimension model.FleetDimension, from, to time.Time) ([]*model.FleetStatsSample, error)
+	DeleteFleetStatsSamplesBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// BulkJobStore 批量任务存储
+type BulkJobStore interface {
+	CreateBulkJob(ctx context.Context, job *model.BulkJob) error
+	GetBulkJob(ctx context.Context, id uint) (*model.BulkJob, error)
+	ListBulkJobs(ctx context.Context, createdBy string, limit, offset int) ([]*model.BulkJob, int64, error)
+	UpdateBulkJob(ctx context.Context, job *model.BulkJob) error
+	InterruptRunningBulkJobs(ctx context.Context) (int64, error)
+}
+
+// Store 平台使用的完整存储接口
+type Store interface {
+	AgentStore
+	ConfigurationStore
+	HistoryStore
+	UserStore
+	PackageStore
+	OrganizationStore
+	AuthStore
+	AlertStore
+	WebhookStore
+	AuditStore
+	FleetStatsStore
+	BulkJobStore
+
+	// Ping 检查存储是否可用
+	Ping(ctx context.Context) error
+	// Close 释放存储占用的资源
+	Close() error
+}
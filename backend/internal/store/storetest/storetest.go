@@ -0,0 +1,702 @@
+// Package storetest 提供 store.Store 实现共用的契约测试
+//
+// 每个存储实现在自己的测试中调用 Run, 保证 PostgreSQL、SQLite 和内存存储的行为一致。
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/store"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// Run 对存储实现运行契约测试
+//
+// newStore 为每个子测试返回一个只包含默认组织的空存储, 需要时由调用方负责清理。
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"Agents", testAgents},
+		{"SearchAgentsCursor", testSearchAgentsCursor},
+		{"StaleAgents", testStaleAgents},
+		{"UpsertAgentKeepsFlapping", testUpsertAgentKeepsFlapping},
+		{"DecommissionedAgentsExcluded", testDecommissionedAgentsExcluded},
+		{"ConfigurationVersions", testConfigurationVersions},
+		{"ConfigurationVersionConflict", testConfigurationVersionConflict},
+		{"ConfigurationForAgent", testConfigurationForAgent},
+		{"ConnectionHistory", testConnectionHistory},
+		{"ApplyHistory", testApplyHistory},
+		{"Users", testUsers},
+		{"TenantIsolation", testTenantIsolation},
+		{"AcceptInvitation", testAcceptInvitation},
+		{"AuditLogs", testAuditLogs},
+		{"Retention", testRetention},
+		{"CreateDisabledWebhook", testCreateDisabledWebhook},
+		{"CreateDisabledAlertRule", testCreateDisabledAlertRule},
+		{"BulkJobs", testBulkJobs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func testAgents(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	agent, err := s.GetAgent(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, agent)
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, s.UpsertAgent(ctx, &model.Agent{
+			ID:       fmt.Sprintf("agent-%d", i),
+			Name:     fmt.Sprintf("collector-%d", i),
+			Protocol: "opamp",
+			Labels:   model.Labels{"env": "test"},
+		}))
+	}
+
+	agent, err = s.GetAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	require.NotNil(t, agent)
+	assert.Equal(t, "collector-1", agent.Name)
+	assert.Equal(t, model.StatusOffline, agent.Status)
+	assert.Equal(t, model.DefaultOrganizationID, agent.OrganizationID)
+	assert.False(t, agent.CreatedAt.IsZero())
+	createdAt := agent.CreatedAt
+
+	// 再次上报时更新字段, 保留创建时间
+	agent.Name = "renamed"
+	agent.Status = model.StatusOnline
+	require.NoError(t, s.UpsertAgent(ctx, agent))
+
+	agent, err = s.GetAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", agent.Name)
+	assert.Equal(t, model.StatusOnline, agent.Status)
+	assert.WithinDuration(t, createdAt, agent.CreatedAt, time.Millisecond)
+
+	agents, total, err := s.ListAgents(ctx, 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Len(t, agents, 2)
+
+	agents, total, err = s.ListAgents(ctx, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Len(t, agents, 1)
+
+	require.NoError(t, s.DeleteAgent(ctx, "agent-2"))
+	agent, err = s.GetAgent(ctx, "agent-2")
+	require.NoError(t, err)
+	assert.Nil(t, agent)
+
+	count, err := s.CountAgents(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+}
+
+func testSearchAgentsCursor(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	seen := time.Now().Add(-time.Hour)
+	for i := 1; i <= 5; i++ {
+		agent := &model.Agent{
+			ID:       fmt.Sprintf("agent-%d", i),
+			Name:     fmt.Sprintf("collector-%d", 6-i),
+			Protocol: "opamp",
+		}
+		// agent-5 从未上报心跳
+		if i < 5 {
+			lastSeen := seen.Add(time.Duration(i) * time.Minute)
+			agent.LastSeenAt = &lastSeen
+		}
+		require.NoError(t, s.UpsertAgent(ctx, agent))
+	}
+
+	walk := func(opts model.AgentListOptions) []string {
+		var ids []string
+		for page := 0; page < 10; page++ {
+			agents, total, next, err := s.SearchAgents(ctx, model.AgentFilter{}, opts)
+			require.NoError(t, err)
+			assert.Equal(t, int64(5), total)
+			for _, agent := range agents {
+				ids = append(ids, agent.ID)
+			}
+			if next == "" {
+				return ids
+			}
+			opts.Cursor = next
+		}
+		t.Fatal("cursor pagination did not terminate")
+		return nil
+	}
+
+	assert.Equal(t,
+		[]string{"agent-5", "agent-4", "agent-3", "agent-2", "agent-1"},
+		walk(model.AgentListOptions{Sort: model.AgentSortName, Limit: 2}))
+	assert.Equal(t,
+		[]string{"agent-4", "agent-3", "agent-2", "agent-1", "agent-5"},
+		walk(model.AgentListOptions{Sort: model.AgentSortLastSeenAt, Descending: true, Limit: 2}))
+
+	_, _, _, err := s.SearchAgents(ctx, model.AgentFilter{}, model.AgentListOptions{Cursor: "not-a-cursor"})
+	assert.ErrorIs(t, err, store.ErrInvalidCursor)
+}
+
+func testStaleAgents(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	stale := time.Now().Add(-10 * time.Minute)
+	fresh := time.Now()
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "stale", Status: model.StatusOnline, LastSeenAt: &stale}))
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "fresh", Status: model.StatusOnline, LastSeenAt: &fresh}))
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "offline", Status: model.StatusOffline, LastSeenAt: &stale}))
+
+	agents, err := s.ListStaleAgents(ctx, 5*time.Minute)
+	require.NoError(t, err)
+	require.Len(t, agents, 1)
+	assert.Equal(t, "stale", agents[0].ID)
+
+	require.NoError(t, s.UpdateAgentLastSeen(ctx, "stale"))
+	agents, err = s.ListStaleAgents(ctx, 5*time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, agents)
+
+	require.NoError(t, s.UpdateAgentStatus(ctx, "fresh", model.StatusOffline))
+	online, err := s.ListOnlineAgents(ctx)
+	require.NoError(t, err)
+	require.Len(t, online, 1)
+	assert.Equal(t, "stale", online[0].ID)
+}
+
+func testUpsertAgentKeepsFlapping(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "agent-1", Protocol: "opamp", Status: model.StatusOnline}))
+	stale, err := s.GetAgent(ctx, "agent-1")
+	require.NoError(t, err)
+
+	// Agent 上报时使用的是标记抖动之前读取的记录
+	require.NoError(t, s.SetAgentFlapping(ctx, "agent-1", true))
+	stale.Status = model.StatusOffline
+	require.NoError(t, s.UpsertAgent(ctx, stale))
+
+	agent, err := s.GetAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusOffline, agent.Status)
+	assert.True(t, agent.Flapping)
+	assert.NotNil(t, agent.FlappingSince)
+
+	flapping, err := s.ListFlappingAgents(ctx)
+	require.NoError(t, err)
+	require.Len(t, flapping, 1)
+}
+
+func testDecommissionedAgentsExcluded(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	for _, id := range []string{"agent-1", "agent-2"} {
+		require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: id, Protocol: "opamp", Status: model.StatusOffline, Labels: model.Labels{"env": "prod"}}))
+	}
+	found, err := s.DecommissionAgent(ctx, "agent-2", false)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// 告警评估、配额和舰队统计都不计入已退役的 Agent
+	agents, err := s.ListAgentsBySelector(ctx, map[string]string{"env": "prod"})
+	require.NoError(t, err)
+	require.Len(t, agents, 1)
+	assert.Equal(t, "agent-1", agents[0].ID)
+
+	agents, err = s.ListAgentsBySelector(ctx, nil)
+	require.NoError(t, err)
+	assert.Len(t, agents, 1)
+
+	count, err := s.CountAgents(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	buckets, err := s.CountAgentsBy(ctx, model.AgentFilter{}, model.FleetDimensionStatus)
+	require.NoError(t, err)
+	assert.Equal(t, []model.FleetBucket{{Value: string(model.StatusOffline), Count: 1}}, buckets)
+
+	// 显式查询已退役的 Agent 时仍然可以统计
+	decommissioned := true
+	buckets, err = s.CountAgentsBy(ctx, model.AgentFilter{Decommissioned: &decommissioned}, model.FleetDimensionStatus)
+	require.NoError(t, err)
+	assert.Equal(t, []model.FleetBucket{{Value: string(model.StatusOffline), Count: 1}}, buckets)
+}
+
+func testConfigurationVersions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	config := &model.Configuration{Name: "base", ContentType: "yaml", RawConfig: "receivers: {}", UpdatedBy: "alice"}
+	require.NoError(t, s.CreateConfiguration(ctx, config))
+
+	stored, err := s.GetConfigurationByName(ctx, "base")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, 1, stored.Version)
+	assert.NotEmpty(t, stored.ConfigHash)
+
+	// 配置不变时不生成历史版本
+	require.NoError(t, s.UpdateConfiguration(ctx, stored))
+	assert.Equal(t, 1, stored.Version)
+	version, err := s.GetLatestConfigurationVersion(ctx, "base")
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	for i := 2; i <= 3; i++ {
+		stored.RawConfig = fmt.Sprintf("receivers: {otlp: {}}\n# v%d", i)
+		stored.UpdatedBy = "bob"
+		require.NoError(t, s.UpdateConfiguration(ctx, stored))
+	}
+
+	stored, err = s.GetConfigurationByName(ctx, "base")
+	require.NoError(t, err)
+	assert.Equal(t, 3, stored.Version)
+
+	version, err = s.GetLatestConfigurationVersion(ctx, "base")
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	history, err := s.GetConfigurationHistory(ctx, "base", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "receivers: {}", history.RawConfig)
+	assert.Equal(t, "alice", history.CreatedBy)
+
+	_, err = s.GetConfigurationHistory(ctx, "base", 3)
+	assert.Error(t, err)
+
+	histories, total, err := s.ListConfigurationHistory(ctx, "base", 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, histories, 1)
+	assert.Equal(t, 2, histories[0].Version)
+
+	require.NoError(t, s.DeleteConfiguration(ctx, "base"))
+	stored, err = s.GetConfigurationByName(ctx, "base")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+}
+
+func testConfigurationVersionConflict(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateConfiguration(ctx, &model.Configuration{Name: "base", RawConfig: "a: 1"}))
+
+	// 基于过期版本的更新被拒绝, 配置保持不变
+	update := &model.Configuration{Name: "base", RawConfig: "a: 2"}
+	err := s.UpdateConfigurationIfVersion(ctx, update, 2)
+	assert.ErrorIs(t, err, store.ErrVersionConflict)
+	stored, err := s.GetConfigurationByName(ctx, "base")
+	require.NoError(t, err)
+	assert.Equal(t, "a: 1", stored.RawConfig)
+	assert.Equal(t, 1, stored.Version)
+
+	require.NoError(t, s.UpdateConfigurationIfVersion(ctx, update, 1))
+	assert.Equal(t, 2, update.Version)
+
+	// 同一版本的第二次更新发生冲突
+	stale := &model.Configuration{Name: "base", RawConfig: "a: 3"}
+	assert.ErrorIs(t, s.UpdateConfigurationIfVersion(ctx, stale, 1), store.ErrVersionConflict)
+
+	// 只修改选择器同样递增版本号, 基于旧版本的另一次选择器修改发生冲突
+	selector := &model.Configuration{Name: "base", RawConfig: "a: 2", Selector: map[string]string{"env": "prod"}}
+	require.NoError(t, s.UpdateConfigurationIfVersion(ctx, selector, 2))
+	assert.Equal(t, 3, selector.Version)
+	other := &model.Configuration{Name: "base", RawConfig: "a: 2", Selector: map[string]string{"env": "dev"}}
+	assert.ErrorIs(t, s.UpdateConfigurationIfVersion(ctx, other, 2), store.ErrVersionConflict)
+	history, err := s.GetConfigurationHistory(ctx, "base", 2)
+	require.NoError(t, err)
+	assert.Empty(t, history.Selector)
+
+	assert.ErrorIs(t, s.DeleteConfigurationIfVersion(ctx, "base", 2), store.ErrVersionConflict)
+	stored, err = s.GetConfigurationByName(ctx, "base")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, map[string]string{"env": "prod"}, stored.Selector)
+
+	require.NoError(t, s.DeleteConfigurationIfVersion(ctx, "base", 3))
+	stored, err = s.GetConfigurationByName(ctx, "base")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	assert.ErrorIs(t, s.DeleteConfigurationIfVersion(ctx, "base", 3), store.ErrVersionConflict)
+}
+
+func testConfigurationForAgent(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateConfiguration(ctx, &model.Configuration{Name: "prod", RawConfig: "a: 1", Selector: map[string]string{"env": "prod"}}))
+	require.NoError(t, s.CreateConfiguration(ctx, &model.Configuration{Name: "pinned", RawConfig: "b: 2", Selector: map[string]string{"env": "none"}}))
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "prod-agent", Labels: model.Labels{"env": "prod"}}))
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "dev-agent", Labels: model.Labels{"env": "dev"}}))
+
+	config, err := s.GetConfiguration(ctx, "prod-agent")
+	require.NoError(t, err)
+	require.NotNil(t, config)
+	assert.Equal(t, "prod", config.Name)
+
+	config, err = s.GetConfiguration(ctx, "dev-agent")
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	// 指定配置优先于标签匹配
+	updated, err := s.UpdateAgentConfigurationName(ctx, "prod-agent", "pinned")
+	require.NoError(t, err)
+	assert.True(t, updated)
+	config, err = s.GetConfiguration(ctx, "prod-agent")
+	require.NoError(t, err)
+	require.NotNil(t, config)
+	assert.Equal(t, "pinned", config.Name)
+}
+
+func testConnectionHistory(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "agent-1"}))
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.CreateConnectionHistory(ctx, &model.AgentConnectionHistory{
+			AgentID:     "agent-1",
+			ConnectedAt: start.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	active, err := s.GetActiveConnectionHistory(ctx, "agent-1")
+	require.NoError(t, err)
+	require.NotNil(t, active)
+	assert.WithinDuration(t, start.Add(2*time.Minute), active.ConnectedAt, time.Millisecond)
+
+	disconnectedAt := active.ConnectedAt.Add(30 * time.Second)
+	active.DisconnectedAt = &disconnectedAt
+	require.NoError(t, s.UpdateConnectionHistory(ctx, active))
+
+	history, err := s.GetConnectionHistory(ctx, active.ID)
+	require.NoError(t, err)
+	require.NotNil(t, history.DurationSeconds)
+	assert.Equal(t, 30, *history.DurationSeconds)
+
+	_, err = s.GetConnectionHistory(ctx, active.ID+100)
+	assert.Error(t, err)
+
+	histories, total, err := s.ListConnectionHistoryByAgent(ctx, "agent-1", 2, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Len(t, histories, 2)
+	assert.Equal(t, active.ID, histories[0].ID)
+
+	counts, err := s.CountConnectionsSince(ctx, start.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), counts["agent-1"])
+
+	// 只返回指定 Agent 与时间范围有交集的记录
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "agent-2"}))
+	require.NoError(t, s.CreateConnectionHistory(ctx, &model.AgentConnectionHistory{AgentID: "agent-2", ConnectedAt: start}))
+	inRange, err := s.ListConnectionHistoryInRange(ctx, []string{"agent-1"}, start.Add(3*time.Minute), time.Now())
+	require.NoError(t, err)
+	require.Len(t, inRange, 2)
+	assert.Equal(t, "agent-1", inRange[0].AgentID)
+	inRange, err = s.ListConnectionHistoryInRange(ctx, []string{"agent-1", "agent-2"}, start, time.Now())
+	require.NoError(t, err)
+	assert.Len(t, inRange, 4)
+	inRange, err = s.ListConnectionHistoryInRange(ctx, nil, start, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, inRange)
+}
+
+func testApplyHistory(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "agent-1"}))
+	require.NoError(t, s.CreateConfiguration(ctx, &model.Configuration{Name: "base", RawConfig: "a: 1"}))
+
+	first := &model.ConfigurationApplyHistory{AgentID: "agent-1", ConfigurationName: "base", ConfigHash: "h1"}
+	require.NoError(t, s.CreateApplyHistory(ctx, first))
+	assert.Equal(t, model.ApplyStatusPending, first.Status)
+
+	second := &model.ConfigurationApplyHistory{
+		AgentID:           "agent-1",
+		ConfigurationName: "base",
+		ConfigHash:        "h2",
+		CreatedAt:         first.CreatedAt.Add(time.Second),
+	}
+	require.NoError(t, s.CreateApplyHistory(ctx, second))
+
+	latest, err := s.GetLatestApplyHistory(ctx, "agent-1", "base")
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, latest.ID)
+
+	first.Status = model.ApplyStatusFailed
+	first.ErrorMessage = "invalid config"
+	require.NoError(t, s.UpdateApplyHistory(ctx, first))
+
+	pending, err := s.GetPendingApplyHistories(ctx)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, second.ID, pending[0].ID)
+
+	failed, err := s.ListFailedApplyHistorySince(ctx, "base", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, "invalid config", failed[0].ErrorMessage)
+
+	histories, total, err := s.ListApplyHistoryByConfig(ctx, "base", 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Len(t, histories, 2)
+
+	_, err = s.GetApplyHistory(ctx, second.ID+100)
+	assert.Error(t, err)
+}
+
+func testUsers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	user := &model.User{Username: "alice", Email: "alice@example.com", Password: "Secret123!", Role: "admin"}
+	require.NoError(t, s.CreateUser(ctx, user))
+	assert.NotZero(t, user.ID)
+	assert.True(t, user.IsActive)
+	assert.NotEqual(t, "Secret123!", user.Password)
+
+	assert.Error(t, s.CreateUser(ctx, &model.User{Username: "alice", Email: "other@example.com", Password: "Secret123!"}))
+	assert.Error(t, s.CreateUser(ctx, &model.User{Username: "other", Email: "alice@example.com", Password: "Secret123!"}))
+
+	found, err := s.GetUserByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, user.ID, found.ID)
+
+	found, err = s.GetUserByEmail(ctx, "nobody@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	count, err := s.CountActiveUsersWithRole(ctx, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	user.IsActive = false
+	require.NoError(t, s.UpdateUser(ctx, user))
+	count, err = s.CountActiveUsersWithRole(ctx, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+
+	require.NoError(t, s.DeleteUser(ctx, user.ID))
+	found, err = s.GetUserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func testTenantIsolation(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	org := &model.Organization{Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, s.CreateOrganization(ctx, org))
+	acme := tenant.WithOrganization(ctx, org.ID)
+	defaultOrg := tenant.WithOrganization(ctx, model.DefaultOrganizationID)
+
+	require.NoError(t, s.UpsertAgent(acme, &model.Agent{ID: "acme-agent"}))
+	require.NoError(t, s.UpsertAgent(defaultOrg, &model.Agent{ID: "default-agent"}))
+	require.NoError(t, s.CreateConfiguration(acme, &model.Configuration{Name: "shared", RawConfig: "acme: true"}))
+	require.NoError(t, s.CreateConfiguration(defaultOrg, &model.Configuration{Name: "shared", RawConfig: "default: true"}))
+
+	agent, err := s.GetAgent(acme, "acme-agent")
+	require.NoError(t, err)
+	require.NotNil(t, agent)
+	assert.Equal(t, org.ID, agent.OrganizationID)
+
+	agent, err = s.GetAgent(defaultOrg, "acme-agent")
+	require.NoError(t, err)
+	assert.Nil(t, agent)
+
+	agents, total, err := s.ListAgents(acme, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, agents, 1)
+	assert.Equal(t, "acme-agent", agents[0].ID)
+
+	// 未绑定组织时可以看到所有组织的数据
+	_, total, err = s.ListAgents(ctx, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	config, err := s.GetConfigurationByName(acme, "shared")
+	require.NoError(t, err)
+	require.NotNil(t, config)
+	assert.Equal(t, "acme: true", config.RawConfig)
+
+	config.RawConfig = "acme: false"
+	require.NoError(t, s.UpdateConfiguration(acme, config))
+	config, err = s.GetConfigurationByName(defaultOrg, "shared")
+	require.NoError(t, err)
+	assert.Equal(t, "default: true", config.RawConfig)
+	assert.Equal(t, 1, config.Version)
+
+	usage, err := s.GetOrganizationUsage(ctx, org.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), usage.Agents)
+}
+
+func testAcceptInvitation(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	invitation := &model.Invitation{TokenHash: "hash-1", Role: "user", ExpiresAt: time.Now().Add(time.Hour)}
+	require.NoError(t, s.CreateInvitation(ctx, invitation))
+
+	user := &model.User{Username: "invited", Email: "invited@example.com", Password: "Secret123!"}
+	require.NoError(t, s.AcceptInvitation(ctx, invitation, user))
+	assert.NotNil(t, invitation.AcceptedAt)
+	require.NotNil(t, invitation.AcceptedBy)
+	assert.Equal(t, user.ID, *invitation.AcceptedBy)
+
+	// 邀请只能使用一次, 失败时不会创建用户
+	again := &model.User{Username: "again", Email: "again@example.com", Password: "Secret123!"}
+	assert.ErrorIs(t, s.AcceptInvitation(ctx, invitation, again), store.ErrInvitationUnavailable)
+	found, err := s.GetUserByUsername(ctx, "again")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	invitations, err := s.ListInvitations(ctx, false)
+	require.NoError(t, err)
+	assert.Empty(t, invitations)
+}
+
+func testAuditLogs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	for _, action := range []string{"users.create", "users.mfa.reset", "configurations.update"} {
+		require.NoError(t, s.CreateAuditLog(ctx, &model.AuditLog{ActorType: model.AuditActorUser, Actor: "alice", Action: action}))
+	}
+
+	logs, total, err := s.ListAuditLogs(ctx, model.AuditFilter{Action: "users.*"}, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "users.mfa.reset", logs[0].Action)
+
+	var exported []string
+	require.NoError(t, s.ExportAuditLogs(ctx, model.AuditFilter{Actor: "alice"}, func(entry *model.AuditLog) error {
+		exported = append(exported, entry.Action)
+		return nil
+	}))
+	assert.Equal(t, []string{"users.create", "users.mfa.reset", "configurations.update"}, exported)
+}
+
+func testRetention(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.UpsertAgent(ctx, &model.Agent{ID: "agent-1"}))
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		connectedAt := start.Add(time.Duration(i) * time.Minute)
+		history := &model.AgentConnectionHistory{AgentID: "agent-1", ConnectedAt: connectedAt}
+		// 最早的一条连接仍未断开, 不会被删除
+		if i > 0 {
+			disconnectedAt := connectedAt.Add(time.Second)
+			history.DisconnectedAt = &disconnectedAt
+		}
+		require.NoError(t, s.CreateConnectionHistory(ctx, history))
+	}
+
+	policy := model.RetentionPolicy{Table: model.RetentionTableConnectionHistory, MaxRows: 1}
+	count, err := s.CountPrunableHistory(ctx, policy, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	ids, err := s.ListPrunableHistory(ctx, policy, time.Now())
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+	assert.Less(t, ids[0], ids[1])
+
+	// 分批删除, 已经删除的记录不重复计数
+	pruned, err := s.DeleteHistoryBatch(ctx, model.RetentionTableConnectionHistory, ids[:1])
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+	pruned, err = s.DeleteHistoryBatch(ctx, model.RetentionTableConnectionHistory, ids)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), pruned)
+
+	_, total, err := s.ListConnectionHistoryByAgent(ctx, "agent-1", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+
+	// 没有设置清理条件时不删除任何记录
+	count, err = s.CountPrunableHistory(ctx, model.RetentionPolicy{Table: model.RetentionTableConnectionHistory}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func testCreateDisabledWebhook(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	// 数据库列默认启用, 显式禁用的 Webhook 必须保持禁用
+	hook := &model.Webhook{Name: "disabled", URL: "http://example.com/hook", Secret: "secret", Enabled: false}
+	require.NoError(t, s.CreateWebhook(ctx, hook))
+
+	stored, err := s.GetWebhook(ctx, hook.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.False(t, stored.Enabled)
+}
+
+func testCreateDisabledAlertRule(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	// 数据库列默认启用, 显式禁用的规则必须保持禁用, 否则会开始评估并触发告警
+	rule := &model.AlertRule{Name: "disabled", Type: model.AlertRuleOfflineRatio, Threshold: 0.5, Enabled: false}
+	require.NoError(t, s.CreateAlertRule(ctx, rule))
+
+	stored, err := s.GetAlertRule(ctx, rule.ID)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.False(t, stored.Enabled)
+}
+
+func testBulkJobs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	for _, createdBy := range []string{"alice", "bob", "alice"} {
+		job := &model.BulkJob{Action: model.BulkActionResync, Status: model.BulkJobRunning, CreatedBy: createdBy}
+		job.AddResult(model.BulkAgentResult{AgentID: "agent-1", Status: model.BulkResultSucceeded})
+		require.NoError(t, s.CreateBulkJob(ctx, job))
+	}
+
+	jobs, total, err := s.ListBulkJobs(ctx, "", 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+	assert.Len(t, jobs, 3)
+
+	// 按创建者过滤, 列表不包含每个 Agent 的结果
+	jobs, total, err = s.ListBulkJobs(ctx, "alice", 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Len(t, jobs, 2)
+	for _, job := range jobs {
+		assert.Equal(t, "alice", job.CreatedBy)
+		assert.Empty(t, job.Results)
+	}
+
+	// 服务启动时仍在执行的任务标记为已中断
+	interrupted, err := s.InterruptRunningBulkJobs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), interrupted)
+
+	job, err := s.GetBulkJob(ctx, jobs[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, job)
+	assert.Equal(t, model.BulkJobInterrupted, job.Status)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Len(t, job.Results, 1)
+}
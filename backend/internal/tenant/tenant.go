@@ -0,0 +1,29 @@
+// Package tenant 在 context 中传递当前请求所属的组织 (租户)
+//
+// 存储层根据 context 中的组织自动限定查询范围, 因此处理器和后台任务只需在
+// context 中设置组织, 不需要在每个查询中显式过滤。
+package tenant
+
+import "context"
+
+// contextKey context 中保存组织 ID 的键
+type contextKey struct{}
+
+// WithOrganization 返回绑定到指定组织的 context, id 为 0 表示不限定组织
+func WithOrganization(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// WithoutOrganization 返回不限定组织的 context, 用于跨组织的全局查询 (如登录时按用户名查找用户)
+func WithoutOrganization(ctx context.Context) context.Context {
+	return WithOrganization(ctx, 0)
+}
+
+// OrganizationID 返回 context 绑定的组织, 未绑定时 ok 为 false
+func OrganizationID(ctx context.Context) (id uint, ok bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	id, _ = ctx.Value(contextKey{}).(uint)
+	return id, id != 0
+}
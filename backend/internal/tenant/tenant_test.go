@@ -0,0 +1,22 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrganizationID(t *testing.T) {
+	_, ok := OrganizationID(context.Background())
+	assert.False(t, ok)
+
+	ctx := WithOrganization(context.Background(), 7)
+	id, ok := OrganizationID(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, uint(7), id)
+
+	// 显式取消组织限定
+	_, ok = OrganizationID(WithoutOrganization(ctx))
+	assert.False(t, ok)
+}
@@ -0,0 +1,413 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/model"
+	"github.com/cc1024201/opamp-platform/internal/tenant"
+)
+
+// TestEventType "发送测试事件" 使用的事件类型
+const TestEventType events.Type = "webhook.test"
+
+// Store 定义 Webhook 投递所需的存储接口
+type Store interface {
+	ListWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	GetWebhook(ctx context.Context, id uint) (*model.Webhook, error)
+	CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	ListDueWebhookDeliveries(ctx context.Context, before time.Time, limit int) ([]*model.WebhookDelivery, error)
+}
+
+// Config Webhook 投递配置
+type Config struct {
+	Workers        int           // 并发投递数
+	MaxAttempts    int           // 最大投递次数 (含首次)
+	InitialBackoff time.Duration // 首次重试间隔
+	MaxBackoff     time.Duration // 最大重试间隔
+	RetryInterval  time.Duration // 扫描待重试记录的间隔
+	Timeout        time.Duration // 单次请求超时
+}
+
+// Dispatcher 订阅事件总线并将事件投递到 Webhook
+type Dispatcher struct {
+	store  Store
+	bus    *events.Bus
+	client *http.Client
+	logger *zap.Logger
+	config Config
+	queue  chan *model.WebhookDelivery
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+
+	// inflight 已入队或正在投递的记录, 重试循环跳过这些记录
+	mu       sync.Mutex
+	inflight map[uint]struct{}
+}
+
+// NewDispatcher 创建新的 Webhook 投递器
+func NewDispatcher(store Store, bus *events.Bus, logger *zap.Logger, config Config) *Dispatcher {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	// 默认值
+	if config.Workers <= 0 {
+		config.Workers = 4
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 1 * time.Hour
+	}
+	if config.RetryInterval <= 0 {
+		config.RetryInterval = 15 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &Dispatcher{
+		store:    store,
+		bus:      bus,
+		client:   &http.Client{Timeout: config.Timeout},
+		logger:   logger,
+		config:   config,
+		queue:    make(chan *model.WebhookDelivery, 256),
+		stopCh:   make(chan struct{}),
+		inflight: make(map[uint]struct{}),
+	}
+}
+
+// Start 启动事件消费、投递和重试循环
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.logger.Info("starting webhook dispatcher",
+		zap.Int("workers", d.config.Workers),
+		zap.Int("max_attempts", d.config.MaxAttempts))
+
+	sub, _ := d.bus.SubscribeWithBuffer(events.Filter{}, 0, 1024)
+
+	d.wg.Add(1)
+	go d.consume(ctx, sub)
+
+	for i := 0; i < d.config.Workers; i++ {
+		d.wg.Add(1)
+		go d.work(ctx)
+	}
+
+	d.wg.Add(1)
+	go d.retryLoop(ctx)
+}
+
+// Stop 停止投递器
+func (d *Dispatcher) Stop() {
+	d.logger.Info("stopping webhook dispatcher")
+	close(d.stopCh)
+	d.wg.Wait()
+}
+
+// SendTest 向指定 Webhook 同步发送一条测试事件并返回投递记录
+func (d *Dispatcher) SendTest(ctx context.Context, webhook *model.Webhook) (*model.WebhookDelivery, error) {
+	event := &events.Event{
+		Type:           TestEventType,
+		OrganizationID: webhook.OrganizationID,
+		Timestamp:      time.Now(),
+		Data: map[string]interface{}{
+			"message": "this is a test event",
+			"webhook": webhook.Name,
+		},
+	}
+
+	delivery, err := d.newDelivery(ctx, webhook, event)
+	if err != nil {
+		return nil, err
+	}
+
+	d.claim(delivery.ID)
+	defer d.release(delivery.ID)
+
+	d.attempt(ctx, webhook, delivery)
+	return delivery, nil
+}
+
+// consume 消费事件总线并为匹配的 Webhook 创建投递记录
+func (d *Dispatcher) consume(ctx context.Context, sub *events.Subscription) {
+	defer d.wg.Done()
+	defer d.bus.Unsubscribe(sub)
+
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			d.handleEvent(ctx, e)
+		case <-d.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleEvent 为订阅了该事件的 Webhook 创建投递记录并加入投递队列
+//
+// 事件只投递给所属组织的 Webhook, 没有组织的事件属于默认组织。
+func (d *Dispatcher) handleEvent(ctx context.Context, e *events.Event) {
+	orgID := e.OrganizationID
+	if orgID == 0 {
+		orgID = model.DefaultOrganizationID
+	}
+	ctx = tenant.WithOrganization(ctx, orgID)
+
+	webhooks, err := d.store.ListWebhooks(ctx)
+	if err != nil {
+		d.logger.Error("failed to list webhooks", zap.Error(err))
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.Matches(string(e.Type), e.Labels) {
+			continue
+		}
+
+		delivery, err := d.newDelivery(ctx, webhook, e)
+		if err != nil {
+			d.logger.Error("failed to create webhook delivery",
+				zap.Uint("webhook_id", webhook.ID),
+				zap.Error(err))
+			continue
+		}
+
+		d.claim(delivery.ID)
+		// 队列已满时交给重试循环处理
+		select {
+		case d.queue <- delivery:
+		default:
+			d.release(delivery.ID)
+		}
+	}
+}
+
+// newDelivery 创建投递记录
+//
+// NextAttemptAt 预先设置为首次重试时间, 这样即使首次投递因进程退出而中断,
+// 重试循环也能在之后接手; 投递进行中时记录在 inflight 中, 重试循环不会重复入队。
+func (d *Dispatcher) newDelivery(ctx context.Context, webhook *model.Webhook, e *events.Event) (*model.WebhookDelivery, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	nextAttemptAt := time.Now().Add(d.config.InitialBackoff)
+	delivery := &model.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		EventID:       e.ID,
+		EventType:     string(e.Type),
+		Payload:       string(payload),
+		Status:        model.DeliveryStatusPending,
+		NextAttemptAt: &nextAttemptAt,
+	}
+	if err := d.store.CreateWebhookDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// work 处理投递队列
+func (d *Dispatcher) work(ctx context.Context) {
+	defer d.wg.Done()
+
+	for {
+		select {
+		case delivery := <-d.queue:
+			d.process(ctx, delivery)
+		case <-d.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// process 投递一条队列中的记录, 完成后将其移出 inflight
+func (d *Dispatcher) process(ctx context.Context, delivery *model.WebhookDelivery) {
+	defer d.release(delivery.ID)
+
+	webhook, err := d.store.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		d.logger.Error("failed to get webhook",
+			zap.Uint("webhook_id", delivery.WebhookID),
+			zap.Error(err))
+		return
+	}
+	if webhook == nil {
+		// Webhook 已删除, 投递记录随之删除
+		return
+	}
+	if !webhook.Enabled {
+		d.finish(ctx, delivery, model.DeliveryStatusFailed, "webhook disabled")
+		return
+	}
+	d.attempt(ctx, webhook, delivery)
+}
+
+// claim 将投递记录标记为进行中, 已在进行中时返回 false
+func (d *Dispatcher) claim(id uint) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.inflight[id]; ok {
+		return false
+	}
+	d.inflight[id] = struct{}{}
+	return true
+}
+
+// release 清除投递记录的进行中标记
+func (d *Dispatcher) release(id uint) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inflight, id)
+}
+
+// retryLoop 定期扫描到达重试时间的投递记录
+func (d *Dispatcher) retryLoop(ctx context.Context) {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.config.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.enqueueDue(ctx)
+		case <-d.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// enqueueDue 将到达重试时间的投递记录加入队列, 跳过已入队或正在投递的记录
+func (d *Dispatcher) enqueueDue(ctx context.Context) {
+	deliveries, err := d.store.ListDueWebhookDeliveries(ctx, time.Now(), cap(d.queue))
+	if err != nil {
+		d.logger.Error("failed to list due webhook deliveries", zap.Error(err))
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if !d.claim(delivery.ID) {
+			continue
+		}
+
+		select {
+		case d.queue <- delivery:
+		default:
+			d.release(delivery.ID)
+			return
+		}
+	}
+}
+
+// attempt 执行一次投递并根据结果更新投递记录
+func (d *Dispatcher) attempt(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) {
+	delivery.Attempts++
+
+	code, err := d.send(ctx, webhook, delivery)
+	delivery.ResponseCode = code
+
+	if err == nil {
+		now := time.Now()
+		delivery.DeliveredAt = &now
+		d.finish(ctx, delivery, model.DeliveryStatusSucceeded, "")
+		return
+	}
+
+	d.logger.Warn("webhook delivery failed",
+		zap.Uint("webhook_id", webhook.ID),
+		zap.Uint("delivery_id", delivery.ID),
+		zap.Int("attempts", delivery.Attempts),
+		zap.Error(err))
+
+	if delivery.Attempts >= d.config.MaxAttempts {
+		d.finish(ctx, delivery, model.DeliveryStatusFailed, err.Error())
+		return
+	}
+
+	next := time.Now().Add(d.backoff(delivery.Attempts))
+	delivery.NextAttemptAt = &next
+	d.finish(ctx, delivery, model.DeliveryStatusPending, err.Error())
+}
+
+// finish 保存投递结果
+func (d *Dispatcher) finish(ctx context.Context, delivery *model.WebhookDelivery, status model.DeliveryStatus, lastError string) {
+	delivery.Status = status
+	delivery.LastError = lastError
+	if status != model.DeliveryStatusPending {
+		delivery.NextAttemptAt = nil
+	}
+
+	if err := d.store.UpdateWebhookDelivery(ctx, delivery); err != nil {
+		d.logger.Error("failed to update webhook delivery",
+			zap.Uint("delivery_id", delivery.ID),
+			zap.Error(err))
+	}
+}
+
+// send 发送带签名的 HTTP 请求, 2xx 视为成功
+func (d *Dispatcher) send(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "opamp-platform-webhook")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff 计算第 attempts 次失败后的重试间隔 (指数退避)
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.InitialBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= d.config.MaxBackoff {
+			return d.config.MaxBackoff
+		}
+	}
+	return delay
+}
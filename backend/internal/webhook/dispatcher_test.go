@@ -0,0 +1,328 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/cc1024201/opamp-platform/internal/events"
+	"github.com/cc1024201/opamp-platform/internal/model"
+)
+
+// mockStore 内存实现的 Store
+type mockStore struct {
+	mu         sync.Mutex
+	webhooks   map[uint]*model.Webhook
+	deliveries map[uint]*model.WebhookDelivery
+	nextID     uint
+}
+
+func newMockStore(webhooks ...*model.Webhook) *mockStore {
+	s := &mockStore{
+		webhooks:   make(map[uint]*model.Webhook),
+		deliveries: make(map[uint]*model.WebhookDelivery),
+	}
+	for _, w := range webhooks {
+		s.webhooks[w.ID] = w
+	}
+	return s
+}
+
+func (s *mockStore) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*model.Webhook
+	for _, w := range s.webhooks {
+		result = append(result, w)
+	}
+	return result, nil
+}
+
+func (s *mockStore) GetWebhook(ctx context.Context, id uint) (*model.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.webhooks[id], nil
+}
+
+func (s *mockStore) CreateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	delivery.ID = s.nextID
+	copied := *delivery
+	s.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (s *mockStore) UpdateWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	copied := *delivery
+	s.deliveries[delivery.ID] = &copied
+	return nil
+}
+
+func (s *mockStore) ListDueWebhookDeliveries(ctx context.Context, before time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []*model.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.Status == model.DeliveryStatusPending && d.NextAttemptAt != nil && !d.NextAttemptAt.After(before) {
+			copied := *d
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (s *mockStore) delivery(id uint) model.WebhookDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.deliveries[id]
+}
+
+// receiver 记录收到的请求的测试接收端
+type receiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	status   int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := r.status
+	r.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"type":"agent.disconnected"}`)
+	signature := Sign("secret", 1700000000, body)
+
+	assert.Contains(t, signature, "sha256=")
+	assert.True(t, Verify("secret", 1700000000, body, signature))
+	assert.False(t, Verify("other-secret", 1700000000, body, signature))
+	assert.False(t, Verify("secret", 1700000001, body, signature))
+	assert.False(t, Verify("secret", 1700000000, []byte("tampered"), signature))
+}
+
+func TestGenerateSecret(t *testing.T) {
+	s1, err := GenerateSecret()
+	require.NoError(t, err)
+	s2, err := GenerateSecret()
+	require.NoError(t, err)
+
+	assert.Len(t, s1, 64)
+	assert.NotEqual(t, s1, s2)
+}
+
+func TestDispatcher_DeliversMatchingEvents(t *testing.T) {
+	recv := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	webhook := &model.Webhook{
+		ID:         1,
+		Name:       "oncall",
+		URL:        server.URL,
+		EventTypes: []string{string(events.TypeAgentDisconnected)},
+		Selector:   map[string]string{"env": "prod"},
+		Secret:     "s3cret",
+		Enabled:    true,
+	}
+	store := newMockStore(webhook)
+	bus := events.NewBus(100)
+
+	dispatcher := NewDispatcher(store, bus, zap.NewNop(), Config{})
+	dispatcher.Start(context.Background())
+	defer dispatcher.Stop()
+
+	require.Eventually(t, func() bool { return bus.SubscriberCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	bus.Publish(events.Event{Type: events.TypeAgentConnected, AgentID: "a1", Labels: model.Labels{"env": "prod"}})
+	bus.Publish(events.Event{Type: events.TypeAgentDisconnected, AgentID: "a2", Labels: model.Labels{"env": "dev"}})
+	bus.Publish(events.Event{Type: events.TypeAgentDisconnected, AgentID: "a3", Labels: model.Labels{"env": "prod"}})
+
+	require.Eventually(t, func() bool { return recv.count() == 1 }, 2*time.Second, 10*time.Millisecond)
+
+	recv.mu.Lock()
+	req := recv.requests[0]
+	body := recv.bodies[0]
+	recv.mu.Unlock()
+
+	// 校验签名
+	timestamp, err := strconv.ParseInt(req.Header.Get(HeaderTimestamp), 10, 64)
+	require.NoError(t, err)
+	assert.True(t, Verify("s3cret", timestamp, body, req.Header.Get(HeaderSignature)))
+	assert.Equal(t, string(events.TypeAgentDisconnected), req.Header.Get(HeaderEvent))
+
+	var event events.Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, "a3", event.AgentID)
+
+	require.Eventually(t, func() bool {
+		return store.delivery(1).Status == model.DeliveryStatusSucceeded
+	}, time.Second, 10*time.Millisecond)
+	delivery := store.delivery(1)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.Equal(t, http.StatusOK, delivery.ResponseCode)
+	assert.NotNil(t, delivery.DeliveredAt)
+	assert.Nil(t, delivery.NextAttemptAt)
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	recv := &receiver{status: http.StatusInternalServerError}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	webhook := &model.Webhook{ID: 1, Name: "flaky", URL: server.URL, Secret: "s", Enabled: true}
+	store := newMockStore(webhook)
+
+	dispatcher := NewDispatcher(store, events.NewBus(10), zap.NewNop(), Config{
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+	})
+	ctx := context.Background()
+
+	delivery, err := dispatcher.SendTest(ctx, webhook)
+	require.NoError(t, err)
+
+	// 首次失败: 保持 pending 并安排重试
+	saved := store.delivery(delivery.ID)
+	assert.Equal(t, model.DeliveryStatusPending, saved.Status)
+	assert.Equal(t, 1, saved.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, saved.ResponseCode)
+	require.NotNil(t, saved.NextAttemptAt)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), *saved.NextAttemptAt, 5*time.Second)
+
+	// 第二次失败: 退避时间翻倍
+	dispatcher.attempt(ctx, webhook, delivery)
+	saved = store.delivery(delivery.ID)
+	assert.Equal(t, model.DeliveryStatusPending, saved.Status)
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), *saved.NextAttemptAt, 5*time.Second)
+
+	// 第三次失败: 达到最大次数, 标记为失败
+	dispatcher.attempt(ctx, webhook, delivery)
+	saved = store.delivery(delivery.ID)
+	assert.Equal(t, model.DeliveryStatusFailed, saved.Status)
+	assert.Equal(t, 3, saved.Attempts)
+	assert.Nil(t, saved.NextAttemptAt)
+	assert.Contains(t, saved.LastError, "500")
+	assert.Equal(t, 3, recv.count())
+}
+
+func TestDispatcher_EnqueueDue(t *testing.T) {
+	recv := &receiver{status: http.StatusNoContent}
+	server := httptest.NewServer(recv)
+	defer server.Close()
+
+	webhook := &model.Webhook{ID: 1, Name: "retry", URL: server.URL, Secret: "s", Enabled: true}
+	store := newMockStore(webhook)
+
+	past := time.Now().Add(-time.Second)
+	due := &model.WebhookDelivery{
+		WebhookID:     1,
+		EventType:     string(events.TypeConfigFailed),
+		Payload:       `{"type":"config.failed"}`,
+		Status:        model.DeliveryStatusPending,
+		Attempts:      1,
+		NextAttemptAt: &past,
+	}
+	require.NoError(t, store.CreateWebhookDelivery(context.Background(), due))
+
+	dispatcher := NewDispatcher(store, events.NewBus(10), zap.NewNop(), Config{RetryInterval: 20 * time.Millisecond})
+	dispatcher.Start(context.Background())
+	defer dispatcher.Stop()
+
+	require.Eventually(t, func() bool {
+		return store.delivery(due.ID).Status == model.DeliveryStatusSucceeded
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, store.delivery(due.ID).Attempts)
+	assert.Equal(t, 1, recv.count())
+}
+
+func TestDispatcher_EnqueueDueSkipsInflight(t *testing.T) {
+	// 接收端在收到请求后阻塞, 模拟进行中的投递
+	received := make(chan struct{}, 10)
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-unblock
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	webhook := &model.Webhook{ID: 1, Name: "slow", URL: server.URL, Secret: "s", Enabled: true}
+	store := newMockStore(webhook)
+
+	dispatcher := NewDispatcher(store, events.NewBus(10), zap.NewNop(), Config{
+		Workers:       2,
+		RetryInterval: time.Hour,
+	})
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Second)
+	due := &model.WebhookDelivery{
+		WebhookID:     1,
+		EventType:     string(events.TypeConfigFailed),
+		Payload:       `{"type":"config.failed"}`,
+		Status:        model.DeliveryStatusPending,
+		NextAttemptAt: &past,
+	}
+	require.NoError(t, store.CreateWebhookDelivery(ctx, due))
+
+	dispatcher.Start(ctx)
+	defer dispatcher.Stop()
+	defer close(unblock)
+
+	dispatcher.enqueueDue(ctx)
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("delivery was not sent")
+	}
+
+	// 投递进行中, 再次扫描不会重复入队
+	dispatcher.enqueueDue(ctx)
+	dispatcher.enqueueDue(ctx)
+	select {
+	case <-received:
+		t.Fatal("in-flight delivery was sent twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Empty(t, dispatcher.queue)
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	dispatcher := NewDispatcher(newMockStore(), events.NewBus(10), nil, Config{
+		InitialBackoff: time.Second,
+		MaxBackoff:     10 * time.Second,
+	})
+
+	assert.Equal(t, time.Second, dispatcher.backoff(1))
+	assert.Equal(t, 2*time.Second, dispatcher.backoff(2))
+	assert.Equal(t, 4*time.Second, dispatcher.backoff(3))
+	assert.Equal(t, 8*time.Second, dispatcher.backoff(4))
+	assert.Equal(t, 10*time.Second, dispatcher.backoff(5))
+	assert.Equal(t, 10*time.Second, dispatcher.backoff(20))
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	// HeaderSignature 签名 header, 格式为 "sha256=<hex>"
+	HeaderSignature = "X-OpAMP-Signature"
+	// HeaderTimestamp 签名时间戳 header (Unix 秒)
+	HeaderTimestamp = "X-OpAMP-Timestamp"
+	// HeaderEvent 事件类型 header
+	HeaderEvent = "X-OpAMP-Event"
+	// HeaderDelivery 投递记录 ID header
+	HeaderDelivery = "X-OpAMP-Delivery"
+)
+
+// Sign 计算负载签名
+//
+// 签名内容为 "<timestamp>.<body>", 接收方应使用相同的方式校验并拒绝过旧的时间戳以防重放。
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验负载签名
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	expected := Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// GenerateSecret 生成随机签名密钥
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,5 @@
+-- 删除 webhook_deliveries 表
+DROP TABLE IF EXISTS webhook_deliveries;
+
+-- 删除 webhooks 表
+DROP TABLE IF EXISTS webhooks;
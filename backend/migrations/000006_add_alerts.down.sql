@@ -0,0 +1,8 @@
+-- 删除 alert_silences 表
+DROP TABLE IF EXISTS alert_silences;
+
+-- 删除 alerts 表
+DROP TABLE IF EXISTS alerts;
+
+-- 删除 alert_rules 表
+DROP TABLE IF EXISTS alert_rules;
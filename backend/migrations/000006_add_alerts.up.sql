@@ -0,0 +1,70 @@
+-- 创建 alert_rules 表
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT,
+    type VARCHAR(50) NOT NULL,
+    selector JSONB,
+    agent_id VARCHAR(255),
+    configuration_name VARCHAR(255),
+    threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+    window_seconds INTEGER NOT NULL DEFAULT 0,
+    for_seconds INTEGER NOT NULL DEFAULT 0,
+    severity VARCHAR(20) NOT NULL DEFAULT 'warning',
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 创建 alerts 表
+CREATE TABLE IF NOT EXISTS alerts (
+    id BIGSERIAL PRIMARY KEY,
+    rule_id INTEGER NOT NULL,
+    rule_name VARCHAR(255),
+    fingerprint VARCHAR(512) NOT NULL,
+    state VARCHAR(20) NOT NULL,
+    severity VARCHAR(20),
+    summary TEXT,
+    agent_id VARCHAR(255),
+    labels JSONB,
+    value DOUBLE PRECISION,
+    silenced BOOLEAN NOT NULL DEFAULT false,
+    started_at TIMESTAMP NOT NULL,
+    fired_at TIMESTAMP,
+    resolved_at TIMESTAMP,
+    last_evaluated_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_alerts_rule
+        FOREIGN KEY (rule_id)
+        REFERENCES alert_rules(id)
+        ON DELETE CASCADE
+);
+
+-- 为 alerts 添加索引
+CREATE INDEX IF NOT EXISTS idx_alerts_rule_id ON alerts(rule_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_fingerprint ON alerts(fingerprint);
+CREATE INDEX IF NOT EXISTS idx_alerts_state ON alerts(state);
+CREATE INDEX IF NOT EXISTS idx_alerts_agent_id ON alerts(agent_id);
+
+-- 创建 alert_silences 表
+CREATE TABLE IF NOT EXISTS alert_silences (
+    id SERIAL PRIMARY KEY,
+    rule_id INTEGER,
+    matchers JSONB,
+    comment TEXT,
+    created_by VARCHAR(255),
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_alert_silences_rule
+        FOREIGN KEY (rule_id)
+        REFERENCES alert_rules(id)
+        ON DELETE CASCADE
+);
+
+-- 为 alert_silences 添加索引
+CREATE INDEX IF NOT EXISTS idx_alert_silences_rule_id ON alert_silences(rule_id);
+CREATE INDEX IF NOT EXISTS idx_alert_silences_ends_at ON alert_silences(ends_at);
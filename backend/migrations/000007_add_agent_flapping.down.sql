@@ -0,0 +1,6 @@
+-- 删除索引
+DROP INDEX IF EXISTS idx_agents_flapping;
+
+-- 删除抖动检测字段
+ALTER TABLE agents DROP COLUMN IF EXISTS flapping_since;
+ALTER TABLE agents DROP COLUMN IF EXISTS flapping;
@@ -0,0 +1,6 @@
+-- 为 agents 表添加抖动检测字段
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS flapping BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS flapping_since TIMESTAMP;
+
+-- 添加索引以便快速查询抖动中的 Agent
+CREATE INDEX IF NOT EXISTS idx_agents_flapping ON agents(flapping);
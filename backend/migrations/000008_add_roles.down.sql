@@ -0,0 +1,2 @@
+-- 删除 roles 表
+DROP TABLE IF EXISTS roles;
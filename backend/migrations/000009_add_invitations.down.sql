@@ -0,0 +1,2 @@
+-- 删除 invitations 表
+DROP TABLE IF EXISTS invitations;
@@ -0,0 +1,15 @@
+-- 创建 invitations 表
+CREATE TABLE IF NOT EXISTS invitations (
+    id SERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    email VARCHAR(255),
+    role VARCHAR(64) NOT NULL,
+    created_by VARCHAR(255),
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP,
+    accepted_by INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 添加索引以便清理过期邀请
+CREATE INDEX IF NOT EXISTS idx_invitations_expires_at ON invitations(expires_at);
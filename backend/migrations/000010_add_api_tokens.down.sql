@@ -0,0 +1,5 @@
+-- 删除 api_tokens 表
+DROP TABLE IF EXISTS api_tokens;
+
+-- 删除 users 表的服务账号标记
+ALTER TABLE users DROP COLUMN IF EXISTS is_service_account;
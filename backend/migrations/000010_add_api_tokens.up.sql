@@ -0,0 +1,24 @@
+-- 为 users 表添加服务账号标记
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_service_account BOOLEAN NOT NULL DEFAULT false;
+
+-- 创建 api_tokens 表
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    user_id INTEGER NOT NULL,
+    scopes JSONB,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_api_tokens_user
+        FOREIGN KEY (user_id)
+        REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- 添加索引以便按用户查询
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
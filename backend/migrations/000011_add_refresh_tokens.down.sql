@@ -0,0 +1,8 @@
+-- 删除 revoked_access_tokens 表
+DROP TABLE IF EXISTS revoked_access_tokens;
+
+-- 删除 refresh_tokens 表
+DROP TABLE IF EXISTS refresh_tokens;
+
+-- 删除 users 表的令牌版本
+ALTER TABLE users DROP COLUMN IF EXISTS token_version;
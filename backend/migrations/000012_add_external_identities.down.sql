@@ -0,0 +1,4 @@
+-- 删除外部身份相关字段
+DROP INDEX IF EXISTS idx_users_external_id;
+ALTER TABLE users DROP COLUMN IF EXISTS external_id;
+ALTER TABLE users DROP COLUMN IF EXISTS auth_provider;
@@ -0,0 +1,6 @@
+-- 为 users 表添加认证来源和外部身份标识 (OIDC 单点登录)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS auth_provider VARCHAR(32) NOT NULL DEFAULT 'local';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_id VARCHAR(512);
+
+-- 外部身份唯一, 允许本地用户为 NULL
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_external_id ON users(external_id);
@@ -0,0 +1,8 @@
+-- 删除 MFA 相关表
+DROP TABLE IF EXISTS mfa_required_roles;
+DROP TABLE IF EXISTS mfa_recovery_codes;
+
+-- 删除 users 表的 MFA 字段
+ALTER TABLE users DROP COLUMN IF EXISTS mfa_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS mfa_secret;
+ALTER TABLE users DROP COLUMN IF EXISTS mfa_enabled;
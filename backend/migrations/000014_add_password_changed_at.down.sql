@@ -0,0 +1,2 @@
+-- 删除 users 表的密码修改时间字段
+ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
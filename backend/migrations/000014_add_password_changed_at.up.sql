@@ -0,0 +1,5 @@
+-- 为 users 表添加密码修改时间, 用于密码有效期策略
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP;
+
+-- 已有用户以创建时间作为密码修改时间
+UPDATE users SET password_changed_at = created_at WHERE password_changed_at IS NULL;
@@ -0,0 +1,7 @@
+-- 删除 audit_logs 表
+DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs;
+DROP FUNCTION IF EXISTS prevent_audit_log_changes();
+DROP TABLE IF EXISTS audit_logs;
+
+-- 删除 configurations 表的最后修改人字段
+ALTER TABLE configurations DROP COLUMN IF EXISTS updated_by;
@@ -0,0 +1,43 @@
+-- 为 configurations 表添加最后修改人
+ALTER TABLE configurations ADD COLUMN IF NOT EXISTS updated_by VARCHAR(255);
+
+-- 创建 audit_logs 表
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id BIGSERIAL PRIMARY KEY,
+    actor_type VARCHAR(32) NOT NULL,
+    actor_id INTEGER,
+    actor VARCHAR(255),
+    api_token_id INTEGER,
+    action VARCHAR(128) NOT NULL,
+    target_type VARCHAR(64),
+    target_id VARCHAR(255),
+    method VARCHAR(16),
+    path TEXT,
+    status INTEGER,
+    changes JSONB,
+    details JSONB,
+    source_ip VARCHAR(64),
+    request_id VARCHAR(128),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 添加索引以便按条件查询
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_type ON audit_logs(actor_type);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor ON audit_logs(actor);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs(action);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_target ON audit_logs(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_request_id ON audit_logs(request_id);
+
+-- 审计日志只追加, 禁止修改和删除
+CREATE OR REPLACE FUNCTION prevent_audit_log_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs;
+CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION prevent_audit_log_changes();
@@ -0,0 +1,62 @@
+-- 删除按组织查询的索引
+DROP INDEX IF EXISTS idx_audit_logs_organization_id;
+DROP INDEX IF EXISTS idx_alert_silences_organization_id;
+DROP INDEX IF EXISTS idx_alerts_organization_id;
+DROP INDEX IF EXISTS idx_invitations_organization_id;
+DROP INDEX IF EXISTS idx_users_organization_id;
+DROP INDEX IF EXISTS idx_configuration_apply_history_organization_id;
+DROP INDEX IF EXISTS idx_configuration_history_organization_id;
+DROP INDEX IF EXISTS idx_agent_connection_history_organization_id;
+DROP INDEX IF EXISTS idx_agents_organization_id;
+
+-- 恢复全局唯一的名称 (默认组织以外的同名数据需先手动清理)
+DROP INDEX IF EXISTS idx_package_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_package_unique
+    ON packages(name, version, platform, arch);
+DROP INDEX IF EXISTS idx_alert_rules_org_name;
+ALTER TABLE alert_rules ADD CONSTRAINT alert_rules_name_key UNIQUE (name);
+DROP INDEX IF EXISTS idx_webhooks_org_name;
+ALTER TABLE webhooks ADD CONSTRAINT webhooks_name_key UNIQUE (name);
+DROP INDEX IF EXISTS idx_roles_org_name;
+ALTER TABLE roles ADD CONSTRAINT roles_name_key UNIQUE (name);
+
+-- 恢复 MFA 策略主键
+ALTER TABLE mfa_required_roles DROP CONSTRAINT IF EXISTS mfa_required_roles_pkey;
+ALTER TABLE mfa_required_roles ADD PRIMARY KEY (role);
+
+-- 恢复配置主键和配置历史外键
+ALTER TABLE configuration_apply_history DROP CONSTRAINT IF EXISTS fk_config_apply;
+ALTER TABLE configuration_history DROP CONSTRAINT IF EXISTS unique_config_version;
+ALTER TABLE configuration_history DROP CONSTRAINT IF EXISTS fk_configuration;
+ALTER TABLE configurations DROP CONSTRAINT IF EXISTS configurations_pkey;
+ALTER TABLE configurations ADD PRIMARY KEY (name);
+ALTER TABLE configuration_history ADD CONSTRAINT unique_config_version
+    UNIQUE (configuration_name, version);
+ALTER TABLE configuration_history ADD CONSTRAINT fk_configuration
+    FOREIGN KEY (configuration_name)
+    REFERENCES configurations(name)
+    ON DELETE CASCADE;
+ALTER TABLE configuration_apply_history ADD CONSTRAINT fk_config_apply
+    FOREIGN KEY (configuration_name)
+    REFERENCES configurations(name)
+    ON DELETE CASCADE;
+
+-- 删除各表的所属组织
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE alert_silences DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE alerts DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE mfa_required_roles DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE invitations DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE roles DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE users DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE packages DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE configuration_apply_history DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE configuration_history DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE configurations DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE agent_connection_history DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE agents DROP COLUMN IF EXISTS organization_id;
+
+-- 删除 organizations 表
+DROP TABLE IF EXISTS organizations;
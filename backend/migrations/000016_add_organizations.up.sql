@@ -0,0 +1,76 @@
+-- 创建 organizations 表
+CREATE TABLE IF NOT EXISTS organizations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    display_name VARCHAR(255),
+    secret_key_hash VARCHAR(64) UNIQUE, -- Agent 连接 Secret Key 的 SHA-256
+    max_agents INTEGER NOT NULL DEFAULT 0, -- 0 表示不限制
+    max_package_bytes BIGINT NOT NULL DEFAULT 0, -- 0 表示不限制
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- 默认组织, 已有数据全部归入默认组织
+INSERT INTO organizations (id, name, display_name) VALUES (1, 'default', 'Default')
+    ON CONFLICT (id) DO NOTHING;
+SELECT setval('organizations_id_seq', GREATEST((SELECT MAX(id) FROM organizations), 1));
+
+-- 为各表添加所属组织
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE agent_connection_history ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE configurations ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE configuration_history ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE configuration_apply_history ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE packages ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE roles ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE invitations ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE mfa_required_roles ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE alerts ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE alert_silences ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE audit_logs ADD COLUMN IF NOT EXISTS organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id);
+
+-- 配置名称在组织内唯一, 配置历史的外键随之改为 (organization_id, configuration_name)
+ALTER TABLE configuration_history DROP CONSTRAINT IF EXISTS fk_configuration;
+ALTER TABLE configuration_history DROP CONSTRAINT IF EXISTS unique_config_version;
+ALTER TABLE configuration_apply_history DROP CONSTRAINT IF EXISTS fk_config_apply;
+ALTER TABLE configurations DROP CONSTRAINT IF EXISTS configurations_pkey;
+ALTER TABLE configurations ADD PRIMARY KEY (organization_id, name);
+ALTER TABLE configuration_history ADD CONSTRAINT fk_configuration
+    FOREIGN KEY (organization_id, configuration_name)
+    REFERENCES configurations(organization_id, name)
+    ON DELETE CASCADE;
+ALTER TABLE configuration_history ADD CONSTRAINT unique_config_version
+    UNIQUE (organization_id, configuration_name, version);
+ALTER TABLE configuration_apply_history ADD CONSTRAINT fk_config_apply
+    FOREIGN KEY (organization_id, configuration_name)
+    REFERENCES configurations(organization_id, name)
+    ON DELETE CASCADE;
+
+-- MFA 策略按组织配置
+ALTER TABLE mfa_required_roles DROP CONSTRAINT IF EXISTS mfa_required_roles_pkey;
+ALTER TABLE mfa_required_roles ADD PRIMARY KEY (organization_id, role);
+
+-- 角色、Webhook、告警规则和软件包的名称在组织内唯一
+ALTER TABLE roles DROP CONSTRAINT IF EXISTS roles_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_roles_org_name ON roles(organization_id, name);
+ALTER TABLE webhooks DROP CONSTRAINT IF EXISTS webhooks_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhooks_org_name ON webhooks(organization_id, name);
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rules_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_alert_rules_org_name ON alert_rules(organization_id, name);
+DROP INDEX IF EXISTS idx_package_unique;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_package_unique
+    ON packages(name, version, platform, arch, organization_id);
+
+-- 按组织查询的索引
+CREATE INDEX IF NOT EXISTS idx_agents_organization_id ON agents(organization_id);
+CREATE INDEX IF NOT EXISTS idx_agent_connection_history_organization_id ON agent_connection_history(organization_id);
+CREATE INDEX IF NOT EXISTS idx_configuration_history_organization_id ON configuration_history(organization_id);
+CREATE INDEX IF NOT EXISTS idx_configuration_apply_history_organization_id ON configuration_apply_history(organization_id);
+CREATE INDEX IF NOT EXISTS idx_users_organization_id ON users(organization_id);
+CREATE INDEX IF NOT EXISTS idx_invitations_organization_id ON invitations(organization_id);
+CREATE INDEX IF NOT EXISTS idx_alerts_organization_id ON alerts(organization_id);
+CREATE INDEX IF NOT EXISTS idx_alert_silences_organization_id ON alert_silences(organization_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_organization_id ON audit_logs(organization_id);
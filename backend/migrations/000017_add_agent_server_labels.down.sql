@@ -0,0 +1,3 @@
+-- 删除上报标签和服务端标签 (labels 保留合并后的值)
+ALTER TABLE agents DROP COLUMN IF EXISTS server_labels;
+ALTER TABLE agents DROP COLUMN IF EXISTS reported_labels;
@@ -0,0 +1,6 @@
+-- Agent 上报的标签和服务端标签分开保存, labels 为两者合并后生效的标签
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS reported_labels JSONB;
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS server_labels JSONB;
+
+-- 已有的标签都来自 Agent 上报
+UPDATE agents SET reported_labels = labels WHERE reported_labels IS NULL;
@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_agents_version_id;
+DROP INDEX IF EXISTS idx_agents_hostname_id;
+DROP INDEX IF EXISTS idx_agents_name_id;
+DROP INDEX IF EXISTS idx_agents_last_seen_at_id;
+DROP INDEX IF EXISTS idx_agents_created_at_id;
+DROP INDEX IF EXISTS idx_agents_updated_at_id;
+DROP INDEX IF EXISTS idx_agents_type_architecture;
+DROP INDEX IF EXISTS idx_agents_version;
+DROP INDEX IF EXISTS idx_agents_hostname_trgm;
+DROP INDEX IF EXISTS idx_agents_name_trgm;
+
+ALTER TABLE agents DROP COLUMN IF EXISTS capabilities;
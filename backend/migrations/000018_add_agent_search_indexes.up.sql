@@ -0,0 +1,19 @@
+-- Agent 上报的能力位掩码, 用于按能力筛选
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS capabilities BIGINT NOT NULL DEFAULT 0;
+
+-- 名称和主机名的子串搜索使用 trigram 索引
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_agents_name_trgm ON agents USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_agents_hostname_trgm ON agents USING GIN (hostname gin_trgm_ops);
+
+-- 常用的等值过滤
+CREATE INDEX IF NOT EXISTS idx_agents_version ON agents(version);
+CREATE INDEX IF NOT EXISTS idx_agents_type_architecture ON agents(type, architecture);
+
+-- 游标分页按 (排序字段, id) 比较
+CREATE INDEX IF NOT EXISTS idx_agents_updated_at_id ON agents(updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_agents_created_at_id ON agents(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_agents_last_seen_at_id ON agents((COALESCE(last_seen_at, '-infinity')), id);
+CREATE INDEX IF NOT EXISTS idx_agents_name_id ON agents(name, id);
+CREATE INDEX IF NOT EXISTS idx_agents_hostname_id ON agents(hostname, id);
+CREATE INDEX IF NOT EXISTS idx_agents_version_id ON agents(version, id);
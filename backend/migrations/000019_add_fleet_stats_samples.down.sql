@@ -0,0 +1 @@
+DROP TABLE IF EXISTS fleet_stats_samples;
@@ -0,0 +1,14 @@
+-- Agent 分组统计的定期采样, 每个维度取值一行
+CREATE TABLE IF NOT EXISTS fleet_stats_samples (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL DEFAULT 1 REFERENCES organizations(id) ON DELETE CASCADE,
+    dimension VARCHAR(255) NOT NULL,
+    sampled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    value TEXT NOT NULL DEFAULT '',
+    count BIGINT NOT NULL DEFAULT 0
+);
+
+-- 按组织和维度查询时间范围内的序列
+CREATE INDEX IF NOT EXISTS idx_fleet_stats_lookup ON fleet_stats_samples(organization_id, dimension, sampled_at);
+-- 清理过期采样
+CREATE INDEX IF NOT EXISTS idx_fleet_stats_samples_sampled_at ON fleet_stats_samples(sampled_at);
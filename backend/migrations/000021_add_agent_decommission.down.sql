@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_agents_decommissioned_at;
+ALTER TABLE agents DROP COLUMN IF EXISTS decommissioned_at;
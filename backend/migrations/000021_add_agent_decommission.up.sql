@@ -0,0 +1,4 @@
+-- Agent 退役时间, 已退役的 Agent 保留记录并拒绝其再次连接
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS decommissioned_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_agents_decommissioned_at ON agents(decommissioned_at);
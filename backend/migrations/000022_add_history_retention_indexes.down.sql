@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_configuration_history_retention;
+DROP INDEX IF EXISTS idx_configuration_apply_history_retention;
+DROP INDEX IF EXISTS idx_agent_connection_history_retention;
@@ -0,0 +1,7 @@
+-- 历史记录保留策略按组织和 Agent (或配置) 分组, 从新到旧编号后分批删除
+CREATE INDEX IF NOT EXISTS idx_agent_connection_history_retention
+    ON agent_connection_history(organization_id, agent_id, connected_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_configuration_apply_history_retention
+    ON configuration_apply_history(organization_id, agent_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_configuration_history_retention
+    ON configuration_history(organization_id, configuration_name, version DESC, id DESC);
@@ -0,0 +1,22 @@
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['sources', 'destinations', 'processors'] LOOP
+        EXECUTE format('DROP INDEX IF EXISTS %I', 'idx_' || t || '_type');
+        EXECUTE format('ALTER TABLE %I DROP CONSTRAINT IF EXISTS %I', t, t || '_pkey');
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS id SERIAL PRIMARY KEY', t);
+        EXECUTE format('ALTER TABLE %I ADD CONSTRAINT %I UNIQUE (name)', t, t || '_name_key');
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS properties JSONB', t);
+        EXECUTE format('UPDATE %I SET properties = parameters', t);
+        EXECUTE format('ALTER TABLE %I DROP COLUMN IF EXISTS parameters', t);
+        EXECUTE format('ALTER TABLE %I DROP COLUMN IF EXISTS description', t);
+        EXECUTE format('ALTER TABLE %I DROP COLUMN IF EXISTS display_name', t);
+    END LOOP;
+END $$;
+
+ALTER TABLE agents ALTER COLUMN status SET DEFAULT 'disconnected';
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS connected_at TIMESTAMP;
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS disconnected_at TIMESTAMP;
+ALTER TABLE agents DROP COLUMN IF EXISTS protocol;
+ALTER TABLE agents RENAME COLUMN op_amp_state TO opamp_state;
@@ -0,0 +1,43 @@
+-- 迁移文件取代 AutoMigrate 之后, 使迁移创建的表结构与模型一致
+
+-- agents: 列名与模型一致, 删除从未写入的 connected_at 和 disconnected_at
+-- (已由 last_connected_at 和 last_disconnected_at 取代)
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'agents' AND column_name = 'opamp_state') THEN
+        ALTER TABLE agents RENAME COLUMN opamp_state TO op_amp_state;
+    END IF;
+END $$;
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS op_amp_state BYTEA;
+ALTER TABLE agents ADD COLUMN IF NOT EXISTS protocol VARCHAR(50);
+ALTER TABLE agents DROP COLUMN IF EXISTS connected_at;
+ALTER TABLE agents DROP COLUMN IF EXISTS disconnected_at;
+ALTER TABLE agents ALTER COLUMN status SET DEFAULT 'offline';
+
+-- sources, destinations, processors: 以名称为主键, properties 改名为 parameters
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['sources', 'destinations', 'processors'] LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS display_name VARCHAR(255)', t);
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS description TEXT', t);
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS parameters JSONB', t);
+
+        IF EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_schema = current_schema() AND table_name = t AND column_name = 'properties') THEN
+            EXECUTE format('UPDATE %I SET parameters = properties WHERE parameters IS NULL', t);
+            EXECUTE format('ALTER TABLE %I DROP COLUMN properties', t);
+        END IF;
+
+        IF EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_schema = current_schema() AND table_name = t AND column_name = 'id') THEN
+            EXECUTE format('ALTER TABLE %I DROP COLUMN id', t);
+            EXECUTE format('ALTER TABLE %I DROP CONSTRAINT IF EXISTS %I', t, t || '_name_key');
+            EXECUTE format('ALTER TABLE %I ADD PRIMARY KEY (name)', t);
+        END IF;
+
+        EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON %I(type)', 'idx_' || t || '_type', t);
+    END LOOP;
+END $$;
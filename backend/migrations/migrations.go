@@ -0,0 +1,9 @@
+// Package migrations 嵌入数据库迁移文件, 服务启动和 migrate 子命令按版本执行
+package migrations
+
+import "embed"
+
+// FS 所有迁移文件 ({version}_{description}.{up|down}.sql)
+//
+//go:embed *.sql
+var FS embed.FS
@@ -37,7 +37,7 @@ import Editor from '@monaco-editor/react';
 import { useConfigurationStore } from '@/stores/configurationStore';
 import { CreateConfigurationRequest, UpdateConfigurationRequest, ConfigurationHistory } from '@/types/api';
 import { format } from 'date-fns';
-import axios from 'axios';
+import apiClient from '@/services/api';
 
 export default function ConfigurationListPage() {
   const {
@@ -45,6 +45,7 @@ export default function ConfigurationListPage() {
     isLoading,
     error,
     fetchConfigurations,
+    fetchConfiguration,
     createConfiguration,
     updateConfiguration,
     deleteConfiguration,
@@ -85,7 +86,13 @@ export default function ConfigurationListPage() {
     setCreateDialogOpen(true);
   };
 
-  const handleEditClick = (config: any) => {
+  // 编辑和删除前重新获取配置, 保存时使用服务端返回的 ETag, 列表数据可能已经过期
+  const handleEditClick = async (name: string) => {
+    await fetchConfiguration(name);
+    const config = useConfigurationStore.getState().selectedConfiguration;
+    if (config?.name !== name) {
+      return;
+    }
     setFormData({
       name: config.name,
       display_name: config.display_name,
@@ -97,7 +104,11 @@ export default function ConfigurationListPage() {
     setEditDialogOpen(true);
   };
 
-  const handleDeleteClick = (name: string) => {
+  const handleDeleteClick = async (name: string) => {
+    await fetchConfiguration(name);
+    if (useConfigurationStore.getState().selectedConfiguration?.name !== name) {
+      return;
+    }
     setSelectedConfigName(name);
     setDeleteDialogOpen(true);
   };
@@ -168,14 +179,10 @@ export default function ConfigurationListPage() {
     setHistoryDialogOpen(true);
     setLoadingHistory(true);
     try {
-      const token = localStorage.getItem('token');
-      const response = await axios.get(
-        `http://localhost:8080/api/v1/configurations/${name}/history`,
-        {
-          headers: { Authorization: `Bearer ${token}` },
-          params: { limit: 50, offset: 0 },
-        }
-      );
+      // 通过 apiClient 请求, 访问令牌过期时自动刷新
+      const response = await apiClient.get(`/configurations/${name}/history`, {
+        params: { limit: 50, offset: 0 },
+      });
       setConfigHistories(response.data.histories || []);
     } catch (err) {
       console.error('Failed to fetch history:', err);
@@ -370,7 +377,7 @@ export default function ConfigurationListPage() {
                   </TableCell>
                   <TableCell>
                     <Tooltip title="编辑">
-                      <IconButton size="small" onClick={() => handleEditClick(config)}>
+                      <IconButton size="small" onClick={() => handleEditClick(config.name)}>
                         <EditIcon fontSize="small" />
                       </IconButton>
                     </Tooltip>
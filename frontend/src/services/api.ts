@@ -1,4 +1,4 @@
-import axios, { AxiosInstance, InternalAxiosRequestConfig } from 'axios';
+import axios, { AxiosError, AxiosInstance, InternalAxiosRequestConfig } from 'axios';
 
 // 创建 axios 实例
 const apiClient: AxiosInstance = axios.create({
@@ -23,19 +23,66 @@ apiClient.interceptors.request.use(
   }
 );
 
-// 响应拦截器 - 处理错误
+// 返回 401 时不刷新令牌的接口: 登录类接口的 401 是凭据错误, 退出登录不需要续期
+const noRefreshUrls = ['/auth/login', '/auth/register', '/auth/refresh', '/auth/logout'];
+
+// 正在进行的刷新请求, 多个请求同时收到 401 时共用一次刷新
+let refreshing: Promise<string> | null = null;
+
+// 使用 refresh token 换取新的访问令牌, refresh token 每次使用后轮换
+const refreshAccessToken = async (): Promise<string> => {
+  const refreshToken = localStorage.getItem('refresh_token');
+  if (!refreshToken) {
+    throw new Error('no refresh token');
+  }
+
+  // 直接使用 axios, 避免刷新请求本身再经过拦截器
+  const response = await axios.post('/api/v1/auth/refresh', { refresh_token: refreshToken });
+  localStorage.setItem('token', response.data.token);
+  localStorage.setItem('refresh_token', response.data.refresh_token);
+  return response.data.token;
+};
+
+// 清除本地登录状态并跳转到登录页
+const redirectToLogin = () => {
+  localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
+  localStorage.removeItem('user');
+  window.location.href = '/login';
+};
+
+// 响应拦截器 - 访问令牌过期时刷新一次并重试原请求
 apiClient.interceptors.response.use(
   (response) => {
     return response;
   },
-  (error) => {
-    if (error.response?.status === 401) {
-      // Token 过期或无效,清除本地存储并跳转到登录页
-      localStorage.removeItem('token');
-      localStorage.removeItem('user');
-      window.location.href = '/login';
+  async (error: AxiosError) => {
+    const config = error.config as (InternalAxiosRequestConfig & { _retried?: boolean }) | undefined;
+    if (error.response?.status !== 401 || !config) {
+      return Promise.reject(error);
+    }
+
+    if (config.url && noRefreshUrls.includes(config.url)) {
+      return Promise.reject(error);
+    }
+    // 刷新后重试仍然失败
+    if (config._retried) {
+      redirectToLogin();
+      return Promise.reject(error);
+    }
+
+    try {
+      refreshing = refreshing ?? refreshAccessToken().finally(() => {
+        refreshing = null;
+      });
+      const token = await refreshing;
+      config._retried = true;
+      config.headers.Authorization = `Bearer ${token}`;
+      return apiClient(config);
+    } catch {
+      redirectToLogin();
+      return Promise.reject(error);
     }
-    return Promise.reject(error);
   }
 );
 
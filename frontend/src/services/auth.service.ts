@@ -25,15 +25,25 @@ export const authService = {
     return response.data;
   },
 
-  // 登出
+  // 登出, 同时吊销服务端的 refresh token
   logout(): void {
+    const token = this.getToken();
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (token && refreshToken) {
+      // 本地令牌随后就被清除, 请求头需要在这里带上
+      apiClient
+        .post('/auth/logout', { refresh_token: refreshToken }, { headers: { Authorization: `Bearer ${token}` } })
+        .catch(() => undefined);
+    }
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('user');
   },
 
-  // 保存 token
-  saveToken(token: string): void {
+  // 保存访问令牌和 refresh token
+  saveTokens(token: string, refreshToken: string): void {
     localStorage.setItem('token', token);
+    localStorage.setItem('refresh_token', refreshToken);
   },
 
   // 获取 token
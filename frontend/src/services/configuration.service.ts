@@ -13,10 +13,10 @@ export const configurationService = {
     return response.data;
   },
 
-  // 获取单个配置
-  async getConfiguration(name: string): Promise<Configuration> {
+  // 获取单个配置, etag 为响应头中的版本标识, 修改和删除时原样放在 If-Match 中
+  async getConfiguration(name: string): Promise<{ configuration: Configuration; etag: string }> {
     const response = await apiClient.get<Configuration>(`/configurations/${name}`);
-    return response.data;
+    return { configuration: response.data, etag: response.headers['etag'] ?? '' };
   },
 
   // 创建配置
@@ -25,17 +25,22 @@ export const configurationService = {
     return response.data;
   },
 
-  // 更新配置
+  // 更新配置, etag 为获取配置时服务端返回的 ETag, 配置已被他人修改时返回 412
   async updateConfiguration(
     name: string,
-    data: UpdateConfigurationRequest
+    data: UpdateConfigurationRequest,
+    etag: string
   ): Promise<Configuration> {
-    const response = await apiClient.put<Configuration>(`/configurations/${name}`, data);
+    const response = await apiClient.put<Configuration>(`/configurations/${name}`, data, {
+      headers: { 'If-Match': etag },
+    });
     return response.data;
   },
 
-  // 删除配置
-  async deleteConfiguration(name: string): Promise<void> {
-    await apiClient.delete(`/configurations/${name}`);
+  // 删除配置, etag 为获取配置时服务端返回的 ETag, 配置已被他人修改时返回 412
+  async deleteConfiguration(name: string, etag: string): Promise<void> {
+    await apiClient.delete(`/configurations/${name}`, {
+      headers: { 'If-Match': etag },
+    });
   },
 };
@@ -26,7 +26,7 @@ export const useAuthStore = create<AuthState>((set) => ({
     set({ isLoading: true, error: null });
     try {
       const response = await authService.login({ username, password });
-      authService.saveToken(response.token);
+      authService.saveTokens(response.token, response.refresh_token);
       localStorage.setItem('user', JSON.stringify(response.user));
       set({ user: response.user, token: response.token, isLoading: false });
     } catch (error: any) {
@@ -40,7 +40,7 @@ export const useAuthStore = create<AuthState>((set) => ({
     set({ isLoading: true, error: null });
     try {
       const response = await authService.register({ username, email, password });
-      authService.saveToken(response.token);
+      authService.saveTokens(response.token, response.refresh_token);
       localStorage.setItem('user', JSON.stringify(response.user));
       set({ user: response.user, token: response.token, isLoading: false });
     } catch (error: any) {
@@ -5,6 +5,8 @@ import { configurationService } from '@/services/configuration.service';
 interface ConfigurationState {
   configurations: Configuration[];
   selectedConfiguration: Configuration | null;
+  // selectedETag 获取 selectedConfiguration 时服务端返回的 ETag, 修改和删除时作为 If-Match
+  selectedETag: string | null;
   total: number;
   isLoading: boolean;
   error: string | null;
@@ -18,9 +20,14 @@ interface ConfigurationState {
   clearError: () => void;
 }
 
+// loadedETag 返回通过 fetchConfiguration 加载的配置的 ETag, 没有加载该配置时返回空字符串, 服务端会要求重新获取
+const loadedETag = (state: ConfigurationState, name: string): string =>
+  state.selectedConfiguration?.name === name && state.selectedETag ? state.selectedETag : '';
+
 export const useConfigurationStore = create<ConfigurationState>((set, get) => ({
   configurations: [],
   selectedConfiguration: null,
+  selectedETag: null,
   total: 0,
   isLoading: false,
   error: null,
@@ -43,8 +50,8 @@ export const useConfigurationStore = create<ConfigurationState>((set, get) => ({
   fetchConfiguration: async (name: string) => {
     set({ isLoading: true, error: null });
     try {
-      const configuration = await configurationService.getConfiguration(name);
-      set({ selectedConfiguration: configuration, isLoading: false });
+      const { configuration, etag } = await configurationService.getConfiguration(name);
+      set({ selectedConfiguration: configuration, selectedETag: etag, isLoading: false });
     } catch (error: any) {
       const errorMessage = error.response?.data?.error || '获取配置详情失败';
       set({ error: errorMessage, isLoading: false });
@@ -67,11 +74,15 @@ export const useConfigurationStore = create<ConfigurationState>((set, get) => ({
   updateConfiguration: async (name: string, data: UpdateConfigurationRequest) => {
     set({ isLoading: true, error: null });
     try {
-      await configurationService.updateConfiguration(name, data);
+      await configurationService.updateConfiguration(name, data, loadedETag(get(), name));
+      set({ selectedConfiguration: null, selectedETag: null });
       // 更新成功后重新加载列表
       await get().fetchConfigurations();
     } catch (error: any) {
-      const errorMessage = error.response?.data?.error || '更新配置失败';
+      const errorMessage =
+        error.response?.status === 412
+          ? '配置已被其他用户修改, 请刷新后重试'
+          : error.response?.data?.error || '更新配置失败';
       set({ error: errorMessage, isLoading: false });
       throw error;
     }
@@ -80,11 +91,15 @@ export const useConfigurationStore = create<ConfigurationState>((set, get) => ({
   deleteConfiguration: async (name: string) => {
     set({ isLoading: true, error: null });
     try {
-      await configurationService.deleteConfiguration(name);
+      await configurationService.deleteConfiguration(name, loadedETag(get(), name));
+      set({ selectedConfiguration: null, selectedETag: null });
       // 删除成功后重新加载列表
       await get().fetchConfigurations();
     } catch (error: any) {
-      const errorMessage = error.response?.data?.error || '删除配置失败';
+      const errorMessage =
+        error.response?.status === 412
+          ? '配置已被其他用户修改, 请刷新后重试'
+          : error.response?.data?.error || '删除配置失败';
       set({ error: errorMessage, isLoading: false });
       throw error;
     }
@@ -29,6 +29,8 @@ export interface RegisterRequest {
 
 export interface AuthResponse {
   token: string;
+  refresh_token: string;
+  expires_in: number;
   user: User;
 }
 
@@ -63,6 +65,7 @@ export interface Configuration {
   content_type: 'yaml' | 'json';
   raw_config: string;
   config_hash: string;
+  version: number;
   selector?: Record<string, string>;
   created_at: string;
   updated_at: string;